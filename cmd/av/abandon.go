@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/sequencer"
+	"github.com/aviator-co/av/internal/sequencer/planner"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var abandonFlags struct {
+	Reason string
+}
+
+var abandonCmd = &cobra.Command{
+	Use:   "abandon [<branch>] [--reason <reason>]",
+	Short: "Abandon a branch, moving its children onto its parent",
+	Long: strings.TrimSpace(`
+Abandon a branch (the current branch if none is given): move its children onto
+its parent branch, close its pull request with a comment, and delete the branch
+locally and on the remote.
+
+The children are rebased onto the parent branch so that they no longer have the
+commits of the abandoned branch, and their pull requests are retargeted to the
+parent branch. If a child can't be rebased without conflicts, nothing is changed.
+
+The comment is the stack.abandonComment config followed by the reason given with
+--reason. Run av undo to restore the local branches. The closed pull request is
+not reopened, and the deleted remote branch is not restored.
+`),
+	Args:              cobra.MaximumNArgs(1),
+	SilenceUsage:      true,
+	ValidArgsFunction: branchNameArgAt(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		if err := checkNoOtherOperationInProgress(repo, ""); err != nil {
+			return err
+		}
+		// The current branch is empty on a detached HEAD.
+		currentBranch, _ := repo.CurrentBranchName()
+		name := currentBranch
+		if len(args) > 0 {
+			name = stripRemoteRefPrefixes(repo, args[0])
+		}
+		if name == "" {
+			return errors.New("no branch is given and HEAD is detached")
+		}
+
+		tx := db.ReadTx()
+		branch, exists := tx.Branch(name)
+		if !exists {
+			return actions.BranchNotAdoptedError{Branch: name}
+		}
+		children := meta.Children(tx, name)
+		for _, child := range children {
+			if child.IsPinned() {
+				return errors.Errorf(
+					"child branch %q is pinned to %q (run 'av unpin %s' first)",
+					child.Name, name, child.Name,
+				)
+			}
+		}
+
+		if err := oplog.Record(repo, tx, "av abandon"); err != nil {
+			return err
+		}
+		if err := abandonBranch(
+			repo, db, branch, children, currentBranch, abandonFlags.Reason,
+		); err != nil {
+			restoreAbandonedBranches(repo, db)
+			return errors.WrapIff(err, "failed to abandon branch %q", name)
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Abandoned branch "), colors.UserInput(name), colors.Success(".\n"),
+		)
+		for _, child := range children {
+			fmt.Fprint(os.Stderr,
+				"  - moved ", colors.UserInput(child.Name),
+				" onto ", colors.UserInput(branch.Parent.Name), "\n",
+			)
+		}
+		if len(children) > 0 {
+			fmt.Fprint(os.Stderr,
+				colors.Faint("  - run "), colors.CliCmd("av pr --all"),
+				colors.Faint(" to push the moved branches and update the pull requests\n"),
+			)
+		}
+		return nil
+	},
+}
+
+// abandonBranch moves the children of the branch onto its parent, closes its pull request, and
+// deletes the branch. The caller restores the branches if this fails.
+func abandonBranch(
+	repo *git.Repo,
+	db meta.DB,
+	branch meta.Branch,
+	children []meta.Branch,
+	currentBranch string,
+	reason string,
+) error {
+	ops := planner.PlanForAbandon(db.ReadTx(), plumbing.NewBranchReferenceName(branch.Name))
+	seq := sequencer.NewSequencer(repo.GetRemoteName(), db, ops)
+	for seq.CurrentSyncRef != "" {
+		result, err := seq.Run(repo, db, false, false, false)
+		if err != nil {
+			return err
+		}
+		if result != nil && result.Status == git.RebaseConflict {
+			return errors.Errorf(
+				"branch %q conflicts with %q", seq.CurrentSyncRef.Short(), branch.Parent.Name,
+			)
+		}
+	}
+	// The rebases leave the last rebased branch checked out.
+	checkout := currentBranch
+	if checkout == branch.Name {
+		checkout = branch.Parent.Name
+	}
+	if checkout != "" {
+		if _, err := repo.CheckoutBranch(&git.CheckoutBranch{Name: checkout}); err != nil {
+			return err
+		}
+	}
+
+	if err := updateAbandonedPullRequests(db, branch, children, reason); err != nil {
+		return err
+	}
+
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+	// The merged children are not rebased, but they can't stay on a deleted branch.
+	for _, child := range meta.Children(tx, branch.Name) {
+		child.Parent = branch.Parent
+		tx.SetBranch(child)
+	}
+	tx.DeleteBranch(branch.Name)
+	// Deleting the branch drops its config (branch.<name>.pushRemote).
+	remote := repo.GetBranchPushRemoteName(branch.Name)
+	if err := repo.BranchDelete(branch.Name); err != nil {
+		return errors.WrapIff(err, "failed to delete branch %q", branch.Name)
+	}
+	cu.Cancel()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if exists, _ := repo.DoesRefExist(git.RemoteTrackingRef(remote, branch.Name)); exists {
+		if err := repo.BranchDeleteRemote(remote, branch.Name); err != nil {
+			// The local changes are done, so this is not worth undoing them.
+			logrus.WithError(err).Warnf("failed to delete branch %q on %s", branch.Name, remote)
+		}
+	}
+	return nil
+}
+
+// restoreAbandonedBranches restores the branches as they were before a failed abandonment (the
+// last operation in the operation log).
+func restoreAbandonedBranches(repo *git.Repo, db meta.DB) {
+	if gitPathExists(repo, "rebase-merge") || gitPathExists(repo, "rebase-apply") {
+		if _, err := repo.Rebase(git.RebaseOpts{Abort: true}); err != nil {
+			logrus.WithError(err).Warn("failed to abort the rebase")
+		}
+	}
+	if _, err := oplog.Undo(repo, db, 1); err != nil {
+		logrus.WithError(err).Warn("failed to restore the branches (run av undo)")
+	}
+}
+
+// updateAbandonedPullRequests retargets the pull requests of the children of the abandoned
+// branch to its parent, and closes the pull request of the branch. The children are retargeted
+// first because deleting the base branch of a pull request closes it.
+func updateAbandonedPullRequests(
+	db meta.DB,
+	branch meta.Branch,
+	children []meta.Branch,
+	reason string,
+) error {
+	var retarget []meta.Branch
+	for _, child := range children {
+		if child.PullRequest != nil && child.PullRequest.State == githubv4.PullRequestStateOpen {
+			retarget = append(retarget, child)
+		}
+	}
+	isOpen := branch.PullRequest != nil &&
+		branch.PullRequest.State == githubv4.PullRequestStateOpen
+	if len(retarget) == 0 && !isOpen {
+		return nil
+	}
+
+	f, err := getForge(db.ReadTx().Repository())
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	for _, child := range retarget {
+		if _, err := f.UpdatePullRequest(ctx, forge.UpdatePullRequestInput{
+			ID:          child.PullRequest.ID,
+			BaseRefName: &branch.Parent.Name,
+		}); err != nil {
+			return errors.WrapIff(
+				err, "failed to retarget pull request #%d", child.PullRequest.Number,
+			)
+		}
+	}
+	if isOpen {
+		if _, err := f.ClosePullRequest(
+			ctx, branch.PullRequest.ID, abandonComment(reason),
+		); err != nil {
+			return errors.WrapIff(err, "failed to close pull request #%d", branch.PullRequest.Number)
+		}
+		fmt.Fprint(os.Stderr,
+			"Closed pull request ", colors.UserInput(branch.PullRequest.Permalink), ".\n",
+		)
+	}
+	return nil
+}
+
+func init() {
+	abandonCmd.Flags().StringVar(
+		&abandonFlags.Reason, "reason", "",
+		"the reason for abandoning the branch, appended to the comment on the pull request",
+	)
+}
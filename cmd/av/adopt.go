@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
 	"sort"
 	"strings"
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/gh"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/treedetector"
@@ -19,12 +24,16 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/shurcooL/githubv4"
 	"github.com/spf13/cobra"
 )
 
 var adoptFlags struct {
-	Parent string
-	DryRun bool
+	Parent      string
+	DryRun      bool
+	Scan        bool
+	Yes         bool
+	FromPRChain string
 }
 
 var adoptCmd = &cobra.Command{
@@ -34,11 +43,23 @@ var adoptCmd = &cobra.Command{
 Adopt branches that are not managed by av.
 
 This command will show a list of branches that are not managed by av. You can choose which branches
-should be adopted to av.
+should be adopted to av. The parents of the branches are inferred from the commit graph. If the
+inferred parent is wrong (or cannot be inferred), press "p" to choose a different parent before
+adopting.
 
 If you want to adopt the current branch, you can use the --parent flag to specify the parent branch.
 For example, "av adopt --parent main" will adopt the current branch with the main branch as
-the parent.`),
+the parent. The parent can also be a tag or a commit SHA (e.g., "av adopt --parent v1.2.0"), which
+is treated as the trunk frozen at that commit.
+
+With --scan, the parents of all the branches that are not managed by av are inferred from the
+commit graph, including the merge bases between the branches, and the proposed stacks are shown.
+The branches are adopted after a confirmation.
+
+With --from-pr-chain, the stack is reconstructed from GitHub instead: starting from the given
+topmost pull request, the base branches are followed through their pull requests down to the
+trunk, and all the head branches are fetched and adopted (e.g., to take over the stack of a
+teammate who left, without their av metadata).`),
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		repo, err := getRepo()
@@ -51,6 +72,13 @@ the parent.`),
 			return err
 		}
 
+		if adoptFlags.FromPRChain != "" {
+			if adoptFlags.DryRun {
+				return errors.New("cannot use --from-pr-chain with --dry-run")
+			}
+			return adoptFromPullRequestChain(repo, db, adoptFlags.FromPRChain)
+		}
+
 		status, err := repo.Status()
 		if err != nil {
 			return err
@@ -60,6 +88,17 @@ the parent.`),
 		if adoptFlags.Parent != "" {
 			return adoptForceAdoption(repo, db, currentBranch, adoptFlags.Parent)
 		}
+		if adoptFlags.Scan {
+			return adoptScan(repo, db, currentBranch)
+		}
+		if adoptFlags.Yes {
+			return errors.New("--yes can only be used with --scan")
+		}
+		if err := uiutils.RequireInteractive(
+			"choosing the branches to adopt", "use --parent, or --scan with --yes",
+		); err != nil {
+			return err
+		}
 
 		return uiutils.RunBubbleTea(&adoptViewModel{
 			repo:              repo,
@@ -73,6 +112,47 @@ the parent.`),
 	},
 }
 
+// adoptFromPullRequestChain adopts the branches of the given pull request and its base branches
+// down to the trunk, reconstructed from the pull requests on GitHub.
+func adoptFromPullRequestChain(repo *git.Repo, db meta.DB, ref string) error {
+	client, err := getGitHubClient()
+	if err != nil {
+		return err
+	}
+	info := db.ReadTx().Repository()
+	number, err := parsePullRequestRef(ref, info)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	top, err := client.PullRequestByNumber(ctx, gh.PullRequestOpts{
+		Owner:  info.Owner,
+		Repo:   info.Name,
+		Number: number,
+	})
+	if err != nil {
+		return err
+	}
+	if top.State != githubv4.PullRequestStateOpen {
+		return errors.Errorf("pull request #%d is %s", top.Number, strings.ToLower(string(top.State)))
+	}
+	prs, trunk, err := pullRequestChain(ctx, repo, client, info, top)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stderr,
+		"Adopting the chain of pull request ", colors.UserInput("#", top.Number), ":\n",
+	)
+	if err := adoptPullRequestBranches(repo, db, prs, trunk); err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Success("Adopted "), colors.UserInput(len(prs)), colors.Success(" branches. Run "),
+		colors.CliCmd("av switch ", top.HeadBranchName()), colors.Success(" to check out the top of the stack.\n"),
+	)
+	return nil
+}
+
 func adoptForceAdoption(repo *git.Repo, db meta.DB, currentBranch, parent string) error {
 	if currentBranch == "" {
 		return errors.New("the current repository state is at a detached HEAD")
@@ -95,6 +175,25 @@ func adoptForceAdoption(repo *git.Repo, db meta.DB, currentBranch, parent string
 		return errors.New("cannot adopt the default branch")
 	}
 
+	if commit, ok := resolveImmutableParent(repo, parent); ok {
+		// A tag or a commit as the parent is treated as the trunk frozen at the commit.
+		defaultBranch, err := repo.DefaultBranch()
+		if err != nil {
+			return errors.WrapIf(err, "failed to determine repository default branch")
+		}
+		branch.Parent = meta.BranchState{
+			Name:  defaultBranch,
+			Trunk: true,
+		}
+		branch.FrozenBase = commit
+		branch.BaseRef = parent
+		tx.SetBranch(branch)
+		if adoptFlags.DryRun {
+			return nil
+		}
+		return tx.Commit()
+	}
+
 	isParentBranchTrunk, err := repo.IsTrunkBranch(parent)
 	if err != nil {
 		return errors.Wrap(err, "failed to check if the parent branch is trunk")
@@ -127,6 +226,103 @@ func adoptForceAdoption(repo *git.Repo, db meta.DB, currentBranch, parent string
 	return tx.Commit()
 }
 
+// adoptScan infers the stacks of all the unmanaged branches, shows them, and adopts the branches
+// whose parents are known after a confirmation.
+func adoptScan(repo *git.Repo, db meta.DB, currentBranch string) error {
+	unmanagedBranches, err := getUnmanagedBranches(repo, db)
+	if err != nil {
+		return err
+	}
+	pieces, err := treedetector.DetectBranches(repo, unmanagedBranches)
+	if err != nil {
+		return err
+	}
+	var adoptedBranches []plumbing.ReferenceName
+	for name := range db.ReadTx().AllBranches() {
+		adoptedBranches = append(adoptedBranches, plumbing.NewBranchReferenceName(name))
+	}
+	if err := treedetector.InferParentsByMergeBase(repo, pieces, adoptedBranches); err != nil {
+		return err
+	}
+
+	vm := &adoptViewModel{
+		repo:              repo,
+		db:                db,
+		currentHEADBranch: plumbing.NewBranchReferenceName(currentBranch),
+		chosenTargets:     make(map[plumbing.ReferenceName]bool),
+	}
+	vm.treeInfo = vm.newTreeInfo(pieces)
+	var unknown []plumbing.ReferenceName
+	for _, branch := range vm.treeInfo.adoptionTargets {
+		if vm.hasKnownParent(branch) {
+			vm.chosenTargets[branch] = true
+		} else {
+			unknown = append(unknown, branch)
+		}
+	}
+	if len(vm.treeInfo.adoptionTargets) == 0 {
+		fmt.Fprint(os.Stderr, colors.Success("No branch to adopt.\n"))
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, "Proposed stacks:\n")
+	for _, rootNode := range vm.treeInfo.rootNodes {
+		fmt.Fprint(os.Stderr, "\n", stackutils.RenderTree(
+			rootNode,
+			func(branchName string, isTrunk bool) string {
+				return vm.renderBranch(plumbing.NewBranchReferenceName(branchName), isTrunk)
+			},
+		))
+	}
+	if len(unknown) > 0 {
+		fmt.Fprint(os.Stderr, "\n", colors.Warning("The parents of these branches can't be inferred:"), "\n")
+		for _, branch := range unknown {
+			piece := vm.treeInfo.branches[branch]
+			var reason string
+			if piece.ContainsMergeCommit {
+				reason = "contains a merge commit"
+			} else if len(piece.PossibleParents) > 0 {
+				var names []string
+				for _, p := range piece.PossibleParents {
+					names = append(names, p.Short())
+				}
+				reason = "possible parents: " + strings.Join(names, ", ")
+			} else {
+				reason = "its parent can't be adopted"
+			}
+			fmt.Fprint(os.Stderr, "  - ", colors.UserInput(branch.Short()), " (", reason, ")\n")
+		}
+		fmt.Fprint(os.Stderr,
+			"Run ", colors.CliCmd("av adopt"), " to choose their parents.\n",
+		)
+	}
+	if len(vm.chosenTargets) == 0 || adoptFlags.DryRun {
+		return nil
+	}
+
+	if !adoptFlags.Yes {
+		if err := uiutils.RequireInteractive("confirming the adoption", "use --yes"); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "\nAdopt %d branch(es)? [y/N]: ", len(vm.chosenTargets))
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && answer == "" {
+			return err
+		}
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Fprint(os.Stderr, colors.Failure("Aborting adoption."), "\n")
+			return nil
+		}
+	}
+	if err, ok := vm.adoptBranches().(error); ok {
+		return err
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Success("Adopted "), colors.UserInput(len(vm.chosenTargets)), colors.Success(" branch(es).\n"),
+	)
+	return nil
+}
+
 type adoptTreeInfo struct {
 	branches        map[plumbing.ReferenceName]*treedetector.BranchPiece
 	rootNodes       []*stackutils.StackTreeNode
@@ -143,6 +339,7 @@ type adoptViewModel struct {
 	currentCursor      plumbing.ReferenceName
 	chosenTargets      map[plumbing.ReferenceName]bool
 	treeInfo           *adoptTreeInfo
+	parentPicker       *adoptParentPicker
 	adoptionComplete   bool
 	adoptionInProgress bool
 
@@ -164,8 +361,10 @@ func (vm adoptViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return vm, tea.Quit
 		}
 		for _, branch := range vm.treeInfo.adoptionTargets {
-			// By default choose everything.
-			vm.chosenTargets[branch] = true
+			// By default choose everything that can be adopted.
+			if vm.hasKnownParent(branch) {
+				vm.chosenTargets[branch] = true
+			}
 		}
 		vm.currentCursor = vm.treeInfo.adoptionTargets[0]
 		if adoptFlags.DryRun {
@@ -175,6 +374,30 @@ func (vm adoptViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		vm.adoptionComplete = true
 		return vm, tea.Quit
 	case tea.KeyMsg:
+		if vm.parentPicker != nil {
+			switch msg.String() {
+			case "ctrl+c":
+				return vm, tea.Quit
+			case "up", "k", "ctrl+p":
+				if vm.parentPicker.cursor > 0 {
+					vm.parentPicker.cursor--
+				}
+			case "down", "j", "ctrl+n":
+				if vm.parentPicker.cursor < len(vm.parentPicker.candidates)-1 {
+					vm.parentPicker.cursor++
+				}
+			case "esc", "q":
+				vm.parentPicker = nil
+			case "enter":
+				picker := vm.parentPicker
+				vm.parentPicker = nil
+				if err := vm.setParent(picker.branch, picker.candidates[picker.cursor]); err != nil {
+					vm.err = err
+					return vm, tea.Quit
+				}
+			}
+			return vm, nil
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return vm, tea.Quit
@@ -187,6 +410,8 @@ func (vm adoptViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				vm.currentCursor = vm.getNextBranch()
 			case " ":
 				vm.toggleAdoption(vm.currentCursor)
+			case "p":
+				vm.parentPicker = vm.newParentPicker(vm.currentCursor)
 			case "enter":
 				vm.adoptionInProgress = true
 				return vm, vm.adoptBranches
@@ -201,7 +426,7 @@ func (vm adoptViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (vm adoptViewModel) initCmd() tea.Msg {
-	unmanagedBranches, err := vm.getUnmanagedBranches()
+	unmanagedBranches, err := getUnmanagedBranches(vm.repo, vm.db)
 	if err != nil {
 		return err
 	}
@@ -209,18 +434,34 @@ func (vm adoptViewModel) initCmd() tea.Msg {
 	if err != nil {
 		return err
 	}
+	return vm.newTreeInfo(pieces)
+}
+
+func (vm adoptViewModel) newTreeInfo(
+	pieces map[plumbing.ReferenceName]*treedetector.BranchPiece,
+) *adoptTreeInfo {
 	nodes := treedetector.ConvertToStackTree(vm.db, pieces, plumbing.HEAD, false)
+	var targets []plumbing.ReferenceName
+	for _, node := range nodes {
+		targets = append(targets, vm.getAdoptionTargets(node)...)
+		root := plumbing.NewBranchReferenceName(node.Branch.BranchName)
+		if _, ok := pieces[root]; ok {
+			// The parent of this branch couldn't be inferred. It's listed so that the parent
+			// can be chosen.
+			targets = append(targets, root)
+		}
+	}
 	return &adoptTreeInfo{
 		branches:        pieces,
 		rootNodes:       nodes,
-		adoptionTargets: vm.getAdoptionTargets(nodes[0]),
+		adoptionTargets: targets,
 	}
 }
 
-func (vm *adoptViewModel) getUnmanagedBranches() ([]plumbing.ReferenceName, error) {
-	tx := vm.db.ReadTx()
+func getUnmanagedBranches(repo *git.Repo, db meta.DB) ([]plumbing.ReferenceName, error) {
+	tx := db.ReadTx()
 	adoptedBranches := tx.AllBranches()
-	branches, err := vm.repo.GoGitRepo().Branches()
+	branches, err := repo.GoGitRepo().Branches()
 	if err != nil {
 		return nil, err
 	}
@@ -285,8 +526,27 @@ func (vm adoptViewModel) getNextBranch() plumbing.ReferenceName {
 	return vm.currentCursor
 }
 
+// hasKnownParent returns true if the parents of the branch are known all the way up to an adopted
+// branch or a trunk.
+func (vm adoptViewModel) hasKnownParent(branch plumbing.ReferenceName) bool {
+	for {
+		piece, ok := vm.treeInfo.branches[branch]
+		if !ok {
+			// Adopted branch or trunk.
+			return true
+		}
+		if piece.Parent == "" {
+			return false
+		}
+		if piece.ParentIsTrunk {
+			return true
+		}
+		branch = piece.Parent
+	}
+}
+
 func (vm *adoptViewModel) toggleAdoption(branch plumbing.ReferenceName) {
-	if vm.treeInfo == nil {
+	if vm.treeInfo == nil || !vm.hasKnownParent(branch) {
 		return
 	}
 	if vm.chosenTargets[branch] {
@@ -312,6 +572,65 @@ func (vm *adoptViewModel) toggleAdoption(branch plumbing.ReferenceName) {
 	}
 }
 
+type adoptParentPicker struct {
+	branch     plumbing.ReferenceName
+	candidates []plumbing.ReferenceName
+	cursor     int
+}
+
+func (vm adoptViewModel) newParentPicker(branch plumbing.ReferenceName) *adoptParentPicker {
+	piece, ok := vm.treeInfo.branches[branch]
+	if !ok {
+		return nil
+	}
+	excluded := treedetector.GetChildren(vm.treeInfo.branches, branch)
+	excluded[branch] = piece
+
+	picker := &adoptParentPicker{branch: branch}
+	trunks, _ := vm.repo.TrunkBranches()
+	for _, trunk := range trunks {
+		picker.candidates = append(picker.candidates, plumbing.NewBranchReferenceName(trunk))
+	}
+	var others []plumbing.ReferenceName
+	for name := range vm.db.ReadTx().AllBranches() {
+		others = append(others, plumbing.NewBranchReferenceName(name))
+	}
+	for bn := range vm.treeInfo.branches {
+		if _, ok := excluded[bn]; !ok && vm.hasKnownParent(bn) {
+			others = append(others, bn)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i] < others[j] })
+	picker.candidates = append(picker.candidates, others...)
+	for i, candidate := range picker.candidates {
+		if candidate == piece.Parent {
+			picker.cursor = i
+		}
+	}
+	return picker
+}
+
+// setParent changes the parent of the branch and selects it (and its parents) for adoption.
+func (vm *adoptViewModel) setParent(branch, parent plumbing.ReferenceName) error {
+	isTrunk, err := vm.repo.IsTrunkBranch(parent.Short())
+	if err != nil {
+		return err
+	}
+	if err := treedetector.SetParent(vm.repo, vm.treeInfo.branches[branch], parent, isTrunk); err != nil {
+		return err
+	}
+	vm.treeInfo = vm.newTreeInfo(vm.treeInfo.branches)
+	for bn := range vm.chosenTargets {
+		if !vm.hasKnownParent(bn) {
+			delete(vm.chosenTargets, bn)
+		}
+	}
+	if !vm.chosenTargets[branch] {
+		vm.toggleAdoption(branch)
+	}
+	return nil
+}
+
 type adoptionCompleteMsg struct{}
 
 func (vm adoptViewModel) adoptBranches() tea.Msg {
@@ -344,6 +663,10 @@ func (vm adoptViewModel) View() string {
 			ss = append(ss, colors.SuccessStyle.Render("✓ Adoption complete"))
 		} else if vm.adoptionInProgress {
 			ss = append(ss, colors.ProgressStyle.Render(vm.spinner.View()+"Adopting the chosen branches..."))
+		} else if vm.parentPicker != nil {
+			ss = append(ss, colors.QuestionStyle.Render(
+				"Choose the parent branch of "+vm.parentPicker.branch.Short(),
+			))
 		} else {
 			choosing = true
 			ss = append(ss, colors.QuestionStyle.Render("Choose which branches to adopt"))
@@ -366,6 +689,18 @@ func (vm adoptViewModel) View() string {
 				),
 			)
 		}
+		if vm.parentPicker != nil && !vm.adoptionInProgress && !vm.adoptionComplete {
+			ss = append(ss, "")
+			for i, candidate := range vm.parentPicker.candidates {
+				if i == vm.parentPicker.cursor {
+					ss = append(ss, colors.PromptChoice.Render("> "+candidate.Short()))
+				} else {
+					ss = append(ss, "  "+candidate.Short())
+				}
+			}
+			ss = append(ss, "")
+			ss = append(ss, vm.help.ShortHelpView(adoptParentPickerKeys))
+		}
 		if choosing {
 			ss = append(ss, "")
 			ss = append(ss, vm.help.ShortHelpView(promptKeys))
@@ -437,6 +772,9 @@ func (vm adoptViewModel) renderBranch(branch plumbing.ReferenceName, isTrunk boo
 	if vm.currentHEADBranch == branch {
 		status = append(status, "HEAD")
 	}
+	if !vm.hasKnownParent(branch) {
+		status = append(status, "parent unknown")
+	}
 	if len(status) != 0 {
 		sb.WriteString(" (" + strings.Join(status, ", ") + ")")
 	}
@@ -460,14 +798,21 @@ func init() {
 		&adoptFlags.DryRun, "dry-run", false,
 		"dry-run adoption",
 	)
-
-	_ = adoptCmd.RegisterFlagCompletionFunc(
-		"parent",
-		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-			branches, _ := allBranches()
-			return branches, cobra.ShellCompDirectiveNoFileComp
-		},
+	adoptCmd.Flags().BoolVar(
+		&adoptFlags.Scan, "scan", false,
+		"infer the stacks of all the unmanaged branches and adopt them after a confirmation",
+	)
+	adoptCmd.Flags().BoolVarP(
+		&adoptFlags.Yes, "yes", "y", false,
+		"adopt the branches found by --scan without a confirmation",
+	)
+	adoptCmd.Flags().StringVar(
+		&adoptFlags.FromPRChain, "from-pr-chain", "",
+		"adopt the stack of the given topmost pull request (number or URL) reconstructed from GitHub",
 	)
+	adoptCmd.MarkFlagsMutuallyExclusive("parent", "scan", "from-pr-chain")
+
+	_ = adoptCmd.RegisterFlagCompletionFunc("parent", branchNameArgs)
 }
 
 var promptKeys = []key.Binding{
@@ -483,6 +828,10 @@ var promptKeys = []key.Binding{
 		key.WithKeys("space"),
 		key.WithHelp("space", "select / unselect"),
 	),
+	key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("p", "change parent"),
+	),
 	key.NewBinding(
 		key.WithKeys("enter"),
 		key.WithHelp("enter", "adopt selected branches"),
@@ -492,3 +841,22 @@ var promptKeys = []key.Binding{
 		key.WithHelp("ctrl+c", "cancel"),
 	),
 }
+
+var adoptParentPickerKeys = []key.Binding{
+	key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "move up"),
+	),
+	key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "move down"),
+	),
+	key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "set as the parent"),
+	),
+	key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "back"),
+	),
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/gh"
 
 	"github.com/aviator-co/av/internal/avgql"
@@ -56,14 +57,22 @@ func checkGitHubAuthStatus() error {
 	if err != nil {
 		return err
 	}
+	// The client uses the same token.
+	source := gh.DiscoverCredential().Source
+	sourceNote := "the GitHub token is read from " + source
+	if config.Av.GitHub.Transport == config.GitHubTransportGhCLI {
+		source = "the gh CLI"
+		sourceNote = "the GitHub API requests are sent through the gh CLI (gitHub.transport)"
+	}
 
 	viewer, err := ghClient.Viewer(context.Background())
 	if err != nil {
 		// GitHub API returns 401 Unauthorized if the token is invalid or
 		// expired.
 		if gh.IsHTTPUnauthorized(err) {
-			return errors.New(
-				"You are not logged in to GitHub. Please verify that your API token is correct.",
+			return errors.Errorf(
+				"You are not logged in to GitHub. Please verify that your API token (read from %s) is correct.",
+				source,
 			)
 		}
 		return errors.Wrap(err, "Failed to query GitHub")
@@ -72,6 +81,7 @@ func checkGitHubAuthStatus() error {
 	fmt.Fprint(os.Stderr,
 		"Logged in to GitHub as ", colors.UserInput(viewer.Name),
 		" (", colors.UserInput(viewer.Login), ").\n",
+		colors.Faint("  - "+sourceNote+"\n"),
 	)
 	return nil
 }
@@ -7,7 +7,9 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/hooks"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/cleanup"
 	"github.com/aviator-co/av/internal/utils/colors"
@@ -24,11 +26,23 @@ var branchFlags struct {
 	// within the stack). The branch can only be renamed if a pull request does
 	// not exist.
 	Rename bool
-	// If true, rename the current branch even if a pull request exists.
+	// If true, rename the current branch even if a pull request exists, and skip the branch
+	// namespace checks (see config.PullRequest.EnforceBranchNamePrefix) and the stack depth
+	// limit (see config.Stack.MaxDepth).
 	Force bool
+	// If true, use the branch name as it is given instead of generating a name from it with
+	// the branch name template (see config.PullRequest.BranchNameTemplate).
+	Exact bool
+	// If true, the branch is excluded from submit (see meta.Branch.NoSubmit). Without a branch
+	// name, this marks the current branch.
+	NoSubmit bool
+	// If true, the current branch is included in submit again.
+	Submit bool
+	// The issue to link the new branch (or the current branch) to (see meta.Branch.Issue).
+	Issue string
 }
 var branchCmd = &cobra.Command{
-	Use:   "branch [flags] <branch-name> [<parent-branch>]",
+	Use:   "branch [flags] <branch-name | description> [<parent-branch>]",
 	Short: "Create or rename a branch in the stack",
 	Long: strings.TrimSpace(`
 Create a new branch that is stacked on the current branch.
@@ -38,10 +52,55 @@ Create a new branch that is stacked on the current branch.
 If the --rename/-m flag is given, the current branch is renamed to the name
 given as the first argument to the command. Branches should only be renamed
 with this command (not with git branch -m ...) because av needs to update
-internal tracking metadata that defines the order of branches within a stack.`),
-	Args: cobra.RangeArgs(0, 2),
+internal tracking metadata that defines the order of branches within a stack.
+
+If a free-form description with spaces is given instead of a branch name (e.g.,
+av branch "Fix login race"), the branch name is generated from it with the
+pullRequest.branchNameTemplate config (e.g., "{user}/{ticket}/{slug}" for
+"sam/fix-login-race"), or as the kebab case of the description prefixed with
+pullRequest.branchNamePrefix. The --exact flag uses the given name as it is.
+
+If pullRequest.branchNamePattern is set in the config, creating or renaming to a
+branch name that doesn't match the pattern is refused unless the --force flag is
+given.
+
+If pullRequest.enforceBranchNamePrefix is set in the config, the configured
+pullRequest.branchNamePrefix (e.g., "alice/") is prepended to the branch name
+unless it already has the prefix. In that case, creating a branch that already
+exists on the remote and renaming a branch outside the prefix are refused unless
+the --force flag is given.
+
+The parent can also be a tag or a commit SHA (e.g., a release tag to build a
+hotfix stack on). The new branch is then based on the trunk frozen at that
+commit, as with av freeze-base, until it's retargeted with av reparent.
+
+If stack.maxDepth is set in the config, creating a branch that would make the
+stack deeper than the limit is refused unless the --force flag is given.
+
+With --no-submit, the new branch (or the current branch if no branch name is
+given) is excluded from submit, e.g., a local integration or experiment branch.
+It's restacked as usual, but av pr and av sync don't push it, and the branches
+stacked on it are not submitted either. Use --submit to include the current
+branch again.
+
+With --issue, the new branch (or the current branch if no branch name is given)
+is linked to an issue: an issue key like PROJ-123 (e.g., Jira) or a GitHub issue
+like #123. The issue is prepended to the subjects of the commits created with av
+commit and to the pull request title, and referenced in the pull request body.
+A GitHub issue is referenced with a closing keyword ("Fixes #123"), so merging
+the pull request closes the issue. Use --issue="" to unlink the current branch.`),
+	Args:              cobra.RangeArgs(0, 2),
+	ValidArgsFunction: branchNameArgAt(1),
 	RunE: func(cmd *cobra.Command, args []string) (reterr error) {
-		if len(args) == 0 {
+		issueGiven := cmd.Flags().Changed("issue")
+		var issue string
+		if issueGiven && branchFlags.Issue != "" {
+			var err error
+			if issue, err = actions.ParseIssue(branchFlags.Issue); err != nil {
+				return err
+			}
+		}
+		if len(args) == 0 && !branchFlags.NoSubmit && !branchFlags.Submit && !issueGiven {
 			// The only time we don't want to suppress the usage message is when
 			// a user runs `av branch` with no arguments.
 			return cmd.Usage()
@@ -56,20 +115,132 @@ internal tracking metadata that defines the order of branches within a stack.`),
 		if err != nil {
 			return err
 		}
+		if len(args) == 0 {
+			if issueGiven {
+				if err := setCurrentBranchIssue(repo, db, issue); err != nil {
+					return err
+				}
+				if !branchFlags.NoSubmit && !branchFlags.Submit {
+					return nil
+				}
+			}
+			return setCurrentBranchNoSubmit(repo, db, branchFlags.NoSubmit)
+		}
+		if branchFlags.Submit {
+			return errors.New("--submit cannot be used with a branch name")
+		}
 
 		branchName := args[0]
+		// A description with spaces (but not the OLD_BRANCH:NEW_BRANCH form of --rename).
+		isDescription := strings.ContainsAny(strings.TrimSpace(branchName), " \t") &&
+			!(branchFlags.Rename && strings.Contains(branchName, ":"))
+		if !branchFlags.Exact && isDescription {
+			branchName = branchNameFromMessage(branchName)
+			if branchName == "" {
+				return errors.Errorf("cannot create a valid branch name from %q", args[0])
+			}
+		}
 		if branchFlags.Rename {
-			return branchMove(repo, db, branchName, branchFlags.Force)
+			return branchMove(repo, db, branchName, branchFlags.Force, branchFlags.Exact)
 		}
 
 		if len(args) == 2 {
 			branchFlags.Parent = args[1]
 		}
 
-		return createBranch(repo, db, branchName, branchFlags.Parent)
+		if branchFlags.Exact {
+			if !branchFlags.Force {
+				if err := checkBranchNamespace(branchName); err != nil {
+					return err
+				}
+			}
+		} else {
+			branchName = applyBranchNamespace(branchName)
+		}
+		if !branchFlags.Force {
+			if err := checkRemoteBranchCollision(repo, branchName); err != nil {
+				return err
+			}
+		}
+
+		if err := createBranch(repo, db, branchName, branchFlags.Parent, branchFlags.Force); err != nil {
+			return err
+		}
+		if issue != "" {
+			if err := setCurrentBranchIssue(repo, db, issue); err != nil {
+				return err
+			}
+		}
+		if branchFlags.NoSubmit {
+			return setCurrentBranchNoSubmit(repo, db, true)
+		}
+		return nil
 	},
 }
 
+// setCurrentBranchNoSubmit excludes the current branch from submit or includes it again.
+func setCurrentBranchNoSubmit(repo *git.Repo, db meta.DB, noSubmit bool) error {
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return err
+	}
+	tx := db.WriteTx()
+	defer tx.Abort()
+	br, ok := tx.Branch(currentBranch)
+	if !ok || br.Parent.Name == "" {
+		return errors.Errorf("branch %q is not managed by av", currentBranch)
+	}
+	br.NoSubmit = noSubmit
+	tx.SetBranch(br)
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if noSubmit {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Branch "), colors.UserInput(currentBranch),
+			colors.Success(" is excluded from submit.\n"),
+		)
+	} else {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Branch "), colors.UserInput(currentBranch),
+			colors.Success(" is included in submit.\n"),
+		)
+	}
+	return nil
+}
+
+// setCurrentBranchIssue links the current branch to the issue, or unlinks it if the issue is
+// empty.
+func setCurrentBranchIssue(repo *git.Repo, db meta.DB, issue string) error {
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return err
+	}
+	tx := db.WriteTx()
+	defer tx.Abort()
+	br, ok := tx.Branch(currentBranch)
+	if !ok || br.Parent.Name == "" {
+		return errors.Errorf("branch %q is not managed by av", currentBranch)
+	}
+	br.Issue = issue
+	tx.SetBranch(br)
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if issue != "" {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Branch "), colors.UserInput(currentBranch),
+			colors.Success(" is linked to issue "), colors.UserInput(issue), colors.Success(".\n"),
+		)
+	} else {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Branch "), colors.UserInput(currentBranch),
+			colors.Success(" is no longer linked to an issue.\n"),
+		)
+	}
+	return nil
+}
+
 func init() {
 	branchCmd.Flags().
 		StringVar(&branchFlags.Parent, "parent", "", "the parent branch to base the new branch off of")
@@ -78,15 +249,18 @@ func init() {
 	branchCmd.Flags().
 		BoolVarP(&branchFlags.Rename, "rename", "m", false, "rename the current branch")
 	branchCmd.Flags().
-		BoolVar(&branchFlags.Force, "force", false, "force rename the current branch, even if a pull request exists")
+		BoolVar(&branchFlags.Force, "force", false, "force rename the current branch, even if a pull request exists or it is outside of the branch namespace, or create a branch beyond the stack depth limit or outside the branch naming pattern")
+	branchCmd.Flags().
+		BoolVar(&branchFlags.Exact, "exact", false, "use the branch name as it is given, without the branch name template and prefix")
+	branchCmd.Flags().
+		BoolVar(&branchFlags.NoSubmit, "no-submit", false, "exclude the new branch (or the current branch) from submit")
+	branchCmd.Flags().
+		BoolVar(&branchFlags.Submit, "submit", false, "include the current branch in submit again")
+	branchCmd.Flags().
+		StringVar(&branchFlags.Issue, "issue", "", "link the new branch (or the current branch) to an issue\n(e.g., PROJ-123 or #123)")
+	branchCmd.MarkFlagsMutuallyExclusive("no-submit", "submit")
 
-	_ = branchCmd.RegisterFlagCompletionFunc(
-		"parent",
-		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-			branches, _ := allBranches()
-			return branches, cobra.ShellCompDirectiveNoSpace
-		},
-	)
+	_ = branchCmd.RegisterFlagCompletionFunc("parent", branchNameArgs)
 }
 
 func createBranch(
@@ -94,7 +268,16 @@ func createBranch(
 	db meta.DB,
 	branchName string,
 	parentBranchName string,
+	force bool,
 ) (reterr error) {
+	if !force {
+		if err := actions.CheckBranchNamePattern(
+			config.Av.PullRequest.BranchNamePattern, branchName,
+		); err != nil {
+			return err
+		}
+	}
+
 	// Determine important contextual information from Git
 	// or if a parent branch is provided, check it allows as a default branch
 	defaultBranch, err := repo.DefaultBranch()
@@ -102,27 +285,43 @@ func createBranch(
 		return errors.WrapIf(err, "failed to determine repository default branch")
 	}
 
-	tx := db.WriteTx()
-	cu := cleanup.New(func() {
-		logrus.WithError(reterr).Debug("aborting db transaction")
-		tx.Abort()
-	})
+	// The write transaction is started after the pre-branch-create hooks, which may run av
+	// themselves.
+	tx := db.ReadTx()
+	var cu cleanup.Cleanup
 	defer cu.Cleanup()
 
 	// Determine the parent branch and make sure it's checked out
+	var startAtHEAD bool
 	if parentBranchName == "" {
 		var err error
 		parentBranchName, err = repo.CurrentBranchName()
-		if err != nil {
+		if errors.Is(err, git.ErrDetachedHEAD) {
+			// Create the branch at the detached HEAD, stacked on the closest branch that HEAD
+			// is based on.
+			parent, ok := nearestAncestorBranch(repo, tx)
+			if !ok {
+				return errors.WrapIf(err, "failed to find a branch that HEAD is based on")
+			}
+			parentBranchName = parent.Name
+			startAtHEAD = true
+		} else if err != nil {
 			return errors.WrapIff(err, "failed to get current branch name")
 		}
 	}
 
-	remoteName := repo.GetRemoteName()
-	if parentBranchName == remoteName+"/HEAD" {
+	if parentBranchName == repo.GetRemoteName()+"/HEAD" ||
+		parentBranchName == repo.RemoteBranchRef("HEAD") {
+		parentBranchName = defaultBranch
+	}
+	parentBranchName = stripRemoteRefPrefixes(repo, parentBranchName)
+
+	// A tag or a commit as the parent is treated as the trunk frozen at the commit.
+	var frozenBase, baseRef string
+	if commit, ok := resolveImmutableParent(repo, parentBranchName); ok {
+		frozenBase, baseRef = commit, parentBranchName
 		parentBranchName = defaultBranch
 	}
-	parentBranchName = strings.TrimPrefix(parentBranchName, remoteName+"/")
 
 	isBranchFromTrunk, err := repo.IsTrunkBranch(parentBranchName)
 	if err != nil {
@@ -132,7 +331,7 @@ func createBranch(
 	var parentHead string
 	if isBranchFromTrunk {
 		// If the parent is trunk, start from the remote tracking branch.
-		checkoutStartingPoint = remoteName + "/" + defaultBranch
+		checkoutStartingPoint = repo.RemoteBranchRef(parentBranchName)
 		// If the parent is the trunk, we don't log the parent branch's head
 		parentHead = ""
 	} else {
@@ -150,6 +349,15 @@ func createBranch(
 			return errParentNotAdopted
 		}
 	}
+	if !force {
+		if err := actions.CheckStackDepth(tx, parentBranchName, 1); err != nil {
+			return err
+		}
+	}
+	hookEnv := hooks.Env{Branch: branchName, Parent: parentBranchName}
+	if err := hooks.Run(repo, hooks.PreBranchCreate, hookEnv); err != nil {
+		return err
+	}
 
 	// Resolve to a commit hash for the starting point.
 	//
@@ -159,13 +367,22 @@ func createBranch(
 	// people want to set it to none. etc. etc.
 	//
 	// For this new ref creation specifically, git automatically guesses what to set for
-	// branch.<name>.merge. For now, by using a commit hash, we can suppress all of
-	// those behaviors. Later maybe we can add an av-cli config to control what to set
-	// for branch.<name>.merge at what timing.
+	// branch.<name>.merge. By default, by using a commit hash, we suppress all of those
+	// behaviors. The upstreamTracking config controls whether and when we set
+	// branch.<name>.merge explicitly instead, or lets git guess it with "git".
+	if startAtHEAD {
+		checkoutStartingPoint = "HEAD"
+	} else if frozenBase != "" {
+		checkoutStartingPoint = frozenBase
+	}
 	startPointCommitHash, err := repo.RevParse(&git.RevParse{Rev: checkoutStartingPoint})
 	if err != nil {
 		return errors.WrapIf(err, "failed to determine commit hash of starting point")
 	}
+	newHeadRef := startPointCommitHash
+	if config.Av.UpstreamTracking == config.UpstreamTrackingGit {
+		newHeadRef = checkoutStartingPoint
+	}
 
 	// Create a new branch off of the parent
 	logrus.WithFields(logrus.Fields{
@@ -175,7 +392,7 @@ func createBranch(
 	if _, err := repo.CheckoutBranch(&git.CheckoutBranch{
 		Name:       branchName,
 		NewBranch:  true,
-		NewHeadRef: startPointCommitHash,
+		NewHeadRef: newHeadRef,
 	}); err != nil {
 		return errors.WrapIff(err, "checkout error")
 	}
@@ -199,19 +416,31 @@ func createBranch(
 		}
 	})
 
-	tx.SetBranch(meta.Branch{
+	if err := actions.ConfigureNewBranch(repo, branchName); err != nil {
+		return err
+	}
+
+	wtx := db.WriteTx()
+	cu.Add(func() {
+		logrus.WithError(reterr).Debug("aborting db transaction")
+		wtx.Abort()
+	})
+	wtx.SetBranch(meta.Branch{
 		Name: branchName,
 		Parent: meta.BranchState{
 			Name:  parentBranchName,
 			Trunk: isBranchFromTrunk,
 			Head:  parentHead,
 		},
+		FrozenBase: frozenBase,
+		BaseRef:    baseRef,
 	})
 
 	cu.Cancel()
-	if err := tx.Commit(); err != nil {
+	if err := wtx.Commit(); err != nil {
 		return err
 	}
+	runPostHook(repo, hooks.PostBranchCreate, hookEnv)
 	return nil
 }
 
@@ -220,6 +449,7 @@ func branchMove(
 	db meta.DB,
 	newBranch string,
 	force bool,
+	exact bool,
 ) (reterr error) {
 	c := strings.Count(newBranch, ":")
 	if c > 1 {
@@ -244,6 +474,20 @@ func branchMove(
 	})
 	defer cu.Cleanup()
 
+	if !exact {
+		newBranch = applyBranchNamespace(newBranch)
+	}
+	if !force {
+		if err := checkBranchNamespace(oldBranch); err != nil {
+			return err
+		}
+		if err := actions.CheckBranchNamePattern(
+			config.Av.PullRequest.BranchNamePattern, newBranch,
+		); err != nil {
+			return err
+		}
+	}
+
 	if oldBranch == newBranch {
 		return errors.Errorf("cannot rename branch to itself")
 	}
@@ -315,3 +559,49 @@ func branchMove(
 	}
 	return nil
 }
+
+// applyBranchNamespace prepends the personal branch namespace to the branch name if the
+// namespace is enforced and the name doesn't have it yet.
+func applyBranchNamespace(branchName string) string {
+	prefix := config.Av.PullRequest.BranchNamePrefix
+	if !config.Av.PullRequest.EnforceBranchNamePrefix || prefix == "" ||
+		strings.HasPrefix(branchName, prefix) {
+		return branchName
+	}
+	return prefix + branchName
+}
+
+// checkBranchNamespace returns an error if the namespace is enforced and the branch is outside
+// of it.
+func checkBranchNamespace(branchName string) error {
+	prefix := config.Av.PullRequest.BranchNamePrefix
+	if !config.Av.PullRequest.EnforceBranchNamePrefix || prefix == "" ||
+		strings.HasPrefix(branchName, prefix) {
+		return nil
+	}
+	return errors.Errorf(
+		"branch %q is outside of your branch namespace %q (use --force to modify it anyway)",
+		branchName, prefix,
+	)
+}
+
+// checkRemoteBranchCollision returns an error if the namespace is enforced and the branch
+// already exists on the remote (e.g., someone else has created a branch with the same name).
+func checkRemoteBranchCollision(repo *git.Repo, branchName string) error {
+	if !config.Av.PullRequest.EnforceBranchNamePrefix {
+		return nil
+	}
+	remote := config.Av.BranchPushRemote
+	if remote == "" {
+		remote = repo.GetBranchPushRemoteName(branchName)
+	}
+	remoteBranch := git.RemoteTrackingRef(remote, branchName)
+	if _, err := repo.RevParse(&git.RevParse{Rev: remoteBranch}); err != nil {
+		// The branch doesn't exist on the remote.
+		return nil
+	}
+	return errors.Errorf(
+		"branch %q already exists on the remote (use --force to create it anyway)",
+		branchName,
+	)
+}
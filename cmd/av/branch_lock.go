@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/meta/metasync"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var branchLockFlags struct {
+	Force bool
+}
+
+var branchLockCmd = &cobra.Command{
+	Use:   "lock [<branch>]",
+	Short: "Lock a branch while you are editing it",
+	Long: strings.TrimSpace(`
+Lock a branch while you are editing it (e.g., in a pair or mob session on a shared
+stack). The other users are warned before av restack, av sync, and av pr rebase
+or push the locked branch. Use av branch unlock to release the lock.
+
+The lock is stored in the av metadata of the branch. It's shared with the other
+clones of the repository only if syncMetadata is enabled in the config, in which
+case the metadata is pushed right away, and the locks of the other users are
+pulled from their av metadata.
+
+If the branch is not specified, the current branch is locked. A branch locked by
+another user can't be locked unless the --force flag is given.
+`),
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: branchNameArgAt(0),
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setBranchLock(args, true)
+	},
+}
+
+var branchUnlockCmd = &cobra.Command{
+	Use:   "unlock [<branch>]",
+	Short: "Unlock a branch locked by av branch lock",
+	Long: strings.TrimSpace(`
+Unlock a branch locked by av branch lock.
+
+If the branch is not specified, the current branch is unlocked. A branch locked by
+another user can't be unlocked unless the --force flag is given (e.g., when they
+forgot to unlock it).
+`),
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: branchNameArgAt(0),
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setBranchLock(args, false)
+	},
+}
+
+func setBranchLock(args []string, lock bool) error {
+	repo, err := getRepo()
+	if err != nil {
+		return err
+	}
+	db, err := getDB(repo)
+	if err != nil {
+		return err
+	}
+	var branchName string
+	if len(args) > 0 {
+		branchName = args[0]
+	} else {
+		branchName, err = repo.CurrentBranchName()
+		if err != nil {
+			return errors.WrapIf(err, "failed to determine current branch")
+		}
+	}
+	owner, err := repo.CommitterEmail()
+	if err != nil {
+		return err
+	}
+	if config.Av.SyncMetadata {
+		// See the latest locks of the other users, which are taken from their metadata refs.
+		pullSyncedMetadata(repo, db)
+	}
+	if err := updateBranchLock(db, branchName, owner, lock); err != nil {
+		return err
+	}
+
+	if lock {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Locked branch "), colors.UserInput(branchName), colors.Success(".\n"),
+		)
+	} else {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Unlocked branch "), colors.UserInput(branchName), colors.Success(".\n"),
+		)
+	}
+	if !config.Av.SyncMetadata {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("  - the lock is not shared since syncMetadata is disabled in the config\n"),
+		)
+		return nil
+	}
+	if err := metasync.Push(repo, db); err != nil {
+		return errors.WrapIf(err, "failed to share the lock")
+	}
+	return nil
+}
+
+func updateBranchLock(db meta.DB, branchName string, owner string, lock bool) error {
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+
+	branch, ok := tx.Branch(branchName)
+	if !ok {
+		return actions.BranchNotAdoptedError{Branch: branchName}
+	}
+	if branch.Lock != nil && !strings.EqualFold(branch.Lock.Owner, owner) &&
+		!branchLockFlags.Force {
+		return errors.Errorf(
+			"branch %q is locked by %s (use --force to take over the lock)",
+			branchName, branch.Lock.Owner,
+		)
+	}
+	if lock {
+		branch.Lock = &meta.BranchLock{
+			Owner:    owner,
+			LockedAt: time.Now().UTC().Truncate(time.Second),
+		}
+	} else {
+		if branch.Lock == nil {
+			return errors.Errorf("branch %q is not locked", branchName)
+		}
+		branch.Lock = nil
+	}
+	tx.SetBranch(branch)
+	cu.Cancel()
+	return tx.Commit()
+}
+
+// lockedBranchesToCheck returns the branches that an operation on the current stack (or all
+// the branches if all is true) rebases or pushes.
+func lockedBranchesToCheck(repo *git.Repo, tx meta.ReadTx, all bool) []string {
+	if all {
+		var ret []string
+		for name := range tx.AllBranches() {
+			ret = append(ret, name)
+		}
+		return ret
+	}
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return nil
+	}
+	branches, _ := meta.StackBranches(tx, currentBranch)
+	return branches
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{branchLockCmd, branchUnlockCmd} {
+		cmd.Flags().BoolVar(
+			&branchLockFlags.Force, "force", false,
+			"take over the lock of another user",
+		)
+	}
+	branchCmd.AddCommand(branchLockCmd, branchUnlockCmd)
+}
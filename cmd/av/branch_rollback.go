@@ -0,0 +1,52 @@
+package main
+
+import (
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/sirupsen/logrus"
+)
+
+// branchRollback records the branches that a command creates so that they are deleted if the
+// command fails partway through (e.g., a patch doesn't apply), leaving the repository as it was.
+type branchRollback struct {
+	repo *git.Repo
+	// The branch checked out before the command, or the commit if HEAD was detached.
+	orig    string
+	created []string
+}
+
+func newBranchRollback(repo *git.Repo) (*branchRollback, error) {
+	orig, err := repo.CurrentBranchName()
+	if errors.Is(err, git.ErrDetachedHEAD) {
+		orig, err = repo.RevParse(&git.RevParse{Rev: "HEAD"})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &branchRollback{repo: repo, orig: orig}, nil
+}
+
+// add records a branch that was created by the command.
+func (r *branchRollback) add(name string) {
+	r.created = append(r.created, name)
+}
+
+// rollback aborts any patch application in progress, checks out the original branch, and
+// deletes the created branches.
+func (r *branchRollback) rollback() {
+	if len(r.created) == 0 {
+		return
+	}
+	// This fails if no patch application is in progress.
+	_, _ = r.repo.Git("am", "--abort")
+	if _, err := r.repo.Git("reset", "--hard"); err != nil {
+		logrus.WithError(err).Error("failed to reset the working tree during cleanup")
+	}
+	if _, err := r.repo.Git("checkout", "--quiet", r.orig); err != nil {
+		logrus.WithError(err).Error("failed to return to the original branch during cleanup")
+		return
+	}
+	if err := r.repo.BranchDelete(r.created...); err != nil {
+		logrus.WithError(err).Error("failed to delete the created branches during cleanup")
+	}
+}
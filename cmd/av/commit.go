@@ -9,10 +9,12 @@ import (
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
 	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/editor"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/cleanup"
 	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
 	"github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -45,6 +47,11 @@ var commitFlags struct {
 	BranchName   string
 	AllChanges   bool
 	Parent       string
+	Force        bool
+	LocalOnly    bool
+	SplitByDir   bool
+	Signoff      bool
+	Fixup        bool
 }
 
 var commitCmd = &cobra.Command{
@@ -52,6 +59,57 @@ var commitCmd = &cobra.Command{
 	Short: "Record changes to the repository with commits",
 	Args:  cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) error {
+		if commitFlags.Fixup {
+			if commitFlags.Message != "" || commitFlags.Amend || commitFlags.CreateBranch ||
+				commitFlags.BranchName != "" || commitFlags.Parent != "" ||
+				commitFlags.LocalOnly || commitFlags.SplitByDir {
+				return errors.New(
+					"--fixup cannot be used with -m, --amend, -b, --branch-name, --parent, --local-only, or --split-by-dir",
+				)
+			}
+			repo, err := getRepo()
+			if err != nil {
+				return err
+			}
+			db, err := getDB(repo)
+			if err != nil {
+				return err
+			}
+			return runFixup(repo, db)
+		}
+		if commitFlags.LocalOnly {
+			if commitFlags.Message == "" {
+				return errors.New("--local-only requires a commit message (-m <message>)")
+			}
+			if config.Av.LocalOnlyCommitPrefix == "" {
+				return errors.New("--local-only requires localOnlyCommitPrefix to be configured")
+			}
+			commitFlags.Message = config.Av.LocalOnlyCommitPrefix + " " + commitFlags.Message
+		}
+		if config.Av.Commit.ProtectTrunk && !commitFlags.CreateBranch &&
+			commitFlags.BranchName == "" && !commitFlags.Force {
+			branchName, err := trunkCommitBranchName(commitFlags.Message)
+			if err != nil {
+				return err
+			}
+			commitFlags.BranchName = branchName
+		}
+		if commitFlags.BranchName != "" {
+			commitFlags.BranchName = applyBranchNamespace(commitFlags.BranchName)
+		} else if !commitFlags.CreateBranch && !commitFlags.Force {
+			// Committing to the current branch.
+			if err := checkCurrentBranchNamespace(); err != nil {
+				return err
+			}
+		}
+		if commitFlags.SplitByDir {
+			if commitFlags.Amend || commitFlags.CreateBranch || commitFlags.BranchName != "" ||
+				commitFlags.LocalOnly {
+				return errors.New(
+					"--split-by-dir cannot be used with --amend, -b, --branch-name, or --local-only",
+				)
+			}
+		}
 		if commitFlags.Amend {
 			if commitFlags.CreateBranch || commitFlags.BranchName != "" {
 				return errors.New("cannot create a branch and amend at the same time")
@@ -66,6 +124,7 @@ var commitCmd = &cobra.Command{
 				commitFlags.AllChanges,
 				commitFlags.All,
 				commitFlags.Parent,
+				commitFlags.Force,
 			)
 
 		}
@@ -88,7 +147,7 @@ var commitCmd = &cobra.Command{
 		// we need to make p.ReleaseTerminal() and p.RestoreTerminal().
 		if err := runCreate(repo, db); err != nil {
 			fmt.Fprint(os.Stderr, "\n", colors.Failure("Failed to create commit."), "\n")
-			return actions.ErrExitSilently{ExitCode: 1}
+			return actions.ErrExitSilently{ExitCode: 1, Err: err}
 		}
 
 		return runPostCommitRestack(repo, db)
@@ -115,14 +174,6 @@ func runCreate(repo *git.Repo, db meta.DB) error {
 		}
 	}
 
-	commitArgs := []string{"commit"}
-	if commitFlags.All {
-		commitArgs = append(commitArgs, "--all")
-	}
-	if commitFlags.Message != "" {
-		commitArgs = append(commitArgs, "--message", commitFlags.Message)
-	}
-
 	tx := db.WriteTx()
 	defer tx.Abort()
 
@@ -135,9 +186,38 @@ func runCreate(repo *git.Repo, db meta.DB) error {
 		)
 		return errors.New("this branch has already been merged, commit is not allowed")
 	}
+	if branch.IsPinned() {
+		return errors.Errorf(
+			"branch %q is pinned, commit is not allowed (run 'av unpin' first)", currentBranch,
+		)
+	}
+	if branch.IsFollowed() {
+		return errors.Errorf(
+			"branch %q is followed from the remote, commit is not allowed", currentBranch,
+		)
+	}
 
+	if commitFlags.SplitByDir {
+		return runSplitByDir(repo)
+	}
+
+	messageArgs, err := commitMessageArgs(
+		repo, currentBranch, branch.Parent.Name, branch.Issue, commitFlags.Message, true,
+		commitFlags.All,
+	)
+	if err != nil {
+		return err
+	}
+	commitArgs := []string{"commit"}
+	commitArgs = append(commitArgs, commitSignoffArgs(commitFlags.Signoff)...)
+	commitArgs = append(commitArgs, actions.CIHintCommitArgs(tx, currentBranch)...)
+	if commitFlags.All {
+		commitArgs = append(commitArgs, "--all")
+	}
+	commitArgs = append(commitArgs, messageArgs...)
 	if _, err := repo.Run(&git.RunOpts{
 		Args:        commitArgs,
+		Env:         trunkCommitEnv(),
 		ExitError:   true,
 		Interactive: true,
 	}); err != nil {
@@ -146,6 +226,242 @@ func runCreate(repo *git.Repo, db meta.DB) error {
 	return nil
 }
 
+// commitMessageArgs returns the git commit arguments for the message of a commit on the branch.
+// If useTemplate is true, the message is generated from the commit.messageTemplate config. The
+// issue linked to the branch (if any) is prepended to the subject; without -m, the editor starts
+// with it. The message is checked against the commit.messageRules config before the commit is
+// created: without -m, av opens the editor itself (instead of git commit) and reopens it until
+// the message follows the rules.
+func commitMessageArgs(
+	repo *git.Repo,
+	branch, parent, issue, message string,
+	useTemplate, all bool,
+) ([]string, error) {
+	given := message != ""
+	hasMessage, edit := given, false
+	if useTemplate && config.Av.Commit.MessageTemplate != "" {
+		var err error
+		message, err = commitMessageFromTemplate(repo, branch, parent, issue, message, all)
+		if err != nil {
+			return nil, err
+		}
+		hasMessage, edit = true, !given
+	}
+	// The local-only commits are not pushed, so they don't reference the issue.
+	if issue != "" && !commitFlags.LocalOnly {
+		message = actions.AddIssueToSubject(message, issue)
+		hasMessage, edit = true, edit || !given
+	}
+	// The local-only commits are not pushed, so they don't need to follow the rules.
+	if rules := config.Av.Commit.MessageRules; rules.IsEnabled() && !commitFlags.LocalOnly {
+		if !given {
+			var err error
+			message, err = editCommitMessage(repo, branch, parent, message)
+			if err != nil {
+				return nil, err
+			}
+			hasMessage, edit = true, false
+		} else if problems := actions.LintCommitMessage(message, rules); len(problems) > 0 {
+			printCommitMessageProblems(problems)
+			return nil, errors.New("the commit message doesn't follow the commit.messageRules config")
+		}
+	}
+
+	if !hasMessage || edit {
+		// Git opens the editor for the message.
+		if err := uiutils.RequireInteractive("writing the commit message", "use -m"); err != nil {
+			return nil, err
+		}
+	}
+	var args []string
+	if hasMessage {
+		args = append(args, "--message", message)
+	}
+	if edit {
+		args = append(args, "--edit")
+	}
+	return args, nil
+}
+
+// editCommitMessage opens the editor with the message and the stack context, and returns the
+// edited message once it follows the commit.messageRules config. Without a terminal, a message
+// that breaks the rules is an error instead of reopening the editor.
+func editCommitMessage(repo *git.Repo, branch, parent, message string) (string, error) {
+	rules := config.Av.Commit.MessageRules
+	var problems []string
+	for {
+		var sb strings.Builder
+		sb.WriteString(message)
+		sb.WriteString("\n\n")
+		if parent != "" {
+			fmt.Fprintf(&sb, "# Enter the commit message for branch %s (on %s).\n", branch, parent)
+		} else {
+			fmt.Fprintf(&sb, "# Enter the commit message for branch %s.\n", branch)
+		}
+		if ticket := actions.TicketFromBranchName(branch); ticket != "" {
+			fmt.Fprintf(&sb, "# Ticket: %s\n", ticket)
+		}
+		for _, line := range actions.CommitMessageRulesHelp(rules) {
+			fmt.Fprintf(&sb, "# %s\n", line)
+		}
+		if len(problems) > 0 {
+			sb.WriteString("#\n# The last message was rejected:\n")
+			for _, problem := range problems {
+				fmt.Fprintf(&sb, "#   - %s\n", problem)
+			}
+		}
+		sb.WriteString("#\n# Lines starting with '#' are ignored, and an empty message aborts the commit.\n")
+
+		res, err := editor.Launch(repo, editor.Config{
+			Text:           sb.String(),
+			TmpFilePattern: "av-commit-*",
+			CommentPrefix:  "#",
+		})
+		if err != nil {
+			return "", errors.WrapIf(err, "failed to edit the commit message")
+		}
+		var lines []string
+		// The editor command ":" returns the text with the comments as it is.
+		for _, line := range strings.Split(res, "\n") {
+			if !strings.HasPrefix(line, "#") {
+				lines = append(lines, strings.TrimRight(line, " \t"))
+			}
+		}
+		message = strings.TrimSpace(strings.Join(lines, "\n"))
+		if message == "" {
+			fmt.Fprint(os.Stderr, colors.Failure("Aborting the commit due to the empty commit message."), "\n")
+			return "", errors.New("empty commit message")
+		}
+		problems = actions.LintCommitMessage(message, rules)
+		if len(problems) == 0 {
+			return message, nil
+		}
+		printCommitMessageProblems(problems)
+		if !uiutils.IsInteractive() {
+			return "", errors.New("the commit message doesn't follow the commit.messageRules config")
+		}
+	}
+}
+
+func printCommitMessageProblems(problems []string) {
+	fmt.Fprint(os.Stderr, colors.Failure("The commit message doesn't follow the rules (commit.messageRules):"), "\n")
+	for _, problem := range problems {
+		fmt.Fprint(os.Stderr, "  - ", problem, "\n")
+	}
+}
+
+// commitMessageFromTemplate fills the commit.messageTemplate config with the files to be
+// committed (the staged files, or all the changed tracked files with --all) and the branch.
+func commitMessageFromTemplate(
+	repo *git.Repo,
+	branch, parent, issue, message string,
+	all bool,
+) (string, error) {
+	args := []string{"diff", "--cached", "--name-only", "--no-renames", "-z"}
+	if all {
+		args = []string{"diff", "HEAD", "--name-only", "--no-renames", "-z"}
+	}
+	out, err := repo.Run(&git.RunOpts{Args: args, ExitError: true})
+	if err != nil {
+		return "", errors.WrapIf(err, "failed to list the changed files")
+	}
+	var files []string
+	for _, file := range strings.Split(string(out.Stdout), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	data := actions.NewCommitTemplateData(files, config.Av.Commit.PathGroups, message)
+	data.Branch = branch
+	data.Parent = parent
+	data.Ticket = issue
+	if data.Ticket == "" {
+		data.Ticket = actions.TicketFromBranchName(branch)
+	}
+	return actions.CommitMessageFromTemplate(config.Av.Commit.MessageTemplate, data), nil
+}
+
+// runSplitByDir commits the staged changes in one commit per path group (see
+// actions.GroupChangedPaths). The subject of each commit is prefixed with the group name. If a
+// commit fails, the changes that are not committed yet are left staged.
+func runSplitByDir(repo *git.Repo) error {
+	if commitFlags.All {
+		if _, err := repo.Run(&git.RunOpts{
+			Args:      []string{"add", "--update"},
+			ExitError: true,
+		}); err != nil {
+			return errors.WrapIf(err, "failed to stage files")
+		}
+	}
+	out, err := repo.Run(&git.RunOpts{
+		Args:      []string{"diff", "--cached", "--name-only", "--no-renames", "-z"},
+		ExitError: true,
+	})
+	if err != nil {
+		return errors.WrapIf(err, "failed to list the staged files")
+	}
+	var files []string
+	for _, file := range strings.Split(string(out.Stdout), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return errors.New("no changes staged for commit")
+	}
+	groups := actions.GroupChangedPaths(files, config.Av.Commit.PathGroups)
+
+	// Save the staged state as a tree, unstage everything, and then stage the files of each
+	// group from the tree one by one.
+	stagedTree, err := repo.Git("write-tree")
+	if err != nil {
+		return errors.WrapIf(err, "failed to save the staged changes")
+	}
+	// The file paths are given as they are, not as pathspecs.
+	env := append([]string{"GIT_LITERAL_PATHSPECS=1"}, trunkCommitEnv()...)
+	stage := func(source string, paths []string) error {
+		_, err := repo.Run(&git.RunOpts{
+			Args:      append([]string{"restore", "--staged", "--source=" + source, "--"}, paths...),
+			Env:       env,
+			ExitError: true,
+		})
+		return err
+	}
+	if err := stage("HEAD", files); err != nil {
+		return errors.WrapIf(err, "failed to unstage the changes")
+	}
+	for i, group := range groups {
+		if err := stage(stagedTree, group.Paths); err != nil {
+			return errors.WrapIff(err, "failed to stage the changes of %q", group.Name)
+		}
+		commitArgs := []string{"commit", "--message", group.Name + ": " + commitFlags.Message}
+		commitArgs = append(commitArgs, commitSignoffArgs(commitFlags.Signoff)...)
+		if commitFlags.Message == "" {
+			commitArgs = append(commitArgs, "--edit")
+		}
+		if _, err := repo.Run(&git.RunOpts{
+			Args:        commitArgs,
+			Env:         env,
+			ExitError:   true,
+			Interactive: true,
+		}); err != nil {
+			var rest []string
+			for _, g := range groups[i:] {
+				rest = append(rest, g.Paths...)
+			}
+			if err := stage(stagedTree, rest); err != nil {
+				logrus.WithError(err).Error("failed to restage the uncommitted changes")
+			}
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Committed "), colors.UserInput(len(group.Paths)),
+			colors.Success(" file(s) of "), colors.UserInput(group.Name), "\n",
+		)
+	}
+	return nil
+}
+
 func amendCmd(message string, edit bool, all bool) error {
 	repo, err := getRepo()
 	if err != nil {
@@ -161,7 +477,7 @@ func amendCmd(message string, edit bool, all bool) error {
 	// we need to make p.ReleaseTerminal() and p.RestoreTerminal().
 	if err := runAmend(repo, db, message, edit, all); err != nil {
 		fmt.Fprint(os.Stderr, "\n", colors.Failure("Failed to amend."), "\n")
-		return actions.ErrExitSilently{ExitCode: 1}
+		return actions.ErrExitSilently{ExitCode: 1, Err: err}
 	}
 
 	return runPostCommitRestack(repo, db)
@@ -173,17 +489,6 @@ func runAmend(repo *git.Repo, db meta.DB, message string, edit bool, all bool) e
 		return errors.WrapIf(err, "failed to determine current branch")
 	}
 
-	commitArgs := []string{"commit", "--amend"}
-	if !edit && message == "" {
-		commitArgs = append(commitArgs, "--no-edit")
-	}
-	if all {
-		commitArgs = append(commitArgs, "--all")
-	}
-	if message != "" {
-		commitArgs = append(commitArgs, "--message", message)
-	}
-
 	tx := db.WriteTx()
 	defer tx.Abort()
 
@@ -196,6 +501,52 @@ func runAmend(repo *git.Repo, db meta.DB, message string, edit bool, all bool) e
 		)
 		return errors.New("this branch has already been merged, amending is not allowed")
 	}
+	if branch.IsPinned() {
+		return errors.Errorf(
+			"branch %q is pinned, amending is not allowed (run 'av unpin' first)", currentBranch,
+		)
+	}
+	if branch.IsFollowed() {
+		return errors.Errorf(
+			"branch %q is followed from the remote, amending is not allowed", currentBranch,
+		)
+	}
+
+	if rules := config.Av.Commit.MessageRules; rules.IsEnabled() && !commitFlags.LocalOnly {
+		if message != "" {
+			if problems := actions.LintCommitMessage(message, rules); len(problems) > 0 {
+				printCommitMessageProblems(problems)
+				return errors.New("the commit message doesn't follow the commit.messageRules config")
+			}
+		} else if edit {
+			// Edit the message here so that it's checked before the commit is amended.
+			current, err := repo.Git("log", "-1", "--format=%B")
+			if err != nil {
+				return err
+			}
+			message, err = editCommitMessage(repo, currentBranch, branch.Parent.Name, current)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if edit && message == "" {
+		if err := uiutils.RequireInteractive("editing the commit message", "use -m"); err != nil {
+			return err
+		}
+	}
+	commitArgs := []string{"commit", "--amend"}
+	commitArgs = append(commitArgs, commitSignoffArgs(commitFlags.Signoff)...)
+	if !edit && message == "" {
+		commitArgs = append(commitArgs, "--no-edit")
+	}
+	if all {
+		commitArgs = append(commitArgs, "--all")
+	}
+	if message != "" {
+		commitArgs = append(commitArgs, "--message", message)
+	}
 
 	// Handle "--all-changes"
 	if commitFlags.AllChanges {
@@ -213,6 +564,7 @@ func runAmend(repo *git.Repo, db meta.DB, message string, edit bool, all bool) e
 
 	if _, err := repo.Run(&git.RunOpts{
 		Args:        commitArgs,
+		Env:         trunkCommitEnv(),
 		ExitError:   true,
 		Interactive: true,
 	}); err != nil {
@@ -227,6 +579,7 @@ func branchAndCommit(
 	all bool,
 	allModified bool,
 	parentBranchName string,
+	force bool,
 ) (reterr error) {
 	if branchName == "" {
 		if message == "" {
@@ -240,6 +593,15 @@ func branchAndCommit(
 		}
 	}
 
+	// Check the message before creating the branch.
+	if rules := config.Av.Commit.MessageRules; rules.IsEnabled() && message != "" &&
+		!commitFlags.LocalOnly {
+		if problems := actions.LintCommitMessage(message, rules); len(problems) > 0 {
+			printCommitMessageProblems(problems)
+			return errors.New("the commit message doesn't follow the commit.messageRules config")
+		}
+	}
+
 	repo, err := getRepo()
 	if err != nil {
 		return err
@@ -250,7 +612,7 @@ func branchAndCommit(
 		return err
 	}
 
-	err = createBranch(repo, db, branchName, parentBranchName)
+	err = createBranch(repo, db, branchName, parentBranchName, force)
 	if err != nil {
 		return err
 	}
@@ -285,10 +647,17 @@ func branchAndCommit(
 		}
 	}
 
-	commitArgs := []string{"commit"}
-	if message != "" {
-		commitArgs = append(commitArgs, "--message", message)
+	branch, _ := tx.Branch(branchName)
+	messageArgs, err := commitMessageArgs(
+		repo, branchName, branch.Parent.Name, branch.Issue, message, false, false,
+	)
+	if err != nil {
+		return err
 	}
+	commitArgs := []string{"commit"}
+	commitArgs = append(commitArgs, commitSignoffArgs(commitFlags.Signoff)...)
+	commitArgs = append(commitArgs, actions.CIHintCommitArgs(tx, branchName)...)
+	commitArgs = append(commitArgs, messageArgs...)
 
 	if _, err := repo.Run(&git.RunOpts{
 		Args:        commitArgs,
@@ -311,18 +680,71 @@ func branchAndCommit(
 	return nil
 }
 
+func checkCurrentBranchNamespace() error {
+	repo, err := getRepo()
+	if err != nil {
+		return err
+	}
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return errors.WrapIf(err, "failed to determine current branch")
+	}
+	if isTrunk, err := repo.IsTrunkBranch(currentBranch); err != nil {
+		return err
+	} else if isTrunk {
+		return nil
+	}
+	return checkBranchNamespace(currentBranch)
+}
+
+// branchNameFromMessage generates a branch name from a commit message or a free-form
+// description of the branch, with pullRequest.branchNameTemplate if it's configured.
 func branchNameFromMessage(message string) string {
-	name := branchNameReplacedPattern.ReplaceAllLiteralString(message, " ")
+	template := config.Av.PullRequest.BranchNameTemplate
+	if template == "" {
+		name := branchNameSlug(message)
+		if name != "" && config.Av.PullRequest.BranchNamePrefix != "" {
+			name = fmt.Sprintf("%s%s", config.Av.PullRequest.BranchNamePrefix, name)
+		}
+		return name
+	}
+	ticket, rest := actions.SplitTicket(message)
+	slug := branchNameSlug(rest)
+	if ticket == "" && slug == "" {
+		return ""
+	}
+	return actions.BranchNameFromTemplate(template, actions.BranchNameData{
+		User:   branchNameUser(),
+		Ticket: ticket,
+		Slug:   slug,
+	})
+}
+
+// branchNameSlug converts the text to a lower kebab case string that can be used in a branch
+// name (e.g., "Fix login race" to "fix-login-race").
+func branchNameSlug(text string) string {
+	name := branchNameReplacedPattern.ReplaceAllLiteralString(text, " ")
 	name = strings.TrimSpace(name)
 	name = multipleSpacePattern.ReplaceAllLiteralString(name, "-")
 	if len(name) > branchNameLength {
 		name = name[:branchNameLength]
 	}
-	name = strings.ToLower(name)
-	if config.Av.PullRequest.BranchNamePrefix != "" {
-		name = fmt.Sprintf("%s%s", config.Av.PullRequest.BranchNamePrefix, name)
+	return strings.ToLower(name)
+}
+
+// branchNameUser returns the user name for the {user} placeholder of the branch name template,
+// which is the local part of Git user.email.
+func branchNameUser() string {
+	repo, err := getRepo()
+	if err != nil {
+		return ""
+	}
+	email, err := repo.Git("config", "user.email")
+	if err != nil {
+		return ""
 	}
-	return name
+	user, _, _ := strings.Cut(email, "@")
+	return branchNameSlug(user)
 }
 
 func init() {
@@ -343,14 +765,22 @@ func init() {
 		StringVar(&commitFlags.BranchName, "branch-name", "", "create a new branch with the given name and commit to it")
 	commitCmd.Flags().
 		StringVar(&commitFlags.Parent, "parent", "", "the parent branch to base the new branch off of")
+	commitCmd.Flags().
+		BoolVar(&commitFlags.LocalOnly, "local-only", false, "mark the commit as local-only so that it's not pushed to the remote")
+	commitCmd.Flags().
+		BoolVar(&commitFlags.Force, "force", false, "commit to the current branch even if it is outside of the branch namespace or a protected trunk branch, or create a branch beyond the stack depth limit")
+	_ = commitCmd.RegisterFlagCompletionFunc("parent", branchNameArgs)
 
-	_ = branchCmd.RegisterFlagCompletionFunc(
-		"parent",
-		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-			branches, _ := allBranches()
-			return branches, cobra.ShellCompDirectiveNoFileComp
-		},
-	)
+	commitCmd.Flags().
+		BoolVar(&commitFlags.SplitByDir, "split-by-dir", false,
+			"commit the staged changes in one commit per path group (top-level directory by default)")
+	commitCmd.Flags().
+		BoolVarP(&commitFlags.Signoff, "signoff", "s", false,
+			"add a Signed-off-by trailer to the commit and to the commits of the restacked child branches")
+
+	commitCmd.Flags().
+		BoolVar(&commitFlags.Fixup, "fixup", false,
+			"fold the staged changes into the commits of the current branch that last changed the same lines")
 
 	commitCmd.MarkFlagsMutuallyExclusive("all", "all-changes")
 
@@ -370,6 +800,7 @@ func init() {
 		BoolVarP(&commitAmendFlags.All, "all", "a", false, "automatically stage modified files (same as git commit --all)")
 
 	commitCmd.AddCommand(
+		commitCheckTrunkCmd,
 		deprecatedAmendCmd,
 		deprecatedCreateCmd,
 		deprecatedSplitCmd,
@@ -5,6 +5,7 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/sequencer"
@@ -18,6 +19,15 @@ import (
 
 var nothingToRestackError = errors.Sentinel("nothing to restack")
 
+// commitSignoffArgs returns the git commit arguments to add a Signed-off-by trailer if the
+// commits that av creates are signed off (commit.signoff or the --signoff flag).
+func commitSignoffArgs(flag bool) []string {
+	if flag || config.Av.Commit.Signoff {
+		return []string{"--signoff"}
+	}
+	return nil
+}
+
 func runPostCommitRestack(repo *git.Repo, db meta.DB) error {
 	return uiutils.RunBubbleTea(&postCommitRestackViewModel{repo: repo, db: db})
 }
@@ -60,6 +70,11 @@ func (vm *postCommitRestackViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return vm, tea.Quit
 	case tea.KeyMsg:
+		if vm.restackModel != nil && vm.restackModel.IsPrompting() {
+			var cmd tea.Cmd
+			vm.restackModel, cmd = vm.restackModel.Update(msg)
+			return vm, cmd
+		}
 		switch msg.String() {
 		case "ctrl+c":
 			return vm, tea.Quit
@@ -87,10 +102,10 @@ func (vm *postCommitRestackViewModel) ExitError() error {
 		return nil
 	}
 	if vm.err != nil {
-		return actions.ErrExitSilently{ExitCode: 1}
+		return actions.ErrExitSilently{ExitCode: 1, Err: vm.err}
 	}
 	if vm.quitWithConflict {
-		return actions.ErrExitSilently{ExitCode: 1}
+		return actions.ErrExitConflict
 	}
 	return nil
 }
@@ -107,8 +122,15 @@ func (vm *postCommitRestackViewModel) createState() (*sequencerui.RestackState,
 	if err != nil {
 		return nil, err
 	}
+	if isTrunk, err := vm.repo.IsTrunkBranch(currentBranch); err != nil {
+		return nil, err
+	} else if isTrunk {
+		// The stacks on the trunk are not restacked after a commit on the trunk (e.g.,
+		// av commit --force); av sync --rebase-to-trunk does that.
+		return nil, nothingToRestackError
+	}
 	if _, exist := vm.db.ReadTx().Branch(currentBranch); !exist {
-		return nil, errors.New("current branch is not adopted to av")
+		return nil, actions.BranchNotAdoptedError{Branch: currentBranch}
 	}
 	var state sequencerui.RestackState
 	state.InitialBranch = currentBranch
@@ -125,5 +147,6 @@ func (vm *postCommitRestackViewModel) createState() (*sequencerui.RestackState,
 		return nil, nothingToRestackError
 	}
 	state.Seq = sequencer.NewSequencer(vm.repo.GetRemoteName(), vm.db, ops)
+	state.Seq.Signoff = state.Seq.Signoff || commitFlags.Signoff
 	return &state, nil
 }
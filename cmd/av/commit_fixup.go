@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+)
+
+// fixupFile is a staged file for av commit --fixup.
+type fixupFile struct {
+	path string
+	// The file mode and the content at HEAD.
+	mode    string
+	content string
+	hunks   []actions.Hunk
+	// The commit that each hunk is folded into, or an empty string if it doesn't belong to any
+	// commit of the branch.
+	targets []string
+}
+
+// runFixup creates a fixup commit for each commit of the current branch that the staged changes
+// belong to (see actions.FixupTarget), folds them into the commits with git rebase --autosquash,
+// and restacks the children. The changes that don't belong to any commit of the branch are left
+// staged.
+func runFixup(repo *git.Repo, db meta.DB) error {
+	if err := checkNoOtherOperationInProgress(repo, git.StateFileKindRestack); err != nil {
+		return err
+	}
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return errors.WrapIf(err, "failed to determine current branch")
+	}
+	tx := db.ReadTx()
+	branch, exists := tx.Branch(currentBranch)
+	if !exists {
+		return actions.BranchNotAdoptedError{Branch: currentBranch}
+	}
+	if branch.PullRequest != nil && branch.PullRequest.State == githubv4.PullRequestStateMerged {
+		return errors.Errorf("branch %q has already been merged", currentBranch)
+	}
+	if branch.IsPinned() {
+		return errors.Errorf(
+			"branch %q is pinned, commit is not allowed (run 'av unpin' first)", currentBranch,
+		)
+	}
+	if branch.IsFollowed() {
+		return errors.Errorf(
+			"branch %q is followed from the remote, commit is not allowed", currentBranch,
+		)
+	}
+
+	var addArgs []string
+	if commitFlags.AllChanges {
+		addArgs = []string{"add", "--all"}
+	} else if commitFlags.All {
+		addArgs = []string{"add", "--update"}
+	}
+	if addArgs != nil {
+		if _, err := repo.Run(&git.RunOpts{Args: addArgs, ExitError: true}); err != nil {
+			return errors.WrapIf(err, "failed to stage files")
+		}
+	}
+
+	base, err := actions.BranchBase(repo, branch)
+	if err != nil {
+		return err
+	}
+	commits, err := repo.Log(git.LogOpts{RevisionRange: []string{base + "..HEAD"}})
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return errors.Errorf("branch %q has no commits to fix up", currentBranch)
+	}
+	candidates := map[string]bool{}
+	for _, c := range commits {
+		candidates[c.Hash] = true
+	}
+
+	files, err := readFixupFiles(repo, candidates)
+	if err != nil {
+		return err
+	}
+	matched := map[string]int{}
+	for _, f := range files {
+		for _, target := range f.targets {
+			if target != "" {
+				matched[target]++
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return errors.Errorf(
+			"none of the staged changes belong to a commit of branch %q", currentBranch,
+		)
+	}
+
+	if err := oplog.Record(repo, tx, "av commit --fixup"); err != nil {
+		return err
+	}
+	if err := commitFixups(repo, files, commits, matched); err != nil {
+		return err
+	}
+	if out, err := repo.Run(&git.RunOpts{Args: []string{"diff", "--cached", "--quiet"}}); err != nil {
+		return err
+	} else if out.ExitCode != 0 {
+		fmt.Fprint(os.Stderr,
+			colors.Warning("The staged changes that don't belong to a commit of "),
+			colors.UserInput(currentBranch), colors.Warning(" are left staged."), "\n",
+		)
+	}
+
+	// The changes that are not committed are stashed while the commits are rewritten, since
+	// git rebase refuses to run with them.
+	status, err := repo.Git("status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return err
+	}
+	stashed := status != ""
+	if stashed {
+		if _, err := repo.Run(&git.RunOpts{
+			Args:      []string{"stash", "push", "--message", "av commit --fixup"},
+			ExitError: true,
+		}); err != nil {
+			return errors.WrapIf(err, "failed to stash the uncommitted changes")
+		}
+	}
+	stashHint := func() {
+		if stashed {
+			fmt.Fprint(os.Stderr,
+				colors.Faint("  - the uncommitted changes are stashed; run "),
+				colors.CliCmd("git stash pop --index"), colors.Faint(" to restore them\n"),
+			)
+		}
+	}
+
+	// git rebase --autosquash needs --interactive before Git 2.44. The todo list is used as
+	// it is.
+	_, rebaseErr := repo.Run(&git.RunOpts{
+		Args:        []string{"rebase", "--interactive", "--autosquash", base},
+		Env:         []string{"GIT_SEQUENCE_EDITOR=true"},
+		ExitError:   true,
+		Interactive: true,
+	})
+	if gitPathExists(repo, "rebase-merge") {
+		fmt.Fprint(os.Stderr,
+			"\n", colors.Warning("Folding the fixup commits into "), colors.UserInput(currentBranch),
+			colors.Warning(" is not finished."), "\n",
+			colors.Faint("  - run "), colors.CliCmd("git rebase --continue"),
+			colors.Faint(" to finish it, and then "), colors.CliCmd("av restack"),
+			colors.Faint(" to restack the children\n"),
+		)
+		stashHint()
+		return nil
+	}
+	if rebaseErr != nil {
+		fmt.Fprint(os.Stderr, "\n", colors.Failure("Failed to fold the fixup commits."), "\n")
+		stashHint()
+		return actions.ErrExitSilently{ExitCode: 1}
+	}
+	if err := runPostCommitRestack(repo, db); err != nil {
+		stashHint()
+		return err
+	}
+	if stashed {
+		if _, err := repo.Run(&git.RunOpts{
+			Args:      []string{"stash", "pop", "--index"},
+			ExitError: true,
+		}); err != nil {
+			logrus.WithError(err).Warn("failed to restore the uncommitted changes")
+			stashHint()
+		}
+	}
+	return nil
+}
+
+// readFixupFiles reads the staged changes of the modified files and finds the commits that they
+// belong to. The added, deleted, and renamed files are not folded into any commit.
+func readFixupFiles(repo *git.Repo, candidates map[string]bool) ([]*fixupFile, error) {
+	// The file paths are given as they are, not as pathspecs.
+	env := []string{"GIT_LITERAL_PATHSPECS=1"}
+	out, err := repo.Run(&git.RunOpts{Args: []string{"diff", "--cached", "--quiet"}})
+	if err != nil {
+		return nil, err
+	}
+	if out.ExitCode == 0 {
+		return nil, errors.New("no changes staged for commit")
+	}
+	out, err = repo.Run(&git.RunOpts{
+		Args: []string{
+			"diff", "--cached", "--name-only", "-z", "--no-renames", "--diff-filter=M",
+		},
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to list the staged files")
+	}
+	var ret []*fixupFile
+	for _, path := range strings.Split(string(out.Stdout), "\x00") {
+		if path == "" {
+			continue
+		}
+		f := &fixupFile{path: path}
+		diff, err := repo.Run(&git.RunOpts{
+			Args: []string{
+				"diff", "--cached", "-U0", "--no-color", "--no-ext-diff", "--no-textconv",
+				"--", path,
+			},
+			Env:       env,
+			ExitError: true,
+		})
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to read the staged changes of %q", path)
+		}
+		if f.hunks, err = actions.ParseHunks(string(diff.Stdout)); err != nil {
+			return nil, err
+		}
+		if len(f.hunks) == 0 {
+			// A binary file or a mode change.
+			continue
+		}
+		tree, err := repo.Run(&git.RunOpts{
+			Args:      []string{"ls-tree", "HEAD", "--", path},
+			Env:       env,
+			ExitError: true,
+		})
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to read %q", path)
+		}
+		f.mode, _, _ = strings.Cut(string(tree.Stdout), " ")
+		content, err := repo.Run(&git.RunOpts{
+			Args:      []string{"cat-file", "blob", "HEAD:" + path},
+			ExitError: true,
+		})
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to read %q", path)
+		}
+		f.content = string(content.Stdout)
+		blame, err := repo.BlameLines("HEAD", path)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range f.hunks {
+			target, _ := actions.FixupTarget(h, blame, candidates)
+			f.targets = append(f.targets, target)
+		}
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
+
+// commitFixups creates the fixup commits, from the oldest target commit. The index is restored
+// to the staged changes afterwards, so that the changes that are not committed stay staged.
+func commitFixups(
+	repo *git.Repo,
+	files []*fixupFile,
+	commits []*git.CommitInfo,
+	matched map[string]int,
+) (reterr error) {
+	stagedTree, err := repo.Git("write-tree")
+	if err != nil {
+		return errors.WrapIf(err, "failed to save the staged changes")
+	}
+	defer func() {
+		if _, err := repo.Run(&git.RunOpts{
+			Args:      []string{"read-tree", stagedTree},
+			ExitError: true,
+		}); err != nil {
+			logrus.WithError(err).Error("failed to restore the staged changes")
+			if reterr == nil {
+				reterr = err
+			}
+		}
+	}()
+	if _, err := repo.Run(&git.RunOpts{
+		Args:      []string{"read-tree", "HEAD"},
+		ExitError: true,
+	}); err != nil {
+		return errors.WrapIf(err, "failed to unstage the changes")
+	}
+
+	// The hunks are staged cumulatively on top of the original HEAD content, since the hunks
+	// of the files are relative to it.
+	committed := map[string]bool{}
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		if matched[c.Hash] == 0 {
+			continue
+		}
+		committed[c.Hash] = true
+		for _, f := range files {
+			var hunks []actions.Hunk
+			changed := false
+			for j, h := range f.hunks {
+				if committed[f.targets[j]] {
+					hunks = append(hunks, h)
+					changed = changed || f.targets[j] == c.Hash
+				}
+			}
+			if !changed {
+				continue
+			}
+			if err := stageFixupContent(repo, f, actions.ApplyHunks(f.content, hunks)); err != nil {
+				return err
+			}
+		}
+		commitArgs := []string{"commit", "--fixup=" + c.Hash}
+		commitArgs = append(commitArgs, commitSignoffArgs(commitFlags.Signoff)...)
+		if _, err := repo.Run(&git.RunOpts{
+			Args:        commitArgs,
+			ExitError:   true,
+			Interactive: true,
+		}); err != nil {
+			return errors.WrapIff(err, "failed to create the fixup commit for %s", c.ShortHash)
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Created a fixup commit for "), colors.UserInput(c.ShortHash),
+			colors.Success(" "+c.Subject+" ("), colors.UserInput(matched[c.Hash]),
+			colors.Success(" hunk(s))"), "\n",
+		)
+	}
+	return nil
+}
+
+func stageFixupContent(repo *git.Repo, f *fixupFile, content string) error {
+	blob, err := repo.Run(&git.RunOpts{
+		Args:      []string{"hash-object", "-w", "--no-filters", "--stdin"},
+		Stdin:     strings.NewReader(content),
+		ExitError: true,
+	})
+	if err != nil {
+		return errors.WrapIff(err, "failed to write %q", f.path)
+	}
+	if _, err := repo.Run(&git.RunOpts{
+		Args: []string{
+			"update-index", "--cacheinfo",
+			f.mode + "," + strings.TrimSpace(string(blob.Stdout)) + "," + f.path,
+		},
+		ExitError: true,
+	}); err != nil {
+		return errors.WrapIff(err, "failed to stage %q", f.path)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+	"github.com/spf13/cobra"
+)
+
+// The environment variable that av commit --force sets for git commit so that the pre-commit
+// hook (av commit check-trunk) lets the commit on the trunk branch through.
+const allowTrunkCommitEnv = "AV_ALLOW_TRUNK_COMMIT"
+
+var commitCheckTrunkCmd = &cobra.Command{
+	Use:   "check-trunk",
+	Short: "Fail if the current branch is a trunk branch (for pre-commit hooks)",
+	Long: strings.TrimSpace(`
+Exit with a non-zero status if the current branch is a trunk branch, so that Git
+refuses to commit directly on trunk when this is run from the pre-commit hook:
+
+    #!/bin/sh
+    exec av commit check-trunk
+
+Commits made by av commit --force, and with AV_ALLOW_TRUNK_COMMIT=1 set, are
+allowed.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if os.Getenv(allowTrunkCommitEnv) == "1" {
+			return nil
+		}
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			// Detached HEAD, e.g., during a rebase.
+			return nil
+		}
+		isTrunk, err := repo.IsTrunkBranch(currentBranch)
+		if err != nil {
+			return err
+		}
+		if !isTrunk {
+			return nil
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Failure("Committing directly on the trunk branch "), colors.UserInput(currentBranch),
+			colors.Failure(" is not allowed."), "\n",
+			colors.Faint("  - run "), colors.CliCmd("av commit -b"),
+			colors.Faint(" to commit on a new branch instead\n"),
+		)
+		return actions.ErrExitSilently{ExitCode: 1}
+	},
+}
+
+// trunkCommitBranchName returns the name of the new branch to commit on if the current branch is
+// a trunk branch (for commit.protectTrunk), or an empty string if the commit can be made on the
+// current branch. The user is asked for the name, and the name generated from the
+// commit message is used if nothing is entered.
+func trunkCommitBranchName(message string) (string, error) {
+	repo, err := getRepo()
+	if err != nil {
+		return "", err
+	}
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return "", errors.WrapIf(err, "failed to determine current branch")
+	}
+	if isTrunk, err := repo.IsTrunkBranch(currentBranch); err != nil {
+		return "", err
+	} else if !isTrunk {
+		return "", nil
+	}
+	if commitFlags.Amend || commitFlags.SplitByDir {
+		return "", errors.Errorf(
+			"committing directly on the trunk branch %q is not allowed (use --force to commit anyway)",
+			currentBranch,
+		)
+	}
+	if !uiutils.IsInteractive() {
+		return "", uiutils.InteractionRequired(errors.Errorf(
+			"committing directly on the trunk branch %q is not allowed "+
+				"(use -b to commit on a new branch, or --force to commit anyway)",
+			currentBranch,
+		))
+	}
+
+	generated := branchNameFromMessage(message)
+	fmt.Fprint(os.Stderr,
+		colors.Warning("You are on the trunk branch "), colors.UserInput(currentBranch),
+		colors.Warning("."), " The commit will be made on a new branch.\n",
+		"Branch name",
+	)
+	if generated != "" {
+		fmt.Fprint(os.Stderr, " [", colors.UserInput(generated), "]")
+	}
+	fmt.Fprint(os.Stderr, ": ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return "", err
+	}
+	if name := strings.TrimSpace(answer); name != "" {
+		return applyBranchNamespace(name), nil
+	}
+	if generated == "" {
+		return "", errors.New("a branch name is required to commit on a new branch")
+	}
+	return generated, nil
+}
+
+// trunkCommitEnv returns the environment for git commit that lets av commit --force commit on
+// the trunk branch even with the pre-commit hook.
+func trunkCommitEnv() []string {
+	if !commitFlags.Force {
+		return nil
+	}
+	return []string{allowTrunkCommitEnv + "=1"}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var completionFlags struct {
+	Install   bool
+	Uninstall bool
+}
+
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [--install | --uninstall] [bash|zsh|fish|powershell]",
+	Short: "Generate or install the shell completion script",
+	Long: strings.TrimSpace(`
+Generate the shell completion script for the given shell and print it.
+
+With --install, the completion script is written to the location where the
+shell loads the completions from (or updated if it's already installed). The
+shell is detected from $SHELL if it's not given. With --uninstall, the installed
+completion script is removed.
+
+To load the completion in the current PowerShell session without installing it:
+
+    av completion powershell | Out-String | Invoke-Expression
+`),
+	Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: completionShells,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var shell string
+		if len(args) > 0 {
+			shell = args[0]
+		} else if completionFlags.Install || completionFlags.Uninstall {
+			var err error
+			shell, err = detectShell()
+			if err != nil {
+				return err
+			}
+		} else {
+			return errors.New("specify the shell (bash, zsh, fish, or powershell)")
+		}
+		if !completionFlags.Install && !completionFlags.Uninstall {
+			return generateCompletion(shell, os.Stdout)
+		}
+
+		target, err := completionTargetFor(shell)
+		if err != nil {
+			return err
+		}
+		if completionFlags.Uninstall {
+			return target.uninstall()
+		}
+		var script bytes.Buffer
+		if err := generateCompletion(shell, &script); err != nil {
+			return err
+		}
+		return target.install(script.Bytes())
+	},
+}
+
+// generateCompletion writes the completion script of the shell.
+func generateCompletion(shell string, w io.Writer) error {
+	switch shell {
+	case "bash":
+		return rootCmd.GenBashCompletionV2(w, true)
+	case "zsh":
+		return rootCmd.GenZshCompletion(w)
+	case "fish":
+		return rootCmd.GenFishCompletion(w, true)
+	case "powershell":
+		return rootCmd.GenPowerShellCompletionWithDesc(w)
+	}
+	return errors.Errorf("unsupported shell %q", shell)
+}
+
+// detectShell returns the shell of the user from $SHELL.
+func detectShell() (string, error) {
+	name := strings.TrimSuffix(filepath.Base(os.Getenv("SHELL")), ".exe")
+	switch name {
+	case "bash", "zsh", "fish":
+		return name, nil
+	case "pwsh", "powershell":
+		return "powershell", nil
+	}
+	if runtime.GOOS == "windows" {
+		return "powershell", nil
+	}
+	return "", errors.New("cannot detect the shell from $SHELL (specify bash, zsh, fish, or powershell)")
+}
+
+// completionTarget is where the completion script of a shell is installed.
+type completionTarget struct {
+	shell string
+	// The path of the completion script.
+	script string
+	// The profile that needs to load the script (PowerShell). Empty if the shell loads it from
+	// the directory automatically.
+	profile string
+	// The hint printed after the install (e.g., how to add the directory to fpath).
+	hint string
+}
+
+// completionProfileMarker marks the line that av adds to the shell profile.
+const completionProfileMarker = "# av completion"
+
+func completionTargetFor(shell string) (*completionTarget, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+
+	switch shell {
+	case "bash":
+		// bash-completion loads the completions from this directory on demand.
+		return &completionTarget{
+			shell:  shell,
+			script: filepath.Join(dataHome, "bash-completion", "completions", "av"),
+			hint:   "the bash-completion package is required to load it",
+		}, nil
+	case "zsh":
+		dir := filepath.Join(dataHome, "zsh", "site-functions")
+		return &completionTarget{
+			shell:  shell,
+			script: filepath.Join(dir, "_av"),
+			hint: "if the directory is not in fpath yet, add `fpath=(" + dir + " $fpath)` " +
+				"before compinit in ~/.zshrc",
+		}, nil
+	case "fish":
+		return &completionTarget{
+			shell:  shell,
+			script: filepath.Join(configHome, "fish", "completions", "av.fish"),
+		}, nil
+	case "powershell":
+		profileDir := filepath.Join(configHome, "powershell")
+		if runtime.GOOS == "windows" {
+			profileDir = filepath.Join(home, "Documents", "PowerShell")
+			if _, err := exec.LookPath("pwsh"); err != nil {
+				// Windows PowerShell 5.1 (without PowerShell 7) has its own profile.
+				profileDir = filepath.Join(home, "Documents", "WindowsPowerShell")
+			}
+		}
+		return &completionTarget{
+			shell:   shell,
+			script:  filepath.Join(profileDir, "av-completion.ps1"),
+			profile: filepath.Join(profileDir, "Microsoft.PowerShell_profile.ps1"),
+		}, nil
+	}
+	return nil, errors.Errorf("unsupported shell %q", shell)
+}
+
+func (t *completionTarget) profileLine() string {
+	return ". \"" + t.script + "\" " + completionProfileMarker
+}
+
+func (t *completionTarget) install(script []byte) error {
+	existing, err := os.ReadFile(t.script)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	exists := err == nil
+	upToDate := exists && bytes.Equal(existing, script)
+	if !upToDate {
+		if err := os.MkdirAll(filepath.Dir(t.script), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(t.script, script, 0o644); err != nil {
+			return errors.WrapIff(err, "failed to write the completion script to %s", t.script)
+		}
+	}
+	if t.profile != "" {
+		if err := t.addProfileLine(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case upToDate:
+		fmt.Fprint(os.Stderr,
+			"The ", t.shell, " completion is already up to date at ",
+			colors.UserInput(t.script), ".\n",
+		)
+	case exists:
+		fmt.Fprint(os.Stderr,
+			colors.Success("Updated the "+t.shell+" completion at "),
+			colors.UserInput(t.script), colors.Success(".\n"),
+		)
+	default:
+		fmt.Fprint(os.Stderr,
+			colors.Success("Installed the "+t.shell+" completion to "),
+			colors.UserInput(t.script), colors.Success(".\n"),
+		)
+	}
+	if !upToDate {
+		if t.hint != "" {
+			fmt.Fprint(os.Stderr, colors.Faint("  - "+t.hint), "\n")
+		}
+		fmt.Fprint(os.Stderr, colors.Faint("  - restart the shell to enable it"), "\n")
+	}
+	return nil
+}
+
+func (t *completionTarget) uninstall() error {
+	removed := false
+	if err := os.Remove(t.script); err == nil {
+		removed = true
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if t.profile != "" {
+		removedLine, err := t.removeProfileLine()
+		if err != nil {
+			return err
+		}
+		removed = removed || removedLine
+	}
+	if !removed {
+		fmt.Fprint(os.Stderr, "The ", t.shell, " completion is not installed.\n")
+		return nil
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Success("Uninstalled the "+t.shell+" completion from "),
+		colors.UserInput(t.script), colors.Success(".\n"),
+	)
+	return nil
+}
+
+// addProfileLine adds the line that loads the completion script to the profile unless it's
+// already there.
+func (t *completionTarget) addProfileLine() error {
+	content, err := os.ReadFile(t.profile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// Keep the line endings of the profile (PowerShell profiles on Windows often have CRLF).
+	newline := "\n"
+	if bytes.Contains(content, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSuffix(line, "\r") == t.profileLine() {
+			return nil
+		}
+	}
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, newline...)
+	}
+	content = append(content, t.profileLine()+newline...)
+	if err := os.MkdirAll(filepath.Dir(t.profile), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(t.profile, content, 0o644); err != nil {
+		return errors.WrapIff(err, "failed to update %s", t.profile)
+	}
+	return nil
+}
+
+// removeProfileLine removes the lines added by addProfileLine from the profile.
+func (t *completionTarget) removeProfileLine() (bool, error) {
+	content, err := os.ReadFile(t.profile)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	var kept []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if !strings.HasSuffix(strings.TrimSuffix(line, "\r"), completionProfileMarker) {
+			kept = append(kept, line)
+		}
+	}
+	newContent := strings.Join(kept, "\n")
+	if newContent == string(content) {
+		return false, nil
+	}
+	if err := os.WriteFile(t.profile, []byte(newContent), 0o644); err != nil {
+		return false, errors.WrapIff(err, "failed to update %s", t.profile)
+	}
+	return true, nil
+}
+
+func init() {
+	completionCmd.Flags().BoolVar(
+		&completionFlags.Install, "install", false,
+		"install (or update) the completion script for the shell",
+	)
+	completionCmd.Flags().BoolVar(
+		&completionFlags.Uninstall, "uninstall", false,
+		"remove the installed completion script for the shell",
+	)
+	completionCmd.MarkFlagsMutuallyExclusive("install", "uninstall")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/sequencer"
+	"github.com/aviator-co/av/internal/sequencer/sequencerui"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+)
+
+// The descriptions of the av operations that can be interrupted by a conflict, keyed by the
+// kind of their state files.
+var interruptedOperations = map[git.StateFileKind]string{
+	git.StateFileKindSyncV2:  "a sync",
+	git.StateFileKindRestack: "a restack",
+	git.StateFileKindReorder: "a reorder",
+}
+
+// checkNoOtherOperationInProgress returns an error if an av operation other than the given one
+// is interrupted. Starting another operation on top of it would mix up their states.
+func checkNoOtherOperationInProgress(repo *git.Repo, kind git.StateFileKind) error {
+	inProgress, ok := repo.InProgressStateFile()
+	if !ok || inProgress == kind {
+		return nil
+	}
+	return errors.Errorf(
+		"%s is in progress; resolve the conflicts and run av sync --continue (or --abort or --skip) first",
+		interruptedOperations[inProgress],
+	)
+}
+
+// explainInterruptedOperation prints the walkthrough of resolving the conflict of the
+// interrupted operation (see sequencerui.ConflictWalkthrough).
+func explainInterruptedOperation(repo *git.Repo) error {
+	kind, ok := repo.InProgressStateFile()
+	if !ok {
+		return errors.New("no av operation is in progress")
+	}
+	var seq *sequencer.Sequencer
+	switch kind {
+	case git.StateFileKindSyncV2:
+		var state savedSyncState
+		if err := repo.ReadStateFile(kind, &state); err != nil {
+			return err
+		}
+		if state.RestackState != nil {
+			seq = state.RestackState.Seq
+		}
+	case git.StateFileKindRestack:
+		var state sequencerui.RestackState
+		if err := repo.ReadStateFile(kind, &state); err != nil {
+			return err
+		}
+		seq = state.Seq
+	}
+	var branch string
+	if seq != nil {
+		branch = seq.CurrentSyncRef.Short()
+	}
+	walkthrough, err := sequencerui.ConflictWalkthrough(repo, branch, "av sync")
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stdout, walkthrough)
+	return nil
+}
+
+// resumeInterruptedOperation continues, aborts, or skips the interrupted operation of the given
+// kind with the flags of av sync, so that av sync --continue works for all the operations that
+// rebase the branches (e.g., av restack, av reparent, and av reorder).
+func resumeInterruptedOperation(repo *git.Repo, db meta.DB, kind git.StateFileKind) error {
+	switch kind {
+	case git.StateFileKindRestack:
+		restackFlags.Continue = syncFlags.Continue
+		restackFlags.Abort = syncFlags.Abort
+		restackFlags.Skip = syncFlags.Skip
+		return uiutils.RunBubbleTea(&restackViewModel{repo: repo, db: db})
+	case git.StateFileKindReorder:
+		if syncFlags.Skip {
+			return errors.New(
+				"a reorder can't skip a commit; resolve the conflicts and run av sync --continue, or run av sync --abort",
+			)
+		}
+		return runReorder(repo, db, reorderOptions{
+			Continue: syncFlags.Continue,
+			Abort:    syncFlags.Abort,
+		})
+	}
+	return errors.Errorf("unknown av operation state %q", kind)
+}
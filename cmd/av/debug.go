@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/debugbundle"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Collect diagnostics for bug reports",
+}
+
+var debugBundleFlags struct {
+	File string
+}
+
+var debugBundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Create an archive of sanitized diagnostics to attach to a bug report",
+	Long: strings.TrimSpace(`
+Create an archive of sanitized diagnostics to attach to a bug report.
+
+The archive contains the versions of av and Git, the branch metadata of av, the
+recent updates of the branches, and the traces of the recent av commands run
+with --debug (run the failing command with --debug first). The branch names are replaced
+with generic names, and the commit messages, pull request titles and bodies,
+email addresses, paths, and credentials are removed.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		bundle, err := debugbundle.Collect(repo, db.ReadTx())
+		if err != nil {
+			return err
+		}
+
+		output := debugBundleFlags.File
+		if output == "" {
+			output = fmt.Sprintf("av-debug-bundle-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			return errors.WrapIf(err, "failed to create the bundle")
+		}
+		if err := bundle.WriteArchive(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return errors.WrapIf(err, "failed to create the bundle")
+		}
+
+		fmt.Fprint(os.Stderr,
+			colors.Success("Created the debug bundle "), colors.UserInput(output), colors.Success(".\n"),
+			colors.Faint("  - it includes "), fmt.Sprint(len(bundle.Branches)),
+			colors.Faint(" branches and "), fmt.Sprint(len(bundle.Traces)),
+			colors.Faint(" command traces\n"),
+			colors.Faint("  - please review the contents before attaching it to an issue\n"),
+		)
+		return nil
+	},
+}
+
+func init() {
+	debugBundleCmd.Flags().StringVarP(
+		&debugBundleFlags.File, "file", "f", "",
+		"path of the archive (default: av-debug-bundle-<timestamp>.tar.gz)",
+	)
+	debugCmd.AddCommand(debugBundleCmd)
+}
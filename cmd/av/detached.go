@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+)
+
+// nearbyBranch is a branch that is related to the detached HEAD.
+type nearbyBranch struct {
+	Name string
+	// The number of commits between the branch and HEAD.
+	Distance int
+}
+
+// nearestAncestorBranch returns the branch managed by av (or the trunk branch) whose head is
+// the closest ancestor of HEAD. This is the branch that a new branch created at HEAD should be
+// stacked on. The second return value is false if no such branch is found.
+func nearestAncestorBranch(repo *git.Repo, tx meta.ReadTx) (nearbyBranch, bool) {
+	candidates, _ := repo.TrunkBranches()
+	for name := range tx.AllBranches() {
+		candidates = append(candidates, name)
+	}
+	var ret nearbyBranch
+	found := false
+	for _, name := range candidates {
+		if _, err := repo.Git("merge-base", "--is-ancestor", name, "HEAD"); err != nil {
+			continue
+		}
+		distance, err := revListCount(repo, name+"..HEAD")
+		if err != nil {
+			continue
+		}
+		if !found || distance < ret.Distance || (distance == ret.Distance && name < ret.Name) {
+			ret = nearbyBranch{Name: name, Distance: distance}
+			found = true
+		}
+	}
+	return ret, found
+}
+
+// branchesContainingHEAD returns the branches managed by av that contain HEAD, closest first.
+// These are the branches that HEAD can be reattached to.
+func branchesContainingHEAD(repo *git.Repo, tx meta.ReadTx) []nearbyBranch {
+	var ret []nearbyBranch
+	for name := range tx.AllBranches() {
+		if _, err := repo.Git("merge-base", "--is-ancestor", "HEAD", name); err != nil {
+			continue
+		}
+		distance, err := revListCount(repo, "HEAD.."+name)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, nearbyBranch{Name: name, Distance: distance})
+	}
+	slices.SortFunc(ret, func(a, b nearbyBranch) int {
+		if a.Distance != b.Distance {
+			return a.Distance - b.Distance
+		}
+		if a.Name < b.Name {
+			return -1
+		}
+		return 1
+	})
+	return ret
+}
+
+func revListCount(repo *git.Repo, rev string) (int, error) {
+	out, err := repo.Git("rev-list", "--count", rev)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(out)
+}
+
+// gitPathExists returns true if the given path in the Git directory (as in
+// `git rev-parse --git-path`) exists.
+func gitPathExists(repo *git.Repo, path string) bool {
+	p, err := repo.Git("rev-parse", "--git-path", path)
+	if err != nil {
+		return false
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(repo.Dir(), p)
+	}
+	_, err = os.Stat(p)
+	return err == nil
+}
+
+// printDetachedHEADHelp explains why the command failed with a detached HEAD and suggests how
+// to recover from it.
+func printDetachedHEADHelp() {
+	repo, err := getRepo()
+	if err != nil {
+		return
+	}
+	fmt.Fprint(os.Stderr, colors.Faint("HEAD is not on a branch.\n"))
+	if gitPathExists(repo, "rebase-merge") || gitPathExists(repo, "rebase-apply") {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("  - a rebase is in progress: resolve the conflicts and run "),
+			colors.CliCmd("av restack --continue"), colors.Faint(" (or "),
+			colors.CliCmd("git rebase --continue"),
+			colors.Faint(" if the rebase was not started by av)\n"),
+		)
+		return
+	}
+	if gitPathExists(repo, "BISECT_LOG") {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("  - a bisect is in progress: run "), colors.CliCmd("git bisect reset"),
+			colors.Faint(" to go back to the original branch\n"),
+		)
+		return
+	}
+
+	db, err := getDB(repo)
+	if err != nil {
+		return
+	}
+	tx := db.ReadTx()
+	if containing := branchesContainingHEAD(repo, tx); len(containing) > 0 {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("  - run "), colors.CliCmd("av switch "+containing[0].Name),
+			colors.Faint(" to reattach to the nearest branch that contains HEAD"),
+		)
+		if containing[0].Distance > 0 {
+			fmt.Fprint(os.Stderr,
+				colors.Faint(fmt.Sprintf(" (%d commit(s) ahead of HEAD)", containing[0].Distance)),
+			)
+		}
+		fmt.Fprint(os.Stderr, "\n")
+	}
+	if parent, ok := nearestAncestorBranch(repo, tx); ok {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("  - run "), colors.CliCmd("av branch <new-branch>"),
+			colors.Faint(" to create a new branch at HEAD stacked on "),
+			colors.UserInput(parent.Name), "\n",
+		)
+	}
+}
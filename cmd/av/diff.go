@@ -3,22 +3,43 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
+	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/textutils"
+	"golang.org/x/exp/maps"
 
 	"github.com/aviator-co/av/internal/git"
 	"github.com/spf13/cobra"
 )
 
+var diffFlags struct {
+	Branch   string
+	Stack    bool
+	Stat     bool
+	NameOnly bool
+}
+
 var diffCmd = &cobra.Command{
-	Use:   "diff",
+	Use:   "diff [--branch <branch>] [--stack] [--stat | --name-only]",
 	Short: "Show the diff between working tree and parent branch",
 	Long: strings.TrimSpace(`
 Generates the diff between the working tree and the parent branch 
 (i.e., the diff between the current branch and the previous branch in the stack).
+
+With --stack, the diff is generated against the trunk instead, covering all the
+branches up to the current branch. With --stack --stat, a report of the stack is
+shown instead of the diff: the changes of each branch, the files that are changed
+by multiple branches (which often predicts rebase conflicts), and the cumulative
+changes of the stack (the diff of the top of the stack against the trunk).
+
+With --branch, the diff of the given branch is shown instead of the working tree:
+the changes that the branch contributes on top of its parent branch (or, with
+--stack, the changes of the stack up to the branch).
 `),
 	SilenceUsage: true,
 	Args:         cobra.NoArgs,
@@ -32,13 +53,21 @@ Generates the diff between the working tree and the parent branch
 			return err
 		}
 
-		currentBranchName, err := repo.CurrentBranchName()
-		if err != nil {
-			return err
+		branchName := diffFlags.Branch
+		if branchName == "" {
+			branchName, err = repo.CurrentBranchName()
+			if err != nil {
+				return err
+			}
+		} else if _, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + branchName}); err != nil {
+			return errors.Errorf("branch %q does not exist", branchName)
 		}
 
 		tx := db.ReadTx()
-		branch, exists := tx.Branch(currentBranchName)
+		if diffFlags.Stack && diffFlags.Stat {
+			return printStackDiffStat(repo, tx, branchName)
+		}
+		branch, exists := tx.Branch(branchName)
 		if !exists {
 			defaultBranch, err := repo.DefaultBranch()
 			if err != nil {
@@ -51,9 +80,23 @@ Generates the diff between the working tree and the parent branch
 		}
 
 		diffArgs := []string{"diff"}
+		if diffFlags.Stat {
+			diffArgs = append(diffArgs, "--stat")
+		}
+		if diffFlags.NameOnly {
+			diffArgs = append(diffArgs, "--name-only")
+		}
 		notUpToDate := false
 
-		if branch.Parent.Trunk {
+		if diffFlags.Stack {
+			// Compare against the merge-base with the trunk (see below) to show the
+			// changes of all the branches up to the current branch.
+			trunk, _ := meta.Trunk(tx, branchName)
+			if trunk == "" {
+				trunk = branch.Parent.Name
+			}
+			diffArgs = append(diffArgs, "--merge-base", trunk)
+		} else if branch.Parent.Trunk {
 			// Compare against the merge-base so that we effectively only see the
 			// diff associated with this branch. Without this, if main has
 			// advanced since this branch was created, we'd also see the (inverse)
@@ -98,6 +141,10 @@ Generates the diff between the working tree and the parent branch
 			}
 			notUpToDate = currentParentHead != branch.Parent.Head
 		}
+		if diffFlags.Branch != "" {
+			// Compare against the branch itself instead of the working tree.
+			diffArgs = append(diffArgs, "refs/heads/"+branchName, "--")
+		}
 
 		// NOTE:
 		// We don't use repo.Diff here since that sets the --exit-error flag
@@ -115,7 +162,7 @@ Generates the diff between the working tree and the parent branch
 		// output pager doesn't eat this message.
 		if notUpToDate {
 			fmt.Fprint(os.Stderr,
-				colors.Warning("\nWARNING: Branch "), colors.UserInput(currentBranchName),
+				colors.Warning("\nWARNING: Branch "), colors.UserInput(branchName),
 				colors.Warning(" is not up to date with parent branch "),
 				colors.UserInput(branch.Parent.Name), colors.Warning(". Run "),
 				colors.CliCmd("av sync"), colors.Warning(" to synchronize the branch.\n"),
@@ -126,3 +173,134 @@ Generates the diff between the working tree and the parent branch
 		return nil
 	},
 }
+
+// printStackDiffStat prints the changes of each branch in the stack, the files that are
+// changed by multiple branches, and the cumulative changes of the stack.
+func printStackDiffStat(repo *git.Repo, tx meta.ReadTx, currentBranchName string) error {
+	branches, err := meta.StackBranches(tx, currentBranchName)
+	if err != nil {
+		return err
+	}
+
+	fileBranches := map[string][]string{}
+	fmt.Println("Changes by branch:")
+	for _, name := range branches {
+		branch, _ := tx.Branch(name)
+		base := branch.Parent.Head
+		if branch.Parent.Trunk || base == "" {
+			// The parent head is not recorded for the trunk, nor in the metadata of the
+			// adopted branches and the old metadata.
+			base, err = repo.MergeBase(branch.Parent.Name, name)
+			if err != nil {
+				return err
+			}
+		}
+		stats, err := repo.DiffStats(base, name)
+		if err != nil {
+			return err
+		}
+		var additions, deletions int
+		for _, stat := range stats {
+			additions += stat.Additions
+			deletions += stat.Deletions
+			fileBranches[stat.Path] = append(fileBranches[stat.Path], name)
+		}
+		fmt.Printf(
+			"  %s: %d %s, %s\n",
+			colors.UserInput(name),
+			len(stats), textutils.Pluralize(len(stats), "file", "files"),
+			formatAdditionsDeletions(additions, deletions),
+		)
+	}
+
+	paths := maps.Keys(fileBranches)
+	sort.Strings(paths)
+
+	var overlaps []string
+	for _, path := range paths {
+		if len(fileBranches[path]) > 1 {
+			overlaps = append(overlaps, path)
+		}
+	}
+	fmt.Println()
+	if len(overlaps) == 0 {
+		fmt.Println("No files are changed by multiple branches.")
+	} else {
+		fmt.Println("Files changed by multiple branches:")
+		for _, path := range overlaps {
+			fmt.Printf("  %s: %s\n", path, strings.Join(fileBranches[path], ", "))
+		}
+	}
+
+	// The cumulative changes are the diff of each top of the stack against the trunk, not the
+	// sum of the changes of the branches (a line added by a branch and removed by its child
+	// is not a change of the stack). A stack that forks has a top for each fork, so that the
+	// cumulative changes cover the same branches as the changes by branch.
+	for _, top := range stackTops(tx, branches) {
+		trunk, _ := meta.Trunk(tx, top)
+		base, err := repo.MergeBase(trunk, top)
+		if err != nil {
+			return err
+		}
+		stats, err := repo.DiffStats(base, top)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		fmt.Printf("Cumulative changes of the stack up to %s:\n", colors.UserInput(top))
+		var additions, deletions int
+		for _, stat := range stats {
+			additions += stat.Additions
+			deletions += stat.Deletions
+			if stat.Binary {
+				fmt.Printf("  %s: binary\n", stat.Path)
+			} else {
+				fmt.Printf("  %s: %s\n", stat.Path, formatAdditionsDeletions(stat.Additions, stat.Deletions))
+			}
+		}
+		fmt.Printf(
+			"  %d %s changed, %s\n",
+			len(stats), textutils.Pluralize(len(stats), "file", "files"),
+			formatAdditionsDeletions(additions, deletions),
+		)
+	}
+	return nil
+}
+
+// stackTops returns the branches of the stack that have no children, in the order of the
+// branches.
+func stackTops(tx meta.ReadTx, branches []string) []string {
+	var tops []string
+	for _, name := range branches {
+		if len(meta.ChildrenNames(tx, name)) == 0 {
+			tops = append(tops, name)
+		}
+	}
+	return tops
+}
+
+func formatAdditionsDeletions(additions, deletions int) string {
+	return colors.Success(fmt.Sprintf("+%d", additions)) + " " +
+		colors.Failure(fmt.Sprintf("-%d", deletions))
+}
+
+func init() {
+	diffCmd.Flags().StringVar(
+		&diffFlags.Branch, "branch", "",
+		"show the diff of the given branch instead of the working tree",
+	)
+	_ = diffCmd.RegisterFlagCompletionFunc("branch", branchNameArgs)
+	diffCmd.Flags().BoolVar(
+		&diffFlags.Stack, "stack", false,
+		"show the diff of the stack up to the current branch against the trunk",
+	)
+	diffCmd.Flags().BoolVar(
+		&diffFlags.Stat, "stat", false,
+		"show the diffstat instead of the diff\n(with --stack, show a report of the changes of each branch and the overlapping files)",
+	)
+	diffCmd.Flags().BoolVar(
+		&diffFlags.NameOnly, "name-only", false,
+		"show only the names of the changed files",
+	)
+	diffCmd.MarkFlagsMutuallyExclusive("stat", "name-only")
+}
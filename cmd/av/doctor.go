@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+	"github.com/spf13/cobra"
+)
+
+var doctorFlags struct {
+	Fix bool
+	Yes bool
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [--fix [--yes]]",
+	Short: "Check the av metadata against the Git branches",
+	Long: strings.TrimSpace(`
+Check the av metadata against the branches in the repository and the remote
+tracking branches, and report the inconsistencies. They usually happen after
+running plain Git commands: branches deleted or renamed with Git, parents that
+are no longer tracked, parent heads that are no longer in the history of the
+branch, and open pull requests whose branches are not pushed.
+
+If the --fix flag is given, you are asked to repair each inconsistency that can
+be repaired automatically (with --yes, all of them are repaired without asking).
+Only the av metadata is changed. Run av undo to restore the metadata.
+
+The command exits with a non-zero status if an inconsistency remains.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		issues, err := actions.Diagnose(repo, db.ReadTx())
+		if err != nil {
+			return err
+		}
+		if len(issues) == 0 {
+			fmt.Fprint(os.Stderr, colors.Success("No issues found.\n"))
+			return nil
+		}
+
+		if !doctorFlags.Fix {
+			for _, issue := range issues {
+				fmt.Fprint(os.Stderr, colors.Warning("  - "+issue.Message), "\n")
+				if issue.Fix != "" {
+					fmt.Fprint(os.Stderr, colors.Faint("    fix: "+issue.Fix), "\n")
+				}
+			}
+			fmt.Fprint(os.Stderr,
+				"Found ", colors.UserInput(len(issues)), " issue(s). Run ",
+				colors.CliCmd("av doctor --fix"), " to repair them.\n",
+			)
+			return actions.ErrExitSilently{ExitCode: 1}
+		}
+
+		if !doctorFlags.Yes {
+			if err := uiutils.RequireInteractive("confirming the fixes", "use --yes"); err != nil {
+				return err
+			}
+		}
+		if err := oplog.Record(repo, db.ReadTx(), "av doctor --fix"); err != nil {
+			return err
+		}
+		tx := db.WriteTx()
+		cu := cleanup.New(func() { tx.Abort() })
+		defer cu.Cleanup()
+		stdin := bufio.NewReader(os.Stdin)
+		remaining := 0
+		for _, issue := range issues {
+			fmt.Fprint(os.Stderr, colors.Warning("  - "+issue.Message), "\n")
+			if issue.Fix == "" {
+				remaining++
+				continue
+			}
+			if !doctorFlags.Yes {
+				fmt.Fprint(os.Stderr, "    ", issue.Fix, "? [y/N]: ")
+				answer, err := stdin.ReadString('\n')
+				if err != nil && answer == "" {
+					return err
+				}
+				if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+					remaining++
+					continue
+				}
+			}
+			if err := actions.FixDoctorIssue(tx, issue); err != nil {
+				return err
+			}
+			fmt.Fprint(os.Stderr, colors.Success("    fixed: "+issue.Fix), "\n")
+		}
+		cu.Cancel()
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		if remaining > 0 {
+			fmt.Fprint(os.Stderr,
+				colors.UserInput(remaining), " issue(s) remain.\n",
+			)
+			return actions.ErrExitSilently{ExitCode: 1}
+		}
+		return nil
+	},
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(
+		&doctorFlags.Fix, "fix", false,
+		"repair the inconsistencies, asking for each of them",
+	)
+	doctorCmd.Flags().BoolVarP(
+		&doctorFlags.Yes, "yes", "y", false,
+		"with --fix, repair all the inconsistencies without asking",
+	)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/cobra"
+)
+
+var editCommitsFlags struct {
+	Autosquash bool
+}
+
+var editCommitsCmd = &cobra.Command{
+	Use:   "edit-commits [--autosquash]",
+	Short: "Interactively rebase the commits of the current branch",
+	Long: strings.TrimSpace(`
+Interactively rebase the commits of the current branch, and restack the children
+of the branch afterwards.
+
+This runs git rebase -i with only the commits of the current branch (the commits
+since its parent branch) in the todo list, so that the commits can be reordered,
+reworded, squashed, or dropped without touching the parent branches.
+
+If the rebase stops (e.g., for an "edit" command or a conflict), finish it with
+git rebase --continue and run av restack to restack the children.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if err := uiutils.RequireInteractive("editing the commits", ""); err != nil {
+			return err
+		}
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		if err := checkNoOtherOperationInProgress(repo, git.StateFileKindRestack); err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		branch, exists := tx.Branch(currentBranch)
+		if !exists {
+			return actions.BranchNotAdoptedError{Branch: currentBranch}
+		}
+		if branch.PullRequest != nil && branch.PullRequest.State == githubv4.PullRequestStateMerged {
+			return errors.Errorf("branch %q has already been merged", currentBranch)
+		}
+		if branch.IsPinned() {
+			return errors.Errorf(
+				"branch %q is pinned, editing the commits is not allowed (run 'av unpin' first)",
+				currentBranch,
+			)
+		}
+
+		base, err := actions.BranchBase(repo, branch)
+		if err != nil {
+			return err
+		}
+		commits, err := repo.Log(git.LogOpts{RevisionRange: []string{base + ".." + currentBranch}})
+		if err != nil {
+			return err
+		}
+		if len(commits) == 0 {
+			return errors.Errorf("branch %q has no commits to edit", currentBranch)
+		}
+
+		if err := oplog.Record(repo, tx, "av edit-commits"); err != nil {
+			return err
+		}
+		args := []string{"rebase", "--interactive"}
+		if editCommitsFlags.Autosquash {
+			args = append(args, "--autosquash")
+		}
+		args = append(args, base)
+		// The rebase needs the terminal for the editor, so this runs before bubbletea grabs
+		// the terminal.
+		_, rebaseErr := repo.Run(&git.RunOpts{
+			Args:        args,
+			ExitError:   true,
+			Interactive: true,
+		})
+		if gitPathExists(repo, "rebase-merge") {
+			fmt.Fprint(os.Stderr,
+				"\n", colors.Warning("The rebase of "), colors.UserInput(currentBranch),
+				colors.Warning(" is not finished."), "\n",
+				colors.Faint("  - run "), colors.CliCmd("git rebase --continue"),
+				colors.Faint(" to finish it, and then "), colors.CliCmd("av restack"),
+				colors.Faint(" to restack the children\n"),
+			)
+			return nil
+		}
+		if rebaseErr != nil {
+			fmt.Fprint(os.Stderr, "\n", colors.Failure("Failed to edit the commits."), "\n")
+			return actions.ErrExitSilently{ExitCode: 1}
+		}
+		return runPostCommitRestack(repo, db)
+	},
+}
+
+func init() {
+	editCommitsCmd.Flags().BoolVar(
+		&editCommitsFlags.Autosquash, "autosquash", false,
+		"move the fixup! and squash! commits next to the commits they fix (see git rebase --autosquash)",
+	)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// The prefix of the executables on PATH that are run as av subcommands (e.g., av-foo for
+// av foo), like git does for git-foo.
+const extensionPrefix = "av-"
+
+// findExtensions returns the extension executables on PATH keyed by the subcommand name. The
+// first one on PATH wins if there are multiple executables with the same name.
+func findExtensions() map[string]string {
+	ret := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, extensionPrefix) {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				if !strings.EqualFold(filepath.Ext(name), ".exe") {
+					continue
+				}
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			sub := strings.TrimPrefix(name, extensionPrefix)
+			if sub == "" {
+				continue
+			}
+			if _, ok := ret[sub]; ok {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			// Follow the symlinks (e.g., the executables installed by a package manager).
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+				continue
+			}
+			ret[sub] = path
+		}
+	}
+	return ret
+}
+
+// addExtensionCommands adds the extensions on PATH as subcommands, so that they are run by
+// av <name> and listed in av --help and the shell completion. The extensions cannot override
+// the built-in commands.
+func addExtensionCommands() {
+	extensions := findExtensions()
+	var names []string
+	for name := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if isBuiltinCommand(name) {
+			logrus.WithField("extension", extensions[name]).
+				Debug("ignoring the extension that has the name of a built-in command")
+			continue
+		}
+		rootCmd.AddCommand(newExtensionCommand(name, extensions[name]))
+	}
+}
+
+func isBuiltinCommand(name string) bool {
+	for _, cmd := range rootCmd.Commands() {
+		if cmd.Name() == name || cmd.HasAlias(name) {
+			return true
+		}
+	}
+	// Added by cobra when the command is executed.
+	return name == "help" || name == cobra.ShellCompRequestCmd || name == cobra.ShellCompNoDescRequestCmd
+}
+
+func newExtensionCommand(name, path string) *cobra.Command {
+	return &cobra.Command{
+		Use:   name,
+		Short: "Run the " + extensionPrefix + name + " extension (" + path + ")",
+		// The arguments (including the flags) are passed to the extension as they are.
+		DisableFlagParsing: true,
+		// The root flags before the subcommand name (e.g., av --debug foo) are parsed here
+		// since the flag parsing is disabled.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			rootArgs, extArgs := splitExtensionArgs(os.Args[1:], name)
+			if err := rootCmd.PersistentFlags().Parse(rootArgs); err != nil {
+				return err
+			}
+			return rootCmd.PersistentPreRunE(cmd, extArgs)
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, extArgs := splitExtensionArgs(os.Args[1:], name)
+			return runExtension(path, extArgs)
+		},
+	}
+}
+
+// splitExtensionArgs splits the command line arguments into the root flags before the
+// extension subcommand name and the arguments after it.
+func splitExtensionArgs(args []string, name string) (rootArgs []string, extArgs []string) {
+	flags := rootCmd.PersistentFlags()
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == name {
+			return args[:i], args[i+1:]
+		}
+		if !strings.HasPrefix(arg, "-") || strings.Contains(arg, "=") {
+			continue
+		}
+		flag := flags.Lookup(strings.TrimPrefix(arg, "--"))
+		if !strings.HasPrefix(arg, "--") && len(arg) == 2 {
+			flag = flags.ShorthandLookup(arg[1:])
+		}
+		if flag != nil && flag.NoOptDefVal == "" {
+			// Skip the value of the flag.
+			i++
+		}
+	}
+	return nil, args
+}
+
+// runExtension runs the extension with the repository context in the environment variables. The
+// exit code of the extension becomes the exit code of av.
+func runExtension(path string, args []string) error {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), extensionEnv()...)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return actions.ErrExitSilently{ExitCode: exitErr.ExitCode()}
+	}
+	return errors.WrapIff(err, "failed to run %s", path)
+}
+
+// extensionEnv returns the environment variables that tell the extension about av and the
+// repository. The repository variables are not set outside of a repository.
+func extensionEnv() []string {
+	env := []string{
+		"AV_VERSION=" + config.Version,
+		"AV_FORGE=" + config.Av.Forge,
+	}
+	if exe, err := os.Executable(); err == nil {
+		env = append(env, "AV_BIN="+exe)
+	}
+	if rootFlags.Debug {
+		env = append(env, "AV_DEBUG=1")
+	}
+	if config.NonInteractive {
+		// The av commands run by the extension are non-interactive too.
+		env = append(env, "AV_NON_INTERACTIVE=1")
+	}
+	switch config.Av.Forge {
+	case config.ForgeGitLab:
+		env = append(env,
+			"AV_FORGE_URL="+strings.TrimSuffix(config.Av.GitLab.BaseURL, "/"),
+			"AV_FORGE_API_URL="+strings.TrimSuffix(config.Av.GitLab.BaseURL, "/")+"/api/v4",
+		)
+	default:
+		url := strings.TrimSuffix(config.Av.GitHub.BaseURL, "/")
+		if url == "" {
+			url = "https://github.com"
+		}
+		env = append(env,
+			"AV_FORGE_URL="+url,
+			"AV_FORGE_API_URL="+config.Av.GitHub.APIEndpoint(),
+		)
+	}
+
+	repo, err := getRepo()
+	if err != nil {
+		return env
+	}
+	env = append(env,
+		"AV_REPO_ROOT="+repo.Dir(),
+		"AV_GIT_DIR="+repo.GitDir(),
+		"AV_DIR="+repo.AvDir(),
+		"AV_DB="+filepath.Join(repo.AvDir(), "av.db"),
+		"AV_REMOTE="+repo.GetRemoteName(),
+	)
+	if branch, err := repo.CurrentBranchName(); err == nil {
+		env = append(env, "AV_CURRENT_BRANCH="+branch)
+	}
+	if trunk, err := repo.DefaultBranch(); err == nil {
+		env = append(env, "AV_TRUNK="+trunk)
+	}
+	if db, err := getDB(repo); err == nil {
+		if r := db.ReadTx().Repository(); r.Owner != "" {
+			env = append(env, "AV_REPOSITORY="+r.Owner+"/"+r.Name)
+		}
+	}
+	return env
+}
@@ -108,6 +108,7 @@ var fetchCmd = &cobra.Command{
 					ID:        pr.ID,
 					Number:    pr.Number,
 					Permalink: pr.Permalink,
+					Title:     pr.Title,
 				}
 				tx.SetBranch(branchMeta)
 			}
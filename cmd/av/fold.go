@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var foldFlags struct {
+	ClosePR bool
+}
+
+var foldCmd = &cobra.Command{
+	Use:   "fold [--close-pr]",
+	Short: "Fold the current branch into its parent branch",
+	Long: strings.TrimSpace(`
+Fold the current branch into its parent branch.
+
+The parent branch is moved to the head of the current branch so that it has the
+commits of both branches. The children of the current branch are moved onto the
+parent branch, and the current branch is deleted. The parent branch is checked
+out. This is the inverse of av split.
+
+With --close-pr, the pull request of the folded branch is closed with a comment
+pointing at the pull request of the parent branch.
+
+Run av undo to restore the branches as they were before the fold. The closed
+pull request is not reopened.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		branch, exists := tx.Branch(currentBranch)
+		if !exists {
+			return actions.BranchNotAdoptedError{Branch: currentBranch}
+		}
+		if branch.Parent.Trunk {
+			return errors.Errorf(
+				"branch %q is based on the trunk branch %q and can't be folded",
+				currentBranch, branch.Parent.Name,
+			)
+		}
+		parent := branch.Parent.Name
+		if _, err := repo.Git("merge-base", "--is-ancestor", parent, currentBranch); err != nil {
+			return errors.Errorf(
+				"branch %q is not on top of its parent branch %q (run av sync or av restack first)",
+				currentBranch, parent,
+			)
+		}
+
+		if err := oplog.Record(repo, tx, "av fold"); err != nil {
+			return err
+		}
+		if err := foldBranch(repo, db, currentBranch, parent); err != nil {
+			return err
+		}
+
+		if foldFlags.ClosePR && branch.PullRequest != nil &&
+			branch.PullRequest.State == githubv4.PullRequestStateOpen {
+			if err := closeFoldedPullRequest(db, branch, parent); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// foldBranch moves the parent branch to the head of the branch, moves the children of the
+// branch onto the parent branch, and deletes the branch.
+func foldBranch(repo *git.Repo, db meta.DB, name string, parent string) (reterr error) {
+	tx := db.WriteTx()
+	cu := cleanup.New(func() {
+		logrus.WithError(reterr).Debug("aborting db transaction")
+		tx.Abort()
+	})
+	defer cu.Cleanup()
+
+	head, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + name})
+	if err != nil {
+		return err
+	}
+	// The parent branch is an ancestor of the branch, so this is a fast-forward and checking
+	// out the parent branch doesn't touch the working tree.
+	if err := repo.UpdateRef(&git.UpdateRef{
+		Ref:          "refs/heads/" + parent,
+		New:          head,
+		CreateReflog: true,
+	}); err != nil {
+		return err
+	}
+	if _, err := repo.CheckoutBranch(&git.CheckoutBranch{Name: parent}); err != nil {
+		return err
+	}
+	if err := repo.BranchDelete(name); err != nil {
+		return errors.WrapIff(err, "failed to delete branch %q", name)
+	}
+
+	children := meta.ChildrenNames(tx, name)
+	for _, childName := range children {
+		child, _ := tx.Branch(childName)
+		child.Parent.Name = parent
+		tx.SetBranch(child)
+	}
+	tx.DeleteBranch(name)
+	cu.Cancel()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stderr,
+		colors.Success("Folded branch "), colors.UserInput(name),
+		colors.Success(" into "), colors.UserInput(parent), colors.Success(".\n"),
+	)
+	for _, child := range children {
+		fmt.Fprint(os.Stderr,
+			"  - moved ", colors.UserInput(child), " onto ", colors.UserInput(parent), "\n",
+		)
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Faint("  - run "), colors.CliCmd("av pr --all"),
+		colors.Faint(" to push the branches and update the pull requests\n"),
+	)
+	return nil
+}
+
+// closeFoldedPullRequest closes the pull request of the folded branch with a comment pointing
+// at the pull request (or the branch) that it was folded into.
+func closeFoldedPullRequest(db meta.DB, folded meta.Branch, parent string) error {
+	tx := db.ReadTx()
+	comment := fmt.Sprintf("This pull request was folded into branch `%s`.", parent)
+	if parentBranch, ok := tx.Branch(parent); ok && parentBranch.PullRequest != nil {
+		comment = fmt.Sprintf(
+			"This pull request was folded into %s.", parentBranch.PullRequest.Permalink,
+		)
+	}
+	f, err := getForge(tx.Repository())
+	if err != nil {
+		return err
+	}
+	if _, err := f.ClosePullRequest(
+		context.Background(), folded.PullRequest.ID, comment,
+	); err != nil {
+		return errors.WrapIff(err, "failed to close pull request #%d", folded.PullRequest.Number)
+	}
+	fmt.Fprint(os.Stderr,
+		"Closed pull request ", colors.UserInput(folded.PullRequest.Permalink), ".\n",
+	)
+	return nil
+}
+
+func init() {
+	foldCmd.Flags().BoolVar(
+		&foldFlags.ClosePR, "close-pr", false,
+		"close the pull request of the folded branch with a comment pointing at the parent pull request",
+	)
+}
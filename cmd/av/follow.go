@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var followFlags struct {
+	Parent string
+}
+
+var followCmd = &cobra.Command{
+	Use:   "follow <branch> [--parent <trunk>]",
+	Short: "Track a remote branch owned by someone else to stack branches onto it",
+	Long: strings.TrimSpace(`
+Track a remote branch owned by someone else (e.g., a dependency update by
+Renovate or Dependabot) so that branches can be stacked onto it before it's
+merged.
+
+The local branch is created from the remote branch, and it's adopted to av on
+top of the trunk branch (the default branch, or the trunk branch given with
+--parent). A followed branch is never rebased or pushed by av, and no pull
+request is created or updated for it. Instead, av sync updates it to the remote
+branch (e.g., after the bot force-pushes it) and rebases its children onto it.
+
+When the pull request of the followed branch is merged, av sync moves its
+children onto the trunk branch and deletes it like the other merged branches.
+When the pull request is closed without merging, the children are moved onto
+the trunk branch without the commits of the followed branch.
+`),
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		name := stripRemoteRefPrefixes(repo, args[0])
+		if _, exists := db.ReadTx().Branch(name); exists {
+			return errors.Errorf("branch %q is already adopted to av", name)
+		}
+		if isTrunk, err := repo.IsTrunkBranch(name); err != nil {
+			return err
+		} else if isTrunk {
+			return errors.Errorf("cannot follow the trunk branch %q", name)
+		}
+		parent := followFlags.Parent
+		if parent == "" {
+			parent, err = repo.DefaultBranch()
+			if err != nil {
+				return errors.WrapIf(err, "failed to determine repository default branch")
+			}
+		} else if isTrunk, err := repo.IsTrunkBranch(parent); err != nil {
+			return err
+		} else if !isTrunk {
+			return errors.Errorf("the parent of a followed branch must be a trunk branch: %q", parent)
+		}
+
+		remote := repo.GetRemoteName()
+		remoteRef := git.RemoteTrackingRef(remote, name)
+		if _, err := repo.Git(
+			"fetch", remote, "refs/heads/"+name+":"+remoteRef,
+		); err != nil {
+			return errors.Errorf("branch %q is not found on %s", name, remote)
+		}
+		commit, err := repo.RevParse(&git.RevParse{Rev: remoteRef})
+		if err != nil {
+			return err
+		}
+
+		if err := oplog.Record(repo, db.ReadTx(), "av follow"); err != nil {
+			return err
+		}
+		if err := createFollowedBranch(repo, name, remote, commit); err != nil {
+			return err
+		}
+		tx := db.WriteTx()
+		cu := cleanup.New(func() { tx.Abort() })
+		defer cu.Cleanup()
+		tx.SetBranch(meta.Branch{
+			Name:     name,
+			Parent:   meta.BranchState{Name: parent, Trunk: true},
+			Followed: true,
+		})
+		cu.Cancel()
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		fmt.Fprint(os.Stderr,
+			colors.Success("Following branch "), colors.UserInput(name),
+			colors.Success(" at "), colors.UserInput(commit[:7]),
+			colors.Success(" from "), colors.UserInput(remote), colors.Success(".\n"),
+			colors.Faint("  - run "), colors.CliCmd("av branch --parent "+name+" <branch>"),
+			colors.Faint(" to stack a branch onto it\n"),
+		)
+		return nil
+	},
+}
+
+// createFollowedBranch creates the local branch of the followed branch at the commit of the
+// remote branch. An existing local branch is kept only if it's already at the commit, so that
+// no local changes are lost.
+func createFollowedBranch(repo *git.Repo, name, remote, commit string) error {
+	if exists, err := repo.DoesBranchExist(name); err != nil {
+		return err
+	} else if exists {
+		local, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + name})
+		if err != nil {
+			return err
+		}
+		if local != commit {
+			return errors.Errorf(
+				"local branch %q differs from %s/%s (reset or delete it first)",
+				name, remote, name,
+			)
+		}
+	} else if _, err := repo.Git("branch", name, commit); err != nil {
+		return errors.WrapIff(err, "failed to create branch %q", name)
+	}
+	// The followed branch is compared with the remote branch that it's followed from, even
+	// if the branches are pushed to another remote (e.g., a fork).
+	if repo.GetBranchPushRemoteName(name) != remote {
+		return repo.BranchSetPushRemote(name, remote)
+	}
+	return nil
+}
+
+func init() {
+	followCmd.Flags().StringVar(
+		&followFlags.Parent, "parent", "",
+		"the trunk branch that the followed branch is based on (default: the default branch)",
+	)
+	_ = followCmd.RegisterFlagCompletionFunc("parent", branchNameArgs)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var freezeBaseCmd = &cobra.Command{
+	Use:   "freeze-base [<commit>]",
+	Short: "Freeze the trunk commit that the current stack is based on",
+	Long: strings.TrimSpace(`
+Freeze the trunk commit that the current stack is based on.
+
+While the base is frozen, av sync rebases the stack onto the frozen commit instead
+of the latest trunk (e.g., to stabilize the stack against a release cut). The commit
+defaults to the current commit of the remote trunk branch. Use av unfreeze-base to
+follow the latest trunk again.
+`),
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rev := ""
+		if len(args) > 0 {
+			rev = args[0]
+		}
+		return setFrozenBase(rev, true)
+	},
+}
+
+var unfreezeBaseCmd = &cobra.Command{
+	Use:   "unfreeze-base",
+	Short: "Unfreeze the trunk commit frozen by av freeze-base",
+	Long: strings.TrimSpace(`
+Unfreeze the trunk commit of the current stack frozen by av freeze-base. The stack
+is rebased onto the latest trunk on the next av sync --rebase-to-trunk.
+`),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setFrozenBase("", false)
+	},
+}
+
+// stackFreezeBaseCmd is av freeze-base under the av stack command.
+var stackFreezeBaseCmd = &cobra.Command{
+	Use:   "freeze-base [<commit>]",
+	Short: "Freeze the trunk commit that the current stack is based on (same as av freeze-base)",
+	Long: strings.TrimSpace(`
+Freeze the trunk commit that the current stack is based on. This is the same
+command as av freeze-base.
+`),
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return freezeBaseCmd.RunE(cmd, args)
+	},
+}
+
+func setFrozenBase(rev string, freeze bool) error {
+	repo, err := getRepo()
+	if err != nil {
+		return err
+	}
+	db, err := getDB(repo)
+	if err != nil {
+		return err
+	}
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return errors.WrapIf(err, "failed to determine current branch")
+	}
+
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+
+	rootName, ok := meta.Root(tx, currentBranch)
+	if !ok {
+		return actions.BranchNotAdoptedError{Branch: currentBranch}
+	}
+	root, _ := tx.Branch(rootName)
+	if freeze {
+		if rev == "" {
+			rev = repo.RemoteBranchRef(root.Parent.Name)
+		}
+		commit, err := repo.RevParse(&git.RevParse{Rev: rev + "^{commit}"})
+		if err != nil {
+			return errors.Errorf("%q is not a commit", rev)
+		}
+		root.FrozenBase = commit
+		// Keep the name of a tag (or a commit SHA) to show what the stack is built on.
+		root.BaseRef = ""
+		if _, ok := resolveImmutableParent(repo, rev); ok {
+			root.BaseRef = rev
+		}
+	} else {
+		if root.FrozenBase == "" {
+			return errors.Errorf("the base of the stack of %q is not frozen", rootName)
+		}
+		root.FrozenBase = ""
+		root.BaseRef = ""
+	}
+	tx.SetBranch(root)
+	cu.Cancel()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if freeze {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Froze the base of the stack of "), colors.UserInput(rootName),
+			colors.Success(" at "), colors.UserInput(git.ShortSha(root.FrozenBase)),
+			colors.Success(".\n"),
+			colors.Faint("  - run "), colors.CliCmd("av sync"),
+			colors.Faint(" to rebase the stack onto the frozen base\n"),
+		)
+	} else {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Unfroze the base of the stack of "), colors.UserInput(rootName),
+			colors.Success(".\n"),
+			colors.Faint("  - run "), colors.CliCmd("av sync --rebase-to-trunk"),
+			colors.Faint(" to rebase the stack onto the latest trunk\n"),
+		)
+	}
+	return nil
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/completioncache"
+	"github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/meta/jsonfiledb"
 	"github.com/aviator-co/av/internal/meta/refmeta"
 	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -20,29 +25,35 @@ var cachedRepo *git.Repo
 
 func getRepo() (*git.Repo, error) {
 	if cachedRepo == nil {
-		cmd := exec.Command(
-			"git",
-			"rev-parse",
-			"--path-format=absolute",
-			"--show-toplevel",
-			"--git-common-dir",
-		)
-
-		if rootFlags.Directory != "" {
-			cmd.Dir = rootFlags.Directory
-		}
-		paths, err := cmd.Output()
+		paths, err := revParse("--is-bare-repository", "--git-common-dir")
 		if err != nil {
 			return nil, errors.Wrap(
 				err,
 				"failed to find git directory (are you running inside a Git repo?)",
 			)
 		}
-
-		dir, gitDir, found := strings.Cut(strings.TrimSpace(string(paths)), "\n")
-		if !found {
+		if len(paths) != 2 {
 			return nil, errors.New("Unexpected format, not able to parse toplevel and common dir.")
 		}
+		isBare, gitDir := paths[0] == "true", paths[1]
+
+		// A bare repository (e.g., one created by `git clone --bare` that is used with
+		// `git worktree add`) doesn't have a toplevel directory. Use the repository itself
+		// as the working directory so that commands that don't need a worktree still work.
+		dir := gitDir
+		if !isBare {
+			toplevel, err := revParse("--show-toplevel")
+			if err != nil {
+				return nil, errors.Wrap(
+					err,
+					"failed to find git directory (are you running inside a Git repo?)",
+				)
+			}
+			if len(toplevel) != 1 {
+				return nil, errors.New("Unexpected format, not able to parse toplevel and common dir.")
+			}
+			dir = toplevel[0]
+		}
 
 		cachedRepo, err = git.OpenRepo(dir, gitDir)
 		if err != nil {
@@ -52,6 +63,23 @@ func getRepo() (*git.Repo, error) {
 	return cachedRepo, nil
 }
 
+// revParse runs `git rev-parse` with absolute path output in the target directory and returns
+// the output lines.
+func revParse(args ...string) ([]string, error) {
+	cmd := exec.Command(
+		"git",
+		append([]string{"rev-parse", "--path-format=absolute"}, args...)...,
+	)
+	if rootFlags.Directory != "" {
+		cmd.Dir = rootFlags.Directory
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
 var ErrRepoNotInitialized = errors.Sentinel(
 	"this repository is not initialized; please run `av init`",
 )
@@ -87,7 +115,17 @@ func getOrCreateDB(repo *git.Repo) (meta.DB, bool, error) {
 	return jsonfiledb.OpenPath(dbPath)
 }
 
-func allBranches() ([]string, error) {
+// completionData returns the branches for the shell completion. They're read from the
+// completion cache if it's up to date, so that pressing TAB doesn't run Git. Otherwise, they're
+// read from the av database and the cache is rebuilt.
+func completionData() (*completioncache.Data, error) {
+	if config.Av.Completion.Cache {
+		if avDir, ok := completioncache.FindAvDir(workingDir()); ok {
+			if data, ok := completioncache.Read(avDir); ok {
+				return data, nil
+			}
+		}
+	}
 	repo, err := getRepo()
 	if err != nil {
 		return nil, err
@@ -96,20 +134,48 @@ func allBranches() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
-
 	defaultBranch, err := repo.DefaultBranch()
 	if err != nil {
 		return nil, err
 	}
+	data := completioncache.Build(db.ReadTx(), defaultBranch)
+	if config.Av.Completion.Cache {
+		if err := completioncache.Write(repo.AvDir(), data); err != nil {
+			logrus.WithError(err).Debug("failed to write the completion cache")
+		}
+	}
+	return data, nil
+}
 
-	tx := db.ReadTx()
-
-	var branches = []string{defaultBranch}
-	for b := range tx.AllBranches() {
-		branches = append(branches, b)
+// refreshCompletionCache rebuilds the completion cache after a command if the av database has
+// been changed since the cache was written.
+func refreshCompletionCache() {
+	if !config.Av.Completion.Cache || cachedRepo == nil {
+		return
+	}
+	avDir := cachedRepo.AvDir()
+	if _, err := os.Stat(filepath.Join(avDir, "av.db")); err != nil {
+		return
+	}
+	if _, ok := completioncache.Read(avDir); ok {
+		return
 	}
+	// The default branch can't be determined without the remote HEAD. Don't warn about it
+	// here since the command didn't need it.
+	if exists, err := cachedRepo.DoesRefExist(cachedRepo.RemoteBranchRef("HEAD")); err != nil || !exists {
+		return
+	}
+	if _, err := completionData(); err != nil {
+		logrus.WithError(err).Debug("failed to refresh the completion cache")
+	}
+}
 
-	return branches, nil
+// workingDir returns the directory that the command runs in (given with -C).
+func workingDir() string {
+	if rootFlags.Directory != "" {
+		return rootFlags.Directory
+	}
+	return "."
 }
 
 // stripRemoteRefPrefixes removes the "refs/heads/", "refs/remotes/<remote>/", "<remote>/" prefix
@@ -125,6 +191,28 @@ func stripRemoteRefPrefixes(repo *git.Repo, possibleRefName string) string {
 	return ret
 }
 
+// commitSHAPattern matches a full or abbreviated commit SHA.
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// resolveImmutableParent resolves a parent that is an immutable ref (a tag or a commit SHA)
+// instead of a branch. Such a parent is treated as a frozen trunk (see meta.Branch.BaseRef).
+// It returns the commit of the ref, or false if the parent is a branch or not an immutable ref.
+func resolveImmutableParent(repo *git.Repo, parent string) (string, bool) {
+	if exists, err := repo.DoesBranchExist(parent); err != nil || exists {
+		return "", false
+	}
+	if commit, err := repo.RevParse(&git.RevParse{Rev: "refs/tags/" + parent + "^{commit}"}); err == nil {
+		return commit, true
+	}
+	if !commitSHAPattern.MatchString(parent) {
+		return "", false
+	}
+	if commit, err := repo.RevParse(&git.RevParse{Rev: parent + "^{commit}"}); err == nil {
+		return commit, true
+	}
+	return "", false
+}
+
 // deprecateCommand will create a new version of the command that injects a deprecation message
 // into the command's short and long descriptions. As well as a pre-run hook that will print
 // a deprecation warning before running the command.
@@ -155,11 +243,66 @@ func deprecateCommand(
 	return &deprecatedCommand
 }
 
+// branchNameArgs completes the branch names tracked by av. They're read from the av database
+// (or the completion cache), not from Git, and each branch is described with its parent and its
+// pull request.
 func branchNameArgs(
 	_ *cobra.Command,
 	_ []string,
-	toComplete string,
+	_ string,
 ) ([]string, cobra.ShellCompDirective) {
-	branches, _ := allBranches()
-	return branches, cobra.ShellCompDirectiveNoSpace
+	data, err := completionData()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoSpace
+	}
+	completions := []string{data.DefaultBranch + "\ttrunk"}
+	for _, br := range data.Branches {
+		if desc := br.Description(); desc != "" {
+			completions = append(completions, br.Name+"\t"+desc)
+		} else {
+			completions = append(completions, br.Name)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoSpace
+}
+
+// branchNameArgAt completes the branch names for the positional argument at the given index
+// only (e.g., 1 for the parent branch of av branch <name> <parent>).
+func branchNameArgAt(
+	index int,
+) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != index {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return branchNameArgs(cmd, args, toComplete)
+	}
+}
+
+// readStdinBranches reads the branch names from the standard input, one per line (e.g., the
+// output of av query).
+func readStdinBranches() ([]string, error) {
+	var ret []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			ret = append(ret, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.WrapIf(err, "failed to read the branches from the standard input")
+	}
+	if len(ret) == 0 {
+		return nil, errors.New("no branches are given in the standard input")
+	}
+	return ret, nil
+}
+
+// addStdinBranchesFlag adds the --stdin flag that makes the command operate on the branches
+// read from the standard input (see readStdinBranches).
+func addStdinBranchesFlag(cmd *cobra.Command, p *bool) {
+	cmd.Flags().BoolVar(
+		p, "stdin", false,
+		"read the branches from the standard input, one per line (e.g., the output of av query)",
+	)
 }
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/hooks"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+)
+
+// branchHookEnv returns the hook environment of the branch from the av metadata. The branch
+// name is empty if HEAD is detached.
+func branchHookEnv(tx meta.ReadTx, branchName string) hooks.Env {
+	env := hooks.Env{Branch: branchName}
+	if branchName == "" {
+		return env
+	}
+	if br, ok := tx.Branch(branchName); ok {
+		env.Parent = br.Parent.Name
+		if br.PullRequest != nil {
+			env.PullRequestNumber = br.PullRequest.Number
+			env.PullRequestURL = br.PullRequest.Permalink
+		}
+	}
+	return env
+}
+
+// runPostHook runs the hooks of a post-event. The event has already happened, so a failing
+// hook is reported as a warning instead of failing the command.
+func runPostHook(repo *git.Repo, event hooks.Event, env hooks.Env) {
+	if err := hooks.Run(repo, event, env); err != nil {
+		fmt.Fprint(os.Stderr, colors.Warning(err.Error()+"\n"))
+	}
+}
+
+// runPreRestackHook runs the pre-restack hooks for the current branch.
+func runPreRestackHook(repo *git.Repo, tx meta.ReadTx) error {
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil && !errors.Is(err, git.ErrDetachedHEAD) {
+		return err
+	}
+	return hooks.Run(repo, hooks.PreRestack, branchHookEnv(tx, currentBranch))
+}
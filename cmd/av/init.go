@@ -3,17 +3,40 @@ package main
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/cleanup"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var initFlags struct {
+	Remote     string
+	PushRemote string
+}
+
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize the repository for Aviator CLI",
-	Args:  cobra.NoArgs,
+	Long: strings.TrimSpace(`
+Initialize the repository for Aviator CLI.
+
+If the repository has more than one remote, use --remote to choose the remote to
+fetch from and open the pull requests against (origin by default). This sets
+Git's av.remote, which the remote config overrides.
+
+If you can't push branches to the repository (e.g., an open source project), use
+--push-remote to push the branches to your fork instead. The pull requests are
+opened against the repository of the remote (origin by default, see the remote
+config). This sets Git's remote.pushDefault.
+`),
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) (reterr error) {
 		repo, err := getRepo()
 		if err != nil {
@@ -31,32 +54,103 @@ var initCmd = &cobra.Command{
 		})
 		defer cu.Cleanup()
 
-		client, err := getGitHubClient()
-		if err != nil {
-			return err
+		if initFlags.Remote != "" {
+			if _, err := repo.RemoteOrigin(initFlags.Remote); err != nil {
+				return errors.WrapIff(err, "failed to get the URL of remote %q", initFlags.Remote)
+			}
+			if err := repo.SetRemoteName(initFlags.Remote); err != nil {
+				return err
+			}
+		}
+		// The pull requests are opened against the repository of the remote, or the
+		// canonical repository of a mirror.
+		var origin *git.Origin
+		if config.Av.Mirror.CanonicalURL != "" {
+			origin, err = repo.Origin()
+		} else {
+			origin, err = repo.RemoteOrigin(repo.GetRemoteName())
 		}
-
-		origin, err := repo.Origin()
 		if err != nil {
 			return err
 		}
+		if initFlags.PushRemote != "" {
+			if _, err := repo.RemoteOrigin(initFlags.PushRemote); err != nil {
+				return errors.WrapIff(err, "failed to get the URL of remote %q", initFlags.PushRemote)
+			}
+		}
 
-		ghRepo, err := client.GetRepositoryBySlug(context.Background(), origin.RepoSlug)
+		var repoMeta meta.Repository
+		if config.Av.Forge == config.ForgeGitLab {
+			repoMeta, err = getGitLabRepository(origin.RepoSlug)
+		} else {
+			repoMeta, err = getGitHubRepository(origin.RepoSlug)
+		}
 		if err != nil {
 			return err
 		}
-
-		tx.SetRepository(meta.Repository{
-			ID:    ghRepo.ID,
-			Owner: ghRepo.Owner.Login,
-			Name:  ghRepo.Name,
-		})
+		tx.SetRepository(repoMeta)
 
 		cu.Cancel()
 		if err := tx.Commit(); err != nil {
 			return err
 		}
+		if initFlags.PushRemote != "" {
+			if err := repo.SetPushDefault(initFlags.PushRemote); err != nil {
+				return err
+			}
+		}
+		if initFlags.Remote != "" || initFlags.PushRemote != "" {
+			fmt.Printf(
+				"Branches are fetched from %s and pushed to %s, and pull requests are opened against %s.\n",
+				repo.GetRemoteName(), repo.GetPushRemoteName(), origin.RepoSlug,
+			)
+		}
 		fmt.Println("Successfully initialized repository for use with av!")
 		return nil
 	},
 }
+
+func init() {
+	initCmd.Flags().StringVar(
+		&initFlags.Remote, "remote", "",
+		"the remote to fetch from and open the pull requests against (default: origin)",
+	)
+	initCmd.Flags().StringVar(
+		&initFlags.PushRemote, "push-remote", "",
+		"the remote to push the branches to (e.g., your fork of the repository)",
+	)
+}
+
+func getGitHubRepository(slug string) (meta.Repository, error) {
+	client, err := getGitHubClient()
+	if err != nil {
+		return meta.Repository{}, err
+	}
+	ghRepo, err := client.GetRepositoryBySlug(context.Background(), slug)
+	if err != nil {
+		return meta.Repository{}, err
+	}
+	return meta.Repository{
+		ID:    ghRepo.ID,
+		Owner: ghRepo.Owner.Login,
+		Name:  ghRepo.Name,
+	}, nil
+}
+
+// getGitLabRepository returns the repository metadata of the GitLab project. The project path
+// can contain subgroups (e.g., my-group/my-subgroup/my-project).
+func getGitLabRepository(projectPath string) (meta.Repository, error) {
+	f, err := forge.NewGitLab(config.Av.GitLab.BaseURL, config.Av.GitLab.Token, projectPath)
+	if err != nil {
+		return meta.Repository{}, err
+	}
+	project, err := f.Project(context.Background())
+	if err != nil {
+		return meta.Repository{}, err
+	}
+	return meta.Repository{
+		ID:    strconv.FormatInt(project.ID, 10),
+		Owner: project.Namespace.FullPath,
+		Name:  project.Path,
+	}, nil
+}
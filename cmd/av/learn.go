@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/meta/jsonfiledb"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+	"github.com/google/shlex"
+	"github.com/spf13/cobra"
+)
+
+var learnFlags struct {
+	Keep bool
+}
+
+var learnCmd = &cobra.Command{
+	Use:   "learn",
+	Short: "Learn how to use av with an interactive tutorial",
+	Long: strings.TrimSpace(`
+Learn how to use av with an interactive tutorial.
+
+The tutorial creates a throwaway repository in a temporary directory and walks
+through the basic workflow of stacked pull requests: creating branches, making
+commits, updating a branch in the middle of a stack, previewing the pull
+requests, and reordering the stack. Each step checks the repository to make
+sure that the step was done as expected.
+
+Nothing is sent to GitHub, and your repositories are not touched. The throwaway
+repository is deleted at the end unless the --keep flag is given.
+`),
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if err := uiutils.RequireInteractive("the tutorial", ""); err != nil {
+			return err
+		}
+		dir, err := os.MkdirTemp("", "av-learn-")
+		if err != nil {
+			return errors.WrapIf(err, "failed to create a temporary directory")
+		}
+		if learnFlags.Keep {
+			defer fmt.Fprint(os.Stderr,
+				"\nThe tutorial repository is kept at ", colors.UserInput(dir), "\n",
+			)
+		} else {
+			defer os.RemoveAll(dir)
+		}
+
+		repo, err := createLearnRepo(dir)
+		if err != nil {
+			return err
+		}
+		env, err := learnEnv()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(os.Stderr,
+			colors.Success("Welcome to av!"), "\n\n",
+			"This tutorial walks you through the stacked pull request workflow in a\n",
+			"throwaway repository at ", colors.UserInput(repo.Dir()), ".\n\n",
+			"At each step, type the command to run, or press Enter to run the suggested\n",
+			"command. Type ", colors.CliCmd("skip"), " to skip a step or ",
+			colors.CliCmd("quit"), " to quit the tutorial.\n",
+		)
+
+		stdin := bufio.NewReader(os.Stdin)
+		for i, step := range learnSteps {
+			fmt.Fprint(os.Stderr,
+				"\n", colors.Faint(fmt.Sprintf("[%d/%d] ", i+1, len(learnSteps))),
+				colors.Success(step.title), "\n\n",
+				step.description, "\n",
+			)
+			if step.prepare != nil {
+				if err := step.prepare(repo); err != nil {
+					return err
+				}
+			}
+			skipped, err := runLearnStep(repo, env, stdin, step)
+			if errors.Is(err, errLearnQuit) {
+				fmt.Fprint(os.Stderr, "\nQuitting the tutorial. Run ", colors.CliCmd("av learn"),
+					" to start over.\n")
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if !skipped {
+				fmt.Fprint(os.Stderr, colors.Success("\n✓ Well done!\n"))
+			}
+		}
+
+		fmt.Fprint(os.Stderr,
+			"\n", colors.Success("You've completed the tutorial!"), "\n\n",
+			"To start using av in your repository, run ", colors.CliCmd("av init"),
+			" in it.\nSee ", colors.CliCmd("av --help"), " for the other commands.\n",
+		)
+		return nil
+	},
+}
+
+var errLearnQuit = errors.Sentinel("quit the tutorial")
+
+type learnStep struct {
+	title       string
+	description string
+	// The command suggested to the user.
+	command string
+	// Called before the step is shown to set up the repository (e.g., to write files).
+	prepare func(repo *git.Repo) error
+	// Called after the command is run. Returns an error that explains what's missing if the
+	// step is not done yet.
+	check func(repo *git.Repo, tx meta.ReadTx) error
+}
+
+var learnSteps = []learnStep{
+	{
+		title: "Create a branch",
+		description: strings.TrimSpace(`
+Every pull request in a stack has its own branch. av remembers the parent of
+each branch, so that it can keep the stack up to date. Create a new branch on
+top of main.`),
+		command: "av branch feature-1",
+		check: func(repo *git.Repo, tx meta.ReadTx) error {
+			return checkLearnBranch(repo, tx, "feature-1", "main")
+		},
+	},
+	{
+		title: "Make a commit",
+		description: strings.TrimSpace(`
+We've written a new file feature-1.txt for you. Commit it to the branch.`),
+		command: `av commit -A -m "Add feature 1"`,
+		prepare: func(repo *git.Repo) error {
+			return writeLearnFile(repo, "feature-1.txt", "Feature 1\n")
+		},
+		check: func(repo *git.Repo, _ meta.ReadTx) error {
+			return checkLearnCommitted(repo, "feature-1", "feature-1.txt")
+		},
+	},
+	{
+		title: "Stack another branch",
+		description: strings.TrimSpace(`
+Now build the next change on top of feature-1 without waiting for it to be
+reviewed. Create a branch and commit the new file feature-2.txt in one go.`),
+		command: `av commit -A -m "Add feature 2" --branch-name feature-2`,
+		prepare: func(repo *git.Repo) error {
+			return writeLearnFile(repo, "feature-2.txt", "Feature 2\n")
+		},
+		check: func(repo *git.Repo, tx meta.ReadTx) error {
+			if err := checkLearnBranch(repo, tx, "feature-2", "feature-1"); err != nil {
+				return err
+			}
+			return checkLearnCommitted(repo, "feature-2", "feature-2.txt")
+		},
+	},
+	{
+		title: "Look at the stack",
+		description: strings.TrimSpace(`
+The tree shows the branches in the stack and where you are.`),
+		command: "av tree",
+	},
+	{
+		title: "Go back to the first branch",
+		description: strings.TrimSpace(`
+Reviewers asked for a change in feature-1. Move down the stack to the previous
+branch.`),
+		command: "av prev",
+		check: func(repo *git.Repo, _ meta.ReadTx) error {
+			return checkLearnCurrentBranch(repo, "feature-1")
+		},
+	},
+	{
+		title: "Amend the branch",
+		description: strings.TrimSpace(`
+We've updated feature-1.txt for you. Amend the commit. av rebases feature-2 onto
+the amended commit automatically, so the stack stays consistent.`),
+		command: "av commit -a --amend",
+		prepare: func(repo *git.Repo) error {
+			return writeLearnFile(repo, "feature-1.txt", "Feature 1 (reviewed)\n")
+		},
+		check: func(repo *git.Repo, _ meta.ReadTx) error {
+			if err := checkLearnCommitted(repo, "feature-1", "feature-1.txt"); err != nil {
+				return err
+			}
+			if _, err := repo.Git("merge-base", "--is-ancestor", "feature-1", "feature-2"); err != nil {
+				return errors.New(
+					"feature-2 is not based on the amended feature-1 yet (run 'av restack')",
+				)
+			}
+			return nil
+		},
+	},
+	{
+		title: "Preview the pull requests",
+		description: strings.TrimSpace(`
+When the stack is ready, 'av pr --all' creates a pull request for every branch,
+each based on its parent branch. Since this repository is not on GitHub, preview
+the pull requests instead.`),
+		command: "av pr --all --dry-run",
+	},
+	{
+		title: "Reorder the stack",
+		description: strings.TrimSpace(`
+Stacks can be rearranged. 'av reorder' opens an editor with the plan of the
+stack. Move the lines to change the order of the commits and the branches, and
+save the file. Closing the editor without changes keeps the stack as is.`),
+		command: "av reorder",
+	},
+}
+
+// runLearnStep prompts the user to run the command of the step until the step's check passes.
+// It returns true if the user skipped the step.
+func runLearnStep(repo *git.Repo, env []string, stdin *bufio.Reader, step learnStep) (bool, error) {
+	for {
+		fmt.Fprint(os.Stderr,
+			"\nSuggested command: ", colors.CliCmd(step.command), "\n",
+			colors.UserInput("$ "),
+		)
+		line, err := stdin.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, err
+		}
+		input := strings.TrimSpace(line)
+		if errors.Is(err, io.EOF) && input == "" {
+			input = "quit"
+		}
+		switch input {
+		case "":
+			input = step.command
+			fmt.Fprint(os.Stderr, colors.Faint(input), "\n")
+		case "skip":
+			return true, nil
+		case "quit", "exit":
+			return false, errLearnQuit
+		}
+
+		cmd, err := learnCommand(input)
+		if err != nil {
+			fmt.Fprint(os.Stderr, colors.Failure(err.Error(), "\n"))
+			continue
+		}
+		cmd.Dir = repo.Dir()
+		cmd.Env = env
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				return false, errors.WrapIff(err, "failed to run %q", input)
+			}
+		}
+
+		if step.check == nil {
+			return false, nil
+		}
+		db, _, err := jsonfiledb.OpenPath(filepath.Join(repo.AvDir(), "av.db"))
+		if err != nil {
+			return false, err
+		}
+		if err := step.check(repo, db.ReadTx()); err != nil {
+			fmt.Fprint(os.Stderr, colors.Failure("\nNot quite: ", err.Error(), "\n"))
+			continue
+		}
+		return false, nil
+	}
+}
+
+// createLearnRepo creates a repository for the tutorial with a local bare repository as the
+// remote, so that no command talks to GitHub.
+func createLearnRepo(dir string) (*git.Repo, error) {
+	repoDir := filepath.Join(dir, "repo")
+	remoteDir := filepath.Join(dir, "remote.git")
+	for _, args := range [][]string{
+		{"init", "--quiet", "--initial-branch=main", repoDir},
+		{"init", "--quiet", "--bare", remoteDir},
+	} {
+		if out, err := git.Command("", args...).CombinedOutput(); err != nil {
+			return nil, errors.WrapIff(err, "failed to create the tutorial repository: %s", out)
+		}
+	}
+	repo, err := git.OpenRepo(repoDir, filepath.Join(repoDir, ".git"))
+	if err != nil {
+		return nil, err
+	}
+	if err := writeLearnFile(repo, "README.md", "# av tutorial\n"); err != nil {
+		return nil, err
+	}
+	for _, args := range [][]string{
+		{"config", "user.name", "av tutorial"},
+		{"config", "user.email", "av-tutorial@example.com"},
+		{"remote", "add", "origin", remoteDir, "--master=main"},
+		{"add", "README.md"},
+		{"commit", "--quiet", "-m", "Initial commit"},
+		{"push", "--quiet", "origin", "main"},
+	} {
+		if _, err := repo.Git(args...); err != nil {
+			return nil, errors.WrapIf(err, "failed to create the tutorial repository")
+		}
+	}
+
+	db, _, err := jsonfiledb.OpenPath(filepath.Join(repo.AvDir(), "av.db"))
+	if err != nil {
+		return nil, err
+	}
+	tx := db.WriteTx()
+	tx.SetRepository(meta.Repository{
+		ID:    "R_av_learn_",
+		Owner: "av-learn",
+		Name:  "tutorial",
+	})
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// learnEnv returns the environment for the commands run in the tutorial. The directory of the
+// running av binary is prepended to PATH so that "av" refers to this binary.
+func learnEnv() ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to find the av executable")
+	}
+	path := filepath.Dir(exe) + string(os.PathListSeparator) + os.Getenv("PATH")
+	return append(os.Environ(), "PATH="+path), nil
+}
+
+// learnCommand returns the command to run for the input of a step. The input is split into the
+// arguments with the shell quoting rules, but it's run without a shell so that the tutorial
+// works on Windows as well (pipes and redirections are not supported). "av" runs the running av
+// binary.
+func learnCommand(input string) (*exec.Cmd, error) {
+	args, err := shlex.Split(input)
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to parse the command %q", input)
+	}
+	if len(args) == 0 {
+		return nil, errors.Errorf("failed to parse the command %q", input)
+	}
+	if args[0] == "av" {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, errors.WrapIf(err, "failed to find the av executable")
+		}
+		args[0] = exe
+	}
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+func writeLearnFile(repo *git.Repo, name, content string) error {
+	return os.WriteFile(filepath.Join(repo.Dir(), name), []byte(content), 0644)
+}
+
+func checkLearnCurrentBranch(repo *git.Repo, name string) error {
+	current, err := repo.CurrentBranchName()
+	if err != nil {
+		return err
+	}
+	if current != name {
+		return errors.Errorf("the current branch is %q, not %q", current, name)
+	}
+	return nil
+}
+
+func checkLearnBranch(repo *git.Repo, tx meta.ReadTx, name, parent string) error {
+	branch, ok := tx.Branch(name)
+	if !ok {
+		return errors.Errorf("branch %q has not been created with av yet", name)
+	}
+	if branch.Parent.Name != parent {
+		return errors.Errorf("the parent of %q is %q, not %q", name, branch.Parent.Name, parent)
+	}
+	return checkLearnCurrentBranch(repo, name)
+}
+
+func checkLearnCommitted(repo *git.Repo, branch, file string) error {
+	if status, err := repo.Git("status", "--porcelain", "--", file); err != nil {
+		return err
+	} else if strings.TrimSpace(status) != "" {
+		return errors.Errorf("the changes to %s are not committed yet", file)
+	}
+	if _, err := repo.Git("cat-file", "-e", branch+":"+file); err != nil {
+		return errors.Errorf("%s is not committed to %q yet", file, branch)
+	}
+	return nil
+}
+
+func init() {
+	learnCmd.Flags().BoolVar(
+		&learnFlags.Keep, "keep", false,
+		"keep the tutorial repository after the tutorial",
+	)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLearnCommand(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+
+	for input, want := range map[string][]string{
+		`av commit -A -m "Add feature 1"`: {exe, "commit", "-A", "-m", "Add feature 1"},
+		`git log --format='%s %an'`:       {"git", "log", "--format=%s %an"},
+		`  git   status  `:                {"git", "status"},
+	} {
+		cmd, err := learnCommand(input)
+		require.NoError(t, err, input)
+		require.Equal(t, want, cmd.Args, input)
+	}
+
+	for _, input := range []string{"", `av commit -m "unterminated`} {
+		_, err := learnCommand(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestLearnRequiresInteractive(t *testing.T) {
+	config.NonInteractive = true
+	defer func() { config.NonInteractive = false }()
+
+	err := learnCmd.RunE(learnCmd, nil)
+	require.Error(t, err)
+	class, ok := errutils.ClassOf(err)
+	require.True(t, ok)
+	require.Equal(t, errutils.FailureInteractionRequired, class)
+}
+
+func TestRunLearnStep(t *testing.T) {
+	repo, err := createLearnRepo(t.TempDir())
+	require.NoError(t, err)
+	step := learnStep{
+		title:   "Create a branch",
+		command: "git checkout -b feature-1",
+		check: func(repo *git.Repo, _ meta.ReadTx) error {
+			return checkLearnCurrentBranch(repo, "feature-1")
+		},
+	}
+	run := func(input string) (bool, error) {
+		return runLearnStep(repo, os.Environ(), bufio.NewReader(strings.NewReader(input)), step)
+	}
+
+	// The step is repeated until the check passes. An empty input runs the suggested command.
+	skipped, err := run("git status\n\n")
+	require.NoError(t, err)
+	require.False(t, skipped)
+	require.NoError(t, checkLearnCurrentBranch(repo, "feature-1"))
+
+	skipped, err = run("skip\n")
+	require.NoError(t, err)
+	require.True(t, skipped)
+
+	// The end of the input quits the tutorial.
+	_, err = run("quit\n")
+	require.ErrorIs(t, err, errLearnQuit)
+	_, err = run("")
+	require.ErrorIs(t, err, errLearnQuit)
+}
@@ -1,20 +1,24 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/completioncache"
 	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/debugbundle"
+	"github.com/aviator-co/av/internal/forge"
 	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/aviator-co/av/internal/utils/progress"
 	"github.com/fatih/color"
 	"github.com/kr/text"
 	"github.com/sirupsen/logrus"
@@ -25,8 +29,17 @@ import (
 var rootFlags struct {
 	Debug     bool
 	Directory string
+	Progress  string
+	JSON      bool
+	Output    string
+	Offline   bool
+	// If true, never open an editor or a prompt (see config.NonInteractive).
+	NonInteractive bool
 }
 
+// The trace of the current command that is included in av debug bundle.
+var commandTrace *debugbundle.Trace
+
 var rootCmd = &cobra.Command{
 	Use: "av",
 
@@ -35,26 +48,56 @@ var rootCmd = &cobra.Command{
 	SilenceErrors: true,
 	SilenceUsage:  true,
 
-	// Don't show "completion" command in help menu
-	CompletionOptions: cobra.CompletionOptions{
-		HiddenDefaultCmd: true,
-	},
-
 	// Run setup before invoking any child commands.
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		config.NonInteractive = rootFlags.NonInteractive || os.Getenv("AV_NON_INTERACTIVE") == "1"
 		if rootFlags.Debug {
 			logrus.SetLevel(logrus.DebugLevel)
 			logrus.WithField("av_version", config.Version).Debug("enabled debug logging")
 		}
+		switch rootFlags.Progress {
+		case "":
+		case "json":
+			progress.SetOutput(os.Stderr)
+		default:
+			return errors.Errorf("invalid --progress value %q (expected \"json\")", rootFlags.Progress)
+		}
+		switch rootFlags.Output {
+		case outputText, outputJSON:
+		default:
+			return errors.Errorf(
+				"invalid --output value %q (expected %q or %q)",
+				rootFlags.Output, outputText, outputJSON,
+			)
+		}
 
 		repoConfigDir := ""
-		repo, err := getRepo()
+		if cmd.Name() == cobra.ShellCompRequestCmd {
+			// Pressing TAB shouldn't run Git (see completionData), so the repository config is
+			// found without it if possible.
+			if avDir, ok := completioncache.FindAvDir(workingDir()); ok {
+				if err := config.Load(avDir); err != nil {
+					return errors.Wrap(err, "failed to load configuration")
+				}
+				return nil
+			}
+		}
+		var repo *git.Repo
+		var err error
+		if cmd == statusCmd {
+			// av status is run from the shell prompts, so the config is read without running
+			// Git if possible, and the command trace and the forge detection are skipped.
+			repoConfigDir, _ = completioncache.FindAvDir(workingDir())
+		}
+		if repoConfigDir == "" {
+			repo, err = getRepo()
+		}
 		// If we weren't able to load the Git repo, that probably just means the
 		// command isn't being run from inside a repo. That's fine, we just
 		// don't need to bother reading repo-local config.
 		if err != nil {
 			logrus.WithError(err).Debug("unable to load Git repo (probably not inside a repo)")
-		} else {
+		} else if repo != nil {
 			gitCommonDir, err := repo.Git("rev-parse", "--git-common-dir")
 			if err != nil {
 				logrus.WithError(err).Warning("failed to determine $GIT_COMMON_DIR")
@@ -65,6 +108,14 @@ var rootCmd = &cobra.Command{
 				} else {
 					logrus.WithField("git_common_dir", gitCommonDir).Debug("loaded Git repo")
 					repoConfigDir = filepath.Join(gitCommonDir, "av")
+					// The traces are recorded only when the user reproduces a problem with
+					// --debug for a debug bundle.
+					if rootFlags.Debug && cmd.Parent() != debugCmd {
+						commandTrace, err = debugbundle.StartTrace(repoConfigDir, os.Args[1:], cmd.Flags())
+						if err != nil {
+							logrus.WithError(err).Debug("failed to start the command trace")
+						}
+					}
 				}
 			}
 		}
@@ -77,6 +128,16 @@ var rootCmd = &cobra.Command{
 		if err := config.LoadUserState(); err != nil {
 			return errors.Wrap(err, "failed to load the user state")
 		}
+		if err := setupColorTheme(); err != nil {
+			return errors.Wrap(err, "failed to load configuration")
+		}
+		if repo != nil && !config.ForgeConfigured() {
+			// Map the host of the remote (e.g., a GitHub Enterprise Server instance) to its
+			// forge. The unknown hosts keep the default forge.
+			if origin, err := repo.Origin(); err == nil && origin.Forge != "" {
+				config.Av.Forge = origin.Forge
+			}
+		}
 		return nil
 	},
 }
@@ -90,59 +151,150 @@ func init() {
 		&rootFlags.Directory, "repo", "C", "",
 		"directory to use for git repository",
 	)
+	rootCmd.PersistentFlags().StringVar(
+		&rootFlags.Progress, "progress", "",
+		"emit machine-readable progress events to stderr (\"json\": one JSON object per line)",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&rootFlags.Output, "output", outputText,
+		"the output format of the read commands (e.g., av tree): \"text\" or \"json\"",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&rootFlags.JSON, "json", false,
+		"print the output of the read commands in JSON (same as --output=json)",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&rootFlags.Offline, "offline", false,
+		"do not reach GitHub or GitLab, and show the cached pull request state instead",
+	)
+	rootCmd.PersistentFlags().BoolVar(
+		&rootFlags.NonInteractive, "non-interactive", false,
+		"never open an editor or a prompt, and fail if a command needs the user's input\n(e.g., in CI)",
+	)
 	rootCmd.AddCommand(
+		abandonCmd,
 		adoptCmd,
 		authCmd,
 		branchCmd,
 		branchMetaCmd,
 		commitCmd,
+		completionCmd,
+		debugCmd,
 		diffCmd,
+		doctorCmd,
+		editCommitsCmd,
 		fetchCmd,
+		foldCmd,
+		followCmd,
+		freezeBaseCmd,
 		initCmd,
+		learnCmd,
 		nextCmd,
+		notesCmd,
 		orphanCmd,
+		pinCmd,
 		prCmd,
 		prevCmd,
+		previewCmd,
+		pruneCmd,
+		queryCmd,
 		reorderCmd,
 		reparentCmd,
+		renumberCmd,
+		reportCmd,
+		revertCmd,
+		seriesCmd,
+		splitCmd,
 		splitCommitCmd,
 		stackCmd,
+		statusCmd,
 		switchCmd,
 		syncCmd,
 		restackCmd,
 		tidyCmd,
 		treeCmd,
+		undoCmd,
+		unfreezeBaseCmd,
+		unpinCmd,
 		versionCmd,
+		whichCmd,
+		workspaceCmd,
+		worktreeCmd,
 	)
 }
 
+// setupColorTheme applies the color theme and the color profile of the ui config.
+func setupColorTheme() error {
+	if err := colors.SetColorProfile(config.Av.UI.ColorProfile); err != nil {
+		return errors.WrapIf(err, "invalid ui.colorProfile config")
+	}
+	theme, err := colors.LookupTheme(config.Av.UI.Theme)
+	if err != nil {
+		return errors.WrapIf(err, "invalid ui.theme config")
+	}
+	for role, c := range config.Av.UI.Colors {
+		if err := theme.Override(role, c); err != nil {
+			return errors.WrapIf(err, "invalid ui.colors config")
+		}
+	}
+	colors.SetTheme(theme)
+	return nil
+}
+
 func main() {
 	// Note: this doesn't include whatever time is spent in initializing the
 	// runtime and various packages (e.g., package init functions).
 	startTime := time.Now()
 	colors.SetupBackgroundColorTypeFromEnv()
+	addExtensionCommands()
 	err := rootCmd.Execute()
 	logrus.WithField("duration", time.Since(startTime)).Debug("command exited")
+	refreshCompletionCache()
 	checkCliVersion()
+	if err == nil {
+		commandTrace.Finish(0, nil)
+		return
+	}
+	class := failureClassOf(err)
+	exitCode := class.ExitCode
 	var exitSilently actions.ErrExitSilently
 	if errors.As(err, &exitSilently) {
-		os.Exit(exitSilently.ExitCode)
-	}
-	if err != nil {
-		// In debug mode, show more detailed information about the error
-		// (including the stack trace if using pkg/errors).
-		if rootFlags.Debug {
-			stackTrace := fmt.Sprintf("%+v", err)
-			fmt.Fprintf(os.Stderr, "error: %s\n%s\n", err, text.Indent(stackTrace, "\t"))
-		} else {
-			fmt.Fprint(os.Stderr, renderError(err))
+		if class == errutils.FailureGeneric {
+			exitCode = exitSilently.ExitCode
 		}
-
-		os.Exit(1)
+		commandTrace.Finish(exitCode, nil)
+		if jsonOutput() {
+			printJSONError(err, class, exitCode)
+		}
+		os.Exit(exitCode)
+	}
+	commandTrace.Finish(exitCode, err)
+	if jsonOutput() {
+		printJSONError(err, class, exitCode)
+		os.Exit(exitCode)
+	}
+	// In debug mode, show more detailed information about the error
+	// (including the stack trace if using pkg/errors).
+	if rootFlags.Debug {
+		stackTrace := fmt.Sprintf("%+v", err)
+		fmt.Fprintf(os.Stderr, "error: %s\n%s\n", err, text.Indent(stackTrace, "\t"))
+	} else {
+		fmt.Fprint(os.Stderr, renderError(err))
 	}
+	if errors.Is(err, git.ErrDetachedHEAD) {
+		printDetachedHEADHelp()
+	}
+	os.Exit(exitCode)
 }
 
+// skipCliVersionCheck is set by the commands that must not access the network (e.g., av status
+// run from the shell prompts).
+var skipCliVersionCheck bool
+
 func checkCliVersion() {
+	if skipCliVersionCheck {
+		return
+	}
 	if config.Version == config.VersionDev {
 		logrus.Debug("Skipping CLI version check (development version)")
 		return
@@ -179,30 +331,62 @@ func checkCliVersion() {
 var once sync.Once
 var lazyGithubClient *gh.Client
 
+// The error of creating lazyGithubClient, returned on every call of getGitHubClient.
+var lazyGithubClientErr error
+
 func discoverGitHubAPIToken() string {
-	if config.Av.GitHub.Token != "" {
-		return config.Av.GitHub.Token
-	}
-	if ghCli, err := exec.LookPath("gh"); err == nil {
-		var stdout bytes.Buffer
-		cmd := exec.Command(ghCli, "auth", "token")
-		cmd.Stdout = &stdout
-		cmd.Stderr = nil
-		if err := cmd.Run(); err == nil {
-			return strings.TrimSpace(stdout.String())
-		}
-	}
-	return ""
+	return gh.DiscoverCredential().Token
 }
 
+// errOffline is returned when a command needs to reach the forge with --offline.
+var errOffline = errors.New("cannot reach the code hosting service with --offline")
+
 func getGitHubClient() (*gh.Client, error) {
+	if rootFlags.Offline {
+		return nil, errOffline
+	}
+	if config.Av.Forge != config.ForgeGitHub {
+		return nil, errors.Errorf(
+			"this command is only supported for GitHub repositories (the forge is %q)",
+			config.Av.Forge,
+		)
+	}
+	if config.Av.GitHub.Transport == config.GitHubTransportGhCLI {
+		once.Do(func() {
+			lazyGithubClient, lazyGithubClientErr = gh.NewGhCLIClient()
+		})
+		return lazyGithubClient, lazyGithubClientErr
+	}
 	token := discoverGitHubAPIToken()
 	if token == "" {
 		return nil, errNoGitHubToken
 	}
-	var err error
 	once.Do(func() {
-		lazyGithubClient, err = gh.NewClient(token)
+		lazyGithubClient, lazyGithubClientErr = gh.NewClient(token)
 	})
-	return lazyGithubClient, err
+	return lazyGithubClient, lazyGithubClientErr
+}
+
+// getForge returns the forge (GitHub or GitLab) that hosts the pull requests of the repository.
+// The forge is selected with the forge config.
+func getForge(repoMeta meta.Repository) (forge.Forge, error) {
+	if rootFlags.Offline {
+		return nil, errOffline
+	}
+	if config.Av.Forge == config.ForgeGitLab {
+		f, err := forge.NewGitLab(
+			config.Av.GitLab.BaseURL,
+			config.Av.GitLab.Token,
+			repoMeta.Owner+"/"+repoMeta.Name,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	client, err := getGitHubClient()
+	if err != nil {
+		return nil, err
+	}
+	return forge.NewGitHub(client, repoMeta), nil
 }
@@ -64,7 +64,10 @@ type stackNextModel struct {
 	selection   *selection.Model[string]
 	lastInStack bool
 	nInStack    int
-	err         error
+	// The number of branches moved up the stack so far.
+	steps   int
+	message string
+	err     error
 }
 
 func newNextModel(lastInStack bool, nInStack int) (stackNextModel, error) {
@@ -115,6 +118,8 @@ func (m stackNextModel) checkoutCurrentBranch() tea.Msg {
 	return branchCheckedOutMsg{}
 }
 
+type alreadyOnLastBranchMsg struct{}
+
 type checkoutBranchMsg struct{}
 type nextBranchMsg struct{}
 type showSelectionMsg struct{}
@@ -123,6 +128,9 @@ func (m stackNextModel) nextBranch() tea.Msg {
 	currentBranchChildren := m.currentBranchChildren()
 
 	if m.lastInStack && len(currentBranchChildren) == 0 {
+		if m.steps == 0 {
+			return alreadyOnLastBranchMsg{}
+		}
 		return checkoutBranchMsg{}
 	}
 
@@ -131,7 +139,10 @@ func (m stackNextModel) nextBranch() tea.Msg {
 	}
 
 	if m.nInStack > 0 && len(currentBranchChildren) == 0 {
-		return errors.New("invalid number, already on last branch in the stack")
+		if m.steps == 0 {
+			return errors.New("invalid number, already on last branch in the stack")
+		}
+		return fmt.Errorf("invalid number (there are only %d next branches in the stack, you can use '--last' to get to last branch in stack)", m.steps)
 	}
 
 	if len(currentBranchChildren) == 0 {
@@ -141,6 +152,11 @@ func (m stackNextModel) nextBranch() tea.Msg {
 	if len(currentBranchChildren) == 1 {
 		return nextBranchMsg{}
 	}
+	if err := uiutils.RequireInteractive(
+		"choosing the child branch to follow", "use av switch to check out the branch",
+	); err != nil {
+		return err
+	}
 
 	return showSelectionMsg{}
 }
@@ -151,9 +167,12 @@ func (m stackNextModel) Init() tea.Cmd {
 
 func (vm stackNextModel) View() string {
 	var ss []string
-	if vm.selection != nil {
+	if vm.selection != nil && vm.message == "" {
 		ss = append(ss, vm.selection.View()+vm.help.ShortHelpView(uiutils.PromptKeys))
 	}
+	if vm.message != "" {
+		ss = append(ss, vm.message)
+	}
 
 	var ret string
 	if len(ss) != 0 {
@@ -176,12 +195,17 @@ func (m stackNextModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.err = msg
 		return m, tea.Quit
 	case branchCheckedOutMsg:
+		m.message = "Checked out branch " + colors.UserInput(m.currentBranch)
+		return m, tea.Quit
+	case alreadyOnLastBranchMsg:
+		m.message = "Already on last branch in stack (" + colors.UserInput(m.currentBranch) + ")"
 		return m, tea.Quit
 	case checkoutBranchMsg:
 		return m, m.checkoutCurrentBranch
 	case nextBranchMsg:
 		m.currentBranch = m.currentBranchChildren()[0]
 		m.nInStack--
+		m.steps++
 		return m, m.nextBranch
 
 	case showSelectionMsg:
@@ -202,6 +226,7 @@ func (m stackNextModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.currentBranch = currentBranch
 				m.nInStack--
+				m.steps++
 
 				return m, m.nextBranch
 			case "ctrl+c":
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/editor"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var notesSetFlags struct {
+	Branch string
+}
+
+var notesCmd = &cobra.Command{
+	Use:   "notes",
+	Short: "Manage per-branch notes",
+	Long: strings.TrimSpace(`
+Manage per-branch notes.
+
+Notes are a lightweight place for the context that doesn't belong in the pull
+request description (e.g., review or test notes). They are stored as git notes
+under refs/notes/av, so they survive rebases. The notes are fetched by av sync
+and pushed with the branches by av sync and av pr (or with av notes publish),
+and they are shown in av tree.
+`),
+}
+
+var notesShowCmd = &cobra.Command{
+	Use:               "show [<branch>]",
+	Short:             "Show the note of a branch",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: branchNameArgAt(0),
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		branchName, err := notesBranchName(repo, args)
+		if err != nil {
+			return err
+		}
+		notes, err := repo.BranchNotes([]string{branchName})
+		if err != nil {
+			return err
+		}
+		note, ok := notes[branchName]
+		if !ok {
+			fmt.Fprint(os.Stderr,
+				colors.Faint("Branch "), colors.UserInput(branchName), colors.Faint(" has no note.\n"),
+			)
+			return nil
+		}
+		fmt.Println(note)
+		return nil
+	},
+}
+
+var notesSetCmd = &cobra.Command{
+	Use:   "set [<note>]",
+	Short: "Set the note of a branch",
+	Long: strings.TrimSpace(`
+Set the note of the current branch (or the branch given with --branch).
+
+If the note is not given, an editor is opened to edit the current note. An empty
+note removes the note.
+`),
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		var branchArgs []string
+		if notesSetFlags.Branch != "" {
+			branchArgs = []string{notesSetFlags.Branch}
+		}
+		branchName, err := notesBranchName(repo, branchArgs)
+		if err != nil {
+			return err
+		}
+
+		var note string
+		if len(args) > 0 {
+			note = args[0]
+		} else {
+			notes, err := repo.BranchNotes([]string{branchName})
+			if err != nil {
+				return err
+			}
+			note, err = editor.Launch(repo, editor.Config{
+				Text: notes[branchName] + "\n\n" +
+					"%% Edit the note of the branch " + branchName + ".\n" +
+					"%% Lines starting with '%%' are ignored. An empty note removes the note.\n",
+				TmpFilePattern: "av-note-*.md",
+				CommentPrefix:  "%%",
+			})
+			if err != nil {
+				return errors.WrapIf(err, "text editor failed")
+			}
+		}
+
+		note = strings.TrimSpace(note)
+		if err := repo.SetBranchNote(branchName, note); err != nil {
+			return err
+		}
+		if note == "" {
+			fmt.Fprint(os.Stderr,
+				colors.Success("Removed the note of "), colors.UserInput(branchName), colors.Success(".\n"),
+			)
+		} else {
+			fmt.Fprint(os.Stderr,
+				colors.Success("Updated the note of "), colors.UserInput(branchName), colors.Success(".\n"),
+				colors.Faint("  - the notes are pushed by "), colors.CliCmd("av sync"),
+				colors.Faint(" and "), colors.CliCmd("av pr"),
+				colors.Faint(", or run "), colors.CliCmd("av notes publish"),
+				colors.Faint(" to share them now\n"),
+			)
+		}
+		return nil
+	},
+}
+
+var notesPublishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Share the branch notes through the remote repository",
+	Long: strings.TrimSpace(`
+Fetch the branch notes from the remote repository, merge them into the local
+notes, and push the result back to the remote repository.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		if err := repo.PublishBranchNotes(); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Published the branch notes to "),
+			colors.UserInput(repo.GetRemoteName()), colors.Success(".\n"),
+		)
+		return nil
+	},
+}
+
+// stackPublishNotesCmd is av notes publish under the av stack command.
+var stackPublishNotesCmd = &cobra.Command{
+	Use:   "publish-notes",
+	Short: "Share the branch notes through the remote repository (same as av notes publish)",
+	Long: strings.TrimSpace(`
+Fetch the branch notes from the remote repository, merge them into the local
+notes, and push the result back to the remote repository. This is the same
+command as av notes publish.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return notesPublishCmd.RunE(cmd, args)
+	},
+}
+
+func notesBranchName(repo *git.Repo, args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	branchName, err := repo.CurrentBranchName()
+	if err != nil {
+		return "", errors.WrapIf(err, "failed to determine current branch")
+	}
+	return branchName, nil
+}
+
+func init() {
+	notesSetCmd.Flags().StringVar(
+		&notesSetFlags.Branch, "branch", "",
+		"the branch to set the note of (defaults to the current branch)",
+	)
+	_ = notesSetCmd.RegisterFlagCompletionFunc("branch", branchNameArgs)
+
+	notesCmd.AddCommand(
+		notesPublishCmd,
+		notesSetCmd,
+		notesShowCmd,
+	)
+}
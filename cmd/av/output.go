@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/stackutils"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// jsonOutput returns true if the read commands should print JSON instead of the text for the
+// terminal (--json or --output=json).
+func jsonOutput() bool {
+	return rootFlags.JSON || rootFlags.Output == outputJSON
+}
+
+// printJSON prints the value as indented JSON to stdout.
+func printJSON(v any) error {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(os.Stdout, string(bs))
+	return nil
+}
+
+// branchOutput is the JSON output of a branch managed by av.
+type branchOutput struct {
+	Name          string   `json:"name"`
+	Parent        string   `json:"parent"`
+	ParentIsTrunk bool     `json:"parentIsTrunk"`
+	Children      []string `json:"children"`
+	// True if the branch is checked out.
+	Current bool `json:"current"`
+	// The number of the commits in the branch that are not in the parent branch.
+	Ahead int `json:"ahead"`
+	// The number of the commits in the parent branch that are not in the branch.
+	Behind int `json:"behind"`
+	// True if the branch needs av sync (not on top of the parent or not pushed).
+	NeedsSync    bool               `json:"needsSync"`
+	PinnedCommit string             `json:"pinnedCommit,omitempty"`
+	FrozenBase   string             `json:"frozenBase,omitempty"`
+	BaseRef      string             `json:"baseRef,omitempty"`
+	NoSubmit     bool               `json:"noSubmit,omitempty"`
+	Issue        string             `json:"issue,omitempty"`
+	Note         string             `json:"note,omitempty"`
+	PullRequest  *pullRequestOutput `json:"pullRequest,omitempty"`
+	// The report of the conflict that stopped the last restack of the branch, if any.
+	Conflict *meta.BranchConflict `json:"conflict,omitempty"`
+}
+
+type pullRequestOutput struct {
+	Number int64  `json:"number"`
+	State  string `json:"state"`
+	URL    string `json:"url"`
+}
+
+// newBranchOutputs returns the JSON output of the branches.
+func newBranchOutputs(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	currentBranch string,
+	names []string,
+) []branchOutput {
+	notes, _ := repo.BranchNotes(names)
+	ret := []branchOutput{}
+	for _, name := range names {
+		br, _ := tx.Branch(name)
+		out := branchOutput{
+			Name:          name,
+			Parent:        br.Parent.Name,
+			ParentIsTrunk: br.Parent.Trunk,
+			Children:      meta.ChildrenNames(tx, name),
+			Current:       name == currentBranch,
+			NeedsSync:     getStackTreeBranchInfo(repo, tx, name).NeedSync,
+			PinnedCommit:  br.PinnedCommit,
+			FrozenBase:    br.FrozenBase,
+			BaseRef:       br.BaseRef,
+			NoSubmit:      br.NoSubmit,
+			Issue:         br.Issue,
+			Note:          notes[name],
+			Conflict:      br.Conflict,
+		}
+		if out.Children == nil {
+			out.Children = []string{}
+		}
+		out.Ahead, out.Behind = branchAheadBehind(repo, br)
+		if br.PullRequest != nil {
+			out.PullRequest = &pullRequestOutput{
+				Number: br.PullRequest.Number,
+				State:  strings.ToLower(string(br.PullRequest.State)),
+				URL:    br.PullRequest.Permalink,
+			}
+		}
+		ret = append(ret, out)
+	}
+	return ret
+}
+
+// branchAheadBehind returns the number of the commits that the branch is ahead of and behind
+// its parent branch. A branch based on the trunk is compared with the remote trunk branch, or
+// with the frozen base if any.
+func branchAheadBehind(repo *git.Repo, br meta.Branch) (ahead int, behind int) {
+	parent := branchComparisonBase(repo, br)
+	out, err := repo.Git("rev-list", "--left-right", "--count", parent+"..."+br.Name, "--")
+	if err != nil {
+		return 0, 0
+	}
+	left, right, _ := strings.Cut(out, "\t")
+	behind, _ = strconv.Atoi(left)
+	ahead, _ = strconv.Atoi(right)
+	return ahead, behind
+}
+
+// branchComparisonBase returns the ref that the branch is compared with: the parent branch, or
+// the remote trunk branch (or the frozen base) for a branch based on the trunk.
+func branchComparisonBase(repo *git.Repo, br meta.Branch) string {
+	parent := br.Parent.Name
+	if br.Parent.Trunk && br.FrozenBase != "" {
+		return br.FrozenBase
+	} else if br.Parent.Trunk {
+		if refs, err := repo.Refs(); err == nil {
+			if _, ok := refs.RemoteBranch(repo.GetRemoteName(), parent); ok {
+				return repo.RemoteBranchRef(parent)
+			}
+		}
+	}
+	return parent
+}
+
+// stackTreeBranchNames returns the names of the non-trunk branches in the trees in the
+// depth-first order.
+func stackTreeBranchNames(nodes []*stackutils.StackTreeNode) []string {
+	var ret []string
+	for _, node := range nodes {
+		// The trunk branches are the roots without a parent.
+		if node.Branch.ParentBranchName != "" {
+			ret = append(ret, node.Branch.BranchName)
+		}
+		ret = append(ret, stackTreeBranchNames(node.Children)...)
+	}
+	return ret
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var pinFlags struct {
+	StdinBranches bool
+}
+
+var pinCmd = &cobra.Command{
+	Use:   "pin [<branch>]",
+	Short: "Pin a branch to its current commit",
+	Long: strings.TrimSpace(`
+Pin a branch to its current commit.
+
+A pinned branch is not rebased by av sync and av restack (e.g., because the commit
+is deployed to a demo environment). Its children branches are rebased onto the
+pinned commit instead. Use av unpin to unpin the branch.
+
+If the branch is not specified, the current branch is pinned. With --stdin, the
+branches are read from the standard input, one per line (e.g., av query 'merged'
+| av pin --stdin).
+`),
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: branchNameArgAt(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setBranchPin(args, true)
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin [<branch>]",
+	Short: "Unpin a branch pinned by av pin",
+	Long: strings.TrimSpace(`
+Unpin a branch pinned by av pin. The branch is rebased as usual on the next
+av sync or av restack.
+
+If the branch is not specified, the current branch is unpinned. With --stdin, the
+branches are read from the standard input, one per line.
+`),
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: branchNameArgAt(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setBranchPin(args, false)
+	},
+}
+
+func setBranchPin(args []string, pin bool) error {
+	repo, err := getRepo()
+	if err != nil {
+		return err
+	}
+	db, err := getDB(repo)
+	if err != nil {
+		return err
+	}
+
+	var branchNames []string
+	switch {
+	case pinFlags.StdinBranches:
+		if len(args) > 0 {
+			return errors.New("cannot specify a branch with --stdin")
+		}
+		branchNames, err = readStdinBranches()
+		if err != nil {
+			return err
+		}
+	case len(args) > 0:
+		branchNames = args
+	default:
+		branchName, err := repo.CurrentBranchName()
+		if err != nil {
+			return errors.WrapIf(err, "failed to determine current branch")
+		}
+		branchNames = []string{branchName}
+	}
+	for _, branchName := range branchNames {
+		if err := setOneBranchPin(repo, db, branchName, pin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setOneBranchPin(repo *git.Repo, db meta.DB, branchName string, pin bool) error {
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+
+	branch, ok := tx.Branch(branchName)
+	if !ok {
+		return actions.BranchNotAdoptedError{Branch: branchName}
+	}
+	if pin {
+		if branch.IsPinned() {
+			return errors.Errorf("branch %q is already pinned", branchName)
+		}
+		head, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + branchName})
+		if err != nil {
+			return err
+		}
+		branch.PinnedCommit = head
+	} else {
+		if !branch.IsPinned() {
+			return errors.Errorf("branch %q is not pinned", branchName)
+		}
+		branch.PinnedCommit = ""
+	}
+	tx.SetBranch(branch)
+	cu.Cancel()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if pin {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Pinned branch "), colors.UserInput(branchName),
+			colors.Success(" to "), colors.UserInput(branch.PinnedCommit[:7]), colors.Success(".\n"),
+		)
+		if len(meta.Children(tx, branchName)) > 0 {
+			fmt.Fprint(os.Stderr,
+				colors.Faint("  - its children branches will be rebased onto the pinned commit\n"),
+			)
+		}
+	} else {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Unpinned branch "), colors.UserInput(branchName), colors.Success(".\n"),
+			colors.Faint("  - run "), colors.CliCmd("av restack"),
+			colors.Faint(" or "), colors.CliCmd("av sync"),
+			colors.Faint(" to rebase it onto its parent\n"),
+		)
+	}
+	return nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{pinCmd, unpinCmd} {
+		addStdinBranchesFlag(cmd, &pinFlags.StdinBranches)
+		// --stdin-branches is kept as an alias of --stdin.
+		cmd.Flags().BoolVar(&pinFlags.StdinBranches, "stdin-branches", false, "")
+		_ = cmd.Flags().MarkHidden("stdin-branches")
+	}
+}
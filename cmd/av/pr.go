@@ -5,33 +5,54 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
 	"github.com/aviator-co/av/internal/avgql"
 	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/forge"
 	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/hooks"
 	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/notify"
+	"github.com/aviator-co/av/internal/sequencer/planner"
 	"github.com/aviator-co/av/internal/utils/cleanup"
 	"github.com/aviator-co/av/internal/utils/colors"
-	"github.com/shurcooL/githubv4"
+	"github.com/aviator-co/av/internal/utils/progress"
 	"github.com/shurcooL/graphql"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var prFlags struct {
-	Draft     bool
-	Force     bool
-	NoPush    bool
-	Title     string
-	Body      string
-	Edit      bool
-	Reviewers []string
-	Queue     bool
-	All       bool
-	Current   bool
+	Draft       bool
+	Ready       bool
+	ReadyBelow  string
+	Force       bool
+	NoPush      bool
+	Title       string
+	Body        string
+	Edit        bool
+	Summarize   bool
+	Reviewers   []string
+	Labels      []string
+	Assignees   []string
+	Codeowners  bool
+	Queue       bool
+	All         bool
+	Range       submitRange
+	Stdin       bool
+	DryRun      bool
+	Open        bool
+	Suggest     bool
+	Since       string
+	AutoMerge   bool
+	MergeMethod string
 }
 
 var prCmd = &cobra.Command{
@@ -53,38 +74,118 @@ Examples:
   Create a pull request, assigning reviewers:
     $ av pr --reviewers "example,@example-org/example-team"
 
+  Create a pull request with labels, assigned to yourself:
+    $ av pr --label bug --label backend --assignee @me
+
   Create pull requests for every branch in the stack:
 	$ av pr --all
+
+  Create pull requests for every branch in the stack, with only the bottom two ready for
+  review (the others become ready as their parents are merged by av sync):
+    $ av pr --all --ready-below feature-2
+
+  Create pull requests for every branch in the stack and open them in the browser:
+    $ av pr --all --open
+
+  Preview the titles of the pull requests to be created for the stack:
+    $ av pr --all --dry-run
+
+  Submit only the branches of the stack that changed since they were pushed last time:
+    $ av pr --all --since last-submit
+
+  Create pull requests for the stack and squash-merge them automatically once their checks pass
+  (bottom first, as av sync moves the next one onto the trunk):
+    $ av pr --all --auto-merge --merge-method squash
+
+  Create pull requests for the branches listed by av query:
+    $ av query 'author=me && !has-pr' | av pr --stdin
 `),
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) (reterr error) {
 		if prFlags.Queue {
 			if prFlags.Draft ||
+				prFlags.Ready ||
+				prFlags.ReadyBelow != "" ||
 				prFlags.Force ||
 				prFlags.NoPush ||
 				prFlags.Title != "" ||
 				prFlags.Body != "" ||
 				prFlags.Edit ||
-				prFlags.Reviewers != nil {
+				prFlags.Summarize ||
+				prFlags.Reviewers != nil ||
+				prFlags.Labels != nil ||
+				prFlags.Assignees != nil ||
+				prFlags.Codeowners ||
+				prFlags.DryRun ||
+				prFlags.AutoMerge ||
+				prFlags.MergeMethod != "" {
 
 				return errors.New("cannot use other flags with --queue")
 			}
 			return queue()
 		}
 
-		if prFlags.All {
+		autoMerge, err := autoMergeMethod(cmd, prFlags.AutoMerge, prFlags.MergeMethod)
+		if err != nil {
+			return err
+		}
+
+		if prFlags.All || prFlags.Stdin {
 			if prFlags.Force ||
 				prFlags.NoPush ||
 				prFlags.Title != "" ||
 				prFlags.Body != "" ||
 				prFlags.Edit ||
-				prFlags.Reviewers != nil ||
 				prFlags.Queue {
 
-				return errors.New("can only use --current and --draft with --all")
+				return errors.New(
+					"can only use --current, --only, --downstack, --upstack, --from, --to, --draft, --ready, --ready-below, --dry-run, --open, --suggest, --since, --auto-merge, --merge-method, --reviewers, --labels, --assignees, and --codeowners with --all",
+				)
+			}
+			if err := prFlags.Range.validate(); err != nil {
+				return err
 			}
 
-			return submitAll(prFlags.Current, prFlags.Draft)
+			var branches []string
+			if prFlags.Stdin {
+				if prFlags.Range.partial() {
+					return errors.New("cannot use --stdin with --current, --only, --downstack, --upstack, --from, or --to")
+				}
+				var err error
+				branches, err = readStdinBranches()
+				if err != nil {
+					return err
+				}
+			}
+			return submitAll(
+				prFlags.Range,
+				prDraftOpts{Draft: prFlags.Draft, Ready: prFlags.Ready, ReadyBelow: prFlags.ReadyBelow},
+				prFlags.DryRun, prFlags.Open, branches, prFlags.Since, autoMerge,
+				pullRequestTriage(prFlags.Reviewers, prFlags.Labels, prFlags.Assignees, prFlags.Codeowners),
+			)
+		}
+		if prFlags.Open {
+			return errors.New("--open can only be used with --all")
+		}
+		if prFlags.Range.partial() {
+			return errors.New("--only, --downstack, --upstack, --from, and --to can only be used with --all")
+		}
+		if prFlags.ReadyBelow != "" {
+			return errors.New("--ready-below can only be used with --all")
+		}
+		if prFlags.Suggest {
+			return errors.New("--suggest can only be used with --all")
+		}
+		if prFlags.Since != "" {
+			return errors.New("--since can only be used with --all")
+		}
+		if prFlags.Summarize {
+			if prFlags.Title != "" || prFlags.Body != "" {
+				return errors.New("cannot use --summarize with --title or --body")
+			}
+			if config.Av.PullRequest.Summarizer == "" {
+				return errors.New("--summarize requires the pullRequest.summarizer config")
+			}
 		}
 
 		repo, err := getRepo()
@@ -95,17 +196,52 @@ Examples:
 		if err != nil {
 			return errors.WrapIf(err, "failed to determine current branch")
 		}
-		client, err := getGitHubClient()
+		db, err := getDB(repo)
 		if err != nil {
 			return err
 		}
+		migrateMovedRepository(repo, db)
+		tx := db.WriteTx()
+		defer tx.Abort()
 
-		db, err := getDB(repo)
+		f, err := getForge(tx.Repository())
 		if err != nil {
 			return err
 		}
-		tx := db.WriteTx()
-		defer tx.Abort()
+		client := forge.GitHubClient(f)
+		if err := checkTriageFlags(f); err != nil {
+			return err
+		}
+
+		if br, _ := tx.Branch(branchName); br.IsFollowed() {
+			return errors.Errorf(
+				"branch %q is followed from the remote, so its pull request is owned by someone else",
+				branchName,
+			)
+		}
+		if excluded := meta.NoSubmitAncestor(tx, branchName); excluded != "" {
+			return errors.Errorf(
+				"branch %q is excluded from submit (see av branch --submit)", excluded,
+			)
+		}
+		if config.Av.Commit.RequireSignoff {
+			if err := actions.VerifySignoffs(repo, tx, []string{branchName}); err != nil {
+				return err
+			}
+		}
+		if prFlags.DryRun {
+			return previewPullRequests(repo, tx, []string{branchName}, prFlags.Title)
+		}
+		if err := actions.VerifyStackIntegrity(repo, tx, []string{branchName}); err != nil {
+			return err
+		}
+		if !prFlags.NoPush {
+			if err := actions.ConfirmLockedBranches(
+				repo, tx, []string{branchName}, "push",
+			); err != nil {
+				return err
+			}
+		}
 
 		body := prFlags.Body
 		// Special case: ready body from stdin
@@ -118,13 +254,25 @@ Examples:
 		}
 
 		draft := config.Av.PullRequest.Draft
+		var readyAtDepth int
+		existingBranch, _ := tx.Branch(branchName)
+		existingReadyAtDepth := existingBranch.ReadyAtDepth
 		if cmd.Flags().Changed("draft") {
 			draft = prFlags.Draft
+		} else if prFlags.Ready {
+			draft = false
+		} else if ruleDraft, ok := actions.PullRequestDraftByDepth(
+			config.Av.PullRequest.DraftRules, tx, branchName,
+		); ok {
+			draft = ruleDraft
+			if ruleDraft {
+				readyAtDepth = config.Av.PullRequest.DraftRules.DraftAfterDepth
+			}
 		}
 
 		ctx := context.Background()
 		res, err := actions.CreatePullRequest(
-			ctx, repo, client, tx,
+			ctx, repo, f, tx,
 			actions.CreatePullRequestOpts{
 				BranchName: branchName,
 				Title:      prFlags.Title,
@@ -133,19 +281,51 @@ Examples:
 				Force:      prFlags.Force,
 				Draft:      draft,
 				Edit:       prFlags.Edit,
+				Summarize:  prFlags.Summarize,
 			},
 		)
 		if err != nil {
 			return err
 		}
+		if res.Created || existingReadyAtDepth != 0 {
+			// The draft rules don't apply to the existing pull requests made drafts by hand.
+			setReadyAtDepth(tx, branchName, readyAtDepth)
+		}
+		if prFlags.Ready && !res.Created && res.Pull.IsDraft {
+			if _, err := f.MarkPullRequestReadyForReview(ctx, res.Pull.ID); err != nil {
+				return err
+			}
+			fmt.Fprint(os.Stderr,
+				"  - marked pull request ", colors.UserInput(res.Branch.PullRequest.Permalink),
+				" as ready for review\n",
+			)
+		}
+		if autoMerge != "" {
+			if err := actions.EnableAutoMerge(
+				ctx, f, tx, branchName, autoMerge, os.Stderr,
+			); err != nil {
+				fmt.Fprint(os.Stderr, colors.Warning("Failed to enable auto-merge: "+err.Error()+"\n"))
+			}
+		}
 		if err := tx.Commit(); err != nil {
 			return err
 		}
 
 		// Do this after creating the PR and committing the transaction so that
 		// our local database is up-to-date even if this fails.
-		if len(prFlags.Reviewers) > 0 {
-			if err := actions.AddPullRequestReviewers(ctx, client, res.Pull.ID, prFlags.Reviewers); err != nil {
+		if client != nil {
+			if err := actions.SyncPullRequestTriage(
+				ctx, client, tx.Repository(), res.Pull.ID,
+				pullRequestTriage(
+					prFlags.Reviewers, prFlags.Labels, prFlags.Assignees, prFlags.Codeowners,
+				).ForBranch(repo, res.Branch, os.Stderr),
+				os.Stderr,
+			); err != nil {
+				return err
+			}
+			if err := actions.SyncCIHintLabel(
+				ctx, client, tx, branchName, res.Pull.ID, os.Stderr,
+			); err != nil {
 				return err
 			}
 		}
@@ -156,14 +336,37 @@ Examples:
 				return err
 			}
 
-			return actions.UpdatePullRequestsWithStack(ctx, client, tx, stackBranches)
+			return actions.UpdatePullRequestsWithStack(ctx, f, tx, stackBranches)
 		}
 
 		return nil
 	},
 }
 
-func submitAll(current bool, draft bool) error {
+// prDraftOpts is how the pull requests submitted by submitAll are made drafts. If none is set,
+// the pullRequest.draft and the pullRequest.draftRules configs are used.
+type prDraftOpts struct {
+	// All the pull requests are drafts (--draft).
+	Draft bool
+	// All the pull requests are ready for review, including the existing drafts (--ready).
+	Ready bool
+	// The branch and the branches below it are ready for review, and the others are drafts
+	// that become ready as their parents are merged (--ready-below).
+	ReadyBelow string
+}
+
+// submitAll creates or updates the pull requests of the current stack. If branches is not nil,
+// the given branches are submitted instead.
+func submitAll(
+	rng submitRange,
+	drafts prDraftOpts,
+	dryRun bool,
+	open bool,
+	branches []string,
+	since string,
+	autoMerge string,
+	triage actions.PullRequestTriage,
+) error {
 	repo, err := getRepo()
 	if err != nil {
 		return err
@@ -177,93 +380,407 @@ func submitAll(current bool, draft bool) error {
 	cu := cleanup.New(func() { tx.Abort() })
 	defer cu.Cleanup()
 
-	currentBranch, err := repo.CurrentBranchName()
-	if err != nil {
-		return err
-	}
-
-	currentStackBranches, err := meta.StackBranches(tx, currentBranch)
-	if err != nil {
-		return err
-	}
-
+	// The branches whose stacks are written to the pull requests (see
+	// config.PullRequest.WriteStack), and the branch that the stack notification is sent for.
+	var stackBranches []string
+	var notifyBranch string
 	var branchesToSubmit []string
-	if current {
-		previousBranches, err := meta.PreviousBranches(tx, currentBranch)
+	if branches != nil {
+		targets, err := planner.GetTargetBranchesFromList(tx, branches)
 		if err != nil {
 			return err
 		}
-		branchesToSubmit = append(branchesToSubmit, previousBranches...)
-		branchesToSubmit = append(branchesToSubmit, currentBranch)
+		for _, br := range targets {
+			branchesToSubmit = append(branchesToSubmit, br.Short())
+			brs, err := meta.StackBranches(tx, br.Short())
+			if err != nil {
+				return err
+			}
+			for _, n := range brs {
+				if !slices.Contains(stackBranches, n) {
+					stackBranches = append(stackBranches, n)
+				}
+			}
+		}
+		notifyBranch = branchesToSubmit[0]
 	} else {
-		branchesToSubmit = currentStackBranches
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+
+		currentStackBranches, err := meta.StackBranches(tx, currentBranch)
+		if err != nil {
+			return err
+		}
+
+		if rng.partial() {
+			branchesToSubmit, err = rng.branches(tx, currentBranch)
+			if err != nil {
+				return err
+			}
+			// The pull requests outside of the range are left untouched.
+			stackBranches = branchesToSubmit
+		} else {
+			branchesToSubmit = currentStackBranches
+			subsequentBranches := meta.SubsequentBranches(tx, currentBranch)
+			branchesToSubmit = append(branchesToSubmit, subsequentBranches...)
+			stackBranches = currentStackBranches
+		}
+		notifyBranch = currentBranch
+	}
+	// The followed branches (see av follow) are owned by someone else, so they're not submitted.
+	branchesToSubmit = slices.DeleteFunc(slices.Clone(branchesToSubmit), func(name string) bool {
+		br, _ := tx.Branch(name)
+		return br.IsFollowed()
+	})
+	// The branches excluded from submit (see av branch --no-submit) and the branches stacked
+	// on them are restacked locally only.
+	var excluded []string
+	branchesToSubmit = slices.DeleteFunc(branchesToSubmit, func(name string) bool {
+		if meta.NoSubmitAncestor(tx, name) != "" {
+			excluded = append(excluded, name)
+			return true
+		}
+		return false
+	})
+	if len(excluded) > 0 {
+		fmt.Fprint(os.Stderr, colors.Faint(fmt.Sprintf(
+			"Skipping %d branch(es) excluded from submit: %s\n", len(excluded),
+			strings.Join(excluded, ", "),
+		)))
+		if len(branchesToSubmit) == 0 {
+			return nil
+		}
+	}
+	if since != "" {
+		branchesToSubmit, err = filterBranchesChangedSince(repo, tx, branchesToSubmit, since)
+		if err != nil {
+			return err
+		}
+		if len(branchesToSubmit) == 0 {
+			return nil
+		}
 	}
 
-	if !current {
-		subsequentBranches := meta.SubsequentBranches(tx, currentBranch)
-		branchesToSubmit = append(branchesToSubmit, subsequentBranches...)
+	if config.Av.Commit.RequireSignoff {
+		if err := actions.VerifySignoffs(repo, tx, branchesToSubmit); err != nil {
+			return err
+		}
+	}
+	if drafts.Draft && drafts.Ready {
+		return errors.New("cannot use --draft with --ready")
 	}
+	draftRules := config.Av.PullRequest.DraftRules
+	if drafts.ReadyBelow != "" {
+		if drafts.Draft || drafts.Ready {
+			return errors.New("cannot use --ready-below with --draft or --ready")
+		}
+		if !slices.Contains(branchesToSubmit, drafts.ReadyBelow) {
+			return errors.Errorf("branch %q is not submitted", drafts.ReadyBelow)
+		}
+		depth, err := actions.BranchDepth(tx, drafts.ReadyBelow)
+		if err != nil {
+			return err
+		}
+		draftRules = config.PullRequestDraftRules{DraftAfterDepth: depth}
+	}
+	if prFlags.Suggest || config.Av.PullRequest.Suggestions.Enabled {
+		moves, err := showSubmitSuggestions(repo, tx, branchesToSubmit, !dryRun)
+		if err != nil {
+			return err
+		}
+		if len(moves) > 0 {
+			// The branches are moved with their own transactions.
+			cu.Cancel()
+			tx.Abort()
+			return applySubmitSuggestions(repo, db, moves)
+		}
+	}
+	if dryRun {
+		return previewPullRequests(repo, tx, branchesToSubmit, "")
+	}
+	if err := actions.VerifyStackIntegrity(repo, tx, branchesToSubmit); err != nil {
+		return err
+	}
+	if err := actions.ConfirmLockedBranches(repo, tx, branchesToSubmit, "push"); err != nil {
+		return err
+	}
+	// The pre-submit hooks may run av themselves, so they run outside of the transaction. The
+	// transaction is started again to see the changes made by the hooks.
+	tx.Abort()
+	for _, branchName := range branchesToSubmit {
+		if err := hooks.Run(repo, hooks.PreSubmit, branchHookEnv(db.ReadTx(), branchName)); err != nil {
+			return err
+		}
+	}
+	tx = db.WriteTx()
 
 	// ensure pull requests for each branch in the stack
 	createdPullRequestPermalinks := []string{}
+	submittedPullRequestPermalinks := []string{}
 	ctx := context.Background()
-	client, err := getGitHubClient()
+	f, err := getForge(tx.Repository())
 	if err != nil {
 		return err
 	}
-	for _, branchName := range branchesToSubmit {
-		// TODO: should probably commit database after every call to this
-		// since we're just syncing state from GitHub
-
-		draft := config.Av.PullRequest.Draft || draft
-
-		result, err := actions.CreatePullRequest(
-			ctx, repo, client, tx,
-			actions.CreatePullRequestOpts{
-				BranchName:    branchName,
-				Draft:         draft,
-				NoOpenBrowser: true,
+	if err := checkTriageFlags(f); err != nil {
+		return err
+	}
+	existingPulls, err := actions.PrefetchPullRequests(ctx, f, tx, branchesToSubmit)
+	if err != nil {
+		return err
+	}
+	var resultsMu sync.Mutex
+	results := map[string]*actions.CreatePullRequestResult{}
+	if err := actions.SubmitPullRequests(
+		tx, branchesToSubmit,
+		actions.SubmitPullRequestsOpts{
+			Concurrency: config.Av.PullRequest.SubmitConcurrency,
+			// A new pull request (or one without a title or a body) opens the editor.
+			Interactive: func(branchName string) bool {
+				pull := existingPulls[branchName]
+				return pull == nil || pull.Title == "" || pull.Body == ""
 			},
-		)
-		if err != nil {
-			return err
+		},
+		func(tx meta.WriteTx, branchName string, out io.Writer) error {
+			result, err := submitBranch(
+				ctx, repo, f, tx, branchName, drafts, draftRules, existingPulls[branchName],
+				autoMerge, triage, out,
+			)
+			if err != nil {
+				return err
+			}
+			resultsMu.Lock()
+			defer resultsMu.Unlock()
+			results[branchName] = result
+			return nil
+		},
+	); err != nil {
+		// Keep the pull requests submitted before the failure so that running the command
+		// again continues from the failed branch.
+		cu.Cancel()
+		if cerr := tx.Commit(); cerr != nil {
+			return errors.Combine(err, cerr)
 		}
+		if gh.IsRateLimited(err) {
+			return errors.WrapIf(
+				err,
+				"GitHub API rate limit exceeded (run the command again later to submit the rest of the branches)",
+			)
+		}
+		return err
+	}
+	for _, branchName := range branchesToSubmit {
+		result := results[branchName]
+		submittedPullRequestPermalinks = append(
+			submittedPullRequestPermalinks,
+			result.Branch.PullRequest.Permalink,
+		)
 		if result.Created {
 			createdPullRequestPermalinks = append(
 				createdPullRequestPermalinks,
 				result.Branch.PullRequest.Permalink,
 			)
 		}
-		// make sure the base branch of the PR is up to date if it already exists
-		if !result.Created && result.Pull.BaseRefName != result.Branch.Parent.Name {
-			if _, err := client.UpdatePullRequest(
-				ctx, githubv4.UpdatePullRequestInput{
-					PullRequestID: githubv4.ID(result.Branch.PullRequest.ID),
-					BaseRefName:   gh.Ptr(githubv4.String(result.Branch.Parent.Name)),
-				},
-			); err != nil {
-				return errors.Wrap(err, "failed to update PR base branch")
-			}
-		}
 	}
 
+	progress.Report(progress.PhaseSubmit, "", len(branchesToSubmit), len(branchesToSubmit))
+
 	cu.Cancel()
 	if err := tx.Commit(); err != nil {
 		return err
 	}
 
 	if config.Av.PullRequest.WriteStack {
-		if err = actions.UpdatePullRequestsWithStack(ctx, client, tx, currentStackBranches); err != nil {
+		if err = actions.UpdatePullRequestsWithStack(ctx, f, tx, stackBranches); err != nil {
 			return err
 		}
 	}
 
-	if config.Av.PullRequest.OpenBrowser {
-		for _, createdPullRequestPermalink := range createdPullRequestPermalinks {
-			actions.OpenPullRequestInBrowser(createdPullRequestPermalink)
+	if notify.Enabled() {
+		event, err := notify.NewStackEvent(tx, notify.EventSubmit, notifyBranch)
+		if err == nil {
+			err = notify.Send(ctx, event)
+		}
+		if err != nil {
+			fmt.Fprint(os.Stderr,
+				colors.Warning("Failed to send the stack notification: "+err.Error()+"\n"),
+			)
+		}
+	}
+	for _, branchName := range branchesToSubmit {
+		runPostHook(repo, hooks.PostSubmit, branchHookEnv(tx, branchName))
+	}
+
+	var permalinksToOpen []string
+	if open {
+		switch config.Av.PullRequest.SubmitOpen {
+		case config.SubmitOpenAll:
+			permalinksToOpen = submittedPullRequestPermalinks
+		case config.SubmitOpenCreated:
+			permalinksToOpen = createdPullRequestPermalinks
+		case config.SubmitOpenBottom:
+			if len(submittedPullRequestPermalinks) > 0 {
+				permalinksToOpen = submittedPullRequestPermalinks[:1]
+			}
+		}
+	} else if config.Av.PullRequest.OpenBrowser {
+		permalinksToOpen = createdPullRequestPermalinks
+	}
+	for _, permalink := range permalinksToOpen {
+		actions.OpenPullRequestInBrowser(permalink)
+	}
+
+	return nil
+}
+
+// submitBranch creates or updates the pull request of a branch submitted by submitAll. The
+// existing pull request is the one queried by actions.PrefetchPullRequests, if any. If autoMerge
+// is not empty, auto-merge is enabled with the merge method (see actions.EnableAutoMerge).
+func submitBranch(
+	ctx context.Context,
+	repo *git.Repo,
+	f forge.Forge,
+	tx meta.WriteTx,
+	branchName string,
+	drafts prDraftOpts,
+	draftRules config.PullRequestDraftRules,
+	existing *gh.PullRequest,
+	autoMerge string,
+	triage actions.PullRequestTriage,
+	out io.Writer,
+) (*actions.CreatePullRequestResult, error) {
+	prDraft := (config.Av.PullRequest.Draft || drafts.Draft) && !drafts.Ready
+	ruleDraft, hasRule := actions.PullRequestDraftByDepth(draftRules, tx, branchName)
+	useRule := hasRule && !drafts.Draft && !drafts.Ready
+	if useRule {
+		prDraft = ruleDraft
+	}
+	// The existing pull requests follow the draft rules of the config only if av made them
+	// drafts, so that the drafts made by hand are kept. --ready-below applies to all of them.
+	branch, _ := tx.Branch(branchName)
+	avDrafted := branch.ReadyAtDepth != 0 || drafts.ReadyBelow != ""
+
+	result, err := actions.CreatePullRequest(
+		ctx, repo, f, tx,
+		actions.CreatePullRequestOpts{
+			BranchName:          branchName,
+			Draft:               prDraft,
+			NoOpenBrowser:       true,
+			ExistingPullRequest: existing,
+			Output:              out,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	useRule = useRule && (result.Created || avDrafted)
+	readyAtDepth := 0
+	if useRule && ruleDraft {
+		readyAtDepth = draftRules.DraftAfterDepth
+	}
+	setReadyAtDepth(tx, branchName, readyAtDepth)
+	// The position of the branch in the stack changes as its parents are merged, so the
+	// existing draft pull requests are promoted once the rules say they are ready.
+	promote := drafts.Ready || (useRule && !ruleDraft &&
+		(config.Av.PullRequest.NoWIPDetection || !strings.Contains(result.Pull.Title, "WIP")))
+	if !result.Created && result.Pull.IsDraft && promote {
+		if _, err := f.MarkPullRequestReadyForReview(ctx, result.Pull.ID); err != nil {
+			return nil, err
+		}
+		fmt.Fprint(out,
+			"  - marked pull request ", colors.UserInput(result.Branch.PullRequest.Permalink),
+			" as ready for review\n",
+		)
+	}
+	// make sure the base branch of the PR is up to date if it already exists
+	if !result.Created && result.Pull.BaseRefName != result.Branch.Parent.Name {
+		if _, err := f.UpdatePullRequest(
+			ctx, forge.UpdatePullRequestInput{
+				ID:          result.Branch.PullRequest.ID,
+				BaseRefName: &result.Branch.Parent.Name,
+			},
+		); err != nil {
+			return nil, errors.Wrap(err, "failed to update PR base branch")
+		}
+	}
+	if autoMerge != "" {
+		if err := actions.EnableAutoMerge(ctx, f, tx, branchName, autoMerge, out); err != nil {
+			fmt.Fprint(out, colors.Warning("  - failed to enable auto-merge: "+err.Error()+"\n"))
+		}
+	}
+	if client := forge.GitHubClient(f); client != nil {
+		if err := actions.SyncPullRequestTriage(
+			ctx, client, tx.Repository(), result.Branch.PullRequest.ID,
+			triage.ForBranch(repo, result.Branch, out), out,
+		); err != nil {
+			return nil, err
+		}
+		if err := actions.SyncCIHintLabel(
+			ctx, client, tx, branchName, result.Branch.PullRequest.ID, out,
+		); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// setReadyAtDepth records the position in the stack at which the draft pull request of the
+// branch becomes ready for review (see meta.Branch.ReadyAtDepth). Zero clears it.
+func setReadyAtDepth(tx meta.WriteTx, branchName string, depth int) {
+	branch, ok := tx.Branch(branchName)
+	if !ok || branch.ReadyAtDepth == depth {
+		return
+	}
+	branch.ReadyAtDepth = depth
+	tx.SetBranch(branch)
+}
+
+// autoMergeMethod returns the merge method to enable auto-merge with on the submitted pull
+// requests, or an empty string if auto-merge is not enabled by the --auto-merge flag or the
+// pullRequest.autoMerge config. The --merge-method flag overrides pullRequest.mergeMethod.
+func autoMergeMethod(cmd *cobra.Command, autoMerge bool, method string) (string, error) {
+	enabled := config.Av.PullRequest.AutoMerge
+	if cmd.Flags().Changed("auto-merge") {
+		enabled = autoMerge
+	}
+	if method == "" {
+		method = config.Av.PullRequest.MergeMethod
+	} else if err := config.ValidateMergeMethod(method); err != nil {
+		return "", errors.WrapIf(err, "invalid --merge-method")
+	}
+	if !enabled {
+		if cmd.Flags().Changed("merge-method") {
+			return "", errors.New("--merge-method can only be used with --auto-merge")
 		}
+		return "", nil
 	}
+	return method, nil
+}
 
+// pullRequestTriage returns the triage of the submitted pull requests from the config and the
+// flags. The code owners are requested as reviewers only if it's enabled by the config or by
+// --codeowners.
+func pullRequestTriage(
+	reviewers, labels, assignees []string,
+	codeowners bool,
+) actions.PullRequestTriage {
+	triage := actions.NewPullRequestTriage(reviewers, labels, assignees)
+	triage.Codeowners = config.Av.PullRequest.CodeownersReviewers || codeowners
+	return triage
+}
+
+// checkTriageFlags returns an error if the reviewers, the labels, or the assignees are given on
+// the command line for a forge that doesn't support them. The defaults in the config are
+// ignored for such forges.
+func checkTriageFlags(f forge.Forge) error {
+	if forge.GitHubClient(f) != nil {
+		return nil
+	}
+	if len(prFlags.Reviewers) > 0 || len(prFlags.Labels) > 0 || len(prFlags.Assignees) > 0 {
+		return errors.Errorf("--reviewers, --labels, and --assignees are not supported on %s", f.Name())
+	}
 	return nil
 }
 
@@ -332,11 +849,68 @@ func queue() error {
 	return nil
 }
 
+// previewPullRequests prints the titles of the pull requests that would be created for the
+// given branches. If title is given, it's used instead of the generated title.
+func previewPullRequests(repo *git.Repo, tx meta.ReadTx, branches []string, title string) error {
+	fmt.Fprint(os.Stderr, "Pull requests to be created:\n")
+	stale := false
+	for _, branchName := range branches {
+		branch, _ := tx.Branch(branchName)
+		// The submit is refused if the pull request would contain the commits of a stale base
+		// (see actions.VerifyStackIntegrity), which the preview shows as a warning.
+		leaked, err := actions.LeakedCommits(repo, tx, branchName)
+		if err != nil {
+			return err
+		}
+		var warning string
+		if len(leaked) > 0 {
+			stale = true
+			warning = colors.Warning(fmt.Sprintf(
+				" (would contain %d commit(s) of the stale base %s)", len(leaked), branch.Parent.Name,
+			))
+		}
+		if branch.PullRequest != nil {
+			fmt.Fprint(os.Stderr,
+				"  ", colors.UserInput(branchName), ": ",
+				colors.Faint(fmt.Sprintf("pull request #%d already exists", branch.PullRequest.Number)),
+				warning, "\n",
+			)
+			continue
+		}
+		prTitle := title
+		if prTitle == "" {
+			var err error
+			prTitle, err = actions.PreviewPullRequestTitle(repo, tx, branchName)
+			if err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(os.Stderr, "  ", colors.UserInput(branchName), ": ", prTitle, warning, "\n")
+	}
+	if stale {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("  - run "), colors.CliCmd("av sync"),
+			colors.Faint(" to rebase the branches onto their parents before submitting\n"),
+		)
+	}
+	return nil
+}
+
 func init() {
 	prCmd.Flags().BoolVar(
 		&prFlags.Draft, "draft", false,
 		"create the pull request in draft mode",
 	)
+	prCmd.Flags().BoolVar(
+		&prFlags.Ready, "ready", false,
+		"create the pull request ready for review, and mark the existing draft as ready",
+	)
+	prCmd.Flags().StringVar(
+		&prFlags.ReadyBelow, "ready-below", "",
+		"with --all, make the given branch and the branches below it ready for review and the\nothers drafts (they become ready as their parents are merged by av sync)",
+	)
+	prCmd.MarkFlagsMutuallyExclusive("draft", "ready")
+	_ = prCmd.RegisterFlagCompletionFunc("ready-below", branchNameArgs)
 	prCmd.Flags().BoolVar(
 		&prFlags.Force, "force", false,
 		"force creation of a pull request even if there is already a pull request associated with this branch",
@@ -357,10 +931,34 @@ func init() {
 		&prFlags.Edit, "edit", false,
 		"edit the pull request title and description before submitting even if the pull request already exists",
 	)
+	prCmd.Flags().BoolVar(
+		&prFlags.Summarize, "summarize", false,
+		"suggest the pull request title and description with the pullRequest.summarizer command\nand edit them before submitting",
+	)
 	prCmd.Flags().StringSliceVar(
 		&prFlags.Reviewers, "reviewers", nil,
 		"add reviewers to the pull request (can be usernames or team names)",
 	)
+	prCmd.Flags().StringSliceVar(
+		&prFlags.Labels, "labels", nil,
+		"add labels to the pull request",
+	)
+	prCmd.Flags().StringSliceVar(
+		&prFlags.Assignees, "assignees", nil,
+		"assign users to the pull request (@me for yourself)",
+	)
+	prCmd.Flags().BoolVar(
+		&prFlags.Codeowners, "codeowners", false,
+		"request the code owners of the changed files as reviewers",
+	)
+	// --reviewer, --label, and --assignee are accepted as the aliases.
+	prCmd.Flags().SetNormalizeFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		switch name {
+		case "reviewer", "label", "assignee":
+			name += "s"
+		}
+		return pflag.NormalizedName(name)
+	})
 	prCmd.Flags().BoolVar(
 		&prFlags.Queue, "queue", false,
 		"queue an existing pull request for the current branch",
@@ -370,7 +968,48 @@ func init() {
 		"create pull requests for every branch in stack (up to current branch with --current)",
 	)
 	prCmd.Flags().BoolVar(
-		&prFlags.Current, "current", false,
+		&prFlags.Stdin, "stdin", false,
+		"create pull requests for the branches read from the standard input, one per line\n(e.g., the output of av query)",
+	)
+	prCmd.Flags().BoolVar(
+		&prFlags.DryRun, "dry-run", false,
+		"show the titles of the pull requests to be created without creating them",
+	)
+	prCmd.Flags().BoolVar(
+		&prFlags.Open, "open", false,
+		"open the submitted pull requests in the browser (with --all)",
+	)
+	prCmd.Flags().BoolVar(
+		&prFlags.Suggest, "suggest", false,
+		"suggest moving the branches that don't depend on their parents and splitting the large\nbranches before submitting (with --all)",
+	)
+	prCmd.Flags().BoolVar(
+		&prFlags.Open, "web", false,
+		"alias of --open",
+	)
+	_ = prCmd.Flags().MarkHidden("web")
+	prCmd.Flags().StringVar(
+		&prFlags.Since, "since", "",
+		"with --all, only submit the branches changed since the point: last-submit, an operation\nnumber (see av undo --list), a duration (e.g., 3d), a date, or a commit",
+	)
+	prCmd.Flags().BoolVar(
+		&prFlags.AutoMerge, "auto-merge", false,
+		"enable auto-merge on the pull requests so that they are merged once their checks pass\n(the pull requests above the bottom of the stack get it from av sync)",
+	)
+	prCmd.Flags().StringVar(
+		&prFlags.MergeMethod, "merge-method", "",
+		"the merge method of --auto-merge: merge, squash, or rebase (default: the\npullRequest.mergeMethod config, or squash)",
+	)
+	_ = prCmd.RegisterFlagCompletionFunc(
+		"merge-method",
+		cobra.FixedCompletions(
+			[]string{config.MergeMethodMerge, config.MergeMethodSquash, config.MergeMethodRebase},
+			cobra.ShellCompDirectiveNoFileComp,
+		),
+	)
+	addSubmitRangeFlags(prCmd, &prFlags.Range)
+	prCmd.Flags().BoolVar(
+		&prFlags.Range.Downstack, "current", false,
 		"create pull requests up to the current branch")
 	_ = prCmd.Flags().MarkHidden("current")
 
@@ -379,8 +1018,15 @@ func init() {
 
 	prCmd.AddCommand(
 		deprecatedCreateCmd,
+		prAttachCmd,
+		prBodyCmd,
+		prDiffCmd,
 		prQueueCmd,
+		prSplitReviewCmd,
 		prStatusCmd,
+		prTemplateCheckCmd,
+		prURLCmd,
+		prWaitCmd,
 	)
 
 }
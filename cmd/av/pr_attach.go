@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/cobra"
+)
+
+var prAttachFlags struct {
+	Branch string
+	Force  bool
+}
+
+var prAttachCmd = &cobra.Command{
+	Use:   "attach [<number>]",
+	Short: "Associate an existing pull request with a branch",
+	Long: strings.TrimSpace(`
+Associate an existing pull request (e.g., one created in the web UI or with gh)
+with the current branch.
+
+If the pull request number is not given, the open pull request whose head branch
+is the current branch is looked up. After the pull request is attached, av pr and
+av sync update it instead of creating a new one.
+
+The pull request must be open and its head branch must be the branch. If the
+branch already has an open pull request, --force replaces it.
+`),
+	SilenceUsage: true,
+	Args:         cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var number int64
+		if len(args) == 1 {
+			var err error
+			number, err = strconv.ParseInt(strings.TrimPrefix(args[0], "#"), 10, 64)
+			if err != nil || number <= 0 {
+				return errors.Errorf("invalid pull request number %q", args[0])
+			}
+		}
+
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		branchName := prAttachFlags.Branch
+		if branchName == "" {
+			branchName, err = repo.CurrentBranchName()
+			if err != nil {
+				return errors.WrapIf(err, "failed to determine current branch")
+			}
+		}
+
+		tx := db.WriteTx()
+		defer tx.Abort()
+		branch, ok := tx.Branch(branchName)
+		if !ok {
+			return actions.BranchNotAdoptedError{Branch: branchName}
+		}
+		if existing := branch.PullRequest; existing != nil &&
+			existing.State == githubv4.PullRequestStateOpen &&
+			existing.Number != number && !prAttachFlags.Force {
+			return errors.Errorf(
+				"branch %q already has an open pull request #%d (use --force to replace it)",
+				branchName, existing.Number,
+			)
+		}
+
+		f, err := getForge(tx.Repository())
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		var pull *gh.PullRequest
+		if number != 0 {
+			pull, err = f.PullRequestByNumber(ctx, number)
+			if err != nil {
+				return err
+			}
+		} else {
+			pull, err = findOpenPullRequest(ctx, f, branchName)
+			if err != nil {
+				return err
+			}
+		}
+
+		if pull.HeadBranchName() != branchName {
+			return errors.Errorf(
+				"pull request #%d is for branch %q, not %q",
+				pull.Number, pull.HeadBranchName(), branchName,
+			)
+		}
+		if pull.State != githubv4.PullRequestStateOpen {
+			return errors.Errorf(
+				"pull request #%d is %s",
+				pull.Number, strings.ToLower(string(pull.State)),
+			)
+		}
+
+		branch.PullRequest = &meta.PullRequest{
+			ID:        pull.ID,
+			Number:    pull.Number,
+			Permalink: pull.Permalink,
+			State:     pull.State,
+			Title:     pull.Title,
+			FetchedAt: time.Now(),
+		}
+		tx.SetBranch(branch)
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		fmt.Fprint(os.Stderr,
+			colors.Success("Attached pull request "), colors.UserInput("#", pull.Number),
+			colors.Success(" to branch "), colors.UserInput(branchName), "\n",
+			"  ", colors.Faint(pull.Permalink), "\n",
+		)
+		if pull.BaseBranchName() != branch.Parent.Name {
+			fmt.Fprint(os.Stderr,
+				colors.Faint("  - the base branch is "), colors.UserInput(pull.BaseBranchName()),
+				colors.Faint(" but the parent branch is "), colors.UserInput(branch.Parent.Name),
+				colors.Faint("; the next "), colors.CliCmd("av pr"),
+				colors.Faint(" updates it\n"),
+			)
+		}
+		return nil
+	},
+}
+
+// findOpenPullRequest returns the only open pull request whose head branch is the given branch.
+func findOpenPullRequest(
+	ctx context.Context,
+	f forge.Forge,
+	branchName string,
+) (*gh.PullRequest, error) {
+	pulls, err := f.BranchPullRequests(ctx, branchName)
+	if err != nil {
+		return nil, err
+	}
+	var open []gh.PullRequest
+	for _, pull := range pulls {
+		if pull.State == githubv4.PullRequestStateOpen {
+			open = append(open, pull)
+		}
+	}
+	switch len(open) {
+	case 0:
+		return nil, errors.Errorf("no open pull request found for branch %q", branchName)
+	case 1:
+		return &open[0], nil
+	}
+	var numbers []string
+	for _, pull := range open {
+		numbers = append(numbers, "#"+strconv.FormatInt(pull.Number, 10))
+	}
+	return nil, errors.Errorf(
+		"multiple open pull requests found for branch %q (%s); specify the number",
+		branchName, strings.Join(numbers, ", "),
+	)
+}
+
+func init() {
+	prAttachCmd.Flags().StringVar(
+		&prAttachFlags.Branch, "branch", "",
+		"the branch to attach the pull request to (default: the current branch)",
+	)
+	prAttachCmd.Flags().BoolVar(
+		&prAttachFlags.Force, "force", false,
+		"replace the open pull request that is already associated with the branch",
+	)
+	_ = prAttachCmd.RegisterFlagCompletionFunc("branch", branchNameArgs)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/cobra"
+)
+
+var prBodyCmd = &cobra.Command{
+	Use:   "body",
+	Short: "Manage the sections of the pull request bodies written by av",
+}
+
+var prBodySyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Re-render the pull request bodies of the current stack",
+	Long: strings.TrimSpace(`
+Re-render the sections of the pull request bodies that are written by av (the
+stack, the preview URL, and the metadata) for all the open pull requests in the
+current stack. The rest of the bodies are kept as is, and no branch is pushed.
+
+This is useful after changing the pullRequest.stackTemplate or
+pullRequest.stackLocation config, or after renaming or reparenting branches.
+Only the pull requests whose bodies change are updated.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+
+		tx := db.ReadTx()
+		if _, exists := tx.Branch(currentBranch); !exists {
+			return actions.BranchNotAdoptedError{Branch: currentBranch}
+		}
+		stackBranches, err := meta.StackBranches(tx, currentBranch)
+		if err != nil {
+			return err
+		}
+		var branches []meta.Branch
+		for _, name := range stackBranches {
+			br, _ := tx.Branch(name)
+			if br.IsFollowed() {
+				// The pull request is owned by someone else.
+				continue
+			}
+			if br.PullRequest != nil && br.PullRequest.State == githubv4.PullRequestStateOpen {
+				branches = append(branches, br)
+			}
+		}
+		if len(branches) == 0 {
+			return errors.New("no open pull requests in the stack (run 'av pr' to create one)")
+		}
+
+		f, err := getForge(tx.Repository())
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		for _, br := range branches {
+			changed, err := actions.SyncPullRequestBody(ctx, f, tx, br.Name)
+			if err != nil {
+				return errors.WrapIff(
+					err, "failed to update pull request #%d", br.PullRequest.Number,
+				)
+			}
+			pr := fmt.Sprintf("#%d", br.PullRequest.Number)
+			if changed {
+				fmt.Fprint(os.Stderr,
+					"  - updated ", colors.UserInput(pr), " (", colors.UserInput(br.Name), ")\n",
+				)
+			} else {
+				fmt.Fprint(os.Stderr,
+					colors.Faint("  - "+pr+" is up to date ("+br.Name+")\n"),
+				)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	prBodyCmd.AddCommand(prBodySyncCmd)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var prDiffFlags struct {
+	Stat bool
+}
+
+var prDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show the diff of the pull request as GitHub shows it",
+	Long: strings.TrimSpace(`
+Show the diff of the pull request of the current branch as GitHub shows it.
+
+GitHub shows the diff between the head of the pull request and the merge base
+of the head and the base branch (a three-dot diff). This command fetches the
+base and the head of the pull request and shows that diff. If it differs from
+the changes of the local branch relative to its parent branch (for example,
+because the base branch has moved and the pull request shows the changes of the
+parent branch), the differences are highlighted.
+`),
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		branch, _ := tx.Branch(currentBranch)
+		if branch.PullRequest == nil || branch.PullRequest.ID == "" {
+			return errors.New(
+				"this branch has no associated pull request (run 'av pr' to create one)",
+			)
+		}
+		client, err := getGitHubClient()
+		if err != nil {
+			return err
+		}
+		refs, err := client.PullRequestRefs(context.Background(), branch.PullRequest.ID)
+		if err != nil {
+			return err
+		}
+
+		// Make sure that the commits that GitHub sees are available locally.
+		if _, err := repo.Git(
+			"fetch", "--quiet", repo.GetRemoteName(),
+			strings.TrimPrefix(refs.BaseRefName, "refs/heads/"),
+			strings.TrimPrefix(refs.HeadRefName, "refs/heads/"),
+		); err != nil {
+			return errors.WrapIf(err, "failed to fetch the pull request")
+		}
+		serverBase, err := repo.MergeBase(refs.BaseRefOid, refs.HeadRefOid)
+		if err != nil {
+			return err
+		}
+
+		diffArgs := []string{"diff"}
+		if prDiffFlags.Stat {
+			diffArgs = append(diffArgs, "--stat")
+		}
+		diffArgs = append(diffArgs, serverBase, refs.HeadRefOid)
+		if _, err := repo.Run(&git.RunOpts{
+			Args:        diffArgs,
+			Interactive: true,
+		}); err != nil {
+			return err
+		}
+
+		// Compare against the local expectation after the diff so that the pager doesn't eat
+		// the messages.
+		localBase, err := branchDiffBase(repo, tx, currentBranch)
+		if err != nil {
+			return err
+		}
+		serverStats, err := repo.DiffStats(serverBase, refs.HeadRefOid)
+		if err != nil {
+			return err
+		}
+		localStats, err := repo.DiffStats(localBase, currentBranch)
+		if err != nil {
+			return err
+		}
+		localHead, err := repo.RevParse(&git.RevParse{Rev: currentBranch})
+		if err != nil {
+			return err
+		}
+
+		// A path changed differently in both diffs is reported in both lists.
+		onlyServer := diffStatsMissing(serverStats, localStats)
+		onlyLocal := diffStatsMissing(localStats, serverStats)
+		if localHead != refs.HeadRefOid {
+			fmt.Fprint(os.Stderr,
+				colors.Warning("\nWARNING: The pull request is not up to date with the local branch "),
+				colors.UserInput(currentBranch), colors.Warning(". Run "),
+				colors.CliCmd("av pr"), colors.Warning(" to push the branch.\n"),
+			)
+		}
+		if len(onlyServer) == 0 && len(onlyLocal) == 0 {
+			if localHead != refs.HeadRefOid {
+				return nil
+			}
+			fmt.Fprint(os.Stderr,
+				colors.Success("\nThe pull request diff matches the changes of the branch.\n"),
+			)
+			return nil
+		}
+		if len(onlyServer) > 0 {
+			fmt.Fprint(os.Stderr,
+				colors.Warning("\nWARNING: The pull request shows changes that are not part of the branch\n"),
+				colors.Warning("(e.g., the changes of the parent branch):\n"),
+			)
+			for _, p := range onlyServer {
+				fmt.Fprint(os.Stderr, "  - ", colors.UserInput(p), "\n")
+			}
+		}
+		if len(onlyLocal) > 0 {
+			fmt.Fprint(os.Stderr,
+				colors.Warning("\nWARNING: The pull request doesn't show these changes of the branch as expected:\n"),
+			)
+			for _, p := range onlyLocal {
+				fmt.Fprint(os.Stderr, "  - ", colors.UserInput(p), "\n")
+			}
+		}
+		fmt.Fprint(os.Stderr,
+			"\nThe pull request is based on ", colors.UserInput(refs.BaseRefName),
+			". Run ", colors.CliCmd("av sync"),
+			" to update the base branches of the stack and push the branches.\n",
+		)
+		return nil
+	},
+}
+
+// diffStatsMissing returns the paths in a whose changes are not in b.
+func diffStatsMissing(a, b []git.DiffStat) []string {
+	bStats := map[string]git.DiffStat{}
+	for _, stat := range b {
+		bStats[stat.Path] = stat
+	}
+	var ret []string
+	for _, stat := range a {
+		if bStat, ok := bStats[stat.Path]; !ok || bStat != stat {
+			ret = append(ret, stat.Path)
+		}
+	}
+	return ret
+}
+
+func init() {
+	prDiffCmd.Flags().BoolVar(
+		&prDiffFlags.Stat, "stat", false,
+		"show the diffstat instead of the full diff",
+	)
+}
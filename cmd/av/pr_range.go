@@ -0,0 +1,109 @@
+package main
+
+import (
+	"slices"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/spf13/cobra"
+)
+
+// submitRange is the part of the stack that av pr --all submits. The zero value is the whole
+// stack of the current branch.
+type submitRange struct {
+	// Only the current branch.
+	Only bool
+	// The current branch and the branches below it (--current or --downstack).
+	Downstack bool
+	// The current branch and the branches above it.
+	Upstack bool
+	// The branches from From up to To. Without From, the range starts at the bottom of the
+	// stack of To. Without To, the range includes all the branches above From.
+	From string
+	To   string
+}
+
+func (r submitRange) partial() bool {
+	return r.Only || r.Downstack || r.Upstack || r.From != "" || r.To != ""
+}
+
+func (r submitRange) validate() error {
+	var set []string
+	if r.Only {
+		set = append(set, "--only")
+	}
+	if r.Downstack {
+		set = append(set, "--downstack")
+	}
+	if r.Upstack {
+		set = append(set, "--upstack")
+	}
+	if r.From != "" || r.To != "" {
+		set = append(set, "--from/--to")
+	}
+	if len(set) > 1 {
+		return errors.Errorf("cannot use %s with %s", set[0], set[1])
+	}
+	return nil
+}
+
+// branches returns the branches in the range in the dependency order.
+func (r submitRange) branches(tx meta.ReadTx, currentBranch string) ([]string, error) {
+	if r.Only {
+		return []string{currentBranch}, nil
+	}
+	if r.Upstack {
+		return append([]string{currentBranch}, meta.SubsequentBranches(tx, currentBranch)...), nil
+	}
+	top := r.To
+	if r.Downstack {
+		top = currentBranch
+	}
+	if top == "" {
+		if _, ok := tx.Branch(r.From); !ok {
+			return nil, actions.BranchNotAdoptedError{Branch: r.From}
+		}
+		return append([]string{r.From}, meta.SubsequentBranches(tx, r.From)...), nil
+	}
+	previous, err := meta.PreviousBranches(tx, top)
+	if err != nil {
+		return nil, actions.BranchNotAdoptedError{Branch: top}
+	}
+	ret := append(previous, top)
+	if r.From != "" {
+		i := slices.Index(ret, r.From)
+		if i < 0 {
+			return nil, errors.Errorf("branch %q is not below %q in the stack", r.From, top)
+		}
+		ret = ret[i:]
+	}
+	return ret, nil
+}
+
+// addSubmitRangeFlags adds the flags that select the part of the stack to submit. The
+// --downstack flag is an alias of the --current flag, which is added by the caller.
+func addSubmitRangeFlags(cmd *cobra.Command, r *submitRange) {
+	cmd.Flags().BoolVar(
+		&r.Only, "only", false,
+		"only submit the current branch",
+	)
+	cmd.Flags().BoolVar(
+		&r.Downstack, "downstack", false,
+		"submit the current branch and the branches below it (same as --current)",
+	)
+	cmd.Flags().BoolVar(
+		&r.Upstack, "upstack", false,
+		"submit the current branch and the branches above it",
+	)
+	cmd.Flags().StringVar(
+		&r.From, "from", "",
+		"submit the branches starting from the given branch (up to --to, or the top of\nthe stack)",
+	)
+	cmd.Flags().StringVar(
+		&r.To, "to", "",
+		"submit the branches up to the given branch (from --from, or the bottom of the\nstack)",
+	)
+	_ = cmd.RegisterFlagCompletionFunc("from", branchNameArgs)
+	_ = cmd.RegisterFlagCompletionFunc("to", branchNameArgs)
+}
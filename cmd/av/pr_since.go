@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+)
+
+// sinceLastSubmit is the --since value that selects the branches changed since they were
+// pushed last time.
+const sinceLastSubmit = "last-submit"
+
+// filterBranchesChangedSince returns the branches whose heads changed since the given point
+// (av pr --all --since), printing the skipped branches. The point is one of:
+//
+//   - last-submit: the branches that differ from the pushed branches or have no pull request.
+//   - the number of an operation (as listed by av undo --list), a duration before now (e.g.,
+//     3d), or a date: the branches whose heads moved since then (or didn't exist then).
+//   - a commit: the branches that have commits not reachable from the commit.
+func filterBranchesChangedSince(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	branches []string,
+	since string,
+) ([]string, error) {
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, err
+	}
+	var changed func(name string) bool
+	var when string
+	_, atoiErr := strconv.Atoi(since)
+	_, isTime := parseSnapshotTime(since)
+	switch {
+	case since == sinceLastSubmit:
+		when = "since the last submit"
+		remote := repo.GetRemoteName()
+		changed = func(name string) bool {
+			br, _ := tx.Branch(name)
+			local, _ := refs.Branch(name)
+			pushed, ok := refs.RemoteBranch(remote, name)
+			return br.PullRequest == nil || !ok || pushed.OID != local.OID
+		}
+	case atoiErr == nil || isTime:
+		snapshot, at, err := readTreeSnapshot(repo, tx, since)
+		if err != nil {
+			return nil, err
+		}
+		when = "since " + strings.TrimPrefix(at, "at ")
+		changed = func(name string) bool {
+			local, _ := refs.Branch(name)
+			return snapshot.Refs[name] != local.OID
+		}
+	default:
+		commits, err := repo.ResolveRevisions([]string{since + "^{commit}"})
+		if err != nil {
+			return nil, err
+		}
+		commit, ok := commits[since+"^{commit}"]
+		if !ok {
+			return nil, errors.Errorf(
+				"invalid --since %q (expected %s, an operation number, a duration, a date, or a commit)",
+				since, sinceLastSubmit,
+			)
+		}
+		out, err := repo.Git(
+			"for-each-ref", "--format=%(refname:lstrip=2)", "--merged", commit, "refs/heads",
+		)
+		if err != nil {
+			return nil, err
+		}
+		merged := map[string]bool{}
+		for _, name := range strings.Split(out, "\n") {
+			merged[name] = true
+		}
+		when = "since " + since
+		changed = func(name string) bool { return !merged[name] }
+	}
+
+	var ret, skipped []string
+	for _, name := range branches {
+		if changed(name) {
+			ret = append(ret, name)
+		} else {
+			skipped = append(skipped, name)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprint(os.Stderr, colors.Faint(fmt.Sprintf(
+			"Skipping %d branch(es) unchanged %s: %s\n", len(skipped), when,
+			strings.Join(skipped, ", "),
+		)))
+	}
+	if len(ret) == 0 {
+		fmt.Fprint(os.Stderr, colors.Success("No branches have changed "+when+".\n"))
+	}
+	return ret, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/ghutils"
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/cobra"
+)
+
+var prSplitReviewFlags struct {
+	DryRun bool
+}
+
+var prSplitReviewCmd = &cobra.Command{
+	Use:   "split-review",
+	Short: "Request reviews from the code owners of each changed path",
+	Long: strings.TrimSpace(`
+Request reviews for the pull request of the current branch from the code owners
+of each changed path, as defined in the CODEOWNERS file.
+
+The changed files are grouped by their owners. For each group, the owners are
+requested for review and a comment that tags the owners with the list of the
+files they own is posted to the pull request, so that each team knows which
+part of the pull request to review. When the command is run again, the comments
+posted before are updated instead of posting new ones.
+
+If the --dry-run flag is given, the groups are printed without requesting
+reviews or posting comments.
+`),
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		branch, _ := tx.Branch(currentBranch)
+		if !prSplitReviewFlags.DryRun && (branch.PullRequest == nil || branch.PullRequest.ID == "") {
+			return errors.New(
+				"this branch has no associated pull request (run 'av pr' to create one)",
+			)
+		}
+
+		codeowners, err := ghutils.ReadCodeowners(repo)
+		if err != nil {
+			return err
+		}
+		if codeowners == nil {
+			return errors.New("this repository has no CODEOWNERS file")
+		}
+		paths, err := branchChangedPaths(repo, tx, currentBranch)
+		if err != nil {
+			return err
+		}
+		groups, unowned := groupPathsByOwners(codeowners, paths)
+		if len(groups) == 0 {
+			fmt.Fprint(os.Stderr, "No changed files have code owners.\n")
+			return nil
+		}
+
+		for _, group := range groups {
+			fmt.Fprint(os.Stderr,
+				"Files owned by ", colors.UserInput(strings.Join(group.owners, " ")), ":\n",
+			)
+			for _, p := range group.paths {
+				fmt.Fprint(os.Stderr, "  - ", p, "\n")
+			}
+		}
+		if len(unowned) > 0 {
+			fmt.Fprint(os.Stderr,
+				colors.Faint(fmt.Sprintf("%d file(s) have no code owners\n", len(unowned))),
+			)
+		}
+		if prSplitReviewFlags.DryRun {
+			return nil
+		}
+
+		client, err := getGitHubClient()
+		if err != nil {
+			return err
+		}
+		f, err := getForge(tx.Repository())
+		if err != nil {
+			return err
+		}
+		ctx := context.Background()
+		prID := githubv4.ID(branch.PullRequest.ID)
+		for _, group := range groups {
+			var reviewers []string
+			for _, owner := range group.owners {
+				// Email addresses cannot be requested for review by themselves.
+				if strings.HasPrefix(owner, "@") {
+					reviewers = append(reviewers, strings.TrimPrefix(owner, "@"))
+				}
+			}
+			if len(reviewers) > 0 {
+				if err := actions.AddPullRequestReviewers(ctx, client, prID, reviewers); err != nil {
+					return err
+				}
+			}
+			if err := f.UpsertComment(
+				ctx, branch.PullRequest.ID, splitReviewCommentMarker(group),
+				splitReviewComment(group),
+			); err != nil {
+				return err
+			}
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Requested reviews for "), colors.UserInput(len(groups)),
+			colors.Success(" group(s) of files on "), colors.UserInput(branch.PullRequest.Permalink),
+			"\n",
+		)
+		return nil
+	},
+}
+
+type ownedPaths struct {
+	owners []string
+	paths  []string
+}
+
+// branchDiffBase returns the commit that the changes of the given branch are relative to: the
+// branch point of the parent branch, or the merge base for a branch on top of the trunk.
+func branchDiffBase(repo *git.Repo, tx meta.ReadTx, branchName string) (string, error) {
+	branch, exists := tx.Branch(branchName)
+	if !exists {
+		return "", errors.Errorf("branch %q is not managed by av", branchName)
+	}
+	if branch.Parent.Trunk {
+		return repo.MergeBase(branch.Parent.Name, branchName)
+	}
+	return branch.Parent.Head, nil
+}
+
+// branchChangedPaths returns the paths changed by the given branch (relative to its parent).
+func branchChangedPaths(repo *git.Repo, tx meta.ReadTx, branchName string) ([]string, error) {
+	base, err := branchDiffBase(repo, tx, branchName)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := repo.DiffStats(base, branchName)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, stat := range stats {
+		paths = append(paths, stat.Path)
+	}
+	return paths, nil
+}
+
+// groupPathsByOwners groups the paths by their set of owners. The paths without owners are
+// returned separately.
+func groupPathsByOwners(
+	codeowners *ghutils.Codeowners,
+	paths []string,
+) (groups []ownedPaths, unowned []string) {
+	byOwners := map[string]*ownedPaths{}
+	for _, p := range paths {
+		owners := codeowners.Owners(p)
+		if len(owners) == 0 {
+			unowned = append(unowned, p)
+			continue
+		}
+		key := strings.Join(owners, " ")
+		if g, ok := byOwners[key]; ok {
+			g.paths = append(g.paths, p)
+		} else {
+			byOwners[key] = &ownedPaths{owners: owners, paths: []string{p}}
+		}
+	}
+	for _, g := range byOwners {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.Join(groups[i].owners, " ") < strings.Join(groups[j].owners, " ")
+	})
+	return groups, unowned
+}
+
+// splitReviewCommentMarker returns the marker of the comment posted for the group, so that the
+// comment is updated when av pr split-review is run again.
+func splitReviewCommentMarker(group ownedPaths) string {
+	return fmt.Sprintf("<!-- av pr split-review %s -->", strings.Join(group.owners, " "))
+}
+
+func splitReviewComment(group ownedPaths) string {
+	var sb strings.Builder
+	sb.WriteString(splitReviewCommentMarker(group))
+	sb.WriteString("\n")
+	fmt.Fprintf(&sb, "%s, please review the following files:\n\n", strings.Join(group.owners, " "))
+	for _, p := range group.paths {
+		fmt.Fprintf(&sb, "- `%s`\n", p)
+	}
+	return sb.String()
+}
+
+func init() {
+	prSplitReviewCmd.Flags().BoolVar(
+		&prSplitReviewFlags.DryRun, "dry-run", false,
+		"print the files grouped by owners without requesting reviews",
+	)
+}
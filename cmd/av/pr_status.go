@@ -5,25 +5,51 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aviator-co/av/internal/avgql"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/colors"
 	"github.com/aviator-co/av/internal/utils/timeutils"
 	"github.com/shurcooL/githubv4"
 	"github.com/shurcooL/graphql"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
+var prStatusFlags struct {
+	Stack    bool
+	Watch    bool
+	Interval time.Duration
+}
+
 var prStatusCmd = &cobra.Command{
-	Use:          "status",
-	Short:        "Get the status of the associated pull request",
+	Use:   "status",
+	Short: "Get the status of the associated pull request",
+	Long: strings.TrimSpace(`
+Get the status of the pull request of the current branch from Aviator.
+
+With --stack, show the pull requests of all the branches in the current stack
+instead: the mergeability, the results of the required checks, and the review
+approvals of each pull request, fetched from GitHub in one query. With --watch,
+the status is polled and re-rendered until all the pull requests are green
+(merged, or mergeable, approved, and passing the required checks).
+`),
 	SilenceUsage: true,
 	Args:         cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		variables, err := getQueryVariables()
+		if prStatusFlags.Stack || prStatusFlags.Watch {
+			return prStatusStack()
+		}
+		variables, pull, err := getQueryVariables()
 		if err != nil {
 			return err
 		}
+		if rootFlags.Offline {
+			return prStatusCached()
+		}
 
 		client, err := avgql.NewClient()
 		if err != nil {
@@ -66,6 +92,10 @@ var prStatusCmd = &cobra.Command{
 			} `graphql:"githubRepository(owner: $repoOwner, name:$repoName)"`
 		}
 		if err := client.Query(context.Background(), &query, variables); err != nil {
+			if gh.IsNetworkError(err) {
+				fmt.Fprint(os.Stderr, colors.Warning("Failed to reach Aviator: "+err.Error()+"\n"))
+				return prStatusCached()
+			}
 			return err
 		}
 		if err := query.CheckViewer(); err != nil {
@@ -77,6 +107,56 @@ var prStatusCmd = &cobra.Command{
 			return errors.New("pull request not found")
 		}
 
+		if jsonOutput() {
+			out := prStatusOutput{
+				Number:         int64(pr.Number),
+				Title:          string(pr.Title),
+				Status:         string(pr.Status),
+				StatusReason:   string(pr.StatusReason),
+				Author:         string(pr.Author.Login),
+				CreatedAt:      pr.CreatedAt.Time,
+				BaseBranch:     string(pr.BaseBranchName),
+				HeadBranch:     string(pr.HeadBranchName),
+				RequiredChecks: []requiredCheckOutput{},
+				Deployments:    []deploymentOutput{},
+			}
+			if !pr.QueuedAt.IsZero() {
+				out.QueuedAt = &pr.QueuedAt.Time
+			}
+			if !pr.MergedAt.IsZero() {
+				out.MergedAt = &pr.MergedAt.Time
+			}
+			for _, status := range pr.RequiredCheckStatuses {
+				out.RequiredChecks = append(out.RequiredChecks, requiredCheckOutput{
+					Name:   string(status.RequiredCheck.Pattern),
+					Result: string(status.Result),
+				})
+			}
+			for _, deployment := range getPullRequestDeployments(pull) {
+				out.Deployments = append(out.Deployments, deploymentOutput{
+					Environment: deployment.Environment,
+					State:       string(deployment.State),
+					URL:         deployment.EnvironmentURL,
+				})
+			}
+			if pr.BotPullRequest.Number != 0 {
+				out.BotPullRequest = &botPullRequestOutput{
+					Number:         int64(pr.BotPullRequest.Number),
+					RequiredChecks: []requiredCheckOutput{},
+				}
+				for _, status := range pr.BotPullRequest.RequiredCheckStatuses {
+					out.BotPullRequest.RequiredChecks = append(
+						out.BotPullRequest.RequiredChecks,
+						requiredCheckOutput{
+							Name:   string(status.RequiredCheck.Pattern),
+							Result: string(status.Result),
+						},
+					)
+				}
+			}
+			return printJSON(out)
+		}
+
 		// Print PR info
 		indent := "    "
 		fmt.Fprint(
@@ -168,6 +248,8 @@ var prStatusCmd = &cobra.Command{
 			)
 		}
 
+		printPullRequestDeployments(pull, indent)
+
 		// Get Bot Pull Request info
 		botPullRequest := query.GithubRepository.PullRequest.BotPullRequest
 		if botPullRequest.Number == 0 {
@@ -197,28 +279,61 @@ var prStatusCmd = &cobra.Command{
 	},
 }
 
-func getQueryVariables() (map[string]interface{}, error) {
+// prStatusOutput is the JSON output of av pr status.
+type prStatusOutput struct {
+	Number         int64                 `json:"number"`
+	Title          string                `json:"title"`
+	Status         string                `json:"status"`
+	StatusReason   string                `json:"statusReason,omitempty"`
+	Author         string                `json:"author"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	QueuedAt       *time.Time            `json:"queuedAt,omitempty"`
+	MergedAt       *time.Time            `json:"mergedAt,omitempty"`
+	BaseBranch     string                `json:"baseBranch"`
+	HeadBranch     string                `json:"headBranch"`
+	RequiredChecks []requiredCheckOutput `json:"requiredChecks"`
+	Deployments    []deploymentOutput    `json:"deployments"`
+	BotPullRequest *botPullRequestOutput `json:"botPullRequest,omitempty"`
+}
+
+type requiredCheckOutput struct {
+	Name   string `json:"name"`
+	Result string `json:"result"`
+}
+
+type deploymentOutput struct {
+	Environment string `json:"environment"`
+	State       string `json:"state"`
+	URL         string `json:"url,omitempty"`
+}
+
+type botPullRequestOutput struct {
+	Number         int64                 `json:"number"`
+	RequiredChecks []requiredCheckOutput `json:"requiredChecks"`
+}
+
+func getQueryVariables() (map[string]interface{}, *meta.PullRequest, error) {
 	repo, err := getRepo()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	db, err := getDB(repo)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	tx := db.ReadTx()
 
 	currentBranchName, err := repo.CurrentBranchName()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	branch, _ := tx.Branch(currentBranchName)
 
 	if branch.PullRequest == nil {
-		return nil, errors.New(
+		return nil, nil, errors.New(
 			"this branch has no associated pull request (run 'av pr' to create one)",
 		)
 	}
@@ -230,7 +345,89 @@ func getQueryVariables() (map[string]interface{}, error) {
 		"repoName":  graphql.String(repository.Name),
 		"prNumber":  graphql.Int(prNumber), //nolint:gosec
 	}
-	return variables, nil
+	return variables, branch.PullRequest, nil
+}
+
+// prStatusCached prints the status of the pull request of the current branch cached in the
+// metadata.
+func prStatusCached() error {
+	repo, err := getRepo()
+	if err != nil {
+		return err
+	}
+	db, err := getDB(repo)
+	if err != nil {
+		return err
+	}
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return err
+	}
+	return printCachedPRStackStatuses(db.ReadTx(), []string{currentBranch})
+}
+
+// printPullRequestDeployments prints the deployment statuses of the pull request's head commit.
+// This is best-effort since the deployments are queried from GitHub instead of Aviator.
+func printPullRequestDeployments(pull *meta.PullRequest, indent string) {
+	deployments := getPullRequestDeployments(pull)
+	if len(deployments) == 0 {
+		return
+	}
+	fmt.Fprint(os.Stderr, "Deployments\n")
+	for _, deployment := range deployments {
+		fmt.Fprint(
+			os.Stderr,
+			indent,
+			emojiForDeployment(deployment),
+			" ",
+			colors.UserInput(deployment.Environment),
+			" ",
+			colors.Faint(deploymentStateString(deployment)),
+		)
+		if deployment.EnvironmentURL != "" {
+			fmt.Fprint(os.Stderr, " ", deployment.EnvironmentURL)
+		}
+		fmt.Fprint(os.Stderr, "\n")
+	}
+}
+
+// getPullRequestDeployments returns the deployments of the pull request's head commit, or nil
+// if they can't be queried from GitHub.
+func getPullRequestDeployments(pull *meta.PullRequest) []gh.Deployment {
+	if pull.ID == "" {
+		return nil
+	}
+	client, err := getGitHubClient()
+	if err != nil {
+		if !errors.Is(err, errOffline) {
+			logrus.WithError(err).Warning("failed to create a GitHub client, ignoring deployments")
+		}
+		return nil
+	}
+	deployments, err := client.PullRequestDeployments(context.Background(), pull.ID)
+	if err != nil {
+		logrus.WithError(err).Warning("failed to query the deployments")
+		return nil
+	}
+	return deployments
+}
+
+func deploymentStateString(deployment gh.Deployment) string {
+	if deployment.State == "" {
+		return "no status"
+	}
+	return strings.ToLower(strings.ReplaceAll(string(deployment.State), "_", " "))
+}
+
+func emojiForDeployment(deployment gh.Deployment) string {
+	switch {
+	case deployment.Succeeded():
+		return "\u2705"
+	case deployment.Failed():
+		return "\u274C"
+	default:
+		return "\u231B"
+	}
 }
 
 func emojiForRequiredCheckResult(result string) string {
@@ -243,3 +440,18 @@ func emojiForRequiredCheckResult(result string) string {
 		return "\u231B"
 	}
 }
+
+func init() {
+	prStatusCmd.Flags().BoolVar(
+		&prStatusFlags.Stack, "stack", false,
+		"show the checks and the reviews of the pull requests in the current stack",
+	)
+	prStatusCmd.Flags().BoolVar(
+		&prStatusFlags.Watch, "watch", false,
+		"poll until all the pull requests in the current stack are green (implies --stack)",
+	)
+	prStatusCmd.Flags().DurationVar(
+		&prStatusFlags.Interval, "interval", 30*time.Second,
+		"the interval between the polls with --watch",
+	)
+}
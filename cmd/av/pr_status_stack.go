@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/dustin/go-humanize"
+	"github.com/mattn/go-isatty"
+	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+)
+
+// prStackStatusOutput is the JSON output of av pr status --stack for a branch.
+type prStackStatusOutput struct {
+	Branch         string                `json:"branch"`
+	Number         int64                 `json:"number,omitempty"`
+	State          string                `json:"state,omitempty"`
+	Mergeable      string                `json:"mergeable,omitempty"`
+	ReviewDecision string                `json:"reviewDecision,omitempty"`
+	Approvers      []string              `json:"approvers"`
+	RequiredChecks []requiredCheckOutput `json:"requiredChecks"`
+	Green          bool                  `json:"green"`
+	// The report of the conflict that stopped the last restack of the branch, if any.
+	Conflict *meta.BranchConflict `json:"conflict,omitempty"`
+	// True if the status is the one cached in the metadata, fetched at FetchedAt.
+	Cached    bool       `json:"cached,omitempty"`
+	FetchedAt *time.Time `json:"fetchedAt,omitempty"`
+}
+
+// prStackStatus is the pull request status of a branch in the stack. Review is nil if the
+// branch has no pull request.
+type prStackStatus struct {
+	Branch   string
+	Review   *gh.PullRequestReview
+	Conflict *meta.BranchConflict
+	// True if the status is the one cached in the metadata (e.g., with --offline). FetchedAt is
+	// when it was fetched (zero if unknown).
+	Cached    bool
+	FetchedAt time.Time
+}
+
+func prStatusStack() error {
+	repo, err := getRepo()
+	if err != nil {
+		return err
+	}
+	db, err := getDB(repo)
+	if err != nil {
+		return err
+	}
+	tx := db.ReadTx()
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return err
+	}
+	branches, err := meta.StackBranches(tx, currentBranch)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for _, name := range branches {
+		br, _ := tx.Branch(name)
+		if br.PullRequest != nil && br.PullRequest.ID != "" {
+			ids = append(ids, br.PullRequest.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return errors.New(
+			"no branch in the current stack has a pull request (run 'av pr --all' to create them)",
+		)
+	}
+	client, err := getGitHubClient()
+	if errors.Is(err, errOffline) {
+		return printCachedPRStackStatuses(tx, branches)
+	} else if err != nil {
+		return err
+	}
+
+	clearScreen := prStatusFlags.Watch && !jsonOutput() && isatty.IsTerminal(os.Stdout.Fd())
+	for {
+		reviews, err := client.PullRequestReviews(context.Background(), ids)
+		if err != nil {
+			if gh.IsNetworkError(err) {
+				fmt.Fprint(os.Stderr, colors.Warning("Failed to reach GitHub: "+err.Error()+"\n"))
+				return printCachedPRStackStatuses(db.ReadTx(), branches)
+			}
+			return err
+		}
+		cachePullRequestReviews(db, reviews)
+		byID := map[string]*gh.PullRequestReview{}
+		for i := range reviews {
+			byID[reviews[i].ID] = &reviews[i]
+		}
+		var statuses []prStackStatus
+		green := true
+		for _, name := range branches {
+			status := prStackStatus{Branch: name}
+			br, _ := tx.Branch(name)
+			if br.PullRequest != nil {
+				status.Review = byID[br.PullRequest.ID]
+			}
+			status.Conflict = br.Conflict
+			if status.Review != nil && !status.Review.Green() {
+				green = false
+			}
+			statuses = append(statuses, status)
+		}
+
+		if jsonOutput() {
+			if err := printJSON(prStackStatusesOutput(statuses)); err != nil {
+				return err
+			}
+		} else {
+			if clearScreen {
+				fmt.Fprint(os.Stdout, "\x1b[H\x1b[2J")
+			}
+			fmt.Fprint(os.Stdout, renderPRStackStatuses(statuses))
+		}
+		if !prStatusFlags.Watch || green {
+			return nil
+		}
+		if !jsonOutput() {
+			fmt.Fprint(os.Stdout, colors.Faint(
+				"Waiting for the pull requests to be green (updated at "+
+					time.Now().Format(time.TimeOnly)+")...\n",
+			))
+		}
+		time.Sleep(prStatusFlags.Interval)
+	}
+}
+
+// printCachedPRStackStatuses prints the pull request statuses of the branches cached in the
+// metadata when GitHub is not reachable. The pull requests whose review status was never
+// fetched are shown with the state last fetched by av sync.
+func printCachedPRStackStatuses(tx meta.ReadTx, branches []string) error {
+	var statuses []prStackStatus
+	for _, name := range branches {
+		br, _ := tx.Branch(name)
+		status := prStackStatus{Branch: name, Conflict: br.Conflict, Cached: true}
+		if pr := br.PullRequest; pr != nil {
+			status.Review = cachedPullRequestReview(pr)
+			status.FetchedAt = pr.FetchedAt
+			if pr.Review != nil {
+				status.FetchedAt = pr.Review.FetchedAt
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	if jsonOutput() {
+		return printJSON(prStackStatusesOutput(statuses))
+	}
+	fmt.Fprint(os.Stderr, colors.Warning("Offline: showing the cached status of the pull requests.\n"))
+	fmt.Fprint(os.Stdout, renderPRStackStatuses(statuses))
+	return nil
+}
+
+// cachePullRequestReviews records the fetched review statuses in the metadata so that they can
+// be shown offline.
+func cachePullRequestReviews(db meta.DB, reviews []gh.PullRequestReview) {
+	byID := map[string]*gh.PullRequestReview{}
+	for i := range reviews {
+		byID[reviews[i].ID] = &reviews[i]
+	}
+	now := time.Now()
+	tx := db.WriteTx()
+	for _, br := range tx.AllBranches() {
+		if br.PullRequest == nil {
+			continue
+		}
+		review, ok := byID[br.PullRequest.ID]
+		if !ok {
+			continue
+		}
+		// The pull request is shared with the read transactions, so it's copied.
+		pr := *br.PullRequest
+		pr.State = review.State
+		pr.FetchedAt = now
+		pr.Review = &meta.PullRequestReview{
+			FetchedAt:      now,
+			IsDraft:        review.IsDraft,
+			Mergeable:      review.Mergeable,
+			ReviewDecision: review.ReviewDecision,
+			Approvers:      review.Approvers,
+		}
+		for _, check := range review.Checks {
+			pr.Review.Checks = append(pr.Review.Checks, meta.PullRequestCheck{
+				Name:     check.Name,
+				Result:   string(check.Result),
+				Required: check.Required,
+			})
+		}
+		br.PullRequest = &pr
+		tx.SetBranch(br)
+	}
+	if err := tx.Commit(); err != nil {
+		logrus.WithError(err).Warning("failed to cache the pull request statuses")
+	}
+}
+
+// cachedPullRequestReview returns the review status of the pull request cached in the metadata.
+func cachedPullRequestReview(pr *meta.PullRequest) *gh.PullRequestReview {
+	review := &gh.PullRequestReview{ID: pr.ID, Number: pr.Number, State: pr.State}
+	if cached := pr.Review; cached != nil {
+		review.IsDraft = cached.IsDraft
+		review.Mergeable = cached.Mergeable
+		review.ReviewDecision = cached.ReviewDecision
+		review.Approvers = cached.Approvers
+		for _, check := range cached.Checks {
+			review.Checks = append(review.Checks, gh.Check{
+				Name:     check.Name,
+				Result:   gh.CheckResult(check.Result),
+				Required: check.Required,
+			})
+		}
+	}
+	return review
+}
+
+func renderPRStackStatuses(statuses []prStackStatus) string {
+	var sb strings.Builder
+	indent := "    "
+	for _, status := range statuses {
+		sb.WriteString(colors.UserInput(status.Branch))
+		review := status.Review
+		if review == nil {
+			sb.WriteString(colors.Faint(" (no pull request)\n"))
+			renderPRStackConflict(&sb, indent, status.Conflict)
+			continue
+		}
+		sb.WriteString(fmt.Sprintf(" #%d ", review.Number))
+		if review.Green() {
+			sb.WriteString(colors.Success("green"))
+		} else {
+			sb.WriteString(colors.Faint(strings.Join(prReviewSummary(review), ", ")))
+		}
+		if status.Cached {
+			sb.WriteString(colors.Warning(" " + cachedStatusAge(status.FetchedAt)))
+		}
+		sb.WriteString("\n")
+		renderPRStackConflict(&sb, indent, status.Conflict)
+		if review.State != githubv4.PullRequestStateOpen {
+			continue
+		}
+		for _, check := range review.RequiredChecks() {
+			sb.WriteString(indent + emojiForCheckResult(check.Result) + " " + check.Name + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// cachedStatusAge describes how old the cached status is.
+func cachedStatusAge(fetchedAt time.Time) string {
+	if fetchedAt.IsZero() {
+		return "(stale)"
+	}
+	return "(stale, fetched " + humanize.Time(fetchedAt) + ")"
+}
+
+// renderPRStackConflict renders the conflict report of the branch, if any, with the conflicting
+// commits and the suggested commands to resolve it.
+func renderPRStackConflict(sb *strings.Builder, indent string, conflict *meta.BranchConflict) {
+	if conflict == nil {
+		return
+	}
+	sb.WriteString(indent + colors.Warning(conflictSummary(conflict)) + "\n")
+	if conflict.Commit != "" {
+		sb.WriteString(indent + "  commit " + conflict.Commit + "\n")
+	}
+	for _, commit := range conflict.ParentCommits {
+		sb.WriteString(indent + "  vs " + conflict.Parent + " commit " + commit + "\n")
+	}
+	for _, command := range conflict.Commands {
+		sb.WriteString(indent + "  " + colors.CliCmd(command) + "\n")
+	}
+}
+
+// prReviewSummary returns the short descriptions of what the pull request is waiting for.
+func prReviewSummary(review *gh.PullRequestReview) []string {
+	switch review.State {
+	case githubv4.PullRequestStateMerged:
+		return []string{"merged"}
+	case githubv4.PullRequestStateClosed:
+		return []string{"closed"}
+	}
+	var ss []string
+	if review.IsDraft {
+		ss = append(ss, "draft")
+	}
+	switch review.Mergeable {
+	case githubv4.MergeableStateMergeable:
+		ss = append(ss, "mergeable")
+	case githubv4.MergeableStateConflicting:
+		ss = append(ss, "conflicting")
+	default:
+		ss = append(ss, "mergeability unknown")
+	}
+	switch review.ReviewDecision {
+	case githubv4.PullRequestReviewDecisionChangesRequested:
+		ss = append(ss, "changes requested")
+	case githubv4.PullRequestReviewDecisionReviewRequired:
+		ss = append(ss, "review required")
+	}
+	if len(review.Approvers) > 0 {
+		ss = append(ss, "approved by "+strings.Join(review.Approvers, ", "))
+	}
+	return ss
+}
+
+func prStackStatusesOutput(statuses []prStackStatus) []prStackStatusOutput {
+	ret := []prStackStatusOutput{}
+	for _, status := range statuses {
+		out := prStackStatusOutput{
+			Branch:         status.Branch,
+			Approvers:      []string{},
+			RequiredChecks: []requiredCheckOutput{},
+			Conflict:       status.Conflict,
+			Cached:         status.Cached,
+		}
+		if !status.FetchedAt.IsZero() {
+			out.FetchedAt = &status.FetchedAt
+		}
+		if review := status.Review; review != nil {
+			out.Number = review.Number
+			out.State = string(review.State)
+			out.Mergeable = string(review.Mergeable)
+			out.ReviewDecision = string(review.ReviewDecision)
+			out.Approvers = append(out.Approvers, review.Approvers...)
+			for _, check := range review.RequiredChecks() {
+				out.RequiredChecks = append(out.RequiredChecks, requiredCheckOutput{
+					Name:   check.Name,
+					Result: string(check.Result),
+				})
+			}
+			out.Green = review.Green()
+		}
+		ret = append(ret, out)
+	}
+	return ret
+}
+
+func emojiForCheckResult(result gh.CheckResult) string {
+	switch result {
+	case gh.CheckResultPass:
+		return "\u2705"
+	case gh.CheckResultFail:
+		return "\u274C"
+	default:
+		return "\u231B"
+	}
+}
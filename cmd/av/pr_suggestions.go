@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+)
+
+// showSubmitSuggestions prints the suggestions to reorder or split the branches to submit. If
+// prompt is true and the input is a terminal, the user is asked whether to apply each
+// suggested move, and the accepted ones are returned.
+func showSubmitSuggestions(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	branchNames []string,
+	prompt bool,
+) ([]actions.SubmitSuggestion, error) {
+	suggestions, err := actions.SubmitSuggestions(
+		repo, tx, branchNames, config.Av.PullRequest.Suggestions.LargeBranchLines,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(suggestions) == 0 {
+		return nil, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Suggestions for the stack:\n")
+	hasMoves := false
+	for _, s := range suggestions {
+		switch s.Kind {
+		case actions.SubmitSuggestionParallel:
+			hasMoves = true
+			fmt.Fprint(os.Stderr,
+				"  - ", colors.UserInput(s.Branch), " doesn't change the files changed by ",
+				colors.UserInput(s.Parent), "; it may be reviewed in parallel on ",
+				colors.UserInput(s.NewParent), "\n",
+				colors.Faint("    run "), colors.CliCmd("av reparent --parent "+s.NewParent),
+				colors.Faint(" on the branch to move it\n"),
+			)
+		case actions.SubmitSuggestionSplit:
+			fmt.Fprint(os.Stderr,
+				"  - ", colors.UserInput(s.Branch), " changes ",
+				colors.UserInput(strconv.Itoa(s.Lines)),
+				" lines, much more than the other branches\n",
+				colors.Faint("    run "), colors.CliCmd("av split"),
+				colors.Faint(" on the branch to split it into smaller branches\n"),
+			)
+		}
+	}
+	fmt.Fprint(os.Stderr, "\n")
+	if !prompt || !hasMoves || !uiutils.IsInteractive() {
+		return nil, nil
+	}
+
+	var accepted []actions.SubmitSuggestion
+	reader := bufio.NewReader(os.Stdin)
+	for _, s := range suggestions {
+		if s.Kind != actions.SubmitSuggestionParallel {
+			continue
+		}
+		fmt.Fprint(os.Stderr, "Move ", colors.UserInput(s.Branch), " onto ",
+			colors.UserInput(s.NewParent), "? [y/N] ")
+		answer, _ := reader.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			accepted = append(accepted, s)
+		}
+	}
+	return accepted, nil
+}
+
+// applySubmitSuggestions moves the branches (with their children) onto the suggested parents.
+func applySubmitSuggestions(repo *git.Repo, db meta.DB, suggestions []actions.SubmitSuggestion) error {
+	for _, s := range suggestions {
+		reparentFlags.Parent = s.NewParent
+		if err := uiutils.RunBubbleTea(&reparentViewModel{
+			repo:          repo,
+			db:            db,
+			stdinBranches: []string{s.Branch},
+		}); err != nil {
+			return err
+		}
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Success("The stack is reordered."), " Review it with ", colors.CliCmd("av tree"),
+		" and run ", colors.CliCmd("av pr --all"), " again to submit it.\n",
+	)
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/cobra"
+)
+
+var prTemplateCheckFlags struct {
+	All bool
+	Fix bool
+}
+
+var prTemplateCheckCmd = &cobra.Command{
+	Use:   "template-check",
+	Short: "Check that the pull request descriptions have the required sections",
+	Long: strings.TrimSpace(`
+Check that the description of the pull request associated with the current
+branch has the sections required by pullRequest.requiredSections in the config
+(e.g., "Test Plan" or "Risk"). A section is missing if there's no Markdown
+heading for it or if it has nothing but the comments of the pull request
+template.
+
+If the --all flag is given, the pull requests of all the branches in the current
+stack are checked. If the --fix flag is given, you are asked to fill in the
+missing sections and the pull request descriptions are updated.
+
+The command exits with a non-zero status if a section is missing.
+`),
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		required := config.Av.PullRequest.RequiredSections
+		if len(required) == 0 {
+			return errors.New("no required sections are configured (set pullRequest.requiredSections)")
+		}
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		client, err := getGitHubClient()
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+
+		tx := db.ReadTx()
+		branches := []string{currentBranch}
+		if prTemplateCheckFlags.All {
+			branches, err = meta.StackBranches(tx, currentBranch)
+			if err != nil {
+				return err
+			}
+		}
+
+		ctx := context.Background()
+		ok := true
+		for _, name := range branches {
+			branch, _ := tx.Branch(name)
+			if branch.PullRequest == nil {
+				fmt.Fprint(os.Stderr,
+					colors.Faint("  - branch "), colors.UserInput(name),
+					colors.Faint(" has no pull request (skipping)\n"),
+				)
+				continue
+			}
+			pr, err := client.PullRequest(ctx, branch.PullRequest.ID)
+			if err != nil {
+				return err
+			}
+			body, _, _ := actions.ParsePRBody(pr.Body)
+			missing := actions.MissingPullRequestSections(required, body)
+			if len(missing) == 0 {
+				fmt.Fprint(os.Stderr,
+					"  - ", colors.UserInput(pr.Permalink), colors.Success(" has all the required sections\n"),
+				)
+				continue
+			}
+			fmt.Fprint(os.Stderr,
+				"  - ", colors.UserInput(pr.Permalink), colors.Failure(" is missing "),
+				colors.UserInput(strings.Join(missing, ", ")), "\n",
+			)
+			if !prTemplateCheckFlags.Fix {
+				ok = false
+				continue
+			}
+			if err := uiutils.RequireInteractive("filling in the missing sections", ""); err != nil {
+				return err
+			}
+			fixed, err := actions.PromptPullRequestSections(os.Stdin, os.Stderr, pr.Body, missing)
+			if err != nil {
+				return err
+			}
+			if _, err := client.UpdatePullRequest(ctx, githubv4.UpdatePullRequestInput{
+				PullRequestID: githubv4.ID(pr.ID),
+				Body:          githubv4.NewString(githubv4.String(fixed)),
+			}); err != nil {
+				return errors.WrapIf(err, "failed to update the pull request description")
+			}
+			fmt.Fprint(os.Stderr,
+				"  - updated the description of ", colors.UserInput(pr.Permalink), "\n",
+			)
+		}
+		if !ok {
+			fmt.Fprint(os.Stderr,
+				colors.Faint("  - run "), colors.CliCmd("av pr template-check --fix"),
+				colors.Faint(" to fill in the missing sections\n"),
+			)
+			return actions.ErrExitSilently{ExitCode: 1}
+		}
+		return nil
+	},
+}
+
+func init() {
+	prTemplateCheckCmd.Flags().BoolVar(
+		&prTemplateCheckFlags.All, "all", false,
+		"check the pull requests of all the branches in the current stack",
+	)
+	prTemplateCheckCmd.Flags().BoolVar(
+		&prTemplateCheckFlags.Fix, "fix", false,
+		"fill in the missing sections interactively and update the pull requests",
+	)
+}
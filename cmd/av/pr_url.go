@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/atotto/clipboard"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var prURLFlags struct {
+	All  bool
+	Copy bool
+}
+
+var prURLCmd = &cobra.Command{
+	Use:   "url",
+	Short: "Print the URLs of the pull requests in the stack",
+	Long: strings.TrimSpace(`
+Print the URL of the pull request associated with the current branch.
+
+If the --all flag is given, the URLs of all the pull requests in the current
+stack are printed in stack order (from the stack root to the leaves). Branches
+that don't have a pull request are skipped.
+
+If the --copy flag is given, the URLs are also copied to the system clipboard.
+`),
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+
+		tx := db.ReadTx()
+		branches := []string{currentBranch}
+		if prURLFlags.All {
+			branches, err = meta.StackBranches(tx, currentBranch)
+			if err != nil {
+				return err
+			}
+		}
+
+		var urls []string
+		for _, name := range branches {
+			branch, _ := tx.Branch(name)
+			if branch.PullRequest == nil || branch.PullRequest.Permalink == "" {
+				fmt.Fprint(os.Stderr,
+					colors.Faint("  - branch "), colors.UserInput(name),
+					colors.Faint(" has no pull request (skipping)\n"),
+				)
+				continue
+			}
+			urls = append(urls, branch.PullRequest.Permalink)
+		}
+		if len(urls) == 0 {
+			return errors.New(
+				"no pull requests found (run 'av pr' to create one)",
+			)
+		}
+
+		text := strings.Join(urls, "\n")
+		fmt.Println(text)
+		if prURLFlags.Copy {
+			if err := clipboard.WriteAll(text); err != nil {
+				return errors.WrapIf(err, "failed to copy the URLs to the clipboard")
+			}
+			fmt.Fprint(os.Stderr,
+				colors.Success("Copied "), colors.UserInput(len(urls)),
+				colors.Success(" URL(s) to the clipboard.\n"),
+			)
+		}
+		return nil
+	},
+}
+
+func init() {
+	prURLCmd.Flags().BoolVar(
+		&prURLFlags.All, "all", false,
+		"print the URLs of all pull requests in the current stack",
+	)
+	prURLCmd.Flags().BoolVar(
+		&prURLFlags.Copy, "copy", false,
+		"copy the URLs to the system clipboard",
+	)
+}
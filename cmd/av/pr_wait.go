@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var prWaitFlags struct {
+	Deployment string
+	Timeout    time.Duration
+	Interval   time.Duration
+}
+
+var prWaitCmd = &cobra.Command{
+	Use:   "wait --deployment <environment>",
+	Short: "Wait for a deployment of the pull request to finish",
+	Long: strings.TrimSpace(`
+Wait for the deployment of the current branch's pull request to the given
+environment (e.g., a preview environment) to finish.
+
+The command exits with zero when the latest deployment of the head commit of the
+pull request succeeds, and with non-zero when it fails or the timeout is reached.
+This is useful for the workflows that gate the merge on a preview environment.
+
+Examples:
+  Wait for the preview environment to be deployed:
+    $ av pr wait --deployment preview
+`),
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		branch, _ := db.ReadTx().Branch(currentBranch)
+		if branch.PullRequest == nil || branch.PullRequest.ID == "" {
+			return errors.New(
+				"this branch has no associated pull request (run 'av pr' to create one)",
+			)
+		}
+		client, err := getGitHubClient()
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), prWaitFlags.Timeout)
+		defer cancel()
+		fmt.Fprint(os.Stderr,
+			"Waiting for the deployment to ", colors.UserInput(prWaitFlags.Deployment),
+			" of pull request ", colors.UserInput(branch.PullRequest.Permalink), "...\n",
+		)
+		var lastState string
+		for {
+			deployment, err := findDeployment(ctx, client, branch.PullRequest.ID, prWaitFlags.Deployment)
+			if err != nil {
+				if ctx.Err() == nil {
+					return err
+				}
+			} else if deployment != nil {
+				switch {
+				case deployment.Succeeded():
+					fmt.Fprint(os.Stderr, colors.Success("Deployment to "),
+						colors.UserInput(prWaitFlags.Deployment), colors.Success(" succeeded."))
+					if deployment.EnvironmentURL != "" {
+						fmt.Fprint(os.Stderr, " ", deployment.EnvironmentURL)
+					}
+					fmt.Fprint(os.Stderr, "\n")
+					return nil
+				case deployment.Failed():
+					fmt.Fprint(os.Stderr, colors.Failure("Deployment to "),
+						colors.UserInput(prWaitFlags.Deployment), colors.Failure(" failed.\n"))
+					return actions.ErrExitSilently{ExitCode: 1}
+				}
+				if state := deploymentStateString(*deployment); state != lastState {
+					fmt.Fprint(os.Stderr, colors.Faint("  - deployment is "+state+"\n"))
+					lastState = state
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				fmt.Fprint(os.Stderr, colors.Failure("Timed out waiting for the deployment to "),
+					colors.UserInput(prWaitFlags.Deployment), colors.Failure(".\n"))
+				return actions.ErrExitSilently{ExitCode: 1}
+			case <-time.After(prWaitFlags.Interval):
+			}
+		}
+	},
+}
+
+// findDeployment returns the latest deployment of the pull request to the given environment,
+// or nil if there's none yet.
+func findDeployment(
+	ctx context.Context,
+	client *gh.Client,
+	pullRequestID string,
+	environment string,
+) (*gh.Deployment, error) {
+	deployments, err := client.PullRequestDeployments(ctx, pullRequestID)
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments {
+		if deployment.Environment == environment {
+			return &deployment, nil
+		}
+	}
+	return nil, nil
+}
+
+func init() {
+	prWaitCmd.Flags().StringVar(
+		&prWaitFlags.Deployment, "deployment", "",
+		"the name of the deployment environment to wait for",
+	)
+	_ = prWaitCmd.MarkFlagRequired("deployment")
+	prWaitCmd.Flags().DurationVar(
+		&prWaitFlags.Timeout, "timeout", 30*time.Minute,
+		"the maximum time to wait",
+	)
+	prWaitCmd.Flags().DurationVar(
+		&prWaitFlags.Interval, "interval", 15*time.Second,
+		"the interval between the status checks",
+	)
+}
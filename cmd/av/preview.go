@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var previewFlags struct {
+	Branch     string
+	Deployment string
+}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Manage the preview deployment URLs of the branches",
+	Long: strings.TrimSpace(`
+Manage the preview deployment URLs of the branches.
+
+A branch can be associated with the URL of its preview deployment (e.g., a
+preview environment deployed from the pull request). The URL is shown in av tree,
+and it's written to the pull request body together with the preview URLs of the
+other branches in the stack.
+
+The URL can be set from a hook that runs after the deployment, for example:
+    $ av preview set --branch "$BRANCH" "$DEPLOY_URL"
+`),
+}
+
+var previewShowCmd = &cobra.Command{
+	Use:               "show [<branch>]",
+	Short:             "Show the preview URL of a branch",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: branchNameArgAt(0),
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		branchName, err := notesBranchName(repo, args)
+		if err != nil {
+			return err
+		}
+		branch, ok := db.ReadTx().Branch(branchName)
+		if !ok {
+			return actions.BranchNotAdoptedError{Branch: branchName}
+		}
+		if branch.PreviewURL == "" {
+			fmt.Fprint(os.Stderr,
+				colors.Faint("Branch "), colors.UserInput(branchName), colors.Faint(" has no preview URL.\n"),
+			)
+			return nil
+		}
+		fmt.Println(branch.PreviewURL)
+		return nil
+	},
+}
+
+var previewSetCmd = &cobra.Command{
+	Use:   "set [<url> | --deployment <environment>]",
+	Short: "Set the preview URL of a branch",
+	Long: strings.TrimSpace(`
+Set the preview URL of the current branch (or the branch given with --branch).
+
+With --deployment, the URL of the latest deployment of the pull request to the
+given environment on GitHub is used.
+
+If the branch has an open pull request, the pull request body is updated.
+`),
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (len(args) == 0) == (previewFlags.Deployment == "") {
+			return errors.New("exactly one of <url> or --deployment must be given")
+		}
+		var previewURL string
+		if len(args) > 0 {
+			u, err := url.Parse(args[0])
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return errors.Errorf("invalid preview URL %q (must be an http or https URL)", args[0])
+			}
+			previewURL = args[0]
+		}
+		return setPreviewURL(previewURL, previewFlags.Deployment)
+	},
+}
+
+var previewClearCmd = &cobra.Command{
+	Use:          "clear",
+	Short:        "Remove the preview URL of a branch",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return setPreviewURL("", "")
+	},
+}
+
+// setPreviewURL sets the preview URL of the branch given with --branch (or the current branch)
+// and updates its pull request. If deployment is not empty, the URL of the latest deployment
+// to the environment is used.
+func setPreviewURL(previewURL string, deployment string) error {
+	repo, err := getRepo()
+	if err != nil {
+		return err
+	}
+	db, err := getDB(repo)
+	if err != nil {
+		return err
+	}
+	var branchArgs []string
+	if previewFlags.Branch != "" {
+		branchArgs = []string{previewFlags.Branch}
+	}
+	branchName, err := notesBranchName(repo, branchArgs)
+	if err != nil {
+		return err
+	}
+
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+	branch, ok := tx.Branch(branchName)
+	if !ok {
+		return actions.BranchNotAdoptedError{Branch: branchName}
+	}
+	if deployment != "" {
+		previewURL, err = deploymentURL(branch, deployment)
+		if err != nil {
+			return err
+		}
+	}
+	branch.PreviewURL = previewURL
+	tx.SetBranch(branch)
+	cu.Cancel()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if previewURL == "" {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Removed the preview URL of "), colors.UserInput(branchName), colors.Success(".\n"),
+		)
+	} else {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Set the preview URL of "), colors.UserInput(branchName),
+			colors.Success(" to "), colors.UserInput(previewURL), colors.Success(".\n"),
+		)
+	}
+
+	if branch.PullRequest == nil || branch.MergeCommit != "" {
+		return nil
+	}
+	if err := updatePreviewPullRequests(db, branchName); err != nil {
+		return errors.WrapIf(err, "failed to update the pull request")
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Success("Updated pull request "), colors.UserInput(branch.PullRequest.Permalink), "\n",
+	)
+	return nil
+}
+
+// deploymentURL returns the URL of the latest deployment of the pull request of the branch to
+// the given environment.
+func deploymentURL(branch meta.Branch, environment string) (string, error) {
+	if branch.PullRequest == nil || branch.PullRequest.ID == "" {
+		return "", errors.Errorf(
+			"branch %q has no associated pull request to find the deployment", branch.Name,
+		)
+	}
+	client, err := getGitHubClient()
+	if err != nil {
+		return "", err
+	}
+	deployments, err := client.PullRequestDeployments(context.Background(), branch.PullRequest.ID)
+	if err != nil {
+		return "", err
+	}
+	for _, deployment := range deployments {
+		if deployment.Environment != environment {
+			continue
+		}
+		if deployment.EnvironmentURL == "" {
+			return "", errors.Errorf(
+				"the latest deployment to %q has no URL (%s)",
+				environment, deploymentStateString(deployment),
+			)
+		}
+		return deployment.EnvironmentURL, nil
+	}
+	return "", errors.Errorf("pull request #%d has no deployment to %q", branch.PullRequest.Number, environment)
+}
+
+// updatePreviewPullRequests rewrites the pull request body of the branch. If the stack is
+// written to the pull requests, the pull requests of the whole stack are updated since they
+// link to the preview of the branch.
+func updatePreviewPullRequests(db meta.DB, branchName string) error {
+	tx := db.WriteTx()
+	defer tx.Abort()
+	f, err := getForge(tx.Repository())
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if !config.Av.PullRequest.WriteStack {
+		return actions.UpdatePullRequestBody(ctx, f, tx, branchName)
+	}
+	stackBranches, err := meta.StackBranches(tx, branchName)
+	if err != nil {
+		return err
+	}
+	var branches []string
+	for _, name := range stackBranches {
+		if br, _ := tx.Branch(name); br.PullRequest != nil && br.MergeCommit == "" {
+			branches = append(branches, name)
+		}
+	}
+	return actions.UpdatePullRequestsWithStack(ctx, f, tx, branches)
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{previewSetCmd, previewClearCmd} {
+		cmd.Flags().StringVar(
+			&previewFlags.Branch, "branch", "",
+			"the branch to set the preview URL of (defaults to the current branch)",
+		)
+		_ = cmd.RegisterFlagCompletionFunc("branch", branchNameArgs)
+	}
+	previewSetCmd.Flags().StringVar(
+		&previewFlags.Deployment, "deployment", "",
+		"use the URL of the latest deployment of the pull request to the given environment",
+	)
+
+	previewCmd.AddCommand(
+		previewClearCmd,
+		previewSetCmd,
+		previewShowCmd,
+	)
+}
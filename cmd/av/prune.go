@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var pruneFlags struct {
+	DryRun      bool
+	Yes         bool
+	Interactive bool
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune [--dry-run] [--yes | --interactive]",
+	Short: "Delete the branches whose pull requests were merged or closed",
+	Long: strings.TrimSpace(`
+Delete the branches whose pull requests were merged or closed without merging.
+
+The remote is fetched and the states of the pull requests are updated first.
+Then, each merged or closed branch is deleted locally and on the remote, and its
+children are moved onto its parent (as av abandon does), so the children of a
+closed branch no longer have its commits. The pull requests of the children are
+retargeted to the new parents.
+
+The branches to delete are listed and confirmed before anything is deleted.
+With --interactive, each branch is confirmed one by one. With --dry-run, the
+branches are only listed. Run av undo to restore the local branches.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		return runPrune(repo, db, pruneOptions{
+			DryRun:      pruneFlags.DryRun,
+			Yes:         pruneFlags.Yes,
+			Interactive: pruneFlags.Interactive,
+			Refresh:     true,
+		})
+	},
+}
+
+type pruneOptions struct {
+	DryRun bool
+	// Prune without the confirmation.
+	Yes bool
+	// Confirm each branch.
+	Interactive bool
+	// Fetch the remote and update the pull request states first (av sync has done it).
+	Refresh bool
+}
+
+// pruneCandidate is a branch to prune.
+type pruneCandidate struct {
+	name string
+	// "merged" or "closed".
+	state string
+	pr    int64
+}
+
+func (c pruneCandidate) describe() string {
+	if c.pr == 0 {
+		return c.state
+	}
+	return fmt.Sprintf("%s #%d", c.state, c.pr)
+}
+
+// runPrune deletes the branches whose pull requests were merged or closed, moving their children
+// onto their parents.
+func runPrune(repo *git.Repo, db meta.DB, opts pruneOptions) error {
+	if err := checkNoOtherOperationInProgress(repo, ""); err != nil {
+		return err
+	}
+	if opts.Refresh {
+		refreshPruneStates(repo, db)
+	}
+
+	tx := db.ReadTx()
+	candidates, skipped := findPruneCandidates(repo, tx)
+	for _, name := range slices.Sorted(maps.Keys(skipped)) {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("Keeping "), colors.UserInput(name), colors.Faint(": "+skipped[name]+"\n"),
+		)
+	}
+	if len(candidates) == 0 {
+		fmt.Fprint(os.Stderr, colors.Success("No branches to prune.\n"))
+		return nil
+	}
+	fmt.Fprint(os.Stderr, "Branches to prune:\n")
+	for _, c := range candidates {
+		fmt.Fprint(os.Stderr,
+			"  - ", colors.UserInput(c.name), colors.Faint(" ("+c.describe()+")"), "\n",
+		)
+	}
+	if opts.DryRun {
+		fmt.Fprint(os.Stderr, colors.Faint("Dry run: no branches were deleted.\n"))
+		return nil
+	}
+	candidates = confirmPrune(candidates, opts)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	if err := oplog.Record(repo, tx, "av prune"); err != nil {
+		return err
+	}
+	// The current branch is empty on a detached HEAD.
+	currentBranch, _ := repo.CurrentBranchName()
+	var moved []string
+	for _, c := range candidates {
+		// The parent of the branch changes when its parent is pruned before it.
+		tx := db.ReadTx()
+		branch, _ := tx.Branch(c.name)
+		children := meta.Children(tx, c.name)
+		if err := abandonBranch(repo, db, branch, children, currentBranch, ""); err != nil {
+			restoreAbandonedBranches(repo, db)
+			return errors.WrapIff(err, "failed to prune branch %q", c.name)
+		}
+		currentBranch, _ = repo.CurrentBranchName()
+		for _, child := range children {
+			if child.MergeCommit == "" {
+				moved = append(moved, child.Name)
+			}
+		}
+	}
+
+	fmt.Fprint(os.Stderr, colors.Success(fmt.Sprintf("Pruned %d branches.\n", len(candidates))))
+	tx = db.ReadTx()
+	for _, name := range moved {
+		if br, ok := tx.Branch(name); ok {
+			fmt.Fprint(os.Stderr,
+				"  - moved ", colors.UserInput(name), " onto ", colors.UserInput(br.Parent.Name), "\n",
+			)
+		}
+	}
+	if len(moved) > 0 {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("  - run "), colors.CliCmd("av sync"),
+			colors.Faint(" to push the moved branches\n"),
+		)
+	}
+	return nil
+}
+
+// refreshPruneStates fetches the remote and updates the states of the open pull requests. The
+// prune continues with the stored states if they can't be updated (e.g., offline).
+func refreshPruneStates(repo *git.Repo, db meta.DB) {
+	if _, err := repo.Git("fetch", "--prune", repo.GetRemoteName()); err != nil {
+		logrus.WithError(err).Warn("failed to fetch the remote")
+	}
+	tx := db.WriteTx()
+	defer tx.Abort()
+	f, err := getForge(tx.Repository())
+	if err != nil {
+		fmt.Fprint(os.Stderr, colors.Warning(
+			"Using the stored pull request states: "+err.Error()+"\n",
+		))
+		return
+	}
+	for name, branch := range tx.AllBranches() {
+		if branch.IsFollowed() || branch.MergeCommit != "" || branch.PullRequest == nil ||
+			branch.PullRequest.State != githubv4.PullRequestStateOpen {
+			continue
+		}
+		if _, err := actions.UpdatePullRequestState(context.Background(), f, tx, name); err != nil {
+			fmt.Fprint(os.Stderr, colors.Warning(
+				"Failed to update the pull request of "+name+": "+err.Error()+"\n",
+			))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		logrus.WithError(err).Warn("failed to save the pull request states")
+	}
+}
+
+// findPruneCandidates returns the merged and closed branches to prune (parents first), and the
+// merged and closed branches that are kept with the reasons.
+func findPruneCandidates(repo *git.Repo, tx meta.ReadTx) ([]pruneCandidate, map[string]string) {
+	var candidates []pruneCandidate
+	skipped := map[string]string{}
+	for name, branch := range tx.AllBranches() {
+		var state string
+		switch {
+		case branchMerged(branch):
+			state = "merged"
+		case branch.PullRequest != nil &&
+			branch.PullRequest.State == githubv4.PullRequestStateClosed:
+			state = "closed"
+		default:
+			continue
+		}
+		if branch.IsFollowed() {
+			skipped[name] = "followed branch (av sync moves its children)"
+			continue
+		}
+		if err := repo.CheckNotInOtherWorktree(name); err != nil {
+			skipped[name] = "checked out in another worktree"
+			continue
+		}
+		if exists, _ := repo.DoesBranchExist(name); !exists {
+			skipped[name] = "the local branch doesn't exist (run av tidy)"
+			continue
+		}
+		if slices.ContainsFunc(meta.Children(tx, name), func(child meta.Branch) bool {
+			return child.IsPinned()
+		}) {
+			skipped[name] = "has a pinned child branch"
+			continue
+		}
+		candidates = append(candidates, pruneCandidate{
+			name:  name,
+			state: state,
+			pr:    branch.PullRequest.GetNumber(),
+		})
+	}
+	slices.SortFunc(candidates, func(a, b pruneCandidate) int {
+		return cmp.Or(
+			cmp.Compare(actions.StackDepth(tx, a.name), actions.StackDepth(tx, b.name)),
+			strings.Compare(a.name, b.name),
+		)
+	})
+	return candidates, skipped
+}
+
+// confirmPrune returns the candidates confirmed to be pruned. Without a terminal, nothing is
+// pruned unless --yes is given.
+func confirmPrune(candidates []pruneCandidate, opts pruneOptions) []pruneCandidate {
+	if opts.Yes {
+		return candidates
+	}
+	if !uiutils.IsInteractive() {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("Run "), colors.CliCmd("av prune --yes"),
+			colors.Faint(" to prune them without the confirmation.\n"),
+		)
+		return nil
+	}
+	reader := bufio.NewReader(os.Stdin)
+	if !opts.Interactive {
+		fmt.Fprintf(os.Stderr, "Prune these %d branches? [y/N] ", len(candidates))
+		if answer, _ := reader.ReadString('\n'); isYes(answer) {
+			return candidates
+		}
+		return nil
+	}
+	var ret []pruneCandidate
+	for _, c := range candidates {
+		fmt.Fprint(os.Stderr, "Prune ", colors.UserInput(c.name), " ("+c.describe()+")? [y/N] ")
+		if answer, _ := reader.ReadString('\n'); isYes(answer) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+func isYes(answer string) bool {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+func init() {
+	pruneCmd.Flags().BoolVar(
+		&pruneFlags.DryRun, "dry-run", false,
+		"list the branches to prune without deleting them",
+	)
+	pruneCmd.Flags().BoolVarP(
+		&pruneFlags.Yes, "yes", "y", false,
+		"prune the branches without the confirmation",
+	)
+	pruneCmd.Flags().BoolVarP(
+		&pruneFlags.Interactive, "interactive", "i", false,
+		"confirm each branch to prune",
+	)
+	pruneCmd.MarkFlagsMutuallyExclusive("dry-run", "yes", "interactive")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/query"
+	"github.com/spf13/cobra"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query <query>",
+	Short: "List the branches that match a query",
+	Long: strings.TrimSpace(`
+List the branches that match a query, one per line in the stack order.
+
+A query combines predicates, field comparisons, and functions with &&, ||, !,
+and parentheses:
+
+  predicates:  has-pr, merged, pinned, root, leaf, needs-restack, needs-sync
+  fields:      name=<glob>, parent=<branch>, author=<me|name|email>,
+               pr=<number>, pr-state=<open|closed|merged|none>
+  functions:   ancestors(<branch>), children(<branch>), descendants(<branch>),
+               stack(<branch>)
+
+The output can be passed to the commands that accept --stdin. With --json, the
+branches are printed in JSON with the same fields as av tree --json.
+
+Examples:
+  $ av query 'needs-restack && author=me'
+  $ av query 'descendants(feature-x) && !has-pr'
+  $ av query 'merged' | av pin --stdin
+`),
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		expr, err := query.Parse(args[0])
+		if err != nil {
+			return err
+		}
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		me, _ := repo.Git("config", "user.email")
+		env := &query.Env{
+			Tx: tx,
+			Predicates: map[string]func(string) (bool, error){
+				"needs-restack": func(branch string) (bool, error) {
+					return branchNeedsRestack(repo, tx, branch), nil
+				},
+				"needs-sync": func(branch string) (bool, error) {
+					return getStackTreeBranchInfo(repo, tx, branch).NeedSync, nil
+				},
+			},
+			Author: func(branch string) (string, error) {
+				return repo.Git("log", "-1", "--format=%an <%ae>", "refs/heads/"+branch, "--")
+			},
+			Me: me,
+		}
+		branches, err := query.Select(expr, env)
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			currentBranch, _ := repo.CurrentBranchName()
+			return printJSON(newBranchOutputs(repo, tx, currentBranch, branches))
+		}
+		for _, branch := range branches {
+			fmt.Println(branch)
+		}
+		return nil
+	},
+}
+
+// branchNeedsRestack returns true if the branch is not on top of the current head of its
+// parent branch (the same check as av tree).
+func branchNeedsRestack(repo *git.Repo, tx meta.ReadTx, branchName string) bool {
+	bi, _ := tx.Branch(branchName)
+	parentHead, err := repo.RevParse(&git.RevParse{Rev: bi.Parent.Name})
+	if err != nil {
+		return true
+	}
+	mergeBase, err := repo.MergeBase(parentHead, branchName)
+	return err != nil || mergeBase != parentHead
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 
 	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/avgql"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/utils/errutils"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/glamour/styles"
 	"github.com/charmbracelet/lipgloss"
@@ -16,12 +22,13 @@ var (
 
 const noGitHubToken = `# ERROR: No GitHub Token
 
-` + "`av`" + ` needs a GitHub API token to interact with the repository. There are two ways to provide a token:
+` + "`av`" + ` needs a GitHub API token to interact with the repository. There are a few ways to provide a token:
 
 1. (Easy) Use [GitHub CLI](https://cli.github.com) to authenticate with GitHub. Run ` + "`gh auth login`" + ` to authenticate.
-2. Create a Personal Access Token on GitHub and set it in the config. See [av configuration doc](https://docs.aviator.co/aviator-cli/configuration#github-personal-access-token).
+2. Store a Personal Access Token in the OS keychain (the macOS Keychain, the Secret Service keyring, or the Windows Credential Manager) under the service ` + "`av`" + `. See ` + "`man av-auth`" + `.
+3. Set a Personal Access Token in the ` + "`AV_GITHUB_TOKEN`" + ` environment variable or in the config. See [av configuration doc](https://docs.aviator.co/aviator-cli/configuration#github-personal-access-token).
 
-We couldn't find the GitHub CLI setup nor a Personal Access Token in the config. Please set up the token and try again.
+We couldn't find a token in any of these places. Please set up the token and try again.
 `
 
 const parentNotAdopted = `# ERROR: Parent branch is not adopted to ` + "`av`" + `
@@ -57,3 +64,60 @@ func renderError(err error) string {
 	// For now, we just print the error message.
 	return fmt.Sprintf("error: %s\n", err)
 }
+
+// failureClassOf returns the failure class of the error returned by a command, which decides
+// the exit code of av.
+func failureClassOf(err error) errutils.FailureClass {
+	if class, ok := errutils.ClassOf(err); ok {
+		return class
+	}
+	// The errors that are not classified where they are made (e.g., the errors of the GitHub
+	// client, which only tells an HTTP 401 in the message).
+	var exitSilently actions.ErrExitSilently
+	if errors.As(err, &exitSilently) {
+		if exitSilently.Err == nil {
+			return errutils.FailureGeneric
+		}
+		err = exitSilently.Err
+	}
+	switch {
+	case errors.Is(err, errNoGitHubToken), gh.IsHTTPUnauthorized(err), avgql.IsHTTPUnauthorized(err):
+		return errutils.FailureAuth
+	case errors.Is(err, errParentNotAdopted):
+		return errutils.FailureMetadataMismatch
+	}
+	return errutils.FailureGeneric
+}
+
+// jsonError is the error printed to stderr in the JSON output (--json).
+type jsonError struct {
+	Error struct {
+		// The failure class (e.g., "conflict").
+		Class    string `json:"class"`
+		ExitCode int    `json:"exitCode"`
+		Message  string `json:"message"`
+	} `json:"error"`
+}
+
+// printJSONError prints the error as a JSON object in a single line to stderr, so that the
+// scripts can read it after the other messages.
+func printJSONError(err error, class errutils.FailureClass, exitCode int) {
+	var out jsonError
+	out.Error.Class = class.Name
+	out.Error.ExitCode = exitCode
+	out.Error.Message = err.Error()
+	var exitSilently actions.ErrExitSilently
+	if errors.As(err, &exitSilently) {
+		// The command has shown the error in its own way.
+		out.Error.Message = fmt.Sprintf("the command failed with exit code %d", exitCode)
+		if exitSilently.Err != nil {
+			out.Error.Message = exitSilently.Err.Error()
+		}
+	}
+	bs, merr := json.Marshal(out)
+	if merr != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", bs)
+}
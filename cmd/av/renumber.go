@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var renumberFlags struct {
+	Force bool
+}
+
+var stackRenameFlags struct {
+	Sequence bool
+}
+
+var renumberCmd = &cobra.Command{
+	Use:   "renumber [<prefix>]",
+	Short: "Rename the branches in the current stack to numbered names",
+	Long: strings.TrimSpace(`
+Rename all the branches in the current stack to numbered names in the stack order
+(e.g., feat-x/01-schema, feat-x/02-api, ...) for the tools that rely on the lexical
+order of the branch names.
+
+The prefix is remembered, and the stack is renumbered automatically after av
+reorder. If the prefix is omitted, the stack is renumbered with the remembered
+prefix (e.g., after adding a branch to the stack).
+
+Renaming a branch orphans its pull request, so the branches with a pull request
+are not renamed unless the --force flag is given.
+`),
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prefix := ""
+		if len(args) > 0 {
+			prefix = args[0]
+		}
+
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+
+		tx := db.WriteTx()
+		cu := cleanup.New(func() { tx.Abort() })
+		defer cu.Cleanup()
+		if _, ok := tx.Branch(currentBranch); !ok {
+			return actions.BranchNotAdoptedError{Branch: currentBranch}
+		}
+		renames, err := actions.RenameStackSequence(
+			repo, tx, currentBranch, prefix, renumberFlags.Force,
+		)
+		if err != nil {
+			return err
+		}
+		cu.Cancel()
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		printStackRenames(renames)
+		return nil
+	},
+}
+
+// stackRenameCmd is av renumber under the av stack command.
+var stackRenameCmd = &cobra.Command{
+	Use:   "rename --sequence [<prefix>]",
+	Short: "Rename the branches in the current stack to numbered names (same as av renumber)",
+	Long: strings.TrimSpace(`
+Rename all the branches in the current stack to numbered names in the stack order.
+This is the same command as av renumber.
+`),
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return renumberCmd.RunE(cmd, args)
+	},
+}
+
+func printStackRenames(renames map[string]string) {
+	if len(renames) == 0 {
+		fmt.Fprint(os.Stderr, colors.Success("The branches are already numbered.\n"))
+		return
+	}
+	var oldNames []string
+	for oldName := range renames {
+		oldNames = append(oldNames, oldName)
+	}
+	sort.Slice(oldNames, func(i, j int) bool { return renames[oldNames[i]] < renames[oldNames[j]] })
+	for _, oldName := range oldNames {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Renamed "), colors.UserInput(oldName),
+			colors.Success(" to "), colors.UserInput(renames[oldName]), "\n",
+		)
+	}
+}
+
+func init() {
+	renumberCmd.Flags().BoolVar(
+		&renumberFlags.Force, "force", false,
+		"rename the branches even if they have a pull request",
+	)
+
+	stackRenameCmd.Flags().BoolVar(
+		&stackRenameFlags.Sequence, "sequence", false,
+		"rename the branches to numbered names",
+	)
+	_ = stackRenameCmd.MarkFlagRequired("sequence")
+	stackRenameCmd.Flags().BoolVar(
+		&renumberFlags.Force, "force", false,
+		"rename the branches even if they have a pull request",
+	)
+}
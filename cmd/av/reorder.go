@@ -9,17 +9,22 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
 	"github.com/aviator-co/av/internal/reorder"
+	"github.com/aviator-co/av/internal/sequencer"
 	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var reorderFlags struct {
-	Continue bool
-	Abort    bool
+	Continue  bool
+	Abort     bool
+	Autostash bool
 }
 
 var reorderCmd = &cobra.Command{
@@ -44,108 +49,175 @@ squashed, dropped, or moved within the stack.
 		if err != nil {
 			return err
 		}
+		return runReorder(repo, db, reorderOptions{
+			Continue:  reorderFlags.Continue,
+			Abort:     reorderFlags.Abort,
+			Autostash: reorderFlags.Autostash,
+		})
+	},
+}
 
-		var continuation reorder.Continuation
-		if err := repo.ReadStateFile(git.StateFileKindReorder, &continuation); os.IsNotExist(err) {
-			if reorderFlags.Continue || reorderFlags.Abort {
-				fmt.Fprint(os.Stderr,
-					colors.Failure("ERROR: no reorder in progress\n"),
-				)
-				return actions.ErrExitSilently{ExitCode: 127}
-			}
-		} else if err != nil {
-			return err
+type reorderOptions struct {
+	Continue  bool
+	Abort     bool
+	Autostash bool
+}
+
+// runReorder starts a reorder, or continues or aborts the interrupted one.
+func runReorder(repo *git.Repo, db meta.DB, opts reorderOptions) error {
+	var continuation reorder.Continuation
+	if err := repo.ReadStateFile(git.StateFileKindReorder, &continuation); os.IsNotExist(err) {
+		if opts.Continue || opts.Abort {
+			fmt.Fprint(os.Stderr,
+				colors.Failure("ERROR: no reorder in progress\n"),
+			)
+			return actions.ErrExitSilently{ExitCode: 127}
 		}
+	} else if err != nil {
+		return err
+	}
 
-		var state *reorder.State
-		if reorderFlags.Abort {
-			if continuation.State == nil {
-				_ = repo.WriteStateFile(git.StateFileKindReorder, nil)
-				return errors.New("no reorder in progress")
-			}
+	var state *reorder.State
+	if opts.Abort {
+		if continuation.State == nil {
+			_ = repo.WriteStateFile(git.StateFileKindReorder, nil)
+			return errors.New("no reorder in progress")
+		}
 
-			if stat, _ := os.Stat(filepath.Join(repo.GitDir(), "CHERRY_PICK_HEAD")); stat != nil {
-				if err := repo.CherryPick(git.CherryPick{Resume: git.CherryPickAbort}); err != nil {
-					return errors.WrapIf(err, "failed to abort in-progress cherry-pick")
-				}
-			}
-			// TODO: --abort should probably reset the state of each branch
-			//   associated with the reorder to the original. It might be worth
-			//   storing some history and allow the user to do --undo to restore
-			//   their Git state to the state before the reorder.
-			return repo.WriteStateFile(git.StateFileKindReorder, nil)
-		} else if reorderFlags.Continue {
-			state = continuation.State
-		} else {
-			if continuation.State != nil {
-				fmt.Fprint(os.Stderr,
-					colors.Failure("ERROR: reorder already in progress\n"),
-					colors.Failure("	   use --continue or --abort to continue or abort the reorder\n"),
-				)
-				return actions.ErrExitSilently{ExitCode: 127}
-			}
-			tx := db.ReadTx()
-			currentBranch, err := repo.CurrentBranchName()
-			if err != nil {
-				return err
-			}
-			root, ok := meta.Root(tx, currentBranch)
-			if !ok {
-				fmt.Fprint(os.Stderr,
-					colors.Failure("ERROR: branch "), colors.UserInput(currentBranch),
-					colors.Failure(" is not part of a stack\n"),
-				)
-				return actions.ErrExitSilently{ExitCode: 127}
+		if stat, _ := os.Stat(filepath.Join(repo.WorktreeGitDir(), "CHERRY_PICK_HEAD")); stat != nil {
+			if err := repo.CherryPick(git.CherryPick{Resume: git.CherryPickAbort}); err != nil {
+				return errors.WrapIf(err, "failed to abort in-progress cherry-pick")
 			}
-			initialPlan, err := reorder.CreatePlan(repo, db.ReadTx(), root)
-			if err != nil {
+		}
+		// TODO: --abort should probably reset the state of each branch
+		//   associated with the reorder to the original. For now, av undo
+		//   can be used to restore the state before the reorder.
+		if err := repo.WriteStateFile(git.StateFileKindReorder, nil); err != nil {
+			return err
+		}
+		restoreAutostash(repo, continuation.State.Autostash)
+		return nil
+	} else if opts.Continue {
+		state = continuation.State
+	} else {
+		if err := checkNoOtherOperationInProgress(repo, git.StateFileKindReorder); err != nil {
+			return err
+		}
+		if continuation.State != nil {
+			fmt.Fprint(os.Stderr,
+				colors.Failure("ERROR: reorder already in progress\n"),
+				colors.Failure("	   use --continue or --abort to continue or abort the reorder\n"),
+			)
+			return actions.ErrExitSilently{ExitCode: 127}
+		}
+		tx := db.ReadTx()
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		root, ok := meta.Root(tx, currentBranch)
+		if !ok {
+			fmt.Fprint(os.Stderr,
+				colors.Failure("ERROR: branch "), colors.UserInput(currentBranch),
+				colors.Failure(" is not part of a stack\n"),
+			)
+			return actions.ErrExitSilently{ExitCode: 127}
+		}
+		autostash := opts.Autostash || config.Av.Restack.Autostash
+		if !autostash {
+			// Check before the plan is edited so that the edit isn't thrown away.
+			if err := sequencer.CheckCleanWorktree(repo, "av reorder"); err != nil {
 				return err
 			}
+		}
+		initialPlan, err := reorder.CreatePlan(repo, db.ReadTx(), root)
+		if err != nil {
+			return err
+		}
 
-			plan, err := reorderEditPlan(repo, initialPlan)
-			if err != nil {
+		plan, err := reorderEditPlan(repo, initialPlan)
+		if err != nil {
+			return err
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"plan":           plan,
+			"current_branch": currentBranch,
+			"root_branch":    root,
+		}).Debug("created reorder plan")
+		if config.Av.Restack.SignCommits {
+			if err := repo.CheckSigningKey(); err != nil {
 				return err
 			}
-
-			logrus.WithFields(logrus.Fields{
-				"plan":           plan,
-				"current_branch": currentBranch,
-				"root_branch":    root,
-			}).Debug("created reorder plan")
-			state = &reorder.State{Commands: plan}
 		}
-
-		state, err = reorder.Reorder(reorder.Context{
-			Repo:   repo,
-			DB:     db,
-			State:  state,
-			Output: os.Stderr,
-		})
-		if err != nil {
+		if err := oplog.Record(repo, db.ReadTx(), "av reorder"); err != nil {
 			return err
 		}
-		if state == nil {
-			if err := repo.WriteStateFile(git.StateFileKindReorder, nil); err != nil {
+		state = &reorder.State{
+			Commands: plan,
+			Signoff:  config.Av.Commit.Signoff,
+			Sign:     config.Av.Restack.SignCommits,
+		}
+		if autostash {
+			state.Autostash, err = repo.Autostash("av reorder")
+			if err != nil {
 				return err
 			}
-			fmt.Fprint(os.Stderr,
-				colors.Success("\nThe stack was reordered successfully.\n"),
-			)
-			return nil
 		}
+	}
+	autostash := state.Autostash
 
-		continuation = reorder.Continuation{State: state}
-		if err := repo.WriteStateFile(git.StateFileKindReorder, &continuation); err != nil {
+	state, err := reorder.Reorder(reorder.Context{
+		Repo:   repo,
+		DB:     db,
+		State:  state,
+		Output: os.Stderr,
+	})
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		if err := repo.WriteStateFile(git.StateFileKindReorder, nil); err != nil {
 			return err
 		}
 		fmt.Fprint(os.Stderr,
-			colors.Warning("\nThe reorder was interrupted by a conflict.\n"),
-			colors.Warning("Resolve the conflict and run "),
-			colors.CliCmd("av reorder --continue"),
-			colors.Warning(" to continue.\n"),
+			colors.Success("\nThe stack was reordered successfully.\n"),
 		)
-		return actions.ErrExitSilently{ExitCode: 1}
-	},
+		if err := sequencer.UpdateSubmodules(repo); err != nil {
+			return err
+		}
+		restoreAutostash(repo, autostash)
+		return renumberStack(repo, db)
+	}
+
+	continuation = reorder.Continuation{State: state}
+	if err := repo.WriteStateFile(git.StateFileKindReorder, &continuation); err != nil {
+		return err
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Warning("\nThe reorder was interrupted by a conflict.\n"),
+		colors.Warning("Resolve the conflict and run "),
+		colors.CliCmd("av reorder --continue"),
+		colors.Warning(" to continue.\n"),
+	)
+	return actions.ErrExitConflict
+}
+
+// restoreAutostash restores the uncommitted changes stashed before the reorder. A failure to
+// restore them doesn't fail the reorder since the changes are kept in the stash.
+func restoreAutostash(repo *git.Repo, hash string) {
+	if hash == "" {
+		return
+	}
+	if err := repo.AutostashPop(hash); err != nil {
+		fmt.Fprint(os.Stderr,
+			colors.Warning(err.Error()), "\n",
+			colors.Faint("  - run "), colors.CliCmd("git stash pop --index"),
+			colors.Faint(" to restore them after resolving the differences\n"),
+		)
+		return
+	}
+	fmt.Fprint(os.Stderr, colors.Success("Restored the stashed changes.\n"))
 }
 
 func init() {
@@ -153,6 +225,10 @@ func init() {
 		BoolVar(&reorderFlags.Continue, "continue", false, "continue an in-progress reorder")
 	reorderCmd.Flags().
 		BoolVar(&reorderFlags.Abort, "abort", false, "abort an in-progress reorder")
+	reorderCmd.Flags().BoolVar(
+		&reorderFlags.Autostash, "autostash", false,
+		"stash the uncommitted changes before reordering and restore them afterward",
+	)
 	reorderCmd.MarkFlagsMutuallyExclusive("continue", "abort")
 }
 
@@ -170,6 +246,10 @@ edit:
 		return nil, actions.ErrExitSilently{ExitCode: 127}
 	}
 
+	if err := reorder.CheckSharedBases(initialPlan, plan); err != nil {
+		return nil, err
+	}
+
 	diff := reorder.Diff(initialPlan, plan)
 	if len(diff.RemovedBranches) > 0 {
 		fmt.Fprint(
@@ -179,6 +259,9 @@ edit:
 		for _, branch := range diff.RemovedBranches {
 			fmt.Fprint(os.Stderr, "  - ", colors.UserInput(branch), "\n")
 		}
+		if err := uiutils.RequireInteractive("choosing what to do with the removed branches", ""); err != nil {
+			return nil, err
+		}
 
 	promptDeletionBehavior:
 		fmt.Fprint(os.Stderr, "\n",
@@ -219,3 +302,32 @@ edit:
 
 	return plan, nil
 }
+
+// renumberStack renumbers the branches of the current stack if the stack is numbered by
+// av renumber. The reorder itself is already done, so a failure to renumber (e.g., a branch
+// with a pull request would be renamed) is only a warning.
+func renumberStack(repo *git.Repo, db meta.DB) error {
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return err
+	}
+	tx := db.WriteTx()
+	defer tx.Abort()
+	if actions.StackSequencePrefix(tx, currentBranch) == "" {
+		return nil
+	}
+	renames, err := actions.RenameStackSequence(repo, tx, currentBranch, "", false)
+	if err != nil {
+		fmt.Fprint(os.Stderr,
+			colors.Warning("The stack was not renumbered: "), err.Error(), "\n",
+		)
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if len(renames) > 0 {
+		printStackRenames(renames)
+	}
+	return nil
+}
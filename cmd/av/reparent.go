@@ -1,29 +1,74 @@
 package main
 
 import (
+	"context"
+	"strings"
+
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh/ghui"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
 	"github.com/aviator-co/av/internal/sequencer"
 	"github.com/aviator-co/av/internal/sequencer/planner"
 	"github.com/aviator-co/av/internal/sequencer/sequencerui"
+	"github.com/aviator-co/av/internal/utils/sliceutils"
 	"github.com/aviator-co/av/internal/utils/uiutils"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/shurcooL/githubv4"
 	"github.com/spf13/cobra"
 )
 
 var reparentFlags struct {
-	Parent string
+	Parent    string
+	Stdin     bool
+	Force     bool
+	Only      bool
+	Push      string
+	Autostash bool
 }
 
 var reparentCmd = &cobra.Command{
 	Use:   "reparent",
 	Short: "Change the parent of the current branch",
+	Long: strings.TrimSpace(`
+Change the parent of the current branch and rebase the branch and its children
+onto the new parent. With --only, only the current branch is moved, and its
+children are moved onto its old parent.
+
+The new parent can be a branch of a different stack. If the moved branches have
+pull requests, the rebased branches are pushed and the pull requests are
+retargeted to the new parents (as av sync does; see --push). If
+pullRequest.writeStack is set, the stacks in the pull requests of the old and
+the new stacks are updated too.
+
+The new parent can also be a tag or a commit SHA. The branches are then rebased
+onto it and become stack roots built on it, which av sync doesn't move to the
+latest trunk.
+
+If the --stdin flag is given, all the branches read from the standard input
+(one per line, e.g., the output of av query) are moved onto the new parent
+instead of the current branch.
+
+If stack.maxDepth is set in the config, moving the branches in a way that makes
+the stack deeper than the limit is refused unless the --force flag is given.
+`),
+	ValidArgsFunction: branchNameArgAt(0),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if !sliceutils.Contains(
+			[]string{"ask", "yes", "no"},
+			strings.ToLower(reparentFlags.Push),
+		) {
+			return errors.New("invalid value for --push; must be one of ask, yes, no")
+		}
+		if reparentFlags.Only && reparentFlags.Stdin {
+			return errors.New("cannot use --only with --stdin")
+		}
 		repo, err := getRepo()
 		if err != nil {
 			return err
@@ -46,8 +91,36 @@ var reparentCmd = &cobra.Command{
 			return errors.New("missing parent branch name")
 		}
 		reparentFlags.Parent = stripRemoteRefPrefixes(repo, reparentFlags.Parent)
+		// A tag or a commit SHA makes the branches stack roots built on it (see av branch).
+		var baseRef, baseCommit string
+		if commit, ok := resolveImmutableParent(repo, reparentFlags.Parent); ok {
+			baseRef, baseCommit = reparentFlags.Parent, commit
+			reparentFlags.Parent, err = repo.DefaultBranch()
+			if err != nil {
+				return err
+			}
+		}
 
-		return uiutils.RunBubbleTea(&reparentViewModel{repo: repo, db: db})
+		var stdinBranches []string
+		if reparentFlags.Stdin {
+			stdinBranches, err = readStdinBranches()
+			if err != nil {
+				return err
+			}
+		}
+		if !reparentFlags.Force {
+			if err := checkReparentDepth(repo, db.ReadTx(), stdinBranches); err != nil {
+				return err
+			}
+		}
+		return uiutils.RunBubbleTea(&reparentViewModel{
+			repo:          repo,
+			db:            db,
+			stdinBranches: stdinBranches,
+			baseRef:       baseRef,
+			baseCommit:    baseCommit,
+			autostash:     reparentFlags.Autostash,
+		})
 	},
 }
 
@@ -55,7 +128,25 @@ type reparentViewModel struct {
 	repo *git.Repo
 	db   meta.DB
 
-	restackModel *sequencerui.RestackModel
+	// The branches given with --stdin.
+	stdinBranches []string
+	// The tag or the commit SHA given as the new parent, and its commit. The branches are
+	// moved onto the commit and become stack roots with it as the frozen base.
+	baseRef    string
+	baseCommit string
+	// The branches of the stacks that the branches are moved from and to. The stacks in their
+	// pull requests are updated after the push.
+	stackBranches []string
+	// Don't push the rebased branches (av restack --onto leaves it to av sync).
+	noPush bool
+	// The command recorded in the operation log. Defaults to "av reparent".
+	command string
+	// Stash the uncommitted changes before the rebase (--autostash).
+	autostash bool
+
+	restackModel    *sequencerui.RestackModel
+	githubPushModel *ghui.GitHubPushModel
+	pushingToGitHub bool
 
 	quitWithConflict bool
 	err              error
@@ -66,6 +157,14 @@ func (vm *reparentViewModel) Init() tea.Cmd {
 	if err != nil {
 		return func() tea.Msg { return err }
 	}
+	if vm.autostash || config.Av.Restack.Autostash {
+		state.Autostash, err = vm.repo.Autostash(vm.commandName())
+	} else {
+		err = sequencer.CheckCleanWorktree(vm.repo, vm.commandName())
+	}
+	if err != nil {
+		return func() tea.Msg { return err }
+	}
 	vm.restackModel = sequencerui.NewRestackModel(vm.repo, vm.db)
 	vm.restackModel.State = state
 	return vm.restackModel.Init()
@@ -73,7 +172,15 @@ func (vm *reparentViewModel) Init() tea.Cmd {
 
 func (vm *reparentViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
-	case *sequencerui.RestackProgress, spinner.TickMsg:
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		if vm.githubPushModel != nil {
+			vm.githubPushModel, cmd = vm.githubPushModel.Update(msg)
+		} else if vm.restackModel != nil {
+			vm.restackModel, cmd = vm.restackModel.Update(msg)
+		}
+		return vm, cmd
+	case *sequencerui.RestackProgress:
 		var cmd tea.Cmd
 		vm.restackModel, cmd = vm.restackModel.Update(msg)
 		return vm, cmd
@@ -83,16 +190,44 @@ func (vm *reparentViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		vm.quitWithConflict = true
 		return vm, tea.Quit
-	case *sequencerui.RestackAbort, *sequencerui.RestackDone:
+	case *sequencerui.RestackAbort:
 		if err := vm.writeState(nil); err != nil {
 			return vm, func() tea.Msg { return err }
 		}
 		return vm, tea.Quit
+	case *sequencerui.RestackDone:
+		if err := vm.writeState(nil); err != nil {
+			return vm, func() tea.Msg { return err }
+		}
+		if vm.noPush {
+			return vm, tea.Quit
+		}
+		return vm, vm.initPushBranches()
+	case *ghui.GitHubPushProgress:
+		var cmd tea.Cmd
+		vm.githubPushModel, cmd = vm.githubPushModel.Update(msg)
+		return vm, cmd
+	case *ghui.GitHubPushDone:
+		vm.pushingToGitHub = false
+		if vm.githubPushModel.ChoseNoPush() {
+			return vm, tea.Quit
+		}
+		return vm, vm.updateStacks
 	case tea.KeyMsg:
+		if vm.restackModel != nil && vm.restackModel.IsPrompting() {
+			var cmd tea.Cmd
+			vm.restackModel, cmd = vm.restackModel.Update(msg)
+			return vm, cmd
+		}
 		switch msg.String() {
 		case "ctrl+c":
 			return vm, tea.Quit
 		}
+		if vm.pushingToGitHub {
+			var cmd tea.Cmd
+			vm.githubPushModel, cmd = vm.githubPushModel.Update(msg)
+			return vm, cmd
+		}
 	case error:
 		vm.err = msg
 		return vm, tea.Quit
@@ -102,10 +237,17 @@ func (vm *reparentViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (vm *reparentViewModel) View() string {
 	var ss []string
-	ss = append(ss, "Reparenting onto "+reparentFlags.Parent+"...")
+	parent := reparentFlags.Parent
+	if vm.baseRef != "" {
+		parent = vm.baseRef
+	}
+	ss = append(ss, "Reparenting onto "+parent+"...")
 	if vm.restackModel != nil {
 		ss = append(ss, vm.restackModel.View())
 	}
+	if vm.githubPushModel != nil {
+		ss = append(ss, vm.githubPushModel.View())
+	}
 
 	var ret string
 	if len(ss) != 0 {
@@ -134,26 +276,29 @@ func (vm *reparentViewModel) createState() (*sequencerui.RestackState, error) {
 	if err != nil {
 		return nil, err
 	}
+	if vm.stdinBranches != nil {
+		return vm.createStateForBranches(currentBranch)
+	}
 	if isCurrentBranchTrunk, err := vm.repo.IsTrunkBranch(currentBranch); err != nil {
 		return nil, err
 	} else if isCurrentBranchTrunk {
 		return nil, errors.New("current branch is a trunk branch")
 	}
 	if _, exist := vm.db.ReadTx().Branch(currentBranch); !exist {
-		return nil, errors.New("current branch is not adopted to av")
+		return nil, actions.BranchNotAdoptedError{Branch: currentBranch}
 	}
 
-	if isParentBranchTrunk, err := vm.repo.IsTrunkBranch(reparentFlags.Parent); err != nil {
+	if err := vm.checkParentBranch(); err != nil {
 		return nil, err
-	} else if !isParentBranchTrunk {
-		if _, exist := vm.db.ReadTx().Branch(reparentFlags.Parent); !exist {
-			return nil, errors.New("parent branch is not adopted to av")
-		}
 	}
 	var state sequencerui.RestackState
 	state.InitialBranch = currentBranch
 	state.RelatedBranches = []string{currentBranch, reparentFlags.Parent}
-	ops, err := planner.PlanForReparent(
+	plan := planner.PlanForReparent
+	if reparentFlags.Only {
+		plan = planner.PlanForReparentOnly
+	}
+	ops, err := plan(
 		vm.db.ReadTx(),
 		vm.repo,
 		plumbing.NewBranchReferenceName(currentBranch),
@@ -162,22 +307,187 @@ func (vm *reparentViewModel) createState() (*sequencerui.RestackState, error) {
 	if err != nil {
 		return nil, err
 	}
+	vm.setBaseRef(ops, []string{currentBranch})
+	vm.stackBranches = reparentStackBranches(vm.db.ReadTx(), state.RelatedBranches)
+	if len(ops) == 0 {
+		return nil, nothingToRestackError
+	}
+	if err := oplog.Record(vm.repo, vm.db.ReadTx(), vm.commandName()); err != nil {
+		return nil, err
+	}
+	state.Seq = sequencer.NewSequencer(vm.repo.GetRemoteName(), vm.db, ops)
+	return &state, nil
+}
+
+func (vm *reparentViewModel) createStateForBranches(currentBranch string) (*sequencerui.RestackState, error) {
+	if err := vm.checkParentBranch(); err != nil {
+		return nil, err
+	}
+	tx := vm.db.ReadTx()
+	branches, err := planner.GetTargetBranchesFromList(tx, vm.stdinBranches)
+	if err != nil {
+		return nil, err
+	}
+	var state sequencerui.RestackState
+	state.InitialBranch = currentBranch
+	for _, br := range branches {
+		state.RelatedBranches = append(state.RelatedBranches, br.Short())
+	}
+	state.RelatedBranches = append(state.RelatedBranches, reparentFlags.Parent)
+	ops, err := planner.PlanForReparentBranches(
+		tx,
+		vm.repo,
+		branches,
+		plumbing.NewBranchReferenceName(reparentFlags.Parent),
+	)
+	if err != nil {
+		return nil, err
+	}
+	vm.setBaseRef(ops, state.RelatedBranches)
+	vm.stackBranches = reparentStackBranches(tx, state.RelatedBranches)
 	if len(ops) == 0 {
 		return nil, nothingToRestackError
 	}
+	if err := oplog.Record(vm.repo, vm.db.ReadTx(), vm.commandName()); err != nil {
+		return nil, err
+	}
 	state.Seq = sequencer.NewSequencer(vm.repo.GetRemoteName(), vm.db, ops)
 	return &state, nil
 }
 
+func (vm *reparentViewModel) commandName() string {
+	if vm.command != "" {
+		return vm.command
+	}
+	return "av reparent"
+}
+
+// initPushBranches pushes the rebased branches and retargets their pull requests if any branch
+// of the affected stacks has a pull request.
+func (vm *reparentViewModel) initPushBranches() tea.Cmd {
+	tx := vm.db.ReadTx()
+	hasPR := false
+	for _, name := range vm.stackBranches {
+		if br, _ := tx.Branch(name); br.PullRequest != nil {
+			hasPR = true
+			break
+		}
+	}
+	if !hasPR {
+		return tea.Quit
+	}
+	client, err := getForge(tx.Repository())
+	if err != nil {
+		return func() tea.Msg { return err }
+	}
+	var targets []plumbing.ReferenceName
+	for _, op := range vm.restackModel.State.Seq.Operations {
+		targets = append(targets, op.Name)
+	}
+	pushPolicy, _ := config.ParsePushPolicy(reparentFlags.Push)
+	vm.githubPushModel = ghui.NewGitHubPushModel(vm.repo, vm.db, client, pushPolicy, targets)
+	vm.pushingToGitHub = true
+	return vm.githubPushModel.Init()
+}
+
+// updateStacks updates the stacks written in the pull requests of the old and the new stacks.
+func (vm *reparentViewModel) updateStacks() tea.Msg {
+	if !config.Av.PullRequest.WriteStack {
+		return tea.Quit()
+	}
+	tx := vm.db.WriteTx()
+	defer tx.Abort()
+	client, err := getForge(tx.Repository())
+	if err != nil {
+		return err
+	}
+	var branches []string
+	for _, name := range vm.stackBranches {
+		br, _ := tx.Branch(name)
+		if br.PullRequest != nil && br.PullRequest.State == githubv4.PullRequestStateOpen {
+			branches = append(branches, name)
+		}
+	}
+	if err := actions.UpdatePullRequestsWithStack(context.Background(), client, tx, branches); err != nil {
+		return err
+	}
+	return tea.Quit()
+}
+
+// reparentStackBranches returns the branches of the stacks that the given branches (the moved
+// branches and the new parent) belong to.
+func reparentStackBranches(tx meta.ReadTx, branches []string) []string {
+	var ret []string
+	seen := map[string]bool{}
+	for _, branch := range branches {
+		stack, err := meta.StackBranches(tx, branch)
+		if err != nil {
+			// A trunk branch.
+			continue
+		}
+		for _, name := range stack {
+			if !seen[name] {
+				seen[name] = true
+				ret = append(ret, name)
+			}
+		}
+	}
+	return ret
+}
+
+// setBaseRef makes the re-parented branches stack roots built on the tag or the commit given as
+// the new parent.
+func (vm *reparentViewModel) setBaseRef(ops []sequencer.RestackOp, branches []string) {
+	if vm.baseRef == "" {
+		return
+	}
+	for i := range ops {
+		if sliceutils.Contains(branches, ops[i].Name.Short()) {
+			ops[i].NewParentHash = plumbing.NewHash(vm.baseCommit)
+			ops[i].ClearFrozenBase = false
+			ops[i].NewBaseRef = vm.baseRef
+		}
+	}
+}
+
+func (vm *reparentViewModel) checkParentBranch() error {
+	if isParentBranchTrunk, err := vm.repo.IsTrunkBranch(reparentFlags.Parent); err != nil {
+		return err
+	} else if !isParentBranchTrunk {
+		if _, exist := vm.db.ReadTx().Branch(reparentFlags.Parent); !exist {
+			return actions.BranchNotAdoptedError{Branch: reparentFlags.Parent}
+		}
+	}
+	return nil
+}
+
+// checkReparentDepth checks that moving the branches (or the current branch if none is given)
+// and their children onto the new parent doesn't exceed the stack depth limit.
+func checkReparentDepth(repo *git.Repo, tx meta.ReadTx, branches []string) error {
+	if branches == nil {
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			// Reported when the reparent is planned.
+			return nil
+		}
+		branches = []string{currentBranch}
+	}
+	height := 0
+	for _, branch := range branches {
+		height = max(height, actions.StackHeight(tx, branch))
+	}
+	return actions.CheckStackDepth(tx, reparentFlags.Parent, height)
+}
+
 func (vm *reparentViewModel) ExitError() error {
 	if errors.Is(vm.err, nothingToRestackError) {
 		return nil
 	}
 	if vm.err != nil {
-		return actions.ErrExitSilently{ExitCode: 1}
+		return actions.ErrExitSilently{ExitCode: 1, Err: vm.err}
 	}
 	if vm.quitWithConflict {
-		return actions.ErrExitSilently{ExitCode: 1}
+		return actions.ErrExitConflict
 	}
 	return nil
 }
@@ -187,12 +497,23 @@ func init() {
 		&reparentFlags.Parent, "parent", "",
 		"parent branch to rebase onto",
 	)
-
-	_ = reparentCmd.RegisterFlagCompletionFunc(
-		"parent",
-		func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-			branches, _ := allBranches()
-			return branches, cobra.ShellCompDirectiveNoFileComp
-		},
+	addStdinBranchesFlag(reparentCmd, &reparentFlags.Stdin)
+	reparentCmd.Flags().BoolVar(
+		&reparentFlags.Force, "force", false,
+		"reparent even if the stack gets deeper than the stack depth limit",
 	)
+	reparentCmd.Flags().BoolVar(
+		&reparentFlags.Only, "only", false,
+		"move only the current branch and move its children onto its old parent",
+	)
+	reparentCmd.Flags().StringVar(
+		&reparentFlags.Push, "push", "ask",
+		"push the rebased branches and retarget their pull requests\n(ask|yes|no)",
+	)
+	reparentCmd.Flags().BoolVar(
+		&reparentFlags.Autostash, "autostash", false,
+		"stash the uncommitted changes before rebasing and restore them afterward",
+	)
+
+	_ = reparentCmd.RegisterFlagCompletionFunc("parent", branchNameArgs)
 }
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/report"
+	"github.com/aviator-co/av/internal/utils/timeutils"
+	"github.com/spf13/cobra"
+)
+
+var reportFlags struct {
+	Since string
+	Until string
+}
+
+var reportCmd = &cobra.Command{
+	Use:   "report [--since <duration | date>] [--until <date>] [--json]",
+	Short: "Summarize the stacks and pull requests of a timeframe",
+	Long: strings.TrimSpace(`
+Summarize the stacks and the pull requests created, merged, and still open in a
+timeframe (the last 7 days by default), for standups or sprint reviews.
+
+The pull requests are the ones of the branches managed by av, including the
+branches recorded in the av operation log that were deleted after merged. Their
+states are queried from GitHub. The report is printed in Markdown, or in JSON
+with --json.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		now := time.Now()
+		until := now
+		if reportFlags.Until != "" {
+			t, err := parseReportDate(reportFlags.Until)
+			if err != nil {
+				return err
+			}
+			// The date is inclusive.
+			until = t.AddDate(0, 0, 1)
+		}
+		since, err := parseReportDate(reportFlags.Since)
+		if err != nil {
+			d, derr := timeutils.ParseDuration(reportFlags.Since)
+			if derr != nil {
+				return errors.Errorf(
+					"invalid --since %q (expected a duration like 7d or a date like 2006-01-02)",
+					reportFlags.Since,
+				)
+			}
+			since = until.Add(-d)
+		}
+		if !since.Before(until) {
+			return errors.New("--since must be before --until")
+		}
+
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		entries, err := oplog.Read(repo)
+		if err != nil {
+			return err
+		}
+		// The branches in the operation log are added first so that the current metadata
+		// takes precedence.
+		branches := map[string]meta.Branch{}
+		operations := map[string][]time.Time{}
+		for _, entry := range entries {
+			for name, br := range entry.Branches {
+				branches[name] = br
+			}
+			operations[entry.Command] = append(operations[entry.Command], entry.Time)
+		}
+		for name, br := range db.ReadTx().AllBranches() {
+			branches[name] = br
+		}
+
+		var ids []string
+		branchByID := map[string]string{}
+		for name, br := range branches {
+			if br.PullRequest != nil && br.PullRequest.ID != "" {
+				if _, ok := branchByID[br.PullRequest.ID]; !ok {
+					ids = append(ids, br.PullRequest.ID)
+				}
+				branchByID[br.PullRequest.ID] = name
+			}
+		}
+		pullRequests := map[string]report.PullRequest{}
+		if len(ids) > 0 {
+			client, err := getGitHubClient()
+			if err != nil {
+				return err
+			}
+			activities, err := client.PullRequestsActivity(context.Background(), ids)
+			if err != nil {
+				return err
+			}
+			for _, a := range activities {
+				pr := report.PullRequest{
+					Number:    a.Number,
+					Title:     a.Title,
+					URL:       a.Permalink,
+					Branch:    branchByID[a.ID],
+					State:     strings.ToLower(string(a.State)),
+					CreatedAt: a.CreatedAt.Time,
+				}
+				if a.MergedAt != nil {
+					pr.MergedAt = &a.MergedAt.Time
+				}
+				if a.ClosedAt != nil {
+					pr.ClosedAt = &a.ClosedAt.Time
+				}
+				pullRequests[pr.Branch] = pr
+			}
+		}
+
+		r := report.Build(since, until, branches, pullRequests, operations)
+		if jsonOutput() {
+			return printJSON(r)
+		}
+		return r.WriteMarkdown(os.Stdout)
+	},
+}
+
+// parseReportDate parses a date (2006-01-02) in the local time zone.
+func parseReportDate(s string) (time.Time, error) {
+	return time.ParseInLocation(time.DateOnly, s, time.Local)
+}
+
+func init() {
+	reportCmd.Flags().StringVar(
+		&reportFlags.Since, "since", "7d",
+		"the start of the timeframe as a duration before --until (e.g., 7d, 2w) or a date (2006-01-02)",
+	)
+	reportCmd.Flags().StringVar(
+		&reportFlags.Until, "until", "",
+		"the last date of the timeframe (2006-01-02, defaults to now)",
+	)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/sirupsen/logrus"
+)
+
+// migrateMovedRepository detects that the repository was transferred or renamed on GitHub and
+// updates the metadata (and the remote URL) so that av keeps working without av init. GitHub
+// redirects the old owner and name to the moved repository, and the repository ID doesn't
+// change. This is best effort: the errors are logged and ignored.
+func migrateMovedRepository(repo *git.Repo, db meta.DB) {
+	if config.Av.Forge != config.ForgeGitHub {
+		return
+	}
+	old := db.ReadTx().Repository()
+	if old.ID == "" {
+		return
+	}
+	client, err := getGitHubClient()
+	if err != nil {
+		return
+	}
+	ctx := context.Background()
+	ghRepo, err := client.GetRepositoryBySlug(ctx, old.Owner+"/"+old.Name)
+	if err != nil || ghRepo.ID != old.ID {
+		// The redirect is gone if another repository took the old name.
+		ghRepo, err = client.GetRepositoryByID(ctx, old.ID)
+		if err != nil {
+			logrus.WithError(err).Debug("failed to check if the repository was moved")
+			return
+		}
+	}
+	moveRepository(repo, db, ghRepo)
+}
+
+// moveRepository updates the metadata if the GitHub repository has a different owner or name.
+func moveRepository(repo *git.Repo, db meta.DB, ghRepo *gh.Repository) {
+	tx := db.WriteTx()
+	old := tx.Repository()
+	moved, err := actions.MoveRepository(repo, tx, repo.GetRemoteName(), meta.Repository{
+		ID:    ghRepo.ID,
+		Owner: ghRepo.Owner.Login,
+		Name:  ghRepo.Name,
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("failed to update the moved repository")
+	}
+	if !moved {
+		tx.Abort()
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		logrus.WithError(err).Warn("failed to update the moved repository")
+		return
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Warning("Repository "), colors.UserInput(old.Owner+"/"+old.Name),
+		colors.Warning(" was moved to "), colors.UserInput(ghRepo.Owner.Login+"/"+ghRepo.Name),
+		colors.Warning(" on GitHub. Updated the pull requests and the remote URL.\n"),
+	)
+}
@@ -5,6 +5,7 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/sequencer"
@@ -25,6 +26,14 @@ var restackFlags struct {
 	Continue bool
 	Skip     bool
 	DryRun   bool
+	// Re-create the current branch from its net diff instead of rebasing.
+	FromScratch bool
+	Signoff     bool
+	Autostash   bool
+	// Move the current branch and its descendants onto this branch.
+	Onto string
+	// Allow --onto to make the stack deeper than stack.maxDepth.
+	Force bool
 }
 
 var restackCmd = &cobra.Command{
@@ -40,6 +49,42 @@ var restackCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		if !restackFlags.Continue && !restackFlags.Abort && !restackFlags.Skip {
+			if err := checkNoOtherOperationInProgress(repo, git.StateFileKindRestack); err != nil {
+				return err
+			}
+		}
+		if !restackFlags.Continue && !restackFlags.Abort && !restackFlags.Skip &&
+			!restackFlags.DryRun {
+			tx := db.ReadTx()
+			if err := actions.ConfirmLockedBranches(
+				repo, tx, lockedBranchesToCheck(repo, tx, restackFlags.All), "restack",
+			); err != nil {
+				return err
+			}
+		}
+		if !restackFlags.Continue && !restackFlags.Abort && !restackFlags.Skip &&
+			!restackFlags.DryRun {
+			if err := runPreRestackHook(repo, db.ReadTx()); err != nil {
+				return err
+			}
+		}
+		if restackFlags.FromScratch {
+			return restackFromScratch(repo, db)
+		}
+		if restackFlags.Onto != "" {
+			return restackOnto(repo, db)
+		}
+		if restackFlags.DryRun {
+			if err := checkNoOtherOperationInProgress(repo, ""); err != nil {
+				return err
+			}
+			state, err := (&restackViewModel{repo: repo, db: db}).createState()
+			if err != nil {
+				return err
+			}
+			return runRestackDryRun(os.Stderr, repo, state.Seq, "av restack")
+		}
 		return uiutils.RunBubbleTea(&restackViewModel{repo: repo, db: db})
 	},
 }
@@ -67,6 +112,16 @@ func (vm *restackViewModel) Init() tea.Cmd {
 		if err != nil {
 			return func() tea.Msg { return err }
 		}
+		if len(state.Seq.Operations) > 0 {
+			if restackFlags.Autostash || config.Av.Restack.Autostash {
+				state.Autostash, err = vm.repo.Autostash("av restack")
+			} else {
+				err = sequencer.CheckCleanWorktree(vm.repo, "av restack")
+			}
+			if err != nil {
+				return func() tea.Msg { return err }
+			}
+		}
 	}
 	if state == nil {
 		return func() tea.Msg { return nothingToRestackError }
@@ -76,7 +131,6 @@ func (vm *restackViewModel) Init() tea.Cmd {
 	vm.restackModel.Abort = restackFlags.Abort
 	vm.restackModel.Continue = restackFlags.Continue
 	vm.restackModel.Skip = restackFlags.Skip
-	vm.restackModel.DryRun = restackFlags.DryRun
 	return vm.restackModel.Init()
 }
 
@@ -98,6 +152,11 @@ func (vm *restackViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return vm, tea.Quit
 	case tea.KeyMsg:
+		if vm.restackModel != nil && vm.restackModel.IsPrompting() {
+			var cmd tea.Cmd
+			vm.restackModel, cmd = vm.restackModel.Update(msg)
+			return vm, cmd
+		}
 		switch msg.String() {
 		case "ctrl+c":
 			return vm, tea.Quit
@@ -135,10 +194,10 @@ func (vm *restackViewModel) ExitError() error {
 		return nil
 	}
 	if vm.err != nil {
-		return actions.ErrExitSilently{ExitCode: 1}
+		return actions.ErrExitSilently{ExitCode: 1, Err: vm.err}
 	}
 	if vm.quitWithConflict {
-		return actions.ErrExitSilently{ExitCode: 1}
+		return actions.ErrExitConflict
 	}
 	return nil
 }
@@ -175,7 +234,7 @@ func (vm *restackViewModel) createState() (*sequencerui.RestackState, error) {
 		state.RestackingAll = true
 	} else {
 		if _, exist := vm.db.ReadTx().Branch(currentBranch); !exist {
-			return nil, errors.New("current branch is not adopted to av")
+			return nil, actions.BranchNotAdoptedError{Branch: currentBranch}
 		}
 		state.RelatedBranches = append(state.RelatedBranches, currentBranch)
 	}
@@ -196,9 +255,40 @@ func (vm *restackViewModel) createState() (*sequencerui.RestackState, error) {
 		return nil, err
 	}
 	state.Seq = sequencer.NewSequencer(vm.repo.GetRemoteName(), vm.db, ops)
+	state.Seq.Signoff = state.Seq.Signoff || restackFlags.Signoff
 	return &state, nil
 }
 
+// restackOnto moves the current branch and its descendants onto the --onto branch in one
+// restack. This is av reparent without pushing the branches; a conflict is continued with
+// av restack --continue like any other restack.
+func restackOnto(repo *git.Repo, db meta.DB) error {
+	parent := stripRemoteRefPrefixes(repo, restackFlags.Onto)
+	vm := &reparentViewModel{
+		repo:      repo,
+		db:        db,
+		noPush:    true,
+		command:   "av restack --onto",
+		autostash: restackFlags.Autostash,
+	}
+	if commit, ok := resolveImmutableParent(repo, parent); ok {
+		vm.baseRef, vm.baseCommit = parent, commit
+		var err error
+		parent, err = repo.DefaultBranch()
+		if err != nil {
+			return err
+		}
+	}
+	reparentFlags.Parent = parent
+	reparentFlags.Only = false
+	if !restackFlags.Force {
+		if err := checkReparentDepth(repo, db.ReadTx(), nil); err != nil {
+			return err
+		}
+	}
+	return uiutils.RunBubbleTea(vm)
+}
+
 func init() {
 	restackCmd.Flags().BoolVar(
 		&restackFlags.All, "all", false,
@@ -222,8 +312,41 @@ func init() {
 	)
 	restackCmd.Flags().BoolVar(
 		&restackFlags.DryRun, "dry-run", false,
-		"show the list of branches that will be rebased without actually rebasing them",
+		"show which branches would be rebased and which would conflict without rebasing them",
+	)
+	restackCmd.Flags().BoolVar(
+		&restackFlags.FromScratch, "from-scratch", false,
+		"re-create the current branch by applying its net diff onto the parent as a new commit",
+	)
+	restackCmd.Flags().BoolVar(
+		&restackFlags.Signoff, "signoff", false,
+		"add a Signed-off-by trailer to the rebased commits that are not signed off",
 	)
 
-	restackCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip")
+	restackCmd.Flags().BoolVar(
+		&restackFlags.Autostash, "autostash", false,
+		"stash the uncommitted changes before rebasing and restore them afterward",
+	)
+
+	restackCmd.Flags().StringVar(
+		&restackFlags.Onto, "onto", "",
+		"move the current branch and its descendants onto this branch, tag, or commit",
+	)
+	restackCmd.Flags().BoolVar(
+		&restackFlags.Force, "force", false,
+		"with --onto, move the branches even if the stack gets deeper than the stack depth limit",
+	)
+	_ = restackCmd.RegisterFlagCompletionFunc("onto", branchNameArgs)
+
+	restackCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip", "from-scratch")
+	// Pair --onto with each flag separately so that the other flags can still be combined with
+	// each other (e.g., --all --dry-run).
+	for _, flag := range []string{
+		"all", "current", "from-scratch", "dry-run", "continue", "abort", "skip",
+	} {
+		restackCmd.MarkFlagsMutuallyExclusive("onto", flag)
+	}
+	restackCmd.MarkFlagsMutuallyExclusive("all", "from-scratch")
+	restackCmd.MarkFlagsMutuallyExclusive("dry-run", "from-scratch")
+	restackCmd.MarkFlagsMutuallyExclusive("dry-run", "continue", "abort", "skip")
 }
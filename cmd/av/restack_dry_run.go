@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/sequencer"
+	"github.com/aviator-co/av/internal/utils/colors"
+)
+
+// runRestackDryRun prints the predicted outcome of the restack of the sequencer without
+// changing anything (see Sequencer.DryRun).
+func runRestackDryRun(w io.Writer, repo *git.Repo, seq *sequencer.Sequencer, command string) error {
+	if len(seq.Operations) == 0 {
+		fmt.Fprint(w, "Nothing to restack.\n")
+		return nil
+	}
+	results, err := seq.DryRun(repo)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(w, colors.Faint("Dry run of "+command+"; no branches are changed.\n"))
+	conflicts := 0
+	for _, result := range results {
+		branch := colors.UserInput(result.Op.Name.Short())
+		parent := colors.UserInput(result.Op.NewParent.Short())
+		switch result.Status {
+		case sequencer.DryRunUpToDate:
+			fmt.Fprint(w,
+				colors.Faint("  - "), branch, colors.Faint(" is up to date with "), parent, "\n",
+			)
+		case sequencer.DryRunRebase:
+			fmt.Fprint(w,
+				colors.Success("  - "), branch, colors.Success(" would be rebased onto "), parent,
+				fmt.Sprintf(" (%d commit(s))\n", result.Commits),
+			)
+		case sequencer.DryRunConflict:
+			conflicts++
+			fmt.Fprint(w,
+				colors.Failure("  - "), branch, colors.Failure(" would conflict with "), parent,
+				" at commit ", colors.UserInput(result.ConflictCommit[:7]), " in:\n",
+			)
+			for _, file := range result.ConflictFiles {
+				fmt.Fprint(w, "      ", file, "\n")
+			}
+		case sequencer.DryRunBlocked:
+			fmt.Fprint(w,
+				colors.Warning("  - "), branch, colors.Warning(" would be rebased onto "), parent,
+				colors.Warning(" after the conflict in "),
+				colors.UserInput(result.BlockedBy.Short()), colors.Warning(" is resolved\n"),
+			)
+		}
+	}
+	if conflicts > 0 {
+		fmt.Fprint(w,
+			"Run ", colors.CliCmd(command),
+			" and resolve the conflicts, or fix the conflicting branches first.\n",
+		)
+	}
+	return nil
+}
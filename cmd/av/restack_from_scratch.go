@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/errutils"
+)
+
+// restackFromScratch re-creates the current branch on top of its parent. The net diff of the
+// branch (from the merge base with the parent to the branch head) is applied onto the parent
+// as a single fresh commit. This discards the branch history, which is useful when the history
+// is too tangled to rebase (e.g., duplicated commits after a bad merge). The branch name (and
+// thus the pull request) is preserved.
+func restackFromScratch(repo *git.Repo, db meta.DB) error {
+	status, err := repo.Status()
+	if err != nil {
+		return err
+	}
+	if !status.IsCleanIgnoringUntracked() {
+		return errutils.WithFailureClass(errors.New(
+			"the working tree has uncommitted changes (commit or stash them before running this command)",
+		), errutils.FailureDirtyWorktree)
+	}
+	currentBranch := status.CurrentBranch
+
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+
+	branch, ok := tx.Branch(currentBranch)
+	if !ok {
+		return actions.BranchNotAdoptedError{Branch: currentBranch}
+	}
+	if branch.MergeCommit != "" {
+		return errors.Errorf("branch %q has already been merged", currentBranch)
+	}
+
+	parentRev := branch.Parent.Name
+	if branch.Parent.Trunk {
+		// Same as av sync, re-create the stack root on top of the remote trunk.
+		parentRev = repo.RemoteBranchRef(branch.Parent.Name)
+	}
+	parentHead, err := repo.RevParse(&git.RevParse{Rev: parentRev})
+	if err != nil {
+		return errors.WrapIff(err, "failed to resolve the parent branch %q", parentRev)
+	}
+	origHead, err := repo.RevParse(&git.RevParse{Rev: currentBranch})
+	if err != nil {
+		return err
+	}
+	mergeBase, err := repo.MergeBase(parentHead, origHead)
+	if err != nil {
+		return err
+	}
+
+	diff, err := repo.Run(&git.RunOpts{
+		Args:      []string{"diff", "--binary", mergeBase, origHead},
+		ExitError: true,
+	})
+	if err != nil {
+		return errors.WrapIf(err, "failed to compute the diff of the branch")
+	}
+	if len(diff.Stdout) == 0 {
+		return errors.Errorf("branch %q has no changes against its parent", currentBranch)
+	}
+
+	// Use the message of the oldest commit of the branch for the new commit. This is
+	// usually the commit that the pull request was created from.
+	commits, err := repo.Log(git.LogOpts{
+		RevisionRange: []string{"--no-merges", "--reverse", mergeBase + ".." + origHead},
+	})
+	if err != nil {
+		return err
+	}
+	message := "Re-create " + currentBranch
+	if len(commits) > 0 {
+		message = strings.TrimSpace(commits[0].Subject + "\n\n" + commits[0].Body)
+	}
+
+	commitArgs := commitSignoffArgs(restackFlags.Signoff)
+	if config.Av.Restack.SignCommits {
+		if err := repo.CheckSigningKey(); err != nil {
+			return err
+		}
+		commitArgs = append(commitArgs, "--gpg-sign")
+	}
+
+	if _, err := repo.Git("reset", "--hard", parentHead); err != nil {
+		return err
+	}
+	if _, err := repo.Run(&git.RunOpts{
+		Args:      []string{"apply", "--3way", "--index"},
+		Stdin:     bytes.NewReader(diff.Stdout),
+		ExitError: true,
+	}); err != nil {
+		if _, rerr := repo.Git("reset", "--hard", origHead); rerr != nil {
+			return errors.Combine(err, rerr)
+		}
+		return errors.WrapIff(
+			err,
+			"failed to apply the changes of %q onto %q (the branch has been left unchanged)",
+			currentBranch, branch.Parent.Name,
+		)
+	}
+	if _, err := repo.Run(&git.RunOpts{
+		Args: append(
+			[]string{"commit", "-m", message},
+			commitArgs...,
+		),
+		ExitError: true,
+	}); err != nil {
+		if _, rerr := repo.Git("reset", "--hard", origHead); rerr != nil {
+			return errors.Combine(err, rerr)
+		}
+		return errors.WrapIf(err, "failed to commit the changes (the branch has been left unchanged)")
+	}
+
+	if !branch.Parent.Trunk {
+		branch.Parent.Head = parentHead
+	}
+	tx.SetBranch(branch)
+	cu.Cancel()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stderr,
+		colors.Success("Re-created branch "), colors.UserInput(currentBranch),
+		colors.Success(" as a single commit on top of "), colors.UserInput(branch.Parent.Name),
+		colors.Success(".\n"),
+		colors.Faint("  - the previous branch head was "), colors.UserInput(origHead[:7]),
+		colors.Faint("\n"),
+	)
+	if len(meta.Children(tx, currentBranch)) > 0 {
+		fmt.Fprint(os.Stderr,
+			colors.Faint("  - run "), colors.CliCmd("av restack"),
+			colors.Faint(" to rebase the children branches\n"),
+		)
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Faint("  - run "), colors.CliCmd("av sync --push=yes"),
+		colors.Faint(" or "), colors.CliCmd("av pr"),
+		colors.Faint(" to update the pull request\n"),
+	)
+	return nil
+}
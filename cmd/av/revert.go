@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var revertFlags struct {
+	Parent string
+	Name   string
+	Force  bool
+}
+
+var revertCmd = &cobra.Command{
+	Use:   "revert <branch> | <pr-number> | <pr-url>",
+	Short: "Create a new branch that reverts a merged branch",
+	Long: strings.TrimSpace(`
+Create a new branch that reverts a merged branch or pull request.
+
+The branch is created on the trunk (or on --parent) and has a commit that reverts
+the merge commit of the branch, or all the commits of the branch if it was
+rebase-merged into several commits. The commit message refers to the reverted
+pull request, so the pull request created by av pr for the new branch is linked
+to it.
+
+The merged branch is looked up in the av metadata (av sync records the merge
+commits of the merged branches). For a pull request number or URL, the merge
+commit is queried from GitHub or GitLab.
+
+The new branch is named revert-<branch> unless --name is given. If the revert
+conflicts, resolve the conflicts and run git commit.
+`),
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: branchNameArgAt(0),
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		target, title, commits, err := resolveRevertTarget(repo, tx, args[0])
+		if err != nil {
+			return err
+		}
+		for _, branch := range tx.AllBranches() {
+			if branch.Reverts != nil && branch.Reverts.Commit == target.Commit &&
+				branch.MergeCommit == "" {
+				return errors.Errorf(
+					"branch %q already reverts %s", branch.Name, revertTargetName(*branch.Reverts),
+				)
+			}
+		}
+		if err := ensureCommitFetched(repo, target.Commit); err != nil {
+			return err
+		}
+		target.Base, err = revertBase(repo, target.Commit, commits)
+		if err != nil {
+			return err
+		}
+		if title == "" {
+			title, err = repo.Git("log", "-1", "--format=%s", target.Commit)
+			if err != nil {
+				return err
+			}
+		}
+
+		name := revertFlags.Name
+		if name == "" {
+			name = "revert-" + target.Branch
+		}
+		name = applyBranchNamespace(name)
+		if err := checkRemoteBranchCollision(repo, name); err != nil {
+			return err
+		}
+		parent := revertFlags.Parent
+		if parent == "" {
+			parent, err = repo.DefaultBranch()
+			if err != nil {
+				return err
+			}
+		}
+		if err := createBranch(repo, db, name, parent, revertFlags.Force); err != nil {
+			return err
+		}
+
+		wtx := db.WriteTx()
+		branch, _ := wtx.Branch(name)
+		branch.Reverts = &target
+		wtx.SetBranch(branch)
+		if err := wtx.Commit(); err != nil {
+			return err
+		}
+
+		message := revertCommitMessage(target, title)
+		revertArgs := []string{"revert", "--no-commit"}
+		isMerge, err := isMergeCommit(repo, target.Commit)
+		if err != nil {
+			return err
+		}
+		switch {
+		case isMerge:
+			// A merge commit is reverted to its first parent (the trunk).
+			revertArgs = append(revertArgs, "-m", "1", target.Commit)
+		case target.Base != "":
+			// The commits are reverted from the newest.
+			revertArgs = append(revertArgs, target.Base+".."+target.Commit)
+		default:
+			revertArgs = append(revertArgs, target.Commit)
+		}
+		if _, err := repo.Git(revertArgs...); err != nil {
+			// git commit uses MERGE_MSG as the default message after the conflicts are
+			// resolved.
+			_ = os.WriteFile(filepath.Join(repo.GitDir(), "MERGE_MSG"), []byte(message+"\n"), 0o644)
+			return errors.WrapIff(
+				err,
+				"failed to revert %s on branch %q (resolve the conflicts and run git commit)",
+				git.ShortSha(target.Commit), name,
+			)
+		}
+		if _, err := repo.Git("commit", "--message", message); err != nil {
+			return errors.WrapIf(err, "failed to commit the revert")
+		}
+
+		fmt.Fprint(os.Stderr,
+			"Created branch ", colors.UserInput(name), " reverting ",
+			colors.UserInput(revertTargetName(target)), ".\n",
+			"Run ", colors.CliCmd("av pr"), " to create the revert pull request.\n",
+		)
+		return nil
+	},
+}
+
+// revertedCommits are the commits of the reverted branch before it was merged.
+type revertedCommits struct {
+	// The commit that the branch was based on. If empty, the merge base of Head and the merge
+	// commit is used.
+	From string
+	// The head commit of the branch. Empty if unknown.
+	Head string
+}
+
+// resolveRevertTarget returns the merged branch to revert, the title of its pull request (empty
+// if unknown), and the commits of the branch before it was merged from a branch name, a pull
+// request number, or a pull request URL.
+func resolveRevertTarget(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	ref string,
+) (meta.BranchRevert, string, revertedCommits, error) {
+	if branch, ok := tx.Branch(ref); ok {
+		if branch.MergeCommit == "" {
+			return meta.BranchRevert{}, "", revertedCommits{}, errors.Errorf(
+				"branch %q is not merged (av sync records the merged branches)", ref,
+			)
+		}
+		var title string
+		if branch.PullRequest != nil {
+			title = branch.PullRequest.Title
+		}
+		commits := revertedCommits{From: branch.Parent.Head}
+		commits.Head, _ = repo.RevParse(&git.RevParse{Rev: "refs/heads/" + branch.Name})
+		if commits.Head == "" && branch.PullRequest != nil {
+			commits.Head = fetchPullRequestHead(repo, branch.PullRequest.Number)
+		}
+		return meta.BranchRevert{
+			Branch:            branch.Name,
+			PullRequestNumber: branch.PullRequest.GetNumber(),
+			Commit:            branch.MergeCommit,
+		}, title, commits, nil
+	}
+
+	number, err := parsePullRequestRef(ref, tx.Repository())
+	if err != nil {
+		return meta.BranchRevert{}, "", revertedCommits{}, errors.Errorf(
+			"%q is not a branch, a pull request number, or a pull request URL", ref,
+		)
+	}
+	// The merged branch may still be in the metadata.
+	for _, branch := range tx.AllBranches() {
+		if branch.PullRequest.GetNumber() == number && branch.MergeCommit != "" {
+			return resolveRevertTarget(repo, tx, branch.Name)
+		}
+	}
+	f, err := getForge(tx.Repository())
+	if err != nil {
+		return meta.BranchRevert{}, "", revertedCommits{}, err
+	}
+	pr, err := f.PullRequestByNumber(context.Background(), number)
+	if err != nil {
+		return meta.BranchRevert{}, "", revertedCommits{}, err
+	}
+	commit := pr.GetMergeCommit()
+	if commit == "" {
+		return meta.BranchRevert{}, "", revertedCommits{}, errors.Errorf("pull request #%d is not merged", number)
+	}
+	return meta.BranchRevert{
+		Branch:            pr.HeadBranchName(),
+		PullRequestNumber: pr.Number,
+		Commit:            commit,
+	}, pr.Title, revertedCommits{Head: fetchPullRequestHead(repo, pr.Number)}, nil
+}
+
+// fetchPullRequestHead fetches the head commit of the pull request from the remote. It returns
+// an empty string if the commit can't be fetched.
+func fetchPullRequestHead(repo *git.Repo, number int64) string {
+	ref := fmt.Sprintf("refs/pull/%d/head", number)
+	if config.Av.Forge == config.ForgeGitLab {
+		ref = fmt.Sprintf("refs/merge-requests/%d/head", number)
+	}
+	if _, err := repo.Git("fetch", repo.GetRemoteName(), ref); err != nil {
+		logrus.WithError(err).Debug("failed to fetch the head of the pull request")
+		return ""
+	}
+	head, _ := repo.RevParse(&git.RevParse{Rev: "FETCH_HEAD"})
+	return head
+}
+
+func isMergeCommit(repo *git.Repo, commit string) (bool, error) {
+	parents, err := repo.Git("rev-list", "--parents", "-n", "1", commit)
+	if err != nil {
+		return false, err
+	}
+	return len(strings.Fields(parents)) > 2, nil
+}
+
+// revertBase returns the trunk commit before the commits of the branch if the branch was
+// rebase-merged (or fast-forwarded) into more than one commit, so that all of them are
+// reverted. The commits on the trunk are matched with the commits of the branch by their patch
+// IDs, going back from the merge commit. An empty string is returned for a merge commit, a
+// squash commit, or a single commit.
+func revertBase(repo *git.Repo, commit string, commits revertedCommits) (string, error) {
+	if commits.Head == "" {
+		return "", nil
+	}
+	if isMerge, err := isMergeCommit(repo, commit); err != nil || isMerge {
+		return "", err
+	}
+	from := commits.From
+	if from != "" {
+		if ok, _ := repo.IsAncestor(from, commits.Head); !ok {
+			from = ""
+		}
+	}
+	if from == "" {
+		var err error
+		if from, err = repo.MergeBase(commits.Head, commit); err != nil {
+			return "", nil
+		}
+	}
+	branchIDs, err := repo.PatchIDs(from + ".." + commits.Head)
+	if err != nil {
+		return "", err
+	}
+	patches := map[string]bool{}
+	for _, id := range branchIDs {
+		patches[id] = true
+	}
+	if len(patches) < 2 {
+		return "", nil
+	}
+	limit := "--max-count=" + strconv.Itoa(len(patches))
+	trunk, err := repo.RevList(git.RevListOpts{Specifiers: []string{"--first-parent", limit, commit}})
+	if err != nil {
+		return "", err
+	}
+	trunkIDs, err := repo.PatchIDs("--first-parent", limit, commit)
+	if err != nil {
+		return "", err
+	}
+	n := 0
+	for _, c := range trunk {
+		if !patches[trunkIDs[c]] {
+			break
+		}
+		n++
+	}
+	if n < 2 {
+		return "", nil
+	}
+	return repo.RevParse(&git.RevParse{Rev: fmt.Sprintf("%s~%d", commit, n)})
+}
+
+// ensureCommitFetched fetches the commit from the remote if it's not in the local repository.
+func ensureCommitFetched(repo *git.Repo, commit string) error {
+	if _, err := repo.RevParse(&git.RevParse{Rev: commit + "^{commit}"}); err == nil {
+		return nil
+	}
+	if _, err := repo.Git("fetch", repo.GetRemoteName(), commit); err != nil {
+		return errors.WrapIff(err, "failed to fetch the merge commit %s", git.ShortSha(commit))
+	}
+	return nil
+}
+
+// revertCommitMessage returns the message of the revert commit, which refers to the reverted
+// pull request so that the revert pull request is linked to it.
+func revertCommitMessage(target meta.BranchRevert, title string) string {
+	message := fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", title, target.Commit)
+	if target.Base != "" {
+		message = fmt.Sprintf(
+			"Revert \"%s\"\n\nThis reverts commits %s..%s.", title, target.Base, target.Commit,
+		)
+	}
+	if target.PullRequestNumber != 0 {
+		prefix := "#"
+		if config.Av.Forge == config.ForgeGitLab {
+			prefix = "!"
+		}
+		message += fmt.Sprintf("\n\nReverts %s%d", prefix, target.PullRequestNumber)
+	}
+	return message
+}
+
+func revertTargetName(target meta.BranchRevert) string {
+	if target.PullRequestNumber != 0 {
+		return fmt.Sprintf("#%d (%s)", target.PullRequestNumber, target.Branch)
+	}
+	return target.Branch
+}
+
+func init() {
+	revertCmd.Flags().StringVar(
+		&revertFlags.Parent, "parent", "",
+		"the parent branch of the new branch (default: the trunk)",
+	)
+	revertCmd.Flags().StringVar(
+		&revertFlags.Name, "name", "",
+		"the name of the new branch (default: revert-<branch>)",
+	)
+	revertCmd.Flags().BoolVar(
+		&revertFlags.Force, "force", false,
+		"create the branch even if the stack gets deeper than the stack depth limit",
+	)
+	_ = revertCmd.RegisterFlagCompletionFunc("parent", branchNameArgs)
+}
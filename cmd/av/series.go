@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/series"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var seriesFlags struct {
+	Dir    string
+	Branch string
+}
+
+var (
+	seriesPatchNumberPattern    = regexp.MustCompile(`^[0-9]+-`)
+	seriesPatchSeparatorPattern = regexp.MustCompile(`[-_]+`)
+)
+
+var seriesCmd = &cobra.Command{
+	Use:   "series",
+	Short: "Exchange the stack with a Quilt/StGit style patch series",
+	Long: strings.TrimSpace(`
+Exchange the stack with a Quilt/StGit style patch series.
+
+A patch series is a directory of patches and a series file that lists them in
+the order they are applied. The branches of the stack are recorded as comments
+in the series file, so the series can be used by patch-queue tools (e.g., for
+downstream packaging) and imported back as a stack.
+`),
+}
+
+var seriesExportCmd = &cobra.Command{
+	Use:   "export [--dir <dir>]",
+	Short: "Export the stack as a patch series",
+	Long: strings.TrimSpace(`
+Export the current stack as a patch series.
+
+The current branch, its ancestors, and its descendants are exported. Each
+commit is exported as a patch (as created by git format-patch), and the series
+file lists the patches grouped by branch. Run av series refresh to update the
+series after the stack is modified.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		seriesFile := filepath.Join(seriesDir(), series.FileName)
+		if _, err := os.Stat(seriesFile); err == nil {
+			return errors.Errorf(
+				"%s already exists (run av series refresh to update it)", seriesFile,
+			)
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		if err := writeSeries(repo, db.ReadTx(), currentBranch, nil); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Exported the stack to "), colors.UserInput(seriesFile), "\n",
+		)
+		return nil
+	},
+}
+
+var seriesRefreshCmd = &cobra.Command{
+	Use:   "refresh [--dir <dir>]",
+	Short: "Update the patch series from the stack",
+	Long: strings.TrimSpace(`
+Update an exported patch series from the current state of the stack.
+
+The patches listed in the series file are removed, and the branches in the
+series are exported again together with the branches stacked on top of them
+since the last export. Other files in the directory are kept.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		s, err := readSeries()
+		if err != nil {
+			return err
+		}
+		// The last branch is used so that the branches stacked on top of the series since the
+		// export are included.
+		var stackBranch string
+		for _, b := range s.Branches {
+			if b.Name != "" {
+				stackBranch = b.Name
+			}
+		}
+		if stackBranch == "" {
+			return errors.New("the series file doesn't record the branches of a stack")
+		}
+		if err := writeSeries(repo, db.ReadTx(), stackBranch, s.Patches()); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Refreshed "),
+			colors.UserInput(filepath.Join(seriesDir(), series.FileName)), "\n",
+		)
+		return nil
+	},
+}
+
+var seriesImportCmd = &cobra.Command{
+	Use:   "import [--dir <dir>] [--branch <name>]",
+	Short: "Import a patch series as a stack",
+	Long: strings.TrimSpace(`
+Import a patch series as a stack.
+
+A branch is created for each branch recorded in the series file, and its
+patches are applied on top of its parent. Patches in the mail format (as
+created by git format-patch) are applied with git am. Other patches are applied
+with git apply and committed with a message derived from the patch file name.
+
+The patches of a series file that doesn't record branches are imported to the
+branch given with --branch.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) (reterr error) {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		s, err := readSeries()
+		if err != nil {
+			return err
+		}
+		if len(s.Branches) == 0 {
+			return errors.New("the series file has no patches")
+		}
+		if s.Branches[0].Name == "" {
+			if seriesFlags.Branch == "" {
+				return errors.New(
+					"the series file doesn't record branches; specify the branch to import to with --branch",
+				)
+			}
+			s.Branches[0].Name = applyBranchNamespace(seriesFlags.Branch)
+		}
+
+		status, err := repo.Status()
+		if err != nil {
+			return err
+		}
+		if !status.IsCleanIgnoringUntracked() {
+			return errutils.WithFailureClass(
+				errors.New("the working tree has uncommitted changes"),
+				errutils.FailureDirtyWorktree,
+			)
+		}
+		for _, b := range s.Branches {
+			if exists, err := repo.DoesBranchExist(b.Name); err != nil {
+				return err
+			} else if exists {
+				return errors.Errorf("branch %q already exists", b.Name)
+			}
+		}
+		trunk := s.Trunk
+		if trunk == "" {
+			trunk, err = repo.DefaultBranch()
+			if err != nil {
+				return err
+			}
+		}
+		base := trunk
+		if s.Base != "" {
+			if _, err := repo.Git("cat-file", "-e", s.Base+"^{commit}"); err == nil {
+				base = s.Base
+			} else {
+				fmt.Fprint(os.Stderr,
+					colors.Warning("The base commit of the series doesn't exist in this repository. "),
+					colors.Warning("Importing onto "), colors.UserInput(trunk),
+					colors.Warning(" instead.\n"),
+				)
+			}
+		}
+
+		rb, err := newBranchRollback(repo)
+		if err != nil {
+			return err
+		}
+		tx := db.WriteTx()
+		cu := cleanup.New(func() {
+			logrus.WithError(reterr).Debug("aborting db transaction")
+			tx.Abort()
+			rb.rollback()
+		})
+		defer cu.Cleanup()
+
+		parentState := meta.BranchState{Name: trunk, Trunk: true}
+		startPoint := base
+		for _, b := range s.Branches {
+			if _, err := repo.CheckoutBranch(&git.CheckoutBranch{
+				Name:       b.Name,
+				NewBranch:  true,
+				NewHeadRef: startPoint,
+			}); err != nil {
+				return err
+			}
+			rb.add(b.Name)
+			if err := actions.ConfigureNewBranch(repo, b.Name); err != nil {
+				return err
+			}
+			for _, patch := range b.Patches {
+				if err := applySeriesPatch(repo, patch); err != nil {
+					return errors.WrapIff(err, "failed to apply %s to branch %q", patch, b.Name)
+				}
+			}
+			tx.SetBranch(meta.Branch{Name: b.Name, Parent: parentState})
+			fmt.Fprint(os.Stderr,
+				"  - imported ", colors.UserInput(len(b.Patches)), " patch(es) to branch ",
+				colors.UserInput(b.Name), "\n",
+			)
+
+			head, err := repo.RevParse(&git.RevParse{Rev: b.Name})
+			if err != nil {
+				return err
+			}
+			parentState = meta.BranchState{Name: b.Name, Head: head}
+			startPoint = b.Name
+		}
+
+		cu.Cancel()
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Imported the series. Run "), colors.CliCmd("av tree"),
+			colors.Success(" to see it.\n"),
+		)
+		return nil
+	},
+}
+
+// seriesDir returns the absolute path of the patch directory since git runs at the
+// repository root.
+func seriesDir() string {
+	dir, err := filepath.Abs(seriesFlags.Dir)
+	if err != nil {
+		return seriesFlags.Dir
+	}
+	return dir
+}
+
+func readSeries() (*series.Series, error) {
+	f, err := os.Open(filepath.Join(seriesDir(), series.FileName))
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to read the series file")
+	}
+	defer f.Close()
+	return series.Parse(f)
+}
+
+// seriesBranches returns the line of branches to export for the given branch: its ancestors,
+// the branch, and its descendants up to the first branch that has more than one child since a
+// series can't represent a forked stack.
+func seriesBranches(tx meta.ReadTx, branchName string) ([]string, error) {
+	if _, ok := tx.Branch(branchName); !ok {
+		return nil, actions.BranchNotAdoptedError{Branch: branchName}
+	}
+	branches, err := meta.PreviousBranches(tx, branchName)
+	if err != nil {
+		return nil, err
+	}
+	branches = append(branches, branchName)
+	for {
+		children := meta.ChildrenNames(tx, branches[len(branches)-1])
+		if len(children) != 1 {
+			if len(children) > 1 {
+				logrus.WithField("branch", branches[len(branches)-1]).
+					Warn("not exporting the child branches of a forked branch")
+			}
+			return branches, nil
+		}
+		branches = append(branches, children[0])
+	}
+}
+
+// writeSeries exports the stack of the given branch to the patch directory (see
+// seriesBranches). The old patches are removed before the export.
+func writeSeries(repo *git.Repo, tx meta.ReadTx, branchName string, oldPatches []string) error {
+	branches, err := seriesBranches(tx, branchName)
+	if err != nil {
+		return err
+	}
+	root, _ := tx.Branch(branches[0])
+	base, err := repo.MergeBase(root.Parent.Name, root.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(seriesDir(), 0755); err != nil {
+		return errors.WrapIf(err, "failed to create the patch directory")
+	}
+	for _, patch := range oldPatches {
+		if err := os.Remove(filepath.Join(seriesDir(), patch)); err != nil && !os.IsNotExist(err) {
+			return errors.WrapIff(err, "failed to remove the old patch %s", patch)
+		}
+	}
+
+	s := &series.Series{Base: base, Trunk: root.Parent.Name}
+	for _, name := range branches {
+		from, err := branchDiffBase(repo, tx, name)
+		if err != nil {
+			return err
+		}
+		out, err := repo.Git(
+			"format-patch", "--binary", "--no-signature",
+			"--start-number", fmt.Sprint(len(s.Patches())+1),
+			"--output-directory", seriesDir(),
+			from+".."+name,
+		)
+		if err != nil {
+			return errors.WrapIff(err, "failed to export branch %q", name)
+		}
+		b := series.Branch{Name: name}
+		if out != "" {
+			for _, fp := range strings.Split(out, "\n") {
+				b.Patches = append(b.Patches, filepath.Base(fp))
+			}
+		}
+		s.Branches = append(s.Branches, b)
+		fmt.Fprint(os.Stderr,
+			"  - exported ", colors.UserInput(len(b.Patches)), " commit(s) of branch ",
+			colors.UserInput(name), "\n",
+		)
+	}
+
+	f, err := os.Create(filepath.Join(seriesDir(), series.FileName))
+	if err != nil {
+		return errors.WrapIf(err, "failed to write the series file")
+	}
+	if err := s.Write(f); err != nil {
+		_ = f.Close()
+		return errors.WrapIf(err, "failed to write the series file")
+	}
+	return f.Close()
+}
+
+// applySeriesPatch applies a patch of the series and commits it. A patch in the mail format is
+// applied with git am. Other patches (e.g., plain Quilt patches) are committed with a message
+// derived from the file name.
+func applySeriesPatch(repo *git.Repo, patch string) error {
+	fp := filepath.Join(seriesDir(), patch)
+	isMail, err := isMailPatch(fp)
+	if err != nil {
+		return err
+	}
+	if isMail {
+		if _, err := repo.Run(&git.RunOpts{
+			Args:      []string{"am", "--3way", "--quiet", fp},
+			ExitError: true,
+		}); err != nil {
+			_, _ = repo.Git("am", "--abort")
+			return err
+		}
+		return nil
+	}
+	if _, err := repo.Run(&git.RunOpts{
+		Args:      []string{"apply", "--index", fp},
+		ExitError: true,
+	}); err != nil {
+		return err
+	}
+	_, err = repo.Run(&git.RunOpts{
+		Args: append(
+			[]string{"commit", "--quiet", "--message", seriesPatchSubject(patch)},
+			commitSignoffArgs(false)...,
+		),
+		ExitError: true,
+	})
+	return err
+}
+
+func isMailPatch(fp string) (bool, error) {
+	f, err := os.Open(fp)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return false, nil
+	}
+	return strings.HasPrefix(line, "From "), nil
+}
+
+// seriesPatchSubject returns the commit subject for a patch without a mail header (e.g.,
+// "0002-fix_build.patch" to "fix build").
+func seriesPatchSubject(patch string) string {
+	name := filepath.Base(patch)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	name = seriesPatchNumberPattern.ReplaceAllString(name, "")
+	name = strings.TrimSpace(seriesPatchSeparatorPattern.ReplaceAllString(name, " "))
+	if name == "" {
+		return patch
+	}
+	return name
+}
+
+func init() {
+	seriesCmd.PersistentFlags().StringVar(
+		&seriesFlags.Dir, "dir", "patches",
+		"the directory of the patches and the series file",
+	)
+	_ = seriesCmd.MarkPersistentFlagDirname("dir")
+	seriesImportCmd.Flags().StringVar(
+		&seriesFlags.Branch, "branch", "",
+		"the branch to import the patches of a series file without branches to",
+	)
+
+	seriesCmd.AddCommand(
+		seriesExportCmd,
+		seriesImportCmd,
+		seriesRefreshCmd,
+	)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/editor"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/splitbranch"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var splitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Split the current branch into multiple stacked branches by commit",
+	Long: strings.TrimSpace(`
+Split the current branch into multiple stacked branches by commit.
+
+The commits of the current branch are listed in an editor. Insert "branch <name>"
+lines to assign contiguous groups of the commits to new branches. The branches
+are created at the last commit of their groups and stacked in order. The current
+branch keeps its group (and its pull request), and its children are moved onto
+the last branch. The commits are not rewritten. If the branch namespace is
+enforced, it is prepended to the names of the new branches.
+
+If a branch can't be created, the branches created so far are deleted and the
+current branch is left as it was.
+
+Run av undo to restore the branch as it was before the split.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		if _, exists := tx.Branch(currentBranch); !exists {
+			return actions.BranchNotAdoptedError{Branch: currentBranch}
+		}
+
+		base, err := branchDiffBase(repo, tx, currentBranch)
+		if err != nil {
+			return err
+		}
+		commits, err := repo.Log(git.LogOpts{
+			RevisionRange: []string{"--reverse", base + ".." + currentBranch},
+		})
+		if err != nil {
+			return err
+		}
+		if len(commits) < 2 {
+			return errors.Errorf("branch %q has fewer than two commits to split", currentBranch)
+		}
+		var hashes []string
+		for _, commit := range commits {
+			hashes = append(hashes, commit.Hash)
+		}
+
+		text, err := editor.Launch(repo, editor.Config{
+			Text:           splitbranch.FormatPlan(currentBranch, commits),
+			TmpFilePattern: "av-split-*",
+			CommentPrefix:  "#",
+		})
+		if err != nil {
+			return err
+		}
+		groups, err := splitbranch.ParsePlan(text)
+		if err != nil {
+			return err
+		}
+		groups, err = splitbranch.ResolveGroups(currentBranch, hashes, groups)
+		if err != nil {
+			return err
+		}
+		if len(groups) == 1 {
+			fmt.Fprint(os.Stderr, "The plan is unchanged, nothing to split.\n")
+			return nil
+		}
+		names := map[string]bool{currentBranch: true}
+		for i, group := range groups {
+			if group.Name == currentBranch {
+				continue
+			}
+			group.Name = applyBranchNamespace(group.Name)
+			groups[i].Name = group.Name
+			if names[group.Name] {
+				return errors.Errorf("branch %q appears more than once in the plan", group.Name)
+			}
+			names[group.Name] = true
+			if _, err := repo.Git("check-ref-format", "--branch", group.Name); err != nil {
+				return errors.Errorf("%q is not a valid branch name", group.Name)
+			}
+			if exists, err := repo.DoesBranchExist(group.Name); err != nil {
+				return err
+			} else if exists {
+				return errors.Errorf("branch %q already exists", group.Name)
+			}
+		}
+
+		if err := oplog.Record(repo, tx, "av split"); err != nil {
+			return err
+		}
+		return splitBranch(repo, db, currentBranch, groups)
+	},
+}
+
+// splitBranch creates the branches of the groups and stacks them in order. The children of the
+// split branch are moved onto the last branch.
+func splitBranch(
+	repo *git.Repo,
+	db meta.DB,
+	currentBranch string,
+	groups []splitbranch.Group,
+) (reterr error) {
+	rb, err := newBranchRollback(repo)
+	if err != nil {
+		return err
+	}
+	currentHead, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + currentBranch})
+	if err != nil {
+		return err
+	}
+	tx := db.WriteTx()
+	movedCurrentBranch := false
+	cu := cleanup.New(func() {
+		logrus.WithError(reterr).Debug("aborting db transaction")
+		tx.Abort()
+		if movedCurrentBranch {
+			// The last branch is checked out, so the split branch can be moved back.
+			if _, err := repo.Git("branch", "--force", currentBranch, currentHead); err != nil {
+				logrus.WithError(err).Error("failed to restore the split branch during cleanup")
+			}
+		}
+		rb.rollback()
+	})
+	defer cu.Cleanup()
+
+	original, _ := tx.Branch(currentBranch)
+	children := meta.ChildrenNames(tx, currentBranch)
+	last := groups[len(groups)-1]
+
+	for _, group := range groups {
+		if group.Name == currentBranch {
+			continue
+		}
+		if _, err := repo.Git("branch", "--no-track", group.Name, group.Head()); err != nil {
+			return errors.WrapIff(err, "failed to create branch %q", group.Name)
+		}
+		rb.add(group.Name)
+		if err := actions.ConfigureNewBranch(repo, group.Name); err != nil {
+			return err
+		}
+	}
+	if last.Name != currentBranch {
+		// The last branch points to the same commit as the current branch, so checking it out
+		// doesn't touch the working tree. Then the current branch can be moved.
+		if _, err := repo.CheckoutBranch(&git.CheckoutBranch{Name: last.Name}); err != nil {
+			return err
+		}
+		for _, group := range groups {
+			if group.Name == currentBranch {
+				if _, err := repo.Git("branch", "--force", currentBranch, group.Head()); err != nil {
+					return errors.WrapIff(err, "failed to move branch %q", currentBranch)
+				}
+				movedCurrentBranch = true
+			}
+		}
+	}
+
+	for i, group := range groups {
+		branch, _ := tx.Branch(group.Name)
+		if i == 0 {
+			// The first branch takes over the base of the split branch.
+			branch.Parent = original.Parent
+			branch.FrozenBase = original.FrozenBase
+			branch.BaseRef = original.BaseRef
+		} else {
+			branch.Parent = meta.BranchState{Name: groups[i-1].Name, Head: groups[i-1].Head()}
+			branch.FrozenBase = ""
+			branch.BaseRef = ""
+		}
+		tx.SetBranch(branch)
+	}
+	for _, name := range children {
+		child, _ := tx.Branch(name)
+		child.Parent.Name = last.Name
+		tx.SetBranch(child)
+	}
+	cu.Cancel()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprint(os.Stderr, colors.Success("Split branch "), colors.UserInput(currentBranch),
+		colors.Success(" into:\n"))
+	for _, group := range groups {
+		fmt.Fprint(os.Stderr,
+			"  - ", colors.UserInput(group.Name), " ",
+			colors.Faint(fmt.Sprintf("(%d commit(s))", len(group.Commits))), "\n",
+		)
+	}
+	fmt.Fprint(os.Stderr,
+		colors.Faint("  - run "), colors.CliCmd("av pr --all"),
+		colors.Faint(" to create the pull requests of the new branches\n"),
+	)
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +17,9 @@ var splitCommitCmd = &cobra.Command{
 	SilenceUsage: true,
 	Args:         cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) error {
+		if err := uiutils.RequireInteractive("choosing the changes to split", ""); err != nil {
+			return err
+		}
 		repo, err := getRepo()
 		if err != nil {
 			return err
@@ -94,7 +98,10 @@ func splitCommit(repo *git.Repo, currentBranchName, currentCommitOID string) err
 
 		if _, err := repo.Run(&git.RunOpts{
 			// Add --verbose to show the diffs to be committed.
-			Args:        []string{"commit", "--verbose", "--reedit-message", currentCommitOID},
+			Args: append(
+				[]string{"commit", "--verbose", "--reedit-message", currentCommitOID},
+				commitSignoffArgs(false)...,
+			),
 			ExitError:   true,
 			Interactive: true,
 		}); err != nil {
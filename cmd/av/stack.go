@@ -1,14 +1,21 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/spf13/cobra"
 )
 
 var stackCmd = &cobra.Command{
 	Use:     "stack",
 	Aliases: []string{"st"},
-	Hidden:  true,
-	Short:   "Deprecated command to manage stacked pull requests",
+	Short:   "Manage the current stack as a whole",
+	Long: strings.TrimSpace(`
+Manage the current stack as a whole (e.g., check out, merge, or abandon a stack).
+
+The other subcommands of av stack (e.g., av stack sync) are deprecated and hidden;
+use the top-level commands (e.g., av sync) instead.
+`),
 }
 
 func init() {
@@ -61,8 +68,8 @@ func init() {
 		"only sync changes to the current branch\n(don't recurse into descendant branches)",
 	)
 	deprecatedSyncCmd.Flags().StringVar(
-		&syncFlags.Push, "push", "ask",
-		"push the rebased branches to the remote repository\n(ask|yes|no)",
+		&syncFlags.Push, "push", "",
+		"push the rebased branches to the remote repository\n(ask|always|never|only-if-restacked; default: the sync.push config)",
 	)
 	deprecatedSyncCmd.Flags().StringVar(
 		&syncFlags.Prune, "prune", "ask",
@@ -86,30 +93,106 @@ func init() {
 		&syncFlags.Skip, "skip", false,
 		"skip the current commit and continue an in-progress sync",
 	)
+	addStdinBranchesFlag(deprecatedSyncCmd, &syncFlags.Stdin)
 	deprecatedSyncCmd.MarkFlagsMutuallyExclusive("current", "all")
 	deprecatedSyncCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip")
 
 	deprecatedSubmitCmd := deprecateCommand(*stackSubmitCmd, "av pr --all", "submit")
 	deprecatedSubmitCmd.Flags().BoolVar(
-		&stackSubmitFlags.Current, "current", false,
+		&stackSubmitFlags.Range.Downstack, "current", false,
 		"only create pull requests up to the current branch",
 	)
+	addSubmitRangeFlags(deprecatedSubmitCmd, &stackSubmitFlags.Range)
 	deprecatedSubmitCmd.Flags().BoolVar(
 		&stackSubmitFlags.Draft, "draft", false,
 		"create pull requests in draft mode",
 	)
+	deprecatedSubmitCmd.Flags().BoolVar(
+		&stackSubmitFlags.Ready, "ready", false,
+		"create pull requests ready for review",
+	)
+	deprecatedSubmitCmd.Flags().StringVar(
+		&stackSubmitFlags.ReadyBelow, "ready-below", "",
+		"make the given branch and the branches below it ready for review and the others drafts",
+	)
+	deprecatedSubmitCmd.MarkFlagsMutuallyExclusive("draft", "ready")
+	deprecatedSubmitCmd.Flags().BoolVar(
+		&stackSubmitFlags.DryRun, "dry-run", false,
+		"show the titles of the pull requests to be created without creating them",
+	)
+	deprecatedSubmitCmd.Flags().BoolVar(
+		&stackSubmitFlags.Open, "open", false,
+		"open the submitted pull requests in the browser",
+	)
+	deprecatedSubmitCmd.Flags().BoolVar(
+		&stackSubmitFlags.Open, "web", false,
+		"alias of --open",
+	)
+	_ = deprecatedSubmitCmd.Flags().MarkHidden("web")
+	deprecatedSubmitCmd.Flags().StringVar(
+		&stackSubmitFlags.Since, "since", "",
+		"only submit the branches changed since the point (last-submit, an operation number, a\nduration, a date, or a commit)",
+	)
+	deprecatedSubmitCmd.Flags().BoolVar(
+		&stackSubmitFlags.AutoMerge, "auto-merge", false,
+		"enable auto-merge on the pull requests so that they are merged once their checks pass",
+	)
+	deprecatedSubmitCmd.Flags().StringVar(
+		&stackSubmitFlags.MergeMethod, "merge-method", "",
+		"the merge method of --auto-merge: merge, squash, or rebase",
+	)
+	deprecatedSubmitCmd.Flags().BoolVar(
+		&stackSubmitFlags.Codeowners, "codeowners", false,
+		"request the code owners of the changed files as reviewers",
+	)
+	addStdinBranchesFlag(deprecatedSubmitCmd, &stackSubmitFlags.Stdin)
 
 	deprecatedSwitchCmd := deprecateCommand(*switchCmd, "av switch", "switch")
 
 	deprecatedTidyCmd := deprecateCommand(*tidyCmd, "av tidy", "tidy")
 
 	deprecatedTreeCmd := deprecateCommand(*treeCmd, "av tree", "tree")
+	deprecatedTreeCmd.Flags().BoolVarP(
+		&treeFlags.Interactive, "interactive", "i", false,
+		"browse the tree interactively to check out, restack, or submit the branches",
+	)
+	addTreeFormatFlags(deprecatedTreeCmd)
 	deprecatedTreeCmd.Aliases = []string{"t"}
 
+	for _, cmd := range []*cobra.Command{
+		deprecatedAdoptCmd,
+		deprecatedBranchCmd,
+		deprecatedDiffCmd,
+		deprecatedNextCmd,
+		deprecatedOrphanCmd,
+		deprecatedPrevCmd,
+		deprecatedReorderCmd,
+		deprecatedReparentCmd,
+		deprecatedRestackCmd,
+		deprecatedStackBranchCommitCmd,
+		deprecatedSubmitCmd,
+		deprecatedSwitchCmd,
+		deprecatedSyncCmd,
+		deprecatedTidyCmd,
+		deprecatedTreeCmd,
+	} {
+		cmd.Hidden = true
+	}
+
 	stackCmd.AddCommand(
+		stackAbandonCmd,
 		deprecatedAdoptCmd,
 		deprecatedBranchCmd,
+		stackBaseBumpCmd,
+		stackCheckoutCmd,
+		stackExportCmd,
+		stackFreezeBaseCmd,
 		deprecatedDiffCmd,
+		stackGraphCmd,
+		stackImportCmd,
+		stackMergeCmd,
+		stackPublishNotesCmd,
+		stackRenameCmd,
 		deprecatedNextCmd,
 		deprecatedOrphanCmd,
 		deprecatedPrevCmd,
@@ -122,6 +205,8 @@ func init() {
 		deprecatedTidyCmd,
 		deprecatedTreeCmd,
 		stackForEachCmd,
+		stackSnapshotCmd,
+		stackStatsCmd,
 		deprecatedRestackCmd,
 	)
 }
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// The ref namespace that av stack abandon archives the branches to.
+const abandonArchiveRefPrefix = "refs/av/archive/"
+
+var stackAbandonFlags struct {
+	Reason   string
+	Branches string
+}
+
+var stackAbandonCmd = &cobra.Command{
+	Use:   "abandon [--reason <reason>] [--branches archive|delete]",
+	Short: "Abandon the current stack, closing its pull requests",
+	Long: strings.TrimSpace(`
+Abandon the current stack: close the open pull requests of all the branches in
+the stack with a comment, and archive or delete the branches.
+
+The comment is the stack.abandonComment config followed by the reason given
+with --reason. The branches are archived to refs/av/archive/<branch> by default
+(see the stack.abandonBranches config), and they are no longer tracked by av.
+If the current branch is in the stack, the trunk branch is checked out.
+
+Run av undo to restore the branches as they were before the abandonment. The
+closed pull requests are not reopened.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		policy := config.Av.Stack.AbandonBranches
+		if cmd.Flags().Changed("branches") {
+			policy = stackAbandonFlags.Branches
+		}
+		if policy != config.AbandonBranchesArchive && policy != config.AbandonBranchesDelete {
+			return errors.Errorf(
+				"invalid --branches %q (expected %q or %q)",
+				policy, config.AbandonBranchesArchive, config.AbandonBranchesDelete,
+			)
+		}
+
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		if _, exists := tx.Branch(currentBranch); !exists {
+			return actions.BranchNotAdoptedError{Branch: currentBranch}
+		}
+		stack, err := meta.StackBranches(tx, currentBranch)
+		if err != nil {
+			return err
+		}
+		trunk, _ := meta.Trunk(tx, currentBranch)
+
+		if err := oplog.Record(repo, tx, "av stack abandon"); err != nil {
+			return err
+		}
+		if err := closeAbandonedPullRequests(db, stack, stackAbandonFlags.Reason); err != nil {
+			return err
+		}
+		if err := abandonBranches(repo, db, stack, trunk, policy); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Faint("  - run "), colors.CliCmd("av undo"),
+			colors.Faint(" to restore the branches\n"),
+		)
+		return nil
+	},
+}
+
+// closeAbandonedPullRequests closes the open pull requests of the branches with the abandon
+// comment.
+func closeAbandonedPullRequests(db meta.DB, branches []string, reason string) error {
+	tx := db.ReadTx()
+	var open []meta.Branch
+	for _, name := range branches {
+		br, _ := tx.Branch(name)
+		if br.PullRequest != nil && br.PullRequest.State == githubv4.PullRequestStateOpen {
+			open = append(open, br)
+		}
+	}
+	if len(open) == 0 {
+		return nil
+	}
+
+	comment := abandonComment(reason)
+	f, err := getForge(tx.Repository())
+	if err != nil {
+		return err
+	}
+	for _, br := range open {
+		if _, err := f.ClosePullRequest(
+			context.Background(), br.PullRequest.ID, comment,
+		); err != nil {
+			return errors.WrapIff(err, "failed to close pull request #%d", br.PullRequest.Number)
+		}
+		fmt.Fprint(os.Stderr,
+			"Closed pull request ", colors.UserInput(br.PullRequest.Permalink), ".\n",
+		)
+	}
+	return nil
+}
+
+// abandonComment returns the comment posted on the abandoned pull requests.
+func abandonComment(reason string) string {
+	if reason == "" {
+		return config.Av.Stack.AbandonComment
+	}
+	return strings.TrimSpace(config.Av.Stack.AbandonComment + "\n\nReason: " + reason)
+}
+
+// abandonBranches archives or deletes the branches and stops tracking them. The trunk branch
+// is checked out if the current branch is one of them.
+func abandonBranches(
+	repo *git.Repo,
+	db meta.DB,
+	branches []string,
+	trunk string,
+	policy string,
+) (reterr error) {
+	tx := db.WriteTx()
+	cu := cleanup.New(func() {
+		logrus.WithError(reterr).Debug("aborting db transaction")
+		tx.Abort()
+	})
+	defer cu.Cleanup()
+
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil {
+		return err
+	}
+	for _, name := range branches {
+		if name == currentBranch {
+			if _, err := repo.CheckoutBranch(&git.CheckoutBranch{Name: trunk}); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	for _, name := range branches {
+		if policy == config.AbandonBranchesArchive {
+			oid, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + name})
+			if err != nil {
+				return err
+			}
+			if err := repo.UpdateRef(&git.UpdateRef{
+				Ref: abandonArchiveRefPrefix + name,
+				New: oid,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := repo.BranchDelete(name); err != nil {
+			return errors.WrapIff(err, "failed to delete branch %q", name)
+		}
+		tx.DeleteBranch(name)
+		if policy == config.AbandonBranchesArchive {
+			fmt.Fprint(os.Stderr,
+				"Archived branch ", colors.UserInput(name),
+				" to ", colors.UserInput(abandonArchiveRefPrefix+name), ".\n",
+			)
+		} else {
+			fmt.Fprint(os.Stderr, "Deleted branch ", colors.UserInput(name), ".\n")
+		}
+	}
+	cu.Cancel()
+	return tx.Commit()
+}
+
+func init() {
+	stackAbandonCmd.Flags().StringVar(
+		&stackAbandonFlags.Reason, "reason", "",
+		"the reason for abandoning the stack, appended to the comment on the pull requests",
+	)
+	stackAbandonCmd.Flags().StringVar(
+		&stackAbandonFlags.Branches, "branches", "",
+		"what to do with the branches\n(archive|delete, default: the stack.abandonBranches config)",
+	)
+}
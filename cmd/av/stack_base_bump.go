@@ -0,0 +1,215 @@
+package main
+
+import (
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/sequencer"
+	"github.com/aviator-co/av/internal/sequencer/planner"
+	"github.com/aviator-co/av/internal/sequencer/sequencerui"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/cobra"
+)
+
+var stackBaseBumpCmd = &cobra.Command{
+	Use:   "base-bump",
+	Short: "Rebase only the root branch of the current stack onto the latest trunk",
+	Long: strings.TrimSpace(`
+Rebase only the root branch of the current stack onto the latest trunk, and leave
+the rest of the stack as it is.
+
+The trunk branch is fetched from the remote, and the stack root is rebased onto
+it (e.g., to pick up a fix that the bottom branch needs). The other branches of
+the stack are not touched, so they show up as needing a restack in av tree until
+av restack or av sync rebases them onto the new root.
+
+If the rebase conflicts, resolve the conflicts and run av restack --continue.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		if err := checkNoOtherOperationInProgress(repo, git.StateFileKindRestack); err != nil {
+			return err
+		}
+		return uiutils.RunBubbleTea(&stackBaseBumpViewModel{repo: repo, db: db})
+	},
+}
+
+type stackBaseBumpViewModel struct {
+	repo *git.Repo
+	db   meta.DB
+
+	restackModel *sequencerui.RestackModel
+
+	// The stack root that is rebased.
+	root string
+	// The descendants of the stack root that are left for the next restack. Set after the
+	// rebase is done.
+	deferred []string
+
+	done             bool
+	quitWithConflict bool
+	err              error
+}
+
+func (vm *stackBaseBumpViewModel) Init() tea.Cmd {
+	state, err := vm.createState()
+	if err != nil {
+		return func() tea.Msg { return err }
+	}
+	vm.restackModel = sequencerui.NewRestackModel(vm.repo, vm.db)
+	vm.restackModel.State = state
+	return vm.restackModel.Init()
+}
+
+func (vm *stackBaseBumpViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case *sequencerui.RestackProgress, spinner.TickMsg:
+		var cmd tea.Cmd
+		vm.restackModel, cmd = vm.restackModel.Update(msg)
+		return vm, cmd
+	case *sequencerui.RestackConflict:
+		if err := vm.writeState(vm.restackModel.State); err != nil {
+			return vm, func() tea.Msg { return err }
+		}
+		vm.quitWithConflict = true
+		return vm, tea.Quit
+	case *sequencerui.RestackAbort, *sequencerui.RestackDone:
+		if err := vm.writeState(nil); err != nil {
+			return vm, func() tea.Msg { return err }
+		}
+		if _, ok := msg.(*sequencerui.RestackDone); ok {
+			vm.done = true
+			vm.deferred = vm.branchesToRestack()
+		}
+		return vm, tea.Quit
+	case tea.KeyMsg:
+		if vm.restackModel != nil && vm.restackModel.IsPrompting() {
+			var cmd tea.Cmd
+			vm.restackModel, cmd = vm.restackModel.Update(msg)
+			return vm, cmd
+		}
+		switch msg.String() {
+		case "ctrl+c":
+			return vm, tea.Quit
+		}
+	case error:
+		vm.err = msg
+		return vm, tea.Quit
+	}
+	return vm, nil
+}
+
+func (vm *stackBaseBumpViewModel) View() string {
+	var ss []string
+	if vm.root != "" {
+		ss = append(ss, "Rebasing "+vm.root+" onto the latest trunk...")
+	}
+	if vm.restackModel != nil {
+		ss = append(ss, vm.restackModel.View())
+	}
+	if vm.done && len(vm.deferred) > 0 {
+		var sb strings.Builder
+		sb.WriteString("These branches need a restack onto " + vm.root + ":\n")
+		for _, name := range vm.deferred {
+			sb.WriteString("  - " + name + "\n")
+		}
+		sb.WriteString(
+			colors.Faint("Run ") + colors.CliCmd("av restack") +
+				colors.Faint(" (or av sync) when you are ready to rebase them."),
+		)
+		ss = append(ss, sb.String())
+	}
+
+	var ret string
+	if len(ss) != 0 {
+		ret = lipgloss.NewStyle().MarginTop(1).MarginBottom(1).MarginLeft(2).Render(
+			lipgloss.JoinVertical(0, ss...),
+		)
+	}
+	if vm.err != nil {
+		if len(ret) != 0 {
+			ret += "\n"
+		}
+		ret += renderError(vm.err)
+	}
+	return ret
+}
+
+// branchesToRestack returns the descendants of the stack root that are not on top of their
+// parents anymore.
+func (vm *stackBaseBumpViewModel) branchesToRestack() []string {
+	tx := vm.db.ReadTx()
+	var ret []string
+	for _, name := range meta.SubsequentBranches(tx, vm.root) {
+		if branchNeedsRestack(vm.repo, tx, name) {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+func (vm *stackBaseBumpViewModel) writeState(state *sequencerui.RestackState) error {
+	if state == nil {
+		return vm.repo.WriteStateFile(git.StateFileKindRestack, nil)
+	}
+	return vm.repo.WriteStateFile(git.StateFileKindRestack, state)
+}
+
+func (vm *stackBaseBumpViewModel) createState() (*sequencerui.RestackState, error) {
+	currentBranch, err := vm.repo.CurrentBranchName()
+	if err != nil {
+		return nil, err
+	}
+	tx := vm.db.ReadTx()
+	if _, exist := tx.Branch(currentBranch); !exist {
+		return nil, actions.BranchNotAdoptedError{Branch: currentBranch}
+	}
+	ops, err := planner.PlanForBaseBump(tx, plumbing.NewBranchReferenceName(currentBranch))
+	if err != nil {
+		return nil, err
+	}
+	vm.root = ops[0].Name.Short()
+
+	// The sequencer rebases the stack root onto the remote tracking branch of the trunk.
+	trunk := ops[0].NewParent.Short()
+	remote := vm.repo.GetRemoteName()
+	if _, err := vm.repo.Git("fetch", remote, trunk); err != nil {
+		return nil, errors.WrapIff(err, "failed to fetch %s from %s", trunk, remote)
+	}
+	if err := oplog.Record(vm.repo, tx, "av stack base-bump"); err != nil {
+		return nil, err
+	}
+	var state sequencerui.RestackState
+	state.InitialBranch = currentBranch
+	state.RelatedBranches = []string{currentBranch}
+	state.Seq = sequencer.NewSequencer(remote, vm.db, ops)
+	return &state, nil
+}
+
+func (vm *stackBaseBumpViewModel) ExitError() error {
+	if vm.err != nil {
+		return actions.ErrExitSilently{ExitCode: 1, Err: vm.err}
+	}
+	if vm.quitWithConflict {
+		return actions.ErrExitConflict
+	}
+	return nil
+}
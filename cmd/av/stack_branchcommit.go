@@ -30,6 +30,7 @@ var stackBranchCommitCmd = &cobra.Command{
 			stackBranchCommitFlags.Message,
 			stackBranchCommitFlags.All,
 			stackBranchCommitFlags.AllModified,
-			"")
+			"",
+			false)
 	},
 }
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// pullRequestRefPattern matches a pull request number ("123" or "#123") or a pull request URL
+// ("https://github.com/owner/repo/pull/123").
+var pullRequestRefPattern = regexp.MustCompile(
+	`^(?:https?://[^/]+/([^/]+)/([^/]+)/pulls?/)?#?([0-9]+)(?:[/?#].*)?$`,
+)
+
+var stackCheckoutCmd = &cobra.Command{
+	Use:   "checkout <pr-number | pr-url>",
+	Short: "Check out the stack of a pull request from GitHub",
+	Long: strings.TrimSpace(`
+Check out the stack of a pull request from GitHub (e.g., to review a teammate's
+stack).
+
+The base branches of the pull request are followed through their pull requests
+down to the trunk, and the open pull requests stacked on top of it are followed
+up. All the branches are fetched from the remote and created locally with the av
+metadata of the stack, so that av tree and av sync work on them. The branch of
+the given pull request is checked out at the end.
+
+A local branch that already exists is fast-forwarded to the remote branch. If it
+has diverged from the remote branch, the command fails.
+`),
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		client, err := getGitHubClient()
+		if err != nil {
+			return err
+		}
+		info := db.ReadTx().Repository()
+		number, err := parsePullRequestRef(args[0], info)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		target, err := client.PullRequestByNumber(ctx, gh.PullRequestOpts{
+			Owner:  info.Owner,
+			Repo:   info.Name,
+			Number: number,
+		})
+		if err != nil {
+			return err
+		}
+		prs, trunk, err := stackPullRequests(ctx, repo, client, info, target)
+		if err != nil {
+			return err
+		}
+
+		if err := adoptPullRequestBranches(repo, db, prs, trunk); err != nil {
+			return err
+		}
+
+		if _, err := repo.CheckoutBranch(&git.CheckoutBranch{Name: target.HeadBranchName()}); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Checked out the stack of pull request "),
+			colors.UserInput("#", target.Number), colors.Success(". Run "), colors.CliCmd("av tree"),
+			colors.Success(" to see it.\n"),
+		)
+		return nil
+	},
+}
+
+// parsePullRequestRef parses a pull request number or URL. The URL must be of the repository.
+func parsePullRequestRef(ref string, info meta.Repository) (int64, error) {
+	m := pullRequestRefPattern.FindStringSubmatch(strings.TrimSpace(ref))
+	if m == nil {
+		return 0, errors.Errorf("%q is not a pull request number or URL", ref)
+	}
+	if m[1] != "" && (!strings.EqualFold(m[1], info.Owner) || !strings.EqualFold(m[2], info.Name)) {
+		return 0, errors.Errorf(
+			"pull request %q is not in this repository (%s/%s)", ref, info.Owner, info.Name,
+		)
+	}
+	return strconv.ParseInt(m[3], 10, 64)
+}
+
+// adoptPullRequestBranches fetches the head branches of the pull requests (in the stack order)
+// from the remote, creates or fast-forwards the local branches, and records the av metadata of
+// the stack based on the pull requests.
+func adoptPullRequestBranches(
+	repo *git.Repo,
+	db meta.DB,
+	prs []*gh.PullRequest,
+	trunk string,
+) (reterr error) {
+	remote := repo.GetRemoteName()
+	fetchArgs := []string{"fetch", remote}
+	for _, pr := range prs {
+		name := pr.HeadBranchName()
+		fetchArgs = append(fetchArgs, "+refs/heads/"+name+":"+git.RemoteTrackingRef(remote, name))
+	}
+	if _, err := repo.Run(&git.RunOpts{Args: fetchArgs, ExitError: true}); err != nil {
+		return errors.WrapIf(err, "failed to fetch the branches of the stack")
+	}
+
+	tx := db.WriteTx()
+	cu := cleanup.New(func() {
+		logrus.WithError(reterr).Debug("aborting db transaction")
+		tx.Abort()
+	})
+	defer cu.Cleanup()
+
+	currentBranch, _ := repo.CurrentBranchName()
+	for _, pr := range prs {
+		name := pr.HeadBranchName()
+		remoteBranch := git.RemoteTrackingRef(remote, name)
+		if err := updateLocalBranch(repo, name, remoteBranch, currentBranch); err != nil {
+			return err
+		}
+
+		branch, _ := tx.Branch(name)
+		base := pr.BaseBranchName()
+		if base == trunk {
+			branch.Parent = meta.BranchState{Name: base, Trunk: true}
+		} else {
+			mergeBase, err := repo.MergeBase(base, name)
+			if err != nil {
+				return err
+			}
+			branch.Parent = meta.BranchState{Name: base, Head: mergeBase}
+		}
+		branch.PullRequest = &meta.PullRequest{
+			ID:        pr.ID,
+			Number:    pr.Number,
+			Permalink: pr.Permalink,
+			State:     pr.State,
+			Title:     pr.Title,
+			FetchedAt: time.Now(),
+		}
+		tx.SetBranch(branch)
+		fmt.Fprint(os.Stderr,
+			"  - ", colors.UserInput(name), " ", colors.Faint(pr.Permalink), "\n",
+		)
+	}
+	cu.Cancel()
+	return tx.Commit()
+}
+
+// stackPullRequests returns the pull requests of the stack of the given pull request in the
+// stack order (parents first) and the trunk branch that the stack is based on.
+func stackPullRequests(
+	ctx context.Context,
+	repo *git.Repo,
+	client *gh.Client,
+	info meta.Repository,
+	target *gh.PullRequest,
+) ([]*gh.PullRequest, string, error) {
+	ancestors, trunk, err := pullRequestChain(ctx, repo, client, info, target)
+	if err != nil {
+		return nil, "", err
+	}
+	seen := map[string]bool{}
+	for _, pr := range ancestors {
+		seen[pr.HeadBranchName()] = true
+	}
+
+	ret := ancestors
+	queue := []string{target.HeadBranchName()}
+	for len(queue) > 0 {
+		head := queue[0]
+		queue = queue[1:]
+		page, err := client.GetPullRequests(ctx, gh.GetPullRequestsInput{
+			Owner:       info.Owner,
+			Repo:        info.Name,
+			BaseRefName: head,
+			States:      []githubv4.PullRequestState{githubv4.PullRequestStateOpen},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		for i := range page.PullRequests {
+			pr := &page.PullRequests[i]
+			if seen[pr.HeadBranchName()] {
+				continue
+			}
+			seen[pr.HeadBranchName()] = true
+			ret = append(ret, pr)
+			queue = append(queue, pr.HeadBranchName())
+		}
+	}
+	return ret, trunk, nil
+}
+
+// pullRequestChain returns the given pull request and the pull requests of its base branches
+// down to the trunk in the stack order (parents first), and the trunk branch that the chain is
+// based on.
+func pullRequestChain(
+	ctx context.Context,
+	repo *git.Repo,
+	client *gh.Client,
+	info meta.Repository,
+	target *gh.PullRequest,
+) ([]*gh.PullRequest, string, error) {
+	seen := map[string]bool{target.HeadBranchName(): true}
+	ancestors := []*gh.PullRequest{target}
+	for {
+		base := ancestors[0].BaseBranchName()
+		isTrunk, err := repo.IsTrunkBranch(base)
+		if err != nil {
+			return nil, "", err
+		}
+		if isTrunk {
+			break
+		}
+		if seen[base] {
+			return nil, "", errors.Errorf("the base branches of the pull requests form a cycle at %q", base)
+		}
+		seen[base] = true
+		page, err := client.GetPullRequests(ctx, gh.GetPullRequestsInput{
+			Owner:       info.Owner,
+			Repo:        info.Name,
+			HeadRefName: base,
+			States:      []githubv4.PullRequestState{githubv4.PullRequestStateOpen},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		if len(page.PullRequests) == 0 {
+			return nil, "", errors.Errorf(
+				"branch %q (the base of pull request #%d) has no open pull request",
+				base, ancestors[0].Number,
+			)
+		}
+		ancestors = append([]*gh.PullRequest{&page.PullRequests[0]}, ancestors...)
+	}
+	return ancestors, ancestors[0].BaseBranchName(), nil
+}
+
+// updateLocalBranch creates the local branch from the remote branch, or fast-forwards it if it
+// already exists.
+func updateLocalBranch(repo *git.Repo, name string, remoteBranch string, currentBranch string) error {
+	remoteHead, err := repo.RevParse(&git.RevParse{Rev: remoteBranch})
+	if err != nil {
+		return err
+	}
+	if exists, err := repo.DoesBranchExist(name); err != nil {
+		return err
+	} else if !exists {
+		_, err := repo.Git("branch", "--no-track", name, remoteHead)
+		return errors.WrapIff(err, "failed to create branch %q", name)
+	}
+	localHead, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + name})
+	if err != nil {
+		return err
+	}
+	if localHead == remoteHead {
+		return nil
+	}
+	if _, err := repo.Git("merge-base", "--is-ancestor", localHead, remoteHead); err != nil {
+		return errors.Errorf(
+			"branch %q already exists and has diverged from %s", name, remoteBranch,
+		)
+	}
+	if name == currentBranch {
+		_, err = repo.Git("merge", "--ff-only", "--quiet", remoteHead)
+	} else {
+		_, err = repo.Git("branch", "--force", name, remoteHead)
+	}
+	return errors.WrapIff(err, "failed to fast-forward branch %q", name)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+// stackManifestFileName is the name of the manifest file in the patch directory.
+const stackManifestFileName = "manifest.json"
+
+// stackManifest describes the stack exported by `av stack export` so that `av stack import` can reconstruct
+// it.
+type stackManifest struct {
+	Version int `json:"version"`
+	// The trunk branch that the stack is based on.
+	Trunk string `json:"trunk"`
+	// The commit of the trunk that the stack root is based on.
+	Base string `json:"base"`
+	// The branches of the stack, parents first.
+	Branches []stackManifestBranch `json:"branches"`
+}
+
+type stackManifestBranch struct {
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+	// The directory (relative to the patch directory) that contains the patches of the branch.
+	Dir string `json:"dir"`
+	// The number of patches (commits) of the branch.
+	Commits int `json:"commits"`
+}
+
+var stackExportFlags struct {
+	PatchDir string
+}
+
+var patchDirNameReplacedPattern = regexp.MustCompile("[^-_.a-zA-Z0-9]+")
+
+var stackExportCmd = &cobra.Command{
+	Use:   "export --patch-dir <dir>",
+	Short: "Export the stack as per-branch patch directories",
+	Long: strings.TrimSpace(`
+Export the current stack as per-branch directories of patches plus a manifest.
+
+Each branch of the stack is exported to a directory of patches (as created by
+git format-patch) that contains the commits between the branch and its parent.
+The manifest records the branches, their parents, and the trunk commit that the
+stack is based on. Use av stack import to reconstruct the stack in another repository.
+
+This is useful to move a stack across networks where neither Git bundles nor
+GitHub are available.
+`),
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		branches, err := meta.StackBranches(tx, currentBranch)
+		if err != nil {
+			return err
+		}
+		root, _ := tx.Branch(branches[0])
+		base, err := repo.MergeBase(root.Parent.Name, root.Name)
+		if err != nil {
+			return err
+		}
+
+		if entries, err := os.ReadDir(stackExportFlags.PatchDir); err == nil && len(entries) > 0 {
+			return errors.Errorf("patch directory %q is not empty", stackExportFlags.PatchDir)
+		}
+		if err := os.MkdirAll(stackExportFlags.PatchDir, 0755); err != nil {
+			return errors.WrapIf(err, "failed to create the patch directory")
+		}
+
+		manifest := stackManifest{
+			Version: 1,
+			Trunk:   root.Parent.Name,
+			Base:    base,
+		}
+		for i, name := range branches {
+			branch, _ := tx.Branch(name)
+			from, err := branchDiffBase(repo, tx, name)
+			if err != nil {
+				return err
+			}
+			dir := fmt.Sprintf("%02d-%s", i+1, patchDirNameReplacedPattern.ReplaceAllString(name, "-"))
+			out, err := repo.Git(
+				"format-patch", "--binary", "--no-signature",
+				"--output-directory", filepath.Join(stackExportFlags.PatchDir, dir),
+				from+".."+name,
+			)
+			if err != nil {
+				return errors.WrapIff(err, "failed to export branch %q", name)
+			}
+			var commits int
+			if out != "" {
+				commits = len(strings.Split(out, "\n"))
+			}
+			manifest.Branches = append(manifest.Branches, stackManifestBranch{
+				Name:    name,
+				Parent:  branch.Parent.Name,
+				Dir:     dir,
+				Commits: commits,
+			})
+			fmt.Fprint(os.Stderr,
+				"  - exported ", colors.UserInput(commits), " commit(s) of branch ",
+				colors.UserInput(name), "\n",
+			)
+		}
+
+		data, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(
+			filepath.Join(stackExportFlags.PatchDir, stackManifestFileName), append(data, '\n'), 0644,
+		); err != nil {
+			return errors.WrapIf(err, "failed to write the manifest")
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Exported the stack to "), colors.UserInput(stackExportFlags.PatchDir),
+			colors.Success(". Run "), colors.CliCmd("av stack import --patch-dir <dir>"),
+			colors.Success(" to import it.\n"),
+		)
+		return nil
+	},
+}
+
+func init() {
+	stackExportCmd.Flags().StringVar(
+		&stackExportFlags.PatchDir, "patch-dir", "",
+		"the directory to export the patches and the manifest to",
+	)
+	_ = stackExportCmd.MarkFlagRequired("patch-dir")
+	_ = stackExportCmd.MarkFlagDirname("patch-dir")
+}
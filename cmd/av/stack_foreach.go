@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 
 	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/colors"
@@ -14,31 +16,59 @@ import (
 )
 
 var stackForEachFlags struct {
-	previous   bool
-	subsequent bool
+	previous        bool
+	subsequent      bool
+	From            string
+	Down            bool
+	Up              bool
+	ContinueOnError bool
+}
+
+// stackForEachResult is the result of the command for a branch.
+type stackForEachResult struct {
+	Branch string `json:"branch"`
+	// The exit code of the command. -1 if the command couldn't be executed.
+	ExitCode int `json:"exitCode"`
+	// True if the command was not executed because the command failed for a previous branch.
+	Skipped bool `json:"skipped,omitempty"`
 }
 
 var stackForEachCmd = &cobra.Command{
-	Use:        "for-each [flags] -- <command> [args...] ggit ",
+	Use:        "for-each [flags] -- <command> [args...]",
 	Aliases:    []string{"foreach", "fe"},
 	Hidden:     true,
 	Deprecated: "this command has been deprecated and will be removed in a future release",
 	Short:      "execute a command for each branch in the current stack",
 	Long: `Execute a command for each branch in the current stack.
 
+Each branch is checked out in the stack order (from the bottom of the stack to the
+top), and the command is executed on it. The original branch is checked out again
+at the end. If the command fails for a branch, the remaining branches are skipped
+unless --continue-on-error is given.
+
+To start from a specific branch, use --from (defaults to the current branch with
+--up or --down). With --up (the default with --from), the command is executed for
+the branch and the branches above it. With --down, the command is executed for
+the branch and the branches below it, from the top to the bottom.
+
 To prevent flags for the command to be executed from being parsed as flags for
 this command, use the "--" separator (see examples below).
 
-Output from the command will be printed to stdout/stderr as it is generated.
+Output from the command will be printed to stdout/stderr as it is generated. With
+--json, the output of the command is printed to stderr, and a JSON summary of the
+exit status of each branch is printed to stdout.
 
 Examples:
   Print the current HEAD commit for each branch in the stack:
-    $ av stack for-each -- git rev-parse HEAD
+    $ av stack foreach -- git rev-parse HEAD
+
+  Run the tests for the current branch and the branches above it:
+    $ av stack foreach --up --continue-on-error -- make test
 
   Push every branch in the stack:
-	$ av stack for-each -- git push --force
+    $ av stack foreach -- git push --force
   Note that the "--" separator is required here to prevent "--force" from being
-  interpreted as a flag for the "stack for-each" command.
+  interpreted as a flag for the "stack foreach" command.
 `,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -56,28 +86,27 @@ Examples:
 			return err
 		}
 
-		var branches []string
-		if stackForEachFlags.previous {
-			branches, err = meta.PreviousBranches(tx, currentBranch)
-			if err != nil {
-				return err
-			}
-			branches = append(branches, currentBranch)
-		} else if stackForEachFlags.subsequent {
-			branches = meta.SubsequentBranches(tx, currentBranch)
-			branches = append([]string{currentBranch}, branches...)
-		} else {
-			branches, err = meta.StackBranches(tx, currentBranch)
-			if err != nil {
-				return err
-			}
+		branches, err := stackForEachBranches(tx, currentBranch)
+		if err != nil {
+			return err
 		}
 
+		var stdout io.Writer = os.Stdout
+		if jsonOutput() {
+			// Keep stdout for the summary.
+			stdout = os.Stderr
+		}
 		_, _ = fmt.Fprint(os.Stderr,
 			"Executing command ", colors.CliCmd(executils.FormatCommandLine(args)),
 			" for ", colors.UserInput(len(branches)), " branches:\n",
 		)
+		results := make([]stackForEachResult, 0, len(branches))
+		failed := false
 		for _, branch := range branches {
+			if failed && !stackForEachFlags.ContinueOnError {
+				results = append(results, stackForEachResult{Branch: branch, ExitCode: -1, Skipped: true})
+				continue
+			}
 			_, _ = fmt.Fprint(os.Stderr,
 				"  - switching to branch ", colors.UserInput(branch), "\n",
 			)
@@ -85,24 +114,121 @@ Examples:
 				return errors.Wrapf(err, "failed to switch to branch %q", branch)
 			}
 			cmd := exec.Command(args[0], args[1:]...)
-			cmd.Stdout = os.Stdout
+			cmd.Stdout = stdout
 			cmd.Stderr = os.Stderr
+			result := stackForEachResult{Branch: branch}
 			if err := cmd.Run(); err != nil {
-				return errors.Wrapf(err, "failed to execute command for branch %q", branch)
+				var exitErr *exec.ExitError
+				if errors.As(err, &exitErr) {
+					result.ExitCode = exitErr.ExitCode()
+				} else {
+					_, _ = fmt.Fprint(os.Stderr, colors.Failure("    ", err.Error(), "\n"))
+					result.ExitCode = -1
+				}
+				failed = true
 			}
+			results = append(results, result)
 		}
 
-		// Switch back to the original branch.
-		// We only do this on success, because on failure, it's likely that the
-		// user will want to be on the branch that had issues.
 		if _, err := repo.CheckoutBranch(&git.CheckoutBranch{Name: currentBranch}); err != nil {
 			return errors.Wrapf(err, "failed to switch back to branch %q", currentBranch)
 		}
+
+		printStackForEachSummary(results)
+		if jsonOutput() {
+			if err := printJSON(results); err != nil {
+				return err
+			}
+		}
+		if failed {
+			return actions.ErrExitSilently{ExitCode: 1}
+		}
 		return nil
 	},
 }
 
+// stackForEachBranches returns the branches to execute the command for in the execution
+// order.
+func stackForEachBranches(tx meta.ReadTx, currentBranch string) ([]string, error) {
+	switch {
+	case stackForEachFlags.previous:
+		branches, err := meta.PreviousBranches(tx, currentBranch)
+		if err != nil {
+			return nil, err
+		}
+		return append(branches, currentBranch), nil
+	case stackForEachFlags.subsequent:
+		return append([]string{currentBranch}, meta.SubsequentBranches(tx, currentBranch)...), nil
+	case stackForEachFlags.From == "" && !stackForEachFlags.Up && !stackForEachFlags.Down:
+		return meta.StackBranches(tx, currentBranch)
+	}
+
+	from := stackForEachFlags.From
+	if from == "" {
+		from = currentBranch
+	}
+	if _, ok := tx.Branch(from); !ok {
+		return nil, actions.BranchNotAdoptedError{Branch: from}
+	}
+	if stackForEachFlags.Down {
+		branches, err := meta.PreviousBranches(tx, from)
+		if err != nil {
+			return nil, err
+		}
+		ret := []string{from}
+		for i := len(branches) - 1; i >= 0; i-- {
+			ret = append(ret, branches[i])
+		}
+		return ret, nil
+	}
+	return append([]string{from}, meta.SubsequentBranches(tx, from)...), nil
+}
+
+func printStackForEachSummary(results []stackForEachResult) {
+	_, _ = fmt.Fprint(os.Stderr, "\nSummary:\n")
+	for _, result := range results {
+		switch {
+		case result.Skipped:
+			_, _ = fmt.Fprint(os.Stderr,
+				"  ", colors.Faint("- "), colors.UserInput(result.Branch), colors.Faint(": skipped"), "\n",
+			)
+		case result.ExitCode == 0:
+			_, _ = fmt.Fprint(os.Stderr,
+				"  ", colors.Success("✓ "), colors.UserInput(result.Branch), "\n",
+			)
+		default:
+			_, _ = fmt.Fprint(os.Stderr,
+				"  ", colors.Failure("✗ "), colors.UserInput(result.Branch),
+				colors.Failure(": ", stackForEachExitStatus(result.ExitCode)), "\n",
+			)
+		}
+	}
+}
+
+func stackForEachExitStatus(exitCode int) string {
+	if exitCode < 0 {
+		return "failed to execute"
+	}
+	return fmt.Sprintf("exit status %d", exitCode)
+}
+
 func init() {
+	stackForEachCmd.Flags().StringVar(
+		&stackForEachFlags.From, "from", "",
+		"the branch to start from (defaults to the current branch with --up or --down)",
+	)
+	stackForEachCmd.Flags().BoolVar(
+		&stackForEachFlags.Up, "up", false,
+		"apply the command to the starting branch and the branches above it",
+	)
+	stackForEachCmd.Flags().BoolVar(
+		&stackForEachFlags.Down, "down", false,
+		"apply the command to the starting branch and the branches below it, from the top",
+	)
+	stackForEachCmd.Flags().BoolVar(
+		&stackForEachFlags.ContinueOnError, "continue-on-error", false,
+		"continue with the remaining branches if the command fails",
+	)
 	stackForEachCmd.Flags().BoolVar(
 		&stackForEachFlags.previous, "previous", false,
 		"apply the command only to the current branch and all previous branches in the stack",
@@ -111,5 +237,11 @@ func init() {
 		&stackForEachFlags.subsequent, "subsequent", false,
 		"apply the command only to the current branch and all subsequent branches in the stack",
 	)
-	stackForEachCmd.MarkFlagsMutuallyExclusive("previous", "subsequent")
+	_ = stackForEachCmd.Flags().MarkDeprecated("previous", "use --down instead")
+	_ = stackForEachCmd.Flags().MarkDeprecated("subsequent", "use --up instead")
+	stackForEachCmd.MarkFlagsMutuallyExclusive("previous", "subsequent", "up", "down")
+	stackForEachCmd.MarkFlagsMutuallyExclusive("previous", "from")
+	stackForEachCmd.MarkFlagsMutuallyExclusive("subsequent", "from")
+
+	_ = stackForEachCmd.RegisterFlagCompletionFunc("from", branchNameArgs)
 }
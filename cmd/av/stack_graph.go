@@ -0,0 +1,299 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/browser"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/stackutils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+//go:embed stack_graph.html
+var stackGraphPage []byte
+
+var stackGraphFlags struct {
+	Serve bool
+	Addr  string
+	Open  bool
+}
+
+var stackGraphCmd = &cobra.Command{
+	Use:   "graph [--serve [--addr <addr>] [--open]]",
+	Short: "Show the graph of the stacks (as Graphviz or a local web page)",
+	Long: strings.TrimSpace(`
+Print the graph of all the stacks in the Graphviz DOT format. The branches that
+need av sync are highlighted, and the pull requests are linked in the SVG output
+(e.g., av stack graph | dot -Tsvg > stacks.svg).
+
+With --serve, a small web page that renders the graph is served locally instead.
+The page shows the pull request links, the branches that need a restack, and the
+checked-out branch, and it refreshes itself as the branches change. This is
+useful on a large monitor or to share the state of the stacks during a review.
+Press Ctrl-C to stop the server.
+`),
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		if !stackGraphFlags.Serve {
+			if cmd.Flags().Changed("addr") || stackGraphFlags.Open {
+				return errors.New("--addr and --open can only be used with --serve")
+			}
+			graph, err := readStackGraph(repo)
+			if err != nil {
+				return err
+			}
+			return writeStackGraphDot(os.Stdout, graph, false)
+		}
+
+		listener, err := net.Listen("tcp", stackGraphFlags.Addr)
+		if err != nil {
+			return errors.WrapIf(err, "failed to listen")
+		}
+		url := "http://" + listener.Addr().String() + "/"
+		fmt.Fprint(os.Stderr,
+			colors.Success("Serving the stack graph at "), colors.UserInput(url), "\n",
+			colors.Faint("Press Ctrl-C to stop.\n"),
+		)
+		if stackGraphFlags.Open {
+			if err := browser.Open(url); err != nil {
+				logrus.WithError(err).Warn("failed to open the browser")
+			}
+		}
+		return http.Serve(listener, newStackGraphHandler(repo))
+	},
+}
+
+// stackGraphOutput is the JSON served to the stack graph page.
+type stackGraphOutput struct {
+	Trunks   []string       `json:"trunks"`
+	Branches []branchOutput `json:"branches"`
+}
+
+// readStackGraph reads all the stacks. The database is opened every time so that the changes
+// made by the other av commands are picked up while serving.
+func readStackGraph(repo *git.Repo) (*stackGraphOutput, error) {
+	db, err := getDB(repo)
+	if err != nil {
+		return nil, err
+	}
+	tx := db.ReadTx()
+	// The current branch is empty on a detached HEAD.
+	currentBranch, _ := repo.CurrentBranchName()
+	rootNodes := stackutils.BuildStackTreeAllBranches(tx, currentBranch, true)
+	ret := &stackGraphOutput{Trunks: []string{}}
+	for _, node := range rootNodes {
+		ret.Trunks = append(ret.Trunks, node.Branch.BranchName)
+	}
+	ret.Branches = newBranchOutputs(repo, tx, currentBranch, stackTreeBranchNames(rootNodes))
+	for _, br := range ret.Branches {
+		if br.PullRequest != nil {
+			br.PullRequest.URL = graphLinkURL(br.PullRequest.URL)
+		}
+	}
+	return ret, nil
+}
+
+// graphLinkURL returns the URL if it's an http(s) URL, or an empty string otherwise. The URLs
+// are linked in the graph, so the other schemes (e.g., javascript:) must not be passed through.
+func graphLinkURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ""
+	}
+	return u
+}
+
+func newStackGraphHandler(repo *git.Repo) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(stackGraphPage)
+	})
+	mux.HandleFunc("/graph.json", func(w http.ResponseWriter, r *http.Request) {
+		graph, err := readStackGraph(repo)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(graph); err != nil {
+			logrus.WithError(err).Debug("failed to write the stack graph")
+		}
+	})
+	return mux
+}
+
+// writeStackGraphDot writes the graph in the Graphviz DOT format. With commitCounts, the edges
+// are labeled with the number of the commits in the child branch.
+func writeStackGraphDot(w io.Writer, graph *stackGraphOutput, commitCounts bool) error {
+	var sb strings.Builder
+	sb.WriteString("digraph stacks {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box, style=rounded, fontname=\"Helvetica\"];\n")
+	for _, trunk := range graph.Trunks {
+		fmt.Fprintf(&sb, "  %s [style=\"rounded,bold\"];\n", strconv.Quote(trunk))
+	}
+	for _, br := range graph.Branches {
+		label := br.Name
+		attrs := []string{}
+		styles := []string{"rounded"}
+		if br.PullRequest != nil {
+			label += fmt.Sprintf("\\n#%d (%s)", br.PullRequest.Number, br.PullRequest.State)
+			if link := graphLinkURL(br.PullRequest.URL); link != "" {
+				attrs = append(attrs, "URL="+strconv.Quote(link))
+			}
+			switch br.PullRequest.State {
+			case "merged":
+				attrs = append(attrs, "color=\"purple\"", "fontcolor=\"purple\"")
+			case "closed":
+				styles = append(styles, "dashed")
+				attrs = append(attrs, "color=\"gray\"", "fontcolor=\"gray\"")
+			}
+		}
+		if br.NeedsSync {
+			label += "\\nneeds sync"
+			attrs = append(attrs, "color=\"orange\"")
+		}
+		if br.Current {
+			styles = append(styles, "filled")
+		}
+		if len(styles) > 1 {
+			attrs = append(attrs, "style="+strconv.Quote(strings.Join(styles, ",")))
+		}
+		if br.Current {
+			attrs = append(attrs, "fillcolor=\"lightblue\"")
+		}
+		attrs = append([]string{"label=\"" + strings.ReplaceAll(label, "\"", "\\\"") + "\""}, attrs...)
+		fmt.Fprintf(&sb, "  %s [%s];\n", strconv.Quote(br.Name), strings.Join(attrs, ", "))
+		if commitCounts {
+			fmt.Fprintf(
+				&sb, "  %s -> %s [label=%s];\n", strconv.Quote(br.Parent), strconv.Quote(br.Name),
+				strconv.Quote(commitCountLabel(br.Ahead)),
+			)
+		} else {
+			fmt.Fprintf(&sb, "  %s -> %s;\n", strconv.Quote(br.Parent), strconv.Quote(br.Name))
+		}
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeStackGraphMermaid writes the graph as a Mermaid flowchart (e.g., to paste into a
+// Markdown document). With commitCounts, the edges are labeled with the number of the commits
+// in the child branch.
+func writeStackGraphMermaid(w io.Writer, graph *stackGraphOutput, commitCounts bool) error {
+	// The branch names can contain characters that are not allowed in the node IDs.
+	ids := map[string]string{}
+	nodeID := func(name string) string {
+		if id, ok := ids[name]; ok {
+			return id
+		}
+		id := fmt.Sprintf("n%d", len(ids))
+		ids[name] = id
+		return id
+	}
+	classes := map[string][]string{}
+	var sb strings.Builder
+	sb.WriteString("graph LR\n")
+	for _, trunk := range graph.Trunks {
+		fmt.Fprintf(&sb, "  %s[\"%s\"]\n", nodeID(trunk), mermaidEscape(trunk))
+		classes["trunk"] = append(classes["trunk"], nodeID(trunk))
+	}
+	var clicks []string
+	for _, br := range graph.Branches {
+		id := nodeID(br.Name)
+		label := mermaidEscape(br.Name)
+		if br.PullRequest != nil {
+			label += fmt.Sprintf("<br/>#%d (%s)", br.PullRequest.Number, br.PullRequest.State)
+			if link := graphLinkURL(br.PullRequest.URL); link != "" {
+				clicks = append(clicks, fmt.Sprintf("  click %s %q\n", id, link))
+			}
+			if br.PullRequest.State == "merged" || br.PullRequest.State == "closed" {
+				classes[br.PullRequest.State] = append(classes[br.PullRequest.State], id)
+			}
+		}
+		if br.NeedsSync {
+			label += "<br/>needs sync"
+			classes["needsSync"] = append(classes["needsSync"], id)
+		}
+		if br.Current {
+			classes["current"] = append(classes["current"], id)
+		}
+		fmt.Fprintf(&sb, "  %s[\"%s\"]\n", id, label)
+		if commitCounts {
+			fmt.Fprintf(
+				&sb, "  %s -->|%s| %s\n", nodeID(br.Parent), commitCountLabel(br.Ahead), id,
+			)
+		} else {
+			fmt.Fprintf(&sb, "  %s --> %s\n", nodeID(br.Parent), id)
+		}
+	}
+	for _, class := range []struct{ name, style string }{
+		{"trunk", "font-weight:bold"},
+		{"merged", "stroke:#8250df,color:#8250df"},
+		{"closed", "stroke:#999,stroke-dasharray:4 4,color:#999"},
+		{"needsSync", "stroke:#ffa500"},
+		{"current", "fill:#add8e6"},
+	} {
+		if len(classes[class.name]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "  classDef %s %s\n", class.name, class.style)
+		fmt.Fprintf(&sb, "  class %s %s\n", strings.Join(classes[class.name], ","), class.name)
+	}
+	for _, click := range clicks {
+		sb.WriteString(click)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// mermaidEscape escapes the characters that end a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer("\"", "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
+
+func commitCountLabel(n int) string {
+	if n == 1 {
+		return "1 commit"
+	}
+	return fmt.Sprintf("%d commits", n)
+}
+
+func init() {
+	stackGraphCmd.Flags().BoolVar(
+		&stackGraphFlags.Serve, "serve", false,
+		"serve a web page that renders the graph and refreshes itself",
+	)
+	stackGraphCmd.Flags().StringVar(
+		&stackGraphFlags.Addr, "addr", "localhost:0",
+		"the address to serve the page on (port 0 picks a free port)",
+	)
+	stackGraphCmd.Flags().BoolVar(
+		&stackGraphFlags.Open, "open", false,
+		"open the page in the browser",
+	)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var stackImportFlags struct {
+	PatchDir string
+}
+
+var stackImportCmd = &cobra.Command{
+	Use:   "import --patch-dir <dir>",
+	Short: "Import a stack exported by av stack export",
+	Long: strings.TrimSpace(`
+Import a stack exported by av stack export.
+
+The branches in the manifest are created in order, and the patches of each
+branch are applied on top of its parent branch with git am. The root branch of
+the stack is based on the trunk commit recorded in the manifest, or on the
+current trunk if the commit doesn't exist in this repository.
+
+The branches must not exist yet, and the working tree must be clean. If a patch
+cannot be applied, the branches created by the import are deleted.
+`),
+	SilenceUsage: true,
+	Args:         cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) (reterr error) {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(filepath.Join(stackImportFlags.PatchDir, stackManifestFileName))
+		if err != nil {
+			return errors.WrapIf(err, "failed to read the manifest")
+		}
+		var manifest stackManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return errors.WrapIf(err, "failed to parse the manifest")
+		}
+		if manifest.Version != 1 {
+			return errors.Errorf("unsupported manifest version %d", manifest.Version)
+		}
+		for _, branch := range manifest.Branches {
+			if !isManifestDirName(branch.Dir) {
+				return errors.Errorf(
+					"invalid directory %q of branch %q (it must be a directory in the patch directory)",
+					branch.Dir, branch.Name,
+				)
+			}
+		}
+
+		status, err := repo.Status()
+		if err != nil {
+			return err
+		}
+		if !status.IsCleanIgnoringUntracked() {
+			return errutils.WithFailureClass(
+				errors.New("the working tree has uncommitted changes"),
+				errutils.FailureDirtyWorktree,
+			)
+		}
+		for _, branch := range manifest.Branches {
+			if exists, err := repo.DoesBranchExist(branch.Name); err != nil {
+				return err
+			} else if exists {
+				return errors.Errorf("branch %q already exists", branch.Name)
+			}
+		}
+
+		base := manifest.Base
+		if _, err := repo.Git("cat-file", "-e", base+"^{commit}"); err != nil {
+			fmt.Fprint(os.Stderr,
+				colors.Warning("The base commit of the stack doesn't exist in this repository. "),
+				colors.Warning("Importing onto "), colors.UserInput(manifest.Trunk),
+				colors.Warning(" instead.\n"),
+			)
+			base = manifest.Trunk
+		}
+
+		rb, err := newBranchRollback(repo)
+		if err != nil {
+			return err
+		}
+		tx := db.WriteTx()
+		cu := cleanup.New(func() {
+			logrus.WithError(reterr).Debug("aborting db transaction")
+			tx.Abort()
+			rb.rollback()
+		})
+		defer cu.Cleanup()
+
+		for _, branch := range manifest.Branches {
+			parentState := meta.BranchState{Name: branch.Parent}
+			startPoint := base
+			if branch.Parent == manifest.Trunk {
+				parentState.Trunk = true
+			} else {
+				startPoint = branch.Parent
+				parentState.Head, err = repo.RevParse(&git.RevParse{Rev: branch.Parent})
+				if err != nil {
+					return errors.WrapIff(err, "parent branch %q of %q doesn't exist", branch.Parent, branch.Name)
+				}
+			}
+			if _, err := repo.CheckoutBranch(&git.CheckoutBranch{
+				Name:       branch.Name,
+				NewBranch:  true,
+				NewHeadRef: startPoint,
+			}); err != nil {
+				return err
+			}
+			rb.add(branch.Name)
+			if err := actions.ConfigureNewBranch(repo, branch.Name); err != nil {
+				return err
+			}
+
+			patches, err := filepath.Glob(filepath.Join(stackImportFlags.PatchDir, branch.Dir, "*.patch"))
+			if err != nil {
+				return err
+			}
+			if len(patches) != branch.Commits {
+				return errors.Errorf(
+					"expected %d patch(es) for branch %q but found %d",
+					branch.Commits, branch.Name, len(patches),
+				)
+			}
+			sort.Strings(patches)
+			if len(patches) > 0 {
+				if _, err := repo.Run(&git.RunOpts{
+					Args:      append([]string{"am", "--3way", "--quiet"}, patches...),
+					ExitError: true,
+				}); err != nil {
+					return errors.WrapIff(err, "failed to apply the patches of branch %q", branch.Name)
+				}
+			}
+			tx.SetBranch(meta.Branch{Name: branch.Name, Parent: parentState})
+			fmt.Fprint(os.Stderr,
+				"  - imported ", colors.UserInput(len(patches)), " commit(s) to branch ",
+				colors.UserInput(branch.Name), "\n",
+			)
+		}
+
+		cu.Cancel()
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Imported the stack. Run "), colors.CliCmd("av tree"),
+			colors.Success(" to see it.\n"),
+		)
+		return nil
+	},
+}
+
+// isManifestDirName returns true if the directory name of a branch in the manifest is a single
+// path component, so that the patches are never read from outside of the patch directory.
+func isManifestDirName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+func init() {
+	stackImportCmd.Flags().StringVar(
+		&stackImportFlags.PatchDir, "patch-dir", "",
+		"the directory that contains the patches and the manifest exported by av stack export",
+	)
+	_ = stackImportCmd.MarkFlagRequired("patch-dir")
+	_ = stackImportCmd.MarkFlagDirname("patch-dir")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/executils"
+	"github.com/spf13/cobra"
+)
+
+// cascadeLocalRef is the ref that the result of av stack merge --cascade-local is written to.
+const cascadeLocalRef = "refs/av/cascade-local"
+
+var stackMergeFlags struct {
+	CascadeLocal bool
+	MergeMethod  string
+}
+
+var stackMergeCmd = &cobra.Command{
+	Use:   "merge --cascade-local [flags] [-- <command> [args...]]",
+	Short: "Simulate merging the stack locally and test the result",
+	Long: `Simulate merging the stack locally and test the result.
+
+With --cascade-local, the branches of the current stack are merged one by one from the bottom
+into a temporary integration branch started from the trunk, with the merge method of
+--merge-method (or the pullRequest.mergeMethod config), as their pull requests would be merged
+after each other. Nothing is merged on GitHub, and the branches are not changed.
+
+The result is written to refs/av/cascade-local. If a command is given (or the stack.testCommand
+config is set), it's executed on the result in a temporary worktree to verify that the whole
+stack integrates cleanly before any pull request is merged.
+
+Examples:
+  Check that the stack merges cleanly:
+    $ av stack merge --cascade-local
+
+  Run the tests on the squash-merged stack:
+    $ av stack merge --cascade-local --merge-method squash -- make test
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !stackMergeFlags.CascadeLocal {
+			return errors.New(
+				"only --cascade-local is supported (merge the pull requests on GitHub, e.g., with av pr --auto-merge)",
+			)
+		}
+		method := config.Av.PullRequest.MergeMethod
+		if stackMergeFlags.MergeMethod != "" {
+			if err := config.ValidateMergeMethod(stackMergeFlags.MergeMethod); err != nil {
+				return errors.WrapIf(err, "invalid --merge-method")
+			}
+			method = stackMergeFlags.MergeMethod
+		}
+
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		branches, err := meta.StackBranches(tx, currentBranch)
+		if err != nil {
+			return err
+		}
+		trunk, _ := meta.Trunk(tx, currentBranch)
+		onto, err := cascadeLocalTrunkCommit(repo, trunk)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprint(os.Stderr,
+			"Merging ", colors.UserInput(len(branches)), " branches onto ", colors.UserInput(trunk),
+			" (", method, "):\n",
+		)
+		steps, err := actions.CascadeMergeLocal(repo, tx, onto, branches, method)
+		if err != nil {
+			return err
+		}
+		for _, step := range steps {
+			if len(step.ConflictedFiles) > 0 {
+				fmt.Fprint(os.Stderr,
+					"  ", colors.Failure("✗ "), colors.UserInput(step.Branch),
+					colors.Failure(" conflicts in:"), "\n",
+				)
+				for _, file := range step.ConflictedFiles {
+					fmt.Fprint(os.Stderr, "      ", file, "\n")
+				}
+				fmt.Fprint(os.Stderr,
+					"Run ", colors.CliCmd("av sync"),
+					" to restack the stack onto the latest trunk and resolve the conflicts.\n",
+				)
+				return actions.ErrExitSilently{ExitCode: 1}
+			}
+			fmt.Fprint(os.Stderr,
+				"  ", colors.Success("✓ "), colors.UserInput(step.Branch),
+				colors.Faint(fmt.Sprintf(" (%d commit(s))", step.Commits)), "\n",
+			)
+		}
+		result := onto
+		if len(steps) > 0 {
+			result = steps[len(steps)-1].Commit
+		}
+		if err := repo.UpdateRef(&git.UpdateRef{Ref: cascadeLocalRef, New: result}); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			"The stack merges cleanly. The result is ", colors.UserInput(git.ShortSha(result)),
+			" (", cascadeLocalRef, ").\n",
+		)
+
+		var testCmd *exec.Cmd
+		if len(args) > 0 {
+			testCmd = exec.Command(args[0], args[1:]...)
+		} else if config.Av.Stack.TestCommand != "" {
+			testCmd = exec.Command("sh", "-c", config.Av.Stack.TestCommand)
+		} else {
+			return nil
+		}
+		return runCascadeLocalTest(repo, result, testCmd)
+	},
+}
+
+// cascadeLocalTrunkCommit returns the commit of the trunk that the stack is merged onto: the
+// remote trunk branch if it exists (as the pull requests are merged there), or the local one.
+func cascadeLocalTrunkCommit(repo *git.Repo, trunk string) (string, error) {
+	ref := repo.RemoteBranchRef(trunk)
+	if exists, _ := repo.DoesRefExist(ref); !exists {
+		ref = "refs/heads/" + trunk
+	}
+	commit, err := repo.RevParse(&git.RevParse{Rev: ref})
+	if err != nil {
+		return "", errors.WrapIff(err, "failed to read the trunk branch %q", trunk)
+	}
+	return commit, nil
+}
+
+// runCascadeLocalTest runs the test command on the commit in a temporary worktree, which is
+// removed afterward.
+func runCascadeLocalTest(repo *git.Repo, commit string, testCmd *exec.Cmd) error {
+	dir, err := os.MkdirTemp(repo.AvTmpDir(), "cascade-local-")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := repo.Git("worktree", "add", "--detach", dir, commit); err != nil {
+		_ = os.RemoveAll(dir)
+		return errors.WrapIf(err, "failed to create the worktree to test")
+	}
+	defer func() {
+		if _, err := repo.Git("worktree", "remove", "--force", dir); err != nil {
+			fmt.Fprint(os.Stderr, colors.Warning("Failed to remove the worktree "+dir+": "+err.Error()+"\n"))
+		}
+	}()
+
+	fmt.Fprint(os.Stderr,
+		"Running ", colors.CliCmd(executils.FormatCommandLine(testCmd.Args)),
+		" on the merged stack:\n",
+	)
+	testCmd.Dir = dir
+	testCmd.Stdout = os.Stdout
+	testCmd.Stderr = os.Stderr
+	if err := testCmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return errors.WrapIf(err, "failed to run the test command")
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Failure("The test command failed on the merged stack: "+err.Error()), "\n",
+		)
+		return actions.ErrExitSilently{ExitCode: exitErr.ExitCode()}
+	}
+	fmt.Fprint(os.Stderr, colors.Success("The test command passed on the merged stack.\n"))
+	return nil
+}
+
+func init() {
+	stackMergeCmd.Flags().BoolVar(
+		&stackMergeFlags.CascadeLocal, "cascade-local", false,
+		"merge the stack bottom-up into a local integration branch and test the result",
+	)
+	stackMergeCmd.Flags().StringVar(
+		&stackMergeFlags.MergeMethod, "merge-method", "",
+		"the merge method: merge, squash, or rebase (default: the pullRequest.mergeMethod\nconfig, or squash)",
+	)
+	_ = stackMergeCmd.RegisterFlagCompletionFunc(
+		"merge-method",
+		cobra.FixedCompletions(
+			[]string{config.MergeMethodMerge, config.MergeMethodSquash, config.MergeMethodRebase},
+			cobra.ShellCompDirectiveNoFileComp,
+		),
+	)
+}
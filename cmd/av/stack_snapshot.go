@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/snapshot"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/spf13/cobra"
+)
+
+var stackSnapshotSaveFlags struct {
+	Force bool
+}
+
+var stackSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and restore named snapshots of the current stack",
+	Long: strings.TrimSpace(`
+Save and restore named snapshots of the current stack.
+
+A snapshot records the commit of every branch in the stack and their av
+metadata. Save one before an experimental reorder or split, and restore it to
+roll the whole stack back if you don't like the result.
+
+The snapshots are stored as Git refs under refs/av/snapshots/, so the recorded
+commits are kept by git gc even after the branches are rewritten.
+`),
+}
+
+var stackSnapshotSaveCmd = &cobra.Command{
+	Use:          "save <name> [--force]",
+	Short:        "Save a snapshot of the current stack",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		currentBranch, err := repo.CurrentBranchName()
+		if err != nil {
+			return err
+		}
+		if _, ok := tx.Branch(currentBranch); !ok {
+			return actions.BranchNotAdoptedError{Branch: currentBranch}
+		}
+		names, err := meta.StackBranches(tx, currentBranch)
+		if err != nil {
+			return err
+		}
+		snap, err := snapshot.Save(repo, tx, args[0], names, stackSnapshotSaveFlags.Force)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Saved snapshot "), colors.UserInput(snap.Name),
+			colors.Success(fmt.Sprintf(" of %d branches.\n", len(snap.Branches))),
+			colors.Faint("  - run "), colors.CliCmd("av stack snapshot restore "+snap.Name),
+			colors.Faint(" to restore the stack\n"),
+		)
+		return nil
+	},
+}
+
+var stackSnapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Restore the stack to a snapshot",
+	Long: strings.TrimSpace(`
+Restore the stack to a snapshot: the branches of the snapshot are reset to the
+recorded commits (the deleted ones are re-created), and their av metadata is
+restored. The branches are reset atomically.
+
+The branches that were added to the stack after the snapshot (e.g., by av split)
+are kept in Git, but they are no longer tracked by av. Run av undo to undo the
+restore.
+`),
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: snapshotNameArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		snap, err := snapshot.Read(repo, args[0])
+		if err != nil {
+			return err
+		}
+		if _, inProgress := repo.InProgressStateFile(); inProgress {
+			return errors.New(
+				"an av operation is in progress; continue or abort it before restoring a snapshot",
+			)
+		}
+		status, err := repo.Status()
+		if err != nil {
+			return err
+		}
+		if !status.IsCleanIgnoringUntracked() {
+			return errutils.WithFailureClass(errors.New(
+				"the working tree has uncommitted changes; commit or stash them before restoring a snapshot",
+			), errutils.FailureDirtyWorktree)
+		}
+
+		if err := oplog.Record(repo, db.ReadTx(), "av stack snapshot restore"); err != nil {
+			return err
+		}
+		untracked, err := snapshot.Restore(repo, db, snap)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Restored snapshot "), colors.UserInput(snap.Name),
+			colors.Success(fmt.Sprintf(" of %d branches.\n", len(snap.Branches))),
+		)
+		for _, name := range untracked {
+			fmt.Fprint(os.Stderr,
+				"  - branch ", colors.UserInput(name), " is no longer tracked by av\n",
+			)
+		}
+		return nil
+	},
+}
+
+var stackSnapshotListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the snapshots",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		snaps, err := snapshot.List(repo)
+		if err != nil {
+			return err
+		}
+		if len(snaps) == 0 {
+			fmt.Fprint(os.Stderr, colors.Faint("No snapshots are saved.\n"))
+			return nil
+		}
+		for _, snap := range snaps {
+			fmt.Fprintf(os.Stdout, "%s\t%s\t%s\n",
+				snap.Name, snap.Time.Format("2006-01-02 15:04:05"),
+				strings.Join(snap.BranchNames(), ", "),
+			)
+		}
+		return nil
+	},
+}
+
+var stackSnapshotDeleteCmd = &cobra.Command{
+	Use:               "delete <name>",
+	Short:             "Delete a snapshot",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: snapshotNameArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		if err := snapshot.Delete(repo, args[0]); err != nil {
+			return err
+		}
+		fmt.Fprint(os.Stderr, colors.Success("Deleted snapshot "), colors.UserInput(args[0]), "\n")
+		return nil
+	},
+}
+
+func snapshotNameArgs(
+	_ *cobra.Command,
+	args []string,
+	_ string,
+) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	repo, err := getRepo()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	snaps, err := snapshot.List(repo)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, snap := range snaps {
+		names = append(names, snap.Name)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	stackSnapshotSaveCmd.Flags().BoolVar(
+		&stackSnapshotSaveFlags.Force, "force", false,
+		"replace the snapshot if it already exists",
+	)
+	stackSnapshotCmd.AddCommand(
+		stackSnapshotDeleteCmd,
+		stackSnapshotListCmd,
+		stackSnapshotRestoreCmd,
+		stackSnapshotSaveCmd,
+	)
+}
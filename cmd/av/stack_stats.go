@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/timeutils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var stackStatsCmd = &cobra.Command{
+	Use:   "stats [<branch>] [--json]",
+	Short: "Show the summary metrics of the current stack",
+	Long: strings.TrimSpace(`
+Show the summary metrics of the current stack (or the stack of the given branch),
+per branch and in total: the numbers of the commits, the changed files, and the
+added and deleted lines, the age of the oldest commit, the time since the last
+av sync, and the review latency of the pull requests.
+
+The commits and the changes of a branch are the ones that are not in its parent
+branch. The review latency is the time from the creation of the pull request to
+its first review (or until now if it's not reviewed yet), queried from GitHub.
+Use it to decide when a stack has grown too large. With --json, the metrics are
+printed in JSON for dashboards.
+`),
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: branchNameArgAt(0),
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		var branch string
+		if len(args) > 0 {
+			branch = args[0]
+		} else if branch, err = repo.CurrentBranchName(); err != nil {
+			return err
+		}
+		if _, ok := tx.Branch(branch); !ok {
+			return actions.BranchNotAdoptedError{Branch: branch}
+		}
+		names, err := meta.StackBranches(tx, branch)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		stats := collectStackStats(repo, tx, names, now)
+		if jsonOutput() {
+			return printJSON(stats)
+		}
+		printStackStats(stats, now)
+		return nil
+	},
+}
+
+type stackStatsCounts struct {
+	Commits      int `json:"commits"`
+	FilesChanged int `json:"filesChanged"`
+	Additions    int `json:"additions"`
+	Deletions    int `json:"deletions"`
+}
+
+type stackStatsBranch struct {
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+	stackStatsCounts
+	// The author date of the oldest commit of the branch.
+	OldestCommitAt *time.Time `json:"oldestCommitAt,omitempty"`
+	// When the branch was last synchronized by av sync (from the av operation log).
+	LastSyncedAt *time.Time             `json:"lastSyncedAt,omitempty"`
+	PullRequest  *stackStatsPullRequest `json:"pullRequest,omitempty"`
+}
+
+type stackStatsPullRequest struct {
+	Number        int64      `json:"number"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	FirstReviewAt *time.Time `json:"firstReviewAt,omitempty"`
+	// The seconds from the creation to the first review, or until now if it's not reviewed.
+	ReviewLatencySeconds int64 `json:"reviewLatencySeconds"`
+}
+
+type stackStatsTotal struct {
+	Branches int `json:"branches"`
+	// FilesChanged counts a file changed in multiple branches once.
+	stackStatsCounts
+	OldestCommitAt *time.Time `json:"oldestCommitAt,omitempty"`
+	// The least recent of the last syncs of the branches.
+	LastSyncedAt *time.Time `json:"lastSyncedAt,omitempty"`
+	// The longest review latency of the pull requests.
+	MaxReviewLatencySeconds int64 `json:"maxReviewLatencySeconds,omitempty"`
+}
+
+type stackStats struct {
+	Branches []stackStatsBranch `json:"branches"`
+	Total    stackStatsTotal    `json:"total"`
+}
+
+// collectStackStats computes the metrics of the branches. The metrics that can't be computed
+// (e.g., the review latency while offline) are left empty.
+func collectStackStats(repo *git.Repo, tx meta.ReadTx, names []string, now time.Time) stackStats {
+	lastSyncs := lastSyncTimes(repo)
+	reviews := pullRequestReviewStats(tx, names, now)
+
+	stats := stackStats{Branches: []stackStatsBranch{}}
+	files := map[string]bool{}
+	for _, name := range names {
+		br, _ := tx.Branch(name)
+		base := branchComparisonBase(repo, br)
+		out := stackStatsBranch{Name: name, Parent: br.Parent.Name, PullRequest: reviews[name]}
+		out.Commits, _ = branchAheadBehind(repo, br)
+		for file, counts := range branchDiffStat(repo, base, name) {
+			files[file] = true
+			out.FilesChanged++
+			out.Additions += counts[0]
+			out.Deletions += counts[1]
+		}
+		out.OldestCommitAt = oldestCommitTime(repo, base, name)
+		if t, ok := lastSyncs[name]; ok {
+			out.LastSyncedAt = &t
+		}
+		stats.Branches = append(stats.Branches, out)
+
+		total := &stats.Total
+		total.Branches++
+		total.Commits += out.Commits
+		total.Additions += out.Additions
+		total.Deletions += out.Deletions
+		if out.OldestCommitAt != nil &&
+			(total.OldestCommitAt == nil || out.OldestCommitAt.Before(*total.OldestCommitAt)) {
+			total.OldestCommitAt = out.OldestCommitAt
+		}
+		if out.LastSyncedAt != nil &&
+			(total.LastSyncedAt == nil || out.LastSyncedAt.Before(*total.LastSyncedAt)) {
+			total.LastSyncedAt = out.LastSyncedAt
+		}
+		if out.PullRequest != nil {
+			total.MaxReviewLatencySeconds = max(
+				total.MaxReviewLatencySeconds, out.PullRequest.ReviewLatencySeconds,
+			)
+		}
+	}
+	stats.Total.FilesChanged = len(files)
+	return stats
+}
+
+// branchDiffStat returns the numbers of the added and deleted lines of the files changed in the
+// branch since it diverged from the base. The binary files count as zero lines.
+func branchDiffStat(repo *git.Repo, base string, branch string) map[string][2]int {
+	out, err := repo.Run(&git.RunOpts{
+		Args:      []string{"diff", "--numstat", "--no-renames", "-z", base + "..." + branch, "--"},
+		ExitError: true,
+	})
+	if err != nil {
+		logrus.WithError(err).Debugf("failed to compute the diff of %q", branch)
+		return nil
+	}
+	ret := map[string][2]int{}
+	for _, entry := range strings.Split(string(out.Stdout), "\x00") {
+		fields := strings.SplitN(entry, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		added, _ := strconv.Atoi(fields[0])
+		deleted, _ := strconv.Atoi(fields[1])
+		ret[fields[2]] = [2]int{added, deleted}
+	}
+	return ret
+}
+
+// oldestCommitTime returns the author date of the oldest commit in the branch that is not in
+// the base, or nil if the branch has no commits.
+func oldestCommitTime(repo *git.Repo, base string, branch string) *time.Time {
+	out, err := repo.Git("log", "--format=%at", base+".."+branch, "--")
+	if err != nil || out == "" {
+		return nil
+	}
+	var oldest *time.Time
+	for _, line := range strings.Split(out, "\n") {
+		sec, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64)
+		if err != nil {
+			continue
+		}
+		if t := time.Unix(sec, 0); oldest == nil || t.Before(*oldest) {
+			oldest = &t
+		}
+	}
+	return oldest
+}
+
+// lastSyncTimes returns when each branch was last synchronized by av sync, from the av operation
+// log.
+func lastSyncTimes(repo *git.Repo) map[string]time.Time {
+	entries, err := oplog.Read(repo)
+	if err != nil {
+		logrus.WithError(err).Debug("failed to read the av operation log")
+		return nil
+	}
+	ret := map[string]time.Time{}
+	for _, entry := range entries {
+		if entry.Command != "av sync" {
+			continue
+		}
+		for name := range entry.Branches {
+			ret[name] = entry.Time
+		}
+	}
+	return ret
+}
+
+// pullRequestReviewStats queries when the pull requests of the branches were first reviewed.
+// It returns nothing if GitHub can't be reached (e.g., with --offline).
+func pullRequestReviewStats(
+	tx meta.ReadTx,
+	names []string,
+	now time.Time,
+) map[string]*stackStatsPullRequest {
+	var ids []string
+	branchByID := map[string]string{}
+	for _, name := range names {
+		br, _ := tx.Branch(name)
+		if br.PullRequest != nil && br.PullRequest.ID != "" {
+			ids = append(ids, br.PullRequest.ID)
+			branchByID[br.PullRequest.ID] = name
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	client, err := getGitHubClient()
+	if err != nil {
+		logrus.WithError(err).Debug("skipping the review latency")
+		return nil
+	}
+	activities, err := client.PullRequestsReviewActivity(context.Background(), ids)
+	if err != nil {
+		fmt.Fprint(os.Stderr, colors.Warning("Failed to query the reviews: "+err.Error()+"\n"))
+		return nil
+	}
+	ret := map[string]*stackStatsPullRequest{}
+	for _, a := range activities {
+		pr := &stackStatsPullRequest{
+			Number:        a.Number,
+			CreatedAt:     a.CreatedAt.Time,
+			FirstReviewAt: a.FirstReviewAt(),
+		}
+		end := now
+		if pr.FirstReviewAt != nil {
+			end = *pr.FirstReviewAt
+		}
+		pr.ReviewLatencySeconds = int64(end.Sub(pr.CreatedAt) / time.Second)
+		ret[branchByID[a.ID]] = pr
+	}
+	return ret
+}
+
+func printStackStats(stats stackStats, now time.Time) {
+	ago := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return timeutils.FormatShortDuration(now.Sub(*t)) + " ago"
+	}
+	fmt.Fprint(os.Stdout,
+		"Stack of ", colors.UserInput(stats.Branches[0].Name), " (",
+		colors.UserInput(stats.Total.Branches), " branches)\n\n",
+	)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BRANCH\tCOMMITS\tFILES\tLINES\tOLDEST COMMIT\tLAST SYNC\tREVIEW")
+	for _, br := range stats.Branches {
+		review := "-"
+		if pr := br.PullRequest; pr != nil {
+			latency := timeutils.FormatShortDuration(
+				time.Duration(pr.ReviewLatencySeconds) * time.Second,
+			)
+			if pr.FirstReviewAt != nil {
+				review = fmt.Sprintf("#%d reviewed in %s", pr.Number, latency)
+			} else {
+				review = fmt.Sprintf("#%d waiting for %s", pr.Number, latency)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t+%d -%d\t%s\t%s\t%s\n",
+			br.Name, br.Commits, br.FilesChanged, br.Additions, br.Deletions,
+			ago(br.OldestCommitAt), ago(br.LastSyncedAt), review,
+		)
+	}
+	total := stats.Total
+	review := "-"
+	if total.MaxReviewLatencySeconds > 0 {
+		review = "up to " + timeutils.FormatShortDuration(
+			time.Duration(total.MaxReviewLatencySeconds)*time.Second,
+		)
+	}
+	fmt.Fprintf(w, "total\t%d\t%d\t+%d -%d\t%s\t%s\t%s\n",
+		total.Commits, total.FilesChanged, total.Additions, total.Deletions,
+		ago(total.OldestCommitAt), ago(total.LastSyncedAt), review,
+	)
+	_ = w.Flush()
+}
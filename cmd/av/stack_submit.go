@@ -3,12 +3,22 @@ package main
 import (
 	"strings"
 
+	"emperror.dev/errors"
 	"github.com/spf13/cobra"
 )
 
 var stackSubmitFlags struct {
-	Current bool
-	Draft   bool
+	Range       submitRange
+	Draft       bool
+	Ready       bool
+	ReadyBelow  string
+	DryRun      bool
+	Open        bool
+	Stdin       bool
+	Since       string
+	AutoMerge   bool
+	MergeMethod string
+	Codeowners  bool
 }
 
 var stackSubmitCmd = &cobra.Command{
@@ -17,9 +27,60 @@ var stackSubmitCmd = &cobra.Command{
 	Long: strings.TrimSpace(`
 Create pull requests for every branch in the stack
 
-If the --current flag is given, this command will create pull requests up to the current branch.`),
+If the --current (or --downstack) flag is given, this command will create pull requests up to the
+current branch. With --upstack, the pull requests are created for the current branch and the
+branches above it, and with --only, for the current branch only. With --from and --to, they're
+created for the branches between the two (inclusive). The pull requests of the other branches are
+left untouched.
+
+If the --ready flag is given, the pull requests are ready for review even if they are drafts by
+default (see the pullRequest.draft config). If the --ready-below flag is given, the given branch
+and the branches below it are ready for review, and the others are drafts that become ready as
+their parents are merged by av sync.
+
+If the --open (or --web) flag is given, the submitted pull requests are opened in the browser.
+
+If the --since flag is given, only the branches changed since the given point are submitted: the
+last submit (last-submit), an operation (the number listed by av undo --list), a duration before
+now (e.g., 3d), a date, or a commit (the branches with commits not reachable from it).
+
+If the --auto-merge flag is given, auto-merge is enabled on the pull request of the branch based
+on the trunk with the merge method of --merge-method (merge, squash, or rebase). The pull requests
+above it get auto-merge from av sync once their parents are merged.
+
+If the --stdin flag is given, this command will create pull requests for the branches read from
+the standard input (one per line, e.g., the output of av query) instead of the current stack.`),
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) error {
-		return submitAll(stackSubmitFlags.Current, stackSubmitFlags.Draft)
+		autoMerge, err := autoMergeMethod(
+			cmd, stackSubmitFlags.AutoMerge, stackSubmitFlags.MergeMethod,
+		)
+		if err != nil {
+			return err
+		}
+		if err := stackSubmitFlags.Range.validate(); err != nil {
+			return err
+		}
+		var branches []string
+		if stackSubmitFlags.Stdin {
+			if stackSubmitFlags.Range.partial() {
+				return errors.New("cannot use --stdin with --current, --only, --downstack, --upstack, --from, or --to")
+			}
+			branches, err = readStdinBranches()
+			if err != nil {
+				return err
+			}
+		}
+		return submitAll(
+			stackSubmitFlags.Range,
+			prDraftOpts{
+				Draft:      stackSubmitFlags.Draft,
+				Ready:      stackSubmitFlags.Ready,
+				ReadyBelow: stackSubmitFlags.ReadyBelow,
+			},
+			stackSubmitFlags.DryRun, stackSubmitFlags.Open,
+			branches, stackSubmitFlags.Since, autoMerge,
+			pullRequestTriage(nil, nil, nil, stackSubmitFlags.Codeowners),
+		)
 	},
 }
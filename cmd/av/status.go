@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/spf13/cobra"
+)
+
+var statusFlags struct {
+	Porcelain bool
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status [--porcelain]",
+	Short: "Show the position of the current branch in its stack (for shell prompts)",
+	Long: strings.TrimSpace(`
+Show the current branch, its position in the stack, whether it needs a restack,
+and the numbers of the changed files and the commits ahead of and behind its
+parent branch.
+
+This command only reads the local repository (no network access), so that it's
+fast enough to be run from a shell prompt. With --porcelain, the status is
+printed in a stable format that is easy to parse.
+`),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Shell prompts run this command all the time.
+		skipCliVersionCheck = true
+
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		st, err := readStatus(repo)
+		if err != nil {
+			return err
+		}
+		switch {
+		case jsonOutput():
+			return printJSON(st)
+		case statusFlags.Porcelain:
+			fmt.Print(st.porcelain())
+		default:
+			fmt.Println(st.String())
+		}
+		return nil
+	},
+}
+
+// statusOutput is the output of av status.
+type statusOutput struct {
+	// The current branch. Empty if HEAD is detached.
+	Branch string `json:"branch"`
+	// The parent branch. Empty if the branch is not tracked by av.
+	Parent        string `json:"parent"`
+	ParentIsTrunk bool   `json:"parentIsTrunk"`
+	// The position of the branch in the stack (1 is the branch on the trunk). Zero if the
+	// branch is not tracked by av.
+	Depth int `json:"depth"`
+	// The number of the branches in the stack.
+	StackSize int `json:"stackSize"`
+	// True if the parent branch has commits that the branch doesn't have.
+	NeedsRestack bool `json:"needsRestack"`
+	Staged       int  `json:"staged"`
+	Unstaged     int  `json:"unstaged"`
+	Untracked    int  `json:"untracked"`
+	Conflicted   int  `json:"conflicted"`
+	// The numbers of the commits ahead of and behind the parent branch.
+	Ahead  int `json:"ahead"`
+	Behind int `json:"behind"`
+}
+
+// readStatus reads the status of the current branch with as few git commands as possible.
+func readStatus(repo *git.Repo) (*statusOutput, error) {
+	gitStatus, err := repo.Status()
+	if err != nil {
+		return nil, err
+	}
+	st := &statusOutput{
+		Branch:     gitStatus.CurrentBranch,
+		Staged:     len(gitStatus.StagedTrackedFiles),
+		Unstaged:   len(gitStatus.UnstagedTrackedFiles),
+		Untracked:  len(gitStatus.UntrackedFiles),
+		Conflicted: len(gitStatus.UnmergedFiles),
+	}
+	if st.Branch == "" {
+		return st, nil
+	}
+	db, err := getDB(repo)
+	if err != nil {
+		// Not initialized for av. The branch is just not in a stack.
+		return st, nil
+	}
+	tx := db.ReadTx()
+	br, ok := tx.Branch(st.Branch)
+	if !ok || br.Parent.Name == "" {
+		return st, nil
+	}
+	previous, err := meta.PreviousBranches(tx, st.Branch)
+	if err != nil {
+		return nil, err
+	}
+	stack, err := meta.StackBranches(tx, st.Branch)
+	if err != nil {
+		return nil, err
+	}
+	st.Parent = br.Parent.Name
+	st.ParentIsTrunk = br.Parent.Trunk
+	st.Depth = len(previous) + 1
+	st.StackSize = len(stack)
+	st.Ahead, st.Behind = statusAheadBehind(repo, br)
+	st.NeedsRestack = !br.Parent.Trunk && st.Behind > 0
+	return st, nil
+}
+
+// statusAheadBehind is like branchAheadBehind, but it doesn't read all the refs of the
+// repository.
+func statusAheadBehind(repo *git.Repo, br meta.Branch) (ahead int, behind int) {
+	parents := []string{br.Parent.Name}
+	if br.Parent.Trunk && br.FrozenBase != "" {
+		parents = []string{br.FrozenBase}
+	} else if br.Parent.Trunk {
+		parents = []string{repo.RemoteBranchRef(br.Parent.Name), br.Parent.Name}
+	}
+	for _, parent := range parents {
+		out, err := repo.Git("rev-list", "--left-right", "--count", parent+"..."+br.Name, "--")
+		if err != nil {
+			continue
+		}
+		left, right, _ := strings.Cut(out, "\t")
+		behind, _ = strconv.Atoi(left)
+		ahead, _ = strconv.Atoi(right)
+		return ahead, behind
+	}
+	return 0, 0
+}
+
+// porcelain returns the status in the format of av status --porcelain: one "<key> <value>"
+// per line. New keys may be added in the future, so the unknown keys should be ignored.
+func (st *statusOutput) porcelain() string {
+	branch := st.Branch
+	if branch == "" {
+		branch = "(detached)"
+	}
+	lines := [][2]string{
+		{"branch", branch},
+		{"parent", st.Parent},
+		{"parent-is-trunk", strconv.FormatBool(st.ParentIsTrunk)},
+		{"depth", strconv.Itoa(st.Depth)},
+		{"stack-size", strconv.Itoa(st.StackSize)},
+		{"needs-restack", strconv.FormatBool(st.NeedsRestack)},
+		{"staged", strconv.Itoa(st.Staged)},
+		{"unstaged", strconv.Itoa(st.Unstaged)},
+		{"untracked", strconv.Itoa(st.Untracked)},
+		{"conflicted", strconv.Itoa(st.Conflicted)},
+		{"ahead", strconv.Itoa(st.Ahead)},
+		{"behind", strconv.Itoa(st.Behind)},
+	}
+	sb := strings.Builder{}
+	for _, line := range lines {
+		sb.WriteString(line[0])
+		sb.WriteString(" ")
+		sb.WriteString(line[1])
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// String returns the short status for the prompts, e.g., "feature 2/3 ↑1 ↓2 restack +1 ~2 ?3".
+func (st *statusOutput) String() string {
+	parts := []string{st.Branch}
+	if st.Branch == "" {
+		parts = []string{"(detached)"}
+	}
+	if st.Depth > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d", st.Depth, st.StackSize))
+		if st.Ahead > 0 {
+			parts = append(parts, fmt.Sprintf("↑%d", st.Ahead))
+		}
+		if st.Behind > 0 {
+			parts = append(parts, fmt.Sprintf("↓%d", st.Behind))
+		}
+		if st.NeedsRestack {
+			parts = append(parts, "restack")
+		}
+	}
+	for _, count := range []struct {
+		sign string
+		n    int
+	}{{"!", st.Conflicted}, {"+", st.Staged}, {"~", st.Unstaged}, {"?", st.Untracked}} {
+		if count.n > 0 {
+			parts = append(parts, count.sign+strconv.Itoa(count.n))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func init() {
+	statusCmd.Flags().BoolVar(
+		&statusFlags.Porcelain, "porcelain", false,
+		"print the status in a stable, machine-readable format",
+	)
+}
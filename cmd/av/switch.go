@@ -5,6 +5,8 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -14,8 +16,10 @@ import (
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/colors"
 	"github.com/aviator-co/av/internal/utils/stackutils"
+	"github.com/aviator-co/av/internal/utils/stringutils"
 	"github.com/aviator-co/av/internal/utils/uiutils"
 	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -23,11 +27,33 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var switchFlags struct {
+	// If set, create a new branch with this name (like `git switch -c`) and switch to it.
+	Create string
+	// If true, create the branch even if it exists on the remote or the stack gets deeper than
+	// the stack depth limit.
+	Force bool
+}
+
 var switchCmd = &cobra.Command{
-	Use:               "switch [<branch> | <url>]",
-	Short:             "Interactively switch to a different branch",
+	Use:   "switch [<branch> | <url> | <query> | -c <new-branch> [<parent-branch>]]",
+	Short: "Interactively switch to a different branch",
+	Long: strings.TrimSpace(`
+Interactively switch to a different branch.
+
+If a branch or a pull request URL is given, switch to that branch. If the given
+name is not a branch, it's used as a fuzzy query over the branches that av
+tracks, and av switches to the branch if exactly one matches.
+
+Otherwise, the branches are shown interactively. Type / to fuzzy-find a
+branch. With --json, the branches are listed in JSON (see av tree --json)
+instead.
+
+If the -c/--create flag is given, create a new branch stacked on the current
+branch (or on <parent-branch> if given) and switch to it. This is the same as
+av branch <new-branch> [<parent-branch>], mirroring git switch -c.`),
 	Args:              cobra.MaximumNArgs(1),
-	ValidArgsFunction: branchNameArgs,
+	ValidArgsFunction: branchNameArgAt(0),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		repo, err := getRepo()
 		if err != nil {
@@ -39,6 +65,20 @@ var switchCmd = &cobra.Command{
 			return err
 		}
 
+		if switchFlags.Create != "" {
+			var parent string
+			if len(args) > 0 {
+				parent = args[0]
+			}
+			branchName := applyBranchNamespace(switchFlags.Create)
+			if !switchFlags.Force {
+				if err := checkRemoteBranchCollision(repo, branchName); err != nil {
+					return err
+				}
+			}
+			return createBranch(repo, db, branchName, parent, switchFlags.Force)
+		}
+
 		status, err := repo.Status()
 		if err != nil {
 			return err
@@ -52,6 +92,10 @@ var switchCmd = &cobra.Command{
 			if err != nil {
 				return err
 			}
+			branch, err = resolveSwitchQuery(repo, tx, branch)
+			if err != nil {
+				return err
+			}
 			if _, err := repo.CheckoutBranch(&git.CheckoutBranch{Name: branch}); err != nil {
 				return err
 			}
@@ -67,7 +111,21 @@ var switchCmd = &cobra.Command{
 		if len(branchList) == 0 {
 			return errors.New("no branches found")
 		}
+		if jsonOutput() {
+			// List the branches instead of choosing one interactively (without the trunk
+			// branches).
+			var names []string
+			for _, branch := range branchList {
+				if _, ok := tx.Branch(branch.BranchName); ok {
+					names = append(names, branch.BranchName)
+				}
+			}
+			return printJSON(newBranchOutputs(repo, tx, currentBranch, names))
+		}
 
+		if err := uiutils.RequireInteractive("choosing the branch", "give the branch name"); err != nil {
+			return err
+		}
 		if !isatty.IsTerminal(os.Stdout.Fd()) {
 			return errors.New("switch command must be run in a terminal")
 		}
@@ -79,6 +137,7 @@ var switchCmd = &cobra.Command{
 			rootNodes:           rootNodes,
 			branchList:          branchList,
 			branches:            branches,
+			previews:            switchBranchPreviews(tx, branchList),
 			spinner:             spinner.New(spinner.WithSpinner(spinner.Dot)),
 		})
 	},
@@ -112,6 +171,63 @@ func switchBranchList(
 	return ret
 }
 
+// resolveSwitchQuery returns the branch to switch to for the given name. If there's no such
+// branch, the name is used as a fuzzy query over the av-tracked branches. If nothing matches,
+// the name is returned as is so that git switch can still guess a remote branch.
+func resolveSwitchQuery(repo *git.Repo, tx meta.ReadTx, name string) (string, error) {
+	exists, err := repo.DoesBranchExist(name)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return name, nil
+	}
+	var candidates []string
+	for branch := range tx.AllBranches() {
+		candidates = append(candidates, branch)
+	}
+	sort.Strings(candidates)
+	matches := stringutils.FuzzyFilter(name, candidates)
+	switch len(matches) {
+	case 0:
+		return name, nil
+	case 1:
+		return matches[0], nil
+	}
+	return "", errors.Errorf(
+		"%q matches multiple branches: %s",
+		name, strings.Join(matches, ", "),
+	)
+}
+
+// switchBranchPreviews returns the preview of each branch: its stack context (parent → branch
+// → children) and its pull request state.
+func switchBranchPreviews(tx meta.ReadTx, branchList []*stackTreeBranchInfo) map[string]string {
+	ret := map[string]string{}
+	for _, stbi := range branchList {
+		branch, ok := tx.Branch(stbi.BranchName)
+		if !ok {
+			// The trunk branch.
+			continue
+		}
+		stack := []string{branch.Parent.Name, colors.UserInput(branch.Name)}
+		if children := meta.ChildrenNames(tx, branch.Name); len(children) > 0 {
+			stack = append(stack, strings.Join(children, ", "))
+		}
+		lines := []string{strings.Join(stack, " → ")}
+		if pr := branch.PullRequest; pr != nil {
+			lines = append(lines, fmt.Sprintf("#%d %s %s", pr.Number, pr.State, pr.Permalink))
+			if pr.Title != "" {
+				lines = append(lines, pr.Title)
+			}
+		} else {
+			lines = append(lines, "No pull request")
+		}
+		ret[branch.Name] = strings.Join(lines, "\n")
+	}
+	return ret
+}
+
 func parseBranchName(tx meta.ReadTx, input string) (string, error) {
 	if branch, err := parsePullRequestURL(tx, input); err == nil {
 		return branch, nil
@@ -165,6 +281,38 @@ type switchViewModel struct {
 	rootNodes         []*stackutils.StackTreeNode
 	branchList        []*stackTreeBranchInfo
 	branches          map[string]*stackTreeBranchInfo
+	previews          map[string]string
+
+	// True while the fuzzy-find query is being typed.
+	filtering bool
+	query     string
+}
+
+// visibleBranches returns the branches that match the fuzzy-find query (best matches first),
+// or all the branches if there's no query.
+func (vm switchViewModel) visibleBranches() []*stackTreeBranchInfo {
+	if vm.query == "" {
+		return vm.branchList
+	}
+	var names []string
+	for _, branch := range vm.branchList {
+		names = append(names, branch.BranchName)
+	}
+	var ret []*stackTreeBranchInfo
+	for _, name := range stringutils.FuzzyFilter(vm.query, names) {
+		ret = append(ret, vm.branches[name])
+	}
+	return ret
+}
+
+func (vm switchViewModel) updateQuery(query string) switchViewModel {
+	vm.query = query
+	if visible := vm.visibleBranches(); len(visible) > 0 {
+		vm.currentChosenBranch = visible[0].BranchName
+	} else {
+		vm.currentChosenBranch = ""
+	}
+	return vm
 }
 
 func (vm switchViewModel) Init() tea.Cmd {
@@ -183,6 +331,27 @@ func (vm switchViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		vm.checkedOut = true
 		return vm, tea.Quit
 	case tea.KeyMsg:
+		if vm.filtering && !vm.checkingOut && !vm.checkedOut {
+			switch msg.Type {
+			case tea.KeyRunes:
+				return vm.updateQuery(vm.query + string(msg.Runes)), nil
+			case tea.KeySpace:
+				// Branch names cannot contain spaces.
+				return vm, nil
+			case tea.KeyBackspace:
+				if vm.query == "" {
+					vm.filtering = false
+					return vm, nil
+				}
+				runes := []rune(vm.query)
+				return vm.updateQuery(string(runes[:len(runes)-1])), nil
+			case tea.KeyEsc:
+				vm.filtering = false
+				vm = vm.updateQuery("")
+				vm.currentChosenBranch = getInitialChosenBranch(vm.branchList, vm.currentHEADBranch)
+				return vm, nil
+			}
+		}
 		if !vm.checkingOut && !vm.checkedOut {
 			switch msg.String() {
 			case "ctrl+c":
@@ -191,7 +360,12 @@ func (vm switchViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				vm.currentChosenBranch = vm.getPreviousBranch()
 			case "down", "j", "ctrl+n":
 				vm.currentChosenBranch = vm.getNextBranch()
+			case "/":
+				vm.filtering = true
 			case "enter", " ":
+				if vm.currentChosenBranch == "" {
+					return vm, nil
+				}
 				vm.checkingOut = true
 				return vm, vm.checkoutBranch
 			}
@@ -219,24 +393,26 @@ func (vm switchViewModel) checkoutBranch() tea.Msg {
 }
 
 func (vm switchViewModel) getPreviousBranch() string {
-	for i, branch := range vm.branchList {
+	branchList := vm.visibleBranches()
+	for i, branch := range branchList {
 		if branch.BranchName == vm.currentChosenBranch {
 			if i == 0 {
 				return vm.currentChosenBranch
 			}
-			return vm.branchList[i-1].BranchName
+			return branchList[i-1].BranchName
 		}
 	}
 	return vm.currentChosenBranch
 }
 
 func (vm switchViewModel) getNextBranch() string {
-	for i, branch := range vm.branchList {
+	branchList := vm.visibleBranches()
+	for i, branch := range branchList {
 		if branch.BranchName == vm.currentChosenBranch {
-			if i == len(vm.branchList)-1 {
+			if i == len(branchList)-1 {
 				return vm.currentChosenBranch
 			}
-			return vm.branchList[i+1].BranchName
+			return branchList[i+1].BranchName
 		}
 	}
 	return vm.currentChosenBranch
@@ -254,32 +430,64 @@ func (vm switchViewModel) View() string {
 	} else {
 		ss = append(ss, colors.QuestionStyle.Render("Choose which branch to check out"))
 	}
+	if vm.filtering {
+		ss = append(ss, "/"+vm.query)
+	}
 	ss = append(ss, "")
-	for _, node := range vm.rootNodes {
+	if vm.query == "" {
+		for _, node := range vm.rootNodes {
+			ss = append(
+				ss,
+				stackutils.RenderTree(node, func(branchName string, isTrunk bool) string {
+					stbi := vm.branches[branchName]
+					out := vm.renderBranchInfo(
+						stbi,
+						vm.currentHEADBranch,
+						branchName,
+						isTrunk,
+					)
+					if branchName == vm.currentChosenBranch {
+						out = colors.PromptChoice.Render(out)
+					}
+					return out
+				}),
+			)
+		}
+	} else {
+		visible := vm.visibleBranches()
+		if len(visible) == 0 {
+			ss = append(ss, "No matching branches")
+		}
+		for _, stbi := range visible {
+			out := stbi.BranchName
+			if stbi.BranchName == vm.currentHEADBranch {
+				out += " (HEAD)"
+			}
+			if stbi.BranchName == vm.currentChosenBranch {
+				out = colors.PromptChoice.Render("* " + out)
+			} else {
+				out = "  " + out
+			}
+			ss = append(ss, out)
+		}
+	}
+	ss = append(ss, "")
+	if preview := vm.previews[vm.currentChosenBranch]; preview != "" && !vm.checkedOut {
 		ss = append(
 			ss,
-			stackutils.RenderTree(node, func(branchName string, isTrunk bool) string {
-				stbi := vm.branches[branchName]
-				out := vm.renderBranchInfo(
-					stbi,
-					vm.currentHEADBranch,
-					branchName,
-					isTrunk,
-				)
-				if branchName == vm.currentChosenBranch {
-					out = colors.PromptChoice.Render(out)
-				}
-				return out
-			}),
+			lipgloss.NewStyle().
+				Border(lipgloss.RoundedBorder()).
+				Padding(0, 1).
+				Render(preview),
+			"",
 		)
 	}
-	ss = append(ss, "")
 	if vm.checkingOut {
 		ss = append(ss, "Checking out branch "+vm.currentChosenBranch+"...")
 	} else if vm.checkedOut {
 		ss = append(ss, "Checked out branch "+vm.currentChosenBranch)
 	} else {
-		ss = append(ss, vm.help.ShortHelpView(uiutils.PromptKeys))
+		ss = append(ss, vm.help.ShortHelpView(switchKeys))
 	}
 
 	var ret string
@@ -321,9 +529,25 @@ func (switchViewModel) renderBranchInfo(
 	return strings.Join(ss, "\n")
 }
 
+var switchKeys = append(slices.Clone(uiutils.PromptKeys), key.NewBinding(
+	key.WithKeys("/"),
+	key.WithHelp("/", "fuzzy find"),
+))
+
 func (vm switchViewModel) ExitError() error {
 	if vm.err != nil {
 		return actions.ErrExitSilently{ExitCode: 1}
 	}
 	return nil
 }
+
+func init() {
+	switchCmd.Flags().StringVarP(
+		&switchFlags.Create, "create", "c", "",
+		"create a new branch stacked on the current branch (or the given parent branch) and switch to it",
+	)
+	switchCmd.Flags().BoolVar(
+		&switchFlags.Force, "force", false,
+		"with --create, create the branch even if it exists on the remote or the stack gets deeper than the stack depth limit",
+	)
+}
@@ -1,20 +1,27 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"strings"
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
 	"github.com/aviator-co/av/internal/config"
-	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/forge"
 	"github.com/aviator-co/av/internal/gh/ghui"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/git/gitui"
+	"github.com/aviator-co/av/internal/hooks"
 	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/meta/metasync"
+	"github.com/aviator-co/av/internal/oplog"
 	"github.com/aviator-co/av/internal/sequencer"
 	"github.com/aviator-co/av/internal/sequencer/planner"
 	"github.com/aviator-co/av/internal/sequencer/sequencerui"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/progress"
 	"github.com/aviator-co/av/internal/utils/sliceutils"
 	"github.com/aviator-co/av/internal/utils/uiutils"
 	"github.com/charmbracelet/bubbles/help"
@@ -35,6 +42,12 @@ var syncFlags struct {
 	Skip          bool
 	Push          string
 	Prune         string
+	PruneAll      string
+	Stdin         bool
+	Explain       bool
+	DryRun        bool
+	Autostash     bool
+	Summary       bool
 }
 
 const (
@@ -54,6 +67,14 @@ stack and recursively rebasing each branch based on the latest commit from the
 parent branch.
 
 If the --all flag is given, this command will sync all branches in the repository.
+The remote is fetched once, every stack is restacked onto the latest trunk, and
+the merged branches are pruned. A summary of the
+branches that were restacked, merged, deleted, or conflicted in each stack is
+printed at the end.
+
+If the --summary flag is given (or the sync.summary config is set), a summary of
+the stacks touched by the sync is printed at the end, followed by the suggested
+next commands, and av sync offers to run one of them.
 
 If the --current flag is given, this command will not recursively sync dependent
 branches of the current branch within the stack. This allows you to make changes
@@ -63,20 +84,37 @@ If the --rebase-to-trunk flag is given, this command will synchronize changes fr
 latest commit to the repository base branch (e.g., main or master) into the
 stack. This is useful for rebasing a whole stack on the latest changes from the
 base branch.
+
+If the --stdin flag is given, this command will sync the branches read from the
+standard input (one per line, e.g., the output of av query) instead of the
+current stack.
+
+If the --dry-run flag is given, this command will show which branches would be
+rebased, which are up to date, and which would conflict (and in which files)
+without fetching, rebasing, or pushing anything.
 `),
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) error {
+		push := config.Av.Sync.Push
+		if syncFlags.Push != "" {
+			push = syncFlags.Push
+		}
+		pushPolicy, err := config.ParsePushPolicy(push)
+		if err != nil {
+			return errors.WrapIf(err, "invalid value for --push")
+		}
+		syncFlags.Push = pushPolicy
 		if !sliceutils.Contains(
 			[]string{"ask", "yes", "no"},
-			strings.ToLower(syncFlags.Push),
+			strings.ToLower(syncFlags.Prune),
 		) {
-			return errors.New("invalid value for --push; must be one of ask, yes, no")
+			return errors.New("invalid value for --prune; must be one of ask, yes, no")
 		}
 		if !sliceutils.Contains(
 			[]string{"ask", "yes", "no"},
-			strings.ToLower(syncFlags.Prune),
+			strings.ToLower(syncFlags.PruneAll),
 		) {
-			return errors.New("invalid value for --prune; must be one of ask, yes, no")
+			return errors.New("invalid value for --prune-all; must be one of ask, yes, no")
 		}
 		if cmd.Flags().Changed("no-fetch") {
 			return actions.ErrExitSilently{ExitCode: 1}
@@ -87,6 +125,9 @@ base branch.
 		if cmd.Flags().Changed("parent") {
 			return actions.ErrExitSilently{ExitCode: 1}
 		}
+		if rootFlags.Offline {
+			return errors.New("av sync needs to reach the remote (use av restack to restack the branches offline)")
+		}
 		repo, err := getRepo()
 		if err != nil {
 			return err
@@ -95,21 +136,166 @@ base branch.
 		if err != nil {
 			return err
 		}
-		client, err := getGitHubClient()
+		if syncFlags.Explain {
+			return explainInterruptedOperation(repo)
+		}
+		if syncFlags.Continue || syncFlags.Abort || syncFlags.Skip {
+			if kind, ok := repo.InProgressStateFile(); ok && kind != git.StateFileKindSyncV2 {
+				return resumeInterruptedOperation(repo, db, kind)
+			}
+		} else if err := checkNoOtherOperationInProgress(repo, git.StateFileKindSyncV2); err != nil {
+			return err
+		}
+		var stdinBranches []string
+		if syncFlags.Stdin {
+			if syncFlags.All || syncFlags.Current {
+				return errors.New("cannot use --stdin with --all or --current")
+			}
+			stdinBranches, err = readStdinBranches()
+			if err != nil {
+				return err
+			}
+		}
+		if syncFlags.DryRun {
+			return syncDryRun(repo, db, stdinBranches)
+		}
+		if syncFlags.Push == config.PushAsk && !syncFlags.Abort {
+			// Fail before rebasing anything rather than when asking to push.
+			if err := uiutils.RequireInteractive(
+				"confirming the push", "use --push=always or --push=never, or the sync.push config",
+			); err != nil {
+				return err
+			}
+		}
+		migrateMovedRepository(repo, db)
+		client, err := getForge(db.ReadTx().Repository())
 		if err != nil {
 			return err
 		}
 
-		return uiutils.RunBubbleTea(&syncViewModel{
+		syncMetadata := config.Av.SyncMetadata && !syncFlags.Abort
+		if syncMetadata && !syncFlags.Continue && !syncFlags.Skip {
+			pullSyncedMetadata(repo, db)
+		}
+		if !syncFlags.Continue && !syncFlags.Abort && !syncFlags.Skip {
+			tx := db.ReadTx()
+			branches := stdinBranches
+			if branches == nil {
+				branches = lockedBranchesToCheck(repo, tx, syncFlags.All)
+			}
+			if err := actions.ConfirmLockedBranches(repo, tx, branches, "sync"); err != nil {
+				return err
+			}
+		}
+		// The summary after the sync compares the branches before and after the sync.
+		var before syncSnapshot
+		if !syncFlags.Continue && !syncFlags.Abort && !syncFlags.Skip && stdinBranches == nil {
+			before = takeSyncSnapshot(repo, db.ReadTx())
+		}
+		vm := &syncViewModel{
 			repo:             repo,
 			db:               db,
 			client:           client,
 			help:             help.New(),
 			askingSyncChange: !config.UserState.NotifiedStackSyncChange,
-		})
+			stdinBranches:    stdinBranches,
+		}
+		err = uiutils.RunBubbleTea(vm)
+		summary := config.Av.Sync.Summary
+		if cmd.Flags().Changed("summary") {
+			summary = syncFlags.Summary
+		}
+		var nextSteps []syncNextStep
+		if before != nil && (summary || syncFlags.All) && vm.completedOrConflicted() {
+			nextSteps = printSyncSummary(
+				os.Stderr, repo, db.ReadTx(), before, vm.conflictBranch(), syncFlags.All,
+			)
+		}
+		if !summary {
+			// The next steps and the prompt are opt-in.
+			nextSteps = nil
+		}
+		pruneAll := strings.ToLower(syncFlags.PruneAll)
+		if err != nil {
+			if vm.quitWithConflict && pruneAll != "no" {
+				// The branches to prune may be in the middle of the restack.
+				fmt.Fprint(os.Stderr,
+					colors.Faint("Skipped pruning because of the conflict. Run "),
+					colors.CliCmd("av prune"), colors.Faint(" once the sync is done.\n"),
+				)
+			}
+			return err
+		}
+		if !syncFlags.Abort {
+			// The pull requests whose parents were merged may be ready for review now.
+			if err := actions.PromoteDraftPullRequests(
+				context.Background(), client, db, os.Stderr,
+			); err != nil {
+				fmt.Fprint(os.Stderr, colors.Warning("Failed to mark the draft pull requests as ready: "+err.Error()+"\n"))
+			}
+			// The pull requests submitted with auto-merge get it once they target the trunk.
+			if err := actions.EnablePendingAutoMerges(
+				context.Background(), client, db, os.Stderr,
+			); err != nil {
+				fmt.Fprint(os.Stderr, colors.Warning("Failed to enable auto-merge: "+err.Error()+"\n"))
+			}
+		}
+		if pruneAll != "no" && !syncFlags.Abort && vm.completedOrConflicted() {
+			// The remote and the pull requests have been fetched by the sync.
+			if err := runPrune(repo, db, pruneOptions{Yes: pruneAll == "yes"}); err != nil {
+				return err
+			}
+		}
+		if syncMetadata {
+			if err := metasync.Push(repo, db); err != nil {
+				fmt.Fprint(os.Stderr, colors.Warning("Failed to push the av metadata: "+err.Error()+"\n"))
+			}
+		}
+		if !syncFlags.Abort && vm.completedOrConflicted() {
+			currentBranch, _ := repo.CurrentBranchName()
+			runPostHook(repo, hooks.PostSync, branchHookEnv(db.ReadTx(), currentBranch))
+		}
+		return printSyncNextSteps(nextSteps)
 	},
 }
 
+// syncDryRun prints the predicted outcome of restacking the branches to sync. The remote is not
+// fetched, so the branches are rebased onto the remote tracking branches as they are.
+func syncDryRun(repo *git.Repo, db meta.DB, stdinBranches []string) error {
+	if err := checkNoOtherOperationInProgress(repo, ""); err != nil {
+		return err
+	}
+	vm := &syncViewModel{repo: repo, db: db, stdinBranches: stdinBranches}
+	state, err := vm.createState()
+	if err != nil {
+		return err
+	}
+	return runRestackDryRun(os.Stderr, repo, state.RestackState.Seq, "av sync")
+}
+
+// pullSyncedMetadata merges the av metadata on the remote (see the syncMetadata config) into
+// the local metadata. A failure is reported as a warning since the sync can proceed with the
+// local metadata.
+func pullSyncedMetadata(repo *git.Repo, db meta.DB) {
+	result, err := metasync.Pull(repo, db)
+	if err != nil {
+		fmt.Fprint(os.Stderr, colors.Warning("Failed to fetch the av metadata: "+err.Error()+"\n"))
+		return
+	}
+	for _, name := range result.Added {
+		fmt.Fprint(os.Stderr,
+			colors.Success("Added branch "), colors.UserInput(name),
+			colors.Success(" from the av metadata on the remote.\n"),
+		)
+	}
+	for _, name := range result.Conflicts {
+		fmt.Fprint(os.Stderr,
+			colors.Warning("The av metadata of "), colors.UserInput(name),
+			colors.Warning(" was changed on another clone too; keeping the local one.\n"),
+		)
+	}
+}
+
 type savedSyncState struct {
 	RestackState *sequencerui.RestackState
 	SyncState    *syncState
@@ -119,14 +305,20 @@ type syncState struct {
 	TargetBranches []plumbing.ReferenceName
 	Prune          string
 	Push           string
+	// The commits of the target branches before the restack, to find the branches rebased by
+	// the sync for the only-if-restacked push policy.
+	Heads map[string]string
 }
 
 type syncViewModel struct {
 	repo   *git.Repo
 	db     meta.DB
-	client *gh.Client
+	client forge.Forge
 	help   help.Model
 
+	// The branches given with --stdin.
+	stdinBranches []string
+
 	state              *syncState
 	changeNoticePrompt *selection.Model[string]
 	syncAllPrompt      *selection.Model[string]
@@ -145,7 +337,7 @@ type syncViewModel struct {
 }
 
 func (vm *syncViewModel) Init() tea.Cmd {
-	if vm.askingSyncChange && os.Getenv("AV_STACK_SYNC_CHANGE_NO_ASK") != "1" {
+	if vm.askingSyncChange && os.Getenv("AV_STACK_SYNC_CHANGE_NO_ASK") != "1" && !config.NonInteractive {
 		vm.changeNoticePrompt = uiutils.NewPromptModel(
 			changeNoticePrompt,
 			[]string{continueWithSyncChoice, abortSyncChoice},
@@ -186,6 +378,7 @@ func (vm *syncViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		vm.githubFetchModel, cmd = vm.githubFetchModel.Update(msg)
 		return vm, cmd
 	case *ghui.GitHubFetchDone:
+		progress.Report(progress.PhaseFetch, "", 1, 1)
 		return vm, vm.initSequencerState()
 
 	case *sequencerui.RestackProgress:
@@ -214,6 +407,7 @@ func (vm *syncViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		vm.githubPushModel, cmd = vm.githubPushModel.Update(msg)
 		return vm, cmd
 	case *ghui.GitHubPushDone:
+		progress.Report(progress.PhasePush, "", 1, 1)
 		vm.pushingToGitHub = false
 		return vm, vm.initPruneBranches()
 
@@ -222,6 +416,7 @@ func (vm *syncViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		vm.pruneBranchModel, cmd = vm.pruneBranchModel.Update(msg)
 		return vm, cmd
 	case *gitui.PruneBranchDone:
+		progress.Report(progress.PhasePrune, "", 1, 1)
 		vm.pruningBranches = false
 		return vm, tea.Quit
 
@@ -230,6 +425,11 @@ func (vm *syncViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return vm, vm.syncAllPrompt.Init()
 
 	case tea.KeyMsg:
+		if vm.restackModel != nil && vm.restackModel.IsPrompting() {
+			var cmd tea.Cmd
+			vm.restackModel, cmd = vm.restackModel.Update(msg)
+			return vm, cmd
+		}
 		if vm.syncAllPrompt != nil {
 			switch msg.String() {
 			case " ", "enter":
@@ -345,54 +545,52 @@ func (vm *syncViewModel) View() string {
 	return ret
 }
 
-var commandStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("5"))
-
 func (vm *syncViewModel) viewChangeNotice() string {
 	boldStyle := lipgloss.NewStyle().Bold(true)
 	sb := strings.Builder{}
 	sb.WriteString(
 		boldStyle.Render(
 			"The behavior of ",
-		) + commandStyle.Bold(true).
+		) + colors.CommandStyle.Bold(true).
 			Render("av sync") +
 			boldStyle.Render(
 				" has changed. We will now ask for confirmation before syncing the stack.\n",
 			),
 	)
 	sb.WriteString("\n")
-	sb.WriteString("* " + commandStyle.Render("av sync") + " is split into four commands:\n")
+	sb.WriteString("* " + colors.CommandStyle.Render("av sync") + " is split into four commands:\n")
 	sb.WriteString(
-		"  * " + commandStyle.Render("av adopt") + " to adopt a new branch into the stack.\n",
+		"  * " + colors.CommandStyle.Render("av adopt") + " to adopt a new branch into the stack.\n",
 	)
 	sb.WriteString(
-		"  * " + commandStyle.Render("av reparent") + " to change the parent branch.\n",
+		"  * " + colors.CommandStyle.Render("av reparent") + " to change the parent branch.\n",
 	)
 	sb.WriteString(
-		"  * " + commandStyle.Render("av restack") + " to rebase the stack locally.\n",
+		"  * " + colors.CommandStyle.Render("av restack") + " to rebase the stack locally.\n",
 	)
 	sb.WriteString(
-		"  * " + commandStyle.Render(
+		"  * " + colors.CommandStyle.Render(
 			"av sync",
 		) + " to rebase the stack with the remote repository.\n",
 	)
 	sb.WriteString(
-		"* " + commandStyle.Render(
+		"* " + colors.CommandStyle.Render(
 			"av sync",
 		) + " will ask if you want to push to the remote repository.\n",
 	)
 	sb.WriteString(
-		"* " + commandStyle.Render(
+		"* " + colors.CommandStyle.Render(
 			"av sync",
 		) + " will ask if you want to delete the branches that have been merged.\n",
 	)
 	sb.WriteString("\n")
 	sb.WriteString(
-		"With this change, " + commandStyle.Render(
+		"With this change, " + colors.CommandStyle.Render(
 			"av sync",
 		) + " will always rebase onto the remote trunk branch (e.g., main or\n",
 	)
 	sb.WriteString(
-		"master). If you do not want to rebase onto the remote trunk branch, please use " + commandStyle.Render(
+		"master). If you do not want to rebase onto the remote trunk branch, please use " + colors.CommandStyle.Render(
 			"av restack",
 		) + ".\n",
 	)
@@ -422,15 +620,22 @@ func (vm *syncViewModel) initSync() tea.Cmd {
 	if err != nil {
 		return func() tea.Msg { return err }
 	}
-	if isTrunkBranch && !syncFlags.All {
+	if isTrunkBranch && !syncFlags.All && vm.stdinBranches == nil {
+		if err := uiutils.RequireInteractive("choosing whether to sync all stacks", "use --all"); err != nil {
+			return func() tea.Msg { return err }
+		}
 		return func() tea.Msg {
 			return promptUserShouldSyncAllMsg{}
 		}
 	}
+	if err := oplog.Record(vm.repo, vm.db.ReadTx(), "av sync"); err != nil {
+		return func() tea.Msg { return err }
+	}
 	vm.githubFetchModel, err = vm.createGitHubFetchModel()
 	if err != nil {
 		return func() tea.Msg { return err }
 	}
+	progress.Report(progress.PhaseFetch, "", 0, 1)
 	return vm.githubFetchModel.Init()
 }
 
@@ -442,6 +647,16 @@ func (vm *syncViewModel) initSequencerState() tea.Cmd {
 	if state == nil {
 		return func() tea.Msg { return nothingToRestackError }
 	}
+	if len(state.RestackState.Seq.Operations) > 0 {
+		if syncFlags.Autostash || config.Av.Restack.Autostash {
+			state.RestackState.Autostash, err = vm.repo.Autostash("av sync")
+		} else {
+			err = sequencer.CheckCleanWorktree(vm.repo, "av sync")
+		}
+		if err != nil {
+			return func() tea.Msg { return err }
+		}
+	}
 	return vm.continueWithState(state)
 }
 
@@ -485,7 +700,13 @@ func (vm *syncViewModel) createGitHubFetchModel() (*ghui.GitHubFetchModel, error
 	currentBranch := status.CurrentBranch
 
 	var targetBranches []plumbing.ReferenceName
-	if syncFlags.All {
+	if vm.stdinBranches != nil {
+		var err error
+		targetBranches, err = planner.GetTargetBranchesFromList(vm.db.ReadTx(), vm.stdinBranches)
+		if err != nil {
+			return nil, err
+		}
+	} else if syncFlags.All {
 		var err error
 		targetBranches, err = planner.GetTargetBranches(
 			vm.db.ReadTx(),
@@ -498,7 +719,7 @@ func (vm *syncViewModel) createGitHubFetchModel() (*ghui.GitHubFetchModel, error
 		}
 	} else {
 		if _, exist := vm.db.ReadTx().Branch(currentBranch); !exist {
-			return nil, errors.New("current branch is not adopted to av")
+			return nil, actions.BranchNotAdoptedError{Branch: currentBranch}
 		}
 		var err error
 		if syncFlags.Current {
@@ -541,7 +762,16 @@ func (vm *syncViewModel) createState() (*savedSyncState, error) {
 	state.RestackState.InitialBranch = currentBranch
 
 	var targetBranches []plumbing.ReferenceName
-	if syncFlags.All {
+	if vm.stdinBranches != nil {
+		var err error
+		targetBranches, err = planner.GetTargetBranchesFromList(vm.db.ReadTx(), vm.stdinBranches)
+		if err != nil {
+			return nil, err
+		}
+		for _, br := range targetBranches {
+			state.RestackState.RelatedBranches = append(state.RestackState.RelatedBranches, br.Short())
+		}
+	} else if syncFlags.All {
 		var err error
 		targetBranches, err = planner.GetTargetBranches(
 			vm.db.ReadTx(),
@@ -555,7 +785,7 @@ func (vm *syncViewModel) createState() (*savedSyncState, error) {
 		state.RestackState.RestackingAll = true
 	} else {
 		if _, exist := vm.db.ReadTx().Branch(currentBranch); !exist {
-			return nil, errors.New("current branch is not adopted to av")
+			return nil, actions.BranchNotAdoptedError{Branch: currentBranch}
 		}
 		var err error
 		if syncFlags.Current {
@@ -569,38 +799,79 @@ func (vm *syncViewModel) createState() (*savedSyncState, error) {
 		state.RestackState.RelatedBranches = append(state.RestackState.RelatedBranches, currentBranch)
 	}
 	state.SyncState.TargetBranches = targetBranches
+	state.SyncState.Heads = map[string]string{}
+	for _, br := range targetBranches {
+		if head, err := vm.repo.RevParse(&git.RevParse{Rev: br.String()}); err == nil {
+			state.SyncState.Heads[br.Short()] = head
+		}
+	}
 
 	var currentBranchRef plumbing.ReferenceName
 	if currentBranch != "" {
 		currentBranchRef = plumbing.NewBranchReferenceName(currentBranch)
 	}
-	ops, err := planner.PlanForSync(
-		vm.db.ReadTx(),
-		vm.repo,
-		currentBranchRef,
-		syncFlags.All,
-		syncFlags.Current,
-		syncFlags.RebaseToTrunk,
-	)
-	if err != nil {
-		return nil, err
+	var ops []sequencer.RestackOp
+	if vm.stdinBranches != nil {
+		ops = planner.PlanForSyncBranches(
+			vm.db.ReadTx(),
+			targetBranches,
+			syncFlags.RebaseToTrunk || syncFlags.All,
+		)
+	} else {
+		ops, err = planner.PlanForSync(
+			vm.db.ReadTx(),
+			vm.repo,
+			currentBranchRef,
+			syncFlags.All,
+			syncFlags.Current,
+			// --all brings every stack up to date with the latest trunk.
+			syncFlags.RebaseToTrunk || syncFlags.All,
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 	state.RestackState.Seq = sequencer.NewSequencer(vm.repo.GetRemoteName(), vm.db, ops)
 	return &state, nil
 }
 
 func (vm *syncViewModel) initPushBranches() tea.Cmd {
+	// The state saved by an older version may have "yes" or "no".
+	pushPolicy, err := config.ParsePushPolicy(vm.state.Push)
+	if err != nil {
+		return func() tea.Msg { return err }
+	}
 	vm.githubPushModel = ghui.NewGitHubPushModel(
 		vm.repo,
 		vm.db,
 		vm.client,
-		vm.state.Push,
+		pushPolicy,
 		vm.state.TargetBranches,
 	)
+	if pushPolicy == config.PushOnlyIfRestacked {
+		vm.githubPushModel.SetRestackedBranches(vm.restackedBranches())
+	}
 	vm.pushingToGitHub = true
+	progress.Report(progress.PhasePush, "", 0, 1)
 	return vm.githubPushModel.Init()
 }
 
+// restackedBranches returns the target branches whose commits were changed by the restack. The
+// branches without a recorded commit are included.
+func (vm *syncViewModel) restackedBranches() []string {
+	var ret []string
+	for _, br := range vm.state.TargetBranches {
+		head, err := vm.repo.RevParse(&git.RevParse{Rev: br.String()})
+		if err != nil {
+			continue
+		}
+		if before, ok := vm.state.Heads[br.Short()]; !ok || before != head {
+			ret = append(ret, br.Short())
+		}
+	}
+	return ret
+}
+
 func (vm *syncViewModel) initPruneBranches() tea.Cmd {
 	vm.pruneBranchModel = gitui.NewPruneBranchModel(
 		vm.repo,
@@ -610,18 +881,38 @@ func (vm *syncViewModel) initPruneBranches() tea.Cmd {
 		vm.restackModel.State.InitialBranch,
 	)
 	vm.pruningBranches = true
+	progress.Report(progress.PhasePrune, "", 0, 1)
 	return vm.pruneBranchModel.Init()
 }
 
+// completedOrConflicted returns true if the sync ran to the end or stopped at a conflict.
+func (vm *syncViewModel) completedOrConflicted() bool {
+	if vm.quitWithConflict {
+		return true
+	}
+	if vm.err != nil {
+		return errors.Is(vm.err, nothingToRestackError)
+	}
+	return vm.restackModel != nil && !vm.quitWithAbortChoice
+}
+
+// conflictBranch returns the branch that conflicted during the restack, if any.
+func (vm *syncViewModel) conflictBranch() string {
+	if !vm.quitWithConflict || vm.restackModel == nil || vm.restackModel.State == nil {
+		return ""
+	}
+	return vm.restackModel.State.Seq.CurrentSyncRef.Short()
+}
+
 func (vm *syncViewModel) ExitError() error {
 	if errors.Is(vm.err, nothingToRestackError) {
 		return nil
 	}
 	if vm.err != nil {
-		return actions.ErrExitSilently{ExitCode: 1}
+		return actions.ErrExitSilently{ExitCode: 1, Err: vm.err}
 	}
 	if vm.quitWithConflict {
-		return actions.ErrExitSilently{ExitCode: 1}
+		return actions.ErrExitConflict
 	}
 	return nil
 }
@@ -631,19 +922,25 @@ func init() {
 		&syncFlags.All, "all", false,
 		"synchronize all branches",
 	)
+	addStdinBranchesFlag(syncCmd, &syncFlags.Stdin)
 	syncCmd.Flags().BoolVar(
 		&syncFlags.Current, "current", false,
 		"only sync changes to the current branch\n(don't recurse into descendant branches)",
 	)
 	syncCmd.Flags().StringVar(
-		&syncFlags.Push, "push", "ask",
-		"push the rebased branches to the remote repository\n(ask|yes|no)",
+		&syncFlags.Push, "push", "",
+		"push the rebased branches to the remote repository\n(ask|always|never|only-if-restacked; default: the sync.push config)",
 	)
 	syncCmd.Flags().StringVar(
 		&syncFlags.Prune, "prune", "ask",
 		"delete branches that have been merged into the parent branch\n(ask|yes|no)",
 	)
 	syncCmd.Flags().Lookup("prune").NoOptDefVal = "ask"
+	syncCmd.Flags().StringVar(
+		&syncFlags.PruneAll, "prune-all", "no",
+		"after the sync, run av prune to delete the merged and closed branches\nlocally and on the remote (ask|yes|no)",
+	)
+	syncCmd.Flags().Lookup("prune-all").NoOptDefVal = "ask"
 	syncCmd.Flags().BoolVar(
 		&syncFlags.RebaseToTrunk, "rebase-to-trunk", false,
 		"rebase the branches to the latest trunk always",
@@ -662,7 +959,24 @@ func init() {
 		"skip the current commit and continue an in-progress sync",
 	)
 	syncCmd.MarkFlagsMutuallyExclusive("current", "all")
-	syncCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip")
+	syncCmd.Flags().BoolVar(
+		&syncFlags.Explain, "explain", false,
+		"explain how to resolve the conflict of an in-progress sync and continue",
+	)
+	syncCmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip", "explain")
+	syncCmd.Flags().BoolVar(
+		&syncFlags.DryRun, "dry-run", false,
+		"show which branches would be rebased and which would conflict without changing them",
+	)
+	syncCmd.MarkFlagsMutuallyExclusive("dry-run", "continue", "abort", "skip", "explain")
+	syncCmd.Flags().BoolVar(
+		&syncFlags.Autostash, "autostash", false,
+		"stash the uncommitted changes before rebasing and restore them afterward",
+	)
+	syncCmd.Flags().BoolVar(
+		&syncFlags.Summary, "summary", false,
+		"print the summary of the stacks and the suggested next steps after the sync\n(default: the sync.summary config)",
+	)
 
 	// Deprecated flags
 	syncCmd.Flags().Bool("no-fetch", false,
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+	"github.com/mattn/go-isatty"
+	"github.com/shurcooL/githubv4"
+)
+
+// syncSnapshot is the state of the branches before av sync, keyed by the branch name. It's
+// compared with the state after the sync to summarize what the sync changed.
+type syncSnapshot map[string]syncBranchSnapshot
+
+type syncBranchSnapshot struct {
+	// The stack root of the branch.
+	Root   string
+	Parent string
+	Head   string
+	Merged bool
+}
+
+// syncNextStep is an av command suggested by the sync summary.
+type syncNextStep struct {
+	Description string
+	// The arguments of av.
+	Args []string
+	// The lines written to the standard input of the command.
+	Stdin []string
+}
+
+func (s syncNextStep) command() string {
+	cmd := "av " + strings.Join(s.Args, " ")
+	if len(s.Stdin) > 0 {
+		cmd = "printf '%s\\n' " + strings.Join(s.Stdin, " ") + " | " + cmd
+	}
+	return cmd
+}
+
+func branchMerged(branch meta.Branch) bool {
+	return branch.MergeCommit != "" ||
+		(branch.PullRequest != nil && branch.PullRequest.State == githubv4.PullRequestStateMerged)
+}
+
+func takeSyncSnapshot(repo *git.Repo, tx meta.ReadTx) syncSnapshot {
+	ret := syncSnapshot{}
+	for name, branch := range tx.AllBranches() {
+		root, _ := meta.Root(tx, name)
+		head, _ := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + name})
+		ret[name] = syncBranchSnapshot{
+			Root:   root,
+			Parent: branch.Parent.Name,
+			Head:   head,
+			Merged: branchMerged(branch),
+		}
+	}
+	return ret
+}
+
+// printSyncSummary prints what happened to each stack during the sync (the branches that were
+// restacked, merged, or deleted, and the pull requests that were retargeted) and what still
+// needs attention (the branch that conflicted and the branches without a pull request). Without
+// --all, the stacks that the sync didn't touch are left out. It returns the suggested next
+// steps.
+func printSyncSummary(
+	w io.Writer,
+	repo *git.Repo,
+	tx meta.ReadTx,
+	before syncSnapshot,
+	conflict string,
+	all bool,
+) []syncNextStep {
+	type stackSummary struct {
+		restacked   []string
+		merged      []string
+		pruned      []string
+		retargeted  []string
+		unsubmitted []string
+		conflict    string
+	}
+	stacks := map[string]*stackSummary{}
+	for name, snap := range before {
+		if snap.Root == "" {
+			continue
+		}
+		s, ok := stacks[snap.Root]
+		if !ok {
+			s = &stackSummary{}
+			stacks[snap.Root] = s
+		}
+		if name == conflict {
+			s.conflict = name
+		}
+		branch, ok := tx.Branch(name)
+		if !ok {
+			s.pruned = append(s.pruned, name)
+			continue
+		}
+		merged := branchMerged(branch)
+		if !snap.Merged && merged {
+			s.merged = append(s.merged, name)
+		}
+		if head, _ := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + name}); head != snap.Head {
+			s.restacked = append(s.restacked, name)
+		}
+		if merged {
+			continue
+		}
+		if branch.PullRequest == nil {
+			if meta.NoSubmitAncestor(tx, name) == "" {
+				s.unsubmitted = append(s.unsubmitted, name)
+			}
+		} else if branch.Parent.Name != snap.Parent {
+			s.retargeted = append(s.retargeted, name)
+		}
+	}
+
+	var roots []string
+	for root, s := range stacks {
+		changed := s.conflict != "" || len(s.restacked) > 0 || len(s.merged) > 0 ||
+			len(s.pruned) > 0 || len(s.retargeted) > 0
+		if all || changed {
+			roots = append(roots, root)
+		}
+	}
+	if len(roots) == 0 {
+		return nil
+	}
+	slices.Sort(roots)
+	var unsubmitted []string
+	_, _ = fmt.Fprint(w, "Summary of the stacks:\n")
+	for _, root := range roots {
+		s := stacks[root]
+		var parts []string
+		if len(s.restacked) > 0 {
+			slices.Sort(s.restacked)
+			parts = append(parts, "restacked "+strings.Join(s.restacked, ", "))
+		}
+		if len(s.retargeted) > 0 {
+			slices.Sort(s.retargeted)
+			parts = append(parts, "retargeted the pull requests of "+strings.Join(s.retargeted, ", "))
+		}
+		if len(s.merged) > 0 {
+			slices.Sort(s.merged)
+			parts = append(parts, "merged "+strings.Join(s.merged, ", "))
+		}
+		if len(s.pruned) > 0 {
+			slices.Sort(s.pruned)
+			parts = append(parts, "deleted "+strings.Join(s.pruned, ", "))
+		}
+		_, _ = fmt.Fprint(w, "  - ", colors.UserInput(root), ": ")
+		switch {
+		case s.conflict != "":
+			_, _ = fmt.Fprint(w, colors.Failure("conflict in "+s.conflict))
+			if len(parts) > 0 {
+				_, _ = fmt.Fprint(w, colors.Faint(" ("+strings.Join(parts, "; ")+")"))
+			}
+		case len(parts) > 0:
+			_, _ = fmt.Fprint(w, strings.Join(parts, "; "))
+		default:
+			_, _ = fmt.Fprint(w, colors.Faint("up to date"))
+		}
+		_, _ = fmt.Fprint(w, "\n")
+		if len(s.unsubmitted) > 0 {
+			slices.Sort(s.unsubmitted)
+			unsubmitted = append(unsubmitted, s.unsubmitted...)
+			_, _ = fmt.Fprint(w,
+				"    ", colors.Warning("needs attention: "),
+				"no pull request for ", strings.Join(s.unsubmitted, ", "), "\n",
+			)
+		}
+	}
+
+	var steps []syncNextStep
+	if conflict != "" {
+		_, _ = fmt.Fprint(w,
+			colors.Faint("Resolve the conflict and run "), colors.CliCmd("av sync --continue"),
+			colors.Faint(" to sync the rest of the stacks.\n"),
+		)
+		// The other commands can't run until the conflict is resolved.
+		return []syncNextStep{{
+			Description: "explain the conflict",
+			Args:        []string{"sync", "--explain"},
+		}}
+	}
+	if len(unsubmitted) > 0 {
+		steps = append(steps, syncNextStep{
+			Description: "create the missing pull requests",
+			Args:        []string{"pr", "--all", "--stdin"},
+			Stdin:       unsubmitted,
+		})
+	}
+	return steps
+}
+
+// printSyncNextSteps lists the suggested next steps, if any, and, in a terminal, offers to run
+// one of them.
+func printSyncNextSteps(steps []syncNextStep) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	fmt.Fprint(os.Stderr, "Next steps:\n")
+	for i, step := range steps {
+		fmt.Fprint(os.Stderr,
+			"  ", i+1, ". ", colors.CliCmd(step.command()),
+			colors.Faint(" ("+step.Description+")"), "\n",
+		)
+	}
+	if !uiutils.IsInteractive() || !isatty.IsTerminal(os.Stderr.Fd()) {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Run a next step? [1-%d, Enter to skip] ", len(steps))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(answer)
+	if err != nil || n < 1 || n > len(steps) {
+		return errors.Errorf("invalid choice %q", answer)
+	}
+	step := steps[n-1]
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.WrapIf(err, "failed to find the av executable")
+	}
+	cmd := exec.Command(exe, step.Args...)
+	cmd.Stdin = os.Stdin
+	if len(step.Stdin) > 0 {
+		cmd.Stdin = strings.NewReader(strings.Join(step.Stdin, "\n") + "\n")
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return actions.ErrExitSilently{ExitCode: exitErr.ExitCode()}
+		}
+		return errors.WrapIff(err, "failed to run %s", step.command())
+	}
+	return nil
+}
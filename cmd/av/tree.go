@@ -1,51 +1,249 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"slices"
 	"strings"
+	"time"
 
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/colors"
 	"github.com/aviator-co/av/internal/utils/stackutils"
+	"github.com/aviator-co/av/internal/utils/timeutils"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/dustin/go-humanize"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"golang.org/x/exp/maps"
+)
+
+var treeFlags struct {
+	Stale        string
+	Activity     bool
+	Status       bool
+	Prefix       string
+	Mine         bool
+	Interactive  bool
+	At           string
+	Format       string
+	CommitCounts bool
+}
+
+const (
+	treeFormatText    = "text"
+	treeFormatMermaid = "mermaid"
+	treeFormatDot     = "dot"
 )
 
 var treeCmd = &cobra.Command{
 	Use:   "tree",
 	Short: "Show the tree of stacked branches",
-	Args:  cobra.NoArgs,
+	Long: strings.TrimSpace(`
+Show the tree of stacked branches.
+
+With --activity, each branch is shown with the time since its last commit. With
+--stale, the time since the last review of the pull request is also taken into
+account (and shown), and only the stacks that contain a branch without any
+activity for the given duration are shown. Stale branches are highlighted. With
+--status, the latest deployment statuses (e.g., preview environments) of the
+pull requests are shown.
+The first line of the branch note (see av notes) and the preview URL of the
+branch (see av preview) are shown if they are set.
+With --prefix, only the stacks that contain a branch with the given name prefix
+(e.g., "alice/") are shown. --mine is a shorthand for --prefix with the
+configured pullRequest.branchNamePrefix.
+
+With --json, the branches are printed in JSON in the tree order instead, with
+their parents, children, pull requests, and the numbers of the commits ahead of
+and behind their parents.
+
+With --format=mermaid or --format=dot, the tree is printed as a Mermaid
+flowchart or a Graphviz graph with the pull request numbers and states (e.g., to
+paste into a design document or a pull request description). The merged and
+closed pull requests are styled differently. With --commit-counts, the edges are
+labeled with the number of the commits in each branch.
+
+With --interactive, the tree is shown in an interactive browser with the status
+of the pull requests and their CI checks. Choose a branch with the arrow keys and
+press enter to check it out, r to restack it, or s to submit the pull requests up
+to it.
+
+With --at, the tree is shown as it was in the past, reconstructed from the av
+operation log and the reflogs of the branches. The argument is the number of an
+operation as listed by av undo --list (the state before the operation), a
+duration before now (e.g., 3d), or a date and time (2006-01-02 15:04).
+
+Examples:
+  Show the stacks with branches that have been inactive for two weeks:
+    $ av tree --stale 14d
+
+  Show the stacks with your branches:
+    $ av tree --mine
+
+  Show the stacks before the last av sync:
+    $ av tree --at 1
+
+  Render the stacks as an SVG image:
+    $ av tree --format=dot | dot -Tsvg > stacks.svg
+`),
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, _ []string) error {
 		repo, err := getRepo()
 		if err != nil {
 			return err
 		}
+		switch treeFlags.Format {
+		case "", treeFormatText:
+			if treeFlags.CommitCounts {
+				return errors.New("--commit-counts can only be used with --format=mermaid or --format=dot")
+			}
+		case treeFormatMermaid, treeFormatDot:
+			if jsonOutput() {
+				return errors.Errorf("--format=%s cannot be used with --json", treeFlags.Format)
+			}
+		default:
+			return errors.Errorf(
+				"invalid --format %q (expected %q, %q, or %q)",
+				treeFlags.Format, treeFormatText, treeFormatMermaid, treeFormatDot,
+			)
+		}
+
+		if treeFlags.Interactive {
+			if jsonOutput() {
+				return errors.New("--interactive cannot be used with --json")
+			}
+			return runTreeInteractive(repo)
+		}
 
 		db, err := getDB(repo)
 		if err != nil {
 			return err
 		}
 
+		if treeFlags.At != "" {
+			if jsonOutput() {
+				return errors.New("--at cannot be used with --json")
+			}
+			return runTreeAt(repo, db.ReadTx(), treeFlags.At)
+		}
+
 		status, err := repo.Status()
 		if err != nil {
 			return err
 		}
 
+		var staleThreshold time.Duration
+		if treeFlags.Stale != "" {
+			staleThreshold, err = timeutils.ParseDuration(treeFlags.Stale)
+			if err != nil {
+				return err
+			}
+		}
+
+		prefix := treeFlags.Prefix
+		if treeFlags.Mine {
+			if config.Av.PullRequest.BranchNamePrefix == "" {
+				return errors.New("--mine requires pullRequest.branchNamePrefix to be configured")
+			}
+			prefix = config.Av.PullRequest.BranchNamePrefix
+		}
+
 		var ss []string
 		currentBranch := status.CurrentBranch
 		tx := db.ReadTx()
+		var activities map[string]*branchActivity
+		if treeFlags.Activity || staleThreshold != 0 {
+			activities = getBranchActivities(repo, tx, staleThreshold != 0)
+		}
+		notes, err := repo.BranchNotes(maps.Keys(tx.AllBranches()))
+		if err != nil {
+			logrus.WithError(err).Warning("failed to read the branch notes")
+		}
+		var deployments map[string][]gh.Deployment
+		if treeFlags.Status {
+			deployments = getBranchDeployments(tx)
+		}
 		rootNodes := stackutils.BuildStackTreeAllBranches(tx, currentBranch, true)
+		staleBranches := map[string]bool{}
+		if staleThreshold != 0 || prefix != "" {
+			var names []string
+			if staleThreshold != 0 {
+				for name, activity := range activities {
+					if time.Since(activity.Last()) > staleThreshold {
+						staleBranches[name] = true
+						names = append(names, name)
+					}
+				}
+			} else {
+				names = maps.Keys(tx.AllBranches())
+			}
+			if prefix != "" {
+				names = slices.DeleteFunc(names, func(name string) bool {
+					return !strings.HasPrefix(name, prefix)
+				})
+				if len(names) == 0 {
+					if jsonOutput() {
+						return printJSON([]branchOutput{})
+					}
+					fmt.Fprint(os.Stderr,
+						colors.Success("No branches match the prefix "),
+						colors.UserInput(prefix), colors.Success(".\n"),
+					)
+					return nil
+				}
+			}
+			if len(names) == 0 {
+				if jsonOutput() {
+					return printJSON([]branchOutput{})
+				}
+				fmt.Fprint(os.Stderr,
+					colors.Success("No branches have been inactive for "),
+					colors.UserInput(treeFlags.Stale), colors.Success(".\n"),
+				)
+				return nil
+			}
+			rootNodes, err = stackutils.BuildStackTreeRelatedBranchStacks(
+				tx, currentBranch, true, names,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		if jsonOutput() {
+			return printJSON(newBranchOutputs(repo, tx, currentBranch, stackTreeBranchNames(rootNodes)))
+		}
+		if treeFlags.Format == treeFormatMermaid || treeFlags.Format == treeFormatDot {
+			graph := &stackGraphOutput{Trunks: []string{}}
+			for _, node := range rootNodes {
+				graph.Trunks = append(graph.Trunks, node.Branch.BranchName)
+			}
+			graph.Branches = newBranchOutputs(repo, tx, currentBranch, stackTreeBranchNames(rootNodes))
+			if treeFlags.Format == treeFormatMermaid {
+				return writeStackGraphMermaid(os.Stdout, graph, treeFlags.CommitCounts)
+			}
+			return writeStackGraphDot(os.Stdout, graph, treeFlags.CommitCounts)
+		}
 		for _, node := range rootNodes {
 			ss = append(
 				ss,
 				stackutils.RenderTree(node, func(branchName string, isTrunk bool) string {
 					return renderStackTreeBranchInfo(
 						tx,
-						stackTreeStackBranchInfoStyles,
+						stackTreeStackBranchInfoStyles(),
 						currentBranch,
 						branchName,
 						isTrunk,
+						activities[branchName],
+						staleBranches[branchName],
+						deployments[branchName],
+						notes[branchName],
 					)
 				}),
 			)
@@ -65,12 +263,27 @@ type stackBranchInfoStyles struct {
 	BranchName      lipgloss.Style
 	HEAD            lipgloss.Style
 	PullRequestLink lipgloss.Style
+	Activity        lipgloss.Style
+	Stale           lipgloss.Style
+	Pinned          lipgloss.Style
+	Deployments     lipgloss.Style
+	Preview         lipgloss.Style
+	Note            lipgloss.Style
 }
 
-var stackTreeStackBranchInfoStyles = stackBranchInfoStyles{
-	BranchName:      lipgloss.NewStyle().Bold(true).Foreground(colors.Green600),
-	HEAD:            lipgloss.NewStyle().Bold(true).Foreground(colors.Cyan600),
-	PullRequestLink: lipgloss.NewStyle(),
+// stackTreeStackBranchInfoStyles returns the styles of av tree in the configured theme.
+func stackTreeStackBranchInfoStyles() stackBranchInfoStyles {
+	return stackBranchInfoStyles{
+		BranchName:      lipgloss.NewStyle().Bold(true).Foreground(colors.BranchColor),
+		HEAD:            lipgloss.NewStyle().Bold(true).Foreground(colors.CurrentColor),
+		PullRequestLink: lipgloss.NewStyle(),
+		Activity:        lipgloss.NewStyle().Faint(true),
+		Stale:           lipgloss.NewStyle().Bold(true).Foreground(colors.AttentionColor),
+		Pinned:          lipgloss.NewStyle().Bold(true).Foreground(colors.PinnedColor),
+		Deployments:     lipgloss.NewStyle().Faint(true),
+		Preview:         lipgloss.NewStyle().Foreground(colors.CurrentColor),
+		Note:            lipgloss.NewStyle().Italic(true).Foreground(colors.AttentionColor),
+	}
 }
 
 func renderStackTreeBranchInfo(
@@ -79,6 +292,10 @@ func renderStackTreeBranchInfo(
 	currentBranchName string,
 	branchName string,
 	isTrunk bool,
+	activity *branchActivity,
+	stale bool,
+	deployments []gh.Deployment,
+	note string,
 ) string {
 	bi, _ := tx.Branch(branchName)
 
@@ -88,6 +305,38 @@ func renderStackTreeBranchInfo(
 	if branchName == currentBranchName {
 		stats = append(stats, styles.HEAD.Render("HEAD"))
 	}
+	if bi.IsPinned() {
+		stats = append(stats, styles.Pinned.Render("pinned at "+bi.PinnedCommit[:7]))
+	}
+	if bi.IsFollowed() {
+		stats = append(stats, styles.Pinned.Render("followed"))
+	}
+	if bi.NoSubmit {
+		stats = append(stats, styles.Pinned.Render("no submit"))
+	}
+	if bi.Issue != "" {
+		stats = append(stats, styles.Pinned.Render("issue "+bi.Issue))
+	}
+	if bi.BaseRef != "" {
+		stats = append(stats, styles.Pinned.Render("based on "+bi.BaseRef))
+	} else if bi.FrozenBase != "" {
+		stats = append(stats, styles.Pinned.Render("base frozen at "+bi.FrozenBase[:7]))
+	}
+	if bi.Lock != nil {
+		stats = append(stats, styles.Pinned.Render("locked by "+bi.Lock.Owner))
+	}
+	if !isTrunk {
+		// A branch that more than one stack is built on. Restacking it restacks all of them.
+		if n := len(meta.ChildrenNames(tx, branchName)); n > 1 {
+			stats = append(stats, styles.Pinned.Render(fmt.Sprintf("shared by %d stacks", n)))
+		}
+	}
+	if bi.Conflict != nil {
+		stats = append(stats, styles.Stale.Render("conflicted"))
+	}
+	if stale {
+		stats = append(stats, styles.Stale.Render("stale"))
+	}
 	if len(stats) > 0 {
 		sb.WriteString(" (")
 		sb.WriteString(strings.Join(stats, ", "))
@@ -101,10 +350,154 @@ func renderStackTreeBranchInfo(
 		} else {
 			sb.WriteString(styles.PullRequestLink.Render("No pull request"))
 		}
+		if activity != nil {
+			sb.WriteString("\n")
+			sb.WriteString(styles.Activity.Render(activity.String()))
+		}
+		if len(deployments) > 0 {
+			var ds []string
+			for _, deployment := range deployments {
+				ds = append(ds, deployment.Environment+" "+emojiForDeployment(deployment))
+			}
+			sb.WriteString("\n")
+			sb.WriteString(styles.Deployments.Render("deployments: " + strings.Join(ds, ", ")))
+		}
+		if bi.PreviewURL != "" {
+			sb.WriteString("\n")
+			sb.WriteString(styles.Preview.Render("preview: " + bi.PreviewURL))
+		}
+		if bi.Conflict != nil {
+			sb.WriteString("\n")
+			sb.WriteString(styles.Stale.Render(conflictSummary(bi.Conflict)))
+		}
+		if note != "" {
+			firstLine, _, _ := strings.Cut(note, "\n")
+			sb.WriteString("\n")
+			sb.WriteString(styles.Note.Render("note: " + firstLine))
+		}
 	}
 	return sb.String()
 }
 
+// conflictSummary returns the one-line summary of the conflict report of a branch.
+func conflictSummary(conflict *meta.BranchConflict) string {
+	s := "conflict with " + conflict.Parent
+	if len(conflict.Files) > 0 {
+		s += " in " + strings.Join(conflict.Files, ", ")
+	}
+	return s + " since " + humanize.Time(conflict.DetectedAt)
+}
+
+// branchActivity is the latest activity on a branch and its pull request.
+type branchActivity struct {
+	LastCommit time.Time
+	// The time of the last review of the pull request. Zero if unknown or not reviewed.
+	LastReview time.Time
+}
+
+// Last returns the time of the most recent activity.
+func (a *branchActivity) Last() time.Time {
+	if a.LastReview.After(a.LastCommit) {
+		return a.LastReview
+	}
+	return a.LastCommit
+}
+
+func (a *branchActivity) String() string {
+	s := "last commit " + humanize.Time(a.LastCommit)
+	if !a.LastReview.IsZero() {
+		s += ", last review " + humanize.Time(a.LastReview)
+	}
+	return s
+}
+
+// getBranchActivities returns the activity of the managed branches that exist locally and are
+// not merged yet. If includeReviews is true, the last review times of the pull requests are
+// queried from GitHub in a single query (per 100 pull requests).
+func getBranchActivities(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	includeReviews bool,
+) map[string]*branchActivity {
+	var client *gh.Client
+	if includeReviews {
+		var err error
+		client, err = getGitHubClient()
+		if err != nil && !errors.Is(err, errOffline) {
+			logrus.WithError(err).Warning("failed to create a GitHub client, ignoring review activity")
+		}
+	}
+
+	ret := map[string]*branchActivity{}
+	pullRequestIDs := map[string]string{}
+	for name, br := range tx.AllBranches() {
+		if br.MergeCommit != "" {
+			continue
+		}
+		ref, err := repo.GoGitRepo().Reference(plumbing.NewBranchReferenceName(name), true)
+		if err != nil {
+			continue
+		}
+		commit, err := repo.GoGitRepo().CommitObject(ref.Hash())
+		if err != nil {
+			continue
+		}
+		ret[name] = &branchActivity{LastCommit: commit.Committer.When}
+		if br.PullRequest != nil && br.PullRequest.ID != "" {
+			pullRequestIDs[name] = br.PullRequest.ID
+		}
+	}
+	if client == nil || len(pullRequestIDs) == 0 {
+		return ret
+	}
+	reviews, err := client.PullRequestsLastReviewActivity(
+		context.Background(), maps.Values(pullRequestIDs),
+	)
+	if err != nil {
+		logrus.WithError(err).Warning("failed to query the last review activity")
+		return ret
+	}
+	for name, id := range pullRequestIDs {
+		ret[name].LastReview = reviews[id]
+	}
+	return ret
+}
+
+// getBranchDeployments returns the latest deployments of the pull requests of the managed
+// branches that are not merged yet. The deployments are queried in a single query (per 100
+// pull requests).
+func getBranchDeployments(tx meta.ReadTx) map[string][]gh.Deployment {
+	client, err := getGitHubClient()
+	if err != nil {
+		if !errors.Is(err, errOffline) {
+			logrus.WithError(err).Warning("failed to create a GitHub client, ignoring deployments")
+		}
+		return nil
+	}
+	pullRequestIDs := map[string]string{}
+	for name, br := range tx.AllBranches() {
+		if br.MergeCommit != "" || br.PullRequest == nil || br.PullRequest.ID == "" {
+			continue
+		}
+		pullRequestIDs[name] = br.PullRequest.ID
+	}
+	if len(pullRequestIDs) == 0 {
+		return nil
+	}
+	deployments, err := client.PullRequestsDeployments(
+		context.Background(), maps.Values(pullRequestIDs),
+	)
+	if err != nil {
+		logrus.WithError(err).Warning("failed to query the deployments")
+		return nil
+	}
+	ret := map[string][]gh.Deployment{}
+	for name, id := range pullRequestIDs {
+		ret[name] = deployments[id]
+	}
+	return ret
+}
+
 type stackTreeBranchInfo struct {
 	BranchName      string
 	Deleted         bool
@@ -124,39 +517,86 @@ func getStackTreeBranchInfo(
 	if bi.PullRequest != nil && bi.PullRequest.Permalink != "" {
 		branchInfo.PullRequestLink = bi.PullRequest.Permalink
 	}
-	if _, err := repo.RevParse(&git.RevParse{Rev: branchName}); err != nil {
+	// The refs are read at once and cached, so that listing many branches doesn't run git for
+	// each of them.
+	refs, err := repo.Refs()
+	if err != nil {
+		logrus.WithError(err).Debug("failed to read the refs")
+		branchInfo.NeedSync = true
+		return &branchInfo
+	}
+	branchRef, ok := refs.Branch(branchName)
+	if !ok {
 		branchInfo.Deleted = true
 	}
 
-	parentHead, err := repo.RevParse(&git.RevParse{Rev: bi.Parent.Name})
-	if err != nil {
+	parentRef, ok := refs.Ref(bi.Parent.Name)
+	if !ok {
 		// The parent branch doesn't exist.
 		branchInfo.NeedSync = true
-	} else {
-		mergeBase, err := repo.MergeBase(parentHead, branchName)
-		if err != nil {
-			// The merge base doesn't exist. This is odd. Mark the branch as needing
-			// sync to see if we can fix this.
-			branchInfo.NeedSync = true
-		}
-		if mergeBase != parentHead {
+	} else if branchRef.OID != parentRef.OID {
+		containing, err := refs.BranchesContaining(parentRef.OID)
+		if err != nil || !containing[branchName] {
 			// This branch is not on top of the parent branch. Need sync.
 			branchInfo.NeedSync = true
 		}
 	}
 
-	upstreamExists, err := repo.DoesRemoteBranchExist(branchName)
-	if err != nil || !upstreamExists {
-		// Not pushed.
-		branchInfo.NeedSync = true
-	}
-	upstreamBranch := fmt.Sprintf("remotes/origin/%s", branchName)
-	upstreamDiff, err := repo.Diff(&git.DiffOpts{
-		Quiet:      true,
-		Specifiers: []string{branchName, upstreamBranch},
-	})
-	if err != nil || !upstreamDiff.Empty {
+	// The per-branch pushRemote is not looked up since it runs git for each branch.
+	upstreamRef, ok := refs.RemoteBranch(repo.GetPushRemoteName(), branchName)
+	if !ok || upstreamRef.Tree != branchRef.Tree {
+		// Not pushed, or the pushed branch has different contents.
 		branchInfo.NeedSync = true
 	}
 	return &branchInfo
 }
+
+func init() {
+	treeCmd.Flags().StringVar(
+		&treeFlags.Stale, "stale", "",
+		"only show stacks with branches that have been inactive for the given duration (e.g. 14d)",
+	)
+	treeCmd.Flags().BoolVar(
+		&treeFlags.Activity, "activity", false,
+		"show the time since the last commit of each branch",
+	)
+	treeCmd.Flags().BoolVar(
+		&treeFlags.Status, "status", false,
+		"show the deployment statuses of the pull requests",
+	)
+	treeCmd.Flags().StringVar(
+		&treeFlags.Prefix, "prefix", "",
+		"only show stacks with branches whose name starts with the given prefix",
+	)
+	treeCmd.Flags().BoolVar(
+		&treeFlags.Mine, "mine", false,
+		"only show stacks with branches in your branch namespace (pullRequest.branchNamePrefix)",
+	)
+	treeCmd.Flags().BoolVarP(
+		&treeFlags.Interactive, "interactive", "i", false,
+		"browse the tree interactively to check out, restack, or submit the branches",
+	)
+	treeCmd.Flags().StringVar(
+		&treeFlags.At, "at", "",
+		"show the tree as it was before an operation (see av undo --list) or at a time (e.g., 3d, 2006-01-02)",
+	)
+	addTreeFormatFlags(treeCmd)
+	treeCmd.MarkFlagsMutuallyExclusive("prefix", "mine")
+	for _, flag := range []string{"stale", "activity", "status", "prefix", "mine", "at", "format"} {
+		treeCmd.MarkFlagsMutuallyExclusive("interactive", flag)
+	}
+	for _, flag := range []string{"stale", "activity", "status", "prefix", "mine", "format"} {
+		treeCmd.MarkFlagsMutuallyExclusive("at", flag)
+	}
+}
+
+func addTreeFormatFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&treeFlags.Format, "format", treeFormatText,
+		"the output format (text, mermaid, or dot)",
+	)
+	cmd.Flags().BoolVar(
+		&treeFlags.CommitCounts, "commit-counts", false,
+		"label the edges with the number of the commits in each branch (with --format=mermaid or dot)",
+	)
+}
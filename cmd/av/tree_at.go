@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/stackutils"
+	"github.com/aviator-co/av/internal/utils/timeutils"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// runTreeAt shows the tree of the stacks as it was at a time in the past or before an operation
+// in the operation log (av tree --at).
+func runTreeAt(repo *git.Repo, tx meta.ReadTx, at string) error {
+	snapshot, when, err := readTreeSnapshot(repo, tx, at)
+	if err != nil {
+		return err
+	}
+	if len(snapshot.Branches) == 0 {
+		fmt.Fprint(os.Stderr, colors.Success("No branches were tracked by av "+when+".\n"))
+		return nil
+	}
+
+	styles := stackTreeStackBranchInfoStyles()
+	var ss []string
+	for _, node := range stackutils.BuildStackTreeFromBranches(
+		snapshot.Branches, snapshot.CurrentBranch, true,
+	) {
+		ss = append(ss, stackutils.RenderTree(node, func(branchName string, isTrunk bool) string {
+			sb := strings.Builder{}
+			sb.WriteString(styles.BranchName.Render(branchName))
+			if branchName == snapshot.CurrentBranch {
+				sb.WriteString(" (" + styles.HEAD.Render("HEAD") + ")")
+			}
+			if isTrunk {
+				return sb.String()
+			}
+			sb.WriteString("\n")
+			if oid, ok := snapshot.Refs[branchName]; ok {
+				sb.WriteString(styles.Activity.Render(oid[:7]))
+			} else {
+				sb.WriteString(styles.Activity.Render("commit unknown"))
+			}
+			if pr := snapshot.Branches[branchName].PullRequest; pr != nil && pr.Number != 0 {
+				sb.WriteString(styles.Activity.Render(fmt.Sprintf(
+					" #%d %s", pr.Number, strings.ToLower(string(pr.State)),
+				)))
+			}
+			return sb.String()
+		}))
+	}
+	fmt.Fprint(os.Stderr, colors.Faint("The stacks "+when+":\n"))
+	fmt.Print(lipgloss.NewStyle().MarginTop(1).MarginBottom(1).Render(
+		lipgloss.JoinVertical(0, ss...),
+	) + "\n")
+	return nil
+}
+
+// readTreeSnapshot reads the state of the stacks for av tree --at. The argument is the number
+// of an operation (as listed by av undo --list), a duration before now (e.g., 3d), or a date and
+// time in the local time zone (2006-01-02 or 2006-01-02 15:04). The description of the time is
+// returned too.
+func readTreeSnapshot(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	at string,
+) (*oplog.Snapshot, string, error) {
+	if n, err := strconv.Atoi(at); err == nil {
+		snapshot, err := oplog.SnapshotBefore(repo, n)
+		if err != nil {
+			return nil, "", err
+		}
+		return snapshot, fmt.Sprintf(
+			"before %s at %s", snapshot.Before.Command,
+			snapshot.Before.Time.Local().Format(time.DateTime),
+		), nil
+	}
+
+	t, ok := parseSnapshotTime(at)
+	if !ok {
+		return nil, "", errors.Errorf(
+			"invalid --at %q (expected an operation number, a duration, or a date)", at,
+		)
+	}
+	if t.After(time.Now()) {
+		return nil, "", errors.Errorf("%q is in the future", at)
+	}
+	snapshot, err := oplog.SnapshotAt(repo, tx, t)
+	if err != nil {
+		return nil, "", err
+	}
+	return snapshot, "at " + t.Format(time.DateTime), nil
+}
+
+// parseSnapshotTime parses a duration before now (e.g., 3d) or a date and time in the local
+// time zone (2006-01-02 or 2006-01-02 15:04).
+func parseSnapshotTime(at string) (time.Time, bool) {
+	if d, err := timeutils.ParseDuration(at); err == nil {
+		return time.Now().Add(-d), true
+	}
+	for _, layout := range []string{time.DateOnly, "2006-01-02 15:04", time.DateTime} {
+		if t, err := time.ParseInLocation(layout, at, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/stackutils"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-isatty"
+	"github.com/shurcooL/githubv4"
+)
+
+var treeInteractiveKeys = []key.Binding{
+	key.NewBinding(
+		key.WithKeys("up", "k", "ctrl+p"),
+		key.WithHelp("↑/k", "move up"),
+	),
+	key.NewBinding(
+		key.WithKeys("down", "j", "ctrl+n"),
+		key.WithHelp("↓/j", "move down"),
+	),
+	key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "check out"),
+	),
+	key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "restack"),
+	),
+	key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "submit"),
+	),
+	key.NewBinding(
+		key.WithKeys("q", "ctrl+c"),
+		key.WithHelp("q", "quit"),
+	),
+}
+
+// runTreeInteractive shows the stack tree in an interactive browser.
+func runTreeInteractive(repo *git.Repo) error {
+	if err := uiutils.RequireInteractive("av tree --interactive", ""); err != nil {
+		return err
+	}
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		return errors.New("tree --interactive must be run in a terminal")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.WrapIf(err, "failed to find the av executable")
+	}
+	vm := &treeInteractiveViewModel{
+		repo:    repo,
+		exe:     exe,
+		help:    help.New(),
+		spinner: spinner.New(spinner.WithSpinner(spinner.Dot)),
+	}
+	if err := vm.load(); err != nil {
+		return err
+	}
+	return uiutils.RunBubbleTea(vm)
+}
+
+type treeInteractiveViewModel struct {
+	repo *git.Repo
+	// The path to the av executable to run the restack and submit commands.
+	exe string
+
+	help    help.Model
+	spinner spinner.Model
+
+	currentHEADBranch   string
+	currentChosenBranch string
+	rootNodes           []*stackutils.StackTreeNode
+	branchList          []*stackTreeBranchInfo
+	branches            map[string]*stackTreeBranchInfo
+	// The pull request IDs of the branches to query the statuses.
+	pullRequestIDs map[string]string
+
+	loadingStatuses bool
+	statuses        map[string]*gh.PullRequestStatus
+	statusErr       error
+
+	// The message of the last action (e.g., "Checked out branch foo").
+	message string
+	running bool
+	err     error
+}
+
+type treeInteractiveStatusesMsg struct {
+	statuses map[string]*gh.PullRequestStatus
+	err      error
+}
+
+type treeInteractiveCheckoutDoneMsg struct{}
+
+type treeInteractiveCommandDoneMsg struct {
+	command string
+	err     error
+}
+
+// load reads the branches from the av database. This is called again after running a
+// restack or a submit because they update the branches.
+func (vm *treeInteractiveViewModel) load() error {
+	db, err := getDB(vm.repo)
+	if err != nil {
+		return err
+	}
+	currentBranch, err := vm.repo.CurrentBranchName()
+	if err != nil {
+		return err
+	}
+	tx := db.ReadTx()
+	rootNodes := stackutils.BuildStackTreeAllBranches(tx, currentBranch, true)
+	var branchList []*stackTreeBranchInfo
+	branches := map[string]*stackTreeBranchInfo{}
+	for _, node := range rootNodes {
+		branchList = append(branchList, switchBranchList(vm.repo, tx, branches, node)...)
+	}
+	if len(branchList) == 0 {
+		return errors.New("no branches found")
+	}
+	pullRequestIDs := map[string]string{}
+	for name, br := range tx.AllBranches() {
+		if br.MergeCommit == "" && br.PullRequest != nil && br.PullRequest.ID != "" {
+			pullRequestIDs[name] = br.PullRequest.ID
+		}
+	}
+
+	vm.currentHEADBranch = currentBranch
+	if _, ok := branches[vm.currentChosenBranch]; !ok {
+		vm.currentChosenBranch = getInitialChosenBranch(branchList, currentBranch)
+	}
+	vm.rootNodes = rootNodes
+	vm.branchList = branchList
+	vm.branches = branches
+	vm.pullRequestIDs = pullRequestIDs
+	return nil
+}
+
+func (vm *treeInteractiveViewModel) Init() tea.Cmd {
+	vm.loadingStatuses = true
+	return tea.Batch(vm.spinner.Tick, vm.fetchStatuses)
+}
+
+func (vm *treeInteractiveViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case error:
+		vm.err = msg
+		return vm, tea.Quit
+	case treeInteractiveStatusesMsg:
+		vm.loadingStatuses = false
+		vm.statuses = msg.statuses
+		vm.statusErr = msg.err
+	case treeInteractiveCheckoutDoneMsg:
+		vm.running = false
+		vm.currentHEADBranch = vm.currentChosenBranch
+		vm.message = "Checked out branch " + vm.currentChosenBranch
+	case treeInteractiveCommandDoneMsg:
+		vm.running = false
+		if msg.err != nil {
+			vm.message = colors.Failure(msg.command + " failed")
+		} else {
+			vm.message = colors.Success(msg.command + " finished")
+		}
+		if err := vm.load(); err != nil {
+			vm.err = err
+			return vm, tea.Quit
+		}
+		vm.loadingStatuses = true
+		return vm, vm.fetchStatuses
+	case tea.KeyMsg:
+		if vm.running {
+			return vm, nil
+		}
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			return vm, tea.Quit
+		case "up", "k", "ctrl+p":
+			vm.currentChosenBranch = vm.getPreviousBranch()
+		case "down", "j", "ctrl+n":
+			vm.currentChosenBranch = vm.getNextBranch()
+		case "enter", " ":
+			vm.running = true
+			vm.message = ""
+			return vm, vm.checkoutBranch
+		case "r":
+			vm.running = true
+			vm.message = ""
+			return vm, vm.runAv("av restack", "restack")
+		case "s":
+			vm.running = true
+			vm.message = ""
+			return vm, vm.runAv("av pr --all --current", "pr", "--all", "--current")
+		}
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		vm.spinner, cmd = vm.spinner.Update(msg)
+		return vm, cmd
+	}
+	return vm, nil
+}
+
+// fetchStatuses queries the statuses of the pull requests from GitHub.
+func (vm *treeInteractiveViewModel) fetchStatuses() tea.Msg {
+	if len(vm.pullRequestIDs) == 0 {
+		return treeInteractiveStatusesMsg{}
+	}
+	client, err := getGitHubClient()
+	if err != nil {
+		return treeInteractiveStatusesMsg{err: err}
+	}
+	statuses := map[string]*gh.PullRequestStatus{}
+	for name, id := range vm.pullRequestIDs {
+		status, err := client.PullRequestStatus(context.Background(), id)
+		if err != nil {
+			return treeInteractiveStatusesMsg{statuses: statuses, err: err}
+		}
+		statuses[name] = status
+	}
+	return treeInteractiveStatusesMsg{statuses: statuses}
+}
+
+func (vm *treeInteractiveViewModel) checkoutBranch() tea.Msg {
+	if err := vm.checkout(); err != nil {
+		return err
+	}
+	return treeInteractiveCheckoutDoneMsg{}
+}
+
+func (vm *treeInteractiveViewModel) checkout() error {
+	if vm.currentChosenBranch == vm.currentHEADBranch {
+		return nil
+	}
+	_, err := vm.repo.CheckoutBranch(&git.CheckoutBranch{Name: vm.currentChosenBranch})
+	return err
+}
+
+// runAv checks out the chosen branch and runs av with the given arguments on it. The browser
+// is suspended while the command is running.
+func (vm *treeInteractiveViewModel) runAv(name string, args ...string) tea.Cmd {
+	if err := vm.checkout(); err != nil {
+		return func() tea.Msg { return err }
+	}
+	vm.currentHEADBranch = vm.currentChosenBranch
+	cmd := exec.Command(vm.exe, args...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return treeInteractiveCommandDoneMsg{command: name, err: err}
+	})
+}
+
+func (vm *treeInteractiveViewModel) getPreviousBranch() string {
+	for i, branch := range vm.branchList {
+		if branch.BranchName == vm.currentChosenBranch {
+			if i == 0 {
+				return vm.currentChosenBranch
+			}
+			return vm.branchList[i-1].BranchName
+		}
+	}
+	return vm.currentChosenBranch
+}
+
+func (vm *treeInteractiveViewModel) getNextBranch() string {
+	for i, branch := range vm.branchList {
+		if branch.BranchName == vm.currentChosenBranch {
+			if i == len(vm.branchList)-1 {
+				return vm.currentChosenBranch
+			}
+			return vm.branchList[i+1].BranchName
+		}
+	}
+	return vm.currentChosenBranch
+}
+
+func (vm *treeInteractiveViewModel) View() string {
+	var ss []string
+	for _, node := range vm.rootNodes {
+		ss = append(
+			ss,
+			stackutils.RenderTree(node, func(branchName string, isTrunk bool) string {
+				out := vm.renderBranchInfo(vm.branches[branchName], branchName, isTrunk)
+				if branchName == vm.currentChosenBranch {
+					out = colors.PromptChoice.Render(out)
+				}
+				return out
+			}),
+		)
+	}
+	ss = append(ss, "")
+	if vm.loadingStatuses {
+		ss = append(ss, colors.ProgressStyle.Render(vm.spinner.View()+"Loading the pull request statuses..."))
+	} else if vm.statusErr != nil {
+		ss = append(ss, colors.Faint("Failed to load the pull request statuses: "+vm.statusErr.Error()))
+	}
+	if vm.message != "" {
+		ss = append(ss, vm.message)
+	}
+	if !vm.running {
+		ss = append(ss, vm.help.ShortHelpView(treeInteractiveKeys))
+	}
+
+	ret := lipgloss.NewStyle().MarginTop(1).MarginBottom(1).MarginLeft(2).Render(
+		lipgloss.JoinVertical(0, ss...),
+	) + "\n"
+	if vm.err != nil {
+		ret += renderError(vm.err)
+	}
+	return ret
+}
+
+func (vm *treeInteractiveViewModel) renderBranchInfo(
+	stbi *stackTreeBranchInfo,
+	branchName string,
+	isTrunk bool,
+) string {
+	line := branchName
+	var stats []string
+	if branchName == vm.currentHEADBranch {
+		stats = append(stats, "HEAD")
+	}
+	if stbi != nil && stbi.NeedSync && !isTrunk {
+		stats = append(stats, "needs sync")
+	}
+	if len(stats) > 0 {
+		line += " (" + strings.Join(stats, ", ") + ")"
+	}
+
+	ss := []string{line}
+	if !isTrunk {
+		if stbi != nil && stbi.PullRequestLink != "" {
+			ss = append(ss, stbi.PullRequestLink)
+		} else {
+			ss = append(ss, "No pull request")
+		}
+		if status := vm.statuses[branchName]; status != nil {
+			ss = append(ss, pullRequestStatusString(status))
+		}
+	}
+	return strings.Join(ss, "\n")
+}
+
+func pullRequestStatusString(status *gh.PullRequestStatus) string {
+	state := strings.ToLower(string(status.State))
+	if status.State == githubv4.PullRequestStateOpen && status.IsDraft {
+		state = "draft"
+	}
+	var checks string
+	switch {
+	case status.CheckState == "":
+		checks = "no checks"
+	case status.ChecksSucceeded():
+		checks = "checks \u2705"
+	case status.ChecksFailed():
+		checks = "checks \u274C"
+	default:
+		checks = "checks \u231B"
+	}
+	return state + ", " + checks
+}
+
+func (vm *treeInteractiveViewModel) ExitError() error {
+	if vm.err != nil {
+		return actions.ErrExitSilently{ExitCode: 1}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/oplog"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/spf13/cobra"
+)
+
+var undoFlags struct {
+	Count int
+	List  bool
+}
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Undo the last av sync, reorder, or reparent",
+	Long: strings.TrimSpace(`
+Undo the last av sync, av reorder, or av reparent.
+
+Before these commands rewrite the branches, av records the commits of the branches
+and the av metadata to the operation log. av undo resets the branches and the
+metadata to the state before the last operation (or the last N operations with
+--count). The commits made after the operation are discarded from the branches
+(they are still available in the Git reflog).
+
+Use --list to show the recorded operations.
+`),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+
+		if undoFlags.List {
+			entries, err := oplog.Read(repo)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				fmt.Fprint(os.Stderr, colors.Faint("No operations are recorded.\n"))
+				return nil
+			}
+			for i := len(entries) - 1; i >= 0; i-- {
+				fmt.Fprintf(os.Stdout, "%d\t%s\t%s\n",
+					len(entries)-i, entries[i].Time.Format("2006-01-02 15:04:05"), entries[i].Command,
+				)
+			}
+			return nil
+		}
+
+		if _, inProgress := repo.InProgressStateFile(); inProgress {
+			return errors.New(
+				"an av operation is in progress; continue or abort it before running av undo",
+			)
+		}
+		status, err := repo.Status()
+		if err != nil {
+			return err
+		}
+		if !status.IsCleanIgnoringUntracked() {
+			return errutils.WithFailureClass(errors.New(
+				"the working tree has uncommitted changes; commit or stash them before running av undo",
+			), errutils.FailureDirtyWorktree)
+		}
+
+		undone, err := oplog.Undo(repo, db, undoFlags.Count)
+		if err != nil {
+			return err
+		}
+		for _, entry := range undone {
+			fmt.Fprint(os.Stderr,
+				colors.Success("Undid "), colors.CliCmd(entry.Command),
+				colors.Success(" run at "+entry.Time.Format("2006-01-02 15:04:05")+".\n"),
+			)
+		}
+		return nil
+	},
+}
+
+func init() {
+	undoCmd.Flags().IntVarP(
+		&undoFlags.Count, "count", "n", 1,
+		"the number of operations to undo",
+	)
+	undoCmd.Flags().BoolVar(
+		&undoFlags.List, "list", false,
+		"list the recorded operations instead of undoing them",
+	)
+	undoCmd.MarkFlagsMutuallyExclusive("count", "list")
+}
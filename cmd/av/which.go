@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var whichFlags struct {
+	Branch string
+}
+
+var whichCmd = &cobra.Command{
+	Use:   "which <path>[:<line>[-<line>]] [--branch=<branch>] [--json]",
+	Short: "Show which branch in the stack last changed a file or lines",
+	Long: strings.TrimSpace(`
+Show which branch in the current stack (and which commit) last changed a file or
+a range of lines, e.g., to find the branch to fix when review feedback comes in.
+
+For a file, the branch and the commit that last changed the file are shown. For
+lines (e.g., main.go:42 or main.go:40-50), each group of lines is attributed to
+the branch of the commit that last changed it (as git blame does). The lines
+that are not changed in the stack are reported as coming from the trunk.
+
+The file is looked at as of the current branch (or --branch), and only the
+branches below it are considered. This works locally from the commits of the
+branches.
+`),
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		tx := db.ReadTx()
+		branch := whichFlags.Branch
+		if branch == "" {
+			if branch, err = repo.CurrentBranchName(); err != nil {
+				return err
+			}
+		}
+		if _, ok := tx.Branch(branch); !ok {
+			return actions.BranchNotAdoptedError{Branch: branch}
+		}
+		path, start, end, err := parseWhichArg(args[0])
+		if err != nil {
+			return err
+		}
+		if path, err = repoRelativePath(repo, path); err != nil {
+			return err
+		}
+
+		owners, err := stackCommitBranches(repo, tx, branch)
+		if err != nil {
+			return err
+		}
+		var results []whichResult
+		if start == 0 {
+			results, err = whichFile(repo, branch, path, owners)
+		} else {
+			results, err = whichLines(repo, branch, path, start, end, owners)
+		}
+		if err != nil {
+			return err
+		}
+		if jsonOutput() {
+			if results == nil {
+				results = []whichResult{}
+			}
+			return printJSON(results)
+		}
+		printWhichResults(path, results)
+		return nil
+	},
+}
+
+// whichResult is the branch and the commit that last changed the file or the lines.
+type whichResult struct {
+	// The line range (1-based and inclusive). Zero for the whole file.
+	StartLine int `json:"startLine,omitempty"`
+	EndLine   int `json:"endLine,omitempty"`
+	// The branch in the stack, or empty if the change is not in the stack (e.g., in the trunk).
+	Branch  string `json:"branch,omitempty"`
+	Commit  string `json:"commit"`
+	Subject string `json:"subject"`
+}
+
+var whichLinePattern = regexp.MustCompile(`^(.+):(\d+)(?:-(\d+))?$`)
+
+// parseWhichArg parses "<path>", "<path>:<line>", or "<path>:<start>-<end>". The lines are zero
+// for a path without lines.
+func parseWhichArg(arg string) (path string, start int, end int, err error) {
+	m := whichLinePattern.FindStringSubmatch(arg)
+	if m == nil {
+		return arg, 0, 0, nil
+	}
+	start, _ = strconv.Atoi(m[2])
+	end = start
+	if m[3] != "" {
+		end, _ = strconv.Atoi(m[3])
+	}
+	if start < 1 || end < start {
+		return "", 0, 0, errors.Errorf("invalid line range in %q", arg)
+	}
+	return m[1], start, end, nil
+}
+
+// repoRelativePath returns the path relative to the root of the repository (with slashes) of a
+// path relative to the current directory.
+func repoRelativePath(repo *git.Repo, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// The repository directory may be a symlink (e.g., /tmp on macOS).
+	root, err := filepath.EvalSymlinks(repo.Dir())
+	if err != nil {
+		return "", err
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		abs = filepath.Join(dir, filepath.Base(abs))
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.Errorf("%q is outside the repository", path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// stackCommitBranches returns the branch of each commit in the branch and the branches below
+// it, keyed by the commit hash.
+func stackCommitBranches(repo *git.Repo, tx meta.ReadTx, branch string) (map[string]string, error) {
+	names, err := meta.PreviousBranches(tx, branch)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]string{}
+	for _, name := range append(names, branch) {
+		br, _ := tx.Branch(name)
+		out, err := repo.Git("rev-list", branchComparisonBase(repo, br)+".."+name, "--")
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to list the commits of %q", name)
+		}
+		for _, commit := range strings.Fields(out) {
+			ret[commit] = name
+		}
+	}
+	return ret, nil
+}
+
+// whichFile returns the branch and the commit that last changed the file as of the branch.
+func whichFile(
+	repo *git.Repo,
+	branch string,
+	path string,
+	owners map[string]string,
+) ([]whichResult, error) {
+	out, err := repo.Git("log", "-1", "--format=%H", branch, "--", path)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, errors.Errorf("%q is not in branch %q", path, branch)
+	}
+	subject, err := repo.Git("log", "-1", "--format=%s", out)
+	if err != nil {
+		return nil, err
+	}
+	return []whichResult{{Branch: owners[out], Commit: out, Subject: subject}}, nil
+}
+
+// whichLines returns the branches and the commits that last changed the lines as of the branch.
+// The adjacent lines changed by the same commit are grouped.
+func whichLines(
+	repo *git.Repo,
+	branch string,
+	path string,
+	start, end int,
+	owners map[string]string,
+) ([]whichResult, error) {
+	blame, err := repo.BlameLines(branch, path)
+	if err != nil {
+		return nil, err
+	}
+	if start > len(blame) {
+		return nil, errors.Errorf("%q has only %d lines in branch %q", path, len(blame), branch)
+	}
+	end = min(end, len(blame))
+	var ret []whichResult
+	subjects := map[string]string{}
+	for line := start; line <= end; line++ {
+		commit := blame[line-1]
+		if n := len(ret); n > 0 && ret[n-1].Commit == commit {
+			ret[n-1].EndLine = line
+			continue
+		}
+		if _, ok := subjects[commit]; !ok {
+			if subjects[commit], err = repo.Git("log", "-1", "--format=%s", commit); err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, whichResult{
+			StartLine: line,
+			EndLine:   line,
+			Branch:    owners[commit],
+			Commit:    commit,
+			Subject:   subjects[commit],
+		})
+	}
+	return ret, nil
+}
+
+func printWhichResults(path string, results []whichResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		location := path
+		if r.StartLine == r.EndLine && r.StartLine != 0 {
+			location = fmt.Sprintf("%s:%d", path, r.StartLine)
+		} else if r.StartLine != 0 {
+			location = fmt.Sprintf("%s:%d-%d", path, r.StartLine, r.EndLine)
+		}
+		branch := colors.UserInput(r.Branch)
+		if r.Branch == "" {
+			branch = colors.Faint("(not changed in the stack)")
+		}
+		fmt.Fprint(w,
+			location, "\t", branch, "\t", colors.Faint(git.ShortSha(r.Commit)), " ", r.Subject, "\n",
+		)
+	}
+	_ = w.Flush()
+}
+
+func init() {
+	whichCmd.Flags().StringVar(
+		&whichFlags.Branch, "branch", "",
+		"look at the file as of the branch (default: the current branch)",
+	)
+	_ = whichCmd.RegisterFlagCompletionFunc("branch", branchNameArgs)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// workspaceFileName is the name of the workspace file that is searched for in the current
+// directory and its parents.
+const workspaceFileName = "av-workspace.yml"
+
+var workspaceFlags struct {
+	File string
+}
+
+// workspace is a group of repositories that av operates on at once.
+type workspace struct {
+	// The paths of the repositories. Relative paths are relative to the directory of the
+	// workspace file.
+	Repos []string
+}
+
+var workspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "Run av across the repositories of a workspace",
+	Long: strings.TrimSpace(`
+Run av across the repositories of a workspace.
+
+A workspace is a group of related repositories that a logical change is split
+across. The repositories are listed in an av-workspace.yml file, which is
+searched for in the current directory and its parents:
+
+    repos:
+      - backend
+      - ../frontend
+
+Relative paths are relative to the directory of the workspace file.
+`),
+}
+
+var workspaceSyncCmd = &cobra.Command{
+	Use:          "sync [-- <sync-flags>...]",
+	Short:        "Run av sync in each repository of the workspace",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runInWorkspace(append([]string{"sync"}, args...))
+	},
+}
+
+var workspaceTreeCmd = &cobra.Command{
+	Use:          "tree [-- <tree-flags>...]",
+	Short:        "Run av tree in each repository of the workspace",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runInWorkspace(append([]string{"tree"}, args...))
+	},
+}
+
+var workspaceStatusCmd = &cobra.Command{
+	Use:          "status",
+	Short:        "Show the current branch and the uncommitted changes of each repository",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ws, err := loadWorkspace()
+		if err != nil {
+			return err
+		}
+		for _, dir := range ws.Repos {
+			out, err := git.Command(dir, "status", "--porcelain", "--branch").Output()
+			if err != nil {
+				fmt.Fprint(os.Stdout,
+					colors.UserInput(dir), ": ", colors.Failure("not a Git repository"), "\n",
+				)
+				continue
+			}
+			lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+			branch := strings.TrimPrefix(lines[0], "## ")
+			fmt.Fprint(os.Stdout, colors.UserInput(dir), ": ", branch)
+			if changes := len(lines) - 1; changes > 0 {
+				fmt.Fprint(os.Stdout, colors.Warning(fmt.Sprintf(", %d uncommitted change(s)", changes)))
+			}
+			fmt.Fprint(os.Stdout, "\n")
+		}
+		return nil
+	},
+}
+
+// runInWorkspace runs av with the given arguments in each repository of the workspace. It
+// continues with the other repositories if the command fails in one of them, and reports the
+// failed repositories at the end.
+func runInWorkspace(args []string) error {
+	ws, err := loadWorkspace()
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.WrapIf(err, "failed to find the av executable")
+	}
+
+	var failed []string
+	for i, dir := range ws.Repos {
+		if i > 0 {
+			fmt.Fprint(os.Stderr, "\n")
+		}
+		fmt.Fprint(os.Stderr, colors.Faint("==> "), colors.UserInput(dir), "\n")
+		cmd := exec.Command(exe, append([]string{"--repo", dir}, args...)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			failed = append(failed, dir)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	fmt.Fprint(os.Stderr,
+		"\n", colors.Failure("av "+args[0]+" failed in "), colors.UserInput(len(failed)),
+		colors.Failure(" of "), colors.UserInput(len(ws.Repos)), colors.Failure(" repositories:\n"),
+	)
+	for _, dir := range failed {
+		fmt.Fprint(os.Stderr, "  - ", colors.UserInput(dir), "\n")
+	}
+	return actions.ErrExitSilently{ExitCode: 1}
+}
+
+// loadWorkspace reads the workspace file given by --file or found in the current directory or
+// its parents. The repository paths are resolved to absolute paths.
+func loadWorkspace() (*workspace, error) {
+	path := workspaceFlags.File
+	if path == "" {
+		var err error
+		path, err = findWorkspaceFile()
+		if err != nil {
+			return nil, err
+		}
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, errors.WrapIff(err, "failed to read the workspace file %q", path)
+	}
+	var ws workspace
+	if err := v.Unmarshal(&ws); err != nil {
+		return nil, errors.WrapIff(err, "failed to parse the workspace file %q", path)
+	}
+	if len(ws.Repos) == 0 {
+		return nil, errors.Errorf("no repositories are listed in the workspace file %q", path)
+	}
+	baseDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	for i, dir := range ws.Repos {
+		if !filepath.IsAbs(dir) {
+			ws.Repos[i] = filepath.Join(baseDir, dir)
+		}
+	}
+	return &ws, nil
+}
+
+func findWorkspaceFile() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, workspaceFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.Errorf(
+				"%s not found in the current directory or its parents (use --file to specify it)",
+				workspaceFileName,
+			)
+		}
+		dir = parent
+	}
+}
+
+func init() {
+	workspaceCmd.PersistentFlags().StringVar(
+		&workspaceFlags.File, "file", "",
+		"the workspace file (default: "+workspaceFileName+" in the current directory or its parents)",
+	)
+	workspaceCmd.AddCommand(
+		workspaceStatusCmd,
+		workspaceSyncCmd,
+		workspaceTreeCmd,
+	)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/spf13/cobra"
+)
+
+var worktreeCmd = &cobra.Command{
+	Use:   "worktree",
+	Short: "Manage the worktrees of the stack branches",
+	Long: strings.TrimSpace(`
+Manage the worktrees of the stack branches.
+
+All the worktrees of a repository share the av metadata, so the stacks can be
+worked on from any of them. A branch that is checked out in one worktree cannot
+be checked out or restacked from another one.
+`),
+}
+
+var worktreeAddCmd = &cobra.Command{
+	Use:   "add <branch> [<path>]",
+	Short: "Create a worktree for a stack branch",
+	Long: strings.TrimSpace(`
+Create a linked worktree (see git worktree add) with the given stack branch
+checked out.
+
+The worktree is created at the given path, or next to the main worktree as
+<repository>-<branch> by default.
+`),
+	Args:         cobra.RangeArgs(1, 2),
+	SilenceUsage: true,
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return branchNameArgs(cmd, args, toComplete)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, err := getRepo()
+		if err != nil {
+			return err
+		}
+		db, err := getDB(repo)
+		if err != nil {
+			return err
+		}
+		branch := stripRemoteRefPrefixes(repo, args[0])
+		if _, exists := db.ReadTx().Branch(branch); !exists {
+			return errors.Errorf(
+				"branch %q is not adopted to av (use git worktree add for the other branches)",
+				branch,
+			)
+		}
+		path, err := repo.BranchWorktree(branch)
+		if err != nil {
+			return err
+		}
+		if path != "" {
+			return errors.Errorf("branch %q is already checked out in the worktree at %s", branch, path)
+		}
+
+		if len(args) > 1 {
+			path, err = filepath.Abs(args[1])
+			if err != nil {
+				return err
+			}
+		} else {
+			path = defaultWorktreePath(repo, branch)
+		}
+		if _, err := repo.Run(&git.RunOpts{
+			Args:      []string{"worktree", "add", path, branch},
+			ExitError: true,
+		}); err != nil {
+			return errors.WrapIff(err, "failed to create the worktree for %q", branch)
+		}
+		fmt.Fprint(os.Stderr,
+			colors.Success("Created the worktree for "), colors.UserInput(branch),
+			colors.Success(" at "), colors.UserInput(path), "\n",
+		)
+		return nil
+	},
+}
+
+// defaultWorktreePath returns the path of the new worktree for the branch, which is next to the
+// main worktree (or the bare repository) and named after the repository and the branch.
+func defaultWorktreePath(repo *git.Repo, branch string) string {
+	dir := filepath.Clean(repo.Dir())
+	if repo.IsLinkedWorktree() {
+		// The common git directory is the .git directory of the main worktree, or the bare
+		// repository.
+		dir = filepath.Clean(repo.GitDir())
+		if filepath.Base(dir) == ".git" {
+			dir = filepath.Dir(dir)
+		}
+	}
+	name := strings.TrimSuffix(filepath.Base(dir), ".git")
+	return filepath.Join(filepath.Dir(dir), name+"-"+strings.ReplaceAll(branch, "/", "-"))
+}
+
+func init() {
+	worktreeCmd.AddCommand(worktreeAddCmd)
+}
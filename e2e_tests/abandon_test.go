@@ -0,0 +1,94 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAbandon(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two -> three
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	twoHead := repo.CommitFile(t, "two.txt", "two-b")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three")
+	repo.Git(t, "push", "origin", "one", "two", "three")
+
+	body := "<!-- av pr metadata\n```\n{}\n```\n-->"
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main", Body: body},
+		mockPR{ID: "nodeid-2", Number: 2, State: "OPEN", HeadRefName: "two", BaseRefName: "one", Body: body},
+	)
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	for _, pr := range server.pulls {
+		br, _ := tx.Branch(pr.HeadRefName)
+		br.PullRequest = &meta.PullRequest{ID: pr.ID, Number: int64(pr.Number), State: "OPEN"}
+		tx.SetBranch(br)
+	}
+	require.NoError(t, tx.Commit())
+
+	// main -> two -> three
+	repo.CheckoutBranch(t, "refs/heads/one")
+	RequireAv(t, "abandon", "--reason", "Not needed.")
+
+	require.Equal(t, plumbing.NewBranchReferenceName("main"), repo.CurrentBranch(t))
+	_, err := repo.GoGit.Reference(plumbing.NewBranchReferenceName("one"), false)
+	require.Error(t, err, "the abandoned branch should be deleted")
+	_, err = repo.GoGit.Reference(plumbing.NewRemoteReferenceName("origin", "one"), false)
+	require.Error(t, err, "the abandoned branch should be deleted on the remote")
+	_, exists := repo.OpenDB(t).ReadTx().Branch("one")
+	require.False(t, exists)
+
+	require.Equal(t, "main", GetStoredParentBranchState(t, repo, "two").Name)
+	require.True(t, GetStoredParentBranchState(t, repo, "two").Trunk)
+	require.Equal(t, "two", GetStoredParentBranchState(t, repo, "three").Name)
+	require.Equal(t, "three.txt\ntwo.txt\n", repo.Git(
+		t, "ls-tree", "--name-only", "three", "--", "one.txt", "two.txt", "three.txt",
+	), "three should no longer have the commits of one")
+
+	require.Equal(t, "CLOSED", server.pulls[0].State)
+	require.Equal(t,
+		[]string{"This pull request was abandoned.\n\nReason: Not needed."},
+		server.pulls[0].Comments,
+	)
+	require.Equal(t, "main", server.pulls[1].BaseRefName)
+
+	RequireAv(t, "undo")
+	require.Equal(t, twoHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two")))
+	require.Equal(t, "one", GetStoredParentBranchState(t, repo, "two").Name)
+	require.Equal(t, plumbing.NewBranchReferenceName("one"), repo.CurrentBranch(t))
+}
+
+func TestAbandonConflict(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two, where two changes the file added by one.
+	RequireAv(t, "branch", "one")
+	oneHead := repo.CommitFile(t, "file.txt", "one")
+	RequireAv(t, "branch", "two")
+	twoHead := repo.CommitFile(t, "file.txt", "two")
+
+	out := Av(t, "abandon", "one")
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Contains(t, out.Stderr, "conflicts")
+
+	// Nothing is changed.
+	require.Equal(t, plumbing.NewBranchReferenceName("two"), repo.CurrentBranch(t))
+	require.Equal(t, oneHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")))
+	require.Equal(t, twoHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two")))
+	require.Equal(t, "one", GetStoredParentBranchState(t, repo, "two").Name)
+	require.Equal(t, "", repo.Git(t, "status", "--porcelain"))
+}
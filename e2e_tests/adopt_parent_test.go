@@ -0,0 +1,51 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/treedetector"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdopt_SetParent(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main: root_commit
+	// stack-1: root_commit -> 1a -> 1b
+	// stack-2: root_commit -> 1a -> 1b -> 2a
+	repo.Git(t, "checkout", "-b", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	repo.CommitFile(t, "my-file", "1b\n", gittest.WithMessage("Commit 1b"))
+	repo.Git(t, "checkout", "-b", "stack-2")
+	repo.CommitFile(t, "my-file", "2a\n", gittest.WithMessage("Commit 2a"))
+	repo.Git(t, "switch", "main")
+
+	stack1 := plumbing.NewBranchReferenceName("stack-1")
+	stack2 := plumbing.NewBranchReferenceName("stack-2")
+	main := plumbing.NewBranchReferenceName("main")
+	avRepo := repo.AsAvGitRepo()
+	pieces, err := treedetector.DetectBranches(avRepo, []plumbing.ReferenceName{stack1, stack2})
+	require.NoError(t, err)
+	piece := pieces[stack2]
+	require.Equal(t, stack1, piece.Parent)
+	require.Len(t, piece.IncludedCommits, 1)
+
+	// Choosing the trunk as the parent (the "p" key of av adopt) includes the commits of
+	// stack-1 in stack-2.
+	require.NoError(t, treedetector.SetParent(avRepo, piece, main, true))
+	require.Equal(t, main, piece.Parent)
+	require.True(t, piece.ParentIsTrunk)
+	require.Equal(t, repo.GetCommitAtRef(t, main), piece.ParentMergeBase)
+	require.Len(t, piece.IncludedCommits, 3)
+
+	// Choosing stack-1 again restores the detected parent.
+	require.NoError(t, treedetector.SetParent(avRepo, piece, stack1, false))
+	require.Equal(t, stack1, piece.Parent)
+	require.False(t, piece.ParentIsTrunk)
+	require.Equal(t, repo.GetCommitAtRef(t, stack1), piece.ParentMergeBase)
+	require.Len(t, piece.IncludedCommits, 1)
+	require.Equal(t, repo.GetCommitAtRef(t, stack2), piece.IncludedCommits[0].Hash)
+}
@@ -1,9 +1,11 @@
 package e2e_tests
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAdopt_Success_NoStackRoot(t *testing.T) {
@@ -62,3 +64,45 @@ func TestAdopt_Success_WithStackRoot(t *testing.T) {
 	repo.Git(t, "switch", "stack-1")
 	RequireAv(t, "adopt", "--dry-run")
 }
+
+func TestAdopt_Scan(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main: root_commit
+	// stack-1: root_commit -> 1a -> 1b -> 1c
+	// stack-2: root_commit -> 1a -> 1b -> 2a
+	// stack-3: root_commit -> 1a -> 1b -> 2a -> 3a
+	//
+	// stack-1 got 1c after stack-2 was created, so stack-2 is inferred from the merge base.
+	t.Setenv("GIT_COMMITTER_DATE", "2024-01-01T00:00:00Z")
+	repo.Git(t, "checkout", "-b", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	repo.CommitFile(t, "my-file", "1b\n", gittest.WithMessage("Commit 1b"))
+	t.Setenv("GIT_COMMITTER_DATE", "2024-01-02T00:00:00Z")
+	repo.Git(t, "checkout", "-b", "stack-2")
+	repo.CommitFile(t, "other-file", "2a\n", gittest.WithMessage("Commit 2a"))
+	t.Setenv("GIT_COMMITTER_DATE", "2024-01-03T00:00:00Z")
+	repo.Git(t, "checkout", "-b", "stack-3")
+	repo.CommitFile(t, "other-file", "3a\n", gittest.WithMessage("Commit 3a"))
+	repo.Git(t, "switch", "stack-1")
+	repo.CommitFile(t, "my-file", "1c\n", gittest.WithMessage("Commit 1c"))
+	repo.Git(t, "switch", "main")
+
+	// Nothing is adopted on a dry run.
+	RequireAv(t, "adopt", "--scan", "--dry-run")
+	require.Empty(t, repo.OpenDB(t).ReadTx().AllBranches())
+
+	RequireAv(t, "adopt", "--scan", "--yes")
+	tx := repo.OpenDB(t).ReadTx()
+	stack1, ok := tx.Branch("stack-1")
+	require.True(t, ok)
+	require.True(t, stack1.Parent.Trunk)
+	stack2, ok := tx.Branch("stack-2")
+	require.True(t, ok)
+	require.Equal(t, "stack-1", stack2.Parent.Name)
+	require.Equal(t, strings.TrimSpace(repo.Git(t, "rev-parse", "stack-1~1")), stack2.Parent.Head)
+	stack3, ok := tx.Branch("stack-3")
+	require.True(t, ok)
+	require.Equal(t, "stack-2", stack3.Parent.Name)
+}
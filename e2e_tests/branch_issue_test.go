@@ -0,0 +1,39 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchIssue(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "--issue", "PROJ-123", "fix-login")
+	require.Equal(t, "PROJ-123", repo.OpenDB(t).ReadTx().AllBranches()["fix-login"].Issue)
+	require.Contains(t, RequireAv(t, "tree").Stdout, "issue PROJ-123")
+
+	// The issue is prepended to the commit subjects.
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "login.txt"), []byte("fixed\n"), 0o644))
+	repo.Git(t, "add", "login.txt")
+	RequireAv(t, "commit", "-m", "Fix login")
+	require.Equal(t, "[PROJ-123] Fix login", strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%s")))
+
+	output := RequireAv(t, "pr", "--dry-run")
+	require.Contains(t, output.Stderr, "[PROJ-123] Fix login")
+
+	// A bare number is a GitHub issue, and an empty issue unlinks the branch.
+	RequireAv(t, "branch", "--issue", "42")
+	require.Equal(t, "#42", repo.OpenDB(t).ReadTx().AllBranches()["fix-login"].Issue)
+	RequireAv(t, "branch", "--issue=")
+	require.Equal(t, "", repo.OpenDB(t).ReadTx().AllBranches()["fix-login"].Issue)
+
+	output = Av(t, "branch", "--issue", "not an issue", "other")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "invalid issue")
+}
@@ -0,0 +1,34 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchLock(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+
+	RequireAv(t, "branch", "lock", "one")
+	require.Contains(t, RequireAv(t, "tree").Stdout, "locked by ")
+
+	// The owner of the lock is not warned.
+	require.NotContains(t, RequireAv(t, "restack").Stderr, "is locked by")
+
+	// Another user is warned, but the restack proceeds since the input is not a terminal.
+	repo.Git(t, "config", "user.email", "teammate@example.com")
+	require.Contains(t, RequireAv(t, "restack").Stderr, "Branch one is locked by")
+
+	// Only the owner can unlock the branch without --force.
+	require.NotEqual(t, 0, Av(t, "branch", "unlock", "one").ExitCode)
+	RequireAv(t, "branch", "unlock", "--force", "one")
+	require.NotContains(t, RequireAv(t, "tree").Stdout, "locked by ")
+	require.NotEqual(t, 0, Av(t, "branch", "unlock", "one").ExitCode)
+}
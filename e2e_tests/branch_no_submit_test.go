@@ -0,0 +1,43 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchNoSubmit(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one", gittest.WithMessage("Add one"))
+	RequireAv(t, "branch", "--no-submit", "integration")
+	repo.CommitFile(t, "integration.txt", "integration", gittest.WithMessage("Add integration"))
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two", gittest.WithMessage("Add two"))
+
+	require.True(t, repo.OpenDB(t).ReadTx().AllBranches()["integration"].NoSubmit)
+	require.Contains(t, RequireAv(t, "tree").Stdout, "no submit")
+
+	// The excluded branch and the branches stacked on it are skipped.
+	output := RequireAv(t, "pr", "--all", "--dry-run")
+	require.Contains(t, output.Stderr, "Skipping 2 branch(es) excluded from submit: integration, two")
+	require.Contains(t, output.Stderr, "one: Add one")
+	require.NotContains(t, output.Stderr, "two: Add two")
+
+	output = Av(t, "pr", "--dry-run")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, `branch "integration" is excluded from submit`)
+
+	RequireAv(t, "switch", "integration")
+	RequireAv(t, "branch", "--submit")
+	require.False(t, repo.OpenDB(t).ReadTx().AllBranches()["integration"].NoSubmit)
+	output = RequireAv(t, "pr", "--all", "--dry-run")
+	require.NotContains(t, output.Stderr, "excluded from submit")
+	require.Contains(t, output.Stderr, "two: Add two")
+
+	output = Av(t, "branch", "--submit", "three")
+	require.NotEqual(t, 0, output.ExitCode)
+}
@@ -1,6 +1,7 @@
 package e2e_tests
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/aviator-co/av/internal/git/gittest"
@@ -92,3 +93,124 @@ func TestBranchRetroactiveMove(t *testing.T) {
 	)
 	require.NotContainsf(t, branches, "one", "expected one to be deleted from the branch metadata")
 }
+
+func TestBranchUpstreamTracking(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	require.Equal(t, "", strings.TrimSpace(repo.Git(t, "config", "--default", "", "branch.one.merge")))
+
+	repo.AppendAvConfig(t, "upstreamTracking: create\n")
+
+	RequireAv(t, "branch", "two")
+	require.Equal(t, "origin", strings.TrimSpace(repo.Git(t, "config", "branch.two.remote")))
+	require.Equal(t, "refs/heads/two", strings.TrimSpace(repo.Git(t, "config", "branch.two.merge")))
+}
+
+func TestBranchUpstreamTrackingGit(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, "upstreamTracking: git\n")
+
+	// Git tracks the remote-tracking trunk branch the new branch is created from.
+	RequireAv(t, "branch", "one")
+	require.Equal(t, "origin", strings.TrimSpace(repo.Git(t, "config", "branch.one.remote")))
+	require.Equal(t, "refs/heads/main", strings.TrimSpace(repo.Git(t, "config", "branch.one.merge")))
+
+	// branch.autoSetupMerge decides whether to track the local parent branch.
+	repo.Git(t, "config", "branch.autoSetupMerge", "always")
+	RequireAv(t, "branch", "two")
+	require.Equal(t, ".", strings.TrimSpace(repo.Git(t, "config", "branch.two.remote")))
+	require.Equal(t, "refs/heads/one", strings.TrimSpace(repo.Git(t, "config", "branch.two.merge")))
+}
+
+func TestBranchNamespace(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "bob/feature")
+	repo.Git(t, "push", "origin", "bob/feature")
+
+	repo.AppendAvConfig(t, "pullRequest:\n  branchNamePrefix: alice/\n  enforceBranchNamePrefix: true\n")
+
+	// Committing to a branch outside of the namespace requires --force.
+	repo.CreateFile(t, "bob.txt", "bob")
+	require.NotEqual(t, 0, Av(t, "commit", "-A", "-m", "Bob's change").ExitCode)
+	RequireAv(t, "commit", "-A", "-m", "Bob's change", "--force")
+
+	// The prefix is applied to the new branches.
+	RequireAv(t, "branch", "feature")
+	require.Equal(t, "alice/feature", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	repo.CreateFile(t, "alice.txt", "alice")
+	RequireAv(t, "commit", "-A", "-m", "Alice's change")
+
+	// Creating a branch that already exists on the remote requires --force.
+	repo.Git(t, "push", "origin", "alice/feature:alice/other")
+	repo.Git(t, "fetch", "origin")
+	require.NotEqual(t, 0, Av(t, "branch", "other").ExitCode)
+	RequireAv(t, "branch", "other", "--force")
+	require.Equal(t, "alice/other", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+
+	// So does av switch -c.
+	repo.Git(t, "push", "origin", "alice/feature:alice/third")
+	repo.Git(t, "fetch", "origin")
+	require.NotEqual(t, 0, Av(t, "switch", "-c", "third").ExitCode)
+	RequireAv(t, "switch", "-c", "third", "--force")
+	require.Equal(t, "alice/third", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+}
+
+func TestBranchStackMaxDepth(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, "stack:\n  maxDepth: 2\n")
+
+	RequireAv(t, "branch", "one")
+	RequireAv(t, "branch", "two")
+
+	// The third branch exceeds the limit.
+	res := Av(t, "branch", "three")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "beyond the limit of 2")
+	require.Equal(t, "two", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	repo.CreateFile(t, "three.txt", "three")
+	require.NotEqual(t, 0, Av(t, "commit", "-A", "-m", "Three", "-b").ExitCode)
+
+	RequireAv(t, "branch", "three", "--force")
+	require.Equal(t, "three", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+
+	// Branches based on the trunk are within the limit.
+	RequireAv(t, "branch", "other", "main")
+}
+
+func TestBranchNameTemplate(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+pullRequest:
+  branchNameTemplate: "{user}/{ticket}/{slug}"
+  branchNamePattern: "^av-test/"
+`)
+
+	// The user is the local part of user.email (av-test@nonexistent).
+	RequireAv(t, "branch", "Fix login race")
+	require.Equal(t, "av-test/fix-login-race", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	RequireAv(t, "branch", "ABC-123 Fix the logout")
+	require.Equal(t, "av-test/ABC-123/fix-the-logout", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+
+	// The commit messages are named with the template too.
+	repo.CreateFile(t, "one.txt", "one")
+	RequireAv(t, "commit", "-A", "-b", "-m", "Add one")
+	require.Equal(t, "av-test/add-one", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+
+	// The names that don't match the pattern are refused.
+	res := Av(t, "branch", "--exact", "Fix-it")
+	require.NotEqual(t, 0, res.ExitCode)
+	require.Contains(t, res.Stderr, "doesn't match the branch naming pattern")
+	RequireAv(t, "branch", "--exact", "--force", "Fix-it")
+	require.Equal(t, "Fix-it", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	require.NotEqual(t, 0, Av(t, "branch", "-m", "other").ExitCode)
+}
@@ -0,0 +1,104 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIHintsTrailer(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+pullRequest:
+    ciHints:
+        reducedAfterDepth: 1
+        markers: [trailer]
+`)
+
+	const trailer = "Av-CI: reduced"
+	RequireAv(t, "branch", "one")
+	repo.AddFile(t, repo.CreateFile(t, "one.txt", "one"))
+	RequireAv(t, "commit", "-m", "Add one")
+	require.NotContains(t, repo.Git(t, "log", "-1", "--format=%B", "one"), trailer)
+
+	RequireAv(t, "branch", "two")
+	repo.AddFile(t, repo.CreateFile(t, "two.txt", "two"))
+	RequireAv(t, "commit", "-m", "Add two")
+	require.Contains(t, repo.Git(t, "log", "-1", "--format=%B", "two"), trailer)
+}
+
+func TestCIHintsPushOptionPartialPush(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+pullRequest:
+    ciHints:
+        reducedAfterDepth: 1
+`)
+
+	// The remote rejects the pushes with push options.
+	remoteDir := strings.TrimSpace(repo.Git(t, "remote", "get-url", "origin"))
+	Cmd(t, "git", "-C", remoteDir, "config", "receive.advertisePushOptions", "true")
+	hook := filepath.Join(remoteDir, "hooks", "pre-receive")
+	require.NoError(t, os.MkdirAll(filepath.Dir(hook), 0755))
+	require.NoError(t, os.WriteFile(hook, []byte(
+		"#!/bin/sh\nif [ \"${GIT_PUSH_OPTION_COUNT:-0}\" -gt 0 ]; then echo \"rejected $GIT_PUSH_OPTION_0\" >&2; exit 1; fi\n",
+	), 0755))
+
+	// main -> one -> two, where only two is deep enough for the push option.
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	repo.Git(t, "push", "origin", "one", "two")
+	repo.CheckoutBranch(t, "refs/heads/one")
+	repo.CommitFile(t, "one.txt", "1b\n")
+
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main"},
+		mockPR{ID: "nodeid-2", Number: 2, State: "OPEN", HeadRefName: "two", BaseRefName: "one"},
+	)
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	for _, pr := range server.pulls {
+		br, _ := tx.Branch(pr.HeadRefName)
+		br.PullRequest = &meta.PullRequest{ID: pr.ID, Number: int64(pr.Number), State: "OPEN"}
+		tx.SetBranch(br)
+	}
+	require.NoError(t, tx.Commit())
+
+	// one and two are pushed separately since only two has the push option.
+	out := Av(t, "sync", "--push=yes", "--prune=no")
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Contains(t, out.Stdout+out.Stderr, "pushed one, but failed to push the other branches")
+	require.Contains(t, out.Stdout+out.Stderr, "rejected ci.reduced")
+
+	oneHead := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one"))
+	require.Equal(
+		t,
+		oneHead.String()+"\trefs/heads/one",
+		strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/one")),
+	)
+	require.Equal(
+		t,
+		oneHead.String(),
+		strings.TrimSpace(repo.Git(t, "config", "branch.one.av-pushed-commit")),
+	)
+	twoHead := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two"))
+	require.NotEqual(
+		t,
+		twoHead.String()+"\trefs/heads/two",
+		strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/two")),
+	)
+}
@@ -0,0 +1,59 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitFixup(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "a.txt", "a1\na2\na3\n", gittest.WithMessage("Add a"))
+	repo.CommitFile(t, "b.txt", "b1\nb2\n", gittest.WithMessage("Add b"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "c.txt", "c1\n", gittest.WithMessage("Add c"))
+	repo.CheckoutBranch(t, "refs/heads/stack-1")
+
+	// Each change belongs to a different commit. The new file doesn't belong to any commit.
+	repo.AddFile(t, repo.CreateFile(t, "a.txt", "a1\nA2\na3\n"))
+	repo.AddFile(t, repo.CreateFile(t, "b.txt", "b1\nb2\nb3\n"))
+	repo.AddFile(t, repo.CreateFile(t, "new.txt", "new\n"))
+
+	RequireAv(t, "commit", "--fixup")
+
+	// The changes are folded into the commits that they belong to.
+	require.Equal(
+		t,
+		[]string{"Add b", "Add a"},
+		strings.Split(strings.TrimSpace(repo.Git(t, "log", "--format=%s", "main..stack-1")), "\n"),
+	)
+	require.Equal(t, "a1\nA2\na3\n", repo.Git(t, "show", "stack-1~1:a.txt"))
+	require.Equal(t, "b1\nb2\nb3\n", repo.Git(t, "show", "stack-1:b.txt"))
+	require.NotContains(t, repo.Git(t, "ls-tree", "--name-only", "stack-1"), "new.txt")
+	require.Equal(t, "A  new.txt\n", repo.Git(t, "status", "--porcelain"))
+
+	// The children are restacked.
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1")).String(),
+		GetStoredParentBranchState(t, repo, "stack-2").Head,
+	)
+	require.Equal(
+		t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "stack-1")),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "stack-2~1")),
+	)
+
+	// The changes of the trunk commits are not folded.
+	repo.AddFile(t, repo.CreateFile(t, "a.txt", "a1\nA2\na3\na4\n"))
+	RequireAv(t, "commit", "--fixup")
+	repo.Git(t, "reset", "--hard")
+	repo.AddFile(t, repo.CreateFile(t, "README.md", "changed\n"))
+	require.NotEqual(t, 0, Av(t, "commit", "--fixup").ExitCode)
+}
@@ -0,0 +1,78 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitMessageRules(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+commit:
+    messageTemplate: "{message}\n\nRefs: {ticket}"
+    messageRules:
+        preset: conventional
+        maxSubjectLength: 50
+`)
+
+	RequireAv(t, "branch", "PAY-12-refunds")
+	head := repo.Git(t, "rev-parse", "HEAD")
+
+	// A message that breaks the rules is rejected before the commit is created.
+	repo.CreateFile(t, "one.txt", "one")
+	repo.AddFile(t, "one.txt")
+	output := Av(t, "commit", "-m", "Add refunds")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "doesn't follow Conventional Commits")
+	require.Equal(t, head, repo.Git(t, "rev-parse", "HEAD"))
+
+	RequireAv(t, "commit", "-m", "feat: add refunds")
+	require.Equal(t,
+		"feat: add refunds\n\nRefs: PAY-12",
+		strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%B")),
+	)
+
+	// Without -m, the message is edited with the stack context and checked.
+	dir := t.TempDir()
+	seen := filepath.Join(dir, "seen")
+	script := filepath.Join(dir, "editor.sh")
+	require.NoError(t, os.WriteFile(script, []byte(
+		"#!/bin/sh\ncp \"$1\" '"+seen+"'\nsed -i -e '1s/^/fix: handle partial refunds/' \"$1\"\n",
+	), 0755))
+	t.Setenv("GIT_EDITOR", script)
+	repo.CreateFile(t, "two.txt", "two")
+	repo.AddFile(t, "two.txt")
+	RequireAv(t, "commit")
+	require.Equal(t,
+		"fix: handle partial refunds\n\nRefs: PAY-12",
+		strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%B")),
+	)
+	edited := readFile(t, seen)
+	require.Contains(t, edited, "# Enter the commit message for branch PAY-12-refunds (on main).\n")
+	require.Contains(t, edited, "# Ticket: PAY-12\n")
+	require.Contains(t, edited, "# The subject must be at most 50 characters long.\n")
+
+	// Without a terminal, an edited message that breaks the rules is an error.
+	require.NoError(t, os.WriteFile(script, []byte(
+		"#!/bin/sh\nsed -i -e '1s/^/Handle partial refunds/' \"$1\"\n",
+	), 0755))
+	head = repo.Git(t, "rev-parse", "HEAD")
+	repo.CreateFile(t, "three.txt", "three")
+	repo.AddFile(t, "three.txt")
+	output = Av(t, "commit")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "doesn't follow Conventional Commits")
+	require.Equal(t, head, repo.Git(t, "rev-parse", "HEAD"))
+
+	// The new branches are checked as well.
+	output = Av(t, "commit", "-b", "-m", "Handle partial refunds")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "doesn't follow Conventional Commits")
+}
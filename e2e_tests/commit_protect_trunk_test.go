@@ -0,0 +1,45 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitProtectTrunk(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+commit:
+    protectTrunk: true
+`)
+
+	initial := repo.Git(t, "rev-parse", "HEAD")
+	repo.AddFile(t, repo.CreateFile(t, "one.txt", "one"))
+
+	// Without a terminal, the commit on the trunk is refused.
+	out := Av(t, "commit", "-m", "Add one")
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Contains(t, out.Stderr, "committing directly on the trunk branch")
+	require.Equal(t, initial, repo.Git(t, "rev-parse", "HEAD"))
+
+	// The pre-commit hook check fails on the trunk.
+	require.NotEqual(t, 0, Av(t, "commit", "check-trunk").ExitCode)
+
+	// -b commits on a new branch.
+	RequireAv(t, "commit", "-b", "-m", "Add one")
+	require.Equal(t, "refs/heads/add-one", repo.CurrentBranch(t).String())
+	require.Equal(t, initial, repo.Git(t, "rev-parse", "main"))
+	RequireAv(t, "commit", "check-trunk")
+
+	// --force commits on the trunk anyway.
+	repo.Git(t, "switch", "main")
+	repo.AddFile(t, repo.CreateFile(t, "two.txt", "two"))
+	RequireAv(t, "commit", "--force", "-m", "Add two")
+	require.NotEqual(t, initial, repo.Git(t, "rev-parse", "main"))
+
+	t.Setenv("AV_ALLOW_TRUNK_COMMIT", "1")
+	RequireAv(t, "commit", "check-trunk")
+}
@@ -0,0 +1,44 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitSplitByDir(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+commit:
+    pathGroups:
+        - name: backend
+          paths: ["api", "db"]
+`)
+
+	RequireAv(t, "branch", "one")
+	for _, dir := range []string{"api", "db", "web"} {
+		require.NoError(t, os.Mkdir(filepath.Join(repo.RepoDir, dir), 0755))
+		repo.AddFile(t, repo.CreateFile(t, filepath.Join(dir, "main.txt"), dir))
+	}
+	repo.AddFile(t, repo.CreateFile(t, "README.txt", "readme"))
+	// Unstaged changes are not committed.
+	repo.CreateFile(t, "web/unstaged.txt", "unstaged")
+
+	RequireAv(t, "commit", "--split-by-dir", "-m", "Add files")
+
+	require.Equal(t, []string{
+		"web: Add files",
+		"root: Add files",
+		"backend: Add files",
+	}, strings.Split(strings.TrimSpace(repo.Git(t, "log", "--format=%s", "main..one")), "\n"))
+	require.Equal(t, []string{"api/main.txt", "db/main.txt"}, strings.Fields(
+		repo.Git(t, "diff-tree", "--no-commit-id", "--name-only", "-r", "one~2"),
+	))
+	require.Equal(t, "?? web/unstaged.txt", strings.TrimSpace(repo.Git(t, "status", "--porcelain")))
+}
@@ -0,0 +1,48 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitMessageTemplate(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+commit:
+    messageTemplate: "{groups}: {message}"
+    pathGroups:
+        - name: payments
+          paths: ["services/payments"]
+        - name: api
+          paths: ["services/api"]
+`)
+
+	for _, dir := range []string{"services/payments", "services/api"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(repo.RepoDir, dir), 0755))
+		repo.AddFile(t, repo.CreateFile(t, filepath.Join(dir, "main.txt"), "v1"))
+	}
+	repo.Git(t, "commit", "-m", "Add services")
+	repo.Git(t, "push", "origin", "main")
+
+	RequireAv(t, "branch", "one")
+	repo.CreateFile(t, "services/payments/main.txt", "v2")
+	repo.CreateFile(t, "services/api/main.txt", "v2")
+	RequireAv(t, "commit", "-a", "-m", "Add refunds")
+	require.Equal(t,
+		"payments,api: Add refunds",
+		strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%s")),
+	)
+
+	// Without -m, the filled template is opened in the editor.
+	t.Setenv("GIT_EDITOR", "true")
+	repo.CreateFile(t, "services/api/main.txt", "v3")
+	RequireAv(t, "commit", "-a")
+	require.Equal(t, "api:", strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%s")))
+}
@@ -0,0 +1,77 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionCache(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "stack-2")
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	stack1, _ := tx.Branch("stack-1")
+	stack1.PullRequest = &meta.PullRequest{Number: 12, Title: "Add one"}
+	tx.SetBranch(stack1)
+	require.NoError(t, tx.Commit())
+
+	// The cache is rebuilt since the av database has been changed.
+	output := RequireAv(t, "__complete", "switch", "")
+	// The branches are described with their parents and pull requests.
+	require.Contains(t, output.Stdout, "main\ttrunk\n")
+	require.Contains(t, output.Stdout, "stack-1\ton main, #12 Add one\n")
+	require.Contains(t, output.Stdout, "stack-2\ton stack-1\n")
+	cacheFile := filepath.Join(repo.GitDir, "av", "completion-cache.json")
+	require.FileExists(t, cacheFile)
+
+	// The commands that change the branches update the cache.
+	RequireAv(t, "branch", "stack-3")
+	require.Contains(t, readFile(t, cacheFile), `"stack-3"`)
+
+	// The completion doesn't run Git with the cache.
+	t.Setenv("PATH", t.TempDir())
+	output = RequireAv(t, "__complete", "switch", "")
+	require.Contains(t, output.Stdout, "stack-3\ton stack-2\n")
+
+	// Without the cache, Git is needed.
+	require.NoError(t, os.Remove(cacheFile))
+	output = RequireAv(t, "__complete", "switch", "")
+	require.NotContains(t, output.Stdout, "stack-3")
+}
+
+func TestCompletionBranchArgs(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	RequireAv(t, "branch", "stack-2")
+
+	// The positional branch arguments are completed.
+	output := RequireAv(t, "__complete", "reparent", "")
+	require.Contains(t, output.Stdout, "stack-1\ton main\n")
+	output = RequireAv(t, "__complete", "abandon", "")
+	require.Contains(t, output.Stdout, "stack-2\ton stack-1\n")
+
+	// Only the parent branch (the second argument) of av branch is completed.
+	output = RequireAv(t, "__complete", "branch", "")
+	require.NotContains(t, output.Stdout, "stack-1")
+	output = RequireAv(t, "__complete", "branch", "new-branch", "")
+	require.Contains(t, output.Stdout, "stack-1\ton main\n")
+
+	// A command that takes one branch doesn't complete a second one.
+	output = RequireAv(t, "__complete", "switch", "stack-1", "")
+	require.NotContains(t, output.Stdout, "stack-2")
+
+	// The branch flags are completed too.
+	output = RequireAv(t, "__complete", "commit", "--parent", "")
+	require.Contains(t, output.Stdout, "stack-2\ton stack-1\n")
+}
@@ -0,0 +1,62 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletionInstall(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("SHELL", "/usr/bin/fish")
+
+	output := RequireAv(t, "completion", "bash")
+	require.Contains(t, output.Stdout, "bash completion V2 for av")
+
+	// The shell is detected from $SHELL.
+	fishScript := filepath.Join(home, ".config", "fish", "completions", "av.fish")
+	output = RequireAv(t, "completion", "--install")
+	require.Contains(t, output.Stderr, "Installed the fish completion")
+	require.FileExists(t, fishScript)
+	output = RequireAv(t, "completion", "--install")
+	require.Contains(t, output.Stderr, "already up to date")
+	require.NoError(t, os.WriteFile(fishScript, []byte("# old\n"), 0644))
+	output = RequireAv(t, "completion", "--install")
+	require.Contains(t, output.Stderr, "Updated the fish completion")
+	RequireAv(t, "completion", "--uninstall")
+	require.NoFileExists(t, fishScript)
+
+	// PowerShell loads the script from the profile.
+	profile := filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1")
+	require.NoError(t, os.MkdirAll(filepath.Dir(profile), 0755))
+	require.NoError(t, os.WriteFile(profile, []byte("Set-PSReadLineOption -EditMode Emacs\n"), 0644))
+	RequireAv(t, "completion", "--install", "powershell")
+	RequireAv(t, "completion", "--install", "powershell")
+	script := filepath.Join(home, ".config", "powershell", "av-completion.ps1")
+	require.FileExists(t, script)
+	require.Equal(
+		t,
+		"Set-PSReadLineOption -EditMode Emacs\n. \""+script+"\" # av completion\n",
+		readFile(t, profile),
+	)
+	RequireAv(t, "completion", "--uninstall", "powershell")
+	require.NoFileExists(t, script)
+	require.Equal(t, "Set-PSReadLineOption -EditMode Emacs\n", readFile(t, profile))
+
+	// A profile with CRLF line endings keeps them, and the line is not added twice.
+	require.NoError(t, os.WriteFile(profile, []byte("Set-PSReadLineOption -EditMode Emacs\r\n"), 0644))
+	RequireAv(t, "completion", "--install", "powershell")
+	RequireAv(t, "completion", "--install", "powershell")
+	require.Equal(
+		t,
+		"Set-PSReadLineOption -EditMode Emacs\r\n. \""+script+"\" # av completion\r\n",
+		readFile(t, profile),
+	)
+	RequireAv(t, "completion", "--uninstall", "powershell")
+	require.Equal(t, "Set-PSReadLineOption -EditMode Emacs\r\n", readFile(t, profile))
+}
@@ -0,0 +1,59 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConflictReport(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "my-file", "1a\n2a\n", gittest.WithMessage("Commit 2a"))
+	repo.CheckoutBranch(t, "refs/heads/stack-1")
+	repo.CommitFile(t, "my-file", "1a\n1b\n", gittest.WithMessage("Commit 1b"))
+
+	require.NotEqual(t, 0, Av(t, "restack").ExitCode)
+
+	// The conflict is recorded on the branch that couldn't be rebased.
+	br, _ := repo.OpenDB(t).ReadTx().Branch("stack-2")
+	require.NotNil(t, br.Conflict)
+	require.Equal(t, "stack-1", br.Conflict.Parent)
+	require.Equal(t, []string{"my-file"}, br.Conflict.Files)
+	require.Contains(t, br.Conflict.Commit, "Commit 2a")
+	require.Len(t, br.Conflict.ParentCommits, 1)
+	require.Contains(t, br.Conflict.ParentCommits[0], "Commit 1b")
+	require.Equal(
+		t,
+		[]string{"git add -- my-file", "av restack --continue"},
+		br.Conflict.Commands,
+	)
+
+	// The report is kept after the rebase is aborted, and shown in av tree.
+	RequireAv(t, "restack", "--abort")
+	br, _ = repo.OpenDB(t).ReadTx().Branch("stack-2")
+	require.NotNil(t, br.Conflict)
+	require.Equal(t, []string{"av restack"}, br.Conflict.Commands)
+	tree := RequireAv(t, "tree")
+	require.Contains(t, tree.Stdout, "conflicted")
+	require.Contains(t, tree.Stdout, "conflict with stack-1 in my-file")
+
+	// The report is cleared once the branch is restacked.
+	require.NotEqual(t, 0, Av(t, "restack").ExitCode)
+	require.NoError(
+		t,
+		os.WriteFile(filepath.Join(repo.RepoDir, "my-file"), []byte("1a\n1b\n2a\n"), 0644),
+	)
+	repo.Git(t, "add", "my-file")
+	RequireAv(t, "restack", "--continue")
+	br, _ = repo.OpenDB(t).ReadTx().Branch("stack-2")
+	require.Nil(t, br.Conflict)
+	require.NotContains(t, RequireAv(t, "tree").Stdout, "conflicted")
+}
@@ -0,0 +1,66 @@
+package e2e_tests
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/debugbundle"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugBundle(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "secret-feature")
+	repo.CommitFile(t, "one.txt", "1a\n", gittest.WithMessage("Secret commit message"))
+	RequireAv(t, "tree")
+	// The commands run without --debug are not traced.
+	require.Equal(t, 0, Cmd(t, avCmdPath, "tree").ExitCode)
+
+	output := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	RequireAv(t, "debug", "bundle", "--file", output)
+
+	f, err := os.Open(output)
+	require.NoError(t, err)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gr)
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = string(data)
+	}
+
+	var branches []debugbundle.Branch
+	require.NoError(t, json.Unmarshal([]byte(files["av-debug-bundle/branches.json"]), &branches))
+	require.Len(t, branches, 1)
+	require.Equal(t, "branch-1", branches[0].Name)
+	require.Equal(t, "main", branches[0].Parent)
+	require.Contains(t, files["av-debug-bundle/info.json"], "gitVersion")
+	require.Contains(t, files["av-debug-bundle/oplog.txt"], "branch-1")
+
+	traces := 0
+	for name, content := range files {
+		require.NotContains(t, content, "secret-feature", name)
+		require.NotContains(t, content, "Secret commit message", name)
+		if filepath.Dir(name) == "av-debug-bundle/traces" {
+			traces++
+		}
+	}
+	// av branch and av tree with --debug (the bundle command itself is not traced).
+	require.Equal(t, 2, traces)
+}
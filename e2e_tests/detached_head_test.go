@@ -0,0 +1,38 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetachedHEAD(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	repo.CommitFile(t, "one.txt", "one more")
+	repo.Git(t, "checkout", "--detach", "HEAD^")
+
+	// The error suggests reattaching to the branch that contains HEAD.
+	output := Av(t, "next")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "av switch one")
+
+	// A new branch is created at HEAD and stacked on the nearest branch.
+	repo.CommitFile(t, "two.txt", "two")
+	head := strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD"))
+	output = Av(t, "next")
+	require.Contains(t, output.Stderr, "av branch <new-branch>")
+	RequireAv(t, "branch", "two")
+	require.Equal(t, "two", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	require.Equal(t, head, strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD")))
+
+	db := repo.OpenDB(t)
+	two, ok := db.ReadTx().Branch("two")
+	require.True(t, ok)
+	require.True(t, two.Parent.Trunk)
+}
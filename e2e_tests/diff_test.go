@@ -0,0 +1,90 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffBranch(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	// one moves ahead without syncing two.
+	repo.CheckoutBranch(t, "refs/heads/one")
+	repo.CommitFile(t, "one-b.txt", "one")
+	repo.CheckoutBranch(t, "refs/heads/main")
+
+	// The diff of two is against the head of one that it was synced onto.
+	out := Av(t, "diff", "--branch", "two", "--name-only")
+	require.Equal(t, 1, out.ExitCode, "two is not up to date with one")
+	require.Equal(t, "two.txt\n", out.Stdout)
+
+	out = RequireAv(t, "diff", "--branch", "two", "--stack", "--name-only")
+	require.Equal(t, "one.txt\ntwo.txt\n", out.Stdout)
+
+	out = RequireAv(t, "diff", "--branch", "one", "--name-only")
+	require.Equal(t, "one-b.txt\none.txt\n", out.Stdout)
+
+	out = RequireAv(t, "diff", "--branch", "one", "--stat")
+	require.Contains(t, out.Stdout, "2 files changed")
+
+	require.NotEqual(t, 0, Av(t, "diff", "--branch", "one", "--stat", "--name-only").ExitCode)
+	require.NotEqual(t, 0, Av(t, "diff", "--branch", "three").ExitCode)
+}
+
+func TestDiffStackStat(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two, where two removes a line added by one.
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "a.txt", "1\n2\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "a.txt", "1\n")
+	repo.CommitFile(t, "b.txt", "b\n")
+	repo.CheckoutBranch(t, "refs/heads/one")
+
+	out := RequireAv(t, "diff", "--stack", "--stat")
+	require.Contains(t, out.Stdout, "a.txt: one, two")
+	// The cumulative changes are the diff of two against main.
+	require.Contains(t, out.Stdout, "Cumulative changes of the stack up to two:")
+	require.Contains(t, out.Stdout, "a.txt: +1 -0")
+	require.Contains(t, out.Stdout, "b.txt: +1 -0")
+	require.Contains(t, out.Stdout, "2 files changed, +2 -0")
+}
+
+func TestDiffStackStatForked(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two
+	//             -> three
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "a.txt", "1\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "b.txt", "b\n")
+	repo.CheckoutBranch(t, "refs/heads/one")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "c.txt", "c\n")
+
+	// The parent head of three is not recorded (e.g., an adopted branch).
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	three, _ := tx.Branch("three")
+	three.Parent.Head = ""
+	tx.SetBranch(three)
+	require.NoError(t, tx.Commit())
+
+	out := RequireAv(t, "diff", "--stack", "--stat")
+	require.Contains(t, out.Stdout, "three: 1 file")
+	require.Contains(t, out.Stdout, "Cumulative changes of the stack up to two:")
+	require.Contains(t, out.Stdout, "Cumulative changes of the stack up to three:")
+	require.Contains(t, out.Stdout, "c.txt: +1 -0")
+}
@@ -0,0 +1,32 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctor(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	//     main -> one -> two
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	RequireAv(t, "doctor")
+
+	repo.Git(t, "branch", "-m", "one", "uno")
+	out := Av(t, "doctor")
+	require.Equal(t, 1, out.ExitCode)
+	require.Contains(t, out.Stderr, `branch "one" was renamed to "uno" with Git`)
+
+	RequireAv(t, "doctor", "--fix", "--yes")
+	require.Equal(t, "uno", GetStoredParentBranchState(t, repo, "two").Name)
+	RequireAv(t, "doctor")
+
+	RequireAv(t, "undo")
+	require.Equal(t, "one", GetStoredParentBranchState(t, repo, "two").Name)
+}
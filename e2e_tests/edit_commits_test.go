@@ -0,0 +1,48 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditCommits(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main: root_commit
+	// one: root_commit -> 1a
+	// two: root_commit -> 1a -> 2a -> 2b
+	// three: root_commit -> 1a -> 2a -> 2b -> 3a
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one", gittest.WithMessage("1a"))
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two-a.txt", "2a", gittest.WithMessage("2a"))
+	repo.CommitFile(t, "two-b.txt", "2b", gittest.WithMessage("2b"))
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three", gittest.WithMessage("3a"))
+	repo.Git(t, "checkout", "two")
+
+	// Only the commits of two are in the todo list. Drop the first one.
+	t.Setenv(
+		"GIT_SEQUENCE_EDITOR",
+		`sh -c 'test "$(grep -c ^pick "$1")" = 2 && sed -i -e "1s/^pick/drop/" "$1"' --`,
+	)
+	RequireAv(t, "edit-commits")
+
+	require.Equal(t, "two", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	require.Equal(t, "2b\n1a", strings.TrimSpace(repo.Git(t, "log", "--format=%s", "main..two")))
+
+	// The child branch is restacked onto the new head.
+	require.Equal(t, "3a\n2b\n1a", strings.TrimSpace(repo.Git(t, "log", "--format=%s", "main..three")))
+	require.Equal(
+		t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "two")),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "three^")),
+	)
+	tx := repo.OpenDB(t).ReadTx()
+	three, _ := tx.Branch("three")
+	require.Equal(t, strings.TrimSpace(repo.Git(t, "rev-parse", "two")), three.Parent.Head)
+}
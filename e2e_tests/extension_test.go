@@ -0,0 +1,47 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtensionCommand(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "av-hello"), []byte(`#!/bin/sh
+echo "args: $*"
+echo "branch: $AV_CURRENT_BRANCH"
+echo "root: $AV_REPO_ROOT"
+echo "db: $AV_DB"
+echo "debug: $AV_DEBUG"
+[ "$1" = fail ] && exit 3
+exit 0
+`), 0o755))
+	// The extensions cannot override the built-in commands.
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "av-tree"), []byte("#!/bin/sh\necho extension\n"), 0o755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	out := RequireAv(t, "hello", "a", "--b", "-C", "c")
+	require.Contains(t, out.Stdout, "args: a --b -C c\n")
+	require.Contains(t, out.Stdout, "branch: main\n")
+	root, err := filepath.EvalSymlinks(repo.RepoDir)
+	require.NoError(t, err)
+	require.Contains(t, out.Stdout, "root: "+root+"\n")
+	require.Contains(t, out.Stdout, "av.db\n")
+	// The root flags before the extension name are handled by av.
+	require.Contains(t, out.Stdout, "debug: 1\n")
+
+	out = Av(t, "hello", "fail")
+	require.Equal(t, 3, out.ExitCode)
+
+	require.NotContains(t, RequireAv(t, "tree").Stdout, "extension")
+	help := Cmd(t, avCmdPath, "--help")
+	require.Contains(t, help.Stdout, "hello")
+	require.Contains(t, Cmd(t, avCmdPath, "__complete", "hel").Stdout, "hello")
+}
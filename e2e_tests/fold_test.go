@@ -0,0 +1,44 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFold(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two -> three
+	RequireAv(t, "branch", "one")
+	oneHead := repo.CommitFile(t, "one.txt", "1")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a")
+	twoHead := repo.CommitFile(t, "two.txt", "2b")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "3")
+
+	// A branch based on the trunk can't be folded.
+	repo.CheckoutBranch(t, "refs/heads/one")
+	require.NotEqual(t, 0, Av(t, "fold").ExitCode)
+
+	// main -> one (with the commits of two) -> three
+	repo.CheckoutBranch(t, "refs/heads/two")
+	RequireAv(t, "fold")
+	require.Equal(t, twoHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")))
+	require.Equal(t, plumbing.NewBranchReferenceName("one"), repo.CurrentBranch(t))
+	require.Equal(t, "one", GetStoredParentBranchState(t, repo, "three").Name)
+	_, err := repo.GoGit.Reference(plumbing.NewBranchReferenceName("two"), false)
+	require.Error(t, err, "the folded branch should be deleted")
+	_, exists := repo.OpenDB(t).ReadTx().Branch("two")
+	require.False(t, exists)
+
+	RequireAv(t, "undo")
+	require.Equal(t, oneHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")))
+	require.Equal(t, twoHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two")))
+	require.Equal(t, "two", GetStoredParentBranchState(t, repo, "three").Name)
+	require.Equal(t, plumbing.NewBranchReferenceName("two"), repo.CurrentBranch(t))
+}
@@ -0,0 +1,75 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+// pushBotBranch pushes a commit of the file on top of main to the remote branch as a bot would,
+// without keeping a local branch, and returns the commit.
+func pushBotBranch(t *testing.T, repo *gittest.GitTestRepo, branch, content string) string {
+	current := strings.TrimSpace(repo.Git(t, "branch", "--show-current"))
+	repo.Git(t, "checkout", "-b", "bot-tmp", "main")
+	commit := repo.CommitFile(t, "deps.txt", content).String()
+	repo.Git(t, "push", "--force", "origin", "bot-tmp:refs/heads/"+branch)
+	repo.Git(t, "checkout", current)
+	repo.Git(t, "branch", "-D", "bot-tmp")
+	return commit
+}
+
+func TestFollowBotBranch(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	first := pushBotBranch(t, repo, "renovate/dep", "v2\n")
+	server.pulls = append(server.pulls, mockPR{
+		ID: "nodeid-1", Number: 1, State: "OPEN",
+		HeadRefName: "renovate/dep", BaseRefName: "main",
+	})
+
+	RequireAv(t, "follow", "renovate/dep")
+	require.Equal(
+		t, first, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("renovate/dep")).String(),
+	)
+	RequireAv(t, "branch", "--parent", "renovate/dep", "feature")
+	repo.CommitFile(t, "feature.txt", "feature\n")
+	require.Equal(t, first, strings.TrimSpace(repo.Git(t, "rev-parse", "feature^")))
+
+	// The pull request of the followed branch is owned by the bot.
+	repo.Git(t, "checkout", "renovate/dep")
+	require.NotEqual(t, 0, Av(t, "pr", "--title", "Bump deps").ExitCode)
+	repo.Git(t, "checkout", "feature")
+
+	// The bot rebases the update onto the latest main and force-pushes it.
+	second := pushBotBranch(t, repo, "renovate/dep", "v3\n")
+	out := RequireAv(t, "sync", "--push=no", "--prune=no")
+	require.Contains(t, out.Stdout, "Followed branch renovate/dep is updated to the remote branch")
+	require.Equal(
+		t, second, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("renovate/dep")).String(),
+	)
+	require.Equal(t, second, strings.TrimSpace(repo.Git(t, "rev-parse", "feature^")))
+	require.Equal(
+		t, "1", strings.TrimSpace(repo.Git(t, "rev-list", "--count", "renovate/dep..feature")),
+	)
+
+	// The followed branch is not pushed.
+	out = RequireAv(t, "sync", "--push=yes", "--prune=no")
+	require.Contains(t, out.Stdout, "renovate/dep: Followed branch is owned by someone else.")
+
+	// The bot closes the pull request. The children are moved onto main without its commits.
+	server.pulls[0].State = "CLOSED"
+	out = RequireAv(t, "sync", "--push=no", "--prune=no")
+	require.Contains(t, out.Stdout, "followed branch renovate/dep was closed without merging")
+	require.Equal(t, "main", GetStoredParentBranchState(t, repo, "feature").Name)
+	require.Equal(
+		t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "origin/main")),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "feature^")),
+	)
+}
@@ -0,0 +1,111 @@
+package e2e_tests
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncPushesToFork(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	forkDir := filepath.Join(t.TempDir(), "alice", "project.git")
+	require.NoError(t, exec.Command("git", "init", "--bare", forkDir).Run())
+	repo.Git(t, "remote", "add", "fork", forkDir)
+	// Same as av init --push-remote fork.
+	repo.Git(t, "config", "remote.pushDefault", "fork")
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	repo.Git(t, "push", "fork", "one")
+	repo.CommitFile(t, "one.txt", "1b\n")
+
+	// The pull request opened from the fork.
+	server.pulls = append(server.pulls, mockPR{
+		ID:          "nodeid-42",
+		Number:      42,
+		State:       "OPEN",
+		HeadRefName: "one",
+		BaseRefName: "main",
+	})
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	oneMeta, _ := tx.Branch("one")
+	oneMeta.PullRequest = &meta.PullRequest{ID: "nodeid-42", Number: 42, State: "OPEN"}
+	tx.SetBranch(oneMeta)
+	require.NoError(t, tx.Commit())
+
+	RequireAv(t, "sync", "--push=yes", "--prune=no")
+
+	head := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one"))
+	require.Equal(
+		t,
+		head.String()+"\trefs/heads/one",
+		strings.TrimSpace(repo.Git(t, "ls-remote", "fork", "refs/heads/one")),
+	)
+	require.Empty(t, strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/one")))
+}
+
+func TestSyncPushesToBranchPushRemote(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	forkDir := filepath.Join(t.TempDir(), "alice", "project.git")
+	require.NoError(t, exec.Command("git", "init", "--bare", forkDir).Run())
+	repo.Git(t, "remote", "add", "fork", forkDir)
+
+	// Only the branches created with the config are pushed to the fork.
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	repo.Git(t, "push", "origin", "two")
+	repo.CommitFile(t, "two.txt", "2b\n")
+
+	repo.AppendAvConfig(t, "branchPushRemote: fork\n")
+
+	repo.CheckoutBranch(t, "refs/heads/main")
+	RequireAv(t, "branch", "one")
+	require.Equal(t, "fork", strings.TrimSpace(repo.Git(t, "config", "branch.one.pushRemote")))
+	repo.CommitFile(t, "one.txt", "1a\n")
+	repo.Git(t, "push", "fork", "one")
+	repo.CommitFile(t, "one.txt", "1b\n")
+
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main"},
+		mockPR{ID: "nodeid-2", Number: 2, State: "OPEN", HeadRefName: "two", BaseRefName: "main"},
+	)
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	for _, pr := range server.pulls {
+		br, _ := tx.Branch(pr.HeadRefName)
+		br.PullRequest = &meta.PullRequest{ID: pr.ID, Number: int64(pr.Number), State: "OPEN"}
+		tx.SetBranch(br)
+	}
+	require.NoError(t, tx.Commit())
+
+	RequireAv(t, "sync", "--all", "--push=yes", "--prune=no")
+
+	oneHead := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one"))
+	require.Equal(
+		t,
+		oneHead.String()+"\trefs/heads/one",
+		strings.TrimSpace(repo.Git(t, "ls-remote", "fork", "refs/heads/one")),
+	)
+	require.Empty(t, strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/one")))
+	twoHead := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two"))
+	require.Equal(
+		t,
+		twoHead.String()+"\trefs/heads/two",
+		strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/two")),
+	)
+}
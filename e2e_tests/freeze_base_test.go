@@ -0,0 +1,133 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreezeBase(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two
+	releaseCut := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("main"))
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+
+	// av stack freeze-base is the same command as av freeze-base.
+	RequireAv(t, "stack", "freeze-base")
+	require.Contains(t, RequireAv(t, "tree").Stdout, "base frozen at "+releaseCut.String()[:7])
+
+	// The trunk moves on after the release cut.
+	var newTrunk plumbing.Hash
+	repo.WithCheckoutBranch(t, "refs/heads/main", func() {
+		repo.CommitFile(t, "main.txt", "3a\n")
+		newTrunk = repo.GetCommitAtRef(t, plumbing.HEAD)
+		repo.Git(t, "push", "origin", "main")
+	})
+
+	// The stack stays on the frozen base.
+	RequireAv(t, "sync", "--rebase-to-trunk", "--push=no", "--prune=no")
+	require.Equal(t, releaseCut.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "one^")))
+
+	RequireAv(t, "unfreeze-base")
+	RequireAv(t, "sync", "--rebase-to-trunk", "--push=no", "--prune=no")
+	require.Equal(t, newTrunk.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "one^")))
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "two^")),
+	)
+}
+
+func TestBranchOnTag(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// The trunk moves on after the release is tagged.
+	release := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("main"))
+	repo.Git(t, "tag", "v1.0")
+	repo.CommitFile(t, "main.txt", "3a\n")
+	repo.Git(t, "push", "origin", "main")
+
+	// The hotfix stack is built on the tag.
+	RequireAv(t, "branch", "hotfix", "v1.0")
+	require.Equal(t, release.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD")))
+	repo.CommitFile(t, "hotfix.txt", "1a\n")
+	require.Contains(t, RequireAv(t, "tree").Stdout, "based on v1.0")
+	hotfix, _ := repo.OpenDB(t).ReadTx().Branch("hotfix")
+	require.Equal(t, "main", hotfix.Parent.Name)
+	require.True(t, hotfix.Parent.Trunk)
+	require.Equal(t, release.String(), hotfix.FrozenBase)
+	require.Equal(t, "v1.0", hotfix.BaseRef)
+
+	// The stack stays on the tag.
+	RequireAv(t, "sync", "--rebase-to-trunk", "--push=no", "--prune=no")
+	require.Equal(t, release.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "hotfix^")))
+
+	// The release branch is created later, and the stack is retargeted to it.
+	repo.Git(t, "branch", "release-1.0", "v1.0")
+	repo.WithCheckoutBranch(t, "refs/heads/release-1.0", func() {
+		repo.CommitFile(t, "release.txt", "4a\n")
+	})
+	repo.Git(t, "push", "origin", "release-1.0")
+	repo.AppendAvConfig(t, "additionalTrunkBranches:\n  - release-1.0\n")
+
+	RequireAv(t, "reparent", "--parent", "release-1.0")
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("release-1.0")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "hotfix^")),
+	)
+	hotfix, _ = repo.OpenDB(t).ReadTx().Branch("hotfix")
+	require.Equal(t, "release-1.0", hotfix.Parent.Name)
+	require.True(t, hotfix.Parent.Trunk)
+	require.Empty(t, hotfix.FrozenBase)
+	require.Empty(t, hotfix.BaseRef)
+}
+
+func TestReparentOntoTag(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	release := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("main"))
+	repo.Git(t, "tag", "v1.0")
+	repo.CommitFile(t, "main.txt", "3a\n")
+	repo.Git(t, "push", "origin", "main")
+
+	// main -> fix -> fix-2 is moved onto the tag.
+	RequireAv(t, "branch", "fix")
+	repo.CommitFile(t, "fix.txt", "1a\n")
+	RequireAv(t, "branch", "fix-2")
+	repo.CommitFile(t, "fix-2.txt", "2a\n")
+	repo.Git(t, "checkout", "fix")
+
+	RequireAv(t, "reparent", "--parent", "v1.0")
+	require.Equal(t, release.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "fix^")))
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("fix")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "fix-2^")),
+	)
+	fix, _ := repo.OpenDB(t).ReadTx().Branch("fix")
+	require.Equal(t, "main", fix.Parent.Name)
+	require.True(t, fix.Parent.Trunk)
+	require.Equal(t, release.String(), fix.FrozenBase)
+	require.Equal(t, "v1.0", fix.BaseRef)
+
+	// The stack stays on the tag.
+	RequireAv(t, "sync", "--rebase-to-trunk", "--push=no", "--prune=no")
+	require.Equal(t, release.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "fix^")))
+}
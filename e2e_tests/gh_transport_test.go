@@ -0,0 +1,41 @@
+package e2e_tests
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhTransportWithoutGhCLI(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	pushWithPullRequests(t, repo, server, "one")
+
+	require.NoError(t, os.WriteFile(
+		filepath.Join(repo.GitDir, "av", "config.yml"),
+		[]byte(fmt.Sprintf("github:\n    baseUrl: %q\n    transport: gh\n", server.URL)),
+		0644,
+	))
+	// Only git is on PATH, so the gh CLI is not found.
+	gitPath, err := exec.LookPath("git")
+	require.NoError(t, err)
+	binDir := t.TempDir()
+	require.NoError(t, os.Symlink(gitPath, filepath.Join(binDir, "git")))
+	t.Setenv("PATH", binDir)
+
+	// Every use of the GitHub client reports the error instead of using a nil client.
+	output := Av(t, "--non-interactive", "sync", "--all", "--push=never")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.NotContains(t, output.Stderr, "panic")
+	require.Contains(t, output.Stderr, "the gh CLI is not found in PATH")
+}
@@ -0,0 +1,54 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooksBranchCreate(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	logFile := filepath.Join(t.TempDir(), "hooks.log")
+	repo.AppendAvConfig(t, `hooks:
+  preBranchCreate:
+    - 'case "$AV_BRANCH" in feat/*) ;; *) echo "branch names must start with feat/"; exit 1 ;; esac'
+  postBranchCreate:
+    - 'echo "$AV_HOOK $AV_BRANCH $AV_PARENT" >> `+logFile+`'
+`)
+
+	output := Av(t, "branch", "one")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "branch names must start with feat/")
+	require.NotContains(t, repo.Git(t, "branch", "--list", "one"), "one")
+
+	RequireAv(t, "branch", "feat/one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "feat/two")
+
+	log, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		"post-branch-create feat/one main\npost-branch-create feat/two feat/one\n",
+		string(log),
+	)
+}
+
+func TestHooksRunAv(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// A hook that runs av must not wait for the av command that runs the hook.
+	repo.AppendAvConfig(t, `hooks:
+  preBranchCreate:
+    - '`+avCmdPath+` tree > /dev/null'
+`)
+
+	RequireAv(t, "branch", "one")
+	require.Equal(t, "one", repo.CurrentBranch(t).Short())
+}
@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
 const (
 	// These are ugly, but this is easy way to tell which query is being used.
-	prQuery = "query($after:String$baseRefName:String$first:Int!$headRefName:String$owner:String!$repo:String!$states:[PullRequestState!]){repository(owner: $owner, name: $repo){pullRequests(states: $states, headRefName: $headRefName, baseRefName: $baseRefName, first: $first, after: $after){nodes{id,number,headRefName,baseRefName,isDraft,permalink,state,title,body,mergeCommit{oid},timelineItems(last: 10, itemTypes: CLOSED_EVENT){nodes{... on ClosedEvent{closer{... on Commit{oid}}}}}},pageInfo{endCursor,hasNextPage,hasPreviousPage,startCursor}}}}"
+	prNodeQuery      = "query($id:ID!){node(id: $id){... on PullRequest{id,number,headRefName,baseRefName,isDraft,isInMergeQueue,permalink,state,title,body,mergeCommit{oid},timelineItems(last: 10, itemTypes: CLOSED_EVENT){nodes{... on ClosedEvent{closer{... on Commit{oid}}}}}}}}"
+	updatePRMutation = "mutation($input:UpdatePullRequestInput!){updatePullRequest(input: $input){pullRequest{id,number,headRefName,baseRefName,isDraft,isInMergeQueue,permalink,state,title,body,mergeCommit{oid},timelineItems(last: 10, itemTypes: CLOSED_EVENT){nodes{... on ClosedEvent{closer{... on Commit{oid}}}}}}}}"
+	prNumberQuery    = "query($number:Int!$owner:String!$repo:String!){repository(owner: $owner, name: $repo){pullRequest(number: $number){id,number,headRefName,baseRefName,isDraft,isInMergeQueue,permalink,state,title,body,mergeCommit{oid},timelineItems(last: 10, itemTypes: CLOSED_EVENT){nodes{... on ClosedEvent{closer{... on Commit{oid}}}}}}}}"
+	prQuery          = "query($after:String$baseRefName:String$first:Int!$headRefName:String$owner:String!$repo:String!$states:[PullRequestState!]){repository(owner: $owner, name: $repo){pullRequests(states: $states, headRefName: $headRefName, baseRefName: $baseRefName, first: $first, after: $after){nodes{id,number,headRefName,baseRefName,isDraft,isInMergeQueue,permalink,state,title,body,mergeCommit{oid},timelineItems(last: 10, itemTypes: CLOSED_EVENT){nodes{... on ClosedEvent{closer{... on Commit{oid}}}}}},pageInfo{endCursor,hasNextPage,hasPreviousPage,startCursor}}}}"
 )
 
 func RunMockGitHubServer(t *testing.T) *mockGitHubServer {
@@ -34,11 +38,20 @@ type mockPR struct {
 	BaseRefName string
 	IsDraft     bool
 	State       string
-	Title       string
-	Body        string
+	// True if the pull request is in the merge queue.
+	InMergeQueue bool
+	Title        string
+	Body         string
 
 	MergeCommitOID  string
 	ClosedCommitOID string
+
+	// The commits of the head and the base branches that GitHub sees.
+	HeadRefOid string
+	BaseRefOid string
+
+	// The bodies of the comments added to the pull request.
+	Comments []string
 }
 
 type graphqlRequest struct {
@@ -66,45 +79,112 @@ func (s *mockGitHubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Query == prNumberQuery {
+		s.t.Logf("Received PR number query: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handlePRNumberQuery(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if req.Query == prNodeQuery {
+		s.t.Logf("Received PR node query: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handlePRNodeQuery(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if strings.HasPrefix(req.Query, "query($ids:[ID!]!){nodes(ids: $ids){... on PullRequest{") {
+		s.t.Logf("Received PR nodes query: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handlePRNodesQuery(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if strings.HasPrefix(req.Query, "query($id:ID!){node(id: $id){... on PullRequest{comments(") {
+		s.t.Logf("Received PR comments query: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handlePRCommentsQuery(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if strings.HasPrefix(req.Query, "query($id:ID!){node(id: $id){... on PullRequest{baseRefName,baseRefOid,") {
+		s.t.Logf("Received PR refs query: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handlePRRefsQuery(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if req.Query == updatePRMutation {
+		s.t.Logf("Received PR update mutation: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handleUpdatePRMutation(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if strings.HasPrefix(req.Query, "mutation($input:AddCommentInput!)") {
+		s.t.Logf("Received add comment mutation: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handleAddCommentMutation(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if strings.HasPrefix(req.Query, "mutation($input:MarkPullRequestReadyForReviewInput!)") {
+		s.t.Logf("Received mark PR ready mutation: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handleMarkPRReadyMutation(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if strings.HasPrefix(req.Query, "mutation($input:UpdateIssueCommentInput!)") {
+		s.t.Logf("Received update comment mutation: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handleUpdateCommentMutation(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if strings.HasPrefix(req.Query, "mutation($input:ClosePullRequestInput!)") {
+		s.t.Logf("Received close PR mutation: %s", req.Variables)
+		if err := json.NewEncoder(w).Encode(s.handleClosePRMutation(req)); err != nil {
+			s.t.Logf("Failed to encode response: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
 	s.t.Logf("Received unexpected query: %s", req.Query)
 	w.WriteHeader(http.StatusInternalServerError)
 }
 
 func (s *mockGitHubServer) handlePRQuery(req graphqlRequest) graphqlResponse {
-	headRefName := req.Variables["headRefName"].(string)
+	headRefName, _ := req.Variables["headRefName"].(string)
+	baseRefName, _ := req.Variables["baseRefName"].(string)
 	var prs []interface{}
 	for _, pr := range s.pulls {
-		if pr.HeadRefName != headRefName {
+		if headRefName != "" && pr.HeadRefName != headRefName {
 			continue
 		}
-		gqlpr := map[string]interface{}{
-			"id":          pr.ID,
-			"number":      pr.Number,
-			"headRefName": pr.HeadRefName,
-			"baseRefName": pr.BaseRefName,
-			"isDraft":     pr.IsDraft,
-			"permalink":   fmt.Sprintf("https://github.invalid/mock/mock/pulls/%d", pr.Number),
-			"state":       pr.State,
-			"title":       pr.Title,
-			"body":        pr.Body,
-		}
-		if pr.MergeCommitOID != "" {
-			gqlpr["mergeCommit"] = map[string]string{"oid": pr.MergeCommitOID}
-		}
-		if pr.ClosedCommitOID != "" {
-			gqlpr["timelineItems"] = map[string]interface{}{
-				"nodes": []interface{}{
-					map[string]interface{}{
-						"__typename": "ClosedEvent",
-						"closer": map[string]interface{}{
-							"__typename": "Commit",
-							"oid":        pr.ClosedCommitOID,
-						},
-					},
-				},
-			}
+		if baseRefName != "" && pr.BaseRefName != baseRefName {
+			continue
 		}
-		prs = append(prs, gqlpr)
+		prs = append(prs, pr.toGraphQL())
 	}
 	return graphqlResponse{
 		Data: map[string]interface{}{
@@ -116,3 +196,192 @@ func (s *mockGitHubServer) handlePRQuery(req graphqlRequest) graphqlResponse {
 		},
 	}
 }
+
+func (s *mockGitHubServer) handlePRNumberQuery(req graphqlRequest) graphqlResponse {
+	number := int(req.Variables["number"].(float64))
+	var gqlpr interface{}
+	for _, pr := range s.pulls {
+		if pr.Number == number {
+			gqlpr = pr.toGraphQL()
+		}
+	}
+	return graphqlResponse{Data: map[string]interface{}{
+		"repository": map[string]interface{}{"pullRequest": gqlpr},
+	}}
+}
+
+func (s *mockGitHubServer) handlePRNodeQuery(req graphqlRequest) graphqlResponse {
+	id := req.Variables["id"].(string)
+	for _, pr := range s.pulls {
+		if pr.ID == id {
+			return graphqlResponse{Data: map[string]interface{}{"node": pr.toGraphQL()}}
+		}
+	}
+	return graphqlResponse{Data: map[string]interface{}{"node": nil}}
+}
+
+func (s *mockGitHubServer) handlePRRefsQuery(req graphqlRequest) graphqlResponse {
+	id := req.Variables["id"].(string)
+	for _, pr := range s.pulls {
+		if pr.ID == id {
+			return graphqlResponse{Data: map[string]interface{}{"node": map[string]interface{}{
+				"baseRefName": pr.BaseRefName,
+				"baseRefOid":  pr.BaseRefOid,
+				"headRefName": pr.HeadRefName,
+				"headRefOid":  pr.HeadRefOid,
+			}}}
+		}
+	}
+	return graphqlResponse{Data: map[string]interface{}{"node": nil}}
+}
+
+func (s *mockGitHubServer) handlePRNodesQuery(req graphqlRequest) graphqlResponse {
+	ids, _ := req.Variables["ids"].([]interface{})
+	nodes := []interface{}{}
+	for _, id := range ids {
+		var node interface{}
+		for _, pr := range s.pulls {
+			if pr.ID == id {
+				node = pr.toGraphQL()
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return graphqlResponse{Data: map[string]interface{}{"nodes": nodes}}
+}
+
+func (s *mockGitHubServer) handleUpdatePRMutation(req graphqlRequest) graphqlResponse {
+	input := req.Variables["input"].(map[string]interface{})
+	for i := range s.pulls {
+		pr := &s.pulls[i]
+		if pr.ID != input["pullRequestId"] {
+			continue
+		}
+		if v, ok := input["baseRefName"].(string); ok {
+			pr.BaseRefName = v
+		}
+		if v, ok := input["title"].(string); ok {
+			pr.Title = v
+		}
+		if v, ok := input["body"].(string); ok {
+			pr.Body = v
+		}
+		return graphqlResponse{Data: map[string]interface{}{
+			"updatePullRequest": map[string]interface{}{"pullRequest": pr.toGraphQL()},
+		}}
+	}
+	return graphqlResponse{Data: map[string]interface{}{"updatePullRequest": nil}}
+}
+
+func (s *mockGitHubServer) handleAddCommentMutation(req graphqlRequest) graphqlResponse {
+	input := req.Variables["input"].(map[string]interface{})
+	for i := range s.pulls {
+		pr := &s.pulls[i]
+		if pr.ID == input["subjectId"] {
+			body, _ := input["body"].(string)
+			pr.Comments = append(pr.Comments, body)
+		}
+	}
+	return graphqlResponse{Data: map[string]interface{}{
+		"addComment": map[string]interface{}{"clientMutationId": ""},
+	}}
+}
+
+func (s *mockGitHubServer) handleMarkPRReadyMutation(req graphqlRequest) graphqlResponse {
+	input := req.Variables["input"].(map[string]interface{})
+	for i := range s.pulls {
+		pr := &s.pulls[i]
+		if pr.ID != input["pullRequestId"] {
+			continue
+		}
+		pr.IsDraft = false
+		return graphqlResponse{Data: map[string]interface{}{
+			"markPullRequestReadyForReview": map[string]interface{}{"pullRequest": pr.toGraphQL()},
+		}}
+	}
+	return graphqlResponse{Data: map[string]interface{}{"markPullRequestReadyForReview": nil}}
+}
+
+// The comments are identified by the pull request ID and their index (e.g., "nodeid-one/0"). All
+// of them are authored by the viewer.
+func (s *mockGitHubServer) handlePRCommentsQuery(req graphqlRequest) graphqlResponse {
+	id := req.Variables["id"].(string)
+	comments := []interface{}{}
+	for _, pr := range s.pulls {
+		if pr.ID != id {
+			continue
+		}
+		for i, body := range pr.Comments {
+			comments = append(comments, map[string]interface{}{
+				"id":              fmt.Sprintf("%s/%d", pr.ID, i),
+				"body":            body,
+				"viewerDidAuthor": true,
+			})
+		}
+	}
+	return graphqlResponse{Data: map[string]interface{}{
+		"node": map[string]interface{}{"comments": map[string]interface{}{"nodes": comments}},
+	}}
+}
+
+func (s *mockGitHubServer) handleUpdateCommentMutation(req graphqlRequest) graphqlResponse {
+	input := req.Variables["input"].(map[string]interface{})
+	for i := range s.pulls {
+		pr := &s.pulls[i]
+		for j := range pr.Comments {
+			if fmt.Sprintf("%s/%d", pr.ID, j) == input["id"] {
+				pr.Comments[j], _ = input["body"].(string)
+			}
+		}
+	}
+	return graphqlResponse{Data: map[string]interface{}{
+		"updateIssueComment": map[string]interface{}{"clientMutationId": ""},
+	}}
+}
+
+func (s *mockGitHubServer) handleClosePRMutation(req graphqlRequest) graphqlResponse {
+	input := req.Variables["input"].(map[string]interface{})
+	for i := range s.pulls {
+		pr := &s.pulls[i]
+		if pr.ID != input["pullRequestId"] {
+			continue
+		}
+		pr.State = "CLOSED"
+		return graphqlResponse{Data: map[string]interface{}{
+			"closePullRequest": map[string]interface{}{"pullRequest": pr.toGraphQL()},
+		}}
+	}
+	return graphqlResponse{Data: map[string]interface{}{"closePullRequest": nil}}
+}
+
+func (pr mockPR) toGraphQL() map[string]interface{} {
+	gqlpr := map[string]interface{}{
+		"id":             pr.ID,
+		"number":         pr.Number,
+		"headRefName":    pr.HeadRefName,
+		"baseRefName":    pr.BaseRefName,
+		"isDraft":        pr.IsDraft,
+		"isInMergeQueue": pr.InMergeQueue,
+		"permalink":      fmt.Sprintf("https://github.invalid/mock/mock/pulls/%d", pr.Number),
+		"state":          pr.State,
+		"title":          pr.Title,
+		"body":           pr.Body,
+	}
+	if pr.MergeCommitOID != "" {
+		gqlpr["mergeCommit"] = map[string]string{"oid": pr.MergeCommitOID}
+	}
+	if pr.ClosedCommitOID != "" {
+		gqlpr["timelineItems"] = map[string]interface{}{
+			"nodes": []interface{}{
+				map[string]interface{}{
+					"__typename": "ClosedEvent",
+					"closer": map[string]interface{}{
+						"__typename": "Commit",
+						"oid":        pr.ClosedCommitOID,
+					},
+				},
+			},
+		}
+	}
+	return gqlpr
+}
@@ -0,0 +1,40 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextPrev(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three")
+
+	RequireAv(t, "prev", "--first")
+	RequireCurrentBranchName(t, repo, "refs/heads/one")
+	RequireAv(t, "next", "2")
+	RequireCurrentBranchName(t, repo, "refs/heads/three")
+	RequireAv(t, "prev", "2")
+	RequireCurrentBranchName(t, repo, "refs/heads/one")
+
+	// Moving past the end of the stack is an error and doesn't move HEAD.
+	require.NotEqual(t, 0, Av(t, "next", "3").ExitCode)
+	RequireCurrentBranchName(t, repo, "refs/heads/one")
+	require.NotEqual(t, 0, Av(t, "prev", "2").ExitCode)
+	RequireCurrentBranchName(t, repo, "refs/heads/one")
+
+	RequireAv(t, "next", "--last")
+	RequireCurrentBranchName(t, repo, "refs/heads/three")
+	// Already at the end of the stack.
+	RequireAv(t, "next", "--last")
+	RequireCurrentBranchName(t, repo, "refs/heads/three")
+	require.NotEqual(t, 0, Av(t, "next").ExitCode)
+}
@@ -0,0 +1,175 @@
+package e2e_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/stretchr/testify/require"
+)
+
+// lastJSONError parses the JSON error printed on the last line of stderr with --json.
+func lastJSONError(t *testing.T, stderr string) (class string, exitCode int) {
+	lines := strings.Split(strings.TrimRight(stderr, "\n"), "\n")
+	var out struct {
+		Error struct {
+			Class    string `json:"class"`
+			ExitCode int    `json:"exitCode"`
+			Message  string `json:"message"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &out), stderr)
+	require.NotEmpty(t, out.Error.Message)
+	return out.Error.Class, out.Error.ExitCode
+}
+
+func TestExitCodeConflict(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "my-file", "2a\n", gittest.WithMessage("Commit 2a"))
+	repo.Git(t, "checkout", "stack-1")
+	repo.CommitFile(t, "my-file", "1b\n", gittest.WithMessage("Commit 1b"))
+	repo.Git(t, "checkout", "stack-2")
+
+	out := Av(t, "--json", "--non-interactive", "restack")
+	require.Equal(t, errutils.FailureConflict.ExitCode, out.ExitCode)
+	class, exitCode := lastJSONError(t, out.Stderr)
+	require.Equal(t, "conflict", class)
+	require.Equal(t, errutils.FailureConflict.ExitCode, exitCode)
+}
+
+func TestExitCodeDirtyWorktree(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "my-file"), []byte("wip\n"), 0644))
+
+	out := Av(t, "undo")
+	require.Equal(t, errutils.FailureDirtyWorktree.ExitCode, out.ExitCode)
+	require.Contains(t, out.Stderr, "uncommitted changes")
+}
+
+func TestExitCodeDirtyWorktreeRestack(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "other-file", "2a\n", gittest.WithMessage("Commit 2a"))
+	repo.CheckoutBranch(t, "refs/heads/stack-1")
+	repo.CommitFile(t, "my-file", "1b\n", gittest.WithMessage("Commit 1b"))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "my-file"), []byte("wip\n"), 0644))
+	before := repo.Git(t, "for-each-ref", "refs/heads")
+
+	for _, args := range [][]string{{"restack"}, {"reorder"}} {
+		out := Av(t, args...)
+		require.Equal(t, errutils.FailureDirtyWorktree.ExitCode, out.ExitCode, args)
+		require.Contains(t, out.Stdout+out.Stderr, "uncommitted changes", args)
+		require.NoFileExists(t, filepath.Join(repo.GitDir, "av", "stack-restack.state.json"))
+		require.NoFileExists(t, filepath.Join(repo.GitDir, "av", "stack-reorder.state.json"))
+		require.Equal(t, before, repo.Git(t, "for-each-ref", "refs/heads"))
+		content, err := os.ReadFile(filepath.Join(repo.RepoDir, "my-file"))
+		require.NoError(t, err)
+		require.Equal(t, "wip\n", string(content))
+	}
+}
+
+func TestExitCodeMetadataMismatch(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.Git(t, "checkout", "-b", "not-adopted")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+
+	out := Av(t, "--json", "restack")
+	require.Equal(t, errutils.FailureMetadataMismatch.ExitCode, out.ExitCode)
+	class, _ := lastJSONError(t, out.Stderr)
+	require.Equal(t, "metadata-mismatch", class)
+}
+
+func TestExitCodeAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+
+	out := Av(t, "--json", "sync", "--push=never")
+	require.Equal(t, errutils.FailureAuth.ExitCode, out.ExitCode)
+	class, _ := lastJSONError(t, out.Stderr)
+	require.Equal(t, "auth", class)
+}
+
+func TestNonInteractive(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// The editor must not be opened.
+	marker := filepath.Join(t.TempDir(), "editor-opened")
+	editor := filepath.Join(t.TempDir(), "editor.sh")
+	require.NoError(t, os.WriteFile(editor, []byte("#!/bin/sh\ntouch "+marker+"\n"), 0755))
+	t.Setenv("GIT_EDITOR", editor)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CreateFile(t, "my-file", "1a\n")
+	repo.Git(t, "add", "my-file")
+
+	out := Av(t, "--non-interactive", "commit")
+	require.Equal(t, errutils.FailureInteractionRequired.ExitCode, out.ExitCode)
+	require.NoFileExists(t, marker)
+	out = Av(t, "--non-interactive", "--json", "commit", "--amend", "--edit")
+	require.Equal(t, errutils.FailureInteractionRequired.ExitCode, out.ExitCode)
+	class, _ := lastJSONError(t, out.Stderr)
+	require.Equal(t, "interaction-required", class)
+	require.NoFileExists(t, marker)
+
+	RequireAv(t, "--non-interactive", "commit", "-m", "Commit 1a")
+
+	// The environment variable works like the flag.
+	t.Setenv("AV_NON_INTERACTIVE", "1")
+	require.Equal(t, errutils.FailureInteractionRequired.ExitCode, Av(t, "switch").ExitCode)
+	require.Equal(t, errutils.FailureInteractionRequired.ExitCode, Av(t, "sync", "--push=ask").ExitCode)
+	require.NoFileExists(t, marker)
+}
+
+func TestNonInteractiveFetchNeedsCredentials(t *testing.T) {
+	// A remote that asks for the credentials on every request.
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="av-test"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer remote.Close()
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+	repo.Git(t, "config", "credential.helper", "")
+	t.Setenv("GIT_ASKPASS", "")
+	t.Setenv("SSH_ASKPASS", "")
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	repo.Git(t, "remote", "set-url", "origin", remote.URL+"/repo.git")
+
+	out := Av(t, "--non-interactive", "--json", "sync", "--push=never")
+	require.Equal(t, errutils.FailureInteractionRequired.ExitCode, out.ExitCode)
+	class, _ := lastJSONError(t, out.Stderr)
+	require.Equal(t, "interaction-required", class)
+}
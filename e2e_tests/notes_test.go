@@ -0,0 +1,83 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchNotes(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+
+	RequireAv(t, "notes", "set", "--branch", "one", "Tested on staging\nSee the load test results")
+	require.Equal(t, "Tested on staging\nSee the load test results", strings.TrimSpace(RequireAv(t, "notes", "show", "one").Stdout))
+	require.Equal(t, "", strings.TrimSpace(RequireAv(t, "notes", "show").Stdout))
+
+	// The note is kept after the branch is rewritten.
+	repo.Git(t, "checkout", "one")
+	repo.CommitFile(t, "one.txt", "one amended", gittest.WithAmend())
+	RequireAv(t, "restack")
+	require.Contains(t, RequireAv(t, "tree").Stdout, "note: Tested on staging")
+
+	RequireAv(t, "notes", "publish")
+	require.NotEmpty(t, repo.Git(t, "ls-remote", "origin", "refs/notes/av"))
+
+	// Publishing again merges the notes on the remote (av stack publish-notes is the same
+	// command as av notes publish).
+	RequireAv(t, "notes", "set", "--branch", "two", "Needs a second review")
+	RequireAv(t, "stack", "publish-notes")
+	require.Equal(
+		t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "refs/notes/av")),
+		strings.Fields(repo.Git(t, "ls-remote", "origin", "refs/notes/av"))[0],
+	)
+
+	RequireAv(t, "notes", "set", "--branch", "one", "")
+	require.Equal(t, "", strings.TrimSpace(RequireAv(t, "notes", "show", "one").Stdout))
+}
+
+func TestBranchNotesWithStack(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	pushWithPullRequests(t, repo, server, "one")
+	RequireAv(t, "notes", "set", "Tested on staging")
+
+	// av pr pushes the notes with the branch.
+	repo.CommitFile(t, "one.txt", "1b\n")
+	RequireAv(t, "pr")
+	require.Equal(
+		t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "refs/notes/av")),
+		strings.Fields(repo.Git(t, "ls-remote", "origin", "refs/notes/av"))[0],
+	)
+
+	// av sync fetches the notes pushed from another clone.
+	repo.Git(t, "update-ref", "-d", "refs/notes/av")
+	repo.Git(t, "update-ref", "-d", "refs/notes/remotes/origin/av")
+	require.Equal(t, "", strings.TrimSpace(RequireAv(t, "notes", "show").Stdout))
+	RequireAv(t, "sync", "--push=no", "--prune=no")
+	require.Equal(t, "Tested on staging", strings.TrimSpace(RequireAv(t, "notes", "show").Stdout))
+
+	// av sync pushes the changed notes with the branches.
+	RequireAv(t, "notes", "set", "Tested on production")
+	repo.CommitFile(t, "one.txt", "1c\n", gittest.WithAmend())
+	RequireAv(t, "sync", "--push=yes", "--prune=no")
+	require.Equal(
+		t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "refs/notes/av")),
+		strings.Fields(repo.Git(t, "ls-remote", "origin", "refs/notes/av"))[0],
+	)
+}
@@ -0,0 +1,83 @@
+package e2e_tests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOfflinePRStatus(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+
+	fetchedAt := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	one, _ := tx.Branch("one")
+	one.PullRequest = &meta.PullRequest{
+		ID:        "nodeid-1",
+		Number:    1,
+		State:     "OPEN",
+		FetchedAt: fetchedAt,
+		Review: &meta.PullRequestReview{
+			FetchedAt: fetchedAt,
+			Mergeable: "MERGEABLE",
+			Approvers: []string{"octocat"},
+			Checks: []meta.PullRequestCheck{
+				{Name: "test", Result: "pass", Required: true},
+			},
+		},
+	}
+	tx.SetBranch(one)
+	two, _ := tx.Branch("two")
+	two.PullRequest = &meta.PullRequest{ID: "nodeid-2", Number: 2, State: "OPEN"}
+	tx.SetBranch(two)
+	require.NoError(t, tx.Commit())
+
+	// The cached statuses are shown without reaching GitHub.
+	out := RequireAv(t, "--offline", "pr", "status", "--stack", "--json")
+	var statuses []struct {
+		Branch         string     `json:"branch"`
+		Number         int64      `json:"number"`
+		Mergeable      string     `json:"mergeable"`
+		Approvers      []string   `json:"approvers"`
+		RequiredChecks []struct{} `json:"requiredChecks"`
+		Green          bool       `json:"green"`
+		Cached         bool       `json:"cached"`
+		FetchedAt      *time.Time `json:"fetchedAt"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out.Stdout), &statuses))
+	require.Len(t, statuses, 2)
+	require.Equal(t, "one", statuses[0].Branch)
+	require.EqualValues(t, 1, statuses[0].Number)
+	require.Equal(t, "MERGEABLE", statuses[0].Mergeable)
+	require.Equal(t, []string{"octocat"}, statuses[0].Approvers)
+	require.Len(t, statuses[0].RequiredChecks, 1)
+	require.True(t, statuses[0].Green)
+	require.True(t, statuses[0].Cached)
+	require.NotNil(t, statuses[0].FetchedAt)
+	require.True(t, fetchedAt.Equal(*statuses[0].FetchedAt))
+	require.Equal(t, "two", statuses[1].Branch)
+	require.EqualValues(t, 2, statuses[1].Number)
+	require.True(t, statuses[1].Cached)
+	require.Nil(t, statuses[1].FetchedAt)
+
+	out = RequireAv(t, "--offline", "pr", "status")
+	require.Contains(t, out.Stdout, "two #2")
+	require.Contains(t, out.Stdout, "(stale)")
+	require.Contains(t, out.Stderr, "Offline")
+
+	// The commands that need the network fail right away.
+	out = Av(t, "--offline", "sync")
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Contains(t, out.Stderr, "av restack")
+}
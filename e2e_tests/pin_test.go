@@ -0,0 +1,61 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinBranch(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two -> three
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "3a\n")
+	pinnedCommit := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two"))
+
+	RequireAv(t, "pin", "two")
+	require.Contains(t, RequireAv(t, "tree").Stdout, "pinned at "+pinnedCommit.String()[:7])
+
+	repo.Git(t, "checkout", "one")
+	repo.CreateFile(t, "one.txt", "1a\n1b\n")
+	RequireAv(t, "commit", "-a", "-m", "1b")
+
+	// two is pinned, so it's not rebased onto the new commit of one. three is rebased onto the
+	// pinned commit (which is a no-op).
+	repo.Git(t, "checkout", "three")
+	RequireAv(t, "restack")
+	require.Equal(t, pinnedCommit, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two")))
+	require.Equal(
+		t,
+		pinnedCommit.String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "three^")),
+	)
+
+	// Commits are not allowed on a pinned branch.
+	repo.Git(t, "checkout", "two")
+	repo.CreateFile(t, "two.txt", "2a\n2b\n")
+	require.NotEqual(t, 0, Av(t, "commit", "-a", "-m", "2b").ExitCode)
+	repo.Git(t, "checkout", "two.txt")
+
+	RequireAv(t, "unpin")
+	RequireAv(t, "restack")
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "two^")),
+	)
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "three^")),
+	)
+}
@@ -0,0 +1,53 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrAttach(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main"},
+		mockPR{ID: "nodeid-2", Number: 2, State: "OPEN", HeadRefName: "two", BaseRefName: "main"},
+		mockPR{ID: "nodeid-3", Number: 3, State: "CLOSED", HeadRefName: "two", BaseRefName: "one"},
+	)
+
+	// The pull request must be for the branch and open.
+	require.NotEqual(t, 0, Av(t, "pr", "attach", "1").ExitCode)
+	require.NotEqual(t, 0, Av(t, "pr", "attach", "3").ExitCode)
+
+	// Auto-discovered from the current branch.
+	out := RequireAv(t, "pr", "attach")
+	require.Contains(t, out.Stderr, "#2")
+	require.Contains(t, out.Stderr, "the next av pr updates it")
+	two, ok := repo.OpenDB(t).ReadTx().Branch("two")
+	require.True(t, ok)
+	require.Equal(t, "nodeid-2", two.PullRequest.ID)
+	require.Equal(t, int64(2), two.PullRequest.GetNumber())
+
+	// By number, for another branch.
+	RequireAv(t, "pr", "attach", "--branch", "one", "#1")
+	one, ok := repo.OpenDB(t).ReadTx().Branch("one")
+	require.True(t, ok)
+	require.Equal(t, "nodeid-1", one.PullRequest.ID)
+
+	// An open pull request is not replaced without --force.
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-4", Number: 4, State: "OPEN", HeadRefName: "two", BaseRefName: "one"},
+	)
+	require.NotEqual(t, 0, Av(t, "pr", "attach", "4").ExitCode)
+	RequireAv(t, "pr", "attach", "4", "--force")
+	two, _ = repo.OpenDB(t).ReadTx().Branch("two")
+	require.Equal(t, "nodeid-4", two.PullRequest.ID)
+}
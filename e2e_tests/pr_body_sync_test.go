@@ -0,0 +1,59 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPRBodySync(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	//     one -> two
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+
+	body := "Some description.\n\n<!-- av pr metadata\n```\n{}\n```\n-->"
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main", Body: body},
+		mockPR{ID: "nodeid-2", Number: 2, State: "OPEN", HeadRefName: "two", BaseRefName: "one", Body: body},
+	)
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	for _, pr := range server.pulls {
+		br, _ := tx.Branch(pr.HeadRefName)
+		br.PullRequest = &meta.PullRequest{ID: pr.ID, Number: int64(pr.Number), State: "OPEN"}
+		tx.SetBranch(br)
+	}
+	require.NoError(t, tx.Commit())
+
+	repo.AppendAvConfig(t, `
+pullRequest:
+    writeStack: true
+`)
+
+	RequireAv(t, "pr", "body", "sync")
+
+	// The stack and the metadata are written, and the description is kept.
+	require.Contains(t, server.pulls[1].Body, "Depends on #1")
+	require.Contains(t, server.pulls[1].Body, "Some description.")
+	_, prMeta, err := actions.ParsePRBody(server.pulls[1].Body)
+	require.NoError(t, err)
+	require.Equal(t, "one", prMeta.Parent)
+	require.Equal(t, int64(1), prMeta.ParentPull)
+	require.Contains(t, server.pulls[0].Body, "#2")
+
+	// Nothing changes on the second run.
+	bodies := []string{server.pulls[0].Body, server.pulls[1].Body}
+	out := RequireAv(t, "pr", "body", "sync")
+	require.Contains(t, out.Stderr, "is up to date")
+	require.Equal(t, bodies, []string{server.pulls[0].Body, server.pulls[1].Body})
+}
@@ -0,0 +1,41 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPRDiff(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	pushWithPullRequests(t, repo, server, "one", "two")
+	rev := func(name string) string {
+		return strings.TrimSpace(repo.Git(t, "rev-parse", name))
+	}
+	server.pulls[1].HeadRefOid = rev("two")
+	server.pulls[1].BaseRefOid = rev("one")
+
+	output := RequireAv(t, "pr", "diff", "--stat")
+	require.Contains(t, output.Stdout, "two.txt")
+	require.NotContains(t, output.Stdout, "one.txt")
+	require.Contains(t, output.Stderr, "The pull request diff matches the changes of the branch.")
+
+	// The pull request of two still targets main, so GitHub shows the changes of one as well.
+	server.pulls[1].BaseRefName = "main"
+	server.pulls[1].BaseRefOid = rev("main")
+	output = RequireAv(t, "pr", "diff", "--stat")
+	require.Contains(t, output.Stdout, "one.txt")
+	require.Contains(t, output.Stderr, "The pull request shows changes that are not part of the branch")
+	require.Contains(t, output.Stderr, "  - one.txt")
+}
@@ -0,0 +1,42 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestDraftRulesKeepManualDrafts(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+	repo.AppendAvConfig(t, `
+pullRequest:
+    draftRules:
+        rootReady: true
+`)
+
+	// The pull request of one was made a draft by hand.
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	pushWithPullRequests(t, repo, server, "one")
+	server.pulls[0].IsDraft = true
+
+	// The rule says that one is ready, but the draft is kept.
+	output := RequireAv(t, "pr", "--all")
+	require.NotContains(t, output.Stderr, "as ready for review")
+	require.True(t, server.pulls[0].IsDraft)
+
+	// The draft made by the rules is marked as ready.
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	br, _ := tx.Branch("one")
+	br.ReadyAtDepth = 1
+	tx.SetBranch(br)
+	require.NoError(t, tx.Commit())
+	output = RequireAv(t, "pr", "--all")
+	require.Contains(t, output.Stderr, "as ready for review")
+	require.False(t, server.pulls[0].IsDraft)
+}
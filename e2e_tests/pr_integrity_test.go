@@ -0,0 +1,34 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestStackIntegrity(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	RequireAv(t, "pr", "--all", "--dry-run")
+
+	// Amend the parent branch without restacking the child branch. The pull request of two
+	// would contain the original commit of one.
+	repo.Git(t, "checkout", "one")
+	repo.CommitFile(t, "one.txt", "one amended", gittest.WithAmend())
+	// The dry run shows it as a warning, and the submit is refused.
+	output := RequireAv(t, "pr", "--all", "--dry-run")
+	require.Contains(t, output.Stderr, "would contain 1 commit(s) of the stale base one")
+	output = Av(t, "pr", "--all")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "would contain")
+
+	RequireAv(t, "restack")
+	output = RequireAv(t, "pr", "--all", "--dry-run")
+	require.NotContains(t, output.Stderr, "would contain")
+}
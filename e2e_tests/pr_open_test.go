@@ -0,0 +1,59 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPROpen(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// The browser records the opened URLs.
+	dir := t.TempDir()
+	opened := filepath.Join(dir, "opened.txt")
+	browser := filepath.Join(dir, "browser")
+	require.NoError(t, os.WriteFile(browser, []byte("#!/bin/sh\necho \"$1\" >> "+opened+"\n"), 0o755))
+	t.Setenv("BROWSER", browser)
+	openedURLs := func() []string {
+		data, _ := os.ReadFile(opened)
+		return strings.Fields(string(data))
+	}
+
+	// main -> one -> two
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	pushWithPullRequests(t, repo, server, "one", "two")
+
+	// Without --open, no pull request is opened.
+	RequireAv(t, "pr", "--all")
+	require.Empty(t, openedURLs())
+
+	// By default, all the submitted pull requests are opened.
+	RequireAv(t, "pr", "--all", "--open")
+	require.Eventually(t, func() bool { return len(openedURLs()) == 2 }, 5*time.Second, 100*time.Millisecond)
+	require.ElementsMatch(t, []string{
+		"https://github.invalid/mock/mock/pulls/1",
+		"https://github.invalid/mock/mock/pulls/2",
+	}, openedURLs())
+
+	// With pullRequest.submitOpen: bottom, only the bottom-most pull request is opened.
+	require.NoError(t, os.Remove(opened))
+	repo.AppendAvConfig(t, `
+pullRequest:
+    submitOpen: bottom
+`)
+	RequireAv(t, "pr", "--all", "--open")
+	require.Eventually(t, func() bool { return len(openedURLs()) == 1 }, 5*time.Second, 100*time.Millisecond)
+	require.Equal(t, []string{"https://github.invalid/mock/mock/pulls/1"}, openedURLs())
+}
@@ -0,0 +1,49 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrRange(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	for _, name := range []string{"one", "two", "three", "four"} {
+		RequireAv(t, "branch", name)
+		repo.CommitFile(t, name+".txt", name, gittest.WithMessage("Add "+name))
+	}
+	repo.Git(t, "checkout", "two")
+
+	submitted := func(args ...string) []string {
+		output := RequireAv(t, append([]string{"pr", "--all", "--dry-run"}, args...)...)
+		var ret []string
+		for _, name := range []string{"one", "two", "three", "four"} {
+			if strings.Contains(output.Stderr, name+": Add "+name) {
+				ret = append(ret, name)
+			}
+		}
+		return ret
+	}
+	require.Equal(t, []string{"one", "two", "three", "four"}, submitted())
+	require.Equal(t, []string{"two"}, submitted("--only"))
+	require.Equal(t, []string{"one", "two"}, submitted("--downstack"))
+	require.Equal(t, []string{"one", "two"}, submitted("--current"))
+	require.Equal(t, []string{"two", "three", "four"}, submitted("--upstack"))
+	require.Equal(t, []string{"two", "three"}, submitted("--from", "two", "--to", "three"))
+	require.Equal(t, []string{"three", "four"}, submitted("--from", "three"))
+	require.Equal(t, []string{"one", "two", "three"}, submitted("--to", "three"))
+
+	output := Av(t, "pr", "--all", "--from", "three", "--to", "one")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, `branch "three" is not below "one" in the stack`)
+	output = Av(t, "pr", "--all", "--only", "--upstack")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "cannot use --only with --upstack")
+	output = Av(t, "pr", "--upstack")
+	require.NotEqual(t, 0, output.ExitCode)
+
+}
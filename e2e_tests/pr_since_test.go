@@ -0,0 +1,39 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrSince(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one", gittest.WithMessage("Add one"))
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two", gittest.WithMessage("Add two"))
+	base := repo.GetCommitAtRef(t, "refs/heads/two")
+	repo.CommitFile(t, "two.txt", "two\nmore", gittest.WithMessage("Add more to two"))
+
+	// Only two has commits that are not reachable from the commit.
+	output := RequireAv(t, "pr", "--all", "--dry-run", "--since", base.String())
+	require.Contains(t, output.Stderr, "Skipping 1 branch(es) unchanged since "+base.String()+": one")
+	require.NotContains(t, output.Stderr, "one: Add one")
+	require.Contains(t, output.Stderr, "two: Add two")
+
+	// The branches without pull requests are always submitted with last-submit.
+	output = RequireAv(t, "pr", "--all", "--dry-run", "--since", "last-submit")
+	require.Contains(t, output.Stderr, "one: Add one")
+	require.Contains(t, output.Stderr, "two: Add two")
+
+	output = Av(t, "pr", "--all", "--dry-run", "--since", "no-such-ref")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, `invalid --since "no-such-ref"`)
+
+	output = Av(t, "pr", "--since", "last-submit")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "--since can only be used with --all")
+}
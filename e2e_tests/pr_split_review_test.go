@@ -0,0 +1,32 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPRSplitReviewUpdatesComments(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	repo.CommitFile(t, "CODEOWNERS", "api*.go api-owner@example.com\n*.ts ui-owner@example.com\n")
+	repo.Git(t, "push", "origin", "main")
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "api_server.go", "package api\n")
+	repo.CommitFile(t, "app.ts", "export {}\n")
+	pushWithPullRequests(t, repo, server, "one")
+
+	RequireAv(t, "pr", "split-review")
+	require.Len(t, server.pulls[0].Comments, 2)
+	require.Contains(t, server.pulls[0].Comments[0], "- `api_server.go`")
+
+	// Running it again updates the comments instead of posting new ones.
+	repo.CommitFile(t, "api_client.go", "package api\n")
+	RequireAv(t, "pr", "split-review")
+	require.Len(t, server.pulls[0].Comments, 2)
+	require.Contains(t, server.pulls[0].Comments[0], "- `api_client.go`")
+}
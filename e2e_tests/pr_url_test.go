@@ -0,0 +1,45 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestURL(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	//     main -> one -> two -> three
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three")
+	pushWithPullRequests(t, repo, server, "one", "two")
+	// Fetch the pull requests to record their URLs.
+	RequireAv(t, "sync", "--push=no", "--prune=no")
+
+	repo.Git(t, "checkout", "two")
+	require.Equal(t, "https://github.invalid/mock/mock/pulls/2\n", RequireAv(t, "pr", "url").Stdout)
+
+	// The URLs are printed in the stack order, and the branch without a pull request is
+	// skipped.
+	output := RequireAv(t, "pr", "url", "--all")
+	require.Equal(
+		t,
+		"https://github.invalid/mock/mock/pulls/1\nhttps://github.invalid/mock/mock/pulls/2\n",
+		output.Stdout,
+	)
+	require.Contains(t, output.Stderr, "branch three has no pull request")
+
+	repo.Git(t, "checkout", "three")
+	output = Av(t, "pr", "url")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Empty(t, output.Stdout)
+	require.Contains(t, output.Stderr, "no pull requests found")
+}
@@ -0,0 +1,60 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewURL(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+
+	require.NotEqual(t, 0, Av(t, "preview", "set", "not a url").ExitCode)
+
+	// A branch without a pull request.
+	RequireAv(t, "preview", "set", "--branch", "one", "https://one.preview.example.com")
+	require.Equal(t, "https://one.preview.example.com\n", RequireAv(t, "preview", "show", "one").Stdout)
+	require.Contains(t, RequireAv(t, "tree").Stdout, "preview: https://one.preview.example.com")
+
+	// A branch with a pull request gets the preview URL in the pull request body.
+	db := repo.OpenDB(t)
+	server.pulls = append(server.pulls, mockPR{
+		ID:          "nodeid-2",
+		Number:      2,
+		State:       "OPEN",
+		HeadRefName: "two",
+		BaseRefName: "one",
+		Body: actions.AddPRMetadataAndStack(
+			"The description.",
+			actions.PRMetadata{Parent: "one", Trunk: "main"},
+			"two",
+			nil,
+			db.ReadTx(),
+		),
+	})
+	tx := db.WriteTx()
+	twoMeta, _ := tx.Branch("two")
+	twoMeta.PullRequest = &meta.PullRequest{ID: "nodeid-2", Number: 2, State: "OPEN"}
+	tx.SetBranch(twoMeta)
+	require.NoError(t, tx.Commit())
+
+	RequireAv(t, "preview", "set", "https://two.preview.example.com")
+	require.Contains(t, server.pulls[0].Body, "**Preview:** https://two.preview.example.com")
+	require.Contains(t, server.pulls[0].Body, "The description.")
+
+	RequireAv(t, "preview", "clear")
+	require.NotContains(t, server.pulls[0].Body, actions.PRPreviewCommentStart)
+	require.Contains(t, server.pulls[0].Body, "The description.")
+	require.Contains(t, RequireAv(t, "preview", "show").Stderr, "has no preview URL")
+}
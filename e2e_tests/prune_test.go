@@ -0,0 +1,122 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrune(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two -> three
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three")
+	repo.Git(t, "push", "origin", "one", "two", "three")
+
+	body := "<!-- av pr metadata\n```\n{}\n```\n-->"
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main", Body: body},
+		mockPR{ID: "nodeid-2", Number: 2, State: "OPEN", HeadRefName: "two", BaseRefName: "one", Body: body},
+	)
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	for _, pr := range server.pulls {
+		br, _ := tx.Branch(pr.HeadRefName)
+		br.PullRequest = &meta.PullRequest{ID: pr.ID, Number: int64(pr.Number), State: "OPEN"}
+		tx.SetBranch(br)
+	}
+	require.NoError(t, tx.Commit())
+
+	// The pull request of one is closed on GitHub.
+	server.pulls[0].State = "CLOSED"
+
+	out := RequireAv(t, "prune", "--dry-run")
+	require.Contains(t, out.Stderr, "one (closed #1)")
+	require.NotContains(t, out.Stderr, "two (")
+	requireBranchExists(t, repo, "one")
+
+	// Without a terminal, nothing is pruned without --yes.
+	out = RequireAv(t, "prune")
+	require.Contains(t, out.Stderr, "av prune --yes")
+	requireBranchExists(t, repo, "one")
+
+	// main -> two -> three
+	out = RequireAv(t, "prune", "--yes")
+	require.Contains(t, out.Stderr, "Pruned 1 branches.")
+	require.Contains(t, out.Stderr, "moved two onto main")
+	_, err := repo.GoGit.Reference(plumbing.NewBranchReferenceName("one"), false)
+	require.Error(t, err, "the pruned branch should be deleted")
+	_, err = repo.GoGit.Reference(plumbing.NewRemoteReferenceName("origin", "one"), false)
+	require.Error(t, err, "the pruned branch should be deleted on the remote")
+	require.Equal(t, "main", GetStoredParentBranchState(t, repo, "two").Name)
+	require.Equal(t, "two", GetStoredParentBranchState(t, repo, "three").Name)
+	require.Equal(t, "three.txt\ntwo.txt\n", repo.Git(
+		t, "ls-tree", "--name-only", "three", "--", "one.txt", "two.txt", "three.txt",
+	), "three should no longer have the commits of one")
+	require.Equal(t, "main", server.pulls[1].BaseRefName, "the pull request of two is retargeted")
+
+	out = RequireAv(t, "prune", "--yes")
+	require.Contains(t, out.Stderr, "No branches to prune.")
+}
+
+func requireBranchExists(t *testing.T, repo *gittest.GitTestRepo, name string) {
+	t.Helper()
+	_, err := repo.GoGit.Reference(plumbing.NewBranchReferenceName(name), false)
+	require.NoError(t, err, "branch %q should exist", name)
+}
+
+func TestSyncPruneAll(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one
+	// main -> conflict
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	repo.Git(t, "push", "origin", "one")
+	repo.Git(t, "switch", "main")
+	RequireAv(t, "branch", "conflict")
+	repo.CommitFile(t, "file.txt", "conflict")
+	repo.Git(t, "switch", "main")
+	repo.CommitFile(t, "file.txt", "main")
+	repo.Git(t, "push", "origin", "main")
+	repo.Git(t, "switch", "conflict")
+
+	server.pulls = append(server.pulls, mockPR{
+		ID: "nodeid-1", Number: 1, State: "CLOSED", HeadRefName: "one", BaseRefName: "main",
+		Body: "<!-- av pr metadata\n```\n{}\n```\n-->",
+	})
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	br, _ := tx.Branch("one")
+	br.PullRequest = &meta.PullRequest{ID: "nodeid-1", Number: 1, State: "CLOSED"}
+	tx.SetBranch(br)
+	require.NoError(t, tx.Commit())
+
+	// Nothing is pruned when the sync stops at a conflict.
+	out := Av(t, "sync", "--rebase-to-trunk", "--prune-all=yes")
+	require.Equal(t, 2, out.ExitCode)
+	require.Contains(t, out.Stderr, "Skipped pruning because of the conflict")
+	requireBranchExists(t, repo, "one")
+
+	// Once the conflict is resolved, the branches are pruned at the end of the sync.
+	repo.CreateFile(t, "file.txt", "resolved")
+	repo.AddFile(t, "file.txt")
+	out = RequireAv(t, "sync", "--continue", "--prune-all=yes")
+	require.Contains(t, out.Stderr, "Pruned 1 branches.")
+	_, err := repo.GoGit.Reference(plumbing.NewBranchReferenceName("one"), false)
+	require.Error(t, err, "the pruned branch should be deleted")
+}
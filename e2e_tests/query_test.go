@@ -0,0 +1,42 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two -> three
+	//             -> four
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "3a\n")
+	repo.Git(t, "checkout", "one")
+	RequireAv(t, "branch", "four")
+	repo.CommitFile(t, "four.txt", "4a\n")
+
+	require.Equal(t, "four\ntwo\nthree\n", RequireAv(t, "query", "descendants(one)").Stdout)
+	require.Equal(t, "four\nthree\n", RequireAv(t, "query", "leaf && author=me").Stdout)
+	require.Equal(t, "", RequireAv(t, "query", "needs-restack").Stdout)
+
+	// Amending one makes its children need a restack.
+	repo.Git(t, "checkout", "one")
+	repo.CreateFile(t, "one.txt", "1a\n1b\n")
+	repo.Git(t, "commit", "-a", "-m", "1b")
+	require.Equal(t, "four\ntwo\n", RequireAv(t, "query", "needs-restack").Stdout)
+
+	require.NotEqual(t, 0, Av(t, "query", "unknown(one)").ExitCode)
+
+	// The output can be piped to the commands that accept --stdin.
+	out := Cmd(t, "sh", "-c", avCmdPath+" query 'children(one)' | "+avCmdPath+" pin --stdin")
+	require.Equal(t, 0, out.ExitCode)
+	require.Equal(t, "four\ntwo\n", RequireAv(t, "query", "pinned").Stdout)
+}
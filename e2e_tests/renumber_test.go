@@ -0,0 +1,53 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenumber(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> schema -> api
+	RequireAv(t, "branch", "schema")
+	repo.CommitFile(t, "schema.txt", "schema")
+	RequireAv(t, "branch", "api")
+	repo.CommitFile(t, "api.txt", "api")
+
+	RequireAv(t, "renumber", "feat-x")
+	require.Equal(t, plumbing.NewBranchReferenceName("feat-x/02-api"), repo.CurrentBranch(t))
+	require.Equal(t, "feat-x/01-schema", GetStoredParentBranchState(t, repo, "feat-x/02-api").Name)
+	require.True(t, GetStoredParentBranchState(t, repo, "feat-x/01-schema").Trunk)
+
+	// A new branch is numbered with the remembered prefix (av stack rename --sequence is the
+	// same command).
+	RequireAv(t, "branch", "ui")
+	repo.CommitFile(t, "ui.txt", "ui")
+	RequireAv(t, "stack", "rename", "--sequence")
+	require.Equal(t, plumbing.NewBranchReferenceName("feat-x/03-ui"), repo.CurrentBranch(t))
+	require.Equal(t, "feat-x/02-api", GetStoredParentBranchState(t, repo, "feat-x/03-ui").Name)
+
+	// A branch with a pull request is not renamed without --force.
+	RequireAv(t, "branch", "docs")
+	repo.CommitFile(t, "docs.txt", "docs")
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	br, _ := tx.Branch("docs")
+	br.PullRequest = &meta.PullRequest{ID: "nodeid-42", Number: 42, State: "OPEN"}
+	tx.SetBranch(br)
+	require.NoError(t, tx.Commit())
+
+	out := Av(t, "renumber")
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Contains(t, out.Stderr, "av renumber --force")
+	require.Equal(t, plumbing.NewBranchReferenceName("docs"), repo.CurrentBranch(t))
+	RequireAv(t, "renumber", "--force")
+	require.Equal(t, plumbing.NewBranchReferenceName("feat-x/04-docs"), repo.CurrentBranch(t))
+}
@@ -2,9 +2,13 @@ package e2e_tests
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/errutils"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/stretchr/testify/require"
 )
@@ -82,3 +86,110 @@ func requireFileContent(t *testing.T, file string, expected string, args ...any)
 	}
 	require.Equal(t, expected, string(actual), args...)
 }
+
+func TestReparentAcrossStacksRetargetsPullRequests(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+pullRequest:
+    writeStack: true
+`)
+
+	//     one -> two
+	//     three
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	repo.CheckoutBranch(t, "refs/heads/main")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three")
+	repo.Git(t, "push", "origin", "one", "two", "three")
+
+	// The pull requests created by av have the metadata in the body.
+	body := "<!-- av pr metadata\n```\n{}\n```\n-->"
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main", Body: body},
+		mockPR{ID: "nodeid-2", Number: 2, State: "OPEN", HeadRefName: "two", BaseRefName: "one", Body: body},
+		mockPR{ID: "nodeid-3", Number: 3, State: "OPEN", HeadRefName: "three", BaseRefName: "main", Body: body},
+	)
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	for _, pr := range server.pulls {
+		br, _ := tx.Branch(pr.HeadRefName)
+		br.PullRequest = &meta.PullRequest{ID: pr.ID, Number: int64(pr.Number), State: "OPEN"}
+		tx.SetBranch(br)
+	}
+	require.NoError(t, tx.Commit())
+
+	// Move two onto three.
+	//     one
+	//     three -> two
+	repo.CheckoutBranch(t, "refs/heads/two")
+	RequireAv(t, "reparent", "--parent", "three", "--push=yes")
+
+	require.Equal(t, "three", GetStoredParentBranchState(t, repo, "two").Name)
+	require.Equal(
+		t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "two")),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "origin/two")),
+	)
+	require.Equal(t, "three", server.pulls[1].BaseRefName)
+	// The stacks in the pull requests of both stacks are updated.
+	require.Contains(t, server.pulls[1].Body, "#3")
+	require.NotContains(t, server.pulls[1].Body, "#1")
+	require.Contains(t, server.pulls[2].Body, "#2")
+	require.NotContains(t, server.pulls[0].Body, "#2")
+}
+
+func TestReparentOnly(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	//     one -> two -> three
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three")
+
+	// Move only two onto main.
+	//     one -> three
+	//     two
+	repo.CheckoutBranch(t, "refs/heads/two")
+	RequireAv(t, "reparent", "--parent", "main", "--only")
+
+	require.Equal(t, "main", GetStoredParentBranchState(t, repo, "two").Name)
+	require.Equal(t, "one", GetStoredParentBranchState(t, repo, "three").Name)
+	require.Equal(t, "refs/heads/two", repo.CurrentBranch(t).String())
+	require.NoFileExists(t, "one.txt")
+	repo.CheckoutBranch(t, "refs/heads/three")
+	requireFileContent(t, "one.txt", "one")
+	require.NoFileExists(t, "two.txt")
+}
+
+func TestReparentUncommittedChanges(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "foo")
+	repo.CommitFile(t, "foo.txt", "foo")
+	RequireAv(t, "branch", "bar")
+	repo.CommitFile(t, "bar.txt", "bar")
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "bar.txt"), []byte("wip"), 0644))
+
+	before := repo.Git(t, "rev-parse", "bar")
+	out := Av(t, "reparent", "--parent", "main", "--push=no")
+	require.Equal(t, errutils.FailureDirtyWorktree.ExitCode, out.ExitCode)
+	require.Equal(t, before, repo.Git(t, "rev-parse", "bar"))
+	requireFileContent(t, "bar.txt", "wip")
+
+	RequireAv(t, "reparent", "--parent", "main", "--push=no", "--autostash")
+	require.NoFileExists(t, "foo.txt")
+	requireFileContent(t, "bar.txt", "wip")
+	require.Equal(t, "main", GetStoredParentBranchState(t, repo, "bar").Name)
+}
@@ -0,0 +1,86 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestackDryRun(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	//     stack-1: main -> 1a -> 1b
+	//     stack-2:           \ -> 2a
+	//     stack-3:           |    \ -> 3a
+	//     stack-4:           \ -> 4a
+	// 1b conflicts with 2a, so stack-3 can only be rebased after the conflict is resolved.
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "file-1", "1a\n")
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "file-2", "2a\n")
+	RequireAv(t, "branch", "stack-3")
+	repo.CommitFile(t, "file-3", "3a\n")
+	repo.CheckoutBranch(t, "refs/heads/stack-1")
+	RequireAv(t, "branch", "stack-4")
+	repo.CommitFile(t, "file-4", "4a\n")
+	repo.CheckoutBranch(t, "refs/heads/stack-1")
+	repo.CommitFile(t, "file-2", "1b\n")
+
+	heads := func() string {
+		return repo.Git(t, "for-each-ref", "refs/heads")
+	}
+	before := heads()
+
+	out := RequireAv(t, "restack", "--dry-run")
+	require.Contains(t, out.Stderr, "stack-2 would conflict with stack-1")
+	require.Contains(t, out.Stderr, "      file-2\n")
+	require.Contains(
+		t, out.Stderr,
+		"stack-3 would be rebased onto stack-2 after the conflict in stack-2 is resolved",
+	)
+	require.Contains(t, out.Stderr, "stack-4 would be rebased onto stack-1 (1 commit(s))")
+
+	// Nothing is changed.
+	require.Equal(t, before, heads())
+	require.Equal(t, "stack-1", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	RequireAv(t, "restack", "--dry-run")
+
+	// --dry-run can be combined with --all and --current, but not with --onto.
+	out = RequireAv(t, "restack", "--all", "--dry-run")
+	require.Contains(t, out.Stderr, "stack-2 would conflict with stack-1")
+	require.Contains(t, out.Stderr, "stack-4 would be rebased onto stack-1 (1 commit(s))")
+	RequireAv(t, "restack", "--current", "--dry-run")
+	out = Av(t, "restack", "--onto", "main", "--dry-run")
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Equal(t, before, heads())
+}
+
+func TestSyncDryRun(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "file-1", "1a\n")
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "file-2", "2a\n")
+
+	// Advance the remote trunk.
+	repo.CheckoutBranch(t, "refs/heads/main")
+	repo.CommitFile(t, "file-main", "main\n")
+	repo.Git(t, "push", "origin", "main")
+	repo.CheckoutBranch(t, "refs/heads/stack-2")
+	before := repo.Git(t, "for-each-ref", "refs/heads")
+
+	// The stack roots are rebased onto the remote trunk only with --rebase-to-trunk.
+	out := RequireAv(t, "sync", "--dry-run")
+	require.Contains(t, out.Stderr, "Dry run of av sync")
+	require.Contains(t, out.Stderr, "stack-2 is up to date with stack-1")
+
+	out = RequireAv(t, "sync", "--dry-run", "--rebase-to-trunk")
+	require.Contains(t, out.Stderr, "stack-1 would be rebased onto main (1 commit(s))")
+	require.Contains(t, out.Stderr, "stack-2 would be rebased onto stack-1 (1 commit(s))")
+	require.Equal(t, before, repo.Git(t, "for-each-ref", "refs/heads"))
+}
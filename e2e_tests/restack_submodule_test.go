@@ -0,0 +1,83 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncUpdatesSubmodules(t *testing.T) {
+	sub := gittest.NewTempRepo(t)
+	sub1 := sub.CommitFile(t, "lib.txt", "1\n")
+	sub2 := sub.CommitFile(t, "lib.txt", "2\n")
+
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+	// Allow cloning the local submodule repository.
+	repo.Git(t, "config", "protocol.file.allow", "always")
+	repo.Git(t, "-c", "protocol.file.allow=always", "submodule", "add", sub.RepoDir, "lib")
+	repo.Git(t, "-C", "lib", "checkout", sub1.String())
+	repo.Git(t, "add", "lib")
+	repo.Git(t, "commit", "-m", "Add the submodule")
+	repo.Git(t, "push", "origin", "main")
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1\n")
+
+	// The trunk bumps the submodule.
+	repo.Git(t, "switch", "main")
+	repo.Git(t, "-C", "lib", "checkout", sub2.String())
+	repo.Git(t, "add", "lib")
+	repo.Git(t, "commit", "-m", "Bump the submodule")
+	repo.Git(t, "push", "origin", "main")
+	repo.Git(t, "switch", "one")
+	repo.Git(t, "submodule", "update")
+	require.Equal(t, sub1.String(), strings.TrimSpace(repo.Git(t, "-C", "lib", "rev-parse", "HEAD")))
+
+	RequireAv(t, "sync", "--rebase-to-trunk", "--push=no", "--prune=no")
+	require.Equal(t, sub2.String(), strings.TrimSpace(repo.Git(t, "-C", "lib", "rev-parse", "HEAD")))
+	require.Equal(t, "2\n", readFile(t, filepath.Join(repo.RepoDir, "lib", "lib.txt")))
+}
+
+func TestRestackSubmoduleConflict(t *testing.T) {
+	sub := gittest.NewTempRepo(t)
+	sub1 := sub.CommitFile(t, "lib.txt", "1\n")
+	sub2 := sub.CommitFile(t, "lib.txt", "2\n")
+	sub3 := sub.CommitFile(t, "lib.txt", "3\n")
+
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+	repo.Git(t, "config", "protocol.file.allow", "always")
+	repo.Git(t, "-c", "protocol.file.allow=always", "submodule", "add", sub.RepoDir, "lib")
+	repo.Git(t, "-C", "lib", "checkout", sub1.String())
+	repo.Git(t, "add", "lib")
+	repo.Git(t, "commit", "-m", "Add the submodule")
+	repo.Git(t, "push", "origin", "main")
+
+	RequireAv(t, "branch", "one")
+	repo.Git(t, "-C", "lib", "checkout", sub2.String())
+	repo.Git(t, "add", "lib")
+	repo.Git(t, "commit", "-m", "Bump the submodule to 2")
+	RequireAv(t, "branch", "two")
+	repo.Git(t, "-C", "lib", "checkout", sub3.String())
+	repo.Git(t, "add", "lib")
+	repo.Git(t, "commit", "-m", "Bump the submodule to 3")
+
+	// Both branches change the submodule pointer from different bases.
+	repo.Git(t, "switch", "one")
+	repo.Git(t, "submodule", "update")
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "one.txt"), []byte("1\n"), 0o644))
+	repo.Git(t, "-C", "lib", "checkout", sub1.String())
+	repo.Git(t, "add", "lib", "one.txt")
+	repo.Git(t, "commit", "--amend", "-m", "Keep the submodule at 1")
+
+	out := Av(t, "restack")
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Contains(t, out.Stdout+out.Stderr, "Submodule conflict in lib needs manual resolution")
+}
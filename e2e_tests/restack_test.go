@@ -2,12 +2,15 @@ package e2e_tests
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/aviator-co/av/internal/git/gittest"
 	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/errutils"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/stretchr/testify/require"
 )
@@ -366,3 +369,410 @@ func TestStackRestackAll(t *testing.T) {
 	repo.Git(t, "merge-base", "--is-ancestor", "stack-1", "stack-1a")
 	repo.Git(t, "merge-base", "--is-ancestor", "stack-1", "stack-1b")
 }
+
+func TestRestackFromScratch(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "other-file", "2a\n", gittest.WithMessage("Commit 2a"))
+	repo.CommitFile(t, "other-file", "2a\n2b\n", gittest.WithMessage("Commit 2b"))
+
+	// Tangle the history of stack-2 by merging stack-1 after it got a new commit.
+	repo.Git(t, "checkout", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n1b\n", gittest.WithMessage("Commit 1b"))
+	repo.Git(t, "checkout", "stack-2")
+	repo.Git(t, "merge", "--no-edit", "stack-1")
+
+	RequireAv(t, "restack", "--from-scratch")
+
+	// stack-2 is now a single commit on top of stack-1 with the same content.
+	require.Equal(t, "Commit 2a", strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%s")))
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD^")),
+	)
+	require.Equal(t, "2a\n2b\n", repo.Git(t, "show", "HEAD:other-file"))
+	require.Equal(t, "1a\n1b\n", repo.Git(t, "show", "HEAD:my-file"))
+
+	db := repo.OpenDB(t)
+	stack2, _ := db.ReadTx().Branch("stack-2")
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1")).String(),
+		stack2.Parent.Head,
+	)
+}
+
+func TestRestackFromScratchRunsCommitHooks(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	repo.CommitFile(t, "my-file", "1a\n1b\n", gittest.WithMessage("Commit 1b"))
+	head := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1"))
+
+	hook := filepath.Join(repo.GitDir, "hooks", "pre-commit")
+	require.NoError(t, os.MkdirAll(filepath.Dir(hook), 0755))
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\necho rejected by hook\nexit 1\n"), 0755))
+
+	output := Av(t, "restack", "--from-scratch")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "rejected by hook")
+	require.Equal(t, head, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1")))
+}
+
+func TestRestackBinaryConflictConfig(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+restack:
+    binaryConflicts:
+        - pattern: "*.bin"
+          resolve: branch
+`)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "image.bin", "1a\x00", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "image.bin", "2a\x00", gittest.WithMessage("Commit 2a"))
+	repo.Git(t, "checkout", "stack-1")
+	repo.CommitFile(t, "image.bin", "1b\x00", gittest.WithMessage("Commit 1b"))
+	repo.Git(t, "checkout", "stack-2")
+
+	// The binary file conflict is resolved with the version of stack-2 by the config.
+	RequireAv(t, "restack")
+	require.Equal(t, "", repo.Git(t, "status", "--porcelain"))
+	require.Equal(t, "2a\x00", repo.Git(t, "show", "stack-2:image.bin"))
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "stack-2^")),
+	)
+}
+
+func TestRestackReplay(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "one.txt", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "two.txt", "2a\n", gittest.WithMessage("Commit 2a"))
+	RequireAv(t, "branch", "stack-3")
+	repo.CommitFile(t, "three.txt", "3a\n", gittest.WithMessage("Commit 3a"))
+	repo.Git(t, "checkout", "stack-1")
+	repo.CommitFile(t, "one.txt", "1a\n1b\n", gittest.WithMessage("Commit 1b"))
+
+	// stack-2 and stack-3 are not checked out, so they are replayed if git replay is available
+	// (and rebased in memory otherwise).
+	RequireAv(t, "restack")
+	require.Equal(t, "stack-1", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	require.Equal(t, "", repo.Git(t, "status", "--porcelain"))
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "stack-2^")),
+	)
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-2")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "stack-3^")),
+	)
+
+	db := repo.OpenDB(t)
+	stack3, _ := db.ReadTx().Branch("stack-3")
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-2")).String(),
+		stack3.Parent.Head,
+	)
+}
+
+func TestRestackProgressJSON(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "one.txt", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "two.txt", "2a\n", gittest.WithMessage("Commit 2a"))
+	repo.Git(t, "checkout", "stack-1")
+	repo.CommitFile(t, "one.txt", "1a\n1b\n", gittest.WithMessage("Commit 1b"))
+
+	output := RequireAv(t, "restack", "--progress=json")
+	require.Contains(t, output.Stderr, `{"phase":"restack","branch":"stack-2","percent":0}`)
+	require.Contains(t, output.Stderr, `{"phase":"restack","branch":"stack-2","percent":100}`)
+
+	require.NotEqual(t, 0, Av(t, "tree", "--progress=bogus").ExitCode)
+}
+
+func TestRestackRerere(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, "restack:\n  rerere: true\n")
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "base\n", gittest.WithMessage("Commit 1"))
+	RequireAv(t, "branch", "stack-1a")
+	repo.CommitFile(t, "my-file", "feature\n", gittest.WithMessage("Commit feature"))
+	repo.Git(t, "switch", "stack-1")
+	RequireAv(t, "branch", "stack-1b")
+	repo.CommitFile(t, "my-file", "feature\n", gittest.WithMessage("Commit feature"))
+	repo.Git(t, "switch", "stack-1")
+	repo.CommitFile(t, "my-file", "upstream\n", gittest.WithMessage("Commit 2"))
+
+	//     stack-1:  1 -> 2 (upstream)
+	//     stack-1a:  \ -> feature
+	//     stack-1b:  \ -> feature
+	// Both branches hit the same conflict.
+	require.NotEqual(t, 0, Av(t, "restack", "--all").ExitCode)
+
+	// Another operation can't be started while the restack is in progress.
+	require.NotEqual(t, 0, Av(t, "sync").ExitCode)
+
+	repo.CreateFile(t, "my-file", "resolved\n")
+	repo.Git(t, "add", "my-file")
+	// av sync --continue continues the restack, and the second conflict is resolved with the
+	// recorded resolution.
+	RequireAv(t, "sync", "--continue")
+	require.Equal(t, "resolved\n", repo.Git(t, "show", "stack-1a:my-file"))
+	require.Equal(t, "resolved\n", repo.Git(t, "show", "stack-1b:my-file"))
+	repo.Git(t, "merge-base", "--is-ancestor", "stack-1", "stack-1a")
+	repo.Git(t, "merge-base", "--is-ancestor", "stack-1", "stack-1b")
+	_, inProgress := repo.AsAvGitRepo().InProgressStateFile()
+	require.False(t, inProgress)
+}
+
+func TestRestackConflictWalkthrough(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "my-file", "2a\n", gittest.WithMessage("Commit 2a"))
+	repo.Git(t, "checkout", "stack-1")
+	repo.CommitFile(t, "my-file", "1b\n", gittest.WithMessage("Commit 1b"))
+
+	// The walkthrough is shown on the first conflict.
+	conflict := Av(t, "restack", "--all")
+	require.NotEqual(t, 0, conflict.ExitCode)
+	require.Contains(t, conflict.Stdout, "How to resolve the conflict")
+	require.Contains(t, conflict.Stdout, "Commit 2a")
+	require.Contains(t, conflict.Stdout, "my-file")
+	require.Contains(t, conflict.Stdout, "av restack --continue")
+	RequireAv(t, "restack", "--abort")
+
+	// ... but not on the next conflicts.
+	conflict = Av(t, "restack", "--all")
+	require.NotEqual(t, 0, conflict.ExitCode)
+	require.NotContains(t, conflict.Stdout, "How to resolve the conflict")
+	require.Contains(t, conflict.Stdout, "av sync --explain")
+
+	// It can be shown again with --explain.
+	explain := RequireAv(t, "sync", "--explain")
+	require.Contains(t, explain.Stdout, "How to resolve the conflict")
+	require.Contains(t, explain.Stdout, "stack-2")
+	require.Contains(t, explain.Stdout, "my-file")
+	RequireAv(t, "sync", "--abort")
+	require.NotEqual(t, 0, Av(t, "sync", "--explain").ExitCode)
+}
+
+func TestRestackFastForwardedParent(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+	// Fix the committer date so that a rewritten commit always gets a new hash.
+	t.Setenv("GIT_COMMITTER_DATE", "2024-01-01T00:00:00Z")
+
+	//     stack-1: main -> 1a
+	//     stack-2:           \ -> 2a -> 2b
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	twoA := repo.CommitFile(t, "my-file", "1a\n2a\n", gittest.WithMessage("Commit 2a"))
+	twoB := repo.CommitFile(t, "my-file", "1a\n2a\n2b\n", gittest.WithMessage("Commit 2b"))
+
+	// Fast-forward stack-1 to 2a (e.g., to move the commit down to the parent).
+	//     stack-1: main -> 1a -> 2a
+	//     stack-2:                 \ -> 2b
+	repo.Git(t, "branch", "-f", "stack-1", twoA.String())
+
+	t.Setenv("GIT_COMMITTER_DATE", "2024-01-02T00:00:00Z")
+	RequireAv(t, "restack")
+
+	// stack-2 already contains the new head of stack-1, so it's not rewritten and only the
+	// recorded parent head is updated.
+	require.Equal(t, twoB, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-2")))
+	require.Equal(t, twoA.String(), GetStoredParentBranchState(t, repo, "stack-2").Head)
+}
+
+func TestRestackSignCommits(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not available")
+	}
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+restack:
+    signCommits: true
+    committerDateIsAuthorDate: true
+`)
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	repo.Git(t, "config", "gpg.format", "ssh")
+	repo.Git(t, "config", "user.signingKey", keyPath)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "one.txt", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "two.txt", "2a\n", gittest.WithMessage("Commit 2a"))
+	repo.Git(t, "commit", "--amend", "--no-edit", "--date=2020-01-02T03:04:05Z")
+	repo.Git(t, "checkout", "stack-1")
+	repo.CommitFile(t, "one.txt", "1a\n1b\n", gittest.WithMessage("Commit 1b"))
+	stack2 := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-2"))
+
+	// The signing key doesn't exist yet, so the restack fails before rebasing.
+	output := Av(t, "restack")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stdout+output.Stderr, "is not found")
+	require.Equal(t, stack2, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-2")))
+
+	require.NoError(t, exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", keyPath).Run())
+	RequireAv(t, "restack")
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "stack-2^")),
+	)
+	require.Contains(t, repo.Git(t, "cat-file", "commit", "stack-2"), "gpgsig -----BEGIN SSH SIGNATURE-----")
+	require.Equal(
+		t,
+		"1577934245 1577934245",
+		strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%at %ct", "stack-2")),
+	)
+}
+
+func TestRestackAutostash(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	repo.CommitFile(t, "notes.txt", "notes\n", gittest.WithMessage("Add notes"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "my-file", "2a\n", gittest.WithMessage("Commit 2a"))
+	repo.Git(t, "checkout", "stack-1")
+	repo.CommitFile(t, "my-file", "1b\n", gittest.WithMessage("Commit 1b"))
+	repo.Git(t, "checkout", "stack-2")
+
+	// The uncommitted changes (both staged and unstaged) are stashed during the restack.
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "notes.txt"), []byte("draft\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "staged.txt"), []byte("wip\n"), 0644))
+	repo.Git(t, "add", "staged.txt")
+
+	require.Equal(t, errutils.FailureConflict.ExitCode, Av(t, "restack", "--autostash").ExitCode)
+	require.Contains(t, repo.Git(t, "stash", "list"), "av restack")
+	require.Equal(t, "notes\n", readFile(t, filepath.Join(repo.RepoDir, "notes.txt")))
+
+	// ... and restored after the restack is continued and finished.
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "my-file"), []byte("1b\n2a\n"), 0644))
+	repo.Git(t, "add", "my-file")
+	RequireAv(t, "restack", "--continue")
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "stack-2^")),
+	)
+	require.Equal(t, "stack-2", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	require.Equal(t, " M notes.txt\nA  staged.txt\n", repo.Git(t, "status", "--porcelain"))
+	require.Empty(t, repo.Git(t, "stash", "list"))
+}
+
+func TestRestackSharedBase(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// Two stacks (one and two) share the base branch.
+	RequireAv(t, "branch", "base")
+	repo.CommitFile(t, "base.txt", "base")
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "switch", "base")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	require.Contains(t, RequireAv(t, "tree").Stdout, "base (shared by 2 stacks)")
+
+	RequireAv(t, "switch", "base")
+	repo.CommitFile(t, "base.txt", "base\nmore")
+	baseHead := repo.GetCommitAtRef(t, "refs/heads/base")
+
+	// --current only restacks up to one, leaving the other stack on the base as it is.
+	RequireAv(t, "switch", "one")
+	twoHead := repo.GetCommitAtRef(t, "refs/heads/two")
+	RequireAv(t, "restack", "--current")
+	require.Equal(t, twoHead, repo.GetCommitAtRef(t, "refs/heads/two"))
+	require.Equal(
+		t, baseHead.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "one^")),
+		"one should be restacked on the base",
+	)
+
+	// Restacking the stack of one restacks the other stack on the base too.
+	RequireAv(t, "switch", "base")
+	repo.CommitFile(t, "base.txt", "base\nmore\nagain")
+	baseHead = repo.GetCommitAtRef(t, "refs/heads/base")
+	RequireAv(t, "switch", "one")
+	RequireAv(t, "restack")
+	for _, branch := range []string{"one", "two"} {
+		require.Equal(
+			t, baseHead.String(),
+			strings.TrimSpace(repo.Git(t, "rev-parse", branch+"^")),
+			"%s should be restacked on the base", branch,
+		)
+	}
+}
+
+func TestRestackOnto(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	//     main -> two -> three
+	//          -> other
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "my-file", "two\n")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three")
+	repo.CheckoutBranch(t, "refs/heads/main")
+	RequireAv(t, "branch", "other")
+	repo.CommitFile(t, "my-file", "other\n")
+
+	// Move two (with three) onto other. two conflicts with other.
+	repo.CheckoutBranch(t, "refs/heads/two")
+	out := Av(t, "restack", "--onto", "other")
+	require.NotEqual(t, 0, out.ExitCode, "restack --onto should fail on a conflict")
+	require.Contains(t, out.Stdout, "av restack --continue")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "my-file"), []byte("other\ntwo\n"), 0644))
+	repo.Git(t, "add", "my-file")
+	RequireAv(t, "restack", "--continue")
+
+	//     main -> other -> two -> three
+	require.Equal(t, "other", GetStoredParentBranchState(t, repo, "two").Name)
+	require.Equal(t, "two", GetStoredParentBranchState(t, repo, "three").Name)
+	require.Equal(
+		t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "two")),
+		GetStoredParentBranchState(t, repo, "three").Head,
+		"three should track the rebased head of two",
+	)
+	repo.CheckoutBranch(t, "refs/heads/three")
+	requireFileContent(t, "my-file", "other\ntwo\n")
+	requireFileContent(t, "three.txt", "three")
+}
@@ -0,0 +1,97 @@
+package e2e_tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevert(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one", gittest.WithMessage("Add one"))
+
+	// The branch isn't merged yet.
+	output := Av(t, "revert", "one")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, `branch "one" is not merged`)
+
+	// Merge the branch into main with a merge commit, as av sync would record it.
+	repo.Git(t, "checkout", "main")
+	repo.Git(t, "merge", "--no-ff", "-m", "Merge one", "one")
+	repo.Git(t, "push", "origin", "main")
+	mergeCommit := strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD"))
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	one, _ := tx.Branch("one")
+	one.MergeCommit = mergeCommit
+	one.PullRequest = &meta.PullRequest{Number: 7, Title: "Add one"}
+	tx.SetBranch(one)
+	require.NoError(t, tx.Commit())
+
+	RequireAv(t, "revert", "one")
+	require.Equal(t, "revert-one", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	require.NoFileExists(t, filepath.Join(repo.RepoDir, "one.txt"))
+	message := repo.Git(t, "log", "-1", "--format=%B")
+	require.Contains(t, message, `Revert "Add one"`)
+	require.Contains(t, message, "This reverts commit "+mergeCommit+".")
+	require.Contains(t, message, "Reverts #7")
+
+	db = repo.OpenDB(t)
+	revert, ok := db.ReadTx().Branch("revert-one")
+	require.True(t, ok)
+	require.True(t, revert.Parent.Trunk)
+	require.Equal(t, &meta.BranchRevert{
+		Branch:            "one",
+		PullRequestNumber: 7,
+		Commit:            mergeCommit,
+	}, revert.Reverts)
+
+	// The pull request number also finds the merged branch in the metadata, which is already
+	// reverted by revert-one.
+	output = Av(t, "revert", "#7", "--name", "revert-again")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, `branch "revert-one" already reverts #7 (one)`)
+}
+
+func TestRevertRebaseMerge(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	first := repo.CommitFile(t, "one.txt", "one", gittest.WithMessage("Add one"))
+	second := repo.CommitFile(t, "two.txt", "two", gittest.WithMessage("Add two"))
+
+	// Rebase-merge the branch into main after another commit, as GitHub does.
+	repo.Git(t, "checkout", "main")
+	repo.CommitFile(t, "main.txt", "main")
+	repo.Git(t, "cherry-pick", first.String(), second.String())
+	repo.Git(t, "push", "origin", "main")
+	base := strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD~2"))
+	mergeCommit := strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD"))
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	one, _ := tx.Branch("one")
+	one.MergeCommit = mergeCommit
+	tx.SetBranch(one)
+	require.NoError(t, tx.Commit())
+
+	// Both commits are reverted, not only the merge commit.
+	RequireAv(t, "revert", "one")
+	require.NoFileExists(t, filepath.Join(repo.RepoDir, "one.txt"))
+	require.NoFileExists(t, filepath.Join(repo.RepoDir, "two.txt"))
+	require.FileExists(t, filepath.Join(repo.RepoDir, "main.txt"))
+	require.Contains(
+		t, repo.Git(t, "log", "-1", "--format=%B"),
+		"This reverts commits "+base+".."+mergeCommit+".",
+	)
+	db = repo.OpenDB(t)
+	revert, _ := db.ReadTx().Branch("revert-one")
+	require.Equal(t, base, revert.Reverts.Base)
+}
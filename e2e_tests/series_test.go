@@ -0,0 +1,124 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesExportRefreshImport(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+
+	patchDir := filepath.Join(t.TempDir(), "patches")
+	RequireAv(t, "series", "export", "--dir", patchDir)
+	seriesFile := filepath.Join(patchDir, "series")
+	require.Equal(t, []string{"0001-Write-one.txt.patch", "0002-Write-two.txt.patch"}, seriesPatches(t, seriesFile))
+
+	// Exporting again fails because the series exists.
+	require.NotEqual(t, 0, Av(t, "series", "export", "--dir", patchDir).ExitCode)
+
+	// Refreshing picks up the new commits and branches.
+	repo.CommitFile(t, "two.txt", "two more", gittest.WithMessage("Update two"))
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three")
+	RequireAv(t, "series", "refresh", "--dir", patchDir)
+	require.Equal(t, []string{
+		"0001-Write-one.txt.patch",
+		"0002-Write-two.txt.patch",
+		"0003-Update-two.patch",
+		"0004-Write-three.txt.patch",
+	}, seriesPatches(t, seriesFile))
+	require.Contains(t, readFile(t, seriesFile), "# av-branch: three\n")
+
+	other := gittest.NewTempRepo(t)
+	Chdir(t, other.RepoDir)
+	RequireAv(t, "series", "import", "--dir", patchDir)
+	require.Equal(t, "two more", other.Git(t, "show", "two:two.txt"))
+	require.Equal(t, "three", other.Git(t, "show", "three:three.txt"))
+	require.Equal(t, other.Git(t, "rev-parse", "two"), other.Git(t, "rev-parse", "three^"))
+	db := other.OpenDB(t)
+	three, ok := db.ReadTx().Branch("three")
+	require.True(t, ok)
+	require.Equal(t, "two", three.Parent.Name)
+}
+
+func TestSeriesImportPlainQuilt(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	patchDir := filepath.Join(t.TempDir(), "patches")
+	require.NoError(t, os.MkdirAll(patchDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(patchDir, "add_readme.patch"), []byte(`Add a readme.
+
+--- /dev/null
++++ b/quilt.txt
+@@ -0,0 +1 @@
++from quilt
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(patchDir, "series"), []byte("add_readme.patch -p1\n"), 0644))
+
+	// A series without branches needs --branch.
+	require.NotEqual(t, 0, Av(t, "series", "import", "--dir", patchDir).ExitCode)
+
+	RequireAv(t, "series", "import", "--dir", patchDir, "--branch", "quilt")
+	require.Equal(t, "from quilt\n", repo.Git(t, "show", "quilt:quilt.txt"))
+	require.Equal(t, "add readme", strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%s", "quilt")))
+	db := repo.OpenDB(t)
+	quilt, ok := db.ReadTx().Branch("quilt")
+	require.True(t, ok)
+	require.True(t, quilt.Parent.Trunk)
+}
+
+func TestSeriesImportRollsBackOnFailure(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	patchDir := filepath.Join(t.TempDir(), "patches")
+	require.NoError(t, os.MkdirAll(patchDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(patchDir, "one.patch"), []byte(`--- /dev/null
++++ b/one.txt
+@@ -0,0 +1 @@
++one
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(patchDir, "broken.patch"), []byte(`--- a/missing.txt
++++ b/missing.txt
+@@ -1 +1 @@
+-old
++new
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(patchDir, "series"), []byte(
+		"# av-branch: one\none.patch\n# av-branch: two\nbroken.patch\n",
+	), 0644))
+
+	require.NotEqual(t, 0, Av(t, "series", "import", "--dir", patchDir).ExitCode)
+	require.Equal(t, "main", repo.CurrentBranch(t).Short())
+	require.Empty(t, strings.TrimSpace(repo.Git(t, "branch", "--list", "one", "two")))
+	_, ok := repo.OpenDB(t).ReadTx().Branch("one")
+	require.False(t, ok)
+}
+
+func seriesPatches(t *testing.T, seriesFile string) []string {
+	var patches []string
+	for _, line := range strings.Split(readFile(t, seriesFile), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patches = append(patches, line)
+		}
+	}
+	return patches
+}
+
+func readFile(t *testing.T, fp string) string {
+	data, err := os.ReadFile(fp)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,41 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignoff(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, `
+commit:
+    requireSignoff: true
+`)
+
+	const trailer = "Signed-off-by: av-test <av-test@nonexistent>"
+	RequireAv(t, "branch", "one")
+	repo.AddFile(t, repo.CreateFile(t, "one.txt", "one"))
+	RequireAv(t, "commit", "--signoff", "-m", "Add one")
+	require.Contains(t, repo.Git(t, "log", "-1", "--format=%B", "one"), trailer)
+
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two", gittest.WithMessage("Add two"))
+	output := Av(t, "pr", "--all", "--dry-run")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "not signed off")
+	require.Contains(t, output.Stderr, "Add two")
+	require.NotContains(t, output.Stderr, "Add one")
+
+	// The signed-off commit of one is not rewritten.
+	head := repo.GetCommitAtRef(t, "refs/heads/one")
+	RequireAv(t, "restack", "--signoff")
+	require.Equal(t, head, repo.GetCommitAtRef(t, "refs/heads/one"))
+	require.Contains(t, repo.Git(t, "log", "-1", "--format=%B", "two"), trailer)
+	require.Equal(t, 1, strings.Count(repo.Git(t, "log", "--format=%B", "one..two"), trailer))
+	RequireAv(t, "pr", "--all", "--dry-run")
+}
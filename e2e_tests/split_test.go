@@ -0,0 +1,128 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	// main -> feature (3 commits) -> child
+	RequireAv(t, "branch", "feature")
+	one := repo.CommitFile(t, "one.txt", "1")
+	two := repo.CommitFile(t, "two.txt", "2")
+	three := repo.CommitFile(t, "three.txt", "3")
+	RequireAv(t, "branch", "child")
+	repo.CommitFile(t, "child.txt", "child")
+	repo.CheckoutBranch(t, "refs/heads/feature")
+
+	// The unchanged plan is a no-op.
+	t.Setenv("GIT_EDITOR", ":")
+	RequireAv(t, "split")
+	require.Equal(t, "main", GetStoredParentBranchState(t, repo, "feature").Name)
+
+	// The commits can't be reordered.
+	setSplitPlan(t, "branch feature\npick "+two.String()+"\npick "+one.String()+"\npick "+three.String()+"\n")
+	require.NotEqual(t, 0, Av(t, "split").ExitCode)
+
+	// main -> feature-1 (one) -> feature-2 (two) -> feature (three) -> child
+	setSplitPlan(t, strings.Join([]string{
+		"branch feature-1",
+		"pick " + one.String()[:7] + " Write one.txt",
+		"branch feature-2",
+		"pick " + two.String()[:7] + " Write two.txt",
+		"branch feature",
+		"pick " + three.String()[:7] + " Write three.txt",
+	}, "\n"))
+	RequireAv(t, "split")
+	require.Equal(t, one, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("feature-1")))
+	require.Equal(t, two, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("feature-2")))
+	require.Equal(t, three, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("feature")))
+	require.Equal(t, plumbing.NewBranchReferenceName("feature"), repo.CurrentBranch(t))
+	require.True(t, GetStoredParentBranchState(t, repo, "feature-1").Trunk)
+	require.Equal(t, "feature-1", GetStoredParentBranchState(t, repo, "feature-2").Name)
+	require.Equal(t, one.String(), GetStoredParentBranchState(t, repo, "feature-2").Head)
+	require.Equal(t, "feature-2", GetStoredParentBranchState(t, repo, "feature").Name)
+	require.Equal(t, "feature", GetStoredParentBranchState(t, repo, "child").Name)
+
+	// The split branch can take the first group, and its children follow the last branch.
+	RequireAv(t, "undo")
+	setSplitPlan(t, strings.Join([]string{
+		"branch feature",
+		"pick " + one.String(),
+		"branch feature-rest",
+		"pick " + two.String(),
+		"pick " + three.String(),
+	}, "\n"))
+	RequireAv(t, "split")
+	require.Equal(t, one, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("feature")))
+	require.Equal(t, three, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("feature-rest")))
+	require.Equal(t, plumbing.NewBranchReferenceName("feature-rest"), repo.CurrentBranch(t))
+	require.True(t, GetStoredParentBranchState(t, repo, "feature").Trunk)
+	require.Equal(t, "feature", GetStoredParentBranchState(t, repo, "feature-rest").Name)
+	require.Equal(t, "feature-rest", GetStoredParentBranchState(t, repo, "child").Name)
+}
+
+func TestSplitRollsBackOnFailure(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "feature")
+	one := repo.CommitFile(t, "one.txt", "1")
+	two := repo.CommitFile(t, "two.txt", "2")
+	three := repo.CommitFile(t, "three.txt", "3")
+
+	// "feature-1/sub" can't be created once "feature-1" exists.
+	setSplitPlan(t, strings.Join([]string{
+		"branch feature-1",
+		"pick " + one.String(),
+		"branch feature-1/sub",
+		"pick " + two.String(),
+		"branch feature",
+		"pick " + three.String(),
+	}, "\n"))
+	require.NotEqual(t, 0, Av(t, "split").ExitCode)
+	require.Equal(t, "feature\n", repo.Git(t, "for-each-ref", "--format=%(refname:short)", "refs/heads/feature*"))
+	require.Equal(t, three, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("feature")))
+	require.Equal(t, plumbing.NewBranchReferenceName("feature"), repo.CurrentBranch(t))
+	require.Equal(t, "main", GetStoredParentBranchState(t, repo, "feature").Name)
+}
+
+func TestSplitBranchNamespace(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	repo.AppendAvConfig(t, "pullRequest:\n  branchNamePrefix: alice/\n  enforceBranchNamePrefix: true\n")
+
+	RequireAv(t, "branch", "feature")
+	one := repo.CommitFile(t, "one.txt", "1")
+	two := repo.CommitFile(t, "two.txt", "2")
+
+	setSplitPlan(t, strings.Join([]string{
+		"branch schema",
+		"pick " + one.String(),
+		"branch alice/feature",
+		"pick " + two.String(),
+	}, "\n"))
+	RequireAv(t, "split")
+	require.Equal(t, one, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("alice/schema")))
+	require.Equal(t, "alice/schema", GetStoredParentBranchState(t, repo, "alice/feature").Name)
+}
+
+// setSplitPlan makes the editor write the given plan.
+func setSplitPlan(t *testing.T, plan string) {
+	dir := t.TempDir()
+	planFile := filepath.Join(dir, "plan")
+	require.NoError(t, os.WriteFile(planFile, []byte(plan), 0644))
+	script := filepath.Join(dir, "editor.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\ncp '"+planFile+"' \"$1\"\n"), 0755))
+	t.Setenv("GIT_EDITOR", script)
+}
@@ -0,0 +1,90 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackAbandon(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	//     one -> two
+	//     three
+	RequireAv(t, "branch", "one")
+	oneHead := repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	twoHead := repo.CommitFile(t, "two.txt", "two")
+	repo.CheckoutBranch(t, "refs/heads/main")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "three")
+
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main"},
+		mockPR{ID: "nodeid-2", Number: 2, State: "MERGED", HeadRefName: "two", BaseRefName: "one"},
+	)
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	for _, pr := range server.pulls {
+		br, _ := tx.Branch(pr.HeadRefName)
+		br.PullRequest = &meta.PullRequest{
+			ID: pr.ID, Number: int64(pr.Number), State: githubv4.PullRequestState(pr.State),
+		}
+		tx.SetBranch(br)
+	}
+	require.NoError(t, tx.Commit())
+
+	repo.CheckoutBranch(t, "refs/heads/two")
+	RequireAv(t, "stack", "abandon", "--reason", "Superseded by a new design.")
+
+	// Only the open pull request is closed.
+	require.Equal(t, "CLOSED", server.pulls[0].State)
+	require.Equal(t, []string{
+		"This pull request was abandoned.\n\nReason: Superseded by a new design.",
+	}, server.pulls[0].Comments)
+	require.Empty(t, server.pulls[1].Comments)
+
+	require.Equal(t, plumbing.NewBranchReferenceName("main"), repo.CurrentBranch(t))
+	for _, name := range []string{"one", "two"} {
+		_, err := repo.GoGit.Reference(plumbing.NewBranchReferenceName(name), false)
+		require.Error(t, err, "the abandoned branch %q should be deleted", name)
+		_, exists := repo.OpenDB(t).ReadTx().Branch(name)
+		require.False(t, exists)
+	}
+	require.Equal(t, oneHead, repo.GetCommitAtRef(t, "refs/av/archive/one"))
+	require.Equal(t, twoHead, repo.GetCommitAtRef(t, "refs/av/archive/two"))
+	_, exists := repo.OpenDB(t).ReadTx().Branch("three")
+	require.True(t, exists, "the other stack should be kept")
+
+	RequireAv(t, "undo")
+	require.Equal(t, oneHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")))
+	require.Equal(t, twoHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two")))
+	require.Equal(t, "one", GetStoredParentBranchState(t, repo, "two").Name)
+	require.Equal(t, plumbing.NewBranchReferenceName("two"), repo.CurrentBranch(t))
+}
+
+func TestStackAbandonDelete(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	repo.CheckoutBranch(t, "refs/heads/main")
+
+	require.NotEqual(t, 0, Av(t, "stack", "abandon").ExitCode, "main is not in a stack")
+	require.NotEqual(t, 0, Av(t, "stack", "abandon", "--branches", "drop").ExitCode)
+
+	repo.CheckoutBranch(t, "refs/heads/one")
+	RequireAv(t, "stack", "abandon", "--branches", "delete")
+	_, err := repo.GoGit.Reference(plumbing.NewBranchReferenceName("one"), false)
+	require.Error(t, err)
+	_, err = repo.GoGit.Reference("refs/av/archive/one", false)
+	require.Error(t, err, "the deleted branch should not be archived")
+}
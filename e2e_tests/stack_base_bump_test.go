@@ -0,0 +1,55 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackBaseBump(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "one.txt", "one", gittest.WithMessage("Commit 1"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "two.txt", "two", gittest.WithMessage("Commit 2"))
+	stack1Before := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1"))
+	stack2Before := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-2"))
+
+	// A fix lands on the remote trunk.
+	var fix plumbing.Hash
+	repo.WithCheckoutBranch(t, "refs/heads/main", func() {
+		repo.CommitFile(t, "fix.txt", "fix", gittest.WithMessage("Fix"))
+		fix = repo.GetCommitAtRef(t, plumbing.HEAD)
+		repo.Git(t, "push", "origin", "main")
+		repo.Git(t, "reset", "--hard", "HEAD~1")
+	})
+
+	RequireAv(t, "stack", "base-bump")
+
+	// Only the stack root is rebased onto the latest trunk.
+	require.Equal(
+		t,
+		plumbing.ReferenceName("refs/heads/stack-2"),
+		repo.CurrentBranch(t),
+	)
+	stack1After := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-1"))
+	require.NotEqual(t, stack1Before, stack1After)
+	repo.Git(t, "merge-base", "--is-ancestor", fix.String(), "stack-1")
+	require.Equal(
+		t,
+		stack2Before,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("stack-2")),
+	)
+	require.Equal(t, "stack-2\n", RequireAv(t, "query", "needs-restack").Stdout)
+
+	// The next restack rebases the rest of the stack onto the new root.
+	RequireAv(t, "restack")
+	repo.Git(t, "merge-base", "--is-ancestor", stack1After.String(), "stack-2")
+	require.Equal(t, "", RequireAv(t, "query", "needs-restack").Stdout)
+}
@@ -0,0 +1,112 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackCheckout(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// A teammate's stack that exists only on the remote.
+	// main: root_commit
+	// one: root_commit -> 1a
+	// two: root_commit -> 1a -> 2a
+	// three: root_commit -> 1a -> 2a -> 3a
+	repo.Git(t, "checkout", "-b", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	repo.Git(t, "checkout", "-b", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	repo.Git(t, "checkout", "-b", "three")
+	repo.CommitFile(t, "three.txt", "three")
+	repo.Git(t, "push", "origin", "one", "two", "three")
+	repo.Git(t, "checkout", "main")
+	repo.Git(t, "branch", "-D", "one", "two", "three")
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main"},
+		mockPR{ID: "nodeid-2", Number: 2, State: "OPEN", HeadRefName: "two", BaseRefName: "one"},
+		mockPR{ID: "nodeid-3", Number: 3, State: "OPEN", HeadRefName: "three", BaseRefName: "two"},
+	)
+
+	require.NotEqual(t, 0, Av(t, "stack", "checkout", "https://github.com/other/repo/pull/2").ExitCode)
+
+	RequireAv(t, "stack", "checkout", "https://github.com/aviator-co/nonexistent/pull/2")
+	require.Equal(t, "two", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+
+	tx := repo.OpenDB(t).ReadTx()
+	one, ok := tx.Branch("one")
+	require.True(t, ok)
+	require.True(t, one.Parent.Trunk)
+	require.Equal(t, int64(1), one.PullRequest.GetNumber())
+	two, ok := tx.Branch("two")
+	require.True(t, ok)
+	require.Equal(t, "one", two.Parent.Name)
+	require.Equal(t, strings.TrimSpace(repo.Git(t, "rev-parse", "one")), two.Parent.Head)
+	require.Equal(t, "nodeid-2", two.PullRequest.ID)
+	three, ok := tx.Branch("three")
+	require.True(t, ok)
+	require.Equal(t, "two", three.Parent.Name)
+	require.Equal(t, "three", strings.TrimSpace(repo.Git(t, "show", "three:three.txt")))
+
+	// Checking out again is a no-op.
+	RequireAv(t, "stack", "checkout", "#3")
+	require.Equal(t, "three", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+}
+
+func TestAdoptFromPRChain(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// A departed teammate's stack that exists only on the remote.
+	// main: root_commit
+	// one: root_commit -> 1a
+	// two: root_commit -> 1a -> 2a
+	// three: root_commit -> 1a -> 2a -> 3a
+	repo.Git(t, "checkout", "-b", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	repo.Git(t, "checkout", "-b", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	repo.Git(t, "checkout", "-b", "three")
+	repo.CommitFile(t, "three.txt", "three")
+	repo.Git(t, "push", "origin", "one", "two", "three")
+	repo.Git(t, "checkout", "main")
+	repo.Git(t, "branch", "-D", "one", "two", "three")
+	server.pulls = append(server.pulls,
+		mockPR{ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main"},
+		mockPR{ID: "nodeid-2", Number: 2, State: "OPEN", HeadRefName: "two", BaseRefName: "one"},
+		mockPR{ID: "nodeid-3", Number: 3, State: "OPEN", HeadRefName: "three", BaseRefName: "two"},
+	)
+
+	RequireAv(t, "adopt", "--from-pr-chain", "2")
+	// The current branch is not changed.
+	require.Equal(t, "main", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+
+	tx := repo.OpenDB(t).ReadTx()
+	one, ok := tx.Branch("one")
+	require.True(t, ok)
+	require.True(t, one.Parent.Trunk)
+	require.Equal(t, int64(1), one.PullRequest.GetNumber())
+	two, ok := tx.Branch("two")
+	require.True(t, ok)
+	require.Equal(t, "one", two.Parent.Name)
+	require.Equal(t, strings.TrimSpace(repo.Git(t, "rev-parse", "one")), two.Parent.Head)
+	require.Equal(t, int64(2), two.PullRequest.GetNumber())
+	// The pull requests above the given one are not adopted.
+	_, ok = tx.Branch("three")
+	require.False(t, ok)
+	require.Empty(t, strings.TrimSpace(repo.Git(t, "branch", "--list", "three")))
+
+	// The deprecated av stack adopt has the same flag.
+	RequireAv(t, "stack", "adopt", "--from-pr-chain", "#3")
+	three, ok := repo.OpenDB(t).ReadTx().Branch("three")
+	require.True(t, ok)
+	require.Equal(t, "two", three.Parent.Name)
+}
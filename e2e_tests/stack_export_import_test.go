@@ -0,0 +1,99 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackExportImport(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "feature/one")
+	repo.CommitFile(t, "one.txt", "one")
+	repo.CommitFile(t, "one.txt", "one more")
+	RequireAv(t, "branch", "feature/two")
+	repo.CommitFile(t, "two.txt", "two")
+
+	patchDir := filepath.Join(t.TempDir(), "patches")
+	RequireAv(t, "stack", "export", "--patch-dir", patchDir)
+	require.FileExists(t, filepath.Join(patchDir, "manifest.json"))
+	patches, err := filepath.Glob(filepath.Join(patchDir, "01-feature-one", "*.patch"))
+	require.NoError(t, err)
+	require.Len(t, patches, 2)
+
+	// Exporting to a non-empty directory fails.
+	require.NotEqual(t, 0, Av(t, "stack", "export", "--patch-dir", patchDir).ExitCode)
+
+	other := gittest.NewTempRepo(t)
+	Chdir(t, other.RepoDir)
+	RequireAv(t, "stack", "import", "--patch-dir", patchDir)
+
+	require.Equal(t, "one more", other.Git(t, "show", "feature/one:one.txt"))
+	require.Equal(t, "two", other.Git(t, "show", "feature/two:two.txt"))
+	require.Equal(
+		t,
+		other.Git(t, "rev-parse", "feature/one"),
+		other.Git(t, "rev-parse", "feature/two^"),
+	)
+
+	db := other.OpenDB(t)
+	two, ok := db.ReadTx().Branch("feature/two")
+	require.True(t, ok)
+	require.Equal(t, "feature/one", two.Parent.Name)
+	one, ok := db.ReadTx().Branch("feature/one")
+	require.True(t, ok)
+	require.True(t, one.Parent.Trunk)
+
+	// Importing again fails because the branches exist.
+	require.NotEqual(t, 0, Av(t, "stack", "import", "--patch-dir", patchDir).ExitCode)
+}
+
+func TestStackImportRollsBackOnFailure(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "feature/one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "feature/two")
+	repo.CommitFile(t, "two.txt", "two")
+
+	patchDir := filepath.Join(t.TempDir(), "patches")
+	RequireAv(t, "stack", "export", "--patch-dir", patchDir)
+
+	// The patch of feature/two cannot be applied.
+	patches, err := filepath.Glob(filepath.Join(patchDir, "02-feature-two", "*.patch"))
+	require.NoError(t, err)
+	require.Len(t, patches, 1)
+	require.NoError(t, os.WriteFile(patches[0], []byte("not a patch\n"), 0644))
+
+	other := gittest.NewTempRepo(t)
+	Chdir(t, other.RepoDir)
+	before := other.Git(t, "for-each-ref", "refs/heads")
+
+	out := Av(t, "stack", "import", "--patch-dir", patchDir)
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Equal(t, before, other.Git(t, "for-each-ref", "refs/heads"))
+	require.Equal(t, "refs/heads/main", other.CurrentBranch(t).String())
+	require.Empty(t, other.Git(t, "status", "--porcelain"))
+	_, ok := other.OpenDB(t).ReadTx().Branch("feature/one")
+	require.False(t, ok)
+
+	// The directories in the manifest must be in the patch directory.
+	manifestPath := filepath.Join(patchDir, "manifest.json")
+	data, err := os.ReadFile(manifestPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(
+		manifestPath,
+		[]byte(strings.Replace(string(data), `"dir": "01-feature-one"`, `"dir": "../01-feature-one"`, 1)),
+		0644,
+	))
+	out = Av(t, "stack", "import", "--patch-dir", patchDir)
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Contains(t, out.Stderr, "invalid directory")
+}
@@ -3,6 +3,7 @@ package e2e_tests
 import (
 	"testing"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/stretchr/testify/require"
 
 	"github.com/aviator-co/av/internal/git/gittest"
@@ -26,3 +27,40 @@ func TestStackForEach(t *testing.T) {
 	)
 	require.Equal(t, "Commit 1a\nCommit 2a\nCommit 3a\n", out.Stdout)
 }
+
+func TestStackForEachDirectionAndSummary(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "my-file", "2a\n", gittest.WithMessage("Commit 2a"))
+	RequireAv(t, "branch", "stack-3")
+	repo.CommitFile(t, "my-file", "3a\n", gittest.WithMessage("Commit 3a"))
+
+	out := RequireAv(t,
+		"stack", "foreach", "--from", "stack-2", "--down", "--",
+		"git", "show", "--format=%s", "--quiet", "HEAD", "--",
+	)
+	require.Equal(t, "Commit 2a\nCommit 1a\n", out.Stdout)
+	require.Equal(t, plumbing.NewBranchReferenceName("stack-3"), repo.CurrentBranch(t))
+
+	// The command fails on stack-2 and the rest is skipped.
+	out = Av(t, "stack", "foreach", "--json", "--", "grep", "-q", "1a", "my-file")
+	require.Equal(t, 1, out.ExitCode)
+	require.JSONEq(t, `[
+		{"branch": "stack-1", "exitCode": 0},
+		{"branch": "stack-2", "exitCode": 1},
+		{"branch": "stack-3", "exitCode": -1, "skipped": true}
+	]`, out.Stdout)
+	require.Equal(t, plumbing.NewBranchReferenceName("stack-3"), repo.CurrentBranch(t))
+
+	out = Av(t, "stack", "foreach", "--json", "--continue-on-error", "--", "grep", "-q", "2a", "my-file")
+	require.Equal(t, 1, out.ExitCode)
+	require.JSONEq(t, `[
+		{"branch": "stack-1", "exitCode": 1},
+		{"branch": "stack-2", "exitCode": 0},
+		{"branch": "stack-3", "exitCode": 1}
+	]`, out.Stdout)
+}
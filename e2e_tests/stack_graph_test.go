@@ -0,0 +1,107 @@
+package e2e_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackGraph(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "my-file", "1a\n2a\n", gittest.WithMessage("Commit 2a"))
+
+	out := RequireAv(t, "stack", "graph")
+	require.Contains(t, out.Stdout, "digraph stacks {")
+	require.Contains(t, out.Stdout, `"main" [style="rounded,bold"];`)
+	require.Contains(t, out.Stdout, `"main" -> "stack-1";`)
+	require.Contains(t, out.Stdout, `"stack-1" -> "stack-2";`)
+	// stack-2 is checked out.
+	require.Regexp(t, `"stack-2" \[label="stack-2[^\]]*fillcolor="lightblue"\];`, out.Stdout)
+
+	require.NotEqual(t, 0, Av(t, "stack", "graph", "--open").ExitCode)
+}
+
+func TestStackGraphServe(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "my-file", "1a\n2a\n", gittest.WithMessage("Commit 2a"))
+
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	for i, link := range []string{"https://github.com/example/repo/pull/1", "javascript:alert(1)"} {
+		br, _ := tx.Branch(fmt.Sprintf("stack-%d", i+1))
+		br.PullRequest = &meta.PullRequest{
+			ID: fmt.Sprintf("nodeid-%d", i+1), Number: int64(i + 1), State: "OPEN", Permalink: link,
+		}
+		tx.SetBranch(br)
+	}
+	require.NoError(t, tx.Commit())
+
+	// Only the http(s) links are linked.
+	out := RequireAv(t, "stack", "graph")
+	require.Contains(t, out.Stdout, `URL="https://github.com/example/repo/pull/1"`)
+	require.NotContains(t, out.Stdout, "javascript:")
+
+	// Pick a free port for the server.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	cmd := exec.Command(avCmdPath, "stack", "graph", "--serve", "--addr", addr)
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		resp, err = http.Get("http://" + addr + "/graph.json")
+		return err == nil
+	}, 10*time.Second, 50*time.Millisecond)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var graph struct {
+		Trunks   []string `json:"trunks"`
+		Branches []struct {
+			Name        string `json:"name"`
+			Current     bool   `json:"current"`
+			PullRequest *struct {
+				URL string `json:"url"`
+			} `json:"pullRequest"`
+		} `json:"branches"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&graph))
+	require.Equal(t, []string{"main"}, graph.Trunks)
+	require.Len(t, graph.Branches, 2)
+	require.Equal(t, "stack-1", graph.Branches[0].Name)
+	require.Equal(t, "https://github.com/example/repo/pull/1", graph.Branches[0].PullRequest.URL)
+	require.Equal(t, "stack-2", graph.Branches[1].Name)
+	require.True(t, graph.Branches[1].Current)
+	require.Empty(t, graph.Branches[1].PullRequest.URL)
+
+	page, err := http.Get("http://" + addr + "/")
+	require.NoError(t, err)
+	defer page.Body.Close()
+	require.Equal(t, http.StatusOK, page.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", page.Header.Get("Content-Type"))
+}
@@ -0,0 +1,57 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackMergeCascadeLocal(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "file-1", "1a\n", gittest.WithMessage("Commit 1a"))
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "file-2", "2a\n", gittest.WithMessage("Commit 2a"))
+	repo.CommitFile(t, "file-2", "2a\n2b\n", gittest.WithMessage("Commit 2b"))
+	head := repo.Git(t, "rev-parse", "HEAD")
+
+	// Without --cascade-local, nothing is merged.
+	require.NotEqual(t, 0, Av(t, "stack", "merge").ExitCode)
+
+	for method, commits := range map[string]string{
+		"squash": "Squash-merge branch 'stack-2'\nSquash-merge branch 'stack-1'\n",
+		"rebase": "Commit 2b\nCommit 2a\nCommit 1a\n",
+		"merge":  "Merge branch 'stack-2'\nMerge branch 'stack-1'\n",
+	} {
+		RequireAv(t, "stack", "merge", "--cascade-local", "--merge-method", method)
+		require.Equal(
+			t, commits,
+			repo.Git(t, "log", "--first-parent", "--format=%s", "main..refs/av/cascade-local"),
+			method,
+		)
+	}
+
+	// The test command runs on the merged result, and its exit code is returned.
+	out := RequireAv(t, "stack", "merge", "--cascade-local", "--", "cat", "file-1", "file-2")
+	require.Equal(t, "1a\n2a\n2b\n", out.Stdout)
+	require.Equal(
+		t, 3, Av(t, "stack", "merge", "--cascade-local", "--", "sh", "-c", "exit 3").ExitCode,
+	)
+
+	// A change on the trunk that conflicts with the stack stops the merge.
+	repo.CheckoutBranch(t, "refs/heads/main")
+	repo.CommitFile(t, "file-2", "main\n", gittest.WithMessage("Commit main"))
+	repo.Git(t, "push", "origin", "main")
+	repo.CheckoutBranch(t, "refs/heads/stack-2")
+	out = Av(t, "stack", "merge", "--cascade-local")
+	require.Equal(t, 1, out.ExitCode)
+	require.Contains(t, out.Stderr, "stack-2 conflicts in")
+
+	// The branches are not changed.
+	require.Equal(t, head, repo.Git(t, "rev-parse", "stack-2"))
+	require.Len(t, strings.Split(strings.TrimSpace(repo.Git(t, "worktree", "list")), "\n"), 1)
+}
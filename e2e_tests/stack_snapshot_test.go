@@ -0,0 +1,55 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackSnapshot(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2\n")
+	oneHead := strings.TrimSpace(repo.Git(t, "rev-parse", "one"))
+	twoHead := strings.TrimSpace(repo.Git(t, "rev-parse", "two"))
+
+	RequireAv(t, "stack", "snapshot", "save", "before-split")
+	require.NotEqual(t, 0, Av(t, "stack", "snapshot", "save", "before-split").ExitCode)
+	require.Contains(t, RequireAv(t, "stack", "snapshot", "list").Stdout, "before-split\t")
+
+	// Rewrite the stack: amend the first branch, move the second one onto the trunk, delete
+	// it, and add a new branch.
+	repo.Git(t, "switch", "one")
+	repo.CommitFile(t, "one.txt", "1 amended\n", gittest.WithAmend())
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "3\n")
+	repo.Git(t, "branch", "-D", "two")
+	// The recorded commits are kept by git gc.
+	repo.Git(t, "reflog", "expire", "--expire=now", "--all")
+	repo.Git(t, "gc", "--prune=now")
+
+	RequireAv(t, "stack", "snapshot", "restore", "before-split")
+	require.Equal(t, oneHead, strings.TrimSpace(repo.Git(t, "rev-parse", "one")))
+	require.Equal(t, twoHead, strings.TrimSpace(repo.Git(t, "rev-parse", "two")))
+	// The branch added after the snapshot is kept but no longer tracked, so the recorded
+	// branch is checked out instead.
+	require.Equal(t, "two", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	require.Contains(t, repo.Git(t, "branch", "--list", "three"), "three")
+	branches := repo.OpenDB(t).ReadTx().AllBranches()
+	require.Contains(t, branches, "two")
+	require.Equal(t, "one", branches["two"].Parent.Name)
+	require.NotContains(t, branches, "three")
+
+	// The restore can be undone.
+	RequireAv(t, "undo")
+	require.Contains(t, repo.OpenDB(t).ReadTx().AllBranches(), "three")
+
+	RequireAv(t, "stack", "snapshot", "delete", "before-split")
+	require.NotEqual(t, 0, Av(t, "stack", "snapshot", "restore", "before-split").ExitCode)
+}
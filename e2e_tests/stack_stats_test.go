@@ -0,0 +1,68 @@
+package e2e_tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackStats(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1\n2\n3\n")
+	repo.CommitFile(t, "shared.txt", "a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "shared.txt", "b\nc\n")
+
+	var stats struct {
+		Branches []struct {
+			Name           string  `json:"name"`
+			Parent         string  `json:"parent"`
+			Commits        int     `json:"commits"`
+			FilesChanged   int     `json:"filesChanged"`
+			Additions      int     `json:"additions"`
+			Deletions      int     `json:"deletions"`
+			OldestCommitAt *string `json:"oldestCommitAt"`
+		} `json:"branches"`
+		Total struct {
+			Branches     int `json:"branches"`
+			Commits      int `json:"commits"`
+			FilesChanged int `json:"filesChanged"`
+			Additions    int `json:"additions"`
+			Deletions    int `json:"deletions"`
+		} `json:"total"`
+	}
+	// The stats are the same from any branch of the stack.
+	repo.Git(t, "switch", "one")
+	require.NoError(t, json.Unmarshal([]byte(RequireAv(t, "stack", "stats", "--json").Stdout), &stats))
+
+	require.Len(t, stats.Branches, 2)
+	one, two := stats.Branches[0], stats.Branches[1]
+	require.Equal(t, "one", one.Name)
+	require.Equal(t, "main", one.Parent)
+	require.Equal(t, 2, one.Commits)
+	require.Equal(t, 2, one.FilesChanged)
+	require.Equal(t, 4, one.Additions)
+	require.NotNil(t, one.OldestCommitAt)
+	require.Equal(t, "two", two.Name)
+	require.Equal(t, "one", two.Parent)
+	require.Equal(t, 1, two.Commits)
+	require.Equal(t, 1, two.FilesChanged)
+	require.Equal(t, 2, two.Additions)
+	require.Equal(t, 1, two.Deletions)
+
+	require.Equal(t, 2, stats.Total.Branches)
+	require.Equal(t, 3, stats.Total.Commits)
+	// shared.txt is changed in both branches.
+	require.Equal(t, 2, stats.Total.FilesChanged)
+	require.Equal(t, 6, stats.Total.Additions)
+	require.Equal(t, 1, stats.Total.Deletions)
+
+	output := RequireAv(t, "stack", "stats", "two")
+	require.Contains(t, output.Stdout, "Stack of one (2 branches)")
+	require.Regexp(t, `total\s+3\s+2\s+\+6 -1`, output.Stdout)
+}
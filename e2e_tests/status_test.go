@@ -0,0 +1,46 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	repo.CommitFile(t, "two.txt", "two\nmore")
+	RequireAv(t, "switch", "one")
+	repo.CommitFile(t, "one.txt", "one\nmore")
+	RequireAv(t, "switch", "two")
+	repo.CreateFile(t, "two.txt", "changed")
+	repo.CreateFile(t, "untracked.txt", "untracked")
+
+	output := RequireAv(t, "status", "--porcelain")
+	require.Equal(
+		t,
+		"branch two\n"+
+			"parent one\n"+
+			"parent-is-trunk false\n"+
+			"depth 2\n"+
+			"stack-size 2\n"+
+			"needs-restack true\n"+
+			"staged 0\n"+
+			"unstaged 1\n"+
+			"untracked 1\n"+
+			"conflicted 0\n"+
+			"ahead 2\n"+
+			"behind 1\n",
+		output.Stdout,
+	)
+	require.Equal(t, "two 2/2 ↑2 ↓1 restack ~1 ?1\n", RequireAv(t, "status").Stdout)
+
+	repo.Git(t, "checkout", "--force", "main")
+	require.Contains(t, RequireAv(t, "status", "--porcelain").Stdout, "branch main\nparent \n")
+}
@@ -0,0 +1,78 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+// avWithStdin runs av with the given lines as the standard input.
+func avWithStdin(t *testing.T, stdin string, args ...string) AvOutput {
+	shArgs := append([]string{"-c", `printf '` + stdin + `' | "$0" --debug "$@"`, avCmdPath}, args...)
+	return Cmd(t, "sh", shArgs...)
+}
+
+func TestReparentStdin(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two -> three
+	//             -> four
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "3a\n")
+	repo.Git(t, "checkout", "one")
+	RequireAv(t, "branch", "four")
+	repo.CommitFile(t, "four.txt", "4a\n")
+
+	// Move two (with three) and four onto main.
+	out := avWithStdin(t, `four\ntwo\n`, "reparent", "--stdin", "--parent", "main")
+	require.Equal(t, 0, out.ExitCode)
+
+	main := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("main")).String()
+	require.Equal(t, main, strings.TrimSpace(repo.Git(t, "rev-parse", "two^")))
+	require.Equal(t, main, strings.TrimSpace(repo.Git(t, "rev-parse", "four^")))
+	require.Equal(
+		t,
+		repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two")).String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "three^")),
+	)
+	require.Equal(t, "four\none\ntwo\n", RequireAv(t, "query", "parent=main").Stdout)
+	require.Equal(t, "three\n", RequireAv(t, "query", "parent=two").Stdout)
+}
+
+func TestSyncStdin(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> one -> two
+	//             -> three
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	repo.Git(t, "checkout", "one")
+	RequireAv(t, "branch", "three")
+	repo.CommitFile(t, "three.txt", "3a\n")
+
+	repo.Git(t, "checkout", "one")
+	repo.CommitFile(t, "one.txt", "1a\n1b\n")
+	oneHead := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")).String()
+	threeHead := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("three")).String()
+
+	// Only two is synced.
+	out := avWithStdin(t, `two\n`, "sync", "--stdin", "--push=no", "--prune=no")
+	require.Equal(t, 0, out.ExitCode)
+	require.Equal(t, oneHead, strings.TrimSpace(repo.Git(t, "rev-parse", "two^")))
+	require.Equal(t, threeHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("three")).String())
+}
@@ -0,0 +1,56 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwitchCreate(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "switch", "-c", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "switch", "--create", "two")
+	require.Equal(t, "two", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	RequireAv(t, "switch", "-c", "three", "one")
+	require.Equal(t, "three", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+
+	db := repo.OpenDB(t)
+	one, _ := db.ReadTx().Branch("one")
+	require.True(t, one.Parent.Trunk)
+	two, _ := db.ReadTx().Branch("two")
+	require.Equal(t, "one", two.Parent.Name)
+	three, _ := db.ReadTx().Branch("three")
+	require.Equal(t, "one", three.Parent.Name)
+
+	RequireAv(t, "switch", "two")
+	require.Equal(t, "two", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+}
+
+func TestSwitchFuzzyQuery(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "feature-login")
+	repo.CommitFile(t, "login.txt", "login")
+	RequireAv(t, "branch", "feature-logout")
+	repo.CommitFile(t, "logout.txt", "logout")
+	RequireAv(t, "branch", "fix-typo")
+	repo.CommitFile(t, "typo.txt", "typo")
+
+	// A unique match is checked out.
+	RequireAv(t, "switch", "flogin")
+	require.Equal(t, "feature-login", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	RequireAv(t, "switch", "TYPO")
+	require.Equal(t, "fix-typo", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+
+	// An ambiguous query is an error.
+	output := Av(t, "switch", "feature")
+	require.NotEqual(t, 0, output.ExitCode)
+	require.Contains(t, output.Stderr, "feature-login, feature-logout")
+	require.Equal(t, "fix-typo", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+}
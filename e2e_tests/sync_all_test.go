@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSyncAll(t *testing.T) {
@@ -39,3 +40,76 @@ func TestSyncAll(t *testing.T) {
 	// HEAD of main should be an ancestor of HEAD of stack-2
 	repo.Git(t, "merge-base", "--is-ancestor", "main", "stack-2")
 }
+
+func TestSyncAllSummary(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	repo.Git(t, "switch", "main")
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+
+	repo.Git(t, "switch", "main")
+	RequireAv(t, "branch", "stack-2")
+	repo.CommitFile(t, "my-file", "2a\n", gittest.WithMessage("Commit 2a"))
+
+	repo.Git(t, "switch", "main")
+	repo.CommitFile(t, "other-file", "X2\n", gittest.WithMessage("Commit X2"))
+	repo.Git(t, "push", "origin", "main")
+	RequireAv(t, "branch", "stack-3")
+	repo.CommitFile(t, "my-file", "3a\n", gittest.WithMessage("Commit 3a"))
+
+	//     main:    X  -> X2
+	//     stack-1:  \ -> 1a
+	//     stack-2:  \ -> 2a
+	//     stack-3:        \ -> 3a
+
+	out := RequireAv(t, "sync", "--all")
+	require.Contains(t, out.Stderr, "Summary of the stacks:")
+	require.Contains(t, out.Stderr, "stack-1: restacked stack-1")
+	require.Contains(t, out.Stderr, "stack-2: restacked stack-2")
+	require.Contains(t, out.Stderr, "stack-3: up to date")
+	require.Contains(t, out.Stderr, "needs attention: no pull request for stack-1")
+	// The next steps are opt-in.
+	require.NotContains(t, out.Stderr, "Next steps:")
+
+	for _, br := range []string{"stack-1", "stack-2", "stack-3"} {
+		require.Equal(
+			t, 0,
+			Cmd(t, "git", "merge-base", "--is-ancestor", "main", br).ExitCode,
+			"HEAD of main should be an ancestor of HEAD of %s", br,
+		)
+	}
+}
+
+func TestSyncSummaryNextSteps(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "stack-1")
+	repo.CommitFile(t, "my-file", "1a\n", gittest.WithMessage("Commit 1a"))
+	repo.Git(t, "switch", "main")
+	repo.CommitFile(t, "other-file", "X2\n", gittest.WithMessage("Commit X2"))
+	repo.Git(t, "push", "origin", "main")
+	repo.Git(t, "switch", "stack-1")
+
+	// Without --summary, a sync of the current stack prints no summary.
+	out := RequireAv(t, "sync", "--rebase-to-trunk")
+	require.NotContains(t, out.Stderr, "Summary of the stacks:")
+	require.NotContains(t, out.Stderr, "Next steps:")
+
+	repo.Git(t, "switch", "main")
+	repo.CommitFile(t, "other-file", "X3\n", gittest.WithMessage("Commit X3"))
+	repo.Git(t, "push", "origin", "main")
+	repo.Git(t, "switch", "stack-1")
+
+	out = RequireAv(t, "sync", "--rebase-to-trunk", "--summary")
+	require.Contains(t, out.Stderr, "stack-1: restacked stack-1")
+	require.Contains(t, out.Stderr, "Next steps:")
+	require.Contains(t, out.Stderr, "printf '%s\\n' stack-1 | av pr --all --stdin")
+	require.NotContains(t, out.Stderr, "av tree")
+}
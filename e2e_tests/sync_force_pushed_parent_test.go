@@ -0,0 +1,84 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+// forcePushBehind rewrites the branch on the remote as a teammate would (by amending its last
+// commit), without updating the remote tracking branch of the local repository. The rewritten
+// commit is returned.
+func forcePushBehind(t *testing.T, repo *gittest.GitTestRepo, branch string) string {
+	oldCommit := strings.TrimSpace(repo.Git(t, "rev-parse", "refs/remotes/origin/"+branch))
+	newCommit := strings.TrimSpace(repo.Git(t,
+		"commit-tree", oldCommit+"^{tree}", "-p", oldCommit+"^", "-m", "Amended by a teammate",
+	))
+	repo.Git(t, "push", "--force", "origin", newCommit+":refs/heads/"+branch)
+	repo.Git(t, "update-ref", "refs/remotes/origin/"+branch, oldCommit)
+	return newCommit
+}
+
+func TestSyncForcePushedParent(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	//     main -> one -> two
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	repo.Git(t, "push", "origin", "one", "two")
+
+	newOne := forcePushBehind(t, repo, "one")
+
+	out := RequireAv(t, "sync", "--push=no", "--prune=no")
+	require.Contains(t, out.Stdout, "one was force-pushed on the remote")
+
+	// The local branch follows the remote, and only the commit of two is rebased onto it.
+	require.Equal(t, newOne, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")).String())
+	require.Equal(t, newOne, strings.TrimSpace(repo.Git(t, "rev-parse", "two^")))
+	require.Equal(t, "1", strings.TrimSpace(repo.Git(t, "rev-list", "--count", "one..two")))
+}
+
+func TestSyncForcePushedBranchWithLocalChanges(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	repo.Git(t, "push", "origin", "one")
+	repo.Git(t, "config", "branch.one.av-pushed-commit", strings.TrimSpace(repo.Git(t, "rev-parse", "one")))
+	repo.CommitFile(t, "one.txt", "1a\n1b\n")
+	localOne := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one"))
+
+	server.pulls = append(server.pulls, mockPR{
+		ID: "nodeid-1", Number: 1, State: "OPEN", HeadRefName: "one", BaseRefName: "main",
+	})
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	oneMeta, _ := tx.Branch("one")
+	oneMeta.PullRequest = &meta.PullRequest{ID: "nodeid-1", Number: 1, State: "OPEN"}
+	tx.SetBranch(oneMeta)
+	require.NoError(t, tx.Commit())
+
+	newOne := forcePushBehind(t, repo, "one")
+
+	// The local changes are kept, and the rewritten remote branch is not overwritten.
+	out := RequireAv(t, "sync", "--push=yes", "--prune=no")
+	require.Contains(t, out.Stdout, "git rebase --onto "+newOne[:7])
+	require.Equal(t, localOne, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")))
+	require.Equal(
+		t,
+		newOne+"\trefs/heads/one",
+		strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/one")),
+	)
+}
@@ -0,0 +1,85 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncMergeQueue(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	//     main -> one -> two
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	repo.Git(t, "push", "origin", "one")
+	repo.Git(t, "push", "origin", "one:refs/pull/1/head")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	oneHead := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one"))
+
+	server.pulls = append(server.pulls, mockPR{
+		ID:           "nodeid-1",
+		Number:       1,
+		State:        "OPEN",
+		HeadRefName:  "one",
+		BaseRefName:  "main",
+		InMergeQueue: true,
+	})
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	oneMeta, _ := tx.Branch("one")
+	oneMeta.PullRequest = &meta.PullRequest{ID: "nodeid-1", Number: 1, State: "OPEN"}
+	tx.SetBranch(oneMeta)
+	require.NoError(t, tx.Commit())
+
+	repo.WithCheckoutBranch(t, "refs/heads/main", func() {
+		repo.CommitFile(t, "main.txt", "main\n")
+		repo.Git(t, "push", "origin", "main")
+	})
+
+	// one is in the merge queue, so it's neither rebased onto the new trunk nor pushed.
+	RequireAv(t, "sync", "--rebase-to-trunk", "--push=yes", "--prune=no")
+	oneMeta, _ = repo.OpenDB(t).ReadTx().Branch("one")
+	require.True(t, oneMeta.IsInMergeQueue())
+	require.Equal(t, oneHead, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")))
+	require.Equal(
+		t,
+		oneHead.String()+"\trefs/heads/one",
+		strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/one")),
+	)
+	require.Equal(
+		t,
+		oneHead.String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "two^")),
+	)
+
+	// The merge queue lands one with a rewritten commit.
+	var queueCommit plumbing.Hash
+	repo.WithCheckoutBranch(t, "refs/heads/main", func() {
+		repo.Git(t, "merge", "--squash", "one")
+		repo.Git(t, "commit", "--no-edit")
+		queueCommit = repo.GetCommitAtRef(t, plumbing.HEAD)
+		repo.Git(t, "push", "origin", "main")
+	})
+	server.pulls[0].State = "MERGED"
+	server.pulls[0].InMergeQueue = false
+	server.pulls[0].MergeCommitOID = queueCommit.String()
+
+	RequireAv(t, "sync", "--push=no", "--prune=yes")
+	_, err := repo.GoGit.Reference(plumbing.NewBranchReferenceName("one"), false)
+	require.Error(t, err, "the merged branch should be pruned")
+	require.Equal(t, "main", GetStoredParentBranchState(t, repo, "two").Name)
+	require.Equal(
+		t,
+		queueCommit.String(),
+		strings.TrimSpace(repo.Git(t, "rev-parse", "two^")),
+	)
+}
@@ -0,0 +1,108 @@
+package e2e_tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+// pushWithPullRequests pushes the branches as av would, and gives them open pull requests.
+func pushWithPullRequests(t *testing.T, repo *gittest.GitTestRepo, server *mockGitHubServer, branches ...string) {
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	for i, name := range branches {
+		repo.Git(t, "push", "origin", name)
+		repo.Git(t, "config", "branch."+name+".av-pushed-commit", strings.TrimSpace(repo.Git(t, "rev-parse", name)))
+		br, _ := tx.Branch(name)
+		id := "nodeid-" + name
+		server.pulls = append(server.pulls, mockPR{
+			ID: id, Number: i + 1, State: "OPEN", HeadRefName: name, BaseRefName: br.Parent.Name,
+		})
+		br.PullRequest = &meta.PullRequest{ID: id, Number: int64(i + 1), State: "OPEN"}
+		tx.SetBranch(br)
+	}
+	require.NoError(t, tx.Commit())
+}
+
+func TestSyncPushOnlyIfRestacked(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	//     main -> one -> two
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	pushWithPullRequests(t, repo, server, "one", "two")
+	remoteOne := strings.TrimSpace(repo.Git(t, "rev-parse", "one"))
+
+	// Amend one locally. Only two is rebased by the sync.
+	repo.Git(t, "switch", "one")
+	repo.CommitFile(t, "one.txt", "1b\n", gittest.WithAmend())
+
+	RequireAv(t, "sync", "--push=only-if-restacked", "--prune=no")
+	require.Equal(t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "two")),
+		strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/two"))[:40],
+	)
+	require.Equal(t,
+		remoteOne,
+		strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/one"))[:40],
+	)
+}
+
+func TestSyncPushRemoteMoved(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	pushWithPullRequests(t, repo, server, "one")
+
+	// A teammate pushes a commit on top of one.
+	pushed := strings.TrimSpace(repo.Git(t, "rev-parse", "one"))
+	teammate := strings.TrimSpace(repo.Git(t,
+		"commit-tree", pushed+"^{tree}", "-p", pushed, "-m", "Pushed by a teammate",
+	))
+	repo.Git(t, "push", "origin", teammate+":refs/heads/one")
+
+	// The local branch is amended without the commit of the teammate.
+	repo.CommitFile(t, "one.txt", "1b\n", gittest.WithAmend())
+
+	out := Av(t, "sync", "--push=always", "--prune=no")
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Contains(t, out.Stdout+out.Stderr, "moved unexpectedly")
+	require.Equal(t,
+		teammate,
+		strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/one"))[:40],
+	)
+}
+
+func TestSyncPushAfterGitPush(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	pushWithPullRequests(t, repo, server, "one")
+
+	// The user amends the branch and force-pushes it with plain git, then amends it again.
+	repo.CommitFile(t, "one.txt", "1b\n", gittest.WithAmend())
+	repo.Git(t, "push", "--force", "origin", "one")
+	repo.CommitFile(t, "one.txt", "1c\n", gittest.WithAmend())
+
+	RequireAv(t, "sync", "--push=always", "--prune=no")
+	require.Equal(t,
+		strings.TrimSpace(repo.Git(t, "rev-parse", "one")),
+		strings.TrimSpace(repo.Git(t, "ls-remote", "origin", "refs/heads/one"))[:40],
+	)
+}
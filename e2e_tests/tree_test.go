@@ -1,9 +1,13 @@
 package e2e_tests
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTree(t *testing.T) {
@@ -22,3 +26,127 @@ func TestTree(t *testing.T) {
 
 	RequireAv(t, "tree")
 }
+
+func TestTreeActivity(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "foo")
+	repo.CommitFile(t, "foo", "foo")
+
+	require.NotContains(t, RequireAv(t, "tree").Stdout, "last commit")
+	require.Contains(t, RequireAv(t, "tree", "--activity").Stdout, "last commit")
+}
+
+func TestTreeJSON(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "foo")
+	repo.CommitFile(t, "foo", "foo")
+	repo.CommitFile(t, "foo2", "foo2")
+
+	RequireAv(t, "branch", "bar")
+	repo.CommitFile(t, "bar", "bar")
+
+	type branch struct {
+		Name          string   `json:"name"`
+		Parent        string   `json:"parent"`
+		ParentIsTrunk bool     `json:"parentIsTrunk"`
+		Children      []string `json:"children"`
+		Current       bool     `json:"current"`
+		Ahead         int      `json:"ahead"`
+		Behind        int      `json:"behind"`
+	}
+	for _, args := range [][]string{
+		{"tree", "--json"},
+		{"--output=json", "tree"},
+		{"query", "--json", "stack(foo)"},
+	} {
+		var branches []branch
+		require.NoError(t, json.Unmarshal([]byte(RequireAv(t, args...).Stdout), &branches), args)
+		require.Equal(t, []branch{
+			{Name: "foo", Parent: "main", ParentIsTrunk: true, Children: []string{"bar"}, Ahead: 2},
+			{Name: "bar", Parent: "foo", Children: []string{}, Current: true, Ahead: 1},
+		}, branches, args)
+	}
+
+	// A new commit on the parent puts the child behind.
+	repo.CheckoutBranch(t, "refs/heads/foo")
+	repo.CommitFile(t, "foo3", "foo3")
+	var branches []branch
+	require.NoError(t, json.Unmarshal([]byte(RequireAv(t, "tree", "--json").Stdout), &branches))
+	require.Equal(t, 1, branches[1].Behind)
+
+	require.NotEqual(t, 0, Av(t, "--output=yaml", "tree").ExitCode)
+}
+
+func TestTreeAt(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> foo -> bar -> spam
+	RequireAv(t, "branch", "foo")
+	repo.CommitFile(t, "foo.txt", "foo")
+	RequireAv(t, "branch", "bar")
+	repo.CommitFile(t, "bar.txt", "bar")
+	RequireAv(t, "branch", "spam")
+	repo.CommitFile(t, "spam.txt", "spam")
+	spamCommit := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("spam"))
+
+	// main -> foo -> spam
+	RequireAv(t, "reparent", "--parent", "foo")
+	require.NotEqual(t, spamCommit, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("spam")))
+
+	// The tree before the reparent has spam on bar with its old commit.
+	out := RequireAv(t, "tree", "--at", "1")
+	require.Contains(t, out.Stderr, "The stacks before av reparent at")
+	require.Contains(t, out.Stdout, spamCommit.String()[:7])
+	require.Contains(t, out.Stdout, "bar")
+
+	// The branches didn't exist a day ago.
+	out = RequireAv(t, "tree", "--at", "1d")
+	require.Contains(t, out.Stderr, "No branches were tracked by av")
+
+	require.NotEqual(t, 0, Av(t, "tree", "--at", "2").ExitCode)
+	require.NotEqual(t, 0, Av(t, "tree", "--at", "someday").ExitCode)
+}
+
+func TestTreeFormat(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "feat/foo")
+	repo.CommitFile(t, "foo", "foo")
+	repo.CommitFile(t, "foo2", "foo2")
+	RequireAv(t, "branch", "bar")
+	repo.CommitFile(t, "bar", "bar")
+
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	br, _ := tx.Branch("feat/foo")
+	br.PullRequest = &meta.PullRequest{
+		ID: "nodeid-1", Number: 1, State: "MERGED", Permalink: "https://github.com/o/r/pull/1",
+	}
+	tx.SetBranch(br)
+	require.NoError(t, tx.Commit())
+
+	mermaid := RequireAv(t, "tree", "--format=mermaid", "--commit-counts").Stdout
+	require.Contains(t, mermaid, "graph LR\n  n0[\"main\"]\n")
+	require.Contains(t, mermaid, "  n1[\"feat/foo<br/>#1 (merged)")
+	require.Contains(t, mermaid, "  n0 -->|2 commits| n1\n")
+	require.Contains(t, mermaid, "  n1 -->|1 commit| n2\n")
+	require.Contains(t, mermaid, "  class n1 merged\n")
+	require.Contains(t, mermaid, "  class n2 current\n")
+	require.Contains(t, mermaid, "  click n1 \"https://github.com/o/r/pull/1\"\n")
+
+	dot := RequireAv(t, "tree", "--format=dot").Stdout
+	require.Contains(t, dot, "digraph stacks {\n")
+	require.Contains(t, dot, "  \"main\" -> \"feat/foo\";\n")
+	require.Contains(t, dot, "color=\"purple\"")
+
+	require.NotEqual(t, 0, Av(t, "tree", "--commit-counts").ExitCode)
+	require.NotEqual(t, 0, Av(t, "tree", "--format=svg").ExitCode)
+}
@@ -0,0 +1,42 @@
+package e2e_tests
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUndo(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+	Chdir(t, repo.RepoDir)
+
+	// main -> foo -> bar -> spam
+	RequireAv(t, "branch", "foo")
+	repo.CommitFile(t, "foo.txt", "foo")
+	RequireAv(t, "branch", "bar")
+	repo.CommitFile(t, "bar.txt", "bar")
+	RequireAv(t, "branch", "spam")
+	repo.CommitFile(t, "spam.txt", "spam")
+	spamCommit := repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("spam"))
+
+	require.NotEqual(t, 0, Av(t, "undo").ExitCode, "nothing to undo yet")
+
+	// main -> foo -> spam
+	RequireAv(t, "reparent", "--parent", "foo")
+	require.Equal(t, "foo", GetStoredParentBranchState(t, repo, "spam").Name)
+	require.NotEqual(t, spamCommit, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("spam")))
+	require.Contains(t, RequireAv(t, "undo", "--list").Stdout, "av reparent")
+
+	RequireAv(t, "undo")
+	require.Equal(t, "bar", GetStoredParentBranchState(t, repo, "spam").Name)
+	require.Equal(t, spamCommit, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("spam")))
+	require.Equal(t, plumbing.NewBranchReferenceName("spam"), repo.CurrentBranch(t))
+	requireFileContent(t, "bar.txt", "bar")
+
+	// The undone operation is removed from the log.
+	require.NotContains(t, RequireAv(t, "undo", "--list").Stdout, "av reparent")
+}
@@ -0,0 +1,57 @@
+package e2e_tests
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhich(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+	require.NoError(t, os.Mkdir(filepath.Join(repo.RepoDir, "dir"), 0o755))
+
+	repo.CommitFile(t, "dir/file.txt", "trunk 1\ntrunk 2\ntrunk 3\n", gittest.WithMessage("Trunk"))
+	repo.Git(t, "push", "origin", "main")
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "dir/file.txt", "one 1\ntrunk 2\ntrunk 3\n", gittest.WithMessage("Change one"))
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "dir/file.txt", "one 1\ntrunk 2\ntwo 3\ntwo 4\n", gittest.WithMessage("Change two"))
+
+	type result struct {
+		StartLine int    `json:"startLine"`
+		EndLine   int    `json:"endLine"`
+		Branch    string `json:"branch"`
+		Subject   string `json:"subject"`
+	}
+	which := func(args ...string) []result {
+		var results []result
+		out := RequireAv(t, append([]string{"which", "--json"}, args...)...)
+		require.NoError(t, json.Unmarshal([]byte(out.Stdout), &results))
+		return results
+	}
+
+	require.Equal(t, []result{
+		{StartLine: 1, EndLine: 1, Branch: "one", Subject: "Change one"},
+		{StartLine: 2, EndLine: 2, Branch: "", Subject: "Trunk"},
+		{StartLine: 3, EndLine: 4, Branch: "two", Subject: "Change two"},
+	}, which("dir/file.txt:1-10"))
+	require.Equal(t, []result{{Branch: "two", Subject: "Change two"}}, which("dir/file.txt"))
+
+	// Only the branches below --branch are considered, and the path is relative to the current
+	// directory.
+	Chdir(t, filepath.Join(repo.RepoDir, "dir"))
+	require.Equal(t, []result{{Branch: "one", Subject: "Change one"}}, which("--branch", "one", "file.txt"))
+
+	out := RequireAv(t, "which", "file.txt:3")
+	require.Contains(t, out.Stdout, "file.txt:3")
+	require.Contains(t, out.Stdout, "two")
+	require.True(t, strings.HasSuffix(strings.TrimSpace(out.Stdout), "Change two"))
+
+	require.NotEqual(t, 0, Av(t, "which", "file.txt:0").ExitCode)
+}
@@ -0,0 +1,46 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspace(t *testing.T) {
+	backend := gittest.NewTempRepo(t)
+	frontend := gittest.NewTempRepo(t)
+
+	Chdir(t, backend.RepoDir)
+	RequireAv(t, "branch", "backend-change")
+	backend.CommitFile(t, "api.txt", "api")
+	Chdir(t, frontend.RepoDir)
+	RequireAv(t, "branch", "frontend-change")
+	frontend.CreateFile(t, "ui.txt", "ui")
+
+	wsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(wsDir, "av-workspace.yml"),
+		[]byte("repos:\n  - "+backend.RepoDir+"\n  - "+frontend.RepoDir+"\n"),
+		0644,
+	))
+	Chdir(t, wsDir)
+
+	tree := RequireAv(t, "workspace", "tree")
+	require.Contains(t, tree.Stdout, "backend-change")
+	require.Contains(t, tree.Stdout, "frontend-change")
+
+	status := RequireAv(t, "workspace", "status")
+	require.Contains(t, status.Stdout, "backend-change")
+	require.Contains(t, status.Stdout, "frontend-change, 1 uncommitted change(s)")
+
+	// The workspace file is found in the parent directories.
+	require.NoError(t, os.Mkdir(filepath.Join(wsDir, "sub"), 0755))
+	Chdir(t, filepath.Join(wsDir, "sub"))
+	RequireAv(t, "workspace", "status")
+
+	Chdir(t, t.TempDir())
+	require.NotEqual(t, 0, Av(t, "workspace", "status").ExitCode)
+}
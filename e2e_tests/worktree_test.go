@@ -0,0 +1,128 @@
+package e2e_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkedWorktree(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	repo.Git(t, "checkout", "main")
+
+	// Create a linked worktree and create a stacked branch from there. The metadata must be
+	// written to the common git directory so that it's shared with the main worktree.
+	wtDir := filepath.Join(t.TempDir(), "wt")
+	repo.Git(t, "worktree", "add", wtDir, "one")
+	Chdir(t, wtDir)
+
+	RequireAv(t, "branch", "two")
+	Cmd(t, "git", "commit", "--allow-empty", "-m", "two")
+	RequireAv(t, "tree")
+
+	db := repo.OpenDB(t)
+	two, ok := db.ReadTx().Branch("two")
+	require.True(t, ok, "expected branch two to be adopted in the common av database")
+	require.Equal(t, "one", two.Parent.Name)
+
+	// Per-worktree HEADs should be respected.
+	Chdir(t, repo.RepoDir)
+	RequireCurrentBranchName(t, repo, "refs/heads/main")
+}
+
+func TestWorktreeAdd(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	Chdir(t, repo.RepoDir)
+
+	RequireAv(t, "branch", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	RequireAv(t, "branch", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	repo.Git(t, "checkout", "main")
+
+	// Only the stack branches.
+	require.NotEqual(t, 0, Av(t, "worktree", "add", "main").ExitCode)
+
+	wtDir := filepath.Join(t.TempDir(), "wt-one")
+	RequireAv(t, "worktree", "add", "one", wtDir)
+	require.FileExists(t, filepath.Join(wtDir, "one.txt"))
+	require.Equal(t, "one", strings.TrimSpace(Cmd(t, "git", "-C", wtDir, "branch", "--show-current").Stdout))
+
+	// The branch checked out in the other worktree cannot be checked out or restacked here.
+	out := Av(t, "switch", "one")
+	require.NotEqual(t, 0, out.ExitCode)
+	require.Contains(t, out.Stderr, "checked out in another worktree at")
+	require.NotEqual(t, 0, Av(t, "worktree", "add", "one").ExitCode)
+
+	// The metadata written in the other worktree is visible here.
+	Chdir(t, wtDir)
+	RequireAv(t, "branch", "three")
+	Chdir(t, repo.RepoDir)
+	three, ok := repo.OpenDB(t).ReadTx().Branch("three")
+	require.True(t, ok)
+	require.Equal(t, "one", three.Parent.Name)
+}
+
+func TestBareRepoWorktree(t *testing.T) {
+	server := RunMockGitHubServer(t)
+	defer server.Close()
+	repo := gittest.NewTempRepoWithGitHubServer(t, server.URL)
+
+	// Set up the `git clone --bare` + `git worktree add` layout: the common git directory is
+	// the bare repository, and there's no main worktree. The remote-tracking branches are
+	// configured as described in av-git-interaction(7).
+	bareDir := filepath.Join(t.TempDir(), "repo.git")
+	remoteURL := strings.TrimSpace(repo.Git(t, "remote", "get-url", "origin"))
+	Cmd(t, "git", "clone", "--bare", remoteURL, bareDir)
+	bare := &gittest.GitTestRepo{RepoDir: bareDir, GitDir: bareDir}
+	bare.Git(t, "config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*")
+	bare.Git(t, "fetch", "origin")
+	// The HEAD of the test remote doesn't point to main, so it's set explicitly instead of
+	// with --auto.
+	bare.Git(t, "remote", "set-head", "origin", "main")
+	for _, k := range []string{"user.name", "user.email"} {
+		bare.Git(t, "config", k, strings.TrimSpace(repo.Git(t, "config", k)))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(bareDir, "av"), 0755))
+	for _, name := range []string{"av.db", "config.yml"} {
+		data, err := os.ReadFile(filepath.Join(repo.GitDir, "av", name))
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(bareDir, "av", name), data, 0644))
+	}
+
+	wtDir := filepath.Join(t.TempDir(), "main")
+	bare.Git(t, "worktree", "add", wtDir, "main")
+	wt := &gittest.GitTestRepo{RepoDir: wtDir, GitDir: bareDir}
+	Chdir(t, wtDir)
+
+	RequireAv(t, "branch", "one")
+	wt.CreateFile(t, "one.txt", "one")
+	wt.AddFile(t, "one.txt")
+	wt.Git(t, "commit", "-m", "one")
+	require.Contains(t, RequireAv(t, "tree").Stdout, "one")
+
+	// The metadata is stored under the bare repository.
+	one, ok := wt.OpenDB(t).ReadTx().Branch("one")
+	require.True(t, ok, "expected branch one in the av database of the bare repository")
+	require.Equal(t, "main", one.Parent.Name)
+	require.NoFileExists(t, filepath.Join(wtDir, ".git", "av", "av.db"))
+
+	// Update the trunk on the remote and sync the stack onto it.
+	repo.CommitFile(t, "main.txt", "main")
+	repo.Git(t, "push", "origin", "main")
+	RequireAv(t, "sync", "--rebase-to-trunk", "--push=no", "--prune=no")
+	require.Equal(
+		t,
+		strings.TrimSpace(wt.Git(t, "rev-parse", "origin/main")),
+		strings.TrimSpace(wt.Git(t, "rev-parse", "one^")),
+	)
+	require.FileExists(t, filepath.Join(wtDir, "main.txt"))
+}
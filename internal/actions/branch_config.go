@@ -0,0 +1,24 @@
+package actions
+
+import (
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+)
+
+// ConfigureNewBranch sets the Git configs of a branch that av just created: the upstream branch
+// if the upstreamTracking config is "create", and the push remote if the branchPushRemote
+// config is set.
+func ConfigureNewBranch(repo *git.Repo, name string) error {
+	if config.Av.UpstreamTracking == config.UpstreamTrackingCreate {
+		if err := repo.BranchSetUpstream(name, repo.GetRemoteName()); err != nil {
+			return errors.WrapIff(err, "failed to set the upstream branch of %q", name)
+		}
+	}
+	if config.Av.BranchPushRemote != "" {
+		if err := repo.BranchSetPushRemote(name, config.Av.BranchPushRemote); err != nil {
+			return errors.WrapIff(err, "failed to set the push remote of %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package actions
+
+import (
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// An issue key such as "ABC-123" (Jira and Linear style).
+var ticketPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-[0-9]+\b`)
+
+// BranchNameData is the data for the placeholders of config.PullRequest.BranchNameTemplate.
+type BranchNameData struct {
+	// {user}: the user name (the local part of Git user.email).
+	User string
+	// {ticket}: the issue key found in the description (e.g., "ABC-123").
+	Ticket string
+	// {slug}: the rest of the description in kebab case (e.g., "fix-login-race").
+	Slug string
+}
+
+// SplitTicket splits a free-form branch description into the first issue key in it (e.g.,
+// "ABC-123") and the rest of the description.
+func SplitTicket(description string) (ticket string, rest string) {
+	loc := ticketPattern.FindStringIndex(description)
+	if loc == nil {
+		return "", description
+	}
+	ticket = description[loc[0]:loc[1]]
+	rest = description[:loc[0]] + " " + description[loc[1]:]
+	return ticket, strings.TrimSpace(rest)
+}
+
+// BranchNameFromTemplate fills the placeholders of the branch name template (e.g.,
+// "{user}/{ticket}/{slug}"). The path components that end up empty (e.g., without a ticket)
+// are dropped, and so are the dashes and underscores left at the ends of the components.
+func BranchNameFromTemplate(template string, data BranchNameData) string {
+	name := strings.NewReplacer(
+		"{user}", data.User,
+		"{ticket}", data.Ticket,
+		"{slug}", data.Slug,
+	).Replace(template)
+	var components []string
+	for _, c := range strings.Split(name, "/") {
+		if c = strings.Trim(c, "-_"); c != "" {
+			components = append(components, c)
+		}
+	}
+	return strings.Join(components, "/")
+}
+
+// CheckBranchNamePattern returns an error if the pattern is set and the branch name doesn't
+// match it.
+func CheckBranchNamePattern(pattern string, branchName string) error {
+	if pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errors.WrapIf(err, "invalid pullRequest.branchNamePattern config")
+	}
+	if !re.MatchString(branchName) {
+		return errors.Errorf(
+			"branch name %q doesn't match the branch naming pattern %q (use --force to use it anyway)",
+			branchName, pattern,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitTicket(t *testing.T) {
+	ticket, rest := actions.SplitTicket("ABC-123 Fix login race")
+	assert.Equal(t, "ABC-123", ticket)
+	assert.Equal(t, "Fix login race", rest)
+
+	ticket, rest = actions.SplitTicket("Fix login race PROJ2-7")
+	assert.Equal(t, "PROJ2-7", ticket)
+	assert.Equal(t, "Fix login race", rest)
+
+	ticket, rest = actions.SplitTicket("Fix the top-10 list")
+	assert.Equal(t, "", ticket)
+	assert.Equal(t, "Fix the top-10 list", rest)
+}
+
+func TestBranchNameFromTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		template string
+		data     actions.BranchNameData
+		want     string
+	}{
+		{
+			"{user}/{ticket}/{slug}",
+			actions.BranchNameData{User: "sam", Ticket: "ABC-123", Slug: "fix-login-race"},
+			"sam/ABC-123/fix-login-race",
+		},
+		{
+			"{user}/{ticket}/{slug}",
+			actions.BranchNameData{User: "sam", Slug: "fix-login-race"},
+			"sam/fix-login-race",
+		},
+		{
+			"{user}/{ticket}-{slug}",
+			actions.BranchNameData{User: "sam", Slug: "fix-login-race"},
+			"sam/fix-login-race",
+		},
+		{
+			"feature/{slug}",
+			actions.BranchNameData{User: "sam", Ticket: "ABC-123", Slug: "fix"},
+			"feature/fix",
+		},
+	} {
+		assert.Equal(t, tt.want, actions.BranchNameFromTemplate(tt.template, tt.data), tt.template)
+	}
+}
+
+func TestCheckBranchNamePattern(t *testing.T) {
+	assert.NoError(t, actions.CheckBranchNamePattern("", "anything"))
+	assert.NoError(t, actions.CheckBranchNamePattern(`^[a-z]+/`, "sam/fix"))
+	assert.Error(t, actions.CheckBranchNamePattern(`^[a-z]+/`, "fix"))
+}
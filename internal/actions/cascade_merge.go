@@ -0,0 +1,144 @@
+package actions
+
+import (
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+)
+
+// CascadeMergeStep is the result of merging a branch in CascadeMergeLocal.
+type CascadeMergeStep struct {
+	Branch string
+	// The number of the commits of the branch (excluding the commits of its parent).
+	Commits int
+	// The commit of the integration branch after the branch is merged. Empty if merging the
+	// branch conflicts.
+	Commit string
+	// The files that conflict when the branch is merged, if any.
+	ConflictedFiles []string
+}
+
+// CascadeMergeLocal simulates merging the pull requests of the branches one by one in the given
+// order (bottom-up) with the merge method ("merge", "squash", or "rebase"), starting from the
+// trunk commit onto. Each branch is merged into the result of the previous branches, as its pull
+// request would be merged into the trunk after its parents. The merged commits are created with
+// git merge-tree without touching the working tree or any ref.
+//
+// The merge stops at the first branch that conflicts, which is the last step returned. The
+// integration commit is the Commit of the last step otherwise.
+func CascadeMergeLocal(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	onto string,
+	branches []string,
+	method string,
+) ([]CascadeMergeStep, error) {
+	var steps []CascadeMergeStep
+	integration := onto
+	for _, name := range branches {
+		branch, ok := tx.Branch(name)
+		if !ok {
+			return nil, errors.Errorf("branch metadata not found for %q", name)
+		}
+		head, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + name})
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to read branch %q", name)
+		}
+		// The changes of the branch are the ones since its parent. Once the parent is merged,
+		// only these are merged from the pull request of the branch.
+		parent := "refs/heads/" + branch.Parent.Name
+		if branch.Parent.Trunk {
+			parent = onto
+		}
+		base, err := repo.MergeBase(parent, head)
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to find the base of branch %q", name)
+		}
+		commits, err := repo.RevList(git.RevListOpts{
+			Specifiers: []string{base + ".." + head},
+			Reverse:    true,
+			NoMerges:   true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		step := CascadeMergeStep{Branch: name, Commits: len(commits)}
+		if len(commits) > 0 {
+			if method == config.MergeMethodRebase {
+				integration, step.ConflictedFiles, err = cascadeRebase(repo, integration, commits)
+			} else {
+				integration, step.ConflictedFiles, err = cascadeMerge(
+					repo, integration, base, head, name, method,
+				)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(step.ConflictedFiles) > 0 {
+			return append(steps, step), nil
+		}
+		step.Commit = integration
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// cascadeRebase applies the commits onto the integration commit one by one, as a pull request
+// merged with the rebase method.
+func cascadeRebase(
+	repo *git.Repo,
+	integration string,
+	commits []string,
+) (string, []string, error) {
+	for _, commit := range commits {
+		merged, err := repo.MergeTree(commit+"^", integration, commit)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(merged.ConflictedFiles) > 0 {
+			return "", merged.ConflictedFiles, nil
+		}
+		message, err := repo.Git("log", "-1", "--format=%B", commit)
+		if err != nil {
+			return "", nil, err
+		}
+		integration, err = repo.CommitTree(merged.Tree, message, integration)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return integration, nil, nil
+}
+
+// cascadeMerge applies the changes of the branch since the base onto the integration commit in
+// one commit, as a pull request merged with the squash or the merge method. With the merge
+// method, the head of the branch is the second parent of the commit.
+func cascadeMerge(
+	repo *git.Repo,
+	integration string,
+	base string,
+	head string,
+	name string,
+	method string,
+) (string, []string, error) {
+	merged, err := repo.MergeTree(base, integration, head)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(merged.ConflictedFiles) > 0 {
+		return "", merged.ConflictedFiles, nil
+	}
+	parents := []string{integration}
+	message := "Squash-merge branch '" + name + "'"
+	if method == config.MergeMethodMerge {
+		parents = append(parents, head)
+		message = "Merge branch '" + name + "'"
+	}
+	commit, err := repo.CommitTree(merged.Tree, message, parents...)
+	if err != nil {
+		return "", nil, err
+	}
+	return commit, nil, nil
+}
@@ -0,0 +1,100 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+)
+
+// ReducedCI returns true if the branch is deep enough in the stack for the CI pipelines to
+// run a reduced test suite (see config.PullRequestCIHints).
+func ReducedCI(tx meta.ReadTx, branchName string) bool {
+	hints := config.Av.PullRequest.CIHints
+	if hints.ReducedAfterDepth == 0 {
+		return false
+	}
+	depth, err := BranchDepth(tx, branchName)
+	if err != nil {
+		return false
+	}
+	return depth > hints.ReducedAfterDepth
+}
+
+func ciHintMarker(marker string) bool {
+	return slices.Contains(config.Av.PullRequest.CIHints.Markers, marker)
+}
+
+// CIHintPushOptions returns the git push arguments that mark the branch for the reduced CI, if
+// any.
+func CIHintPushOptions(tx meta.ReadTx, branchName string) []string {
+	hints := config.Av.PullRequest.CIHints
+	if hints.PushOption == "" || !ciHintMarker(config.CIHintMarkerPushOption) ||
+		!ReducedCI(tx, branchName) {
+		return nil
+	}
+	return []string{"--push-option=" + hints.PushOption}
+}
+
+// CIHintCommitArgs returns the git commit arguments that add the reduced CI trailer to the
+// commits of the branch, if any.
+func CIHintCommitArgs(tx meta.ReadTx, branchName string) []string {
+	hints := config.Av.PullRequest.CIHints
+	if hints.Trailer == "" || !ciHintMarker(config.CIHintMarkerTrailer) ||
+		!ReducedCI(tx, branchName) {
+		return nil
+	}
+	return []string{"--trailer", hints.Trailer}
+}
+
+// SyncCIHintLabel adds the reduced CI label to the pull request of a deep branch, and removes
+// it once the branch is no longer deep (e.g., after its parents are merged).
+func SyncCIHintLabel(
+	ctx context.Context,
+	client *gh.Client,
+	tx meta.ReadTx,
+	branchName string,
+	prID string,
+	out io.Writer,
+) error {
+	hints := config.Av.PullRequest.CIHints
+	if hints.ReducedAfterDepth == 0 || hints.Label == "" ||
+		!ciHintMarker(config.CIHintMarkerLabel) {
+		return nil
+	}
+	current, err := client.PullRequestTriage(ctx, prID)
+	if err != nil {
+		return err
+	}
+	reduced := ReducedCI(tx, branchName)
+	if reduced == containsFold(current.Labels, hints.Label) {
+		return nil
+	}
+	repository := tx.Repository()
+	label, err := client.RepositoryLabel(ctx, repository.Owner, repository.Name, hints.Label)
+	if err != nil {
+		return err
+	}
+	if reduced {
+		_, _ = fmt.Fprint(out,
+			"  - adding label ", colors.UserInput(hints.Label), " for the reduced CI\n",
+		)
+		return client.AddLabels(ctx, githubv4.AddLabelsToLabelableInput{
+			LabelableID: prID,
+			LabelIDs:    []githubv4.ID{label.ID},
+		})
+	}
+	_, _ = fmt.Fprint(out,
+		"  - removing label ", colors.UserInput(hints.Label), " for the reduced CI\n",
+	)
+	return client.RemoveLabels(ctx, githubv4.RemoveLabelsFromLabelableInput{
+		LabelableID: prID,
+		LabelIDs:    []githubv4.ID{label.ID},
+	})
+}
@@ -0,0 +1,50 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIHints(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	// main -> one -> two -> three
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one"}})
+	tx.SetBranch(meta.Branch{Name: "three", Parent: meta.BranchState{Name: "two"}})
+	require.NoError(t, tx.Commit())
+
+	orig := config.Av.PullRequest.CIHints
+	t.Cleanup(func() { config.Av.PullRequest.CIHints = orig })
+
+	rtx := db.ReadTx()
+	require.False(t, actions.ReducedCI(rtx, "three"), "disabled by default")
+	require.Nil(t, actions.CIHintPushOptions(rtx, "three"))
+
+	config.Av.PullRequest.CIHints.ReducedAfterDepth = 2
+	require.False(t, actions.ReducedCI(rtx, "one"))
+	require.False(t, actions.ReducedCI(rtx, "two"))
+	require.True(t, actions.ReducedCI(rtx, "three"))
+	require.Equal(
+		t,
+		[]string{"--push-option=ci.reduced"},
+		actions.CIHintPushOptions(rtx, "three"),
+	)
+	require.Nil(t, actions.CIHintPushOptions(rtx, "two"))
+	// Only the push option is enabled by default.
+	require.Nil(t, actions.CIHintCommitArgs(rtx, "three"))
+
+	config.Av.PullRequest.CIHints.Markers = []string{config.CIHintMarkerTrailer}
+	require.Nil(t, actions.CIHintPushOptions(rtx, "three"))
+	require.Equal(
+		t,
+		[]string{"--trailer", "Av-CI: reduced"},
+		actions.CIHintCommitArgs(rtx, "three"),
+	)
+}
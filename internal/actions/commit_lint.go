@@ -0,0 +1,77 @@
+package actions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aviator-co/av/internal/config"
+)
+
+// conventionalCommitPattern matches the subject of a Conventional Commits message (e.g.,
+// "feat(api)!: add refunds").
+var conventionalCommitPattern = regexp.MustCompile(
+	`^(build|chore|ci|docs|feat|fix|perf|refactor|revert|style|test)(\([^()\s]+\))?!?: \S`,
+)
+
+// The subjects that Git generates, which are not checked.
+var generatedSubjectPrefixes = []string{"fixup! ", "squash! ", "amend! ", "Merge ", "Revert \""}
+
+// LintCommitMessage returns the reasons why the commit message breaks the rules, or nil if it
+// follows them.
+func LintCommitMessage(message string, rules config.CommitMessageRules) []string {
+	subject, body, hasBody := strings.Cut(strings.TrimSpace(message), "\n")
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return []string{"the subject is empty"}
+	}
+	for _, prefix := range generatedSubjectPrefixes {
+		if strings.HasPrefix(subject, prefix) {
+			return nil
+		}
+	}
+
+	var problems []string
+	if rules.Preset == config.CommitMessagePresetConventional {
+		if !conventionalCommitPattern.MatchString(subject) {
+			problems = append(problems,
+				`the subject doesn't follow Conventional Commits (e.g., "feat(api): add refunds")`,
+			)
+		}
+		if hasBody && strings.TrimSpace(strings.SplitN(body, "\n", 2)[0]) != "" {
+			problems = append(problems, "the subject must be followed by a blank line")
+		}
+	}
+	for _, pattern := range rules.SubjectPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid subject pattern %q", pattern))
+			continue
+		}
+		if !re.MatchString(subject) {
+			problems = append(problems, fmt.Sprintf("the subject doesn't match %q", pattern))
+		}
+	}
+	if n := len([]rune(subject)); rules.MaxSubjectLength > 0 && n > rules.MaxSubjectLength {
+		problems = append(problems, fmt.Sprintf(
+			"the subject is %d characters long (at most %d)", n, rules.MaxSubjectLength,
+		))
+	}
+	return problems
+}
+
+// CommitMessageRulesHelp returns the descriptions of the rules, one per line, to show in the
+// editor.
+func CommitMessageRulesHelp(rules config.CommitMessageRules) []string {
+	var ret []string
+	if rules.Preset == config.CommitMessagePresetConventional {
+		ret = append(ret, `The subject must follow Conventional Commits (e.g., "feat(api): add refunds").`)
+	}
+	for _, pattern := range rules.SubjectPatterns {
+		ret = append(ret, fmt.Sprintf("The subject must match %q.", pattern))
+	}
+	if rules.MaxSubjectLength > 0 {
+		ret = append(ret, fmt.Sprintf("The subject must be at most %d characters long.", rules.MaxSubjectLength))
+	}
+	return ret
+}
@@ -0,0 +1,48 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintCommitMessage(t *testing.T) {
+	conventional := config.CommitMessageRules{Preset: config.CommitMessagePresetConventional}
+	for _, tt := range []struct {
+		name    string
+		rules   config.CommitMessageRules
+		message string
+		want    []string
+	}{
+		{"conventional", conventional, "feat(api): add refunds", nil},
+		{"conventional breaking", conventional, "fix!: drop v1\n\nBREAKING CHANGE: v1 is gone", nil},
+		{
+			"not conventional", conventional, "Add refunds",
+			[]string{`the subject doesn't follow Conventional Commits (e.g., "feat(api): add refunds")`},
+		},
+		{
+			"no blank line", conventional, "feat: add refunds\nmore",
+			[]string{"the subject must be followed by a blank line"},
+		},
+		{"fixup", conventional, "fixup! feat: add refunds", nil},
+		{"empty", conventional, "\n\n", []string{"the subject is empty"}},
+		{
+			"pattern", config.CommitMessageRules{SubjectPatterns: []string{`^[A-Z]+-[0-9]+ `}},
+			"Add refunds", []string{`the subject doesn't match "^[A-Z]+-[0-9]+ "`},
+		},
+		{
+			"pattern matches", config.CommitMessageRules{SubjectPatterns: []string{`^[A-Z]+-[0-9]+ `}},
+			"PAY-12 Add refunds", nil,
+		},
+		{
+			"too long", config.CommitMessageRules{MaxSubjectLength: 10},
+			"Add refunds to payments", []string{"the subject is 23 characters long (at most 10)"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, actions.LintCommitMessage(tt.message, tt.rules))
+		})
+	}
+}
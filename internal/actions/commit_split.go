@@ -0,0 +1,67 @@
+package actions
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aviator-co/av/internal/config"
+)
+
+// RootPathGroupName is the name of the path group of the files at the repository root.
+const RootPathGroupName = "root"
+
+// PathGroup is a set of changed files that are committed together by av commit --split-by-dir.
+type PathGroup struct {
+	Name  string
+	Paths []string
+}
+
+// GroupChangedPaths groups the changed files by the configured path groups. The first group
+// that matches a file is used. The files that don't match any group are grouped by their
+// top-level directory, and the files at the repository root are grouped as
+// RootPathGroupName.
+//
+// The configured groups are returned in the configured order followed by the directory groups
+// in alphabetical order. Groups without changes are omitted.
+func GroupChangedPaths(files []string, groups []config.CommitPathGroup) []PathGroup {
+	paths := map[string][]string{}
+	var dirNames []string
+	for _, file := range files {
+		name, ok := matchPathGroup(file, groups)
+		if !ok {
+			name = RootPathGroupName
+			if dir, _, found := strings.Cut(file, "/"); found {
+				name = dir
+			}
+			if _, seen := paths[name]; !seen {
+				dirNames = append(dirNames, name)
+			}
+		}
+		paths[name] = append(paths[name], file)
+	}
+
+	var ret []PathGroup
+	for _, g := range groups {
+		if ps, ok := paths[g.Name]; ok {
+			ret = append(ret, PathGroup{Name: g.Name, Paths: ps})
+			// A directory that has the same name as a configured group is merged into it.
+			delete(paths, g.Name)
+		}
+	}
+	sort.Strings(dirNames)
+	for _, name := range dirNames {
+		if ps, ok := paths[name]; ok {
+			ret = append(ret, PathGroup{Name: name, Paths: ps})
+		}
+	}
+	return ret
+}
+
+func matchPathGroup(file string, groups []config.CommitPathGroup) (string, bool) {
+	for _, g := range groups {
+		if g.Match(file) {
+			return g.Name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,40 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupChangedPaths(t *testing.T) {
+	groups := []config.CommitPathGroup{
+		{Name: "api", Paths: []string{"services/api", "proto/api*.proto"}},
+		{Name: "web", Paths: []string{"services/web/"}},
+		{Name: "docs", Paths: []string{"*.md"}},
+	}
+	files := []string{
+		"README.md",
+		"go.mod",
+		"lib/util.go",
+		"proto/api_v1.proto",
+		"proto/web.proto",
+		"services/api/main.go",
+		"services/api/handler/user.go",
+		"services/web/index.ts",
+		"services/worker/main.go",
+		"docs/guide.txt",
+	}
+	require.Equal(t, []actions.PathGroup{
+		{Name: "api", Paths: []string{"proto/api_v1.proto", "services/api/main.go", "services/api/handler/user.go"}},
+		{Name: "web", Paths: []string{"services/web/index.ts"}},
+		{Name: "docs", Paths: []string{"README.md", "docs/guide.txt"}},
+		{Name: "lib", Paths: []string{"lib/util.go"}},
+		{Name: "proto", Paths: []string{"proto/web.proto"}},
+		{Name: "root", Paths: []string{"go.mod"}},
+		{Name: "services", Paths: []string{"services/worker/main.go"}},
+	}, actions.GroupChangedPaths(files, groups))
+
+	require.Empty(t, actions.GroupChangedPaths(nil, groups))
+}
@@ -0,0 +1,92 @@
+package actions
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aviator-co/av/internal/config"
+)
+
+// CommitTemplateData is the data for the placeholders of config.Commit.MessageTemplate.
+type CommitTemplateData struct {
+	// {groups}: the path groups of the changed files (see GroupChangedPaths).
+	Groups []string
+	// {packages}: the names of the directories that have changed files (e.g., "payments" for
+	// services/payments/handler.go), in alphabetical order. The files at the repository root
+	// are not included.
+	Packages []string
+	// {message}: the message given with -m.
+	Message string
+	// {branch}: the branch that the commit is created on.
+	Branch string
+	// {parent}: the parent branch of the branch.
+	Parent string
+	// {ticket}: the ticket ID in the branch name (see TicketFromBranchName).
+	Ticket string
+}
+
+// NewCommitTemplateData computes the placeholders of the commit message template from the
+// changed files.
+func NewCommitTemplateData(
+	files []string,
+	groups []config.CommitPathGroup,
+	message string,
+) CommitTemplateData {
+	data := CommitTemplateData{Message: message}
+	for _, g := range GroupChangedPaths(files, groups) {
+		data.Groups = append(data.Groups, g.Name)
+	}
+	seen := map[string]bool{}
+	for _, file := range files {
+		dir := path.Dir(file)
+		if dir == "." {
+			continue
+		}
+		if name := path.Base(dir); !seen[name] {
+			seen[name] = true
+			data.Packages = append(data.Packages, name)
+		}
+	}
+	sort.Strings(data.Packages)
+	return data
+}
+
+// CommitMessageFromTemplate fills the placeholders of the commit message template (e.g.,
+// "{groups}: {message}"). The lists are joined with commas. If the template doesn't have the
+// {message} placeholder, the message is appended to it. The brackets and the parentheses left
+// empty by the placeholders (e.g., "feat({packages}): ") and the separators left at the start
+// are dropped.
+func CommitMessageFromTemplate(template string, data CommitTemplateData) string {
+	if !strings.Contains(template, "{message}") {
+		template += "{message}"
+	}
+	msg := strings.NewReplacer(
+		"{groups}", strings.Join(data.Groups, ","),
+		"{packages}", strings.Join(data.Packages, ","),
+		"{branch}", data.Branch,
+		"{parent}", data.Parent,
+		"{ticket}", data.Ticket,
+	).Replace(template)
+	// The message itself is kept as it is.
+	prefix, suffix, _ := strings.Cut(msg, "{message}")
+	emptyBrackets := strings.NewReplacer("[] ", "", "[]", "", "()", "")
+	prefix = strings.TrimLeft(emptyBrackets.Replace(prefix), " ,:")
+	return prefix + data.Message + emptyBrackets.Replace(suffix)
+}
+
+// TicketFromBranchName returns the ticket ID in the branch name (e.g., "ABC-123" for
+// "alice/ABC-123-fix-login"), found with pullRequest.title.ticketPattern or as an issue key.
+// It returns an empty string if the branch name doesn't have one.
+func TicketFromBranchName(branchName string) string {
+	pattern := ticketPattern
+	if config.Av.PullRequest.Title.TicketPattern != "" {
+		re, err := regexp.Compile(config.Av.PullRequest.Title.TicketPattern)
+		if err != nil {
+			return ""
+		}
+		pattern = re
+	}
+	return pattern.FindString(branchName)
+}
@@ -0,0 +1,67 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCommitTemplateData(t *testing.T) {
+	data := actions.NewCommitTemplateData(
+		[]string{
+			"services/payments/handler.go",
+			"services/payments/handler_test.go",
+			"services/api/server.go",
+			"README.md",
+		},
+		[]config.CommitPathGroup{{Name: "api", Paths: []string{"services/api"}}},
+		"Add refunds",
+	)
+	assert.Equal(t, []string{"api", "root", "services"}, data.Groups)
+	assert.Equal(t, []string{"api", "payments"}, data.Packages)
+	assert.Equal(t, "Add refunds", data.Message)
+}
+
+func TestCommitMessageFromTemplate(t *testing.T) {
+	data := actions.CommitTemplateData{
+		Groups:   []string{"payments", "api"},
+		Packages: []string{"handler"},
+		Message:  "Add refunds",
+	}
+	for _, tt := range []struct {
+		template string
+		data     actions.CommitTemplateData
+		want     string
+	}{
+		{"{groups}: {message}", data, "payments,api: Add refunds"},
+		{"{packages}: ", data, "handler: Add refunds"},
+		{"{groups}: ", actions.CommitTemplateData{Groups: []string{"api"}}, "api: "},
+		{"{packages}: ", actions.CommitTemplateData{Message: "Fix typo"}, "Fix typo"},
+		{"{message}\n\nPackages: {packages}", data, "Add refunds\n\nPackages: handler"},
+		{
+			"[{ticket}] {message}",
+			actions.CommitTemplateData{Ticket: "PAY-12", Message: "Add refunds"},
+			"[PAY-12] Add refunds",
+		},
+		{"[{ticket}] {message}", actions.CommitTemplateData{Message: "Call f() twice"}, "Call f() twice"},
+		{"feat({packages}): ", actions.CommitTemplateData{Message: "add refunds"}, "feat: add refunds"},
+		{
+			"{message}\n\nBranch: {branch} (on {parent})",
+			actions.CommitTemplateData{Branch: "one", Parent: "main", Message: "Add refunds"},
+			"Add refunds\n\nBranch: one (on main)",
+		},
+	} {
+		assert.Equal(t, tt.want, actions.CommitMessageFromTemplate(tt.template, tt.data), tt.template)
+	}
+}
+
+func TestTicketFromBranchName(t *testing.T) {
+	assert.Equal(t, "PAY-12", actions.TicketFromBranchName("alice/PAY-12-add-refunds"))
+	assert.Equal(t, "", actions.TicketFromBranchName("alice/add-refunds"))
+
+	config.Av.PullRequest.Title.TicketPattern = "[0-9]+"
+	defer func() { config.Av.PullRequest.Title.TicketPattern = "" }()
+	assert.Equal(t, "123", actions.TicketFromBranchName("alice/123-add-refunds"))
+}
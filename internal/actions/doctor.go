@@ -0,0 +1,327 @@
+package actions
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/shurcooL/githubv4"
+	"golang.org/x/exp/maps"
+)
+
+// DoctorIssueKind is the kind of inconsistency between the av metadata and the repository.
+type DoctorIssueKind string
+
+const (
+	// The branch is tracked by av but doesn't exist in the repository.
+	DoctorBranchMissing DoctorIssueKind = "branch-missing"
+	// The branch was renamed with Git (e.g., git branch -m), so the metadata has the old name.
+	DoctorBranchRenamed DoctorIssueKind = "branch-renamed"
+	// The parent of the branch is neither a trunk branch nor tracked by av.
+	DoctorParentMissing DoctorIssueKind = "parent-missing"
+	// The parents of the branch form a cycle.
+	DoctorParentCycle DoctorIssueKind = "parent-cycle"
+	// The trunk flag of the parent doesn't match whether the parent is a trunk branch.
+	DoctorTrunkMismatch DoctorIssueKind = "trunk-mismatch"
+	// The recorded head of the parent is not in the history of the branch.
+	DoctorStaleParentHead DoctorIssueKind = "stale-parent-head"
+	// The branch has an open pull request, but it's not pushed to the remote.
+	DoctorRemoteBranchMissing DoctorIssueKind = "remote-branch-missing"
+)
+
+// DoctorIssue is an inconsistency found by Diagnose.
+type DoctorIssue struct {
+	Kind   DoctorIssueKind
+	Branch string
+	// The description of the inconsistency.
+	Message string
+	// The description of the repair made by FixDoctorIssue. Empty if the issue can't be
+	// repaired automatically.
+	Fix string
+
+	// The new name of the branch (DoctorBranchRenamed), the new parent (DoctorParentMissing
+	// and DoctorParentCycle), or the new parent head (DoctorStaleParentHead).
+	value string
+}
+
+// Diagnose cross-checks the av metadata against the branches in the repository and the remote
+// tracking branches, and returns the inconsistencies (e.g., after the branches are deleted or
+// renamed with plain Git commands). The issues are sorted by branch name.
+func Diagnose(repo *git.Repo, tx meta.ReadTx) ([]DoctorIssue, error) {
+	refs, err := repo.ListRefs(&git.ListRefs{Patterns: []string{"refs/heads"}})
+	if err != nil {
+		return nil, err
+	}
+	heads := map[string]string{}
+	for _, ref := range refs {
+		heads[strings.TrimPrefix(ref.Name, "refs/heads/")] = ref.Oid
+	}
+	trunks, err := repo.TrunkBranches()
+	if err != nil {
+		return nil, err
+	}
+	defaultBranch, err := repo.DefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+	branches := tx.AllBranches()
+	names := maps.Keys(branches)
+	slices.Sort(names)
+
+	var missing []string
+	for _, name := range names {
+		if _, ok := heads[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	renames := map[string]string{}
+	if len(missing) > 0 {
+		renames, err = gitBranchRenames(repo, heads, branches, trunks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var issues []DoctorIssue
+	for _, name := range names {
+		br := branches[name]
+		if _, ok := heads[name]; !ok {
+			if newName, ok := renames[name]; ok {
+				issues = append(issues, DoctorIssue{
+					Kind:    DoctorBranchRenamed,
+					Branch:  name,
+					Message: fmt.Sprintf("branch %q was renamed to %q with Git", name, newName),
+					Fix:     fmt.Sprintf("rename the branch to %q in the av metadata", newName),
+					value:   newName,
+				})
+				continue
+			}
+			issues = append(issues, DoctorIssue{
+				Kind:    DoctorBranchMissing,
+				Branch:  name,
+				Message: fmt.Sprintf("branch %q is tracked by av but doesn't exist", name),
+				Fix: fmt.Sprintf(
+					"stop tracking the branch and move its children onto %q", br.Parent.Name,
+				),
+			})
+			continue
+		}
+
+		parent := br.Parent
+		isTrunk := slices.Contains(trunks, parent.Name)
+		_, parentTracked := branches[parent.Name]
+		switch {
+		case parent.Trunk && !isTrunk && parentTracked:
+			issues = append(issues, DoctorIssue{
+				Kind:   DoctorTrunkMismatch,
+				Branch: name,
+				Message: fmt.Sprintf(
+					"parent %q of branch %q is marked as a trunk branch but it isn't",
+					parent.Name, name,
+				),
+				Fix: "unmark the parent as a trunk branch",
+			})
+			continue
+		case !parent.Trunk && isTrunk:
+			issues = append(issues, DoctorIssue{
+				Kind:   DoctorTrunkMismatch,
+				Branch: name,
+				Message: fmt.Sprintf(
+					"parent %q of branch %q is a trunk branch but it isn't marked as one",
+					parent.Name, name,
+				),
+				Fix: "mark the parent as a trunk branch",
+			})
+			continue
+		case parent.Trunk && !isTrunk, !parent.Trunk && !parentTracked:
+			if _, ok := renames[parent.Name]; ok {
+				// Fixed by renaming the parent.
+				continue
+			}
+			issues = append(issues, DoctorIssue{
+				Kind:   DoctorParentMissing,
+				Branch: name,
+				Message: fmt.Sprintf(
+					"parent %q of branch %q is neither a trunk branch nor tracked by av",
+					parent.Name, name,
+				),
+				Fix:   fmt.Sprintf("move the branch onto %q", defaultBranch),
+				value: defaultBranch,
+			})
+			continue
+		}
+		if parent.Trunk {
+			continue
+		}
+		if cycle := parentCycle(branches, name); cycle != nil {
+			// Report the cycle once, on its first branch by name.
+			if slices.Min(cycle) == name {
+				issues = append(issues, DoctorIssue{
+					Kind:   DoctorParentCycle,
+					Branch: name,
+					Message: fmt.Sprintf(
+						"the parents of branch %q form a cycle (%s)",
+						name, strings.Join(cycle, " -> "),
+					),
+					Fix:   fmt.Sprintf("move the branch onto %q", defaultBranch),
+					value: defaultBranch,
+				})
+			}
+			continue
+		}
+		if _, ok := heads[parent.Name]; !ok || parent.Head == "" {
+			// A missing parent is reported on its own.
+			continue
+		}
+		if ok, err := repo.IsAncestor(parent.Head, name); err == nil && ok {
+			continue
+		}
+		mergeBase, err := repo.MergeBase(parent.Name, name)
+		if err != nil {
+			continue
+		}
+		issues = append(issues, DoctorIssue{
+			Kind:   DoctorStaleParentHead,
+			Branch: name,
+			Message: fmt.Sprintf(
+				"the recorded head of parent %q (%s) is not in the history of branch %q",
+				parent.Name, shortHash(parent.Head), name,
+			),
+			Fix:   fmt.Sprintf("set the parent head to the merge base %s", shortHash(mergeBase)),
+			value: mergeBase,
+		})
+	}
+
+	for _, name := range names {
+		br := branches[name]
+		if _, ok := heads[name]; !ok || br.MergeCommit != "" || br.PullRequest == nil ||
+			br.PullRequest.State != githubv4.PullRequestStateOpen {
+			continue
+		}
+		remote := repo.GetBranchPushRemoteName(name)
+		if exists, _ := repo.DoesRefExist(git.RemoteTrackingRef(remote, name)); exists {
+			continue
+		}
+		issues = append(issues, DoctorIssue{
+			Kind:   DoctorRemoteBranchMissing,
+			Branch: name,
+			Message: fmt.Sprintf(
+				"branch %q has open pull request #%d, but it's not pushed to %s "+
+					"(run 'av sync' to push it or to refresh the pull request state)",
+				name, br.PullRequest.Number, remote,
+			),
+		})
+	}
+	slices.SortStableFunc(issues, func(a, b DoctorIssue) int {
+		return strings.Compare(a.Branch, b.Branch)
+	})
+	return issues, nil
+}
+
+// FixDoctorIssue repairs the av metadata for the issue found by Diagnose. The issues are fixed
+// against the current metadata in the transaction, so the fixes of multiple issues compose.
+func FixDoctorIssue(tx meta.WriteTx, issue DoctorIssue) error {
+	br, exists := tx.Branch(issue.Branch)
+	if !exists {
+		return errors.Errorf("branch %q is not tracked by av", issue.Branch)
+	}
+	switch issue.Kind {
+	case DoctorBranchMissing:
+		for _, child := range meta.Children(tx, br.Name) {
+			child.Parent = br.Parent
+			tx.SetBranch(child)
+		}
+		tx.DeleteBranch(br.Name)
+	case DoctorBranchRenamed:
+		for _, child := range meta.Children(tx, br.Name) {
+			child.Parent.Name = issue.value
+			tx.SetBranch(child)
+		}
+		tx.DeleteBranch(br.Name)
+		// The pull request is still associated with the old name on the remote.
+		br.Name = issue.value
+		br.PullRequest = nil
+		tx.SetBranch(br)
+	case DoctorParentMissing, DoctorParentCycle:
+		br.Parent = meta.BranchState{Name: issue.value, Trunk: true}
+		tx.SetBranch(br)
+	case DoctorTrunkMismatch:
+		br.Parent.Trunk = !br.Parent.Trunk
+		if br.Parent.Trunk {
+			br.Parent.Head = ""
+		}
+		tx.SetBranch(br)
+	case DoctorStaleParentHead:
+		br.Parent.Head = issue.value
+		tx.SetBranch(br)
+	default:
+		return errors.Errorf("issue %q of branch %q can't be fixed automatically", issue.Kind, br.Name)
+	}
+	return nil
+}
+
+// gitBranchRenames returns the branches renamed with Git (keyed by the old names) among the
+// tracked branches. The renames are found in the reflogs of the untracked branches, where Git
+// records "Branch: renamed refs/heads/<old> to refs/heads/<new>".
+func gitBranchRenames(
+	repo *git.Repo,
+	heads map[string]string,
+	branches map[string]meta.Branch,
+	trunks []string,
+) (map[string]string, error) {
+	renames := map[string]string{}
+	for name := range heads {
+		if _, tracked := branches[name]; tracked || slices.Contains(trunks, name) {
+			continue
+		}
+		out, err := repo.Git("reflog", "show", "--format=%gs", "refs/heads/"+name, "--")
+		if err != nil {
+			// No reflog for the branch.
+			continue
+		}
+		for _, line := range strings.Split(out, "\n") {
+			rest, ok := strings.CutPrefix(line, "Branch: renamed refs/heads/")
+			if !ok {
+				continue
+			}
+			oldName, newName, ok := strings.Cut(rest, " to refs/heads/")
+			if !ok || newName != name {
+				continue
+			}
+			if _, tracked := branches[oldName]; tracked {
+				if _, exists := heads[oldName]; !exists {
+					renames[oldName] = name
+				}
+			}
+			break
+		}
+	}
+	return renames, nil
+}
+
+// parentCycle returns the branches in the cycle if the parents of the branch lead back to it.
+func parentCycle(branches map[string]meta.Branch, name string) []string {
+	cycle := []string{name}
+	for parent := branches[name].Parent; !parent.Trunk; parent = branches[parent.Name].Parent {
+		if parent.Name == name {
+			return cycle
+		}
+		if _, ok := branches[parent.Name]; !ok || slices.Contains(cycle, parent.Name) {
+			// The parents end at an untracked branch, or they lead to a cycle that doesn't
+			// include this branch.
+			return nil
+		}
+		cycle = append(cycle, parent.Name)
+	}
+	return nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
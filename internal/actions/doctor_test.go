@@ -0,0 +1,95 @@
+package actions_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnoseAndFix(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	repo.Git(t, "switch", "-c", "one")
+	repo.CommitFile(t, "one.txt", "1a\n")
+	oneHead := strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD"))
+	repo.Git(t, "switch", "-c", "two")
+	repo.CommitFile(t, "two.txt", "2a\n")
+	repo.Git(t, "switch", "-c", "three")
+	repo.CommitFile(t, "three.txt", "3a\n")
+	repo.Git(t, "switch", "main")
+	repo.Git(t, "branch", "-m", "one", "uno")
+	repo.Git(t, "branch", "-D", "three")
+	repo.Git(t, "branch", "four", "main")
+
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	tx.SetBranch(meta.Branch{
+		Name:   "two",
+		Parent: meta.BranchState{Name: "one", Head: strings.Repeat("0", 40)},
+	})
+	tx.SetBranch(meta.Branch{Name: "three", Parent: meta.BranchState{Name: "two"}})
+	tx.SetBranch(meta.Branch{Name: "four", Parent: meta.BranchState{Name: "gone"}})
+	require.NoError(t, tx.Commit())
+
+	issues, err := actions.Diagnose(repo.AsAvGitRepo(), db.ReadTx())
+	require.NoError(t, err)
+	var kinds []actions.DoctorIssueKind
+	for _, issue := range issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	require.Equal(t, []actions.DoctorIssueKind{
+		actions.DoctorParentMissing,
+		actions.DoctorBranchRenamed,
+		actions.DoctorBranchMissing,
+	}, kinds)
+
+	wtx := db.WriteTx()
+	for _, issue := range issues {
+		require.NoError(t, actions.FixDoctorIssue(wtx, issue))
+	}
+	require.NoError(t, wtx.Commit())
+
+	rtx := db.ReadTx()
+	_, exists := rtx.Branch("one")
+	require.False(t, exists)
+	_, exists = rtx.Branch("three")
+	require.False(t, exists)
+	four, _ := rtx.Branch("four")
+	require.Equal(t, meta.BranchState{Name: "main", Trunk: true}, four.Parent)
+
+	// The stale parent head is reported once the parent is renamed.
+	issues, err = actions.Diagnose(repo.AsAvGitRepo(), rtx)
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Equal(t, actions.DoctorStaleParentHead, issues[0].Kind)
+	wtx = db.WriteTx()
+	require.NoError(t, actions.FixDoctorIssue(wtx, issues[0]))
+	require.NoError(t, wtx.Commit())
+	two, _ := db.ReadTx().Branch("two")
+	require.Equal(t, meta.BranchState{Name: "uno", Head: oneHead}, two.Parent)
+
+	issues, err = actions.Diagnose(repo.AsAvGitRepo(), db.ReadTx())
+	require.NoError(t, err)
+	require.Empty(t, issues)
+}
+
+func TestDiagnoseParentCycle(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	repo.Git(t, "branch", "one")
+	repo.Git(t, "branch", "two")
+	db := repo.OpenDB(t)
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "two"}})
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one"}})
+	require.NoError(t, tx.Commit())
+
+	issues, err := actions.Diagnose(repo.AsAvGitRepo(), db.ReadTx())
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	require.Equal(t, actions.DoctorParentCycle, issues[0].Kind)
+	require.Equal(t, "one", issues[0].Branch)
+}
@@ -1,5 +1,12 @@
 package actions
 
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/utils/errutils"
+)
+
 // errExitSilently is an error type that indicates that program should exit
 // without printing any additional information with the given exit code.
 // This is meant for cases where the running commands wants to manage its own
@@ -7,8 +14,38 @@ package actions
 // nil from RunE would cause a exit with a zero code).
 type ErrExitSilently struct {
 	ExitCode int
+	// The error that the command has already shown to the user, if any. This is used for the
+	// failure class and the JSON error output (--json), and is not printed otherwise.
+	Err error
 }
 
 func (e ErrExitSilently) Error() string {
 	return "<exit silently>"
 }
+
+func (e ErrExitSilently) Unwrap() error {
+	return e.Err
+}
+
+// ErrConflict is the error of a rebase that stopped at a conflict for the user to resolve.
+var ErrConflict = errutils.WithFailureClass(
+	errors.New("the rebase stopped at a conflict that needs to be resolved"),
+	errutils.FailureConflict,
+)
+
+// ErrExitConflict exits with the conflict exit code after the command has shown the conflict.
+var ErrExitConflict = ErrExitSilently{ExitCode: errutils.FailureConflict.ExitCode, Err: ErrConflict}
+
+// BranchNotAdoptedError is returned when a command needs the av metadata of a branch that is
+// not adopted to av.
+type BranchNotAdoptedError struct {
+	Branch string
+}
+
+func (e BranchNotAdoptedError) Error() string {
+	return fmt.Sprintf("branch %q is not adopted to av", e.Branch)
+}
+
+func (e BranchNotAdoptedError) FailureClass() errutils.FailureClass {
+	return errutils.FailureMetadataMismatch
+}
@@ -0,0 +1,125 @@
+package actions
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,\d+)? @@`)
+
+// Hunk is a hunk of a diff without context lines (git diff -U0).
+type Hunk struct {
+	// The first line of the hunk in the old file. If OldLines is zero, the hunk is inserted
+	// after this line (zero for the beginning of the file).
+	OldStart int
+	// The number of the old lines replaced by the hunk.
+	OldLines int
+	// The new lines, including the line endings.
+	NewLines []string
+}
+
+// ParseHunks parses the hunks of a single-file diff generated with git diff -U0.
+func ParseHunks(diff string) ([]Hunk, error) {
+	var ret []Hunk
+	var cur *Hunk
+	lastAdded := false
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			m := hunkHeaderPattern.FindStringSubmatch(line)
+			if m == nil {
+				return nil, errors.Errorf("unexpected hunk header: %q", line)
+			}
+			start, _ := strconv.Atoi(m[1])
+			count := 1
+			if m[2] != "" {
+				count, _ = strconv.Atoi(m[2])
+			}
+			ret = append(ret, Hunk{OldStart: start, OldLines: count})
+			cur = &ret[len(ret)-1]
+			lastAdded = false
+			continue
+		}
+		if cur == nil {
+			// The file header.
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "+"):
+			cur.NewLines = append(cur.NewLines, line[1:])
+			lastAdded = true
+		case strings.HasPrefix(line, "-"):
+			lastAdded = false
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" for the preceding line.
+			if lastAdded {
+				last := &cur.NewLines[len(cur.NewLines)-1]
+				*last = strings.TrimSuffix(*last, "\n")
+			}
+		}
+	}
+	return ret, nil
+}
+
+// ApplyHunks applies the hunks to the content of the old file. The hunks must be in the order
+// of the lines and must not overlap (as generated by git diff).
+func ApplyHunks(content string, hunks []Hunk) string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var sb strings.Builder
+	pos := 0
+	for _, h := range hunks {
+		start := h.OldStart
+		if h.OldLines > 0 {
+			start--
+		}
+		for ; pos < start && pos < len(lines); pos++ {
+			sb.WriteString(lines[pos])
+		}
+		for _, l := range h.NewLines {
+			sb.WriteString(l)
+		}
+		pos = start + h.OldLines
+	}
+	for ; pos < len(lines); pos++ {
+		sb.WriteString(lines[pos])
+	}
+	return sb.String()
+}
+
+// FixupTarget returns the commit that the hunk should be folded into, given the commit that
+// last changed each line of the old file (see git.Repo.BlameLines). The hunk belongs to a
+// commit if all the lines that it replaces were last changed by that commit. A hunk that only
+// adds lines belongs to the commit that changed the lines around it. Only the given commits
+// are considered.
+func FixupTarget(hunk Hunk, blame []string, commits map[string]bool) (string, bool) {
+	var owners []string
+	if hunk.OldLines > 0 {
+		for i := hunk.OldStart - 1; i < hunk.OldStart-1+hunk.OldLines && i < len(blame); i++ {
+			owners = append(owners, blame[i])
+		}
+	} else {
+		// The lines before and after the inserted lines.
+		for _, i := range []int{hunk.OldStart - 1, hunk.OldStart} {
+			if i >= 0 && i < len(blame) {
+				owners = append(owners, blame[i])
+			}
+		}
+	}
+	if len(owners) == 0 {
+		return "", false
+	}
+	for _, o := range owners {
+		if o != owners[0] {
+			return "", false
+		}
+	}
+	if !commits[owners[0]] {
+		return "", false
+	}
+	return owners[0], true
+}
@@ -0,0 +1,82 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fixupTestDiff = `diff --git a/file.txt b/file.txt
+index 1111111..2222222 100644
+--- a/file.txt
++++ b/file.txt
+@@ -0,0 +1 @@
++zero
+@@ -2 +3,2 @@
+-two
++TWO
++two and a half
+@@ -4,2 +5,0 @@
+-four
+-five
+@@ -6 +6 @@
+-six
+\ No newline at end of file
++SIX
+\ No newline at end of file
+`
+
+func TestParseHunks(t *testing.T) {
+	hunks, err := actions.ParseHunks(fixupTestDiff)
+	require.NoError(t, err)
+	assert.Equal(t, []actions.Hunk{
+		{OldStart: 0, OldLines: 0, NewLines: []string{"zero\n"}},
+		{OldStart: 2, OldLines: 1, NewLines: []string{"TWO\n", "two and a half\n"}},
+		{OldStart: 4, OldLines: 2},
+		{OldStart: 6, OldLines: 1, NewLines: []string{"SIX"}},
+	}, hunks)
+}
+
+func TestApplyHunks(t *testing.T) {
+	hunks, err := actions.ParseHunks(fixupTestDiff)
+	require.NoError(t, err)
+	old := "one\ntwo\nthree\nfour\nfive\nsix"
+
+	assert.Equal(t, "zero\none\nTWO\ntwo and a half\nthree\nSIX", actions.ApplyHunks(old, hunks))
+	assert.Equal(t, old, actions.ApplyHunks(old, nil))
+	assert.Equal(
+		t,
+		"one\nTWO\ntwo and a half\nthree\nsix",
+		actions.ApplyHunks(old, []actions.Hunk{hunks[1], hunks[2]}),
+	)
+}
+
+func TestFixupTarget(t *testing.T) {
+	blame := []string{"a", "b", "b", "c"}
+	commits := map[string]bool{"a": true, "b": true}
+
+	for _, tt := range []struct {
+		hunk   actions.Hunk
+		want   string
+		wantOK bool
+	}{
+		// Replaces the lines of b.
+		{actions.Hunk{OldStart: 2, OldLines: 2}, "b", true},
+		// Replaces the lines of a and b.
+		{actions.Hunk{OldStart: 1, OldLines: 2}, "", false},
+		// Replaces the line of c, which is not a candidate.
+		{actions.Hunk{OldStart: 4, OldLines: 1}, "", false},
+		// Inserts between the lines of b.
+		{actions.Hunk{OldStart: 2, OldLines: 0}, "b", true},
+		// Inserts between the lines of a and b.
+		{actions.Hunk{OldStart: 1, OldLines: 0}, "", false},
+		// Inserts at the beginning of the file.
+		{actions.Hunk{OldStart: 0, OldLines: 0}, "a", true},
+	} {
+		got, ok := actions.FixupTarget(tt.hunk, blame, commits)
+		assert.Equal(t, tt.want, got)
+		assert.Equal(t, tt.wantOK, ok)
+	}
+}
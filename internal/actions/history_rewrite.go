@@ -0,0 +1,104 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/sirupsen/logrus"
+)
+
+// HistoryRewriteImpact is the work based on the history of a branch that a force push of the
+// branch rewrites.
+type HistoryRewriteImpact struct {
+	// The open pull requests based on the branch that are not managed by av in this
+	// repository (e.g., the branches of the teammates).
+	PullRequests []gh.BasedPullRequest
+	// The child branches that are still based on the old history (e.g., not restacked yet).
+	Children []string
+	// The remote tracking branches that contain the old history but have no pull request
+	// based on the branch (e.g., the work in progress of the teammates).
+	RemoteBranches []string
+}
+
+// Empty returns true if the push doesn't rewrite the history that anything is based on.
+func (i HistoryRewriteImpact) Empty() bool {
+	return len(i.PullRequests) == 0 && len(i.Children) == 0 && len(i.RemoteBranches) == 0
+}
+
+// Lines returns a line for each impacted pull request or branch.
+func (i HistoryRewriteImpact) Lines() []string {
+	var ret []string
+	for _, pr := range i.PullRequests {
+		ret = append(ret, fmt.Sprintf(
+			"#%d %s by @%s %s", pr.Number, pr.HeadRefName, pr.Author.Login, pr.Permalink,
+		))
+	}
+	for _, name := range i.Children {
+		ret = append(ret, name+" (a child branch that is not restacked yet)")
+	}
+	for _, name := range i.RemoteBranches {
+		ret = append(ret, name+" (no pull request)")
+	}
+	return ret
+}
+
+// FindHistoryRewriteImpact returns the work based on oldHead, the commit of the remote branch
+// that force-pushing the branch to the remote rewrites. The branches managed by av are
+// restacked together, so only their child branches that are still based on oldHead are
+// reported.
+func FindHistoryRewriteImpact(
+	ctx context.Context,
+	repo *git.Repo,
+	f forge.Forge,
+	tx meta.ReadTx,
+	remote string,
+	branchName string,
+	oldHead string,
+) HistoryRewriteImpact {
+	var ret HistoryRewriteImpact
+	reported := map[string]bool{branchName: true}
+	prs, err := f.OpenPullRequestsWithBase(ctx, branchName)
+	if err != nil {
+		logrus.WithError(err).
+			WithField("branch", branchName).
+			Warning("failed to query the pull requests based on the branch")
+	}
+	for _, pr := range prs {
+		name := strings.TrimPrefix(pr.HeadRefName, "refs/heads/")
+		reported[name] = true
+		if _, ok := tx.Branch(name); !ok {
+			ret.PullRequests = append(ret.PullRequests, pr)
+		}
+	}
+
+	for _, child := range meta.ChildrenNames(tx, branchName) {
+		if ok, err := repo.IsAncestor(oldHead, "refs/heads/"+child); err == nil && ok {
+			ret.Children = append(ret.Children, child)
+		}
+	}
+
+	prefix := git.RemoteTrackingRef(remote, "")
+	out, err := repo.Git(
+		"for-each-ref", "--format=%(refname)", "--contains", oldHead, prefix,
+	)
+	if err != nil {
+		logrus.WithError(err).Warning("failed to list the remote branches based on the branch")
+		return ret
+	}
+	for _, ref := range strings.Split(out, "\n") {
+		name := strings.TrimPrefix(ref, prefix)
+		if name == "" || name == "HEAD" || reported[name] {
+			continue
+		}
+		if _, ok := tx.Branch(name); ok {
+			continue
+		}
+		ret.RemoteBranches = append(ret.RemoteBranches, remote+"/"+name)
+	}
+	return ret
+}
@@ -0,0 +1,71 @@
+package actions_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// basedPullRequestsForge is a forge that returns the given pull requests as the ones based on
+// any branch.
+type basedPullRequestsForge struct {
+	forge.Forge
+	prs []gh.BasedPullRequest
+}
+
+func (f *basedPullRequestsForge) OpenPullRequestsWithBase(
+	context.Context,
+	string,
+) ([]gh.BasedPullRequest, error) {
+	return f.prs, nil
+}
+
+func TestFindHistoryRewriteImpact(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	// one is pushed, and two (managed), bob (unmanaged, without a pull request), and carol
+	// (unmanaged, with a pull request) are based on it.
+	repo.Git(t, "checkout", "-b", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	repo.Git(t, "push", "origin", "one")
+	oldHead := strings.TrimSpace(repo.Git(t, "rev-parse", "one"))
+	for _, name := range []string{"two", "bob", "carol"} {
+		repo.Git(t, "checkout", "-b", name, "one")
+		repo.CommitFile(t, name+".txt", name)
+		repo.Git(t, "push", "origin", name)
+	}
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one", Head: oldHead}})
+	require.NoError(t, tx.Commit())
+
+	f := &basedPullRequestsForge{prs: []gh.BasedPullRequest{
+		{Number: 3, HeadRefName: "carol"},
+		{Number: 2, HeadRefName: "two"},
+	}}
+	impact := actions.FindHistoryRewriteImpact(
+		context.Background(), repo.AsAvGitRepo(), f, db.ReadTx(), "origin", "one", oldHead,
+	)
+	assert.Equal(t, []gh.BasedPullRequest{{Number: 3, HeadRefName: "carol"}}, impact.PullRequests)
+	assert.Equal(t, []string{"two"}, impact.Children)
+	assert.Equal(t, []string{"origin/bob"}, impact.RemoteBranches)
+
+	// The child branch restacked onto the new history is not affected.
+	repo.Git(t, "checkout", "one")
+	repo.CommitFile(t, "one.txt", "one amended", gittest.WithAmend())
+	repo.Git(t, "rebase", "--onto", "one", oldHead, "two")
+	impact = actions.FindHistoryRewriteImpact(
+		context.Background(), repo.AsAvGitRepo(), f, db.ReadTx(), "origin", "one", oldHead,
+	)
+	assert.Empty(t, impact.Children)
+	assert.Equal(t, []string{"origin/bob"}, impact.RemoteBranches)
+}
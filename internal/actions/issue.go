@@ -0,0 +1,69 @@
+package actions
+
+import (
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+)
+
+// A Jira-style issue key (e.g., "PROJ-123"), or a GitHub issue ("#123" or "owner/repo#123").
+var issuePattern = regexp.MustCompile(`^(?:[A-Z][A-Z0-9_]*-[0-9]+|(?:[\w.-]+/[\w.-]+)?#[0-9]+)$`)
+
+// The closing keyword added to the pull request body for a GitHub issue so that merging the pull
+// request closes the issue.
+const issueClosingKeyword = "Fixes"
+
+// ParseIssue returns the issue reference given to av branch --issue. A bare number is taken as a
+// GitHub issue of the repository (e.g., "123" is "#123").
+func ParseIssue(s string) (string, error) {
+	issue := strings.TrimSpace(s)
+	if issue != "" && strings.Trim(issue, "0123456789") == "" {
+		issue = "#" + issue
+	}
+	if !issuePattern.MatchString(issue) {
+		return "", errors.Errorf(
+			"invalid issue %q (expected an issue key like PROJ-123 or a GitHub issue like #123)", s,
+		)
+	}
+	return issue, nil
+}
+
+// IsGitHubIssue returns true if the issue is a GitHub issue ("#123" or "owner/repo#123") rather
+// than an issue key of another tracker.
+func IsGitHubIssue(issue string) bool {
+	return strings.Contains(issue, "#")
+}
+
+// AddIssueToSubject prepends the issue to the subject (the first line) of a commit message or a
+// pull request title in the format of pullRequest.title.ticketFormat, unless it's already there.
+func AddIssueToSubject(message string, issue string) string {
+	subject, _, _ := strings.Cut(message, "\n")
+	if issue == "" || strings.Contains(subject, issue) {
+		return message
+	}
+	format := config.Av.PullRequest.Title.TicketFormat
+	if format == "" {
+		format = defaultTicketFormat
+	}
+	return strings.ReplaceAll(format, "%s", issue) + message
+}
+
+// AddIssueToBody appends the issue reference to the pull request body unless the body already
+// mentions the issue. A GitHub issue is referenced with a closing keyword (e.g., "Fixes #123")
+// so that merging the pull request closes the issue.
+func AddIssueToBody(body string, issue string) string {
+	if issue == "" || strings.Contains(body, issue) {
+		return body
+	}
+	ref := "Issue: " + issue
+	if IsGitHubIssue(issue) {
+		ref = issueClosingKeyword + " " + issue
+	}
+	body = strings.TrimRight(body, "\n")
+	if body == "" {
+		return ref + "\n"
+	}
+	return body + "\n\n" + ref + "\n"
+}
@@ -0,0 +1,41 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIssue(t *testing.T) {
+	for in, want := range map[string]string{
+		"PROJ-123":           "PROJ-123",
+		" ENG2-7 ":           "ENG2-7",
+		"#42":                "#42",
+		"42":                 "#42",
+		"aviator-co/av#1234": "aviator-co/av#1234",
+	} {
+		got, err := actions.ParseIssue(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"", "proj-123", "PROJ", "#", "fix login"} {
+		_, err := actions.ParseIssue(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestAddIssueToSubject(t *testing.T) {
+	assert.Equal(t, "[PROJ-1] Fix login\n\nBody", actions.AddIssueToSubject("Fix login\n\nBody", "PROJ-1"))
+	assert.Equal(t, "PROJ-1: fix login", actions.AddIssueToSubject("PROJ-1: fix login", "PROJ-1"))
+	assert.Equal(t, "[#7] ", actions.AddIssueToSubject("", "#7"))
+	assert.Equal(t, "Fix login", actions.AddIssueToSubject("Fix login", ""))
+}
+
+func TestAddIssueToBody(t *testing.T) {
+	assert.Equal(t, "Fixes #7\n", actions.AddIssueToBody("", "#7"))
+	assert.Equal(t, "Some text.\n\nFixes o/r#7\n", actions.AddIssueToBody("Some text.\n", "o/r#7"))
+	assert.Equal(t, "Some text.\n\nIssue: PROJ-1\n", actions.AddIssueToBody("Some text.", "PROJ-1"))
+	assert.Equal(t, "Closes #7.\n", actions.AddIssueToBody("Closes #7.\n", "#7"))
+}
@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+)
+
+// IsLocalOnlyCommit returns true if the commit subject marks the commit as local-only (see
+// config.Av.LocalOnlyCommitPrefix).
+func IsLocalOnlyCommit(subject string) bool {
+	prefix := config.Av.LocalOnlyCommitPrefix
+	return prefix != "" && strings.HasPrefix(subject, prefix)
+}
+
+// PushCommit returns the commit to push to the remote for the given branch. This is the head
+// of the branch unless the branch or its ancestor branches have local-only commits. In that
+// case, the commits of the branch are replayed onto the pushed commit of the parent branch
+// without the local-only commits.
+//
+// The replay is deterministic, so the same commit is returned as long as the branches are not
+// modified.
+func PushCommit(repo *git.Repo, tx meta.ReadTx, branchName string) (string, error) {
+	head, err := repo.RevParse(&git.RevParse{Rev: branchName})
+	if err != nil {
+		return "", err
+	}
+	branch, ok := tx.Branch(branchName)
+	if config.Av.LocalOnlyCommitPrefix == "" || !ok || branch.Parent.Name == "" {
+		return head, nil
+	}
+
+	var base, pushBase string
+	if branch.Parent.Trunk {
+		base, err = repo.MergeBase(branch.Parent.Name, branchName)
+		if err != nil {
+			return "", err
+		}
+		pushBase = base
+	} else {
+		base, err = repo.RevParse(&git.RevParse{Rev: branch.Parent.Name})
+		if err != nil {
+			return "", err
+		}
+		pushBase, err = PushCommit(repo, tx, branch.Parent.Name)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	commits, err := repo.Log(git.LogOpts{RevisionRange: []string{base + ".." + branchName}})
+	if err != nil {
+		return "", err
+	}
+	if pushBase == base && !slices.ContainsFunc(commits, func(c *git.CommitInfo) bool {
+		return IsLocalOnlyCommit(c.Subject)
+	}) {
+		return head, nil
+	}
+
+	// git log lists the newest commit first.
+	slices.Reverse(commits)
+	pushCommit := pushBase
+	for _, c := range commits {
+		if IsLocalOnlyCommit(c.Subject) {
+			continue
+		}
+		pushCommit, err = repo.ReplayCommit(c.Hash, pushCommit)
+		if err != nil {
+			return "", errors.WrapIff(
+				err,
+				"failed to exclude the local-only commits from branch %q (a commit depends on a local-only commit?)",
+				branchName,
+			)
+		}
+	}
+	return pushCommit, nil
+}
+
+// excludeLocalOnlyCommits removes the local-only commits from the newline-separated list of
+// commit hashes.
+func excludeLocalOnlyCommits(repo *git.Repo, commitsList string) string {
+	if config.Av.LocalOnlyCommitPrefix == "" || commitsList == "" {
+		return commitsList
+	}
+	var ret []string
+	for _, commitHash := range strings.Split(commitsList, "\n") {
+		commit, err := repo.CommitInfo(git.CommitInfoOpts{Rev: commitHash})
+		if err == nil && IsLocalOnlyCommit(commit.Subject) {
+			continue
+		}
+		ret = append(ret, commitHash)
+	}
+	return strings.Join(ret, "\n")
+}
@@ -0,0 +1,69 @@
+package actions_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushCommit(t *testing.T) {
+	config.Av.LocalOnlyCommitPrefix = "[local]"
+	t.Cleanup(func() { config.Av.LocalOnlyCommitPrefix = "" })
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+	avRepo := repo.AsAvGitRepo()
+
+	repo.Git(t, "checkout", "-b", "one")
+	repo.CommitFile(t, "one.txt", "one")
+	repo.CommitFile(t, "debug.txt", "debug", gittest.WithMessage("[local] Enable debug logging"))
+	repo.CommitFile(t, "one-more.txt", "one more")
+	oneHead := strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD"))
+	repo.Git(t, "checkout", "-b", "two")
+	repo.CommitFile(t, "two.txt", "two")
+	twoHead := strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD"))
+	repo.Git(t, "checkout", "-b", "three", "main")
+	repo.CommitFile(t, "three.txt", "three")
+	threeHead := strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD"))
+
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one", Head: oneHead}})
+	tx.SetBranch(meta.Branch{Name: "three", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	require.NoError(t, tx.Commit())
+
+	files := func(commit string) []string {
+		return strings.Fields(repo.Git(t, "ls-tree", "-r", "--name-only", commit))
+	}
+	subjects := func(commit string) []string {
+		return strings.Split(strings.TrimSpace(repo.Git(t, "log", "--format=%s", "main.."+commit)), "\n")
+	}
+
+	onePush, err := actions.PushCommit(avRepo, db.ReadTx(), "one")
+	require.NoError(t, err)
+	assert.NotEqual(t, oneHead, onePush)
+	assert.Equal(t, []string{"README.md", "one-more.txt", "one.txt"}, files(onePush))
+	assert.Equal(t, []string{"Write one-more.txt", "Write one.txt"}, subjects(onePush))
+
+	// The child branch is replayed onto the pushed commit of the parent.
+	twoPush, err := actions.PushCommit(avRepo, db.ReadTx(), "two")
+	require.NoError(t, err)
+	assert.NotEqual(t, twoHead, twoPush)
+	assert.Equal(t, []string{"README.md", "one-more.txt", "one.txt", "two.txt"}, files(twoPush))
+	assert.Equal(t, onePush, strings.TrimSpace(repo.Git(t, "rev-parse", twoPush+"^")))
+
+	// The replay is deterministic.
+	onePushAgain, err := actions.PushCommit(avRepo, db.ReadTx(), "one")
+	require.NoError(t, err)
+	assert.Equal(t, onePush, onePushAgain)
+
+	// A branch without local-only commits is pushed as is.
+	threePush, err := actions.PushCommit(avRepo, db.ReadTx(), "three")
+	require.NoError(t, err)
+	assert.Equal(t, threeHead, threePush)
+}
@@ -0,0 +1,64 @@
+package actions
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/uiutils"
+)
+
+// BranchesLockedByOthers returns the branches (of the given names) that are locked by users
+// other than the given user (see meta.BranchLock).
+func BranchesLockedByOthers(tx meta.ReadTx, branchNames []string, owner string) []meta.Branch {
+	var ret []meta.Branch
+	for _, name := range branchNames {
+		br, ok := tx.Branch(name)
+		if ok && br.Lock != nil && !strings.EqualFold(br.Lock.Owner, owner) {
+			ret = append(ret, br)
+		}
+	}
+	return ret
+}
+
+// ConfirmLockedBranches warns if any of the branches is locked by another user before they
+// are rebased or pushed (the operation is described by action, e.g., "restack"). On a
+// terminal, the user is asked to continue, and ErrExitSilently is returned if they don't. The
+// operation continues with the warning otherwise.
+func ConfirmLockedBranches(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	branchNames []string,
+	action string,
+) error {
+	owner, err := repo.CommitterEmail()
+	if err != nil {
+		return err
+	}
+	locked := BranchesLockedByOthers(tx, branchNames, owner)
+	if len(locked) == 0 {
+		return nil
+	}
+	for _, br := range locked {
+		_, _ = fmt.Fprint(os.Stderr,
+			colors.Warning("Branch "), colors.UserInput(br.Name),
+			colors.Warning(" is locked by "), colors.UserInput(br.Lock.Owner),
+			colors.Warning(" (since "+br.Lock.LockedAt.Local().Format(time.DateTime)+").\n"),
+		)
+	}
+	if !uiutils.IsInteractive() {
+		return nil
+	}
+	_, _ = fmt.Fprint(os.Stderr, "They may be edited right now. Continue to "+action+"? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return ErrExitSilently{ExitCode: 1}
+}
@@ -0,0 +1,27 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchesLockedByOthers(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "mine", Lock: &meta.BranchLock{Owner: "Me@example.com"}})
+	tx.SetBranch(meta.Branch{Name: "theirs", Lock: &meta.BranchLock{Owner: "them@example.com"}})
+	tx.SetBranch(meta.Branch{Name: "unlocked"})
+	require.NoError(t, tx.Commit())
+
+	locked := actions.BranchesLockedByOthers(
+		db.ReadTx(), []string{"mine", "theirs", "unlocked", "unknown"}, "me@example.com",
+	)
+	require.Len(t, locked, 1)
+	require.Equal(t, "theirs", locked[0].Name)
+}
@@ -4,17 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/aviator-co/av/internal/utils/errutils"
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/editor"
+	"github.com/aviator-co/av/internal/forge"
 	"github.com/aviator-co/av/internal/gh"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
@@ -24,6 +27,7 @@ import (
 	"github.com/aviator-co/av/internal/utils/stackutils"
 	"github.com/aviator-co/av/internal/utils/stringutils"
 	"github.com/aviator-co/av/internal/utils/templateutils"
+	"github.com/aviator-co/av/internal/utils/uiutils"
 	"github.com/fatih/color"
 	"github.com/shurcooL/githubv4"
 	"github.com/sirupsen/logrus"
@@ -46,8 +50,16 @@ type CreatePullRequestOpts struct {
 	Force bool
 	// If true, open an editor for editing the title and body
 	Edit bool
+	// If true, the title and the body are suggested by the summarizer (see SummarizeBranch)
+	// and opened in the editor.
+	Summarize bool
 	// If true, do not open the browser after creating the PR
 	NoOpenBrowser bool
+	// The pull request of the branch if it's already queried (see PrefetchPullRequests). It's
+	// queried from the forge if nil.
+	ExistingPullRequest *gh.PullRequest
+	// Where the progress is written. Defaults to stderr.
+	Output io.Writer
 }
 
 type CreatePullRequestResult struct {
@@ -95,51 +107,56 @@ func (e errPullRequestClosed) Error() string {
 }
 
 // getExistingOpenPR returns an existing pull request for the given branch if
-// any exist and are open.
+// any exist and are open. The known pull request is used instead of querying it if it's the
+// pull request of the branch.
 func getExistingOpenPR(
 	ctx context.Context,
-	client *gh.Client,
-	repoMeta meta.Repository,
+	f forge.Forge,
 	branchMeta meta.Branch,
 	baseRefName string,
+	known *gh.PullRequest,
 ) (*gh.PullRequest, error) {
 	if branchMeta.PullRequest != nil {
-		logrus.WithField("pr", branchMeta.PullRequest.Number).
-			Debug("querying data for existing PR from GitHub")
-		pr, err := client.PullRequest(ctx, branchMeta.PullRequest.ID)
-		if err != nil {
-			return nil, errors.WrapIf(err, "querying existing pull request")
+		pr := known
+		if pr == nil || pr.ID != branchMeta.PullRequest.ID {
+			logrus.WithField("pr", branchMeta.PullRequest.Number).
+				Debugf("querying data for existing PR from %s", f.Name())
+			var err error
+			pr, err = f.PullRequest(ctx, branchMeta.PullRequest.ID)
+			if err != nil {
+				return nil, errors.WrapIf(err, "querying existing pull request")
+			}
 		}
 		if pr.State != githubv4.PullRequestStateOpen {
 			return nil, errPullRequestClosed{pr}
 		}
 		return pr, nil
 	}
-	logrus.WithField("branch", branchMeta.Name).Debug("querying existing open PRs from GitHub")
-	existing, err := client.GetPullRequests(ctx, gh.GetPullRequestsInput{
-		Owner:       repoMeta.Owner,
-		Repo:        repoMeta.Name,
-		HeadRefName: branchMeta.Name,
-		BaseRefName: baseRefName,
-		States:      []githubv4.PullRequestState{githubv4.PullRequestStateOpen},
-	})
+	logrus.WithField("branch", branchMeta.Name).Debugf("querying existing open PRs from %s", f.Name())
+	pulls, err := f.BranchPullRequests(ctx, branchMeta.Name)
 	if err != nil {
 		return nil, errors.WrapIf(err, "querying existing pull requests")
 	}
-	if len(existing.PullRequests) > 1 {
+	var existing []gh.PullRequest
+	for _, pull := range pulls {
+		if pull.State == githubv4.PullRequestStateOpen && pull.BaseBranchName() == baseRefName {
+			existing = append(existing, pull)
+		}
+	}
+	if len(existing) > 1 {
 		return nil, errors.Errorf("multiple existing PRs found for %q", branchMeta.Name)
-	} else if len(existing.PullRequests) == 1 {
-		return &existing.PullRequests[0], nil
+	} else if len(existing) == 1 {
+		return &existing[0], nil
 	}
 	return nil, nil
 }
 
-// CreatePullRequest creates a pull request on GitHub for the current branch, if
-// one doesn't already exist.
+// CreatePullRequest creates a pull request on the forge (GitHub or GitLab) for the current
+// branch, if one doesn't already exist.
 func CreatePullRequest(
 	ctx context.Context,
 	repo *git.Repo,
-	client *gh.Client,
+	f forge.Forge,
 	tx meta.WriteTx,
 	opts CreatePullRequestOpts,
 ) (_ *CreatePullRequestResult, reterr error) {
@@ -147,22 +164,26 @@ func CreatePullRequest(
 		logrus.Panicf("internal invariant error: CreatePullRequest called with empty branch name")
 	}
 
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
 	repoMeta := tx.Repository()
 	branchMeta, _ := tx.Branch(opts.BranchName)
 
 	var existingPR *gh.PullRequest
 	if !opts.Force {
 		var err error
-		existingPR, err = getExistingOpenPR(ctx, client, repoMeta, branchMeta, opts.BranchName)
+		existingPR, err = getExistingOpenPR(ctx, f, branchMeta, opts.BranchName, opts.ExistingPullRequest)
 		if closed, ok := errutils.As[errPullRequestClosed](err); ok {
-			_, _ = fmt.Fprint(os.Stderr,
+			_, _ = fmt.Fprint(out,
 				colors.Failure("Existing pull request for branch "),
 				colors.UserInput(opts.BranchName),
 				colors.Failure(" is "), colors.UserInput(closed.State),
 				colors.Failure(": "), colors.UserInput(closed.Permalink),
 				"\n",
 			)
-			_, _ = fmt.Fprint(os.Stderr,
+			_, _ = fmt.Fprint(out,
 				colors.Faint("  - use "), colors.CliCmd("av pr --force"),
 				colors.Faint(" to create a new pull request for this branch\n"),
 			)
@@ -181,42 +202,77 @@ func CreatePullRequest(
 	if existingPR != nil {
 		verb = "Updating"
 	}
-	_, _ = fmt.Fprint(os.Stderr,
+	_, _ = fmt.Fprint(out,
 		verb, " pull request for branch ", colors.UserInput(opts.BranchName), ":",
 		"\n",
 	)
 	if !opts.NoPush || opts.ForcePush {
 		pushFlags := []string{"push"}
 
+		remote := repo.GetBranchPushRemoteName(opts.BranchName)
+		pushCommit, err := PushCommit(repo, tx, opts.BranchName)
+		if err != nil {
+			return nil, err
+		}
+		lease := ""
 		if opts.ForcePush {
 			pushFlags = append(pushFlags, "--force")
-		} else {
+		} else if remoteCommit, err := repo.RevParse(&git.RevParse{
+			Rev: git.RemoteTrackingRef(remote, opts.BranchName),
+		}); err == nil {
 			// Use --force-with-lease to allow pushing branches that have been
 			// rebased but don't overwrite changes if we don't expect them to
 			// be there.
+			if lease, err = PushLease(repo, remote, opts.BranchName, remoteCommit, pushCommit); err != nil {
+				return nil, err
+			}
+			pushFlags = append(pushFlags, fmt.Sprintf(
+				"--force-with-lease=refs/heads/%s:%s", opts.BranchName, lease,
+			))
+		} else {
 			pushFlags = append(pushFlags, "--force-with-lease")
 		}
-
-		remote := repo.GetRemoteName()
-		pushFlags = append(pushFlags, remote, opts.BranchName)
+		pushFlags = append(pushFlags, CIHintPushOptions(tx, opts.BranchName)...)
+		pushFlags = append(
+			pushFlags, remote, fmt.Sprintf("%s:refs/heads/%s", pushCommit, opts.BranchName),
+		)
 		logrus.Debug("pushing latest changes")
+		warnHistoryRewrite(ctx, out, repo, f, tx, opts.BranchName, pushCommit)
 
-		_, _ = fmt.Fprint(os.Stderr,
+		_, _ = fmt.Fprint(out,
 			"  - pushing to ", color.CyanString("%s/%s", remote, opts.BranchName),
 			"\n",
 		)
-		if _, err := repo.Git(pushFlags...); err != nil {
+		res, err := repo.Run(&git.RunOpts{Args: pushFlags})
+		if err != nil {
 			return nil, errors.WrapIf(err, "failed to push")
 		}
+		if res.ExitCode != 0 {
+			if lease != "" && IsStaleLeaseRejection(res.Stderr) {
+				return nil, RemoteMovedError{Branch: opts.BranchName, Remote: remote, Expected: lease}
+			}
+			return nil, errors.Errorf("failed to push\n%s", res.Stderr)
+		}
 		if err := repo.BranchSetConfig(opts.BranchName, "av-pushed-remote", remote); err != nil {
 			return nil, err
 		}
 		if err := repo.BranchSetConfig(opts.BranchName, "av-pushed-ref", fmt.Sprintf("refs/heads/%s", opts.BranchName)); err != nil {
 			return nil, err
 		}
+		if err := repo.BranchSetConfig(opts.BranchName, "av-pushed-commit", pushCommit); err != nil {
+			return nil, err
+		}
+		if config.Av.UpstreamTracking == config.UpstreamTrackingPush {
+			if err := repo.BranchSetUpstream(opts.BranchName, remote); err != nil {
+				return nil, err
+			}
+		}
+		if err := repo.PushBranchNotes(); err != nil {
+			logrus.WithError(err).Warning("failed to push the branch notes")
+		}
 	} else {
-		_, _ = fmt.Fprint(os.Stderr,
-			"  - skipping push to GitHub",
+		_, _ = fmt.Fprint(out,
+			"  - skipping push to ", f.Name(),
 			"\n",
 		)
 	}
@@ -254,7 +310,7 @@ func CreatePullRequest(
 		}
 	} else {
 		logrus.WithField("base", parentState.Name).Debug("base branch is a trunk branch")
-		prCompareRef = fmt.Sprintf("%s/%s", repo.GetRemoteName(), parentState.Name)
+		prCompareRef = repo.RemoteBranchRef(parentState.Name)
 	}
 
 	commitsList, err := repo.Git(
@@ -265,6 +321,7 @@ func CreatePullRequest(
 	if err != nil {
 		return nil, errors.WrapIf(err, "failed to determine commits to include in PR")
 	}
+	commitsList = excludeLocalOnlyCommits(repo, commitsList)
 	if commitsList == "" {
 		return nil, errors.Errorf("no commits between %q and %q", prCompareRef, opts.BranchName)
 	}
@@ -288,8 +345,22 @@ func CreatePullRequest(
 		}
 	}
 
-	if opts.Edit || opts.Body == "" || opts.Title == "" {
-		var commits []git.CommitInfo
+	if opts.Summarize {
+		title, body, err := SummarizeBranch(repo, opts.BranchName, parentState.Name, prCompareRef)
+		if err != nil {
+			return nil, err
+		}
+		opts.Title = title
+		if body != "" {
+			opts.Body = body
+		}
+		opts.Edit = true
+	}
+
+	generatedBody := config.Av.PullRequest.GeneratedBody
+	openEditor := opts.Edit || opts.Body == "" || opts.Title == ""
+	var commits []git.CommitInfo
+	if openEditor || generatedBody.Enabled {
 		for _, commitHash := range strings.Split(commitsList, "\n") {
 			commit, err := repo.CommitInfo(git.CommitInfoOpts{Rev: commitHash})
 			if err != nil {
@@ -297,7 +368,20 @@ func CreatePullRequest(
 			}
 			commits = append(commits, *commit)
 		}
+	}
+	addGeneratedBody := func() error {
+		data, err := NewPRBodyTemplateData(opts.BranchName, parentState.Name, commits)
+		if err != nil {
+			return err
+		}
+		if branchMeta.Issue != "" {
+			data.Ticket = branchMeta.Issue
+		}
+		opts.Body, err = AddPRGeneratedBody(opts.Body, generatedBody.Template, data)
+		return err
+	}
 
+	if openEditor {
 		// If a saved pull request description exists, use that.
 		saveFile := filepath.Join(
 			repo.AvTmpDir(),
@@ -321,17 +405,34 @@ func CreatePullRequest(
 		// Try to populate the editor text using contextual information from the
 		// repository and commits included in this pull request.
 		if opts.Title == "" {
-			opts.Title = commits[0].Subject
+			opts.Title, err = GeneratePullRequestTitle(
+				config.Av.PullRequest.Title,
+				opts.BranchName,
+				commits[0].Subject,
+			)
+			if err != nil {
+				return nil, err
+			}
+			opts.Title = AddIssueToSubject(opts.Title, branchMeta.Issue)
 		}
 		// Reasonable defaults for body:
 		// 1. Try and find a pull request template
 		if opts.Body == "" {
 			opts.Body = readDefaultPullRequestTemplate(repo)
 		}
-		// 2. Use the commit message from the first PR
-		if opts.Body == "" {
+		// 2. Use the commit message from the first PR, unless the commit messages are added
+		// in the generated section.
+		if opts.Body == "" && !generatedBody.Enabled {
 			opts.Body = commits[0].Body
 		}
+		if generatedBody.Enabled {
+			if err := addGeneratedBody(); err != nil {
+				return nil, err
+			}
+		}
+		if existingPR == nil {
+			opts.Body = AddIssueToBody(opts.Body, branchMeta.Issue)
+		}
 
 		editorText := templateutils.MustString(prBodyTemplate, prBodyTemplateData{
 			Branch:  opts.BranchName,
@@ -371,6 +472,40 @@ func CreatePullRequest(
 			// lost forever (and we can reuse it if they try again).
 			savePRDescriptionToTemporaryFile(saveFile, res)
 		}()
+	} else {
+		if generatedBody.Enabled {
+			if err := addGeneratedBody(); err != nil {
+				return nil, err
+			}
+		}
+		if existingPR == nil {
+			opts.Title = AddIssueToSubject(opts.Title, branchMeta.Issue)
+			opts.Body = AddIssueToBody(opts.Body, branchMeta.Issue)
+		}
+	}
+
+	if required := config.Av.PullRequest.RequiredSections; len(required) > 0 &&
+		(existingPR == nil || opts.Edit) {
+		if missing := MissingPullRequestSections(required, opts.Body); len(missing) > 0 {
+			if !uiutils.IsInteractive() {
+				return nil, errors.Errorf(
+					"the pull request description is missing the required section(s): %s",
+					strings.Join(missing, ", "),
+				)
+			}
+			opts.Body, err = PromptPullRequestSections(os.Stdin, os.Stderr, opts.Body, missing)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if checklist := config.Av.PullRequest.Checklist; len(checklist) > 0 {
+		files, err := changedFiles(repo, prCompareRef, opts.BranchName)
+		if err != nil {
+			return nil, errors.WrapIf(err, "failed to determine the changed files")
+		}
+		opts.Body = AddPRChecklist(opts.Body, checklist, files)
 	}
 
 	prMeta, err := getPRMetadata(tx, branchMeta, &parentMeta)
@@ -383,17 +518,23 @@ func CreatePullRequest(
 		draft = true
 	}
 
-	pull, didCreatePR, err := ensurePR(ctx, client, repoMeta, tx, ensurePROpts{
-		baseRefName: parentState.Name,
-		headRefName: opts.BranchName,
-		title:       opts.Title,
-		body:        opts.Body,
-		meta:        prMeta,
-		draft:       draft,
-		existingPR:  existingPR,
+	headRepositoryOwner, err := pullRequestHeadOwner(repo, tx.Repository(), opts.BranchName)
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to determine the owner of the fork")
+	}
+
+	pull, didCreatePR, err := ensurePR(ctx, f, tx, ensurePROpts{
+		baseRefName:         parentState.Name,
+		headRefName:         opts.BranchName,
+		headRepositoryOwner: headRepositoryOwner,
+		title:               opts.Title,
+		body:                opts.Body,
+		meta:                prMeta,
+		draft:               draft,
+		existingPR:          existingPR,
 	})
 	if err != nil {
-		_, _ = fmt.Fprint(os.Stderr,
+		_, _ = fmt.Fprint(out,
 			colors.Failure("  - failed to create pull request: "), err, "\n",
 		)
 		return nil, errors.WrapIf(err, "failed to create PR")
@@ -403,6 +544,7 @@ func CreatePullRequest(
 		Number:    pull.Number,
 		ID:        pull.ID,
 		Permalink: pull.Permalink,
+		Title:     pull.Title,
 	}
 	// It's possible that a new PR is created with the same branch. Reset the MergeCommit.
 	branchMeta.MergeCommit = ""
@@ -412,11 +554,21 @@ func CreatePullRequest(
 	} else {
 		action = "synchronized"
 	}
-	_, _ = fmt.Fprint(os.Stderr,
+	_, _ = fmt.Fprint(out,
 		"  - ", action, " pull request ",
 		colors.UserInput(pull.Permalink), "\n",
 	)
 
+	if client := forge.GitHubClient(f); didCreatePR && client != nil &&
+		config.Av.PullRequest.Project.Number != 0 {
+		// Don't fail the command since the pull request is already created.
+		if err := AddPullRequestToProject(ctx, client, repoMeta, pull.ID); err != nil {
+			_, _ = fmt.Fprint(out,
+				colors.Warning("  - failed to add pull request to project: "), err, "\n",
+			)
+		}
+	}
+
 	if didCreatePR && !opts.NoOpenBrowser && config.Av.PullRequest.OpenBrowser {
 		OpenPullRequestInBrowser(pull.Permalink)
 	}
@@ -425,6 +577,77 @@ func CreatePullRequest(
 	return &CreatePullRequestResult{didCreatePR, branchMeta, pull}, nil
 }
 
+// pullRequestHeadOwner returns the owner of the repository that the branch is pushed to if it's
+// different from the repository that the pull request is opened against (e.g., a fork), and
+// empty otherwise. The push URL is used, so the per-branch pushRemote, pushurl, and
+// pushInsteadOf rewrites are taken into account. A mirror of the canonical repository syncs
+// the branch to it, so the branch is in the canonical repository in that case.
+func pullRequestHeadOwner(
+	repo *git.Repo,
+	repository meta.Repository,
+	branchName string,
+) (string, error) {
+	if mirror, err := repo.IsPushMirror(branchName); err != nil {
+		return "", err
+	} else if mirror {
+		return "", nil
+	}
+	pushOrigin, err := repo.RemotePushOrigin(repo.GetBranchPushRemoteName(branchName))
+	if err != nil {
+		return "", err
+	}
+	// The pull requests are opened against the repository recorded by av init.
+	slug := repository.Owner + "/" + repository.Name
+	if repository.Owner == "" {
+		origin, err := repo.Origin()
+		if err != nil {
+			return "", err
+		}
+		slug = origin.RepoSlug
+	}
+	if strings.EqualFold(pushOrigin.RepoSlug, slug) {
+		return "", nil
+	}
+	return pushOrigin.Owner(), nil
+}
+
+// warnHistoryRewrite warns if pushing the branch rewrites the history that others have based
+// work on (see FindHistoryRewriteImpact).
+func warnHistoryRewrite(
+	ctx context.Context,
+	out io.Writer,
+	repo *git.Repo,
+	f forge.Forge,
+	tx meta.ReadTx,
+	branchName string,
+	pushCommit string,
+) {
+	remoteBranch := repo.PushRemoteBranchRef(branchName)
+	oldHead, err := repo.RevParse(&git.RevParse{Rev: remoteBranch})
+	if err != nil {
+		// Not pushed yet.
+		return
+	}
+	if ok, err := repo.IsAncestor(oldHead, pushCommit); err != nil || ok {
+		// Fast-forward push.
+		return
+	}
+	impact := FindHistoryRewriteImpact(
+		ctx, repo, f, tx, repo.GetBranchPushRemoteName(branchName), branchName, oldHead,
+	)
+	if impact.Empty() {
+		return
+	}
+	_, _ = fmt.Fprint(out,
+		colors.Warning("  - WARNING: this push rewrites the history of "),
+		colors.UserInput(branchName),
+		colors.Warning(", which others have based work on:\n"),
+	)
+	for _, line := range impact.Lines() {
+		_, _ = fmt.Fprintf(out, "      %s\n", line)
+	}
+}
+
 func OpenPullRequestInBrowser(pullRequestLink string) {
 	if err := browser.Open(pullRequestLink); err != nil {
 		_, _ = fmt.Fprint(os.Stderr,
@@ -500,13 +723,14 @@ func readDefaultPullRequestTemplate(repo *git.Repo) string {
 }
 
 type ensurePROpts struct {
-	baseRefName string
-	headRefName string
-	title       string
-	body        string
-	meta        PRMetadata
-	draft       bool
-	existingPR  *gh.PullRequest
+	baseRefName         string
+	headRefName         string
+	headRepositoryOwner string
+	title               string
+	body                string
+	meta                PRMetadata
+	draft               bool
+	existingPR          *gh.PullRequest
 }
 
 // ensurePR returns the pull request for the given input, creating a new
@@ -515,38 +739,33 @@ type ensurePROpts struct {
 // occurred.
 func ensurePR(
 	ctx context.Context,
-	client *gh.Client,
-	repoMeta meta.Repository,
+	f forge.Forge,
 	tx meta.ReadTx,
 	opts ensurePROpts,
 ) (*gh.PullRequest, bool, error) {
 	// Don't pass in a stack to start; we'll do a pass over all open PRs in the stack later.
 	var initialStack *stackutils.StackTreeNode = nil
 
+	body := AddPRMetadataAndStack(opts.body, opts.meta, opts.headRefName, initialStack, tx)
 	if opts.existingPR != nil {
-		newBody := AddPRMetadataAndStack(opts.body, opts.meta, opts.headRefName, initialStack, tx)
-		updatedPR, err := client.UpdatePullRequest(ctx, githubv4.UpdatePullRequestInput{
-			PullRequestID: opts.existingPR.ID,
-			Title:         gh.Ptr(githubv4.String(opts.title)),
-			Body:          gh.Ptr(githubv4.String(newBody)),
-			BaseRefName:   gh.Ptr(githubv4.String(opts.baseRefName)),
+		updatedPR, err := f.UpdatePullRequest(ctx, forge.UpdatePullRequestInput{
+			ID:          opts.existingPR.ID,
+			Title:       &opts.title,
+			Body:        &body,
+			BaseRefName: &opts.baseRefName,
 		})
 		if err != nil {
 			return nil, false, errors.WithStack(err)
 		}
 		return updatedPR, false, nil
 	}
-	pull, err := client.CreatePullRequest(ctx, githubv4.CreatePullRequestInput{
-		RepositoryID: githubv4.ID(repoMeta.ID),
-		BaseRefName:  githubv4.String(opts.baseRefName),
-		HeadRefName:  githubv4.String(opts.headRefName),
-		Title:        githubv4.String(opts.title),
-		Body: gh.Ptr(
-			githubv4.String(
-				AddPRMetadataAndStack(opts.body, opts.meta, opts.headRefName, initialStack, tx),
-			),
-		),
-		Draft: gh.Ptr(githubv4.Boolean(opts.draft)),
+	pull, err := f.CreatePullRequest(ctx, forge.CreatePullRequestInput{
+		BaseRefName:         opts.baseRefName,
+		HeadRefName:         opts.headRefName,
+		HeadRepositoryOwner: opts.headRepositoryOwner,
+		Title:               opts.title,
+		Body:                body,
+		Draft:               opts.draft,
 	})
 	if err != nil {
 		return nil, false, errors.WithStack(err)
@@ -562,39 +781,35 @@ type UpdatePullRequestResult struct {
 	Pull *gh.PullRequest
 }
 
-// UpdatePullRequestState fetches the latest pull request information from GitHub
+// UpdatePullRequestState fetches the latest pull request information from the forge
 // and writes the relevant branch metadata.
 func UpdatePullRequestState(
 	ctx context.Context,
-	client *gh.Client,
+	f forge.Forge,
 	tx meta.WriteTx,
 	branchName string,
 ) (*UpdatePullRequestResult, error) {
-	repoMeta := tx.Repository()
 	branch, _ := tx.Branch(branchName)
 
-	page, err := client.GetPullRequests(ctx, gh.GetPullRequestsInput{
-		Owner:       repoMeta.Owner,
-		Repo:        repoMeta.Name,
-		HeadRefName: branchName,
-	})
+	pulls, err := f.BranchPullRequests(ctx, branchName)
 	if err != nil {
-		return nil, errors.WrapIf(
+		return nil, errors.WrapIff(
 			err,
-			"querying GitHub pull requests. Make sure GitHub token is set or refresh.\nSee: https://docs.aviator.co/aviator-cli#getting-started",
+			"querying %[1]s pull requests. Make sure %[1]s token is set or refresh.\nSee: https://docs.aviator.co/aviator-cli#getting-started",
+			f.Name(),
 		)
 	}
 
-	if len(page.PullRequests) == 0 {
+	if len(pulls) == 0 {
 		// branch has no pull request
 		if branch.PullRequest != nil {
 			// This should never happen?
 			logrus.WithFields(logrus.Fields{
 				"branch": branch.Name,
 				"pull":   branch.PullRequest.Permalink,
-			}).Error("GitHub reported no pull requests for branch but local metadata has pull request")
-			return nil, errors.New(
-				"GitHub reported no pull requests for branch but local metadata has pull request",
+			}).Errorf("%s reported no pull requests for branch but local metadata has pull request", f.Name())
+			return nil, errors.Errorf(
+				"%s reported no pull requests for branch but local metadata has pull request", f.Name(),
 			)
 		}
 
@@ -606,8 +821,8 @@ func UpdatePullRequestState(
 	var currentPull *gh.PullRequest
 	// The current open pull request (if any)
 	var openPull *gh.PullRequest
-	for i := range page.PullRequests {
-		pull := &page.PullRequests[i]
+	for i := range pulls {
+		pull := &pulls[i]
 		if branch.PullRequest != nil && pull.ID == branch.PullRequest.ID {
 			currentPull = pull
 		}
@@ -641,11 +856,19 @@ func UpdatePullRequestState(
 		if oldId != openPull.ID {
 			changed = true
 		}
+		var review *meta.PullRequestReview
+		if !changed && branch.PullRequest != nil {
+			review = branch.PullRequest.Review
+		}
 		branch.PullRequest = &meta.PullRequest{
-			ID:        openPull.ID,
-			Number:    openPull.Number,
-			Permalink: openPull.Permalink,
-			State:     openPull.State,
+			ID:           openPull.ID,
+			Number:       openPull.Number,
+			Permalink:    openPull.Permalink,
+			State:        openPull.State,
+			InMergeQueue: openPull.IsInMergeQueue,
+			Title:        openPull.Title,
+			FetchedAt:    time.Now(),
+			Review:       review,
 		}
 		newPull = openPull
 	} else {
@@ -657,6 +880,8 @@ func UpdatePullRequestState(
 				Number:    currentPull.Number,
 				Permalink: currentPull.Permalink,
 				State:     currentPull.State,
+				Title:     currentPull.Title,
+				FetchedAt: time.Now(),
 			}
 		} else {
 			// openPull and currentPull is nil
@@ -687,6 +912,9 @@ const PRMetadataCommentEnd = "-->"
 const PRStackCommentStart = "<!-- av pr stack begin -->"
 const PRStackCommentEnd = "<!-- av pr stack end -->"
 
+const PRPreviewCommentStart = "<!-- av pr preview begin -->"
+const PRPreviewCommentEnd = "<!-- av pr preview end -->"
+
 // extractContent parses the given input and looks for the start and end
 // strings. It returns the content between the start and end strings and the
 // remaining input. If the start or end strings are not found, the content is
@@ -721,6 +949,7 @@ func ParsePRBody(input string) (body string, prMeta PRMetadata, retErr error) {
 	}
 
 	_, body = extractContent(body, PRStackCommentStart, PRStackCommentEnd)
+	_, body = extractContent(body, PRPreviewCommentStart, PRPreviewCommentEnd)
 
 	return
 }
@@ -760,6 +989,7 @@ func walkStack(tx meta.ReadTx, stack *stackutils.StackTreeNode, branchName strin
 			ssb.WriteString(strconv.FormatInt(bi.PullRequest.Number, 10))
 			ssb.WriteString("**")
 		}
+		writePreviewLink(&ssb, bi)
 		ssb.WriteString("\n")
 	}
 
@@ -791,6 +1021,7 @@ func walkStack(tx meta.ReadTx, stack *stackutils.StackTreeNode, branchName strin
 				ssb.WriteString(node.Branch.BranchName)
 				ssb.WriteString("`")
 			}
+			writePreviewLink(&ssb, bi)
 		}
 		ssb.WriteString("\n")
 
@@ -819,6 +1050,167 @@ func walkStack(tx meta.ReadTx, stack *stackutils.StackTreeNode, branchName strin
 	return ssb.String()
 }
 
+// writePreviewLink writes the link to the preview deployment of the branch in the stack list,
+// if any.
+func writePreviewLink(sb *strings.Builder, bi meta.Branch) {
+	if bi.PreviewURL == "" {
+		return
+	}
+	sb.WriteString(" ([preview](")
+	sb.WriteString(bi.PreviewURL)
+	sb.WriteString("))")
+}
+
+// PRStackEntry is a branch in the stack written to the pull requests.
+type PRStackEntry struct {
+	Branch string
+	// The position in the stack. The trunk branch is at 0.
+	Depth int
+	Trunk bool
+	// The number and the URL of the pull request. Zero and empty if the branch doesn't have
+	// a pull request.
+	Number int64
+	URL    string
+	// The URL of the preview deployment of the branch, if any.
+	PreviewURL string
+	// True for the branch of the pull request that the stack is written to.
+	Current bool
+}
+
+// PRStackTemplateData is the data for the pullRequest.stackTemplate config.
+type PRStackTemplateData struct {
+	// The branch of the pull request that the stack is written to.
+	Branch string
+	// The parent branch. Nil if the branch is based on the trunk branch.
+	Parent *PRStackEntry
+	// The branches of the stack from the trunk branch in depth-first order.
+	Entries []PRStackEntry
+	// The default Markdown list of the stack.
+	List string
+}
+
+// hasMultilevelStack returns true if the stack has more than one pull request in it. The stack
+// is not written otherwise.
+func hasMultilevelStack(stack *stackutils.StackTreeNode) bool {
+	return stack != nil && len(stack.Children) > 0 && len(stack.Children[0].Children) > 0
+}
+
+// stackSection returns the stack written to the pull request of the given branch, enclosed
+// with the PRStackComment markers.
+func stackSection(tx meta.ReadTx, branchName string, stack *stackutils.StackTreeNode) string {
+	sb := strings.Builder{}
+	sb.WriteString(PRStackCommentStart)
+	sb.WriteString("\n")
+	content, err := stackTemplateContent(tx, branchName, stack)
+	if err != nil {
+		logrus.WithError(err).Warn("failed to execute pullRequest.stackTemplate (using the default)")
+	}
+	if content != "" {
+		sb.WriteString(content)
+		sb.WriteString("\n")
+	} else {
+		sb.WriteString(defaultStackContent(tx, branchName, stack))
+	}
+	sb.WriteString(PRStackCommentEnd)
+	return sb.String()
+}
+
+// stackTemplateContent executes the pullRequest.stackTemplate config. It returns an empty
+// string if the template is not set.
+func stackTemplateContent(
+	tx meta.ReadTx,
+	branchName string,
+	stack *stackutils.StackTreeNode,
+) (string, error) {
+	if config.Av.PullRequest.StackTemplate == "" {
+		return "", nil
+	}
+	tmpl, err := template.New("stack").Parse(config.Av.PullRequest.StackTemplate)
+	if err != nil {
+		return "", err
+	}
+	data := PRStackTemplateData{
+		Branch: branchName,
+		List:   walkStack(tx, stack, branchName),
+	}
+	var visit func(node *stackutils.StackTreeNode, depth int)
+	visit = func(node *stackutils.StackTreeNode, depth int) {
+		bi, _ := tx.Branch(node.Branch.BranchName)
+		entry := PRStackEntry{
+			Branch:     node.Branch.BranchName,
+			Depth:      depth,
+			Trunk:      depth == 0,
+			PreviewURL: bi.PreviewURL,
+			Current:    node.Branch.BranchName == branchName,
+		}
+		if depth > 0 && bi.PullRequest != nil {
+			entry.Number = bi.PullRequest.Number
+			entry.URL = bi.PullRequest.Permalink
+		}
+		data.Entries = append(data.Entries, entry)
+		for _, child := range node.Children {
+			visit(child, depth+1)
+		}
+	}
+	visit(stack, 0)
+	if bi, _ := tx.Branch(branchName); !bi.Parent.Trunk {
+		for i := range data.Entries {
+			if data.Entries[i].Branch == bi.Parent.Name {
+				data.Parent = &data.Entries[i]
+			}
+		}
+	}
+	content, err := templateutils.String(tmpl, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(content), nil
+}
+
+// defaultStackContent returns the stack as a collapsible list of the pull requests.
+func defaultStackContent(tx meta.ReadTx, branchName string, stack *stackutils.StackTreeNode) string {
+	bi, _ := tx.Branch(branchName)
+	sb := strings.Builder{}
+	// Enclose this stack summary in a table for two reasons:
+	// 1. It actually looks nicer on GitHub
+	// 2. For the Slack GitHub integration, Slack doesn't support and strips out <table> elements in unfurls - we can avoid showing the stack in the unfurl.
+	sb.WriteString("<table><tr><td>")
+	sb.WriteString("<details><summary>")
+	if !bi.Parent.Trunk {
+		parentBi, _ := tx.Branch(bi.Parent.Name)
+		sb.WriteString("<b>Depends on #")
+		sb.WriteString(strconv.FormatInt(parentBi.PullRequest.Number, 10))
+		sb.WriteString(".</b> ")
+	}
+	sb.WriteString(
+		"This PR is part of a stack created with <a href=\"https://github.com/aviator-co/av\">Aviator</a>.",
+	)
+	sb.WriteString("</summary>")
+	sb.WriteString("\n\n")
+	sb.WriteString(walkStack(tx, stack, branchName))
+	sb.WriteString("</details>")
+	sb.WriteString("</td></tr></table>\n")
+	return sb.String()
+}
+
+// SyncStackComment writes the stack to the comment on the pull request of the given branch if
+// the stack is written to a comment (see config.PullRequest.StackLocation). The comment is
+// edited in place on the later updates.
+func SyncStackComment(
+	ctx context.Context,
+	f forge.Forge,
+	tx meta.ReadTx,
+	branchName string,
+	prID string,
+	stack *stackutils.StackTreeNode,
+) error {
+	if config.Av.PullRequest.StackLocation != config.StackLocationComment ||
+		!hasMultilevelStack(stack) {
+		return nil
+	}
+	return f.UpsertComment(ctx, prID, PRStackCommentStart, stackSection(tx, branchName, stack))
+}
+
 func AddPRMetadataAndStack(
 	body string,
 	prMeta PRMetadata,
@@ -834,34 +1226,19 @@ func AddPRMetadataAndStack(
 
 	sb := strings.Builder{}
 
-	// Don't write out a stack unless there is more than one PR in it.
-	hasMultilevelStack := stack != nil && len(stack.Children) > 0 &&
-		len(stack.Children[0].Children) > 0
-	if hasMultilevelStack {
-		bi, _ := tx.Branch(branchName)
-		stackString := walkStack(tx, stack, branchName)
-		sb.WriteString(PRStackCommentStart)
-
-		// Enclose this stack summary in a table for two reasons:
-		// 1. It actually looks nicer on GitHub
-		// 2. For the Slack GitHub integration, Slack doesn't support and strips out <table> elements in unfurls - we can avoid showing the stack in the unfurl.
-		sb.WriteString("\n<table><tr><td>")
-		sb.WriteString("<details><summary>")
-		if !bi.Parent.Trunk {
-			parentBi, _ := tx.Branch(bi.Parent.Name)
-			sb.WriteString("<b>Depends on #")
-			sb.WriteString(strconv.FormatInt(parentBi.PullRequest.Number, 10))
-			sb.WriteString(".</b> ")
-		}
-		sb.WriteString(
-			"This PR is part of a stack created with <a href=\"https://github.com/aviator-co/av\">Aviator</a>.",
-		)
-		sb.WriteString("</summary>")
+	// In the comment mode, the stack is written by SyncStackComment instead.
+	if config.Av.PullRequest.StackLocation != config.StackLocationComment &&
+		hasMultilevelStack(stack) {
+		sb.WriteString(stackSection(tx, branchName, stack))
 		sb.WriteString("\n\n")
-		sb.WriteString(stackString)
-		sb.WriteString("</details>")
-		sb.WriteString("</td></tr></table>\n")
-		sb.WriteString(PRStackCommentEnd)
+	}
+
+	if bi, _ := tx.Branch(branchName); bi.PreviewURL != "" {
+		sb.WriteString(PRPreviewCommentStart)
+		sb.WriteString("\n**Preview:** ")
+		sb.WriteString(bi.PreviewURL)
+		sb.WriteString("\n")
+		sb.WriteString(PRPreviewCommentEnd)
 		sb.WriteString("\n\n")
 	}
 
@@ -886,29 +1263,51 @@ func AddPRMetadataAndStack(
 	return sb.String()
 }
 
-// UpdatePullRequestWithStack updates the GitHub pull request associated with the given branch to include
+// UpdatePullRequestWithStack updates the pull request associated with the given branch to include
 // the stack of branches that the branch is a part of.
 // This should be called after all applicable PRs have been created to ensure we can properly link them.
 func UpdatePullRequestWithStack(
 	ctx context.Context,
-	client *gh.Client,
+	f forge.Forge,
 	tx meta.WriteTx,
 	branchName string,
 ) error {
-	branchMeta, _ := tx.Branch(branchName)
-	logrus.WithField("branch", branchName).
-		WithField("pr", branchMeta.PullRequest.ID).
-		Debug("Updating pull requests with stack")
-
-	repoMeta := tx.Repository()
-
 	// Don't sort based on the current branch so that the output is consistent between branches.
 	stackToWrite, err := stackutils.BuildStackTreeCurrentStack(tx, branchName, false)
 	if err != nil {
 		return err
 	}
+	return updatePullRequestBody(ctx, f, tx, branchName, stackToWrite)
+}
+
+// UpdatePullRequestBody rewrites the sections of the pull request body that are written by av
+// (e.g., the preview URL) for the given branch, without writing the stack.
+func UpdatePullRequestBody(
+	ctx context.Context,
+	f forge.Forge,
+	tx meta.ReadTx,
+	branchName string,
+) error {
+	return updatePullRequestBody(ctx, f, tx, branchName, nil)
+}
+
+func updatePullRequestBody(
+	ctx context.Context,
+	f forge.Forge,
+	tx meta.ReadTx,
+	branchName string,
+	stackToWrite *stackutils.StackTreeNode,
+) error {
+	branchMeta, _ := tx.Branch(branchName)
+	if branchMeta.IsFollowed() {
+		// The pull request is owned by someone else.
+		return nil
+	}
+	logrus.WithField("branch", branchName).
+		WithField("pr", branchMeta.PullRequest.ID).
+		Debug("Updating pull request body")
 
-	existingPR, err := getExistingOpenPR(ctx, client, repoMeta, branchMeta, branchName)
+	existingPR, err := getExistingOpenPR(ctx, f, branchMeta, branchName, nil)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -919,27 +1318,78 @@ func UpdatePullRequestWithStack(
 	}
 
 	newBody := AddPRMetadataAndStack(body, prMeta, branchName, stackToWrite, tx)
-	_, err = client.UpdatePullRequest(ctx, githubv4.UpdatePullRequestInput{
-		PullRequestID: existingPR.ID,
-		Body:          gh.Ptr(githubv4.String(newBody)),
+	_, err = f.UpdatePullRequest(ctx, forge.UpdatePullRequestInput{
+		ID:   existingPR.ID,
+		Body: &newBody,
 	})
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	return nil
+	return SyncStackComment(ctx, f, tx, branchName, existingPR.ID, stackToWrite)
+}
+
+// SyncPullRequestBody re-renders the sections of the pull request body of the given branch that
+// are written by av (the stack if config.PullRequest.WriteStack is set, the preview URL, and the
+// metadata) from the current branch metadata, keeping the rest of the body as is. Unlike
+// UpdatePullRequestBody, the metadata is recomputed too, so renamed parent branches are picked
+// up. The pull request is updated only if the body changes, and true is returned in that case.
+func SyncPullRequestBody(
+	ctx context.Context,
+	f forge.Forge,
+	tx meta.ReadTx,
+	branchName string,
+) (bool, error) {
+	branchMeta, _ := tx.Branch(branchName)
+	existingPR, err := getExistingOpenPR(ctx, f, branchMeta, branchName, nil)
+	if err != nil {
+		return false, err
+	}
+	if existingPR == nil {
+		return false, errors.Errorf("branch %q has no open pull request", branchName)
+	}
+	prMeta, err := getPRMetadata(tx, branchMeta, nil)
+	if err != nil {
+		return false, err
+	}
+	var stackToWrite *stackutils.StackTreeNode
+	if config.Av.PullRequest.WriteStack {
+		stackToWrite, err = stackutils.BuildStackTreeCurrentStack(tx, branchName, false)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// The blank lines around the sections written by av are trimmed so that re-rendering an
+	// up-to-date body doesn't change it.
+	_, body := extractContent(existingPR.Body, PRMetadataCommentStart, PRMetadataCommentEnd)
+	_, body = extractContent(body, PRStackCommentStart, PRStackCommentEnd)
+	_, body = extractContent(body, PRPreviewCommentStart, PRPreviewCommentEnd)
+	newBody := AddPRMetadataAndStack(
+		strings.TrimSpace(body), prMeta, branchName, stackToWrite, tx,
+	)
+	changed := newBody != existingPR.Body
+	if changed {
+		if _, err := f.UpdatePullRequest(ctx, forge.UpdatePullRequestInput{
+			ID:   existingPR.ID,
+			Body: &newBody,
+		}); err != nil {
+			return false, errors.WithStack(err)
+		}
+	}
+	return changed, SyncStackComment(ctx, f, tx, branchName, existingPR.ID, stackToWrite)
 }
 
-// UpdatePullRequestsWithStack updates the GitHub pull requests associated with the given branches to include
+// UpdatePullRequestsWithStack updates the pull requests associated with the given branches to include
 // the stack of branches that each branch is a part of.
 func UpdatePullRequestsWithStack(
 	ctx context.Context,
-	client *gh.Client,
+	f forge.Forge,
 	tx meta.WriteTx,
 	branchNames []string,
 ) error {
 	for _, branchName := range branchNames {
-		if err := UpdatePullRequestWithStack(ctx, client, tx, branchName); err != nil {
+		if err := UpdatePullRequestWithStack(ctx, f, tx, branchName); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,96 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+)
+
+// EnableAutoMerge enables auto-merge on the pull request of the branch with the merge method if
+// the branch is based on the trunk. Otherwise, merging the pull request would merge the branch
+// into its parent branch, so the merge method is recorded on the branch instead (see
+// meta.Branch.AutoMergeMethod) and EnablePendingAutoMerges enables it once the parents are
+// merged.
+func EnableAutoMerge(
+	ctx context.Context,
+	f forge.Forge,
+	tx meta.WriteTx,
+	branchName string,
+	method string,
+	out io.Writer,
+) error {
+	branch, ok := tx.Branch(branchName)
+	if !ok || branch.PullRequest == nil {
+		return nil
+	}
+	if !branch.Parent.Trunk {
+		if branch.AutoMergeMethod != method {
+			branch.AutoMergeMethod = method
+			tx.SetBranch(branch)
+		}
+		_, _ = fmt.Fprint(out,
+			"  - auto-merge of pull request ", colors.UserInput(branch.PullRequest.Permalink),
+			" will be enabled by av sync once its parent branches are merged\n",
+		)
+		return nil
+	}
+	if err := f.EnableAutoMerge(ctx, branch.PullRequest.ID, method); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprint(out,
+		"  - enabled auto-merge (", method, ") on pull request ",
+		colors.UserInput(branch.PullRequest.Permalink), "\n",
+	)
+	if branch.AutoMergeMethod != "" {
+		branch.AutoMergeMethod = ""
+		tx.SetBranch(branch)
+	}
+	return nil
+}
+
+// EnablePendingAutoMerges enables auto-merge on the pull requests recorded by EnableAutoMerge
+// once their branches are based on the trunk (e.g., after their parents are merged by av sync).
+// The pull requests that still target a merged parent are left for the next sync.
+func EnablePendingAutoMerges(ctx context.Context, f forge.Forge, db meta.DB, out io.Writer) error {
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+
+	for _, branch := range tx.AllBranches() {
+		if branch.AutoMergeMethod == "" || branch.PullRequest == nil {
+			continue
+		}
+		if branch.PullRequest.State != githubv4.PullRequestStateOpen {
+			branch.AutoMergeMethod = ""
+			tx.SetBranch(branch)
+			continue
+		}
+		if !branch.Parent.Trunk {
+			continue
+		}
+		pull, err := f.PullRequest(ctx, branch.PullRequest.ID)
+		if err != nil {
+			return err
+		}
+		if pull.BaseBranchName() != branch.Parent.Name {
+			continue
+		}
+		if err := f.EnableAutoMerge(ctx, pull.ID, branch.AutoMergeMethod); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprint(out,
+			"Enabled auto-merge (", branch.AutoMergeMethod, ") on pull request ",
+			colors.UserInput(branch.PullRequest.Permalink), "\n",
+		)
+		branch.AutoMergeMethod = ""
+		tx.SetBranch(branch)
+	}
+	cu.Cancel()
+	return tx.Commit()
+}
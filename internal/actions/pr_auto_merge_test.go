@@ -0,0 +1,108 @@
+package actions_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// autoMergeForge is a forge that records the pull requests that auto-merge is enabled on.
+type autoMergeForge struct {
+	forge.Forge
+	pulls     map[string]*gh.PullRequest
+	autoMerge map[string]string
+}
+
+func (f *autoMergeForge) PullRequest(_ context.Context, id string) (*gh.PullRequest, error) {
+	return f.pulls[id], nil
+}
+
+func (f *autoMergeForge) EnableAutoMerge(_ context.Context, id string, method string) error {
+	f.autoMerge[id] = method
+	return nil
+}
+
+func TestEnableAutoMerge(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	open := func(id string) *meta.PullRequest {
+		return &meta.PullRequest{ID: id, State: githubv4.PullRequestStateOpen}
+	}
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{
+		Name:        "one",
+		Parent:      meta.BranchState{Name: "main", Trunk: true},
+		PullRequest: open("PR_1"),
+	})
+	tx.SetBranch(meta.Branch{
+		Name:        "two",
+		Parent:      meta.BranchState{Name: "one"},
+		PullRequest: open("PR_2"),
+	})
+	require.NoError(t, tx.Commit())
+
+	f := &autoMergeForge{autoMerge: map[string]string{}}
+	tx = db.WriteTx()
+	for _, name := range []string{"one", "two"} {
+		require.NoError(
+			t, actions.EnableAutoMerge(context.Background(), f, tx, name, "squash", io.Discard),
+		)
+	}
+	require.NoError(t, tx.Commit())
+
+	// Only the pull request based on the trunk gets auto-merge, and the other one is pending.
+	assert.Equal(t, map[string]string{"PR_1": "squash"}, f.autoMerge)
+	one, _ := db.ReadTx().Branch("one")
+	assert.Equal(t, "", one.AutoMergeMethod)
+	two, _ := db.ReadTx().Branch("two")
+	assert.Equal(t, "squash", two.AutoMergeMethod)
+}
+
+func TestEnablePendingAutoMerges(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	open := func(id string) *meta.PullRequest {
+		return &meta.PullRequest{ID: id, State: githubv4.PullRequestStateOpen}
+	}
+	tx := db.WriteTx()
+	// main -> two -> three (one is merged and two is reparented onto main)
+	tx.SetBranch(meta.Branch{
+		Name:            "two",
+		Parent:          meta.BranchState{Name: "main", Trunk: true},
+		PullRequest:     open("PR_2"),
+		AutoMergeMethod: "rebase",
+	})
+	tx.SetBranch(meta.Branch{
+		Name:            "three",
+		Parent:          meta.BranchState{Name: "two"},
+		PullRequest:     open("PR_3"),
+		AutoMergeMethod: "rebase",
+	})
+	require.NoError(t, tx.Commit())
+
+	f := &autoMergeForge{
+		pulls: map[string]*gh.PullRequest{
+			"PR_2": {ID: "PR_2", BaseRefName: "main"},
+			"PR_3": {ID: "PR_3", BaseRefName: "two"},
+		},
+		autoMerge: map[string]string{},
+	}
+	require.NoError(t, actions.EnablePendingAutoMerges(context.Background(), f, db, io.Discard))
+	assert.Equal(t, map[string]string{"PR_2": "rebase"}, f.autoMerge)
+
+	two, _ := db.ReadTx().Branch("two")
+	assert.Equal(t, "", two.AutoMergeMethod)
+	three, _ := db.ReadTx().Branch("three")
+	assert.Equal(t, "rebase", three.AutoMergeMethod)
+}
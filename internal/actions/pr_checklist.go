@@ -0,0 +1,60 @@
+package actions
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+)
+
+const PRChecklistCommentStart = "<!-- av pr checklist begin -->"
+const PRChecklistCommentEnd = "<!-- av pr checklist end -->"
+
+var checkedChecklistItemPattern = regexp.MustCompile(`^\s*[-*] \[[xX]\]\s+(.*?)\s*$`)
+
+// AddPRChecklist adds the review checklist to the pull request body, replacing the checklist
+// added previously. An item is checked if the changed files match the item, or if it was
+// checked in the previous checklist (e.g., by the author or a reviewer).
+func AddPRChecklist(body string, items []config.ChecklistItem, changedFiles []string) string {
+	existing, body := extractContent(body, PRChecklistCommentStart, PRChecklistCommentEnd)
+	if len(items) == 0 {
+		return body
+	}
+	checked := map[string]bool{}
+	for _, line := range strings.Split(existing, "\n") {
+		if m := checkedChecklistItemPattern.FindStringSubmatch(line); m != nil {
+			checked[m[1]] = true
+		}
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(strings.TrimRight(body, "\n"))
+	sb.WriteString("\n\n")
+	sb.WriteString(PRChecklistCommentStart)
+	sb.WriteString("\n### Review checklist\n\n")
+	for _, item := range items {
+		if checked[item.Text] || item.Match(changedFiles) {
+			sb.WriteString("- [x] ")
+		} else {
+			sb.WriteString("- [ ] ")
+		}
+		sb.WriteString(item.Text)
+		sb.WriteString("\n")
+	}
+	sb.WriteString(PRChecklistCommentEnd)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// changedFiles returns the files changed in the branch since it diverged from the base.
+func changedFiles(repo *git.Repo, base string, branchName string) ([]string, error) {
+	out, err := repo.Git("diff", "--name-only", base+"..."+branchName)
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
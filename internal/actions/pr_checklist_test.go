@@ -0,0 +1,42 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddPRChecklist(t *testing.T) {
+	items := []config.ChecklistItem{
+		{Text: "Tests added", Paths: []string{"*_test.go"}},
+		{Text: "Docs updated", Paths: []string{"docs/*"}},
+		{Text: "Rollout plan reviewed"},
+	}
+
+	body := actions.AddPRChecklist(
+		"Fix the bug.",
+		items,
+		[]string{"internal/foo.go", "internal/foo_test.go"},
+	)
+	require.Equal(t, "Fix the bug.\n\n"+
+		actions.PRChecklistCommentStart+"\n"+
+		"### Review checklist\n\n"+
+		"- [x] Tests added\n"+
+		"- [ ] Docs updated\n"+
+		"- [ ] Rollout plan reviewed\n"+
+		actions.PRChecklistCommentEnd+"\n", body)
+
+	// The items checked by hand are kept, and the checklist is replaced rather than appended.
+	body = body[:len(body)-len("- [ ] Rollout plan reviewed\n"+actions.PRChecklistCommentEnd+"\n")] +
+		"- [X] Rollout plan reviewed\n" + actions.PRChecklistCommentEnd + "\n"
+	body = actions.AddPRChecklist(body, items, []string{"docs/usage.md"})
+	require.Equal(t, "Fix the bug.\n\n"+
+		actions.PRChecklistCommentStart+"\n"+
+		"### Review checklist\n\n"+
+		"- [x] Tests added\n"+
+		"- [x] Docs updated\n"+
+		"- [x] Rollout plan reviewed\n"+
+		actions.PRChecklistCommentEnd+"\n", body)
+}
@@ -0,0 +1,89 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+)
+
+// PullRequestDraftByDepth returns whether the pull request of the branch should be a draft
+// according to the draft rules and the position of the branch in the stack. The second return
+// value is false if no rule applies to the branch.
+func PullRequestDraftByDepth(
+	rules config.PullRequestDraftRules,
+	tx meta.ReadTx,
+	branchName string,
+) (draft bool, ok bool) {
+	if rules.DraftAfterDepth == 0 && !rules.RootReady {
+		return false, false
+	}
+	depth, err := BranchDepth(tx, branchName)
+	if err != nil {
+		return false, false
+	}
+	if depth == 1 && rules.RootReady {
+		return false, true
+	}
+	if rules.DraftAfterDepth == 0 {
+		return false, false
+	}
+	return depth > rules.DraftAfterDepth, true
+}
+
+// BranchDepth returns the position of the branch in the stack (the branch based on the trunk
+// is at position 1).
+func BranchDepth(tx meta.ReadTx, branchName string) (int, error) {
+	previous, err := meta.PreviousBranches(tx, branchName)
+	if err != nil {
+		return 0, err
+	}
+	return len(previous) + 1, nil
+}
+
+// PromoteDraftPullRequests marks the draft pull requests as ready for review once their
+// branches are at or below meta.Branch.ReadyAtDepth in the stack (e.g., after their parents are
+// merged by av sync). The pull requests that still target a merged parent are left for the
+// next sync.
+func PromoteDraftPullRequests(ctx context.Context, f forge.Forge, db meta.DB, out io.Writer) error {
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+
+	for _, branch := range tx.AllBranches() {
+		if branch.ReadyAtDepth == 0 || branch.PullRequest == nil ||
+			branch.PullRequest.State != githubv4.PullRequestStateOpen {
+			continue
+		}
+		depth, err := BranchDepth(tx, branch.Name)
+		if err != nil || depth > branch.ReadyAtDepth {
+			continue
+		}
+		pull, err := f.PullRequest(ctx, branch.PullRequest.ID)
+		if err != nil {
+			return err
+		}
+		if pull.BaseBranchName() != branch.Parent.Name {
+			continue
+		}
+		if pull.IsDraft {
+			if _, err := f.MarkPullRequestReadyForReview(ctx, pull.ID); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprint(out,
+				"Marked pull request ", colors.UserInput(branch.PullRequest.Permalink),
+				" as ready for review\n",
+			)
+		}
+		branch.ReadyAtDepth = 0
+		tx.SetBranch(branch)
+	}
+	cu.Cancel()
+	return tx.Commit()
+}
@@ -0,0 +1,123 @@
+package actions_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestDraftByDepth(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one"}})
+	tx.SetBranch(meta.Branch{Name: "three", Parent: meta.BranchState{Name: "two"}})
+	require.NoError(t, tx.Commit())
+
+	type result struct {
+		Draft bool
+		OK    bool
+	}
+	for _, tt := range []struct {
+		name  string
+		rules config.PullRequestDraftRules
+		want  map[string]result
+	}{
+		{
+			name:  "no rules",
+			rules: config.PullRequestDraftRules{},
+			want: map[string]result{
+				"one": {false, false}, "two": {false, false}, "three": {false, false},
+			},
+		},
+		{
+			name:  "draft after depth",
+			rules: config.PullRequestDraftRules{DraftAfterDepth: 2},
+			want: map[string]result{
+				"one": {false, true}, "two": {false, true}, "three": {true, true},
+			},
+		},
+		{
+			name:  "root ready",
+			rules: config.PullRequestDraftRules{RootReady: true},
+			want: map[string]result{
+				"one": {false, true}, "two": {false, false}, "three": {false, false},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for branch, want := range tt.want {
+				draft, ok := actions.PullRequestDraftByDepth(tt.rules, db.ReadTx(), branch)
+				assert.Equal(t, want, result{draft, ok}, branch)
+			}
+		})
+	}
+}
+
+// draftForge is a forge that only serves the draft pull requests.
+type draftForge struct {
+	forge.Forge
+	pulls map[string]*gh.PullRequest
+	ready []string
+}
+
+func (f *draftForge) PullRequest(_ context.Context, id string) (*gh.PullRequest, error) {
+	return f.pulls[id], nil
+}
+
+func (f *draftForge) MarkPullRequestReadyForReview(
+	_ context.Context,
+	id string,
+) (*gh.PullRequest, error) {
+	f.ready = append(f.ready, id)
+	f.pulls[id].IsDraft = false
+	return f.pulls[id], nil
+}
+
+func TestPromoteDraftPullRequests(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	open := func(id string) *meta.PullRequest {
+		return &meta.PullRequest{ID: id, State: githubv4.PullRequestStateOpen}
+	}
+	tx := db.WriteTx()
+	// main -> two -> three (one is merged and two is reparented onto main)
+	tx.SetBranch(meta.Branch{
+		Name:         "two",
+		Parent:       meta.BranchState{Name: "main", Trunk: true},
+		PullRequest:  open("PR_2"),
+		ReadyAtDepth: 1,
+	})
+	tx.SetBranch(meta.Branch{
+		Name:         "three",
+		Parent:       meta.BranchState{Name: "two"},
+		PullRequest:  open("PR_3"),
+		ReadyAtDepth: 1,
+	})
+	require.NoError(t, tx.Commit())
+
+	f := &draftForge{pulls: map[string]*gh.PullRequest{
+		"PR_2": {ID: "PR_2", BaseRefName: "main", IsDraft: true},
+		"PR_3": {ID: "PR_3", BaseRefName: "two", IsDraft: true},
+	}}
+	require.NoError(t, actions.PromoteDraftPullRequests(context.Background(), f, db, io.Discard))
+	require.Equal(t, []string{"PR_2"}, f.ready)
+
+	two, _ := db.ReadTx().Branch("two")
+	assert.Equal(t, 0, two.ReadyAtDepth)
+	three, _ := db.ReadTx().Branch("three")
+	assert.Equal(t, 1, three.ReadyAtDepth)
+}
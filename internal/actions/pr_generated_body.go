@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"regexp"
+	"strings"
+	"text/template"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/templateutils"
+)
+
+const PRGeneratedBodyCommentStart = "<!-- av pr generated begin -->"
+const PRGeneratedBodyCommentEnd = "<!-- av pr generated end -->"
+
+// The default pullRequest.generatedBody.template: the commit bodies.
+const defaultGeneratedBodyTemplate = "{{.Bodies}}"
+
+// PRBodyTemplateData is the data for the pullRequest.generatedBody.template config.
+type PRBodyTemplateData struct {
+	// The branch of the pull request.
+	Branch string
+	// The parent branch (the base branch of the pull request).
+	Parent string
+	// The ticket ID found in the branch name (e.g., "ABC-123"), if any. The
+	// pullRequest.title.ticketPattern config is used if it's set. The issue linked to the
+	// branch (see meta.Branch.Issue) takes precedence.
+	Ticket string
+	// The commits of the branch, oldest first.
+	Commits []git.CommitInfo
+	// The non-empty commit bodies, oldest first, separated by blank lines.
+	Bodies string
+}
+
+// NewPRBodyTemplateData returns the template data of the generated section.
+func NewPRBodyTemplateData(
+	branchName string,
+	parent string,
+	commits []git.CommitInfo,
+) (PRBodyTemplateData, error) {
+	data := PRBodyTemplateData{
+		Branch:  branchName,
+		Parent:  parent,
+		Commits: commits,
+	}
+	re := ticketPattern
+	if pattern := config.Av.PullRequest.Title.TicketPattern; pattern != "" {
+		var err error
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return data, errors.WrapIff(
+				err, "invalid pullRequest.title.ticketPattern config %q", pattern,
+			)
+		}
+	}
+	data.Ticket = re.FindString(branchName)
+	var bodies []string
+	for _, commit := range commits {
+		if body := strings.TrimSpace(commit.Body); body != "" {
+			bodies = append(bodies, body)
+		}
+	}
+	data.Bodies = strings.Join(bodies, "\n\n")
+	return data, nil
+}
+
+// AddPRGeneratedBody adds the section generated from the commits to the pull request body.
+// The section generated previously is replaced in place, so the rest of the body (e.g., the
+// edits by the author) is kept as it is. A new section is added at the top of the body.
+func AddPRGeneratedBody(body string, tmplText string, data PRBodyTemplateData) (string, error) {
+	if tmplText == "" {
+		tmplText = defaultGeneratedBodyTemplate
+	}
+	tmpl, err := template.New("generatedBody").Parse(tmplText)
+	if err != nil {
+		return "", errors.WrapIf(err, "invalid pullRequest.generatedBody.template config")
+	}
+	content, err := templateutils.String(tmpl, data)
+	if err != nil {
+		return "", errors.WrapIf(err, "failed to execute pullRequest.generatedBody.template")
+	}
+	section := PRGeneratedBodyCommentStart + "\n"
+	if content = strings.TrimSpace(content); content != "" {
+		section += content + "\n"
+	}
+	section += PRGeneratedBodyCommentEnd
+
+	if start := strings.Index(body, PRGeneratedBodyCommentStart); start != -1 {
+		if end := strings.Index(body[start:], PRGeneratedBodyCommentEnd); end != -1 {
+			end += start + len(PRGeneratedBodyCommentEnd)
+			return body[:start] + section + body[end:], nil
+		}
+	}
+	if strings.TrimSpace(body) == "" {
+		return section + "\n", nil
+	}
+	return section + "\n\n" + body, nil
+}
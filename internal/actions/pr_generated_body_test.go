@@ -0,0 +1,76 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPRBodyTemplateData(t *testing.T) {
+	commits := []git.CommitInfo{
+		{Subject: "Add the schema", Body: "The schema of the table.\n"},
+		{Subject: "Fix a typo"},
+		{Subject: "Add the migration", Body: "The migration.\n\nRun it twice."},
+	}
+	data, err := actions.NewPRBodyTemplateData("alice/ENG-12-schema", "main", commits)
+	require.NoError(t, err)
+	require.Equal(t, "ENG-12", data.Ticket)
+	require.Equal(t, "main", data.Parent)
+	require.Equal(t, "The schema of the table.\n\nThe migration.\n\nRun it twice.", data.Bodies)
+
+	orig := config.Av.PullRequest.Title.TicketPattern
+	config.Av.PullRequest.Title.TicketPattern = "#[0-9]+"
+	defer func() { config.Av.PullRequest.Title.TicketPattern = orig }()
+	data, err = actions.NewPRBodyTemplateData("fix-#42", "main", commits)
+	require.NoError(t, err)
+	require.Equal(t, "#42", data.Ticket)
+}
+
+func TestAddPRGeneratedBody(t *testing.T) {
+	data := actions.PRBodyTemplateData{
+		Branch: "ENG-12-schema",
+		Ticket: "ENG-12",
+		Commits: []git.CommitInfo{
+			{Subject: "Add the schema", Body: "The schema."},
+		},
+		Bodies: "The schema.",
+	}
+
+	// A new section is added at the top.
+	body, err := actions.AddPRGeneratedBody("", "", data)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		actions.PRGeneratedBodyCommentStart+"\nThe schema.\n"+actions.PRGeneratedBodyCommentEnd+"\n",
+		body,
+	)
+	body, err = actions.AddPRGeneratedBody("## Test plan\n\nRan it.\n", "", data)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		actions.PRGeneratedBodyCommentStart+"\nThe schema.\n"+actions.PRGeneratedBodyCommentEnd+
+			"\n\n## Test plan\n\nRan it.\n",
+		body,
+	)
+
+	// The existing section is replaced in place, and the manual edits around it are kept.
+	edited := "Intro by the author.\n\n" + body + "\nMore notes.\n"
+	data.Bodies = "The schema and the migration."
+	tmpl := "Ticket: {{.Ticket}}\n\n{{range .Commits}}- {{.Subject}}\n{{end}}\n{{.Bodies}}"
+	data.Commits = append(data.Commits, git.CommitInfo{Subject: "Add the migration"})
+	body, err = actions.AddPRGeneratedBody(edited, tmpl, data)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		"Intro by the author.\n\n"+actions.PRGeneratedBodyCommentStart+
+			"\nTicket: ENG-12\n\n- Add the schema\n- Add the migration\n\nThe schema and the migration.\n"+
+			actions.PRGeneratedBodyCommentEnd+"\n\n## Test plan\n\nRan it.\n\nMore notes.\n",
+		body,
+	)
+
+	_, err = actions.AddPRGeneratedBody("", "{{.Unknown}}", data)
+	require.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package actions
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/utils/colors"
+)
+
+var (
+	markdownHeadingPattern = regexp.MustCompile(`^ {0,3}#{1,6}\s+(.*?)\s*#*\s*$`)
+	htmlCommentPattern     = regexp.MustCompile(`(?s)<!--.*?-->`)
+)
+
+// MissingPullRequestSections returns the required sections that are missing or empty in the
+// pull request body. A section is a Markdown heading whose text matches the section name
+// (case-insensitively, ignoring a trailing colon), and it is empty if it has nothing but HTML
+// comments (e.g., the hints of a pull request template) until the next heading.
+func MissingPullRequestSections(required []string, body string) []string {
+	contents := map[string]string{}
+	var current string
+	inSection := false
+	for _, line := range strings.Split(htmlCommentPattern.ReplaceAllString(body, ""), "\n") {
+		if m := markdownHeadingPattern.FindStringSubmatch(line); m != nil {
+			current = normalizeSectionName(m[1])
+			inSection = true
+			if _, ok := contents[current]; !ok {
+				contents[current] = ""
+			}
+			continue
+		}
+		if inSection {
+			contents[current] += strings.TrimSpace(line)
+		}
+	}
+
+	var missing []string
+	for _, section := range required {
+		if contents[normalizeSectionName(section)] == "" {
+			missing = append(missing, section)
+		}
+	}
+	return missing
+}
+
+// FillPullRequestSection adds the content to the section of the pull request body. The
+// content is inserted right after the section heading, or a new section is appended to the
+// body if there's no such heading.
+func FillPullRequestSection(body string, section string, content string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		m := markdownHeadingPattern.FindStringSubmatch(line)
+		if m == nil || normalizeSectionName(m[1]) != normalizeSectionName(section) {
+			continue
+		}
+		inserted := append([]string{}, lines[:i+1]...)
+		inserted = append(inserted, "", content)
+		inserted = append(inserted, lines[i+1:]...)
+		return strings.Join(inserted, "\n")
+	}
+	body = strings.TrimRight(body, "\n")
+	if body != "" {
+		body += "\n\n"
+	}
+	return body + "## " + section + "\n\n" + content + "\n"
+}
+
+// PromptPullRequestSections asks the user to fill in each of the missing sections of the pull
+// request body. An empty answer aborts.
+func PromptPullRequestSections(
+	in io.Reader,
+	out io.Writer,
+	body string,
+	missing []string,
+) (string, error) {
+	reader := bufio.NewReader(in)
+	for _, section := range missing {
+		_, _ = fmt.Fprint(out,
+			colors.Warning("The pull request description has no "), colors.UserInput(section),
+			colors.Warning(" section. "), colors.UserInput(section+": "),
+		)
+		answer, err := reader.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			if err != nil && !errors.Is(err, io.EOF) {
+				return "", err
+			}
+			return "", errors.Errorf("the %q section of the pull request description is required", section)
+		}
+		body = FillPullRequestSection(body, section, answer)
+	}
+	return body, nil
+}
+
+func normalizeSectionName(s string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(s), ":"))
+}
@@ -0,0 +1,65 @@
+package actions_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissingPullRequestSections(t *testing.T) {
+	required := []string{"Test Plan", "Risk"}
+	for _, tt := range []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "no sections",
+			body: "Fix the bug.",
+			want: []string{"Test Plan", "Risk"},
+		},
+		{
+			name: "template stubs",
+			body: "## Test Plan\n<!-- How did you test this? -->\n\n## Risk:\n\n",
+			want: []string{"Test Plan", "Risk"},
+		},
+		{
+			name: "filled",
+			body: "Fix the bug.\n\n### test plan\nUnit tests.\n\n## Risk ##\nLow.\n",
+			want: nil,
+		},
+		{
+			name: "partially filled",
+			body: "# Test Plan\nUnit tests.\n# Risk\n<!--\nWhat can go wrong?\n-->\n",
+			want: []string{"Risk"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, actions.MissingPullRequestSections(required, tt.body))
+		})
+	}
+}
+
+func TestPromptPullRequestSections(t *testing.T) {
+	body := "Fix the bug.\n\n## Test Plan\n<!-- How did you test this? -->\n"
+	missing := actions.MissingPullRequestSections([]string{"Test Plan", "Risk"}, body)
+
+	var out bytes.Buffer
+	filled, err := actions.PromptPullRequestSections(
+		strings.NewReader("Unit tests.\nLow.\n"), &out, body, missing,
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"Fix the bug.\n\n## Test Plan\n\nUnit tests.\n<!-- How did you test this? -->\n\n## Risk\n\nLow.\n",
+		filled,
+	)
+	assert.Empty(t, actions.MissingPullRequestSections([]string{"Test Plan", "Risk"}, filled))
+
+	_, err = actions.PromptPullRequestSections(strings.NewReader("\n"), &out, body, missing)
+	require.Error(t, err)
+}
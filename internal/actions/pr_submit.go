@@ -0,0 +1,223 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/progress"
+)
+
+// PrefetchPullRequests queries the pull requests of the branches that have one at once, so that
+// submitting the branches doesn't query them one by one. The result is keyed by the branch
+// name.
+func PrefetchPullRequests(
+	ctx context.Context,
+	f forge.Forge,
+	tx meta.ReadTx,
+	branches []string,
+) (map[string]*gh.PullRequest, error) {
+	var ids []string
+	branchByID := map[string]string{}
+	for _, name := range branches {
+		br, _ := tx.Branch(name)
+		if br.PullRequest != nil && br.PullRequest.ID != "" {
+			ids = append(ids, br.PullRequest.ID)
+			branchByID[br.PullRequest.ID] = name
+		}
+	}
+	ret := map[string]*gh.PullRequest{}
+	if len(ids) == 0 {
+		return ret, nil
+	}
+	pulls, err := f.PullRequests(ctx, ids)
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to query the existing pull requests")
+	}
+	for i := range pulls {
+		if name, ok := branchByID[pulls[i].ID]; ok {
+			ret[name] = &pulls[i]
+		}
+	}
+	return ret, nil
+}
+
+type SubmitPullRequestsOpts struct {
+	// The maximum number of the branches submitted at the same time. The branches are
+	// submitted one by one if this is less than 2.
+	Concurrency int
+	// Returns true if submitting the branch can prompt the user (e.g., the editor for a new
+	// pull request). These branches are submitted one at a time after the output of the
+	// branches before them is written, and they write to Output directly so that their output
+	// (e.g., the header that names the branch) comes before the prompt. The output of the other
+	// branches is held while they prompt.
+	Interactive func(branch string) bool
+	// Where the output of the branches is written. Defaults to stderr.
+	Output io.Writer
+}
+
+// errSubmitSkipped is the error of the branches that are not submitted because another branch
+// failed.
+var errSubmitSkipped = errors.Sentinel("skipped")
+
+// SubmitPullRequests calls submit for each of the branches (in the stack order) with up to
+// opts.Concurrency branches at the same time. A branch waits for its parent branch only if the
+// parent doesn't have a pull request yet, since the pull request of a branch is based on the
+// pull request of its parent.
+//
+// The submit function gets a transaction that is safe to use concurrently and the writer for
+// its output. The outputs are written in the order of the branches. After a branch fails, the
+// branches that are not started yet are skipped, and the first error is returned.
+func SubmitPullRequests(
+	tx meta.WriteTx,
+	branches []string,
+	opts SubmitPullRequestsOpts,
+	submit func(tx meta.WriteTx, branch string, out io.Writer) error,
+) error {
+	output := opts.Output
+	if output == nil {
+		output = os.Stderr
+	}
+	type job struct {
+		branch      string
+		interactive bool
+		wait        *job
+		// Closed when the outputs of the branches before this one are written.
+		turn chan struct{}
+		done chan struct{}
+		out  bytes.Buffer
+		err  error
+	}
+	jobs := make([]*job, len(branches))
+	byName := map[string]*job{}
+	for i, name := range branches {
+		jobs[i] = &job{
+			branch:      name,
+			interactive: opts.Interactive != nil && opts.Interactive(name),
+			turn:        make(chan struct{}),
+			done:        make(chan struct{}),
+		}
+		byName[name] = jobs[i]
+	}
+	// The dependencies are read before any branch is submitted.
+	for _, j := range jobs {
+		br, _ := tx.Branch(j.branch)
+		if br.Parent.Trunk {
+			continue
+		}
+		if parent, ok := tx.Branch(br.Parent.Name); ok && parent.PullRequest == nil {
+			j.wait = byName[br.Parent.Name]
+		}
+	}
+
+	sem := make(chan struct{}, max(opts.Concurrency, 1))
+	// Held while a branch can prompt the user so that nothing else is written to the terminal.
+	var terminalMu sync.Mutex
+	var failed atomic.Bool
+	ltx := &lockedWriteTx{tx: tx}
+	for _, j := range jobs {
+		go func() {
+			defer close(j.done)
+			if j.wait != nil {
+				<-j.wait.done
+			}
+			if j.interactive {
+				// Wait before taking a slot so that the branches before this one can run.
+				<-j.turn
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if failed.Load() {
+				j.err = errSubmitSkipped
+				return
+			}
+			var out io.Writer = &j.out
+			if j.interactive {
+				terminalMu.Lock()
+				defer terminalMu.Unlock()
+				out = output
+			}
+			if err := submit(ltx, j.branch, out); err != nil {
+				j.err = err
+				failed.Store(true)
+			}
+		}()
+	}
+
+	var reterr error
+	for i, j := range jobs {
+		progress.Report(progress.PhaseSubmit, j.branch, i, len(jobs))
+		close(j.turn)
+		<-j.done
+		terminalMu.Lock()
+		_, _ = output.Write(j.out.Bytes())
+		terminalMu.Unlock()
+		if reterr == nil && j.err != nil && !errors.Is(j.err, errSubmitSkipped) {
+			reterr = j.err
+		}
+	}
+	return reterr
+}
+
+// lockedWriteTx is a WriteTx that can be used from multiple goroutines.
+type lockedWriteTx struct {
+	mu sync.Mutex
+	tx meta.WriteTx
+}
+
+var _ meta.WriteTx = &lockedWriteTx{}
+
+func (t *lockedWriteTx) Repository() meta.Repository {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tx.Repository()
+}
+
+func (t *lockedWriteTx) Branch(name string) (meta.Branch, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tx.Branch(name)
+}
+
+func (t *lockedWriteTx) AllBranches() map[string]meta.Branch {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tx.AllBranches()
+}
+
+func (t *lockedWriteTx) Abort() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tx.Abort()
+}
+
+func (t *lockedWriteTx) Commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tx.Commit()
+}
+
+func (t *lockedWriteTx) SetBranch(branch meta.Branch) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tx.SetBranch(branch)
+}
+
+func (t *lockedWriteTx) DeleteBranch(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tx.DeleteBranch(name)
+}
+
+func (t *lockedWriteTx) SetRepository(repository meta.Repository) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tx.SetRepository(repository)
+}
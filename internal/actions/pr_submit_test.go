@@ -0,0 +1,157 @@
+package actions_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitPullRequests(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{
+		Name:        "one",
+		Parent:      meta.BranchState{Name: "main", Trunk: true},
+		PullRequest: &meta.PullRequest{Number: 1},
+	})
+	tx.SetBranch(meta.Branch{
+		Name:        "two",
+		Parent:      meta.BranchState{Name: "one"},
+		PullRequest: &meta.PullRequest{Number: 2},
+	})
+	tx.SetBranch(meta.Branch{Name: "three", Parent: meta.BranchState{Name: "two"}})
+	tx.SetBranch(meta.Branch{Name: "four", Parent: meta.BranchState{Name: "three"}})
+	require.NoError(t, tx.Commit())
+
+	tx = db.WriteTx()
+	defer tx.Abort()
+	var mu sync.Mutex
+	finished := map[string]bool{}
+	var running, maxRunning atomic.Int32
+	var out bytes.Buffer
+	err := actions.SubmitPullRequests(
+		tx, []string{"one", "two", "three", "four"},
+		actions.SubmitPullRequestsOpts{Concurrency: 2, Output: &out},
+		func(tx meta.WriteTx, branch string, out io.Writer) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			br, _ := tx.Branch(branch)
+			if br.Parent.Name == "three" {
+				// The pull request of the parent branch is created first.
+				mu.Lock()
+				assert.True(t, finished["three"])
+				mu.Unlock()
+				parent, _ := tx.Branch("three")
+				assert.NotNil(t, parent.PullRequest)
+			}
+			time.Sleep(10 * time.Millisecond)
+			if br.PullRequest == nil {
+				br.PullRequest = &meta.PullRequest{Number: 3}
+				tx.SetBranch(br)
+			}
+			_, _ = fmt.Fprintf(out, "submitted %s\n", branch)
+			mu.Lock()
+			finished[branch] = true
+			mu.Unlock()
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+	assert.Equal(
+		t,
+		"submitted one\nsubmitted two\nsubmitted three\nsubmitted four\n",
+		out.String(),
+	)
+	for _, name := range []string{"three", "four"} {
+		br, _ := tx.Branch(name)
+		assert.NotNil(t, br.PullRequest, name)
+	}
+}
+
+func TestSubmitPullRequestsError(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one"}})
+	require.NoError(t, tx.Commit())
+
+	tx = db.WriteTx()
+	defer tx.Abort()
+	var submitted []string
+	var out bytes.Buffer
+	err := actions.SubmitPullRequests(
+		tx, []string{"one", "two"},
+		actions.SubmitPullRequestsOpts{Concurrency: 4, Output: &out},
+		func(tx meta.WriteTx, branch string, out io.Writer) error {
+			submitted = append(submitted, branch)
+			_, _ = fmt.Fprintf(out, "failed %s\n", branch)
+			return errors.New("boom")
+		},
+	)
+	require.EqualError(t, err, "boom")
+	// The child branch waits for the pull request of the parent branch, so it's skipped.
+	assert.Equal(t, []string{"one"}, submitted)
+	assert.Equal(t, "failed one\n", out.String())
+}
+
+func TestSubmitPullRequestsInteractive(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{
+		Name:        "one",
+		Parent:      meta.BranchState{Name: "main", Trunk: true},
+		PullRequest: &meta.PullRequest{Number: 1},
+	})
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one"}})
+	require.NoError(t, tx.Commit())
+
+	tx = db.WriteTx()
+	defer tx.Abort()
+	var out bytes.Buffer
+	err := actions.SubmitPullRequests(
+		tx, []string{"one", "two"},
+		actions.SubmitPullRequestsOpts{
+			Concurrency: 2,
+			Output:      &out,
+			Interactive: func(branch string) bool { return branch == "two" },
+		},
+		func(tx meta.WriteTx, branch string, w io.Writer) error {
+			if branch == "one" {
+				time.Sleep(10 * time.Millisecond)
+			} else {
+				// The interactive branch writes to the output directly after the output of
+				// the branches before it.
+				assert.Same(t, &out, w)
+				assert.Equal(t, "submitted one\n", out.String())
+			}
+			_, _ = fmt.Fprintf(w, "submitted %s\n", branch)
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "submitted one\nsubmitted two\n", out.String())
+}
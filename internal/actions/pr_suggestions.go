@@ -0,0 +1,149 @@
+package actions
+
+import (
+	"slices"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+)
+
+type SubmitSuggestionKind string
+
+const (
+	// The branch doesn't touch the files changed by its parent branch, so it may not depend on
+	// the parent and can be moved onto the grandparent to be reviewed in parallel.
+	SubmitSuggestionParallel SubmitSuggestionKind = "parallel"
+	// The branch is much larger than the other branches and may be worth splitting.
+	SubmitSuggestionSplit SubmitSuggestionKind = "split"
+)
+
+// SubmitSuggestion is a suggestion to reorder or split the stack before submitting it.
+type SubmitSuggestion struct {
+	Kind   SubmitSuggestionKind
+	Branch string
+	// The parent branch of the branch.
+	Parent string
+	// The suggested new parent of the branch (SubmitSuggestionParallel only).
+	NewParent string
+	// The number of the changed lines of the branch (SubmitSuggestionSplit only).
+	Lines int
+}
+
+// BranchBase returns the commit that the commits of the branch are on top of. For the branch
+// based on a non-trunk branch, this is the parent commit recorded in the last restack, so that
+// the commits of the parent are not included even if the parent has moved since then.
+func BranchBase(repo *git.Repo, branch meta.Branch) (string, error) {
+	if branch.Parent.Trunk {
+		return repo.MergeBase(branch.Parent.Name, branch.Name)
+	}
+	if branch.Parent.Head != "" {
+		return branch.Parent.Head, nil
+	}
+	return repo.RevParse(&git.RevParse{Rev: branch.Parent.Name})
+}
+
+// SubmitSuggestions analyzes the diffs of the given branches (in the stack order) and returns
+// the suggestions to move the branches that don't depend on their parents and to split the
+// branches that change more than largeLines lines and more than twice the average of the
+// other branches. The split suggestions are disabled if largeLines is zero.
+//
+// The dependency is guessed from the changed files: a branch that doesn't change any file
+// that its parent changes is suggested to be moved onto its grandparent.
+func SubmitSuggestions(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	branchNames []string,
+	largeLines int,
+) ([]SubmitSuggestion, error) {
+	files := map[string][]string{}
+	lines := map[string]int{}
+	for _, name := range branchNames {
+		if err := branchDiffStats(repo, tx, name, files, lines); err != nil {
+			return nil, err
+		}
+	}
+
+	var ret []SubmitSuggestion
+	for _, name := range branchNames {
+		branch, _ := tx.Branch(name)
+		if branch.Parent.Trunk || branch.Parent.Name == "" || len(files[name]) == 0 {
+			continue
+		}
+		parent, ok := tx.Branch(branch.Parent.Name)
+		if !ok || parent.Parent.Name == "" {
+			continue
+		}
+		if _, ok := files[parent.Name]; !ok {
+			if err := branchDiffStats(repo, tx, parent.Name, files, lines); err != nil {
+				return nil, err
+			}
+		}
+		if len(files[parent.Name]) == 0 || slices.ContainsFunc(files[name], func(f string) bool {
+			return slices.Contains(files[parent.Name], f)
+		}) {
+			continue
+		}
+		ret = append(ret, SubmitSuggestion{
+			Kind:      SubmitSuggestionParallel,
+			Branch:    name,
+			Parent:    parent.Name,
+			NewParent: parent.Parent.Name,
+		})
+	}
+
+	if largeLines > 0 {
+		total := 0
+		for _, name := range branchNames {
+			total += lines[name]
+		}
+		for _, name := range branchNames {
+			if lines[name] <= largeLines {
+				continue
+			}
+			if len(branchNames) > 1 {
+				average := (total - lines[name]) / (len(branchNames) - 1)
+				if lines[name] <= 2*average {
+					continue
+				}
+			}
+			branch, _ := tx.Branch(name)
+			ret = append(ret, SubmitSuggestion{
+				Kind:   SubmitSuggestionSplit,
+				Branch: name,
+				Parent: branch.Parent.Name,
+				Lines:  lines[name],
+			})
+		}
+	}
+	return ret, nil
+}
+
+// branchDiffStats records the files and the number of the lines changed by the commits of the
+// branch.
+func branchDiffStats(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	name string,
+	files map[string][]string,
+	lines map[string]int,
+) error {
+	branch, ok := tx.Branch(name)
+	if !ok || branch.Parent.Name == "" {
+		files[name] = nil
+		return nil
+	}
+	base, err := BranchBase(repo, branch)
+	if err != nil {
+		return err
+	}
+	stats, err := repo.DiffStats(base, name)
+	if err != nil {
+		return err
+	}
+	files[name] = []string{}
+	for _, stat := range stats {
+		files[name] = append(files[name], stat.Path)
+		lines[name] += stat.Additions + stat.Deletions
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package actions_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitSuggestions(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+	avRepo := repo.AsAvGitRepo()
+
+	// main -> one (a.txt) -> two (a.txt) -> three (b.txt, large)
+	head := func() string { return strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD")) }
+	repo.Git(t, "checkout", "-b", "one")
+	repo.CommitFile(t, "a.txt", "one\n")
+	oneHead := head()
+	repo.Git(t, "checkout", "-b", "two")
+	repo.CommitFile(t, "a.txt", "one\ntwo\n")
+	twoHead := head()
+	repo.Git(t, "checkout", "-b", "three")
+	repo.CommitFile(t, "b.txt", strings.Repeat("three\n", 30))
+
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one", Head: oneHead}})
+	tx.SetBranch(meta.Branch{Name: "three", Parent: meta.BranchState{Name: "two", Head: twoHead}})
+	require.NoError(t, tx.Commit())
+
+	branches := []string{"one", "two", "three"}
+	suggestions, err := actions.SubmitSuggestions(avRepo, db.ReadTx(), branches, 20)
+	require.NoError(t, err)
+	assert.Equal(t, []actions.SubmitSuggestion{
+		{
+			Kind:      actions.SubmitSuggestionParallel,
+			Branch:    "three",
+			Parent:    "two",
+			NewParent: "one",
+		},
+		{
+			Kind:   actions.SubmitSuggestionSplit,
+			Branch: "three",
+			Parent: "two",
+			Lines:  30,
+		},
+	}, suggestions)
+
+	// The split suggestions are disabled with zero, and the branches under the threshold are
+	// not suggested to be split.
+	for _, largeLines := range []int{0, 30} {
+		suggestions, err = actions.SubmitSuggestions(avRepo, db.ReadTx(), branches, largeLines)
+		require.NoError(t, err)
+		require.Len(t, suggestions, 1)
+		assert.Equal(t, actions.SubmitSuggestionParallel, suggestions[0].Kind)
+	}
+}
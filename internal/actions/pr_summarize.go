@@ -0,0 +1,46 @@
+package actions
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/stringutils"
+	"github.com/sirupsen/logrus"
+)
+
+// SummarizeBranch runs the summarizer command (see config.PullRequest.Summarizer) to suggest
+// the title and the body of the pull request of the branch. The command gets the diff of the
+// branch from the base (e.g., "origin/main" or the parent branch) on stdin, and the branch and
+// its parent in $AV_BRANCH and $AV_PARENT. It prints the title on the first line followed by
+// the body.
+func SummarizeBranch(repo *git.Repo, branch, parent, base string) (title, body string, _ error) {
+	command := config.Av.PullRequest.Summarizer
+	if command == "" {
+		return "", "", errors.New("the pullRequest.summarizer config is not set")
+	}
+	diff, err := repo.Git("diff", base+"..."+branch)
+	if err != nil {
+		return "", "", errors.WrapIff(err, "failed to get the diff of %q", branch)
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = repo.Dir()
+	cmd.Env = append(os.Environ(), "AV_BRANCH="+branch, "AV_PARENT="+parent)
+	cmd.Stdin = strings.NewReader(diff + "\n")
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	logrus.WithField("args", cmd.Args).Debug("running the summarizer")
+	if err := cmd.Run(); err != nil {
+		return "", "", errors.WrapIff(err, "summarizer %q failed", command)
+	}
+	title, body = stringutils.ParseSubjectBody(stdout.String())
+	if strings.TrimSpace(title) == "" {
+		return "", "", errors.Errorf("summarizer %q printed no title", command)
+	}
+	return strings.TrimSpace(title), body, nil
+}
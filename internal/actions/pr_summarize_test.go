@@ -0,0 +1,35 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeBranch(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	repo.Git(t, "checkout", "-b", "one")
+	repo.CommitFile(t, "one.txt", "hello summarizer")
+
+	orig := config.Av.PullRequest.Summarizer
+	defer func() { config.Av.PullRequest.Summarizer = orig }()
+
+	// The summarizer gets the diff on stdin and the branches in the environment.
+	config.Av.PullRequest.Summarizer = `grep -q '^+hello summarizer$' && ` +
+		`printf 'Add one to %s\n\nBased on %s.\n' "$AV_BRANCH" "$AV_PARENT"`
+	title, body, err := actions.SummarizeBranch(repo.AsAvGitRepo(), "one", "main", "main")
+	require.NoError(t, err)
+	require.Equal(t, "Add one to one", title)
+	require.Equal(t, "Based on main.", body)
+
+	config.Av.PullRequest.Summarizer = "true"
+	_, _, err = actions.SummarizeBranch(repo.AsAvGitRepo(), "one", "main", "main")
+	require.ErrorContains(t, err, "printed no title")
+
+	config.Av.PullRequest.Summarizer = "exit 1"
+	_, _, err = actions.SummarizeBranch(repo.AsAvGitRepo(), "one", "main", "main")
+	require.ErrorContains(t, err, `summarizer "exit 1" failed`)
+}
@@ -1,9 +1,12 @@
 package actions_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/forge"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/maputils"
 	"github.com/aviator-co/av/internal/utils/stackutils"
@@ -246,6 +249,58 @@ This information is embedded by the av CLI when creating PRs to track the status
 `, body1)
 }
 
+func TestPRWithPreviewURL(t *testing.T) {
+	tx := fakeReadTx{
+		"baz": {
+			Name:        "baz",
+			Parent:      meta.BranchState{Name: "main", Trunk: true},
+			PullRequest: &meta.PullRequest{Number: 1001},
+			PreviewURL:  "https://baz.preview.example.com",
+		},
+		"foo": {
+			Name:        "foo",
+			Parent:      meta.BranchState{Name: "baz"},
+			PullRequest: &meta.PullRequest{Number: 1002},
+			PreviewURL:  "https://foo.preview.example.com",
+		},
+	}
+	stack := &stackutils.StackTreeNode{
+		Branch: &stackutils.StackTreeBranchInfo{BranchName: "main"},
+		Children: []*stackutils.StackTreeNode{
+			{
+				Branch: &stackutils.StackTreeBranchInfo{BranchName: "baz"},
+				Children: []*stackutils.StackTreeNode{
+					{Branch: &stackutils.StackTreeBranchInfo{BranchName: "foo"}},
+				},
+			},
+		},
+	}
+
+	body := actions.AddPRMetadataAndStack(
+		"Hello! This is a cool PR that does some neat things.",
+		actions.PRMetadata{Parent: "baz", Trunk: "main"},
+		"foo",
+		stack,
+		tx,
+	)
+	assert.Contains(t, body, "* ➡️ **#1002** ([preview](https://foo.preview.example.com))\n")
+	assert.Contains(t, body, "* **#1001** ([preview](https://baz.preview.example.com))\n")
+	assert.Contains(t, body, actions.PRPreviewCommentStart+
+		"\n**Preview:** https://foo.preview.example.com\n"+
+		actions.PRPreviewCommentEnd+
+		"\n\nHello!")
+
+	// The preview section is replaced when the body is written again.
+	foo := tx["foo"]
+	foo.PreviewURL = ""
+	tx["foo"] = foo
+	body, _, err := actions.ParsePRBody(
+		actions.AddPRMetadataAndStack(body, actions.PRMetadata{}, "foo", nil, tx),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello! This is a cool PR that does some neat things.", body)
+}
+
 type fakeReadTx map[string]meta.Branch
 
 func (tx fakeReadTx) Repository() meta.Repository {
@@ -263,3 +318,100 @@ func (tx fakeReadTx) Branch(name string) (meta.Branch, bool) {
 func (tx fakeReadTx) AllBranches() map[string]meta.Branch {
 	return maputils.Copy(tx)
 }
+
+// twoLevelStack returns the stack of main -> baz (#1001) -> foo (#1002).
+func twoLevelStack() (fakeReadTx, *stackutils.StackTreeNode) {
+	tx := fakeReadTx{
+		"baz": {
+			Name:   "baz",
+			Parent: meta.BranchState{Name: "main", Trunk: true},
+			PullRequest: &meta.PullRequest{
+				Number:    1001,
+				Permalink: "https://github.com/org/repo/pull/1001",
+			},
+		},
+		"foo": {
+			Name:   "foo",
+			Parent: meta.BranchState{Name: "baz"},
+			PullRequest: &meta.PullRequest{
+				Number:    1002,
+				Permalink: "https://github.com/org/repo/pull/1002",
+			},
+		},
+	}
+	stack := &stackutils.StackTreeNode{
+		Branch: &stackutils.StackTreeBranchInfo{BranchName: "main"},
+		Children: []*stackutils.StackTreeNode{{
+			Branch: &stackutils.StackTreeBranchInfo{BranchName: "baz"},
+			Children: []*stackutils.StackTreeNode{{
+				Branch: &stackutils.StackTreeBranchInfo{BranchName: "foo"},
+			}},
+		}},
+	}
+	return tx, stack
+}
+
+func TestPRWithStackTemplate(t *testing.T) {
+	orig := config.Av.PullRequest
+	t.Cleanup(func() { config.Av.PullRequest = orig })
+	config.Av.PullRequest.StackTemplate = `
+Stack (after #{{ .Parent.Number }}):
+{{ range .Entries }}{{ if not .Trunk }}- {{ if .Current }}👉 {{ end }}[{{ .Branch }}]({{ .URL }})
+{{ end }}{{ end }}`
+	tx, stack := twoLevelStack()
+
+	body := actions.AddPRMetadataAndStack("Hello!", actions.PRMetadata{}, "foo", stack, tx)
+	assert.Contains(t, body, `<!-- av pr stack begin -->
+Stack (after #1001):
+- [baz](https://github.com/org/repo/pull/1001)
+- 👉 [foo](https://github.com/org/repo/pull/1002)
+<!-- av pr stack end -->
+
+Hello!
+`)
+
+	// Editing the body outside of the markers survives the updates.
+	body = "Intro\n" + body + "\nOutro"
+	config.Av.PullRequest.StackTemplate = "{{ .List }}"
+	body = actions.AddPRMetadataAndStack(body, actions.PRMetadata{}, "foo", stack, tx)
+	assert.Contains(t, body, `<!-- av pr stack begin -->
+* ➡️ **#1002**
+* **#1001**
+* `+"`"+`main`+"`"+`
+<!-- av pr stack end -->
+
+Intro
+Hello!`)
+	assert.Contains(t, body, "Outro")
+	assert.NotContains(t, body, "Stack (after")
+}
+
+// commentForge is a forge that only records the upserted comments.
+type commentForge struct {
+	forge.Forge
+	comments map[string]string
+}
+
+func (f *commentForge) UpsertComment(_ context.Context, id, marker, body string) error {
+	f.comments[id] = body
+	return nil
+}
+
+func TestSyncStackComment(t *testing.T) {
+	orig := config.Av.PullRequest
+	t.Cleanup(func() { config.Av.PullRequest = orig })
+	tx, stack := twoLevelStack()
+	f := &commentForge{comments: map[string]string{}}
+	ctx := context.Background()
+
+	// The stack is written to the body by default.
+	require.NoError(t, actions.SyncStackComment(ctx, f, tx, "foo", "pr-foo", stack))
+	assert.Empty(t, f.comments)
+
+	config.Av.PullRequest.StackLocation = config.StackLocationComment
+	body := actions.AddPRMetadataAndStack("Hello!", actions.PRMetadata{}, "foo", stack, tx)
+	assert.NotContains(t, body, actions.PRStackCommentStart)
+	require.NoError(t, actions.SyncStackComment(ctx, f, tx, "foo", "pr-foo", stack))
+	assert.Contains(t, f.comments["pr-foo"], actions.PRStackCommentStart)
+	assert.Contains(t, f.comments["pr-foo"], "* ➡️ **#1002**")
+}
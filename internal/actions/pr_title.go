@@ -0,0 +1,97 @@
+package actions
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+)
+
+const defaultTicketFormat = "[%s] "
+
+// GeneratePullRequestTitle generates a pull request title from the commit subject by applying
+// the configured title transformations.
+func GeneratePullRequestTitle(
+	rules config.PullRequestTitle,
+	branchName string,
+	subject string,
+) (string, error) {
+	title := strings.TrimSpace(subject)
+	for _, prefix := range rules.StripPrefixes {
+		if prefix != "" && strings.HasPrefix(title, prefix) {
+			title = strings.TrimSpace(strings.TrimPrefix(title, prefix))
+			break
+		}
+	}
+
+	if rules.SentenceCase {
+		if r, size := utf8.DecodeRuneInString(title); r != utf8.RuneError {
+			title = string(unicode.ToUpper(r)) + title[size:]
+		}
+	}
+
+	if rules.TicketPattern != "" {
+		re, err := regexp.Compile(rules.TicketPattern)
+		if err != nil {
+			return "", errors.WrapIff(
+				err,
+				"invalid pullRequest.title.ticketPattern config %q",
+				rules.TicketPattern,
+			)
+		}
+		if ticket := re.FindString(branchName); ticket != "" && !strings.Contains(title, ticket) {
+			format := rules.TicketFormat
+			if format == "" {
+				format = defaultTicketFormat
+			}
+			title = strings.ReplaceAll(format, "%s", ticket) + title
+		}
+	}
+	return title, nil
+}
+
+// PreviewPullRequestTitle returns the title that would be generated for a new pull request
+// of the given branch.
+func PreviewPullRequestTitle(repo *git.Repo, tx meta.ReadTx, branchName string) (string, error) {
+	branch, _ := tx.Branch(branchName)
+	compareRef := branch.Parent.Name
+	if branch.Parent.Name == "" || branch.Parent.Trunk {
+		trunk := branch.Parent.Name
+		if trunk == "" {
+			var err error
+			trunk, err = repo.DefaultBranch()
+			if err != nil {
+				return "", errors.WrapIf(err, "failed to determine default branch")
+			}
+		}
+		compareRef = repo.RemoteBranchRef(trunk)
+	}
+
+	commitsList, err := repo.Git(
+		"rev-list",
+		"--reverse",
+		fmt.Sprintf("%s..%s", compareRef, branchName),
+	)
+	if err != nil {
+		return "", errors.WrapIf(err, "failed to determine commits to include in PR")
+	}
+	if commitsList == "" {
+		return "", errors.Errorf("no commits between %q and %q", compareRef, branchName)
+	}
+	firstCommit, _, _ := strings.Cut(commitsList, "\n")
+	commit, err := repo.CommitInfo(git.CommitInfoOpts{Rev: firstCommit})
+	if err != nil {
+		return "", errors.WrapIff(err, "failed to get commit info for %q", firstCommit)
+	}
+	title, err := GeneratePullRequestTitle(config.Av.PullRequest.Title, branchName, commit.Subject)
+	if err != nil {
+		return "", err
+	}
+	return AddIssueToSubject(title, branch.Issue), nil
+}
@@ -0,0 +1,46 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratePullRequestTitle(t *testing.T) {
+	rules := config.PullRequestTitle{
+		StripPrefixes: []string{"feature/", "wip:"},
+		SentenceCase:  true,
+		TicketPattern: "[A-Z]+-[0-9]+",
+	}
+	for _, tt := range []struct {
+		name    string
+		rules   config.PullRequestTitle
+		branch  string
+		subject string
+		want    string
+	}{
+		{"no rules", config.PullRequestTitle{}, "ENG-12-foo", "feature/add foo", "feature/add foo"},
+		{"all rules", rules, "ENG-12-foo", "feature/add foo", "[ENG-12] Add foo"},
+		{"strip with space", rules, "foo", "wip: fix bar", "Fix bar"},
+		{"ticket already in title", rules, "ENG-12-foo", "ENG-12: add foo", "ENG-12: add foo"},
+		{
+			"custom ticket format",
+			config.PullRequestTitle{TicketPattern: "[A-Z]+-[0-9]+", TicketFormat: "%s: "},
+			"user/ENG-7-bar", "add bar", "ENG-7: add bar",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := actions.GeneratePullRequestTitle(tt.rules, tt.branch, tt.subject)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := actions.GeneratePullRequestTitle(
+		config.PullRequestTitle{TicketPattern: "("}, "foo", "add foo",
+	)
+	require.Error(t, err)
+}
@@ -0,0 +1,240 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/ghutils"
+	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+)
+
+// PullRequestTriage is the reviewers (user logins or "@org/team" names), the labels, and the
+// assignees (user logins, or "@me" for the author of the pull request) to add to the pull
+// requests.
+type PullRequestTriage struct {
+	Reviewers []string
+	Labels    []string
+	Assignees []string
+	// If true, the code owners of the files changed in each branch are requested as reviewers
+	// of its pull request too (see ForBranch).
+	Codeowners bool
+	// The code owners to request as reviewers, set by ForBranch. Unlike Reviewers, a failure
+	// to request them is only a warning.
+	CodeownersReviewers []string
+}
+
+// NewPullRequestTriage returns the defaults of config.Av.PullRequest combined with the given
+// values (e.g., from the command line flags).
+func NewPullRequestTriage(reviewers, labels, assignees []string) PullRequestTriage {
+	return PullRequestTriage{
+		Reviewers: mergeNames(config.Av.PullRequest.Reviewers, reviewers),
+		Labels:    mergeNames(config.Av.PullRequest.Labels, labels),
+		Assignees: mergeNames(config.Av.PullRequest.Assignees, assignees),
+	}
+}
+
+// IsEmpty returns true if there's nothing to add.
+func (t PullRequestTriage) IsEmpty() bool {
+	return len(t.Reviewers) == 0 && len(t.Labels) == 0 && len(t.Assignees) == 0 &&
+		len(t.CodeownersReviewers) == 0
+}
+
+// ForBranch returns the triage of the pull request of the branch. If Codeowners is set, the code
+// owners of the files changed in the branch are set to CodeownersReviewers. The CODEOWNERS file
+// is read from the remote trunk branch, the same as GitHub does. The code owners are skipped
+// with a warning if they can't be determined.
+func (t PullRequestTriage) ForBranch(repo *git.Repo, branch meta.Branch, out io.Writer) PullRequestTriage {
+	if !t.Codeowners {
+		return t
+	}
+	co, err := readTrunkCodeowners(repo)
+	if err != nil {
+		_, _ = fmt.Fprint(out, colors.Warning("  - skipping the code owners: "+err.Error()+"\n"))
+		return t
+	}
+	if co == nil {
+		return t
+	}
+	base := branch.Parent.Name
+	if branch.Parent.Trunk {
+		base = repo.RemoteBranchRef(base)
+	}
+	files, err := changedFiles(repo, base, branch.Name)
+	if err != nil {
+		logrus.WithError(err).Debugf("failed to determine the changed files of %q", branch.Name)
+		return t
+	}
+	t.CodeownersReviewers = CodeownersReviewers(co, files)
+	return t
+}
+
+// readTrunkCodeowners reads the CODEOWNERS file of the remote trunk branch (or of the local one if
+// it's not fetched).
+func readTrunkCodeowners(repo *git.Repo) (*ghutils.Codeowners, error) {
+	trunk, err := repo.DefaultBranch()
+	if err != nil {
+		return nil, err
+	}
+	rev := repo.RemoteBranchRef(trunk)
+	if _, err := repo.RevParse(&git.RevParse{Rev: rev}); err != nil {
+		rev = trunk
+	}
+	return ghutils.ReadCodeownersAt(repo, rev)
+}
+
+// CodeownersReviewers returns the code owners of the files that can be requested as reviewers:
+// the user logins and the "@org/team" names. The owners given by email addresses are skipped.
+func CodeownersReviewers(co *ghutils.Codeowners, files []string) []string {
+	var ret []string
+	for _, file := range files {
+		for _, owner := range co.Owners(file) {
+			if !strings.HasPrefix(owner, "@") {
+				continue
+			}
+			if ok, _, _ := isTeamName(owner); !ok {
+				owner = strings.TrimPrefix(owner, "@")
+			}
+			ret = append(ret, owner)
+		}
+	}
+	return mergeNames(ret)
+}
+
+func mergeNames(lists ...[]string) []string {
+	var ret []string
+	for _, list := range lists {
+		for _, name := range list {
+			name = strings.TrimSpace(name)
+			if name != "" && !containsFold(ret, name) {
+				ret = append(ret, name)
+			}
+		}
+	}
+	return ret
+}
+
+func containsFold(names []string, name string) bool {
+	return slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, name) })
+}
+
+// SyncPullRequestTriage adds the reviewers, the labels, and the assignees that the pull request
+// doesn't have yet. The reviews are not requested again from the users who are already
+// requested or who already reviewed the pull request, nor from the author. Nothing is removed
+// from the pull request, so the reviewers and the labels that are added on GitHub are kept.
+func SyncPullRequestTriage(
+	ctx context.Context,
+	client *gh.Client,
+	repository meta.Repository,
+	prID string,
+	triage PullRequestTriage,
+	out io.Writer,
+) error {
+	if triage.IsEmpty() {
+		return nil
+	}
+	current, err := client.PullRequestTriage(ctx, prID)
+	if err != nil {
+		return err
+	}
+
+	newReviewers := func(names []string) []string {
+		var ret []string
+		for _, reviewer := range names {
+			name := strings.TrimPrefix(reviewer, "@")
+			if strings.EqualFold(name, current.Author) ||
+				containsFold(current.RequestedReviewers, name) ||
+				containsFold(current.Reviewers, name) {
+				continue
+			}
+			ret = append(ret, reviewer)
+		}
+		return ret
+	}
+	reviewers := newReviewers(triage.Reviewers)
+	if len(reviewers) > 0 {
+		_, _ = fmt.Fprint(out,
+			"  - adding ", colors.UserInput(len(reviewers)), " reviewer(s) to pull request\n",
+		)
+		if err := requestReviews(ctx, client, prID, reviewers); err != nil {
+			return err
+		}
+	}
+	var codeowners []string
+	for _, reviewer := range newReviewers(triage.CodeownersReviewers) {
+		if !containsFold(reviewers, reviewer) {
+			codeowners = append(codeowners, reviewer)
+		}
+	}
+	if len(codeowners) > 0 {
+		_, _ = fmt.Fprint(out,
+			"  - requesting ", colors.UserInput(len(codeowners)), " code owner(s) as reviewers\n",
+		)
+		// A code owner may not be able to review (e.g., a team without access to the
+		// repository), which shouldn't fail the submit.
+		if err := requestReviews(ctx, client, prID, codeowners); err != nil {
+			_, _ = fmt.Fprint(out,
+				colors.Warning("  - failed to request the code owners as reviewers: "+err.Error()+"\n"),
+			)
+		}
+	}
+
+	var labelIDs []githubv4.ID
+	for _, name := range triage.Labels {
+		if containsFold(current.Labels, name) {
+			continue
+		}
+		label, err := client.RepositoryLabel(ctx, repository.Owner, repository.Name, name)
+		if err != nil {
+			return err
+		}
+		labelIDs = append(labelIDs, label.ID)
+	}
+	if len(labelIDs) > 0 {
+		_, _ = fmt.Fprint(out,
+			"  - adding ", colors.UserInput(len(labelIDs)), " label(s) to pull request\n",
+		)
+		if err := client.AddLabels(ctx, githubv4.AddLabelsToLabelableInput{
+			LabelableID: prID,
+			LabelIDs:    labelIDs,
+		}); err != nil {
+			return err
+		}
+	}
+
+	var assigneeIDs []githubv4.ID
+	for _, login := range triage.Assignees {
+		if login == "@me" {
+			login = current.Author
+		}
+		login = strings.TrimPrefix(login, "@")
+		if containsFold(current.Assignees, login) {
+			continue
+		}
+		user, err := client.User(ctx, login)
+		if err != nil {
+			return err
+		}
+		assigneeIDs = append(assigneeIDs, user.ID)
+	}
+	if len(assigneeIDs) > 0 {
+		_, _ = fmt.Fprint(out,
+			"  - adding ", colors.UserInput(len(assigneeIDs)), " assignee(s) to pull request\n",
+		)
+		if err := client.AddAssignees(ctx, githubv4.AddAssigneesToAssignableInput{
+			AssignableID: prID,
+			AssigneeIDs:  assigneeIDs,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
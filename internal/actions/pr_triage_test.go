@@ -0,0 +1,169 @@
+package actions_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/ghutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPullRequestTriage(t *testing.T) {
+	orig := config.Av.PullRequest
+	t.Cleanup(func() { config.Av.PullRequest = orig })
+	config.Av.PullRequest.Reviewers = []string{"alice", "@org/team"}
+	config.Av.PullRequest.Labels = []string{"stacked"}
+
+	triage := actions.NewPullRequestTriage([]string{"Alice", "bob"}, nil, []string{"@me"})
+	require.Equal(t, actions.PullRequestTriage{
+		Reviewers: []string{"alice", "@org/team", "bob"},
+		Labels:    []string{"stacked"},
+		Assignees: []string{"@me"},
+	}, triage)
+	require.False(t, actions.NewPullRequestTriage(nil, nil, nil).IsEmpty())
+
+	config.Av.PullRequest = orig
+	require.True(t, actions.NewPullRequestTriage(nil, nil, nil).IsEmpty())
+}
+
+func TestSyncPullRequestTriage(t *testing.T) {
+	var mutations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &req))
+		var resp string
+		switch {
+		case strings.HasPrefix(req.Query, "mutation"):
+			mutations = append(mutations, string(body))
+			resp = `{"data": {}}`
+		case strings.Contains(req.Query, "reviewRequests"):
+			resp = `{"data": {"node": {
+				"id": "PR_1",
+				"author": {"login": "me"},
+				"reviewRequests": {"nodes": [{"requestedReviewer": {"login": "alice"}}]},
+				"latestReviews": {"nodes": [{"author": {"login": "bob"}}]},
+				"labels": {"nodes": [{"name": "stacked"}]},
+				"assignees": {"nodes": []}
+			}}}`
+		case strings.Contains(req.Query, "label(name: $name)"):
+			resp = `{"data": {"repository": {"label": {"id": "LABEL_` + req.Variables["name"].(string) + `"}}}}`
+		case strings.Contains(req.Query, "user(login: $login)"):
+			resp = `{"data": {"user": {"id": "USER_` + req.Variables["login"].(string) + `"}}}`
+		default:
+			t.Errorf("unexpected query: %s", req.Query)
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	defer server.Close()
+	orig := config.Av.GitHub
+	t.Cleanup(func() { config.Av.GitHub = orig })
+	config.Av.GitHub.GraphQLURL = server.URL
+
+	client, err := gh.NewClient("token")
+	require.NoError(t, err)
+	require.NoError(t, actions.SyncPullRequestTriage(
+		context.Background(), client, meta.Repository{Owner: "owner", Name: "repo"}, "PR_1",
+		actions.PullRequestTriage{
+			// alice is already requested, bob already reviewed, and me is the author.
+			Reviewers: []string{"alice", "bob", "me", "carol"},
+			Labels:    []string{"stacked", "backend"},
+			Assignees: []string{"@me"},
+		},
+		io.Discard,
+	))
+	require.Len(t, mutations, 3)
+	require.Contains(t, mutations[0], "requestReviews")
+	require.Contains(t, mutations[0], "USER_carol")
+	require.NotContains(t, mutations[0], "USER_alice")
+	require.Contains(t, mutations[1], "addLabelsToLabelable")
+	require.Contains(t, mutations[1], "LABEL_backend")
+	require.NotContains(t, mutations[1], "LABEL_stacked")
+	require.Contains(t, mutations[2], "addAssigneesToAssignable")
+	require.Contains(t, mutations[2], "USER_me")
+}
+
+func TestCodeownersReviewers(t *testing.T) {
+	co, err := ghutils.ParseCodeowners(strings.NewReader(`
+* @org/core
+/api/ @alice @org/backend
+docs/ docs@example.com
+/api/generated/
+`))
+	require.NoError(t, err)
+
+	require.Equal(t,
+		[]string{"alice", "@org/backend", "@org/core"},
+		actions.CodeownersReviewers(co, []string{
+			"api/server.go", "api/generated/types.go", "docs/index.md", "README.md",
+		}),
+	)
+	require.Empty(t, actions.CodeownersReviewers(co, []string{"api/generated/types.go"}))
+}
+
+func TestSyncPullRequestTriageCodeownersFailure(t *testing.T) {
+	var mutations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		require.NoError(t, json.Unmarshal(body, &req))
+		var resp string
+		switch {
+		case strings.HasPrefix(req.Query, "mutation"):
+			mutations = append(mutations, string(body))
+			resp = `{"data": {}}`
+		case strings.Contains(req.Query, "reviewRequests"):
+			resp = `{"data": {"node": {
+				"id": "PR_1",
+				"author": {"login": "me"},
+				"reviewRequests": {"nodes": []},
+				"latestReviews": {"nodes": []},
+				"labels": {"nodes": []},
+				"assignees": {"nodes": []}
+			}}}`
+		case strings.Contains(req.Query, "organization(login: $organizationLogin)"):
+			resp = `{"data": null, "errors": [{"message": "Could not resolve to an Organization"}]}`
+		case strings.Contains(req.Query, "user(login: $login)"):
+			resp = `{"data": {"user": {"id": "USER_` + req.Variables["login"].(string) + `"}}}`
+		default:
+			t.Errorf("unexpected query: %s", req.Query)
+		}
+		_, _ = w.Write([]byte(resp))
+	}))
+	defer server.Close()
+	orig := config.Av.GitHub
+	t.Cleanup(func() { config.Av.GitHub = orig })
+	config.Av.GitHub.GraphQLURL = server.URL
+
+	client, err := gh.NewClient("token")
+	require.NoError(t, err)
+	var out strings.Builder
+	require.NoError(t, actions.SyncPullRequestTriage(
+		context.Background(), client, meta.Repository{Owner: "owner", Name: "repo"}, "PR_1",
+		actions.PullRequestTriage{
+			Reviewers:           []string{"carol"},
+			CodeownersReviewers: []string{"carol", "@org/missing"},
+		},
+		&out,
+	))
+	// The explicit reviewers are requested, and the failure to request the code owners is a
+	// warning.
+	require.Len(t, mutations, 1)
+	require.Contains(t, mutations[0], "USER_carol")
+	require.Contains(t, out.String(), "failed to request the code owners as reviewers")
+}
@@ -0,0 +1,148 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/shurcooL/githubv4"
+)
+
+// AddPullRequestToProject adds the given pull request to the GitHub project configured with
+// pullRequest.project and sets the configured field values.
+func AddPullRequestToProject(
+	ctx context.Context,
+	client *gh.Client,
+	repoMeta meta.Repository,
+	prID githubv4.ID,
+) error {
+	projectConfig := config.Av.PullRequest.Project
+	owner := projectConfig.Owner
+	if owner == "" {
+		owner = repoMeta.Owner
+	}
+	project, err := client.ProjectV2(ctx, owner, projectConfig.Number)
+	if err != nil {
+		return err
+	}
+
+	itemID, err := client.AddProjectV2Item(ctx, githubv4.AddProjectV2ItemByIdInput{
+		ProjectID: project.ID,
+		ContentID: prID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for name, value := range projectConfig.Fields {
+		fieldID, fieldValue, err := projectFieldValue(project, name, value, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := client.UpdateProjectV2ItemFieldValue(ctx, githubv4.UpdateProjectV2ItemFieldValueInput{
+			ProjectID: project.ID,
+			ItemID:    itemID,
+			FieldID:   fieldID,
+			Value:     fieldValue,
+		}); err != nil {
+			return errors.WrapIff(err, "failed to set field %q of project %q", name, project.Title)
+		}
+	}
+
+	_, _ = fmt.Fprint(os.Stderr,
+		"  - added pull request to project ", colors.UserInput(project.Title), "\n",
+	)
+	return nil
+}
+
+// projectFieldValue returns the ID and the value of the named project field for the configured
+// value. Field names are compared case-insensitively since the config keys are lower-cased.
+func projectFieldValue(
+	project *gh.ProjectV2,
+	name string,
+	value string,
+	now time.Time,
+) (githubv4.ID, githubv4.ProjectV2FieldValue, error) {
+	for _, field := range project.Fields.Nodes {
+		switch field.Typename {
+		case "ProjectV2SingleSelectField":
+			f := field.SingleSelectField
+			if !strings.EqualFold(f.Name, name) {
+				continue
+			}
+			for _, option := range f.Options {
+				if strings.EqualFold(option.Name, value) {
+					return f.ID, githubv4.ProjectV2FieldValue{
+						SingleSelectOptionID: gh.Ptr(githubv4.String(option.ID)),
+					}, nil
+				}
+			}
+			return nil, githubv4.ProjectV2FieldValue{}, errors.Errorf(
+				"project field %q does not have option %q", f.Name, value,
+			)
+		case "ProjectV2IterationField":
+			f := field.IterationField
+			if !strings.EqualFold(f.Name, name) {
+				continue
+			}
+			for _, iteration := range f.Configuration.Iterations {
+				if isProjectIteration(iteration, value, now) {
+					return f.ID, githubv4.ProjectV2FieldValue{
+						IterationID: gh.Ptr(githubv4.String(iteration.ID)),
+					}, nil
+				}
+			}
+			return nil, githubv4.ProjectV2FieldValue{}, errors.Errorf(
+				"project field %q does not have iteration %q", f.Name, value,
+			)
+		case "ProjectV2Field":
+			f := field.Field
+			if !strings.EqualFold(f.Name, name) {
+				continue
+			}
+			switch f.DataType {
+			case githubv4.ProjectV2FieldTypeText:
+				return f.ID, githubv4.ProjectV2FieldValue{
+					Text: gh.Ptr(githubv4.String(value)),
+				}, nil
+			case githubv4.ProjectV2FieldTypeNumber:
+				n, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, githubv4.ProjectV2FieldValue{}, errors.Errorf(
+						"project field %q takes a number, got %q", f.Name, value,
+					)
+				}
+				return f.ID, githubv4.ProjectV2FieldValue{
+					Number: gh.Ptr(githubv4.Float(n)),
+				}, nil
+			default:
+				return nil, githubv4.ProjectV2FieldValue{}, errors.Errorf(
+					"project field %q of type %s is not supported", f.Name, f.DataType,
+				)
+			}
+		}
+	}
+	return nil, githubv4.ProjectV2FieldValue{}, errors.Errorf(
+		"project %q does not have field %q", project.Title, name,
+	)
+}
+
+func isProjectIteration(iteration gh.ProjectV2Iteration, value string, now time.Time) bool {
+	if value != "@current" {
+		return strings.EqualFold(iteration.Title, value)
+	}
+	start, err := time.ParseInLocation(time.DateOnly, iteration.StartDate, now.Location())
+	if err != nil {
+		return false
+	}
+	end := start.AddDate(0, 0, iteration.Duration)
+	return !now.Before(start) && now.Before(end)
+}
@@ -0,0 +1,62 @@
+package actions
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectFieldValue(t *testing.T) {
+	var project gh.ProjectV2
+	project.Title = "Planning"
+	var status, iteration, points gh.ProjectV2Field
+	status.Typename = "ProjectV2SingleSelectField"
+	status.SingleSelectField.ID = "status-id"
+	status.SingleSelectField.Name = "Status"
+	require.NoError(t, json.Unmarshal(
+		[]byte(`[{"id": "todo", "name": "Todo"}, {"id": "review", "name": "In review"}]`),
+		&status.SingleSelectField.Options,
+	))
+	iteration.Typename = "ProjectV2IterationField"
+	iteration.IterationField.ID = "iteration-id"
+	iteration.IterationField.Name = "Iteration"
+	iteration.IterationField.Configuration.Iterations = []gh.ProjectV2Iteration{
+		{ID: "it-1", Title: "Iteration 1", StartDate: "2024-01-01", Duration: 14},
+		{ID: "it-2", Title: "Iteration 2", StartDate: "2024-01-15", Duration: 14},
+	}
+	points.Typename = "ProjectV2Field"
+	points.Field.ID = "points-id"
+	points.Field.Name = "Points"
+	points.Field.DataType = githubv4.ProjectV2FieldTypeNumber
+	project.Fields.Nodes = []gh.ProjectV2Field{status, iteration, points}
+
+	now := time.Date(2024, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	id, value, err := projectFieldValue(&project, "status", "in review", now)
+	require.NoError(t, err)
+	assert.Equal(t, githubv4.ID("status-id"), id)
+	assert.Equal(t, githubv4.String("review"), *value.SingleSelectOptionID)
+
+	id, value, err = projectFieldValue(&project, "iteration", "@current", now)
+	require.NoError(t, err)
+	assert.Equal(t, githubv4.ID("iteration-id"), id)
+	assert.Equal(t, githubv4.String("it-2"), *value.IterationID)
+
+	_, value, err = projectFieldValue(&project, "iteration", "Iteration 1", now)
+	require.NoError(t, err)
+	assert.Equal(t, githubv4.String("it-1"), *value.IterationID)
+
+	_, value, err = projectFieldValue(&project, "points", "3", now)
+	require.NoError(t, err)
+	assert.Equal(t, githubv4.Float(3), *value.Number)
+
+	_, _, err = projectFieldValue(&project, "status", "Done", now)
+	require.Error(t, err)
+	_, _, err = projectFieldValue(&project, "priority", "High", now)
+	require.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/aviator-co/av/internal/git"
+)
+
+// RemoteMovedError is returned when a remote branch is not at the commit that av expects before
+// a force push (e.g., a teammate pushed to it), so that the push would discard their commits.
+type RemoteMovedError struct {
+	Branch string
+	Remote string
+	// The last-known commit of the remote branch.
+	Expected string
+	// The commit of the remote branch as of the last fetch. Empty if unknown.
+	Actual string
+}
+
+func (e RemoteMovedError) Error() string {
+	msg := fmt.Sprintf(
+		"the remote branch %s/%s moved unexpectedly (expected %s",
+		e.Remote, e.Branch, git.ShortSha(e.Expected),
+	)
+	if e.Actual != "" {
+		msg += ", found " + git.ShortSha(e.Actual)
+	}
+	return msg + "); someone else may have pushed to it. " +
+		"Review the remote commits (git log " + e.Branch + ".." + git.RemoteTrackingRef(e.Remote, e.Branch) +
+		"), bring the ones to keep into the branch, and push again"
+}
+
+// PushLease returns the commit to pass to --force-with-lease when force-pushing pushCommit to
+// the remote branch whose remote tracking branch is at remoteCommit.
+//
+// This is the last-known remote commit recorded when av pushed the branch (branch.<name>.av-
+// pushed-commit), unless remoteCommit is already in pushCommit (e.g., the remote commits were
+// pulled into the branch) or in the reflog of the local branch (e.g., the user pushed the branch
+// with git push and then rewrote it). If the remote tracking branch has moved from the recorded
+// commit otherwise, a RemoteMovedError is returned since the push would discard the remote
+// commits. remoteCommit is returned if av hasn't pushed the branch.
+func PushLease(repo *git.Repo, remote, branch, remoteCommit, pushCommit string) (string, error) {
+	pushed, _ := repo.Git("config", "--get", "branch."+branch+".av-pushed-commit")
+	if pushed == "" || pushed == remoteCommit {
+		return remoteCommit, nil
+	}
+	if ok, err := repo.IsAncestor(remoteCommit, pushCommit); err != nil {
+		return "", err
+	} else if ok {
+		return remoteCommit, nil
+	}
+	if ok, err := repo.ReflogContains("refs/heads/"+branch, remoteCommit); err != nil {
+		return "", err
+	} else if ok {
+		return remoteCommit, nil
+	}
+	return "", RemoteMovedError{Branch: branch, Remote: remote, Expected: pushed, Actual: remoteCommit}
+}
+
+// IsStaleLeaseRejection returns true if the stderr of git push shows that the push was rejected
+// because a remote branch is not at the commit given to --force-with-lease.
+func IsStaleLeaseRejection(stderr []byte) bool {
+	return bytes.Contains(stderr, []byte("stale info"))
+}
@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/sirupsen/logrus"
+)
+
+// MoveRepository updates the metadata after the repository was transferred or renamed on the
+// forge: the repository, the permalinks of the pull requests, and the URL of the remote if it
+// still points to the old location. The moved repository must have the same ID. It returns
+// false if the repository was not moved.
+func MoveRepository(
+	repo *git.Repo,
+	tx meta.WriteTx,
+	remote string,
+	moved meta.Repository,
+) (bool, error) {
+	old := tx.Repository()
+	if moved.ID != old.ID {
+		return false, errors.Errorf(
+			"repository %s/%s has a different ID (%q, expected %q)",
+			moved.Owner, moved.Name, moved.ID, old.ID,
+		)
+	}
+	if strings.EqualFold(moved.Owner, old.Owner) && strings.EqualFold(moved.Name, old.Name) {
+		return false, nil
+	}
+
+	tx.SetRepository(moved)
+	for _, branch := range tx.AllBranches() {
+		if branch.PullRequest == nil {
+			continue
+		}
+		permalink := RewriteRepositoryURL(branch.PullRequest.Permalink, old, moved)
+		if permalink == branch.PullRequest.Permalink {
+			continue
+		}
+		branch.PullRequest.Permalink = permalink
+		tx.SetBranch(branch)
+	}
+
+	remoteURL, err := repo.Git("config", "--get", "remote."+remote+".url")
+	if err != nil {
+		// The remote can be configured elsewhere (e.g., with insteadOf). The pull requests
+		// still work, so leave it as is.
+		logrus.WithError(err).Debug("failed to get the remote URL")
+		return true, nil
+	}
+	if newURL := RewriteRepositoryURL(remoteURL, old, moved); newURL != remoteURL {
+		if _, err := repo.Git("remote", "set-url", remote, newURL); err != nil {
+			return true, errors.WrapIff(err, "failed to update the URL of remote %q", remote)
+		}
+	}
+	return true, nil
+}
+
+// RewriteRepositoryURL replaces the owner and the name of the repository in the URL (a web URL
+// or a remote URL like git@github.com:owner/name.git). The URL is returned unchanged if it
+// doesn't point to the repository.
+func RewriteRepositoryURL(u string, from meta.Repository, to meta.Repository) string {
+	pattern := regexp.MustCompile(
+		`(?i)([/:])` + regexp.QuoteMeta(from.Owner+"/"+from.Name) + `(\.git)?(/|$)`,
+	)
+	loc := pattern.FindStringSubmatchIndex(u)
+	if loc == nil {
+		return u
+	}
+	// Only the first occurrence (the path of the URL) is replaced.
+	replaced := pattern.ExpandString(nil, "${1}"+to.Owner+"/"+to.Name+"${2}${3}", u, loc)
+	return u[:loc[0]] + string(replaced) + u[loc[1]:]
+}
@@ -0,0 +1,82 @@
+package actions_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteRepositoryURL(t *testing.T) {
+	from := meta.Repository{Owner: "aviator-co", Name: "av"}
+	to := meta.Repository{Owner: "new-owner", Name: "new-av"}
+	for _, tt := range []struct {
+		url  string
+		want string
+	}{
+		{"https://github.com/aviator-co/av/pull/1", "https://github.com/new-owner/new-av/pull/1"},
+		{"https://github.com/Aviator-Co/AV/pull/1", "https://github.com/new-owner/new-av/pull/1"},
+		{"https://github.com/aviator-co/av.git", "https://github.com/new-owner/new-av.git"},
+		{"https://github.com/aviator-co/av", "https://github.com/new-owner/new-av"},
+		{"git@github.com:aviator-co/av.git", "git@github.com:new-owner/new-av.git"},
+		{"ssh://git@github.com/aviator-co/av.git", "ssh://git@github.com/new-owner/new-av.git"},
+		// Other repositories are left unchanged.
+		{"https://github.com/aviator-co/av-docs/pull/1", "https://github.com/aviator-co/av-docs/pull/1"},
+		{"https://github.com/fork/av.git", "https://github.com/fork/av.git"},
+	} {
+		assert.Equal(t, tt.want, actions.RewriteRepositoryURL(tt.url, from, to), tt.url)
+	}
+}
+
+func TestMoveRepository(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+	old := db.ReadTx().Repository()
+	repo.Git(t, "remote", "set-url", "origin", "git@github.com:"+old.Owner+"/"+old.Name+".git")
+
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{
+		Name:   "one",
+		Parent: meta.BranchState{Name: "main", Trunk: true},
+		PullRequest: &meta.PullRequest{
+			Number:    1,
+			Permalink: "https://github.com/" + old.Owner + "/" + old.Name + "/pull/1",
+		},
+	})
+	require.NoError(t, tx.Commit())
+
+	tx = db.WriteTx()
+	moved, err := actions.MoveRepository(repo.AsAvGitRepo(), tx, "origin", old)
+	require.NoError(t, err)
+	require.False(t, moved)
+	tx.Abort()
+
+	tx = db.WriteTx()
+	_, err = actions.MoveRepository(repo.AsAvGitRepo(), tx, "origin", meta.Repository{
+		ID:    "R_another_",
+		Owner: "new-owner",
+		Name:  "new-name",
+	})
+	require.Error(t, err, "a different repository must not be taken over")
+	tx.Abort()
+
+	newRepo := meta.Repository{ID: old.ID, Owner: "new-owner", Name: "new-name"}
+	tx = db.WriteTx()
+	moved, err = actions.MoveRepository(repo.AsAvGitRepo(), tx, "origin", newRepo)
+	require.NoError(t, err)
+	require.True(t, moved)
+	require.NoError(t, tx.Commit())
+
+	rtx := db.ReadTx()
+	assert.Equal(t, newRepo, rtx.Repository())
+	br, _ := rtx.Branch("one")
+	assert.Equal(t, "https://github.com/new-owner/new-name/pull/1", br.PullRequest.Permalink)
+	assert.Equal(t,
+		"git@github.com:new-owner/new-name.git",
+		strings.TrimSpace(repo.Git(t, "config", "--get", "remote.origin.url")),
+	)
+}
@@ -24,7 +24,15 @@ func AddPullRequestReviewers(
 	_, _ = fmt.Fprint(os.Stderr,
 		"  - adding ", colors.UserInput(len(reviewers)), " reviewer(s) to pull request\n",
 	)
+	return requestReviews(ctx, client, prID, reviewers)
+}
 
+func requestReviews(
+	ctx context.Context,
+	client *gh.Client,
+	prID githubv4.ID,
+	reviewers []string,
+) error {
 	// We need to map the given reviewers to GitHub node IDs.
 	var reviewerIDs []githubv4.ID
 	var teamIDs []githubv4.ID
@@ -0,0 +1,78 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+)
+
+// MissingSignoffs returns the commits of the given branch that are not signed off by their
+// authors, as required by the Developer Certificate of Origin (DCO). The local-only commits
+// are not pushed, so they are not checked.
+func MissingSignoffs(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	branchName string,
+) ([]git.CommitSignoffs, error) {
+	branch, exists := tx.Branch(branchName)
+	if !exists || branch.Parent.Name == "" {
+		return nil, nil
+	}
+	base := branch.Parent.Name
+	if branch.Parent.Trunk {
+		upstream := repo.RemoteBranchRef(branch.Parent.Name)
+		if _, err := repo.RevParse(&git.RevParse{Rev: upstream}); err == nil {
+			base = upstream
+		}
+	}
+	commits, err := repo.Signoffs(base + ".." + branchName)
+	if err != nil {
+		return nil, err
+	}
+	var ret []git.CommitSignoffs
+	for _, c := range commits {
+		if !c.SignedOffByAuthor() && !IsLocalOnlyCommit(c.Subject) {
+			ret = append(ret, c)
+		}
+	}
+	return ret, nil
+}
+
+// VerifySignoffs checks that the commits of the given branches are signed off by their
+// authors (see config.Commit.RequireSignoff). If not, it prints the commits and returns an
+// error that suggests signing them off.
+func VerifySignoffs(repo *git.Repo, tx meta.ReadTx, branchNames []string) error {
+	ok := true
+	for _, branchName := range branchNames {
+		missing, err := MissingSignoffs(repo, tx, branchName)
+		if err != nil {
+			return err
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		ok = false
+		_, _ = fmt.Fprint(os.Stderr,
+			colors.Failure("Branch "), colors.UserInput(branchName),
+			colors.Failure(" has "), colors.UserInput(len(missing)),
+			colors.Failure(" commit(s) that are not signed off by their authors (DCO):\n"),
+		)
+		for _, c := range missing {
+			_, _ = fmt.Fprint(os.Stderr,
+				"  - ", colors.UserInput(c.ShortHash), " ", c.Subject,
+				colors.Faint(" ("+c.AuthorEmail+")"), "\n",
+			)
+		}
+	}
+	if ok {
+		return nil
+	}
+	_, _ = fmt.Fprint(os.Stderr,
+		colors.Faint("  - run "), colors.CliCmd("av restack --signoff"),
+		colors.Faint(" to sign off your commits (the commits of other authors need their sign-offs)\n"),
+	)
+	return ErrExitSilently{ExitCode: 1}
+}
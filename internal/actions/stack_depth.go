@@ -0,0 +1,67 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+)
+
+// StackDepth returns the position of the branch in its stack. The branch based on the trunk is
+// at depth 1. A trunk branch (or a branch that is not adopted) is at depth 0.
+func StackDepth(tx meta.ReadTx, name string) int {
+	if _, ok := tx.Branch(name); !ok {
+		return 0
+	}
+	previous, err := meta.PreviousBranches(tx, name)
+	if err != nil {
+		return 0
+	}
+	return len(previous) + 1
+}
+
+// StackHeight returns the number of the branches from the branch to its deepest descendant,
+// including the branch itself.
+func StackHeight(tx meta.ReadTx, name string) int {
+	height := 0
+	for _, child := range meta.Children(tx, name) {
+		height = max(height, StackHeight(tx, child.Name))
+	}
+	return height + 1
+}
+
+// CheckStackDepth checks that stacking height levels of branches on the parent branch doesn't
+// make the stack deeper than the configured stack.maxDepth. If it does, it prints guidance on
+// keeping the stack reviewable and returns an error. The commands skip this check with --force.
+func CheckStackDepth(tx meta.ReadTx, parent string, height int) error {
+	maxDepth := config.Av.Stack.MaxDepth
+	if maxDepth == 0 {
+		return nil
+	}
+	depth := StackDepth(tx, parent) + height
+	if depth <= maxDepth {
+		return nil
+	}
+	_, _ = fmt.Fprint(os.Stderr,
+		colors.Failure("The stack would be "), colors.UserInput(depth),
+		colors.Failure(" branches deep, beyond the limit of "), colors.UserInput(maxDepth),
+		colors.Failure(" (stack.maxDepth).\n"),
+		colors.Faint("Deep stacks are hard to review. Consider:\n"),
+	)
+	if root, ok := meta.Root(tx, parent); ok {
+		_, _ = fmt.Fprint(os.Stderr,
+			colors.Faint("  - submitting the bottom of the stack ("), colors.UserInput(root),
+			colors.Faint(") with "), colors.CliCmd("av pr"),
+			colors.Faint(" and, once merged, running "), colors.CliCmd("av sync"),
+			"\n",
+		)
+	}
+	_, _ = fmt.Fprint(os.Stderr,
+		colors.Faint("  - folding small branches together with "), colors.CliCmd("av reorder"), "\n",
+		colors.Faint("  - running the command again with "), colors.CliCmd("--force"),
+		colors.Faint(" to go ahead anyway\n"),
+	)
+	return ErrExitSilently{ExitCode: 1}
+}
@@ -0,0 +1,33 @@
+package actions_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackDepthAndHeight(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one"}})
+	tx.SetBranch(meta.Branch{Name: "three", Parent: meta.BranchState{Name: "two"}})
+	tx.SetBranch(meta.Branch{Name: "side", Parent: meta.BranchState{Name: "one"}})
+	require.NoError(t, tx.Commit())
+
+	rtx := db.ReadTx()
+	assert.Equal(t, 0, actions.StackDepth(rtx, "main"))
+	assert.Equal(t, 1, actions.StackDepth(rtx, "one"))
+	assert.Equal(t, 3, actions.StackDepth(rtx, "three"))
+	assert.Equal(t, 2, actions.StackDepth(rtx, "side"))
+
+	assert.Equal(t, 3, actions.StackHeight(rtx, "one"))
+	assert.Equal(t, 2, actions.StackHeight(rtx, "two"))
+	assert.Equal(t, 1, actions.StackHeight(rtx, "side"))
+}
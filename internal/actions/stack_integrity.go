@@ -0,0 +1,121 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/aviator-co/av/internal/utils/errutils"
+)
+
+// LeakedCommits returns the commits that the pull request of the given branch would show in
+// addition to the commits between the branch and its parent. These are the commits that
+// belong to an ancestor branch, and appear when the branch is not based on the latest parent
+// branch (e.g., the parent branch has been amended but the branch hasn't been restacked).
+//
+// The pull request is assumed to be based on the local parent branch (which is pushed before
+// the branch when submitting the stack) or the remote trunk branch.
+func LeakedCommits(repo *git.Repo, tx meta.ReadTx, branchName string) ([]string, error) {
+	branch, exists := tx.Branch(branchName)
+	if !exists || branch.Parent.Name == "" {
+		return nil, nil
+	}
+
+	if branch.Parent.Trunk {
+		// The commits that are already in the trunk (e.g., the commits of a merged parent
+		// branch that was not rebased away) are shown in the pull request if the branch is not
+		// rebased onto the latest trunk.
+		upstream := repo.RemoteBranchRef(branch.Parent.Name)
+		if _, err := repo.RevParse(&git.RevParse{Rev: upstream}); err != nil {
+			// The trunk hasn't been fetched from the remote.
+			return nil, nil
+		}
+		out, err := repo.Git("cherry", upstream, branchName)
+		if err != nil {
+			return nil, err
+		}
+		var leaked []string
+		for _, line := range strings.Split(out, "\n") {
+			if commit, ok := strings.CutPrefix(strings.TrimSpace(line), "- "); ok {
+				leaked = append(leaked, commit)
+			}
+		}
+		return leaked, nil
+	}
+
+	if branch.Parent.Head == "" {
+		return nil, nil
+	}
+	shown, err := repo.RevList(git.RevListOpts{
+		Specifiers: []string{branchName, "^" + branch.Parent.Name},
+		Reverse:    true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	own, err := repo.RevList(git.RevListOpts{
+		Specifiers: []string{branchName, "^" + branch.Parent.Head},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ownSet := map[string]bool{}
+	for _, commit := range own {
+		ownSet[commit] = true
+	}
+	var leaked []string
+	for _, commit := range shown {
+		if !ownSet[commit] {
+			leaked = append(leaked, commit)
+		}
+	}
+	return leaked, nil
+}
+
+// VerifyStackIntegrity checks that the pull request of each of the given branches will show
+// exactly the commits between the branch and its parent. If not, it prints the commits that
+// would leak into the pull requests and returns an error that suggests running av sync.
+func VerifyStackIntegrity(repo *git.Repo, tx meta.ReadTx, branchNames []string) error {
+	ok := true
+	for _, branchName := range branchNames {
+		leaked, err := LeakedCommits(repo, tx, branchName)
+		if err != nil {
+			return err
+		}
+		if len(leaked) == 0 {
+			continue
+		}
+		ok = false
+		branch, _ := tx.Branch(branchName)
+		_, _ = fmt.Fprint(os.Stderr,
+			colors.Failure("The pull request for branch "), colors.UserInput(branchName),
+			colors.Failure(" would contain "), colors.UserInput(len(leaked)),
+			colors.Failure(" commit(s) that are not part of the branch (the base "),
+			colors.UserInput(branch.Parent.Name), colors.Failure(" is stale):\n"),
+		)
+		for _, commit := range leaked {
+			info, err := repo.CommitInfo(git.CommitInfoOpts{Rev: commit})
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprint(os.Stderr,
+				"  - ", colors.UserInput(info.ShortHash), " ", info.Subject, "\n",
+			)
+		}
+	}
+	if ok {
+		return nil
+	}
+	_, _ = fmt.Fprint(os.Stderr,
+		colors.Faint("  - run "), colors.CliCmd("av sync"),
+		colors.Faint(" to rebase the branches onto their parents before submitting\n"),
+	)
+	return ErrExitSilently{ExitCode: 1, Err: errutils.WithFailureClass(
+		errors.New("the pull requests would contain the commits of the stale bases"),
+		errutils.FailureMetadataMismatch,
+	)}
+}
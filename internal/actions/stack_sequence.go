@@ -0,0 +1,153 @@
+package actions
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/sirupsen/logrus"
+)
+
+// SequencedBranchNames returns the numbered names of the branches in the given order (e.g.,
+// "feat-x/01-schema", "feat-x/02-api", ...), keyed by the current branch names.
+//
+// The name of a branch that is already numbered with the prefix keeps its suffix (e.g.,
+// "feat-x/02-api" becomes "feat-x/01-api" if it's moved to the first). Otherwise, the last
+// path component of the branch name is used as the suffix.
+func SequencedBranchNames(prefix string, branches []string) (map[string]string, error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return nil, errors.New("the sequence prefix cannot be empty")
+	}
+	numbered := regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `/\d+-(.+)$`)
+	width := max(2, len(strconv.Itoa(len(branches))))
+
+	ret := make(map[string]string, len(branches))
+	seen := map[string]string{}
+	for i, branch := range branches {
+		suffix := branch[strings.LastIndex(branch, "/")+1:]
+		if m := numbered.FindStringSubmatch(branch); m != nil {
+			suffix = m[1]
+		}
+		if other, ok := seen[suffix]; ok {
+			return nil, errors.Errorf(
+				"branches %q and %q would have the same name suffix %q", other, branch, suffix,
+			)
+		}
+		seen[suffix] = branch
+		ret[branch] = fmt.Sprintf("%s/%0*d-%s", prefix, width, i+1, suffix)
+	}
+	return ret, nil
+}
+
+// RenameStackSequence renames all the branches in the stack of the given branch to the
+// numbered names with the given prefix (see SequencedBranchNames), and records the prefix so
+// that the stack can be renumbered later. If the prefix is empty, the prefix recorded in the
+// stack is used. The renamed branches are returned keyed by the old names.
+//
+// Renaming a branch orphans its pull request, so the branches with a pull request are not
+// renamed unless force is true.
+func RenameStackSequence(
+	repo *git.Repo,
+	tx meta.WriteTx,
+	branchName string,
+	prefix string,
+	force bool,
+) (map[string]string, error) {
+	branches, err := meta.StackBranches(tx, branchName)
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "" {
+		prefix = StackSequencePrefix(tx, branchName)
+		if prefix == "" {
+			return nil, errors.Errorf("the stack of %q is not numbered", branchName)
+		}
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	names, err := SequencedBranchNames(prefix, branches)
+	if err != nil {
+		return nil, err
+	}
+
+	renames := map[string]string{}
+	for _, branch := range branches {
+		br, _ := tx.Branch(branch)
+		newName := names[branch]
+		if newName != branch {
+			if br.PullRequest != nil && !force {
+				return nil, errors.Errorf(
+					"cannot rename branch %q: pull request #%d would be orphaned (use av renumber --force to override)",
+					branch, br.PullRequest.Number,
+				)
+			}
+			if _, ok := tx.Branch(newName); ok {
+				return nil, errors.Errorf("cannot rename branch %q: branch %q already exists", branch, newName)
+			}
+			renames[branch] = newName
+		}
+	}
+
+	// Rename the Git branches first so that the metadata isn't changed if it fails.
+	var renamed []string
+	for _, branch := range branches {
+		newName, ok := renames[branch]
+		if !ok {
+			continue
+		}
+		if exists, err := repo.DoesBranchExist(branch); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+		if _, err := repo.Run(&git.RunOpts{
+			Args:      []string{"branch", "-m", branch, newName},
+			ExitError: true,
+		}); err != nil {
+			for _, b := range renamed {
+				if _, rerr := repo.Git("branch", "-m", renames[b], b); rerr != nil {
+					logrus.WithError(rerr).Warnf("failed to restore the name of branch %q", b)
+				}
+			}
+			return nil, errors.WrapIff(err, "failed to rename Git branch %q", branch)
+		}
+		renamed = append(renamed, branch)
+	}
+
+	for _, br := range tx.AllBranches() {
+		if newName, ok := renames[br.Parent.Name]; ok {
+			br.Parent.Name = newName
+			tx.SetBranch(br)
+		}
+	}
+	for _, branch := range branches {
+		br, _ := tx.Branch(branch)
+		br.SequencePrefix = prefix
+		if newName, ok := renames[branch]; ok {
+			tx.DeleteBranch(branch)
+			br.Name = newName
+			br.PullRequest = nil
+		}
+		tx.SetBranch(br)
+	}
+	return renames, nil
+}
+
+// StackSequencePrefix returns the sequence prefix recorded in the stack of the given branch,
+// or an empty string if the stack is not numbered.
+func StackSequencePrefix(tx meta.ReadTx, branchName string) string {
+	branches, err := meta.StackBranches(tx, branchName)
+	if err != nil {
+		return ""
+	}
+	for _, branch := range branches {
+		if br, _ := tx.Branch(branch); br.SequencePrefix != "" {
+			return br.SequencePrefix
+		}
+	}
+	return ""
+}
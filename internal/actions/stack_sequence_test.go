@@ -0,0 +1,34 @@
+package actions_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequencedBranchNames(t *testing.T) {
+	names, err := actions.SequencedBranchNames("feat-x/", []string{
+		"feat-x/02-api", "alice/schema", "feat-x/01-ui",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"feat-x/02-api": "feat-x/01-api",
+		"alice/schema":  "feat-x/02-schema",
+		"feat-x/01-ui":  "feat-x/03-ui",
+	}, names)
+
+	// The numbers are padded to the same width.
+	var many []string
+	for i := 0; i < 100; i++ {
+		many = append(many, fmt.Sprintf("branch-%d", i))
+	}
+	names, err = actions.SequencedBranchNames("x", many)
+	require.NoError(t, err)
+	require.Equal(t, "x/001-branch-0", names["branch-0"])
+	require.Equal(t, "x/100-branch-99", names["branch-99"])
+
+	_, err = actions.SequencedBranchNames("feat-x", []string{"alice/api", "bob/api"})
+	require.ErrorContains(t, err, `same name suffix "api"`)
+}
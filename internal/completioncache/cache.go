@@ -0,0 +1,178 @@
+// Package completioncache stores the data for the shell completion (the branch names, their
+// parents, and the pull request titles) in a small file in the av directory. The shell completion reads it
+// without running Git, which can be slow (e.g., on a network filesystem).
+//
+// The cache is derived from the av database. It's rebuilt when the database is newer than the
+// cache, which happens after the commands that change the branches.
+package completioncache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/meta"
+)
+
+const (
+	fileName   = "completion-cache.json"
+	dbFileName = "av.db"
+)
+
+// Data is the cached completion data of a repository.
+type Data struct {
+	DefaultBranch string   `json:"defaultBranch"`
+	Branches      []Branch `json:"branches"`
+}
+
+// Branch is a branch tracked by av.
+type Branch struct {
+	Name              string `json:"name"`
+	Parent            string `json:"parent,omitempty"`
+	PullRequestNumber int64  `json:"pullRequestNumber,omitempty"`
+	PullRequestTitle  string `json:"pullRequestTitle,omitempty"`
+}
+
+// Build creates the completion data from the av database.
+func Build(tx meta.ReadTx, defaultBranch string) *Data {
+	data := &Data{DefaultBranch: defaultBranch}
+	for name, br := range tx.AllBranches() {
+		b := Branch{Name: name, Parent: br.Parent.Name}
+		if br.PullRequest != nil {
+			b.PullRequestNumber = br.PullRequest.Number
+			b.PullRequestTitle = br.PullRequest.Title
+		}
+		data.Branches = append(data.Branches, b)
+	}
+	slices.SortFunc(data.Branches, func(a, b Branch) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return data
+}
+
+// Description returns the description of the branch shown next to it in the shell completion:
+// its parent and its pull request.
+func (b Branch) Description() string {
+	var parts []string
+	if b.Parent != "" {
+		parts = append(parts, "on "+b.Parent)
+	}
+	if b.PullRequestNumber != 0 {
+		parts = append(parts, fmt.Sprintf("#%d %s", b.PullRequestNumber, b.PullRequestTitle))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BranchNames returns the default branch and the branches tracked by av.
+func (d *Data) BranchNames() []string {
+	names := []string{d.DefaultBranch}
+	for _, br := range d.Branches {
+		names = append(names, br.Name)
+	}
+	return names
+}
+
+// Read reads the cache in the av directory. It returns false if the cache doesn't exist, can't
+// be read, or is older than the av database.
+func Read(avDir string) (*Data, bool) {
+	fp := filepath.Join(avDir, fileName)
+	stat, err := os.Stat(fp)
+	if err != nil {
+		return nil, false
+	}
+	if dbStat, err := os.Stat(filepath.Join(avDir, dbFileName)); err != nil ||
+		dbStat.ModTime().After(stat.ModTime()) {
+		return nil, false
+	}
+	content, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, false
+	}
+	var data Data
+	if err := json.Unmarshal(content, &data); err != nil || data.DefaultBranch == "" {
+		return nil, false
+	}
+	return &data, true
+}
+
+// Write writes the cache to the av directory. The file is replaced atomically, so that the
+// concurrent readers (e.g., the shell completion while a command is running) never read a
+// partially written file. If multiple commands write the cache at the same time, the last one
+// wins, which is fine since any of them is a valid snapshot.
+func Write(avDir string, data *Data) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(avDir, fileName+".tmp*")
+	if err != nil {
+		return errors.WrapIf(err, "failed to write the completion cache")
+	}
+	_ = f.Chmod(0644)
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return errors.WrapIf(err, "failed to write the completion cache")
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return errors.WrapIf(err, "failed to write the completion cache")
+	}
+	if err := os.Rename(f.Name(), filepath.Join(avDir, fileName)); err != nil {
+		_ = os.Remove(f.Name())
+		return errors.WrapIf(err, "failed to write the completion cache")
+	}
+	return nil
+}
+
+// FindAvDir finds the av directory of the repository that contains the given directory
+// without running Git. The .git directory (or the .git file of a worktree) is looked up in the
+// directory and its ancestors. It returns false if it's not found (e.g., in a bare repository
+// or with $GIT_DIR), in which case the caller should fall back to Git.
+func FindAvDir(dir string) (string, bool) {
+	if os.Getenv("GIT_DIR") != "" {
+		return "", false
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if stat, err := os.Stat(dotGit); err == nil {
+			gitDir := dotGit
+			if !stat.IsDir() {
+				// A worktree: "gitdir: <path>"
+				content, err := os.ReadFile(dotGit)
+				if err != nil {
+					return "", false
+				}
+				path, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: ")
+				if !ok {
+					return "", false
+				}
+				gitDir = resolvePath(dir, path)
+			}
+			if content, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+				gitDir = resolvePath(gitDir, strings.TrimSpace(string(content)))
+			}
+			return filepath.Join(gitDir, "av"), true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func resolvePath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
@@ -0,0 +1,70 @@
+package completioncache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aviator-co/av/internal/completioncache"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/meta/jsonfiledb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWrite(t *testing.T) {
+	avDir := t.TempDir()
+	db, _, err := jsonfiledb.OpenPath(filepath.Join(avDir, "av.db"))
+	require.NoError(t, err)
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one"}})
+	tx.SetBranch(meta.Branch{
+		Name:        "one",
+		Parent:      meta.BranchState{Name: "main", Trunk: true},
+		PullRequest: &meta.PullRequest{Number: 12, Title: "Add one"},
+	})
+	require.NoError(t, tx.Commit())
+
+	_, ok := completioncache.Read(avDir)
+	require.False(t, ok, "the cache doesn't exist yet")
+
+	require.NoError(t, completioncache.Write(avDir, completioncache.Build(db.ReadTx(), "main")))
+	data, ok := completioncache.Read(avDir)
+	require.True(t, ok)
+	assert.Equal(t, []string{"main", "one", "two"}, data.BranchNames())
+	assert.Equal(t, int64(12), data.Branches[0].PullRequestNumber)
+	assert.Equal(t, "Add one", data.Branches[0].PullRequestTitle)
+	assert.Equal(t, "on main, #12 Add one", data.Branches[0].Description())
+	assert.Equal(t, "on one", data.Branches[1].Description())
+
+	// The cache is stale once the database is changed.
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(avDir, "av.db"), future, future))
+	_, ok = completioncache.Read(avDir)
+	assert.False(t, ok)
+}
+
+func TestFindAvDir(t *testing.T) {
+	t.Setenv("GIT_DIR", "")
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "repo", ".git", "worktrees", "wt"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "repo", "sub", "dir"), 0755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(root, "repo", ".git", "worktrees", "wt", "commondir"), []byte("../..\n"), 0644,
+	))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "wt"), 0755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(root, "wt", ".git"),
+		[]byte("gitdir: "+filepath.Join(root, "repo", ".git", "worktrees", "wt")+"\n"),
+		0644,
+	))
+
+	avDir := filepath.Join(root, "repo", ".git", "av")
+	dir, ok := completioncache.FindAvDir(filepath.Join(root, "repo", "sub", "dir"))
+	require.True(t, ok)
+	assert.Equal(t, avDir, dir)
+	dir, ok = completioncache.FindAvDir(filepath.Join(root, "wt"))
+	require.True(t, ok)
+	assert.Equal(t, avDir, dir)
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
 
 	"emperror.dev/errors"
 	"github.com/sirupsen/logrus"
@@ -17,8 +22,119 @@ type GitHub struct {
 	// For example, "https://github.mycompany.com/" (without a "/api/v3" or
 	// "/api/graphql" suffix).
 	BaseURL string
+	// The base URL of the GitHub API. Defaults to https://api.github.com, or to
+	// BaseURL + "/api" for GHES instances. This only needs to be set if the API is served
+	// from a different location (e.g., behind a proxy).
+	APIURL string
+	// The URL of the GitHub GraphQL API endpoint. Defaults to APIURL + "/graphql".
+	GraphQLURL string
+	// How to send the GitHub API requests. One of "http" (default) or "gh" (through the gh CLI
+	// with its authentication instead of a GitHub API token).
+	Transport string
+	// The number of times a GitHub API request rejected by a rate limit is retried. The
+	// request is retried after the time that GitHub tells to wait, or with an exponential
+	// backoff. Set to 0 to disable retries.
+	MaxRetries int
 }
 
+const (
+	// Send the GitHub API requests directly with a GitHub API token.
+	GitHubTransportHTTP = "http"
+	// Send the GitHub API requests through `gh api`.
+	GitHubTransportGhCLI = "gh"
+)
+
+// Host returns the host name of the GitHub instance (github.com unless BaseURL is set).
+func (c GitHub) Host() string {
+	if host := urlHost(c.BaseURL); host != "" {
+		return host
+	}
+	return "github.com"
+}
+
+// APIEndpoint returns the base URL of the GitHub API (see APIURL).
+func (c GitHub) APIEndpoint() string {
+	if c.APIURL != "" {
+		return strings.TrimSuffix(c.APIURL, "/")
+	}
+	if c.BaseURL != "" {
+		return strings.TrimSuffix(c.BaseURL, "/") + "/api"
+	}
+	return "https://api.github.com"
+}
+
+// GraphQLEndpoint returns the URL of the GitHub GraphQL API endpoint (see GraphQLURL).
+func (c GitHub) GraphQLEndpoint() string {
+	if c.GraphQLURL != "" {
+		return c.GraphQLURL
+	}
+	return c.APIEndpoint() + "/graphql"
+}
+
+type GitLab struct {
+	// The GitLab API token (a personal, project, or group access token with the "api" scope)
+	// to use for authenticating to the GitLab API.
+	Token string
+	// The base URL of the GitLab instance to use. Defaults to https://gitlab.com. For
+	// self-managed instances, for example, "https://gitlab.mycompany.com" (without an
+	// "/api/v4" suffix).
+	BaseURL string
+}
+
+const (
+	// Pull requests are hosted on GitHub.
+	ForgeGitHub = "github"
+	// Pull requests are GitLab merge requests.
+	ForgeGitLab = "gitlab"
+)
+
+// ForgeForHost returns the forge that hosts the repositories of the given host (the host of a
+// remote URL). Besides github.com and gitlab.com, the hosts of the configured base URLs (e.g.,
+// a GitHub Enterprise Server or a self-managed GitLab instance) are recognized. Returns an
+// empty string for the unknown hosts.
+func ForgeForHost(host string) string {
+	host = strings.ToLower(host)
+	if host == "" {
+		return ""
+	}
+	for _, u := range []string{Av.GitHub.BaseURL, Av.GitHub.APIURL, Av.GitHub.GraphQLURL} {
+		if urlHost(u) == host {
+			return ForgeGitHub
+		}
+	}
+	if urlHost(Av.GitLab.BaseURL) == host {
+		return ForgeGitLab
+	}
+	switch host {
+	case "github.com":
+		return ForgeGitHub
+	case "gitlab.com":
+		return ForgeGitLab
+	}
+	return ""
+}
+
+// urlHost returns the host name (without the port) of the URL, or an empty string if it's not
+// a valid URL.
+func urlHost(s string) string {
+	if s == "" {
+		return ""
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// ForgeConfigured returns true if the forge is set in the config files. Otherwise, the forge
+// can be detected from the remote URL (see ForgeForHost).
+func ForgeConfigured() bool {
+	return forgeConfigured
+}
+
+var forgeConfigured bool
+
 type PullRequest struct {
 	Draft       bool
 	OpenBrowser bool
@@ -38,9 +154,232 @@ type PullRequest struct {
 	// Branch prefix to use for creating new branches.
 	BranchNamePrefix string
 
+	// If true, BranchNamePrefix is treated as the personal namespace of the user (e.g.,
+	// "alice/"). The prefix is also applied to the branch names given to `av branch` and
+	// `av commit --branch-name`, and av refuses to create a branch that already exists on the
+	// remote or to modify a branch outside the namespace unless --force is given.
+	EnforceBranchNamePrefix bool
+
+	// The template of the branch names generated from a free-form description (e.g.,
+	// `av branch "Fix login race"`) or a commit message (`av commit -b`), such as
+	// "{user}/{ticket}/{slug}". See actions.BranchNameFromTemplate for the placeholders.
+	// Defaults to BranchNamePrefix followed by the slug.
+	BranchNameTemplate string
+
+	// A regular expression that the names of the new branches must match (e.g., the naming
+	// policy of the organization). av refuses to create or rename to the other names unless
+	// --force is given.
+	BranchNamePattern string
+
 	// If true, the CLI will automatically add/update a comment to all PRs linking other PRs in the stack.
 	// False by default, since Aviator's MergeQueue also adds a similar comment.
 	WriteStack bool
+
+	// Where the stack is written if WriteStack is true: "body" (default; a section at the top
+	// of the pull request body) or "comment" (a comment on the pull request that is edited in
+	// place).
+	StackLocation string
+
+	// A Go template (text/template) for the stack written to the pull requests. See
+	// actions.PRStackTemplateData for the data. Defaults to a collapsible list of the pull
+	// requests in the stack.
+	StackTemplate string
+
+	// Transformations applied to the pull request titles generated from the commit messages.
+	Title PullRequestTitle
+
+	// The section of the pull request descriptions generated from the commit messages.
+	GeneratedBody PullRequestGeneratedBody
+
+	// The shell command that suggests the title and the body of a pull request for
+	// `av pr --summarize` (e.g., a script calling an LLM). It gets the diff of the branch on
+	// stdin and prints the title on the first line followed by the body. The suggestion is
+	// opened in the editor to be confirmed.
+	Summarizer string
+
+	// The GitHub project (v2) to add the created pull requests to.
+	Project Project
+
+	// Which pull requests to open in the browser when `av pr --all --open` submits the stack.
+	// One of "all" (default; the created and the updated pull requests), "created", or
+	// "bottom" (only the bottom-most pull request of the submitted branches).
+	SubmitOpen string
+
+	// The maximum number of the pull requests that `av pr --all` creates or updates at the same
+	// time. Defaults to 4. Set to 1 to submit them one by one.
+	SubmitConcurrency int
+
+	// Rules to decide whether the pull requests are drafts based on the position of the branch
+	// in the stack.
+	DraftRules PullRequestDraftRules
+
+	// Markers for the CI pipelines to run a reduced test suite for the branches high in the
+	// stack.
+	CIHints PullRequestCIHints
+
+	// Suggestions to reorder or split the stack that `av pr --all` shows before submitting.
+	Suggestions PullRequestSuggestions
+
+	// The Markdown sections (e.g., "Test Plan") that the pull request descriptions must have.
+	// av asks to fill in the missing or empty sections before creating a pull request.
+	RequiredSections []string
+
+	// The default reviewers (user logins or "@org/team" names), labels, and assignees (user
+	// logins, or "@me" for the author) of the pull requests. They are added when the pull
+	// requests are created, and the missing ones are added again when they are submitted.
+	Reviewers []string
+	Labels    []string
+	Assignees []string
+
+	// If true, the code owners (from the CODEOWNERS file of the trunk) of the files changed in
+	// each branch are requested as reviewers of its pull request, since GitHub requests them
+	// only for the pull requests based on the trunk.
+	CodeownersReviewers bool
+
+	// The review checklist added to the pull request descriptions. The items are checked
+	// automatically based on the files changed in the branch when the pull request is
+	// submitted.
+	Checklist []ChecklistItem
+
+	// If true, auto-merge is enabled on the submitted pull requests (same as the --auto-merge
+	// flag). GitHub merges a pull request once its checks pass. Only the pull requests based on
+	// the trunk get auto-merge at submit; the others get it from av sync once their parents are
+	// merged.
+	AutoMerge bool
+
+	// The merge method of auto-merge: "merge", "squash" (default), or "rebase".
+	MergeMethod string
+}
+
+type ChecklistItem struct {
+	// The text of the checklist item (e.g., "Tests added").
+	Text string
+	// Glob patterns (as in path.Match) of the file paths. The item is checked if the branch
+	// changes a file that matches any of the patterns. A pattern without a slash is matched
+	// against the file name (e.g., "*_test.go"). The items without patterns are left for the
+	// author to check.
+	Paths []string
+}
+
+// Match returns true if any of the given file paths matches the patterns of the item.
+func (i ChecklistItem) Match(files []string) bool {
+	for _, pattern := range i.Paths {
+		for _, fp := range files {
+			if matchPathPattern(pattern, fp) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+const (
+	StackLocationBody    = "body"
+	StackLocationComment = "comment"
+)
+
+const (
+	MergeMethodMerge  = "merge"
+	MergeMethodSquash = "squash"
+	MergeMethodRebase = "rebase"
+)
+
+// ValidateMergeMethod returns an error if the merge method is not one of "merge", "squash", or
+// "rebase".
+func ValidateMergeMethod(method string) error {
+	switch method {
+	case MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		return nil
+	}
+	return errors.Errorf(
+		"unknown merge method %q (expected %q, %q, or %q)",
+		method, MergeMethodMerge, MergeMethodSquash, MergeMethodRebase,
+	)
+}
+
+const (
+	SubmitOpenAll     = "all"
+	SubmitOpenCreated = "created"
+	SubmitOpenBottom  = "bottom"
+)
+
+type Project struct {
+	// The login of the organization or the user that owns the project. Defaults to the owner
+	// of the repository.
+	Owner string
+	// The number of the project (as in https://github.com/orgs/<owner>/projects/<number>).
+	// Pull requests are not added to a project if this is zero.
+	Number int
+	// The field values to set on the project items, keyed by the field name (e.g.,
+	// "Status: In review"). Single select fields take the option name, and iteration fields
+	// take the iteration title or "@current" for the current iteration.
+	Fields map[string]string
+}
+
+type PullRequestDraftRules struct {
+	// The pull requests of the branches deeper than this position in the stack are drafts, and
+	// the others are ready for review (the branch based on the trunk is at position 1). The
+	// rule is disabled if this is zero.
+	DraftAfterDepth int
+	// If true, the pull request of the branch based on the trunk is always ready for review.
+	RootReady bool
+}
+
+const (
+	CIHintMarkerPushOption = "push-option"
+	CIHintMarkerTrailer    = "trailer"
+	CIHintMarkerLabel      = "label"
+)
+
+type PullRequestCIHints struct {
+	// The branches deeper than this position in the stack get the markers (the branch based on
+	// the trunk is at position 1). The hints are disabled if this is zero.
+	ReducedAfterDepth int
+	// How the branches are marked: "push-option" (git push -o), "trailer" (a trailer in the
+	// commits created by av commit), and/or "label" (a pull request label, removed once the
+	// branch is no longer deep). Defaults to push-option.
+	Markers []string
+	// The push option. Defaults to "ci.reduced".
+	PushOption string
+	// The commit trailer. Defaults to "Av-CI: reduced".
+	Trailer string
+	// The pull request label. Defaults to "av:reduced-ci".
+	Label string
+}
+
+type PullRequestSuggestions struct {
+	// If true, `av pr --all` analyzes the diffs of the branches and suggests moving the
+	// branches that don't depend on their parents and splitting the large branches (same as
+	// the --suggest flag).
+	Enabled bool
+	// A branch that changes more lines than this (and more than twice the average of the
+	// other branches) is suggested to be split. Defaults to 500. Zero disables the split
+	// suggestions.
+	LargeBranchLines int
+}
+
+type PullRequestTitle struct {
+	// Prefixes to strip from the generated title (e.g., "feature/" or "wip: ").
+	StripPrefixes []string
+	// If true, the first letter of the generated title is capitalized.
+	SentenceCase bool
+	// A regular expression to find a ticket ID in the branch name (e.g., "[A-Z]+-[0-9]+").
+	// If the branch name contains a ticket ID and the title doesn't, the ticket ID is
+	// prepended to the title.
+	TicketPattern string
+	// The format of the ticket ID prepended to the title. "%s" is replaced with the ticket
+	// ID. Defaults to "[%s] ".
+	TicketFormat string
+}
+
+type PullRequestGeneratedBody struct {
+	// If true, a section generated from the commit messages of the branch is added to the
+	// pull request descriptions. The section is regenerated whenever the pull request is
+	// submitted, and the rest of the description is left as it is.
+	Enabled bool
+	// A Go template (text/template) for the section. See actions.PRBodyTemplateData for the
+	// data. Defaults to the commit bodies separated by blank lines ("{{.Bodies}}").
+	Template string
 }
 
 type Aviator struct {
@@ -53,22 +392,375 @@ type Aviator struct {
 	APIToken string
 }
 
+const (
+	// Don't configure the upstream branch (branch.<name>.remote/merge) of the branches.
+	UpstreamTrackingNone = "none"
+	// Configure the upstream branch when av creates a branch.
+	UpstreamTrackingCreate = "create"
+	// Configure the upstream branch when av pushes a branch.
+	UpstreamTrackingPush = "push"
+	// Let Git configure the upstream branch when av creates a branch, as it does for `git branch
+	// <name> <start-point>` (see branch.autoSetupMerge).
+	UpstreamTrackingGit = "git"
+)
+
+const (
+	// Detect whether the push URL is a mirror of the canonical repository.
+	MirrorPushAuto = "auto"
+	// The push URL is a mirror of the canonical repository.
+	MirrorPushYes = "yes"
+	// The push URL is not a mirror (e.g., it's a fork of the canonical repository).
+	MirrorPushNo = "no"
+)
+
+const (
+	// Resolve the binary file conflict with the version of the parent branch.
+	BinaryConflictResolveParent = "parent"
+	// Resolve the binary file conflict with the version of the branch being restacked.
+	BinaryConflictResolveBranch = "branch"
+)
+
+type Restack struct {
+	// The default resolutions of the binary file conflicts during restacks. The first rule
+	// that matches the conflicted file is used. Conflicts that don't match any rule are
+	// prompted.
+	BinaryConflicts []BinaryConflictRule
+
+	// By default, the branches that are not checked out are rebased in memory first: they are
+	// moved with git replay (Git 2.44 or later), or each commit is applied with git merge-tree
+	// and git commit-tree on older Git versions, without touching the working tree, which is
+	// much faster for deep stacks. av falls back to git rebase for the checked-out branch, on
+	// conflicts, and if the repository has a post-rewrite hook. Setting this to true always
+	// uses git rebase.
+	NoInMemoryRebase bool
+
+	// By default, if the repository has submodules, git submodule update --init --recursive is
+	// run after the checked-out branch is rebased (and when av checks out the original branch
+	// after a restack) so that the submodules are not left at the commits of the old base.
+	// Setting this to true leaves the submodules as they are.
+	NoSubmoduleUpdate bool
+
+	// If true, git rerere is enabled for the rebases of the restacks. The conflict resolutions
+	// are recorded, and the same conflicts in the rest of the stack (or in later restacks) are
+	// resolved with them. If no conflicts are left, the rebase is continued automatically, so
+	// this is off by default to let the user review every resolution.
+	Rerere bool
+
+	// If true, the commits rewritten by restacks (and by av reorder) are signed with the
+	// signing key configured in Git (user.signingKey and gpg.format), regardless of the
+	// commit.gpgSign config. The signing key is checked before the branches are rebased, and
+	// git replay (which can't sign) is not used. The author dates are preserved.
+	SignCommits bool
+
+	// If true, the committer dates of the commits rewritten by restacks are set to their author
+	// dates (git rebase --committer-date-is-author-date) instead of the time of the restack.
+	// git replay is not used.
+	CommitterDateIsAuthorDate bool
+
+	// If true, the uncommitted changes are stashed before av sync, av restack, and av reorder
+	// rewrite the branches, and they are restored afterward (as with --autostash). If the
+	// operation stops at a conflict, the changes are restored after it's continued and
+	// finished, or aborted.
+	Autostash bool
+}
+
+type BinaryConflictRule struct {
+	// A glob pattern (as in path.Match) of the file path. A pattern without a slash is matched
+	// against the file name (e.g., "*.png").
+	Pattern string
+	// Either "parent" or "branch".
+	Resolve string
+}
+
+// Match returns true if the rule matches the given file path.
+func (r BinaryConflictRule) Match(fp string) bool {
+	return matchPathPattern(r.Pattern, fp)
+}
+
+// matchPathPattern matches the file path against the glob pattern. A pattern without a slash
+// is matched against the file name.
+func matchPathPattern(pattern string, fp string) bool {
+	name := fp
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(fp)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+type Commit struct {
+	// The path groups for av commit --split-by-dir. The staged changes are committed in one
+	// commit per group. The changes that don't match any group are grouped by their
+	// top-level directory.
+	PathGroups []CommitPathGroup
+
+	// If true, the commits that av creates (av commit) or rewrites (restacks and av reorder)
+	// get a Signed-off-by trailer of the committer identity (user.name and user.email), as
+	// with git commit --signoff. This is for the projects that require the Developer
+	// Certificate of Origin (DCO).
+	Signoff bool
+
+	// If true, av pr refuses to submit the branches that have commits without a Signed-off-by
+	// trailer of their authors, and lists the commits.
+	RequireSignoff bool
+
+	// If true, av commit refuses to commit directly on a trunk branch and offers to create a
+	// new branch for the commit instead. av commit --force commits on the trunk anyway.
+	ProtectTrunk bool
+
+	// The template of the commit messages of av commit (e.g., "{groups}: {message}"). The
+	// placeholders are computed from the changed files. See actions.CommitTemplateData for the
+	// placeholders. Without -m, the filled template is opened in the editor.
+	MessageTemplate string
+
+	// The rules that the commit messages of av commit must follow. A message that breaks the
+	// rules is rejected before the commit is created.
+	MessageRules CommitMessageRules
+}
+
+type CommitMessageRules struct {
+	// A preset of the rules. "conventional" requires the subject to follow Conventional
+	// Commits (e.g., "feat(api): add refunds").
+	Preset string
+	// Regular expressions that the subject (the first line) must match.
+	SubjectPatterns []string
+	// The maximum length of the subject. Zero means no limit.
+	MaxSubjectLength int
+}
+
+const CommitMessagePresetConventional = "conventional"
+
+// IsEnabled returns true if any rule is configured.
+func (r CommitMessageRules) IsEnabled() bool {
+	return r.Preset != "" || len(r.SubjectPatterns) > 0 || r.MaxSubjectLength > 0
+}
+
+type CommitPathGroup struct {
+	// The name of the group, used as the subject prefix of the commit (e.g., "api").
+	Name string
+	// The glob patterns of the paths in the group (e.g., "services/api"). A path matches if
+	// the path itself or any of its parent directories matches a pattern.
+	Paths []string
+}
+
+// Match returns true if the file path belongs to the group.
+func (g CommitPathGroup) Match(fp string) bool {
+	for p := fp; p != "." && p != "/"; p = path.Dir(p) {
+		for _, pattern := range g.Paths {
+			if ok, _ := path.Match(strings.TrimSuffix(pattern, "/"), p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type Stack struct {
+	// The soft limit of the number of the branches in a stack. Commands that would make a
+	// stack deeper than this (e.g., av branch) print guidance and require --force. The limit
+	// is disabled if this is zero.
+	MaxDepth int
+
+	// The comment posted on the pull requests closed by av abandon and av stack abandon. The
+	// reason given with --reason is appended to it.
+	AbandonComment string
+
+	// What av stack abandon does with the branches of the abandoned stack: "archive"
+	// (default; the branches are moved to refs/av/archive/) or "delete".
+	AbandonBranches string
+
+	// The shell command that av stack merge --cascade-local runs on the result of merging the
+	// stack locally (e.g., "make test"). The command given on the command line takes precedence.
+	TestCommand string
+}
+
+const (
+	AbandonBranchesArchive = "archive"
+	AbandonBranchesDelete  = "delete"
+)
+
+type Sync struct {
+	// When av sync pushes the branches. One of "ask" (default), "always", "never", or
+	// "only-if-restacked" (push the branches rebased by the sync without asking). The --push
+	// flag takes precedence.
+	Push string
+	// Print the summary of the stacks touched by every sync, followed by the suggested next
+	// steps. Without it, only av sync --all prints the summary. The --summary flag takes
+	// precedence.
+	Summary bool
+}
+
+const (
+	// Ask before pushing the branches.
+	PushAsk = "ask"
+	// Push the branches without asking.
+	PushAlways = "always"
+	// Don't push the branches.
+	PushNever = "never"
+	// Push only the branches that the sync rebased, without asking.
+	PushOnlyIfRestacked = "only-if-restacked"
+)
+
+// ParsePushPolicy returns the push policy of the given value. "yes" and "no" are accepted as
+// "always" and "never".
+func ParsePushPolicy(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case PushAsk:
+		return PushAsk, nil
+	case PushAlways, "yes":
+		return PushAlways, nil
+	case PushNever, "no":
+		return PushNever, nil
+	case PushOnlyIfRestacked:
+		return PushOnlyIfRestacked, nil
+	}
+	return "", errors.Errorf(
+		"unknown push policy %q (expected %q, %q, %q, or %q)",
+		s, PushAsk, PushAlways, PushNever, PushOnlyIfRestacked,
+	)
+}
+
+type UI struct {
+	// The color theme of the output. One of "default", "colorblind" (blue and orange instead
+	// of green and red), or "monochrome" (no colors).
+	Theme string
+	// The colors that override the theme, keyed by the role (e.g., success: "#0072b2"). The
+	// colors are ANSI color numbers (0-255) or hex colors.
+	Colors map[string]string
+	// The color support of the terminal. One of "auto" (default, detected from the
+	// terminal), "none", "ansi", "ansi256", or "truecolor".
+	ColorProfile string
+}
+
+type Completion struct {
+	// If true (default), the shell completion reads the branch names and the pull request
+	// titles from a cache in the av directory instead of running Git. The cache is rebuilt by
+	// the commands that change the branches.
+	Cache bool
+}
+
+type Notification struct {
+	// The incoming webhook URL to post notifications to when a stack is submitted or a pull
+	// request in a stack is merged. Notifications are disabled if this is empty.
+	WebhookURL string
+	// The format of the webhook payload. Either "slack" (default) or "teams".
+	Format string
+}
+
+type Mirror struct {
+	// The URL of the canonical repository that the pull requests are opened against (e.g.,
+	// https://github.com/my-org/my-repo). Set this if the remote is a read-only mirror of the
+	// repository. Defaults to the fetch URL of the remote.
+	CanonicalURL string
+	// Whether the branches are pushed to a mirror that syncs them to the canonical
+	// repository under the same name (as opposed to a fork). One of "auto" (default), "yes",
+	// or "no". With "auto", the push URL is a mirror if it's on a different host than the
+	// canonical repository.
+	Push string
+}
+
+// Hooks are the shell commands to run at the lifecycle events of av. The executables in
+// .av/hooks/ of the repository (e.g., .av/hooks/pre-submit) are run after them.
+type Hooks struct {
+	// The shell commands to run before a branch is created. A failing command aborts the
+	// branch creation (e.g., to enforce a naming rule).
+	PreBranchCreate []string
+	// The shell commands to run after a branch is created.
+	PostBranchCreate []string
+	// The shell commands to run for each branch before its pull request is created or
+	// updated. A failing command aborts the submission.
+	PreSubmit []string
+	// The shell commands to run for each branch after its pull request is created or updated.
+	PostSubmit []string
+	// The shell commands to run after av sync completes.
+	PostSync []string
+	// The shell commands to run before av restack rebases the branches. A failing command
+	// aborts the restack.
+	PreRestack []string
+}
+
 var Av = struct {
-	PullRequest             PullRequest
-	GitHub                  GitHub
+	PullRequest PullRequest
+	GitHub      GitHub
+	GitLab      GitLab
+	// The code hosting service of the repository. Either "github" (default) or "gitlab".
+	// If not set, this is detected from the host of the remote URL (see ForgeForHost).
+	Forge                   string
 	Aviator                 Aviator
+	Notification            Notification
+	Hooks                   Hooks
+	Mirror                  Mirror
+	Restack                 Restack
+	Commit                  Commit
+	Stack                   Stack
+	Sync                    Sync
+	UI                      UI
+	Completion              Completion
 	AdditionalTrunkBranches []string
 	Remote                  string
+	// The remote to push the branches to, if it's different from Remote (e.g., a fork of the
+	// repository while Remote is the upstream repository). Defaults to Git's
+	// remote.pushDefault, and then to Remote.
+	PushRemote string
+	// If set, av sets branch.<name>.pushRemote of the branches that it creates to this remote
+	// so that they are pushed there (by both av and plain `git push`). The pushRemote of a
+	// branch takes precedence over PushRemote.
+	BranchPushRemote string
+	// The subject prefix that marks local-only commits (e.g., "[local]" for local debug hacks).
+	// Local-only commits stay in the local branches (and are carried along by restacks), but
+	// they are excluded from the commits pushed to the remote. Empty (the default) disables
+	// local-only commits.
+	LocalOnlyCommitPrefix string
+	// Extra Git configurations (e.g., "rerere.enabled=true") passed with -c to every git
+	// command that av runs. The repository config (hooks, merge drivers, etc.) is honored
+	// anyway, so this is only for the settings that should apply to av's git commands only.
+	GitConfig []string
+	// When to configure the upstream branch (branch.<name>.remote and branch.<name>.merge) of
+	// the branches so that plain `git push` and `git pull` use the same-name branch on the
+	// remote. One of "none" (default), "create", "push", or "git" (Git decides by
+	// branch.autoSetupMerge).
+	UpstreamTracking string
+	// If true, the av metadata of the branches is stored in the refs/av/metadata ref too, and
+	// av sync fetches and pushes it with the remote so that the stacks follow you across
+	// clones of the repository.
+	SyncMetadata bool
 }{
 	Aviator: Aviator{
 		APIHost: "https://api.aviator.co",
 	},
 	PullRequest: PullRequest{
-		OpenBrowser: true,
+		OpenBrowser:       true,
+		StackLocation:     StackLocationBody,
+		SubmitOpen:        SubmitOpenAll,
+		SubmitConcurrency: 4,
+		MergeMethod:       MergeMethodSquash,
+		CIHints: PullRequestCIHints{
+			Markers:    []string{CIHintMarkerPushOption},
+			PushOption: "ci.reduced",
+			Trailer:    "Av-CI: reduced",
+			Label:      "av:reduced-ci",
+		},
+		Suggestions: PullRequestSuggestions{
+			LargeBranchLines: 500,
+		},
+	},
+	GitHub: GitHub{MaxRetries: 5},
+	GitLab: GitLab{
+		BaseURL: "https://gitlab.com",
 	},
-	GitHub:                  GitHub{},
+	Forge:                   ForgeGitHub,
+	Notification:            Notification{},
+	UI:                      UI{Theme: "default", ColorProfile: "auto"},
+	Completion:              Completion{Cache: true},
 	AdditionalTrunkBranches: []string{},
 	Remote:                  "",
+	UpstreamTracking:        UpstreamTrackingNone,
+	Mirror:                  Mirror{Push: MirrorPushAuto},
+	Stack: Stack{
+		AbandonComment:  "This pull request was abandoned.",
+		AbandonBranches: AbandonBranchesArchive,
+	},
+	Sync: Sync{Push: PushAsk},
 }
 
 // Load initializes the configuration values.
@@ -128,6 +820,162 @@ func loadFromFile(repoConfigDir string) error {
 	if err := config.Unmarshal(&Av); err != nil {
 		return errors.Wrap(err, "failed to read av configs")
 	}
+	forgeConfigured = config.IsSet("forge")
+	switch Av.Forge {
+	case ForgeGitHub, ForgeGitLab:
+	default:
+		return errors.Errorf(
+			"invalid forge config %q (expected %q or %q)",
+			Av.Forge, ForgeGitHub, ForgeGitLab,
+		)
+	}
+	switch Av.GitHub.Transport {
+	case "", GitHubTransportHTTP, GitHubTransportGhCLI:
+	default:
+		return errors.Errorf(
+			"invalid gitHub.transport config %q (expected %q or %q)",
+			Av.GitHub.Transport, GitHubTransportHTTP, GitHubTransportGhCLI,
+		)
+	}
+	switch Av.UpstreamTracking {
+	case UpstreamTrackingNone, UpstreamTrackingCreate, UpstreamTrackingPush, UpstreamTrackingGit:
+	default:
+		return errors.Errorf(
+			"invalid upstreamTracking config %q (expected %q, %q, %q, or %q)",
+			Av.UpstreamTracking, UpstreamTrackingNone, UpstreamTrackingCreate, UpstreamTrackingPush,
+			UpstreamTrackingGit,
+		)
+	}
+	switch Av.Mirror.Push {
+	case MirrorPushAuto, MirrorPushYes, MirrorPushNo:
+	default:
+		return errors.Errorf(
+			"invalid mirror.push config %q (expected %q, %q, or %q)",
+			Av.Mirror.Push, MirrorPushAuto, MirrorPushYes, MirrorPushNo,
+		)
+	}
+	switch Av.PullRequest.StackLocation {
+	case StackLocationBody, StackLocationComment:
+	default:
+		return errors.Errorf(
+			"invalid pullRequest.stackLocation config %q (expected %q or %q)",
+			Av.PullRequest.StackLocation, StackLocationBody, StackLocationComment,
+		)
+	}
+	if _, err := ParsePushPolicy(Av.Sync.Push); err != nil {
+		return errors.WrapIf(err, "invalid sync.push config")
+	}
+	switch Av.Stack.AbandonBranches {
+	case AbandonBranchesArchive, AbandonBranchesDelete:
+	default:
+		return errors.Errorf(
+			"invalid stack.abandonBranches config %q (expected %q or %q)",
+			Av.Stack.AbandonBranches, AbandonBranchesArchive, AbandonBranchesDelete,
+		)
+	}
+	if Av.PullRequest.StackTemplate != "" {
+		if _, err := template.New("stack").Parse(Av.PullRequest.StackTemplate); err != nil {
+			return errors.WrapIf(err, "invalid pullRequest.stackTemplate config")
+		}
+	}
+	if Av.PullRequest.GeneratedBody.Template != "" {
+		if _, err := template.New("generatedBody").Parse(Av.PullRequest.GeneratedBody.Template); err != nil {
+			return errors.WrapIf(err, "invalid pullRequest.generatedBody.template config")
+		}
+	}
+	if Av.PullRequest.BranchNamePattern != "" {
+		if _, err := regexp.Compile(Av.PullRequest.BranchNamePattern); err != nil {
+			return errors.WrapIf(err, "invalid pullRequest.branchNamePattern config")
+		}
+	}
+	switch Av.PullRequest.SubmitOpen {
+	case SubmitOpenAll, SubmitOpenCreated, SubmitOpenBottom:
+	default:
+		return errors.Errorf(
+			"invalid pullRequest.submitOpen config %q (expected %q, %q, or %q)",
+			Av.PullRequest.SubmitOpen, SubmitOpenAll, SubmitOpenCreated, SubmitOpenBottom,
+		)
+	}
+	if err := ValidateMergeMethod(Av.PullRequest.MergeMethod); err != nil {
+		return errors.WrapIf(err, "invalid pullRequest.mergeMethod config")
+	}
+	if Av.PullRequest.SubmitConcurrency < 1 {
+		return errors.Errorf(
+			"invalid pullRequest.submitConcurrency config %d (expected a positive number)",
+			Av.PullRequest.SubmitConcurrency,
+		)
+	}
+	if Av.PullRequest.DraftRules.DraftAfterDepth < 0 {
+		return errors.Errorf(
+			"invalid pullRequest.draftRules.draftAfterDepth config %d (expected a non-negative number)",
+			Av.PullRequest.DraftRules.DraftAfterDepth,
+		)
+	}
+	if Av.PullRequest.CIHints.ReducedAfterDepth < 0 {
+		return errors.Errorf(
+			"invalid pullRequest.ciHints.reducedAfterDepth config %d (expected a non-negative number)",
+			Av.PullRequest.CIHints.ReducedAfterDepth,
+		)
+	}
+	for _, marker := range Av.PullRequest.CIHints.Markers {
+		switch marker {
+		case CIHintMarkerPushOption, CIHintMarkerTrailer, CIHintMarkerLabel:
+		default:
+			return errors.Errorf(
+				"invalid pullRequest.ciHints.markers config %q (expected %q, %q, or %q)",
+				marker, CIHintMarkerPushOption, CIHintMarkerTrailer, CIHintMarkerLabel,
+			)
+		}
+	}
+	if Av.PullRequest.Suggestions.LargeBranchLines < 0 {
+		return errors.Errorf(
+			"invalid pullRequest.suggestions.largeBranchLines config %d (expected a non-negative number)",
+			Av.PullRequest.Suggestions.LargeBranchLines,
+		)
+	}
+	if Av.Stack.MaxDepth < 0 {
+		return errors.Errorf(
+			"invalid stack.maxDepth config %d (expected a non-negative number)",
+			Av.Stack.MaxDepth,
+		)
+	}
+	for _, c := range Av.GitConfig {
+		if name, _, ok := strings.Cut(c, "="); !ok || name == "" {
+			return errors.Errorf("invalid gitConfig %q (expected name=value)", c)
+		}
+	}
+	for _, group := range Av.Commit.PathGroups {
+		if group.Name == "" || len(group.Paths) == 0 {
+			return errors.Errorf("invalid commit.pathGroups entry %v (expected a name and paths)", group)
+		}
+	}
+	switch Av.Commit.MessageRules.Preset {
+	case "", CommitMessagePresetConventional:
+	default:
+		return errors.Errorf(
+			"invalid commit.messageRules.preset config %q (expected %q)",
+			Av.Commit.MessageRules.Preset, CommitMessagePresetConventional,
+		)
+	}
+	for _, pattern := range Av.Commit.MessageRules.SubjectPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return errors.WrapIff(err, "invalid commit.messageRules.subjectPatterns config %q", pattern)
+		}
+	}
+	if Av.Commit.MessageRules.MaxSubjectLength < 0 {
+		return errors.Errorf(
+			"invalid commit.messageRules.maxSubjectLength config %d (expected a non-negative number)",
+			Av.Commit.MessageRules.MaxSubjectLength,
+		)
+	}
+	for _, rule := range Av.Restack.BinaryConflicts {
+		if rule.Resolve != BinaryConflictResolveParent && rule.Resolve != BinaryConflictResolveBranch {
+			return errors.Errorf(
+				"invalid restack.binaryConflicts resolution %q for %q (expected %q or %q)",
+				rule.Resolve, rule.Pattern, BinaryConflictResolveParent, BinaryConflictResolveBranch,
+			)
+		}
+	}
 	return nil
 }
 
@@ -138,6 +986,11 @@ func loadFromEnv() error {
 	} else if githubToken := os.Getenv("GITHUB_TOKEN"); githubToken != "" {
 		Av.GitHub.Token = githubToken
 	}
+	if gitlabToken := os.Getenv("AV_GITLAB_TOKEN"); gitlabToken != "" {
+		Av.GitLab.Token = gitlabToken
+	} else if gitlabToken := os.Getenv("GITLAB_TOKEN"); gitlabToken != "" {
+		Av.GitLab.Token = gitlabToken
+	}
 
 	if apiToken := os.Getenv("AV_API_TOKEN"); apiToken != "" {
 		Av.Aviator.APIToken = apiToken
@@ -0,0 +1,6 @@
+package config
+
+// NonInteractive is set by av --non-interactive (or AV_NON_INTERACTIVE=1). av never opens an
+// editor or a prompt in the non-interactive mode, and fails with the interaction-required exit
+// code instead if a command needs the user's input.
+var NonInteractive bool
@@ -11,6 +11,8 @@ import (
 // UserState is per-user state that is saved to their XDG_STATE_HOME directory.
 var UserState struct {
 	NotifiedStackSyncChange bool
+	// True if the walkthrough of resolving a restack conflict was shown to the user.
+	ExplainedConflict bool
 }
 
 // LoadUserState loads the user state.
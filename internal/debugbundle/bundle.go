@@ -0,0 +1,230 @@
+// Package debugbundle collects the sanitized diagnostics of a repository (av and Git versions,
+// the anonymized branch metadata, the recent ref updates, and the traces of the recent av
+// commands) into an archive that can be attached to a bug report.
+package debugbundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/sirupsen/logrus"
+)
+
+// The number of the recent ref updates to include.
+const maxOpLogEntries = 100
+
+// Info is the information about the environment.
+type Info struct {
+	AvVersion  string    `json:"avVersion"`
+	GitVersion string    `json:"gitVersion"`
+	GoVersion  string    `json:"goVersion"`
+	OS         string    `json:"os"`
+	Arch       string    `json:"arch"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Branch is an anonymized branch of the av metadata.
+type Branch struct {
+	Name        string `json:"name"`
+	Parent      string `json:"parent"`
+	ParentTrunk bool   `json:"parentTrunk,omitempty"`
+	// The commit of the parent branch that the branch was last restacked onto.
+	ParentHead string `json:"parentHead,omitempty"`
+	// The current commit of the branch. Empty if the branch doesn't exist in Git.
+	Head         string `json:"head,omitempty"`
+	MergeCommit  string `json:"mergeCommit,omitempty"`
+	PinnedCommit string `json:"pinnedCommit,omitempty"`
+	FrozenBase   string `json:"frozenBase,omitempty"`
+	// The state of the pull request (e.g., "OPEN"). Empty if there's no pull request.
+	PullRequestState string `json:"pullRequestState,omitempty"`
+}
+
+// Bundle is the collected diagnostics.
+type Bundle struct {
+	Info     Info
+	Branches []Branch
+	// The recent updates of HEAD and the branches (date, ref, commit, and action). The reflog
+	// messages are not included since they can contain the commit messages.
+	OpLog []string
+	// The sanitized traces of the recent av commands keyed by the file name.
+	Traces map[string]string
+}
+
+// Collect collects the diagnostics of the repository.
+func Collect(repo *git.Repo, tx meta.ReadTx) (*Bundle, error) {
+	home, _ := os.UserHomeDir()
+	anon := NewAnonymizer(tx, map[string]string{repo.Dir(): "<repo>", home: "~"})
+
+	gitVersion, err := repo.Git("version")
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{
+		Info: Info{
+			AvVersion:  config.Version,
+			GitVersion: strings.TrimPrefix(gitVersion, "git version "),
+			GoVersion:  runtime.Version(),
+			OS:         runtime.GOOS,
+			Arch:       runtime.GOARCH,
+			CreatedAt:  time.Now().UTC(),
+		},
+		Traces: map[string]string{},
+	}
+
+	var names []string
+	for name := range tx.AllBranches() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		br, _ := tx.Branch(name)
+		ab := Branch{
+			Name:         anon.Branch(name),
+			Parent:       anon.Branch(br.Parent.Name),
+			ParentTrunk:  br.Parent.Trunk,
+			ParentHead:   br.Parent.Head,
+			MergeCommit:  br.MergeCommit,
+			PinnedCommit: br.PinnedCommit,
+			FrozenBase:   br.FrozenBase,
+		}
+		if head, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + name}); err == nil {
+			ab.Head = head
+		}
+		if br.PullRequest != nil {
+			ab.PullRequestState = string(br.PullRequest.State)
+		}
+		b.Branches = append(b.Branches, ab)
+	}
+
+	b.OpLog = collectOpLog(repo, anon, append([]string{"HEAD"}, names...))
+
+	traces, err := ListTraces(repo.AvDir())
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range traces {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.WrapIf(err, "failed to read a trace")
+		}
+		b.Traces[filepath.Base(path)] = anon.Text(string(data))
+	}
+	return b, nil
+}
+
+type opLogEntry struct {
+	time int64
+	line string
+}
+
+func collectOpLog(repo *git.Repo, anon *Anonymizer, refs []string) []string {
+	var entries []opLogEntry
+	for _, ref := range refs {
+		fullRef := ref
+		if ref != "HEAD" {
+			fullRef = "refs/heads/" + ref
+		}
+		out, err := repo.Git(
+			"log", "-g", "--date=unix", "--format=%gd%x09%H%x09%gs",
+			"-n", strconv.Itoa(maxOpLogEntries), fullRef, "--",
+		)
+		if err != nil {
+			// The branch doesn't exist or doesn't have a reflog.
+			logrus.WithError(err).WithField("ref", ref).Debug("failed to read the reflog")
+			continue
+		}
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.SplitN(line, "\t", 3)
+			if len(fields) != 3 {
+				continue
+			}
+			start, end := strings.Index(fields[0], "@{"), strings.LastIndex(fields[0], "}")
+			if start < 0 || end < start {
+				continue
+			}
+			ts, err := strconv.ParseInt(fields[0][start+2:end], 10, 64)
+			if err != nil {
+				continue
+			}
+			// Keep only the action (e.g., "commit (amend)" or "rebase (finish)").
+			action, _, _ := strings.Cut(fields[2], ":")
+			entries = append(entries, opLogEntry{
+				time: ts,
+				line: strings.Join([]string{
+					time.Unix(ts, 0).UTC().Format(time.RFC3339), anon.Branch(ref), fields[1], action,
+				}, "\t"),
+			})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].time > entries[j].time })
+	if len(entries) > maxOpLogEntries {
+		entries = entries[:maxOpLogEntries]
+	}
+	var ret []string
+	for _, e := range entries {
+		ret = append(ret, e.line)
+	}
+	return ret
+}
+
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+// WriteArchive writes the bundle as a gzipped tar archive.
+func (b *Bundle) WriteArchive(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	info, err := json.MarshalIndent(b.Info, "", "  ")
+	if err != nil {
+		return err
+	}
+	branches, err := json.MarshalIndent(b.Branches, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := []archiveFile{
+		{"info.json", info},
+		{"branches.json", branches},
+		{"oplog.txt", []byte(strings.Join(b.OpLog, "\n") + "\n")},
+	}
+	var traceNames []string
+	for name := range b.Traces {
+		traceNames = append(traceNames, name)
+	}
+	sort.Strings(traceNames)
+	for _, name := range traceNames {
+		files = append(files, archiveFile{"traces/" + name, []byte(b.Traces[name])})
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    "av-debug-bundle/" + f.name,
+			Mode:    0o644,
+			Size:    int64(len(f.data)),
+			ModTime: b.Info.CreatedAt,
+		}); err != nil {
+			return errors.WrapIf(err, "failed to write the archive")
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return errors.WrapIf(err, "failed to write the archive")
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errors.WrapIf(err, "failed to write the archive")
+	}
+	return gw.Close()
+}
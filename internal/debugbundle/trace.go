@@ -0,0 +1,178 @@
+package debugbundle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// The number of the command traces to keep.
+const maxTraces = 20
+
+// The flags whose values are free-form texts (e.g., pull request titles) that are not recorded
+// in the traces.
+var redactedFlags = []string{"title", "body", "message"}
+
+// TraceDir returns the directory that keeps the traces of the recent av commands.
+func TraceDir(avDir string) string {
+	return filepath.Join(avDir, "traces")
+}
+
+// Trace records the arguments, the logs, and the result of an av command so that they can be
+// included in a debug bundle. The traces are recorded only for the commands run with --debug.
+type Trace struct {
+	mu    sync.Mutex
+	file  *os.File
+	start time.Time
+}
+
+// StartTrace starts recording the trace of the current command. The flags of the command are
+// used to redact the arguments (see RedactArgs). The oldest traces are removed so that only the
+// recent ones are kept.
+func StartTrace(avDir string, args []string, flags *pflag.FlagSet) (*Trace, error) {
+	dir := TraceDir(avDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.WrapIf(err, "failed to create the trace directory")
+	}
+	start := time.Now()
+	name := fmt.Sprintf("%s-%d.log", start.UTC().Format("20060102T150405.000Z"), os.Getpid())
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to create the trace file")
+	}
+	fmt.Fprintf(file, "command: av %s\nstarted: %s\n", strings.Join(RedactArgs(args, flags), " "), start.Format(time.RFC3339))
+	t := &Trace{file: file, start: start}
+	logrus.AddHook(t)
+	pruneTraces(dir)
+	return t, nil
+}
+
+// RedactArgs removes the values of the flags that can contain free-form texts. The flags of the
+// command tell which short flags take the values, since the same letter can be a boolean flag
+// in another command (e.g., av commit -b).
+func RedactArgs(args []string, flags *pflag.FlagSet) []string {
+	var shorthands string
+	if flags != nil {
+		flags.VisitAll(func(f *pflag.Flag) {
+			if slices.Contains(redactedFlags, f.Name) && f.Shorthand != "" &&
+				f.Value.Type() != "bool" {
+				shorthands += f.Shorthand
+			}
+		})
+	}
+	var ret []string
+	redactNext := false
+	for _, arg := range args {
+		if redactNext {
+			ret = append(ret, "<redacted>")
+			redactNext = false
+			continue
+		}
+		if arg == "--" {
+			ret = append(ret, arg)
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			for _, name := range redactedFlags {
+				flag := "--" + name
+				if arg == flag {
+					redactNext = true
+				} else if strings.HasPrefix(arg, flag+"=") {
+					arg = flag + "=<redacted>"
+				}
+			}
+		} else if strings.HasPrefix(arg, "-") && shorthands != "" {
+			// Short flags can be combined (e.g., -am "message" or -m"message").
+			if i := strings.IndexAny(arg[1:], shorthands); i >= 0 {
+				if i+2 == len(arg) {
+					redactNext = true
+				} else {
+					arg = arg[:i+2] + "<redacted>"
+				}
+			}
+		}
+		ret = append(ret, arg)
+	}
+	return ret
+}
+
+// Levels implements logrus.Hook.
+func (t *Trace) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+var traceFormatter = &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+
+// Fire implements logrus.Hook.
+func (t *Trace) Fire(entry *logrus.Entry) error {
+	line, err := traceFormatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil {
+		return nil
+	}
+	_, err = t.file.Write(line)
+	return err
+}
+
+// Finish records the result of the command and closes the trace.
+func (t *Trace) Finish(exitCode int, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.file, "duration: %s\nexit code: %d\n", time.Since(t.start), exitCode)
+	if err != nil {
+		// Include the stack trace of the error.
+		fmt.Fprintf(t.file, "error: %+v\n", err)
+	}
+	_ = t.file.Close()
+	t.file = nil
+}
+
+// ListTraces returns the paths of the recorded traces from the oldest.
+func ListTraces(avDir string) ([]string, error) {
+	entries, err := os.ReadDir(TraceDir(avDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to read the trace directory")
+	}
+	var ret []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".log") {
+			ret = append(ret, filepath.Join(TraceDir(avDir), entry.Name()))
+		}
+	}
+	// The file names start with the timestamps.
+	sort.Strings(ret)
+	return ret, nil
+}
+
+func pruneTraces(dir string) {
+	traces, err := ListTraces(filepath.Dir(dir))
+	if err != nil {
+		logrus.WithError(err).Debug("failed to list the traces")
+		return
+	}
+	for len(traces) > maxTraces {
+		if err := os.Remove(traces[0]); err != nil {
+			logrus.WithError(err).Debug("failed to remove an old trace")
+		}
+		traces = traces[1:]
+	}
+}
@@ -5,10 +5,12 @@ import (
 	"bytes"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/uiutils"
 	"github.com/kballard/go-shellquote"
 	"github.com/sirupsen/logrus"
 )
@@ -50,6 +52,9 @@ func Launch(repo *git.Repo, config Config) (string, error) {
 	if config.Command == CommandNoOp {
 		return config.Text, nil
 	}
+	if err := uiutils.RequireInteractive("opening the editor", ""); err != nil {
+		return "", err
+	}
 
 	tmp, err := os.CreateTemp("", config.TmpFilePattern)
 	if err != nil {
@@ -72,7 +77,7 @@ func Launch(repo *git.Repo, config Config) (string, error) {
 	// both flags and use editor executables with spaces.
 	// e.g., EDITOR="'/path/with spaces/editor'" or
 	// EDITOR="code --wait" work.
-	args, err := shellquote.Split(config.Command)
+	args, err := splitCommand(config.Command, runtime.GOOS == "windows")
 	if err != nil {
 		return "", errors.Wrapf(err, "invalid editor command: %q", config.Command)
 	}
@@ -94,6 +99,28 @@ func Launch(repo *git.Repo, config Config) (string, error) {
 	return parseResult(tmp.Name(), config)
 }
 
+// splitCommand splits the editor command with the shell syntax. On Windows, an unquoted
+// backslash is a path separator (e.g., C:\Windows\notepad.exe) rather than an escape character.
+func splitCommand(command string, windows bool) ([]string, error) {
+	if windows {
+		var sb strings.Builder
+		var quote rune
+		for _, c := range command {
+			switch {
+			case quote == 0 && (c == '\'' || c == '"'):
+				quote = c
+			case c == quote:
+				quote = 0
+			case quote == 0 && c == '\\':
+				sb.WriteRune(c)
+			}
+			sb.WriteRune(c)
+		}
+		command = sb.String()
+	}
+	return shellquote.Split(command)
+}
+
 func DefaultCommand(repo *git.Repo) string {
 	editor, err := repo.Git("var", "GIT_EDITOR")
 	if err != nil {
@@ -112,7 +139,8 @@ func parseResult(path string, config Config) (string, error) {
 	scan := bufio.NewScanner(f)
 	res := bytes.NewBuffer(nil)
 	for scan.Scan() {
-		line := scan.Text()
+		// Editors on Windows may save the file with CRLF line endings.
+		line := strings.TrimSuffix(scan.Text(), "\r")
 		if strings.HasPrefix(line, config.CommentPrefix) {
 			// Skip this line altogether (including the newline).
 			continue
@@ -56,3 +56,34 @@ func TestEditor(t *testing.T) {
 		require.Equal(t, tt.out, res)
 	}
 }
+
+func TestEditorCRLF(t *testing.T) {
+	res, err := Launch(nil, Config{
+		Text:          "Hello world!\r\n%% This is a comment\r\nBonjour le monde!\r\n",
+		CommentPrefix: "%%",
+		Command:       "true",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Hello world!\nBonjour le monde!\n", res)
+}
+
+func TestSplitCommand(t *testing.T) {
+	for _, tt := range []struct {
+		command string
+		windows bool
+		args    []string
+	}{
+		{`code --wait`, false, []string{"code", "--wait"}},
+		{`'/path/with spaces/editor'`, false, []string{"/path/with spaces/editor"}},
+		{`C:\Windows\notepad.exe`, true, []string{`C:\Windows\notepad.exe`}},
+		{
+			`"C:\Program Files\Notepad++\notepad++.exe" -multiInst`, true,
+			[]string{`C:\Program Files\Notepad++\notepad++.exe`, "-multiInst"},
+		},
+		{`'C:\Program Files\editor.exe' --wait`, true, []string{`C:\Program Files\editor.exe`, "--wait"}},
+	} {
+		args, err := splitCommand(tt.command, tt.windows)
+		require.NoError(t, err)
+		require.Equal(t, tt.args, args, "command %q", tt.command)
+	}
+}
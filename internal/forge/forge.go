@@ -0,0 +1,79 @@
+// Package forge abstracts the code hosting service (GitHub or GitLab) that hosts the pull
+// requests of the repository.
+//
+// The pull requests are represented with the GitHub types (gh.PullRequest) since the branch
+// metadata is modeled after GitHub. GitLab merge requests are converted to them.
+package forge
+
+import (
+	"context"
+
+	"github.com/aviator-co/av/internal/gh"
+)
+
+// Forge is the service that hosts the pull requests of the repository.
+type Forge interface {
+	// Name returns the display name of the forge (e.g., "GitHub").
+	Name() string
+	// PullRequest returns the pull request with the given ID.
+	PullRequest(ctx context.Context, id string) (*gh.PullRequest, error)
+	// PullRequestByNumber returns the pull request with the given number (the merge request
+	// IID on GitLab).
+	PullRequestByNumber(ctx context.Context, number int64) (*gh.PullRequest, error)
+	// PullRequests returns the pull requests with the given IDs. The pull requests that are
+	// not found are omitted. GitHub queries them at once.
+	PullRequests(ctx context.Context, ids []string) ([]gh.PullRequest, error)
+	// BranchPullRequests returns the pull requests (in any state) whose head branch is the
+	// given branch.
+	BranchPullRequests(ctx context.Context, headBranch string) ([]gh.PullRequest, error)
+	// CreatePullRequest creates a new pull request.
+	CreatePullRequest(ctx context.Context, input CreatePullRequestInput) (*gh.PullRequest, error)
+	// UpdatePullRequest updates the given fields of the pull request.
+	UpdatePullRequest(ctx context.Context, input UpdatePullRequestInput) (*gh.PullRequest, error)
+	// ConvertPullRequestToDraft converts the pull request to a draft.
+	ConvertPullRequestToDraft(ctx context.Context, id string) (*gh.PullRequest, error)
+	// MarkPullRequestReadyForReview marks the draft pull request as ready for review.
+	MarkPullRequestReadyForReview(ctx context.Context, id string) (*gh.PullRequest, error)
+	// EnableAutoMerge enables auto-merge on the pull request with the merge method ("merge",
+	// "squash", or "rebase"). The pull request is merged once its checks pass.
+	EnableAutoMerge(ctx context.Context, id string, method string) error
+	// ClosePullRequest closes the pull request without merging it. If the comment is not
+	// empty, it's added to the pull request before closing it.
+	ClosePullRequest(ctx context.Context, id string, comment string) (*gh.PullRequest, error)
+	// UpsertComment edits the comment on the pull request that contains the marker, or adds a
+	// new comment if there is none. The body should contain the marker.
+	UpsertComment(ctx context.Context, id string, marker string, body string) error
+	// OpenPullRequestsWithBase returns the open pull requests whose base branch is the given
+	// branch.
+	OpenPullRequestsWithBase(ctx context.Context, baseBranch string) ([]gh.BasedPullRequest, error)
+}
+
+type CreatePullRequestInput struct {
+	BaseRefName string
+	HeadRefName string
+	// The owner of the repository that has the head branch if it's a fork of the repository
+	// (e.g., the user who forked the repository). Empty if the head branch is in the
+	// repository itself.
+	HeadRepositoryOwner string
+	Title               string
+	Body                string
+	Draft               bool
+}
+
+type UpdatePullRequestInput struct {
+	// The ID of the pull request to update.
+	ID string
+	// The fields to update. Nil fields are left unchanged.
+	BaseRefName *string
+	Title       *string
+	Body        *string
+}
+
+// GitHubClient returns the GitHub API client of the forge, or nil if the forge is not GitHub.
+// This is for the features that are only available on GitHub (e.g., reviewer requests).
+func GitHubClient(f Forge) *gh.Client {
+	if g, ok := f.(*GitHub); ok {
+		return g.client
+	}
+	return nil
+}
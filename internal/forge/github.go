@@ -0,0 +1,163 @@
+package forge
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/shurcooL/githubv4"
+)
+
+// GitHub is the forge backed by the GitHub GraphQL API.
+type GitHub struct {
+	client *gh.Client
+	repo   meta.Repository
+}
+
+var _ Forge = (*GitHub)(nil)
+
+// NewGitHub returns the forge for the given GitHub repository.
+func NewGitHub(client *gh.Client, repo meta.Repository) *GitHub {
+	return &GitHub{client: client, repo: repo}
+}
+
+func (f *GitHub) Name() string {
+	return "GitHub"
+}
+
+func (f *GitHub) PullRequest(ctx context.Context, id string) (*gh.PullRequest, error) {
+	return f.client.PullRequest(ctx, id)
+}
+
+func (f *GitHub) PullRequestByNumber(ctx context.Context, number int64) (*gh.PullRequest, error) {
+	return f.client.PullRequestByNumber(ctx, gh.PullRequestOpts{
+		Owner:  f.repo.Owner,
+		Repo:   f.repo.Name,
+		Number: number,
+	})
+}
+
+func (f *GitHub) PullRequests(ctx context.Context, ids []string) ([]gh.PullRequest, error) {
+	return f.client.PullRequests(ctx, ids)
+}
+
+func (f *GitHub) BranchPullRequests(
+	ctx context.Context,
+	headBranch string,
+) ([]gh.PullRequest, error) {
+	page, err := f.client.GetPullRequests(ctx, gh.GetPullRequestsInput{
+		Owner:       f.repo.Owner,
+		Repo:        f.repo.Name,
+		HeadRefName: headBranch,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page.PullRequests, nil
+}
+
+func (f *GitHub) CreatePullRequest(
+	ctx context.Context,
+	input CreatePullRequestInput,
+) (*gh.PullRequest, error) {
+	headRefName := input.HeadRefName
+	if input.HeadRepositoryOwner != "" {
+		// A cross-repository pull request is created with the head branch namespaced by the
+		// owner of the fork.
+		headRefName = input.HeadRepositoryOwner + ":" + headRefName
+	}
+	return f.client.CreatePullRequest(ctx, githubv4.CreatePullRequestInput{
+		RepositoryID: githubv4.ID(f.repo.ID),
+		BaseRefName:  githubv4.String(input.BaseRefName),
+		HeadRefName:  githubv4.String(headRefName),
+		Title:        githubv4.String(input.Title),
+		Body:         gh.Ptr(githubv4.String(input.Body)),
+		Draft:        gh.Ptr(githubv4.Boolean(input.Draft)),
+	})
+}
+
+func (f *GitHub) UpdatePullRequest(
+	ctx context.Context,
+	input UpdatePullRequestInput,
+) (*gh.PullRequest, error) {
+	ghInput := githubv4.UpdatePullRequestInput{PullRequestID: githubv4.ID(input.ID)}
+	if input.BaseRefName != nil {
+		ghInput.BaseRefName = gh.Ptr(githubv4.String(*input.BaseRefName))
+	}
+	if input.Title != nil {
+		ghInput.Title = gh.Ptr(githubv4.String(*input.Title))
+	}
+	if input.Body != nil {
+		ghInput.Body = gh.Ptr(githubv4.String(*input.Body))
+	}
+	return f.client.UpdatePullRequest(ctx, ghInput)
+}
+
+func (f *GitHub) ConvertPullRequestToDraft(ctx context.Context, id string) (*gh.PullRequest, error) {
+	return f.client.ConvertPullRequestToDraft(ctx, id)
+}
+
+func (f *GitHub) MarkPullRequestReadyForReview(
+	ctx context.Context,
+	id string,
+) (*gh.PullRequest, error) {
+	return f.client.MarkPullRequestReadyForReview(ctx, id)
+}
+
+func (f *GitHub) EnableAutoMerge(ctx context.Context, id string, method string) error {
+	return f.client.EnablePullRequestAutoMerge(ctx, githubv4.EnablePullRequestAutoMergeInput{
+		PullRequestID: githubv4.ID(id),
+		MergeMethod:   gh.Ptr(githubv4.PullRequestMergeMethod(strings.ToUpper(method))),
+	})
+}
+
+func (f *GitHub) ClosePullRequest(
+	ctx context.Context,
+	id string,
+	comment string,
+) (*gh.PullRequest, error) {
+	if comment != "" {
+		if err := f.client.AddComment(ctx, githubv4.AddCommentInput{
+			SubjectID: id,
+			Body:      githubv4.String(comment),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return f.client.ClosePullRequest(ctx, id)
+}
+
+func (f *GitHub) UpsertComment(
+	ctx context.Context,
+	id string,
+	marker string,
+	body string,
+) error {
+	comments, err := f.client.PullRequestComments(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		if comment.ViewerDidAuthor && strings.Contains(comment.Body, marker) {
+			if comment.Body == body {
+				return nil
+			}
+			return f.client.UpdateIssueComment(ctx, githubv4.UpdateIssueCommentInput{
+				ID:   comment.ID,
+				Body: githubv4.String(body),
+			})
+		}
+	}
+	return f.client.AddComment(ctx, githubv4.AddCommentInput{
+		SubjectID: id,
+		Body:      githubv4.String(body),
+	})
+}
+
+func (f *GitHub) OpenPullRequestsWithBase(
+	ctx context.Context,
+	baseBranch string,
+) ([]gh.BasedPullRequest, error) {
+	return f.client.OpenPullRequestsWithBase(ctx, f.repo.Owner, f.repo.Name, baseBranch)
+}
@@ -0,0 +1,477 @@
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+)
+
+// The title prefix that marks a GitLab merge request as a draft.
+const gitLabDraftPrefix = "Draft: "
+
+// The title prefixes that GitLab recognizes as a draft marker.
+var gitLabDraftPrefixPattern = regexp.MustCompile(`^(?i)(\[draft\]|\(draft\)|draft:|draft -)\s*`)
+
+func trimGitLabDraftPrefix(title string) string {
+	return gitLabDraftPrefixPattern.ReplaceAllString(title, "")
+}
+
+// GitLab is the forge backed by the GitLab REST API (v4). Merge requests are identified by
+// their IID (the number shown in the UI, e.g., !42).
+type GitLab struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+	// The full path of the project (e.g., my-group/my-subgroup/my-project).
+	project string
+}
+
+var _ Forge = (*GitLab)(nil)
+
+// NewGitLab returns the forge for the given GitLab project. baseURL is the URL of the GitLab
+// instance (e.g., https://gitlab.com) and project is the full path of the project.
+func NewGitLab(baseURL string, token string, project string) (*GitLab, error) {
+	if token == "" {
+		return nil, errutils.WithFailureClass(
+			errors.New("no GitLab token provided (do you need to configure one?)"),
+			errutils.FailureAuth,
+		)
+	}
+	return &GitLab{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		project:    project,
+	}, nil
+}
+
+// GitLabProject is a GitLab project.
+type GitLabProject struct {
+	ID                int64  `json:"id"`
+	Path              string `json:"path"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Namespace         struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+}
+
+type gitLabUser struct {
+	Username string `json:"username"`
+}
+
+type gitLabMergeRequest struct {
+	IID             int64      `json:"iid"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	State           string     `json:"state"`
+	Draft           bool       `json:"draft"`
+	SourceBranch    string     `json:"source_branch"`
+	TargetBranch    string     `json:"target_branch"`
+	WebURL          string     `json:"web_url"`
+	SHA             string     `json:"sha"`
+	MergeCommitSHA  string     `json:"merge_commit_sha"`
+	SquashCommitSHA string     `json:"squash_commit_sha"`
+	Author          gitLabUser `json:"author"`
+}
+
+// pullRequest converts the merge request to the pull request representation used by av.
+func (mr *gitLabMergeRequest) pullRequest() *gh.PullRequest {
+	pr := &gh.PullRequest{
+		ID:          strconv.FormatInt(mr.IID, 10),
+		Number:      mr.IID,
+		HeadRefName: mr.SourceBranch,
+		BaseRefName: mr.TargetBranch,
+		IsDraft:     mr.Draft,
+		Permalink:   mr.WebURL,
+		Title:       trimGitLabDraftPrefix(mr.Title),
+		Body:        mr.Description,
+	}
+	switch mr.State {
+	case "merged":
+		pr.State = githubv4.PullRequestStateMerged
+		// A fast-forward merge doesn't create a merge commit, and the head commit is put on
+		// the target branch as is.
+		switch {
+		case mr.MergeCommitSHA != "":
+			pr.PRIVATE_MergeCommit.Oid = mr.MergeCommitSHA
+		case mr.SquashCommitSHA != "":
+			pr.PRIVATE_MergeCommit.Oid = mr.SquashCommitSHA
+		default:
+			pr.PRIVATE_MergeCommit.Oid = mr.SHA
+		}
+	case "closed":
+		pr.State = githubv4.PullRequestStateClosed
+	default:
+		// "opened" or "locked"
+		pr.State = githubv4.PullRequestStateOpen
+	}
+	return pr
+}
+
+func (f *GitLab) Name() string {
+	return "GitLab"
+}
+
+// Project returns the GitLab project.
+func (f *GitLab) Project(ctx context.Context) (*GitLabProject, error) {
+	var project GitLabProject
+	if err := f.do(ctx, http.MethodGet, f.projectPath(""), nil, &project); err != nil {
+		return nil, errors.WrapIff(err, "failed to query GitLab project %q", f.project)
+	}
+	return &project, nil
+}
+
+func (f *GitLab) PullRequest(ctx context.Context, id string) (*gh.PullRequest, error) {
+	mr, err := f.mergeRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return mr.pullRequest(), nil
+}
+
+func (f *GitLab) PullRequestByNumber(ctx context.Context, number int64) (*gh.PullRequest, error) {
+	// The merge requests are identified by their IIDs.
+	return f.PullRequest(ctx, strconv.FormatInt(number, 10))
+}
+
+func (f *GitLab) PullRequests(ctx context.Context, ids []string) ([]gh.PullRequest, error) {
+	var ret []gh.PullRequest
+	// The merge requests are listed by 100 at most.
+	for start := 0; start < len(ids); start += 100 {
+		end := min(start+100, len(ids))
+		mrs, err := f.mergeRequests(ctx, url.Values{
+			"iids[]": ids[start:end],
+			"state":  {"all"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, mr := range mrs {
+			ret = append(ret, *mr.pullRequest())
+		}
+	}
+	return ret, nil
+}
+
+func (f *GitLab) BranchPullRequests(
+	ctx context.Context,
+	headBranch string,
+) ([]gh.PullRequest, error) {
+	mrs, err := f.mergeRequests(ctx, url.Values{
+		"source_branch": {headBranch},
+		"state":         {"all"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ret []gh.PullRequest
+	for _, mr := range mrs {
+		ret = append(ret, *mr.pullRequest())
+	}
+	return ret, nil
+}
+
+func (f *GitLab) CreatePullRequest(
+	ctx context.Context,
+	input CreatePullRequestInput,
+) (*gh.PullRequest, error) {
+	if input.HeadRepositoryOwner != "" {
+		return nil, errors.New("creating merge requests from a fork is not supported on GitLab")
+	}
+	title := input.Title
+	if input.Draft {
+		title = gitLabDraftPrefix + title
+	}
+	var mr gitLabMergeRequest
+	if err := f.do(ctx, http.MethodPost, f.projectPath("/merge_requests"), map[string]any{
+		"source_branch": input.HeadRefName,
+		"target_branch": input.BaseRefName,
+		"title":         title,
+		"description":   input.Body,
+	}, &mr); err != nil {
+		return nil, errors.WrapIf(err, "failed to create merge request: gitlab error")
+	}
+	return mr.pullRequest(), nil
+}
+
+func (f *GitLab) UpdatePullRequest(
+	ctx context.Context,
+	input UpdatePullRequestInput,
+) (*gh.PullRequest, error) {
+	params := map[string]any{}
+	if input.BaseRefName != nil {
+		params["target_branch"] = *input.BaseRefName
+	}
+	if input.Body != nil {
+		params["description"] = *input.Body
+	}
+	if input.Title != nil {
+		// The draft state is a part of the title on GitLab, so keep it.
+		current, err := f.mergeRequest(ctx, input.ID)
+		if err != nil {
+			return nil, err
+		}
+		title := *input.Title
+		if current.Draft {
+			title = gitLabDraftPrefix + title
+		}
+		params["title"] = title
+	}
+	return f.updateMergeRequest(ctx, input.ID, params)
+}
+
+func (f *GitLab) ConvertPullRequestToDraft(ctx context.Context, id string) (*gh.PullRequest, error) {
+	return f.setDraft(ctx, id, true)
+}
+
+func (f *GitLab) MarkPullRequestReadyForReview(
+	ctx context.Context,
+	id string,
+) (*gh.PullRequest, error) {
+	return f.setDraft(ctx, id, false)
+}
+
+// EnableAutoMerge sets the merge request to be merged when the pipeline succeeds. GitLab merges
+// with the merge method of the project, so only "squash" is honored (as the squash option of the
+// merge request).
+func (f *GitLab) EnableAutoMerge(ctx context.Context, id string, method string) error {
+	var mr gitLabMergeRequest
+	if err := f.do(ctx, http.MethodPut, f.projectPath("/merge_requests/"+id+"/merge"), map[string]any{
+		"merge_when_pipeline_succeeds": true,
+		"squash":                       method == "squash",
+	}, &mr); err != nil {
+		return errors.WrapIff(err, "failed to set merge request !%s to merge when the pipeline succeeds", id)
+	}
+	return nil
+}
+
+func (f *GitLab) ClosePullRequest(
+	ctx context.Context,
+	id string,
+	comment string,
+) (*gh.PullRequest, error) {
+	if comment != "" {
+		var note struct {
+			ID int64 `json:"id"`
+		}
+		if err := f.do(
+			ctx, http.MethodPost, f.projectPath("/merge_requests/"+id+"/notes"),
+			map[string]any{"body": comment}, &note,
+		); err != nil {
+			return nil, errors.WrapIff(err, "failed to comment on merge request !%s", id)
+		}
+	}
+	return f.updateMergeRequest(ctx, id, map[string]any{"state_event": "close"})
+}
+
+func (f *GitLab) UpsertComment(
+	ctx context.Context,
+	id string,
+	marker string,
+	body string,
+) error {
+	var notes []struct {
+		ID     int64  `json:"id"`
+		Body   string `json:"body"`
+		System bool   `json:"system"`
+	}
+	var note struct {
+		ID int64 `json:"id"`
+	}
+	notesPath := f.projectPath("/merge_requests/" + id + "/notes")
+	if err := f.do(
+		ctx, http.MethodGet, notesPath+"?per_page=100&sort=desc", nil, &notes,
+	); err != nil {
+		return errors.WrapIff(err, "failed to query the comments of merge request !%s", id)
+	}
+	for _, n := range notes {
+		if n.System || !strings.Contains(n.Body, marker) {
+			continue
+		}
+		if n.Body == body {
+			return nil
+		}
+		if err := f.do(
+			ctx, http.MethodPut, notesPath+"/"+strconv.FormatInt(n.ID, 10),
+			map[string]any{"body": body}, &note,
+		); err != nil {
+			return errors.WrapIff(err, "failed to update the comment on merge request !%s", id)
+		}
+		return nil
+	}
+	if err := f.do(ctx, http.MethodPost, notesPath, map[string]any{"body": body}, &note); err != nil {
+		return errors.WrapIff(err, "failed to comment on merge request !%s", id)
+	}
+	return nil
+}
+
+func (f *GitLab) OpenPullRequestsWithBase(
+	ctx context.Context,
+	baseBranch string,
+) ([]gh.BasedPullRequest, error) {
+	mrs, err := f.mergeRequests(ctx, url.Values{
+		"target_branch": {baseBranch},
+		"state":         {"opened"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ret []gh.BasedPullRequest
+	for _, mr := range mrs {
+		pr := gh.BasedPullRequest{
+			Number:      mr.IID,
+			Permalink:   mr.WebURL,
+			HeadRefName: mr.SourceBranch,
+		}
+		pr.Author.Login = mr.Author.Username
+		ret = append(ret, pr)
+	}
+	return ret, nil
+}
+
+func (f *GitLab) setDraft(ctx context.Context, id string, draft bool) (*gh.PullRequest, error) {
+	current, err := f.mergeRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Draft == draft {
+		return current.pullRequest(), nil
+	}
+	title := trimGitLabDraftPrefix(current.Title)
+	if draft {
+		title = gitLabDraftPrefix + title
+	}
+	return f.updateMergeRequest(ctx, id, map[string]any{"title": title})
+}
+
+func (f *GitLab) mergeRequest(ctx context.Context, id string) (*gitLabMergeRequest, error) {
+	var mr gitLabMergeRequest
+	if err := f.do(ctx, http.MethodGet, f.projectPath("/merge_requests/"+id), nil, &mr); err != nil {
+		return nil, errors.WrapIff(err, "failed to query merge request !%s", id)
+	}
+	return &mr, nil
+}
+
+func (f *GitLab) mergeRequests(
+	ctx context.Context,
+	query url.Values,
+) ([]gitLabMergeRequest, error) {
+	query.Set("per_page", "100")
+	var mrs []gitLabMergeRequest
+	if err := f.do(
+		ctx, http.MethodGet, f.projectPath("/merge_requests?"+query.Encode()), nil, &mrs,
+	); err != nil {
+		return nil, errors.WrapIf(err, "failed to query merge requests")
+	}
+	return mrs, nil
+}
+
+func (f *GitLab) updateMergeRequest(
+	ctx context.Context,
+	id string,
+	params map[string]any,
+) (*gh.PullRequest, error) {
+	var mr gitLabMergeRequest
+	if err := f.do(ctx, http.MethodPut, f.projectPath("/merge_requests/"+id), params, &mr); err != nil {
+		return nil, errors.WrapIf(err, "failed to update merge request: gitlab error")
+	}
+	return mr.pullRequest(), nil
+}
+
+// projectPath returns the API path of the project followed by the given suffix. The project
+// path is URL-encoded as a whole (e.g., my-group%2Fmy-project) as GitLab requires.
+func (f *GitLab) projectPath(suffix string) string {
+	return "/projects/" + url.PathEscape(f.project) + suffix
+}
+
+func (f *GitLab) do(
+	ctx context.Context,
+	method string,
+	path string,
+	params map[string]any,
+	result any,
+) (reterr error) {
+	var body io.Reader
+	if params != nil {
+		bs, err := json.Marshal(params)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		body = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, f.baseURL+"/api/v4"+path, body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("PRIVATE-TOKEN", f.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	log := logrus.WithFields(logrus.Fields{"method": method, "path": path})
+	log.Debug("executing GitLab API request...")
+	startTime := time.Now()
+	defer func() {
+		log := log.WithField("elapsed", time.Since(startTime))
+		if reterr != nil {
+			log.WithError(reterr).Debug("GitLab API request failed")
+		} else {
+			log.Debug("GitLab API request succeeded")
+		}
+	}()
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := errors.Errorf("GitLab API returned %s: %s", resp.Status, gitLabErrorMessage(respBody))
+		if resp.StatusCode == http.StatusUnauthorized {
+			return errutils.WithFailureClass(err, errutils.FailureAuth)
+		}
+		return err
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return errors.Wrap(err, "failed to decode the GitLab API response")
+	}
+	return nil
+}
+
+// gitLabErrorMessage extracts the error message from the GitLab API error response. The
+// message is either a string or an object of the messages keyed by the field name.
+func gitLabErrorMessage(body []byte) string {
+	var resp struct {
+		Message any    `json:"message"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return strings.TrimSpace(string(body))
+	}
+	switch msg := resp.Message.(type) {
+	case string:
+		return msg
+	case nil:
+		return resp.Error
+	default:
+		bs, _ := json.Marshal(msg)
+		return string(bs)
+	}
+}
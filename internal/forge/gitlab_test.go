@@ -0,0 +1,240 @@
+package forge_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/forge"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/require"
+)
+
+type mockGitLabServer struct {
+	t     *testing.T
+	mrs   map[string]map[string]any
+	notes map[string][]string
+}
+
+func (s *mockGitLabServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	require.Equal(s.t, "token", r.Header.Get("PRIVATE-TOKEN"))
+	const prefix = "/api/v4/projects/group%2Fsub%2Fproject"
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == http.MethodGet && path == prefix+"/merge_requests":
+		var ret []map[string]any
+		for _, mr := range s.mrs {
+			if b := r.URL.Query().Get("source_branch"); b != "" && mr["source_branch"] != b {
+				continue
+			}
+			if b := r.URL.Query().Get("target_branch"); b != "" && mr["target_branch"] != b {
+				continue
+			}
+			if iids, ok := r.URL.Query()["iids[]"]; ok &&
+				!slices.Contains(iids, strconv.Itoa(mr["iid"].(int))) {
+				continue
+			}
+			if st := r.URL.Query().Get("state"); st != "all" && mr["state"] != st {
+				continue
+			}
+			ret = append(ret, mr)
+		}
+		_ = json.NewEncoder(w).Encode(ret)
+	case r.Method == http.MethodPost && path == prefix+"/merge_requests":
+		var params map[string]any
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(&params))
+		mr := map[string]any{
+			"iid":           3,
+			"title":         params["title"],
+			"description":   params["description"],
+			"source_branch": params["source_branch"],
+			"target_branch": params["target_branch"],
+			"state":         "opened",
+			"draft":         isDraftTitle(params["title"].(string)),
+			"web_url":       "https://gitlab.invalid/group/sub/project/-/merge_requests/3",
+		}
+		s.mrs["3"] = mr
+		_ = json.NewEncoder(w).Encode(mr)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/notes"):
+		id := strings.TrimSuffix(path[len(prefix+"/merge_requests/"):], "/notes")
+		var ret []map[string]any
+		for i, body := range s.notes[id] {
+			ret = append(ret, map[string]any{"id": i + 1, "body": body, "system": false})
+		}
+		_ = json.NewEncoder(w).Encode(ret)
+	case r.Method == http.MethodPut && strings.Contains(path, "/notes/"):
+		id, noteID, _ := strings.Cut(path[len(prefix+"/merge_requests/"):], "/notes/")
+		i, err := strconv.Atoi(noteID)
+		require.NoError(s.t, err)
+		var params map[string]any
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(&params))
+		s.notes[id][i-1] = params["body"].(string)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": i})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, "/notes"):
+		id := strings.TrimSuffix(path[len(prefix+"/merge_requests/"):], "/notes")
+		var params map[string]any
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(&params))
+		s.notes[id] = append(s.notes[id], params["body"].(string))
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": len(s.notes[id])})
+	case r.Method == http.MethodGet && len(path) > len(prefix+"/merge_requests/"):
+		mr, ok := s.mrs[path[len(prefix+"/merge_requests/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"404 Not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(mr)
+	case r.Method == http.MethodPut && len(path) > len(prefix+"/merge_requests/"):
+		mr := s.mrs[path[len(prefix+"/merge_requests/"):]]
+		var params map[string]any
+		require.NoError(s.t, json.NewDecoder(r.Body).Decode(&params))
+		for k, v := range params {
+			mr[k] = v
+		}
+		if title, ok := params["title"].(string); ok {
+			mr["draft"] = isDraftTitle(title)
+		}
+		if params["state_event"] == "close" {
+			mr["state"] = "closed"
+		}
+		_ = json.NewEncoder(w).Encode(mr)
+	default:
+		s.t.Errorf("unexpected request: %s %s", r.Method, path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func isDraftTitle(title string) bool {
+	return strings.HasPrefix(title, "Draft: ")
+}
+
+func newMockGitLab(t *testing.T) (*mockGitLabServer, forge.Forge) {
+	s := &mockGitLabServer{t: t, notes: map[string][]string{}, mrs: map[string]map[string]any{
+		"1": {
+			"iid":              1,
+			"title":            "Draft: Add feature",
+			"description":      "body",
+			"source_branch":    "feature-1",
+			"target_branch":    "main",
+			"state":            "merged",
+			"draft":            true,
+			"web_url":          "https://gitlab.invalid/group/sub/project/-/merge_requests/1",
+			"sha":              "1111111111111111111111111111111111111111",
+			"merge_commit_sha": "2222222222222222222222222222222222222222",
+		},
+		"2": {
+			"iid":           2,
+			"title":         "Add another feature",
+			"source_branch": "feature-2",
+			"target_branch": "feature-1",
+			"state":         "opened",
+			"web_url":       "https://gitlab.invalid/group/sub/project/-/merge_requests/2",
+			"author":        map[string]any{"username": "alice"},
+		},
+	}}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	f, err := forge.NewGitLab(server.URL+"/", "token", "group/sub/project")
+	require.NoError(t, err)
+	return s, f
+}
+
+func TestGitLabBranchPullRequests(t *testing.T) {
+	_, f := newMockGitLab(t)
+
+	prs, err := f.BranchPullRequests(context.Background(), "feature-1")
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	pr := prs[0]
+	require.Equal(t, "1", pr.ID)
+	require.Equal(t, int64(1), pr.Number)
+	require.Equal(t, "Add feature", pr.Title)
+	require.True(t, pr.IsDraft)
+	require.Equal(t, "main", pr.BaseBranchName())
+	require.Equal(t, githubv4.PullRequestStateMerged, pr.State)
+	require.Equal(t, "2222222222222222222222222222222222222222", pr.GetMergeCommit())
+
+	based, err := f.OpenPullRequestsWithBase(context.Background(), "feature-1")
+	require.NoError(t, err)
+	require.Len(t, based, 1)
+	require.Equal(t, "feature-2", based[0].HeadRefName)
+	require.Equal(t, "alice", based[0].Author.Login)
+}
+
+func TestGitLabPullRequests(t *testing.T) {
+	_, f := newMockGitLab(t)
+
+	prs, err := f.PullRequests(context.Background(), []string{"2", "1", "4"})
+	require.NoError(t, err)
+	require.Len(t, prs, 2)
+	slices.SortFunc(prs, func(a, b gh.PullRequest) int { return int(a.Number - b.Number) })
+	require.Equal(t, "feature-1", prs[0].HeadBranchName())
+	require.Equal(t, githubv4.PullRequestStateMerged, prs[0].State)
+	require.Equal(t, "feature-2", prs[1].HeadBranchName())
+	require.Equal(t, githubv4.PullRequestStateOpen, prs[1].State)
+}
+
+func TestGitLabCreateAndUpdatePullRequest(t *testing.T) {
+	s, f := newMockGitLab(t)
+	ctx := context.Background()
+
+	pr, err := f.CreatePullRequest(ctx, forge.CreatePullRequestInput{
+		BaseRefName: "feature-2",
+		HeadRefName: "feature-3",
+		Title:       "Third feature",
+		Body:        "body",
+		Draft:       true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "3", pr.ID)
+	require.Equal(t, "Draft: Third feature", s.mrs["3"]["title"])
+
+	base := "main"
+	title := "Renamed feature"
+	pr, err = f.UpdatePullRequest(ctx, forge.UpdatePullRequestInput{
+		ID:          pr.ID,
+		BaseRefName: &base,
+		Title:       &title,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "main", pr.BaseRefName)
+	// The draft state is kept.
+	require.Equal(t, "Draft: Renamed feature", s.mrs["3"]["title"])
+	require.Equal(t, "Renamed feature", pr.Title)
+
+	pr, err = f.MarkPullRequestReadyForReview(ctx, pr.ID)
+	require.NoError(t, err)
+	require.False(t, pr.IsDraft)
+	require.Equal(t, "Renamed feature", s.mrs["3"]["title"])
+
+	_, err = f.PullRequest(ctx, "42")
+	require.ErrorContains(t, err, "404 Not found")
+}
+
+func TestGitLabClosePullRequest(t *testing.T) {
+	s, f := newMockGitLab(t)
+
+	pr, err := f.ClosePullRequest(context.Background(), "2", "Folded into !1")
+	require.NoError(t, err)
+	require.Equal(t, githubv4.PullRequestStateClosed, pr.State)
+	require.Equal(t, []string{"Folded into !1"}, s.notes["2"])
+}
+
+func TestGitLabUpsertComment(t *testing.T) {
+	s, f := newMockGitLab(t)
+	ctx := context.Background()
+	s.notes["2"] = []string{"LGTM"}
+
+	require.NoError(t, f.UpsertComment(ctx, "2", "<!-- marker -->", "<!-- marker -->\nv1"))
+	require.Equal(t, []string{"LGTM", "<!-- marker -->\nv1"}, s.notes["2"])
+
+	// The comment with the marker is edited instead of adding another one.
+	require.NoError(t, f.UpsertComment(ctx, "2", "<!-- marker -->", "<!-- marker -->\nv2"))
+	require.Equal(t, []string{"LGTM", "<!-- marker -->\nv2"}, s.notes["2"])
+}
@@ -0,0 +1,130 @@
+package gh
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+// PullRequestActivity is the state of a pull request and when it was created, merged, and
+// closed.
+type PullRequestActivity struct {
+	ID          string
+	Number      int64
+	Title       string
+	Permalink   string
+	HeadRefName string
+	State       githubv4.PullRequestState
+	CreatedAt   githubv4.DateTime
+	MergedAt    *githubv4.DateTime
+	ClosedAt    *githubv4.DateTime
+}
+
+// The maximum number of the node IDs that can be queried at once.
+const maxNodesPerQuery = 100
+
+// PullRequestsActivity returns the activity of the pull requests with the given IDs. The pull
+// requests that are not found (e.g., deleted) are omitted.
+func (c *Client) PullRequestsActivity(
+	ctx context.Context,
+	ids []string,
+) ([]PullRequestActivity, error) {
+	return queryPullRequestNodes(ctx, c, ids, func(pr PullRequestActivity) string { return pr.ID })
+}
+
+// PullRequestReviewActivity is when a pull request was created and first reviewed.
+type PullRequestReviewActivity struct {
+	ID        string
+	Number    int64
+	CreatedAt githubv4.DateTime
+	// The first submitted review (pending reviews are excluded), if any.
+	Reviews struct {
+		Nodes []struct {
+			SubmittedAt *githubv4.DateTime
+		}
+	} `graphql:"reviews(first: 1, states: [APPROVED, CHANGES_REQUESTED, COMMENTED, DISMISSED])"`
+}
+
+// FirstReviewAt returns when the pull request was first reviewed, or nil if it hasn't been.
+func (a PullRequestReviewActivity) FirstReviewAt() *time.Time {
+	if len(a.Reviews.Nodes) == 0 || a.Reviews.Nodes[0].SubmittedAt == nil {
+		return nil
+	}
+	return &a.Reviews.Nodes[0].SubmittedAt.Time
+}
+
+// PullRequestsReviewActivity returns the review activity of the pull requests with the given
+// IDs. The pull requests that are not found (e.g., deleted) are omitted.
+func (c *Client) PullRequestsReviewActivity(
+	ctx context.Context,
+	ids []string,
+) ([]PullRequestReviewActivity, error) {
+	return queryPullRequestNodes(ctx, c, ids, func(pr PullRequestReviewActivity) string { return pr.ID })
+}
+
+// pullRequestLastReview is the most recent review of a pull request.
+type pullRequestLastReview struct {
+	ID      string
+	Reviews struct {
+		Nodes []struct {
+			UpdatedAt githubv4.DateTime
+		}
+	} `graphql:"reviews(last: 1)"`
+}
+
+// PullRequestsLastReviewActivity returns the time of the most recent review on each of the pull
+// requests with the given IDs, keyed by the ID. The pull requests that haven't been reviewed or
+// are not found are omitted.
+func (c *Client) PullRequestsLastReviewActivity(
+	ctx context.Context,
+	ids []string,
+) (map[string]time.Time, error) {
+	prs, err := queryPullRequestNodes(ctx, c, ids, func(pr pullRequestLastReview) string { return pr.ID })
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]time.Time{}
+	for _, pr := range prs {
+		for _, review := range pr.Reviews.Nodes {
+			if review.UpdatedAt.After(ret[pr.ID]) {
+				ret[pr.ID] = review.UpdatedAt.Time
+			}
+		}
+	}
+	return ret, nil
+}
+
+// queryPullRequestNodes queries the pull requests with the given IDs as T in batches.
+func queryPullRequestNodes[T any](
+	ctx context.Context,
+	c *Client,
+	ids []string,
+	id func(T) string,
+) ([]T, error) {
+	var ret []T
+	for start := 0; start < len(ids); start += maxNodesPerQuery {
+		end := min(start+maxNodesPerQuery, len(ids))
+		var query struct {
+			Nodes []struct {
+				PullRequest T `graphql:"... on PullRequest"`
+			} `graphql:"nodes(ids: $ids)"`
+		}
+		var gqlIDs []githubv4.ID
+		for _, id := range ids[start:end] {
+			gqlIDs = append(gqlIDs, githubv4.ID(id))
+		}
+		if err := c.query(ctx, &query, map[string]any{
+			"ids": gqlIDs,
+		}); err != nil {
+			return nil, errors.Wrap(err, "failed to query pull requests")
+		}
+		for _, node := range query.Nodes {
+			if id(node.PullRequest) != "" {
+				ret = append(ret, node.PullRequest)
+			}
+		}
+	}
+	return ret, nil
+}
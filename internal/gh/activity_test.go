@@ -0,0 +1,41 @@
+package gh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestsLastReviewActivity(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"data": {"nodes": [
+			{"id": "PR_1", "reviews": {"nodes": [{"updatedAt": "2024-01-02T03:04:05Z"}]}},
+			{"id": "PR_2", "reviews": {"nodes": []}},
+			null
+		]}}`))
+	}))
+	defer server.Close()
+	orig := config.Av.GitHub
+	t.Cleanup(func() { config.Av.GitHub = orig })
+	config.Av.GitHub.GraphQLURL = server.URL
+
+	client, err := gh.NewClient("token")
+	require.NoError(t, err)
+	reviews, err := client.PullRequestsLastReviewActivity(
+		context.Background(), []string{"PR_1", "PR_2", "PR_3"},
+	)
+	require.NoError(t, err)
+	// All the pull requests are queried at once.
+	require.Equal(t, 1, requests)
+	require.Equal(t, map[string]time.Time{
+		"PR_1": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}, reviews)
+}
@@ -0,0 +1,304 @@
+package gh
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"emperror.dev/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+// PullRequestStatus is the state of a pull request and the CI state of its head commit.
+type PullRequestStatus struct {
+	State   githubv4.PullRequestState
+	IsDraft bool
+	// The combined state of the check runs and the commit statuses of the head commit (e.g.,
+	// SUCCESS, FAILURE, or PENDING). Empty if the head commit has no checks.
+	CheckState githubv4.StatusState
+}
+
+// ChecksSucceeded returns true if all the checks of the head commit succeeded.
+func (s *PullRequestStatus) ChecksSucceeded() bool {
+	return s.CheckState == githubv4.StatusStateSuccess
+}
+
+// ChecksFailed returns true if any check of the head commit failed.
+func (s *PullRequestStatus) ChecksFailed() bool {
+	return s.CheckState == githubv4.StatusStateFailure || s.CheckState == githubv4.StatusStateError
+}
+
+// PullRequestStatus returns the state of the given pull request and the combined state of the
+// checks on its head commit.
+func (c *Client) PullRequestStatus(ctx context.Context, id string) (*PullRequestStatus, error) {
+	var query struct {
+		Node struct {
+			PullRequest struct {
+				ID      string
+				State   githubv4.PullRequestState
+				IsDraft bool
+				Commits struct {
+					Nodes []struct {
+						Commit struct {
+							StatusCheckRollup *struct {
+								State githubv4.StatusState
+							}
+						}
+					}
+				} `graphql:"commits(last: 1)"`
+			} `graphql:"... on PullRequest"`
+		} `graphql:"node(id: $id)"`
+	}
+	if err := c.query(ctx, &query, map[string]interface{}{
+		"id": githubv4.ID(id),
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to query pull request status")
+	}
+	pr := query.Node.PullRequest
+	if pr.ID == "" {
+		return nil, errors.Errorf("pull request %q not found", id)
+	}
+	status := &PullRequestStatus{State: pr.State, IsDraft: pr.IsDraft}
+	for _, commit := range pr.Commits.Nodes {
+		if commit.Commit.StatusCheckRollup != nil {
+			status.CheckState = commit.Commit.StatusCheckRollup.State
+		}
+	}
+	return status, nil
+}
+
+// CheckResult is the simplified result of a check run or a commit status.
+type CheckResult string
+
+const (
+	CheckResultPass    CheckResult = "pass"
+	CheckResultFail    CheckResult = "fail"
+	CheckResultPending CheckResult = "pending"
+)
+
+// Check is a check run or a commit status of the head commit of a pull request.
+type Check struct {
+	Name   string
+	Result CheckResult
+	// True if the branch protection rule of the base branch requires the check.
+	Required bool
+}
+
+// PullRequestReview is the mergeability, the required checks, and the reviews of a pull
+// request.
+type PullRequestReview struct {
+	ID        string
+	Number    int64
+	State     githubv4.PullRequestState
+	IsDraft   bool
+	Mergeable githubv4.MergeableState
+	// Empty if the repository doesn't require reviews.
+	ReviewDecision githubv4.PullRequestReviewDecision
+	// The logins of the users who approved the pull request.
+	Approvers []string
+	// The checks of the head commit. The required checks that have not been reported yet are
+	// included as pending.
+	Checks []Check
+}
+
+// RequiredChecks returns the checks that are required to merge the pull request. If the base
+// branch doesn't require any check, all the checks are returned.
+func (r *PullRequestReview) RequiredChecks() []Check {
+	var ret []Check
+	for _, check := range r.Checks {
+		if check.Required {
+			ret = append(ret, check)
+		}
+	}
+	if len(ret) == 0 {
+		return r.Checks
+	}
+	return ret
+}
+
+// Green returns true if the pull request is merged, or if it's open, mergeable, approved (when
+// reviews are required), and all the required checks passed.
+func (r *PullRequestReview) Green() bool {
+	if r.State == githubv4.PullRequestStateMerged {
+		return true
+	}
+	if r.State != githubv4.PullRequestStateOpen || r.IsDraft ||
+		r.Mergeable != githubv4.MergeableStateMergeable {
+		return false
+	}
+	if r.ReviewDecision != "" && r.ReviewDecision != githubv4.PullRequestReviewDecisionApproved {
+		return false
+	}
+	for _, check := range r.RequiredChecks() {
+		if check.Result != CheckResultPass {
+			return false
+		}
+	}
+	return true
+}
+
+type pullRequestReviewNode struct {
+	ID             string
+	Number         int64
+	State          githubv4.PullRequestState
+	IsDraft        bool
+	Mergeable      githubv4.MergeableState
+	ReviewDecision *githubv4.PullRequestReviewDecision
+	BaseRef        *struct {
+		BranchProtectionRule *struct {
+			RequiredStatusCheckContexts []string
+		}
+	}
+	Reviews struct {
+		Nodes []struct {
+			Author struct {
+				Login string
+			}
+		}
+	} `graphql:"reviews(last: 50, states: APPROVED)"`
+	Commits struct {
+		Nodes []struct {
+			Commit struct {
+				StatusCheckRollup *struct {
+					Contexts struct {
+						Nodes []struct {
+							CheckRun struct {
+								Name       string
+								Status     githubv4.CheckStatusState
+								Conclusion *githubv4.CheckConclusionState
+							} `graphql:"... on CheckRun"`
+							StatusContext struct {
+								Context string
+								State   githubv4.StatusState
+							} `graphql:"... on StatusContext"`
+						}
+					} `graphql:"contexts(first: 100)"`
+				}
+			}
+		}
+	} `graphql:"commits(last: 1)"`
+}
+
+// PullRequestReviews returns the mergeability, the checks, and the reviews of the pull
+// requests with the given IDs in a single query (per 100 IDs). The pull requests that are not
+// found are omitted.
+func (c *Client) PullRequestReviews(
+	ctx context.Context,
+	ids []string,
+) ([]PullRequestReview, error) {
+	var ret []PullRequestReview
+	for start := 0; start < len(ids); start += maxNodesPerQuery {
+		end := min(start+maxNodesPerQuery, len(ids))
+		var query struct {
+			Nodes []struct {
+				PullRequest pullRequestReviewNode `graphql:"... on PullRequest"`
+			} `graphql:"nodes(ids: $ids)"`
+		}
+		var gqlIDs []githubv4.ID
+		for _, id := range ids[start:end] {
+			gqlIDs = append(gqlIDs, githubv4.ID(id))
+		}
+		if err := c.query(ctx, &query, map[string]any{
+			"ids": gqlIDs,
+		}); err != nil {
+			return nil, errors.Wrap(err, "failed to query pull request reviews")
+		}
+		for _, node := range query.Nodes {
+			if node.PullRequest.ID != "" {
+				ret = append(ret, node.PullRequest.toPullRequestReview())
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (n *pullRequestReviewNode) toPullRequestReview() PullRequestReview {
+	ret := PullRequestReview{
+		ID:        n.ID,
+		Number:    n.Number,
+		State:     n.State,
+		IsDraft:   n.IsDraft,
+		Mergeable: n.Mergeable,
+	}
+	if n.ReviewDecision != nil {
+		ret.ReviewDecision = *n.ReviewDecision
+	}
+	for _, review := range n.Reviews.Nodes {
+		if review.Author.Login != "" && !slices.Contains(ret.Approvers, review.Author.Login) {
+			ret.Approvers = append(ret.Approvers, review.Author.Login)
+		}
+	}
+
+	required := map[string]bool{}
+	if n.BaseRef != nil && n.BaseRef.BranchProtectionRule != nil {
+		for _, name := range n.BaseRef.BranchProtectionRule.RequiredStatusCheckContexts {
+			required[name] = false
+		}
+	}
+	for _, commit := range n.Commits.Nodes {
+		if commit.Commit.StatusCheckRollup == nil {
+			continue
+		}
+		for _, context := range commit.Commit.StatusCheckRollup.Contexts.Nodes {
+			var check Check
+			if context.CheckRun.Name != "" {
+				check = Check{
+					Name:   context.CheckRun.Name,
+					Result: checkRunResult(context.CheckRun.Status, context.CheckRun.Conclusion),
+				}
+			} else if context.StatusContext.Context != "" {
+				check = Check{
+					Name:   context.StatusContext.Context,
+					Result: statusContextResult(context.StatusContext.State),
+				}
+			} else {
+				continue
+			}
+			if _, ok := required[check.Name]; ok {
+				check.Required = true
+				required[check.Name] = true
+			}
+			ret.Checks = append(ret.Checks, check)
+		}
+	}
+	// The required checks that have not started yet.
+	var missing []string
+	for name, reported := range required {
+		if !reported {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		ret.Checks = append(ret.Checks, Check{
+			Name: name, Result: CheckResultPending, Required: true,
+		})
+	}
+	return ret
+}
+
+func checkRunResult(
+	status githubv4.CheckStatusState,
+	conclusion *githubv4.CheckConclusionState,
+) CheckResult {
+	if status != githubv4.CheckStatusStateCompleted || conclusion == nil {
+		return CheckResultPending
+	}
+	switch *conclusion {
+	case githubv4.CheckConclusionStateSuccess,
+		githubv4.CheckConclusionStateNeutral,
+		githubv4.CheckConclusionStateSkipped:
+		return CheckResultPass
+	}
+	return CheckResultFail
+}
+
+func statusContextResult(state githubv4.StatusState) CheckResult {
+	switch state {
+	case githubv4.StatusStateSuccess:
+		return CheckResultPass
+	case githubv4.StatusStatePending, githubv4.StatusStateExpected:
+		return CheckResultPending
+	}
+	return CheckResultFail
+}
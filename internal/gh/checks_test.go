@@ -0,0 +1,81 @@
+package gh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/require"
+)
+
+const pullRequestReviewsResponse = `{"data": {"nodes": [{
+	"id": "PR_1",
+	"number": 1,
+	"state": "OPEN",
+	"isDraft": false,
+	"mergeable": "MERGEABLE",
+	"reviewDecision": "APPROVED",
+	"baseRef": {"branchProtectionRule": {"requiredStatusCheckContexts": ["build", "deploy"]}},
+	"reviews": {"nodes": [
+		{"author": {"login": "alice"}},
+		{"author": {"login": "alice"}},
+		{"author": {"login": "bob"}}
+	]},
+	"commits": {"nodes": [{"commit": {"statusCheckRollup": {"contexts": {"nodes": [
+		{"name": "build", "status": "COMPLETED", "conclusion": "SUCCESS"},
+		{"name": "lint", "status": "COMPLETED", "conclusion": "FAILURE"},
+		{"context": "coverage", "state": "PENDING"}
+	]}}}}]}
+}]}}`
+
+func TestPullRequestReviews(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pullRequestReviewsResponse))
+	}))
+	defer server.Close()
+	orig := config.Av.GitHub
+	t.Cleanup(func() { config.Av.GitHub = orig })
+	config.Av.GitHub.GraphQLURL = server.URL
+
+	client, err := gh.NewClient("token")
+	require.NoError(t, err)
+	reviews, err := client.PullRequestReviews(context.Background(), []string{"PR_1"})
+	require.NoError(t, err)
+	require.Len(t, reviews, 1)
+
+	review := reviews[0]
+	require.Equal(t, []string{"alice", "bob"}, review.Approvers)
+	require.Equal(t, githubv4.PullRequestReviewDecisionApproved, review.ReviewDecision)
+	require.Equal(t, []gh.Check{
+		{Name: "build", Result: gh.CheckResultPass, Required: true},
+		{Name: "deploy", Result: gh.CheckResultPending, Required: true},
+	}, review.RequiredChecks())
+	require.False(t, review.Green())
+
+	review.Checks[3].Result = gh.CheckResultPass
+	require.True(t, review.Green())
+}
+
+func TestPullRequestReviewRequiredChecks(t *testing.T) {
+	review := gh.PullRequestReview{
+		State:     githubv4.PullRequestStateOpen,
+		Mergeable: githubv4.MergeableStateMergeable,
+		Checks: []gh.Check{
+			{Name: "build", Result: gh.CheckResultPass},
+			{Name: "lint", Result: gh.CheckResultFail},
+		},
+	}
+	// Without the branch protection, all the checks are required.
+	require.Len(t, review.RequiredChecks(), 2)
+	require.False(t, review.Green())
+
+	review.Checks[1].Result = gh.CheckResultPass
+	require.True(t, review.Green())
+
+	review.ReviewDecision = githubv4.PullRequestReviewDecisionReviewRequired
+	require.False(t, review.Green())
+}
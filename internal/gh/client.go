@@ -27,13 +27,11 @@ func NewClient(token string) (*Client, error) {
 	src := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
-	httpClient := oauth2.NewClient(context.Background(), src)
-	var gh *githubv4.Client
-	if config.Av.GitHub.BaseURL == "" {
-		gh = githubv4.NewClient(httpClient)
-	} else {
-		gh = githubv4.NewEnterpriseClient(config.Av.GitHub.BaseURL+"/api/graphql", httpClient)
-	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport: newRetryTransport(http.DefaultTransport, config.Av.GitHub.MaxRetries),
+	})
+	httpClient := oauth2.NewClient(ctx, src)
+	gh := githubv4.NewEnterpriseClient(config.Av.GitHub.GraphQLEndpoint(), httpClient)
 	return &Client{httpClient, gh}, nil
 }
 
@@ -0,0 +1,100 @@
+package gh
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Credential is a GitHub API token and where it's read from.
+type Credential struct {
+	Token string
+	// The description of the source of the token (e.g., "the gh CLI").
+	Source string
+}
+
+// CredentialProvider reads the GitHub API token from a source.
+type CredentialProvider struct {
+	// The description of the source.
+	Source string
+	// Returns the token for the given GitHub host, or an empty string if the source doesn't
+	// have one.
+	Token func(host string) (string, error)
+}
+
+// CredentialProviders is the chain of the sources of the GitHub API token in the order of
+// precedence.
+var CredentialProviders = []CredentialProvider{
+	envCredentialProvider("AV_GITHUB_TOKEN"),
+	envCredentialProvider("GITHUB_TOKEN"),
+	{
+		Source: "the gitHub.token config",
+		Token: func(string) (string, error) {
+			return config.Av.GitHub.Token, nil
+		},
+	},
+	{
+		Source: "the gh CLI (gh auth token)",
+		Token:  ghCLIToken,
+	},
+	{
+		Source: "the " + keychainName(),
+		Token:  keychainToken,
+	},
+}
+
+func envCredentialProvider(name string) CredentialProvider {
+	return CredentialProvider{
+		Source: "the " + name + " environment variable",
+		Token: func(string) (string, error) {
+			return os.Getenv(name), nil
+		},
+	}
+}
+
+// DiscoverCredential returns the GitHub API token from the first source in
+// CredentialProviders that has one. The token is empty if none of the sources has one.
+func DiscoverCredential() Credential {
+	host := config.Av.GitHub.Host()
+	for _, p := range CredentialProviders {
+		token, err := p.Token(host)
+		if err != nil {
+			logrus.WithError(err).WithField("source", p.Source).
+				Debug("failed to read the GitHub token")
+			continue
+		}
+		if token = strings.TrimSpace(token); token != "" {
+			return Credential{Token: token, Source: p.Source}
+		}
+	}
+	return Credential{}
+}
+
+func ghCLIToken(host string) (string, error) {
+	ghCli, err := exec.LookPath("gh")
+	if err != nil {
+		return "", nil
+	}
+	return runCredentialHelper(ghCli, "auth", "token", "--hostname", host)
+}
+
+// runCredentialHelper runs the command and returns its output. A command that exits with a
+// non-zero status is treated as not having the token.
+func runCredentialHelper(name string, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", nil
+		}
+		return "", errors.WrapIff(err, "failed to run %s", name)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,54 @@
+package gh_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverCredential(t *testing.T) {
+	// Only the fake gh CLI is found in PATH.
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(bin, "gh"),
+		[]byte("#!/bin/sh\n[ \"$*\" = \"auth token --hostname github.com\" ] && echo gh-token\n"),
+		0o755,
+	))
+	t.Setenv("PATH", bin)
+	t.Setenv("AV_GITHUB_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	config.Av.GitHub.Token = ""
+
+	assert.Equal(
+		t,
+		gh.Credential{Token: "gh-token", Source: "the gh CLI (gh auth token)"},
+		gh.DiscoverCredential(),
+	)
+
+	config.Av.GitHub.Token = "config-token"
+	defer func() { config.Av.GitHub.Token = "" }()
+	assert.Equal(
+		t,
+		gh.Credential{Token: "config-token", Source: "the gitHub.token config"},
+		gh.DiscoverCredential(),
+	)
+
+	t.Setenv("GITHUB_TOKEN", "env-token")
+	assert.Equal(
+		t,
+		gh.Credential{Token: "env-token", Source: "the GITHUB_TOKEN environment variable"},
+		gh.DiscoverCredential(),
+	)
+
+	// The gh CLI is not logged in to the GitHub Enterprise Server instance.
+	t.Setenv("GITHUB_TOKEN", "")
+	config.Av.GitHub.Token = ""
+	config.Av.GitHub.BaseURL = "https://github.mycompany.com"
+	defer func() { config.Av.GitHub.BaseURL = "" }()
+	assert.Equal(t, gh.Credential{}, gh.DiscoverCredential())
+}
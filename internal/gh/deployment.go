@@ -0,0 +1,105 @@
+package gh
+
+import (
+	"context"
+	"sort"
+
+	"emperror.dev/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+type Deployment struct {
+	Environment string
+	// The state of the latest status of the deployment (e.g., SUCCESS, FAILURE, or PENDING).
+	// Empty if the deployment has no status yet.
+	State          githubv4.DeploymentStatusState
+	EnvironmentURL string
+}
+
+// Succeeded returns true if the deployment succeeded.
+func (d Deployment) Succeeded() bool {
+	return d.State == githubv4.DeploymentStatusStateSuccess
+}
+
+// Failed returns true if the deployment failed.
+func (d Deployment) Failed() bool {
+	return d.State == githubv4.DeploymentStatusStateFailure ||
+		d.State == githubv4.DeploymentStatusStateError
+}
+
+// pullRequestDeployments is the deployments of the head commit of a pull request.
+type pullRequestDeployments struct {
+	ID      string
+	Commits struct {
+		Nodes []struct {
+			Commit struct {
+				Deployments struct {
+					Nodes []struct {
+						Environment  string
+						LatestStatus *struct {
+							State          githubv4.DeploymentStatusState
+							EnvironmentURL string `graphql:"environmentUrl"`
+						}
+					}
+				} `graphql:"deployments(last: 50)"`
+			}
+		}
+	} `graphql:"commits(last: 1)"`
+}
+
+// latest returns the latest deployment of each environment, sorted by the environment name.
+func (pr *pullRequestDeployments) latest() []Deployment {
+	// Deployments are returned in the creation order, so the later ones override the earlier
+	// ones of the same environment.
+	latest := map[string]Deployment{}
+	for _, commit := range pr.Commits.Nodes {
+		for _, d := range commit.Commit.Deployments.Nodes {
+			deployment := Deployment{Environment: d.Environment}
+			if d.LatestStatus != nil {
+				deployment.State = d.LatestStatus.State
+				deployment.EnvironmentURL = d.LatestStatus.EnvironmentURL
+			}
+			latest[d.Environment] = deployment
+		}
+	}
+	var ret []Deployment
+	for _, deployment := range latest {
+		ret = append(ret, deployment)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Environment < ret[j].Environment })
+	return ret
+}
+
+// PullRequestDeployments returns the latest deployment of each environment for the head
+// commit of the given pull request, sorted by the environment name.
+func (c *Client) PullRequestDeployments(ctx context.Context, id string) ([]Deployment, error) {
+	var query struct {
+		Node struct {
+			PullRequest pullRequestDeployments `graphql:"... on PullRequest"`
+		} `graphql:"node(id: $id)"`
+	}
+	if err := c.query(ctx, &query, map[string]interface{}{
+		"id": githubv4.ID(id),
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to query pull request deployments")
+	}
+	return query.Node.PullRequest.latest(), nil
+}
+
+// PullRequestsDeployments returns the latest deployments (as PullRequestDeployments) of the
+// pull requests with the given IDs in a single query (per 100 IDs), keyed by the ID. The pull
+// requests that are not found are omitted.
+func (c *Client) PullRequestsDeployments(
+	ctx context.Context,
+	ids []string,
+) (map[string][]Deployment, error) {
+	prs, err := queryPullRequestNodes(ctx, c, ids, func(pr pullRequestDeployments) string { return pr.ID })
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string][]Deployment{}
+	for _, pr := range prs {
+		ret[pr.ID] = pr.latest()
+	}
+	return ret, nil
+}
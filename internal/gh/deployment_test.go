@@ -0,0 +1,52 @@
+package gh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestsDeployments(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"data": {"nodes": [
+			{"id": "PR_1", "commits": {"nodes": [{"commit": {"deployments": {"nodes": [
+				{"environment": "staging", "latestStatus": {"state": "FAILURE", "environmentUrl": ""}},
+				{"environment": "preview", "latestStatus": null},
+				{"environment": "staging", "latestStatus": {"state": "SUCCESS", "environmentUrl": "https://staging.example.com"}}
+			]}}}]}},
+			{"id": "PR_2", "commits": {"nodes": [{"commit": {"deployments": {"nodes": []}}}]}}
+		]}}`))
+	}))
+	defer server.Close()
+	orig := config.Av.GitHub
+	t.Cleanup(func() { config.Av.GitHub = orig })
+	config.Av.GitHub.GraphQLURL = server.URL
+
+	client, err := gh.NewClient("token")
+	require.NoError(t, err)
+	deployments, err := client.PullRequestsDeployments(
+		context.Background(), []string{"PR_1", "PR_2"},
+	)
+	require.NoError(t, err)
+	// All the pull requests are queried at once.
+	require.Equal(t, 1, requests)
+	require.Equal(t, map[string][]gh.Deployment{
+		"PR_1": {
+			{Environment: "preview"},
+			{
+				Environment:    "staging",
+				State:          githubv4.DeploymentStatusStateSuccess,
+				EnvironmentURL: "https://staging.example.com",
+			},
+		},
+		"PR_2": nil,
+	}, deployments)
+}
@@ -1,6 +1,12 @@
 package gh
 
-import "strings"
+import (
+	"context"
+	"net"
+	"strings"
+
+	"emperror.dev/errors"
+)
 
 // IsHTTPUnauthorized returns true if the given error is an HTTP 401 Unauthorized error.
 func IsHTTPUnauthorized(err error) bool {
@@ -9,3 +15,21 @@ func IsHTTPUnauthorized(err error) bool {
 	// the string.
 	return strings.Contains(err.Error(), "status code: 401")
 }
+
+// IsRateLimited returns true if the given error is a GitHub API rate limit error.
+func IsRateLimited(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "rate limit") || strings.Contains(msg, "status code: 429")
+}
+
+// IsNetworkError returns true if the given error is a failure to reach the server (e.g., no
+// network connection or a timeout) rather than an error response from it.
+func IsNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	// The GraphQL package doesn't always wrap the errors of the HTTP client.
+	msg := err.Error()
+	return strings.Contains(msg, "dial tcp") || strings.Contains(msg, "no such host")
+}
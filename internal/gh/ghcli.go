@@ -0,0 +1,144 @@
+package gh
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/shurcooL/githubv4"
+)
+
+// NewGhCLIClient creates a new GitHub client that sends the API requests through the gh CLI
+// (gh api) instead of using a GitHub API token, so that the authentication of gh (e.g., the
+// SSO mandated by the organization) applies to av too.
+func NewGhCLIClient() (*Client, error) {
+	ghCli, err := exec.LookPath("gh")
+	if err != nil {
+		return nil, errors.Errorf(
+			"the gh CLI is not found in PATH (it's required by the gitHub.transport config %q)",
+			config.GitHubTransportGhCLI,
+		)
+	}
+	httpClient := &http.Client{Transport: newRetryTransport(&ghCLITransport{
+		ghCli: ghCli,
+		host:  config.Av.GitHub.Host(),
+	}, config.Av.GitHub.MaxRetries)}
+	gh := githubv4.NewEnterpriseClient(config.Av.GitHub.GraphQLEndpoint(), httpClient)
+	return &Client{httpClient, gh}, nil
+}
+
+// ghCLITransport is an http.RoundTripper that runs gh api for each request.
+type ghCLITransport struct {
+	ghCli string
+	host  string
+}
+
+// The headers that gh sets by itself.
+var ghCLIOmittedHeaders = map[string]bool{
+	"Authorization":  true,
+	"Content-Length": true,
+	"User-Agent":     true,
+}
+
+func (t *ghCLITransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	args := []string{
+		"api", ghCLIEndpoint(req),
+		"--hostname", t.host,
+		"--method", req.Method,
+		"--include",
+	}
+	for name, values := range req.Header {
+		if ghCLIOmittedHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+			continue
+		}
+		for _, v := range values {
+			args = append(args, "--header", name+": "+v)
+		}
+	}
+	if req.Body != nil {
+		defer req.Body.Close()
+		args = append(args, "--input", "-")
+	}
+	cmd := exec.CommandContext(req.Context(), t.ghCli, args...)
+	if req.Body != nil {
+		cmd.Stdin = req.Body
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// gh exits with a non-zero status for the HTTP error responses too, but it still prints the
+	// response, which needs to be returned as is.
+	runErr := cmd.Run()
+	if stdout.Len() == 0 {
+		if runErr == nil {
+			runErr = errors.New("no output")
+		}
+		return nil, errors.WrapIff(runErr, "gh api failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return parseGhCLIResponse(&stdout, req)
+}
+
+// ghCLIEndpoint returns the endpoint argument of gh api for the request, which is relative to
+// the API base URL (e.g., "graphql" for https://api.github.com/graphql or
+// https://github.mycompany.com/api/graphql).
+func ghCLIEndpoint(req *http.Request) string {
+	endpoint := strings.TrimPrefix(req.URL.Path, "/")
+	for _, prefix := range []string{"api/v3/", "api/"} {
+		if strings.HasPrefix(endpoint, prefix) {
+			endpoint = strings.TrimPrefix(endpoint, prefix)
+			break
+		}
+	}
+	if req.URL.RawQuery != "" {
+		endpoint += "?" + req.URL.RawQuery
+	}
+	return endpoint
+}
+
+// parseGhCLIResponse parses the output of gh api --include: the status line and the headers
+// followed by the (already decoded) body.
+func parseGhCLIResponse(out io.Reader, req *http.Request) (*http.Response, error) {
+	r := textproto.NewReader(bufio.NewReader(out))
+	statusLine, err := r.ReadLine()
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to read the gh api response")
+	}
+	proto, status, ok := strings.Cut(statusLine, " ")
+	if !ok || len(status) < 3 {
+		return nil, errors.Errorf("unexpected gh api response status line %q", statusLine)
+	}
+	code, err := strconv.Atoi(status[:3])
+	if err != nil {
+		return nil, errors.Errorf("unexpected gh api response status line %q", statusLine)
+	}
+	header, err := r.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.WrapIf(err, "failed to read the gh api response headers")
+	}
+	body, err := io.ReadAll(r.R)
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to read the gh api response body")
+	}
+	// gh decodes the body, so the encoding headers no longer apply.
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	header.Del("Transfer-Encoding")
+	resp := &http.Response{
+		Status:        status,
+		StatusCode:    code,
+		Proto:         proto,
+		Header:        http.Header(header),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	resp.ProtoMajor, resp.ProtoMinor, _ = http.ParseHTTPVersion(proto)
+	return resp, nil
+}
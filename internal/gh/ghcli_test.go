@@ -0,0 +1,68 @@
+package gh_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/gh"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhCLIClient(t *testing.T) {
+	// The fake gh CLI records its arguments and input, and prints a response like
+	// gh api --include.
+	bin := t.TempDir()
+	record := filepath.Join(t.TempDir(), "record")
+	require.NoError(t, os.WriteFile(
+		filepath.Join(bin, "gh"),
+		[]byte(`#!/bin/sh
+echo "$*" > `+record+`
+cat >> `+record+`
+printf 'HTTP/2.0 200 OK\r\nContent-Type: application/json\r\nContent-Encoding: gzip\r\nContent-Length: 3\r\n\r\n'
+printf '{"data":{"viewer":{"name":"Octo Cat","login":"octocat"}}}'
+`),
+		0o755,
+	))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	config.Av.GitHub.BaseURL = "https://github.example.com"
+	t.Cleanup(func() { config.Av.GitHub.BaseURL = "" })
+
+	client, err := gh.NewGhCLIClient()
+	require.NoError(t, err)
+	viewer, err := client.Viewer(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, &gh.Viewer{Name: "Octo Cat", Login: "octocat"}, viewer)
+
+	recorded, err := os.ReadFile(record)
+	require.NoError(t, err)
+	args, input, _ := strings.Cut(string(recorded), "\n")
+	require.True(t, strings.HasPrefix(
+		args, "api graphql --hostname github.example.com --method POST --include",
+	), args)
+	require.True(t, strings.HasSuffix(args, "--input -"), args)
+	require.Contains(t, input, "viewer{name,login}")
+}
+
+func TestGhCLIClientHTTPError(t *testing.T) {
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(bin, "gh"),
+		[]byte(`#!/bin/sh
+cat > /dev/null
+printf 'HTTP/2.0 401 Unauthorized\nContent-Type: application/json\n\n{"message":"Bad credentials"}'
+exit 1
+`),
+		0o755,
+	))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	client, err := gh.NewGhCLIClient()
+	require.NoError(t, err)
+	_, err = client.Viewer(context.Background())
+	require.Error(t, err)
+	require.True(t, gh.IsHTTPUnauthorized(err), err.Error())
+}
@@ -6,9 +6,10 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
-	"github.com/aviator-co/av/internal/gh"
+	"github.com/aviator-co/av/internal/forge"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/notify"
 	"github.com/aviator-co/av/internal/utils/colors"
 	"github.com/aviator-co/av/internal/utils/stackutils"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -16,12 +17,13 @@ import (
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/sirupsen/logrus"
 )
 
 func NewGitHubFetchModel(
 	repo *git.Repo,
 	db meta.DB,
-	client *gh.Client,
+	client forge.Forge,
 	currentBranch plumbing.ReferenceName,
 	targetBranches []plumbing.ReferenceName,
 ) *GitHubFetchModel {
@@ -42,6 +44,8 @@ func NewGitHubFetchModel(
 
 type GitHubFetchProgress struct {
 	gitFetchIsDone               bool
+	forcePushedBranches          []forcePushedBranch
+	followedBranchUpdates        []followedBranchUpdate
 	apiFetchIsDone               bool
 	checkCommitHistoryIsDone     bool
 	mergeCommitPropagationIsDone bool
@@ -52,7 +56,7 @@ type GitHubFetchDone struct{}
 type GitHubFetchModel struct {
 	repo           *git.Repo
 	db             meta.DB
-	client         *gh.Client
+	client         forge.Forge
 	currentBranch  plumbing.ReferenceName
 	targetBranches []plumbing.ReferenceName
 	spinner        spinner.Model
@@ -61,6 +65,33 @@ type GitHubFetchModel struct {
 	runningGitHubAPIBranch      int
 	runningCheckCommitHistory   bool
 	runningPropagateMergeCommit bool
+
+	forcePushedBranches   []forcePushedBranch
+	followedBranchUpdates []followedBranchUpdate
+	// The followed branches whose pull requests were merged or closed. Their children are moved
+	// onto the trunk.
+	endedFollowedBranches []meta.Branch
+}
+
+// forcePushedBranch is a branch whose remote branch was rewritten since the last fetch (e.g.,
+// force-pushed by a teammate or from another clone).
+type forcePushedBranch struct {
+	branch    string
+	oldCommit string
+	newCommit string
+	// True if the local branch was updated to the remote branch. False if the local branch has
+	// changes of its own, and it has to be reconciled manually.
+	updated bool
+}
+
+// followedBranchUpdate is a followed branch (see meta.Branch.Followed) that is updated to its
+// remote branch.
+type followedBranchUpdate struct {
+	branch    string
+	oldCommit string
+	newCommit string
+	// True if the remote branch was rewritten (e.g., the bot rebased the branch).
+	forcePushed bool
 }
 
 func (vm *GitHubFetchModel) Init() tea.Cmd {
@@ -71,6 +102,8 @@ func (vm *GitHubFetchModel) Update(msg tea.Msg) (*GitHubFetchModel, tea.Cmd) {
 	switch msg := msg.(type) {
 	case *GitHubFetchProgress:
 		if msg.gitFetchIsDone {
+			vm.forcePushedBranches = msg.forcePushedBranches
+			vm.followedBranchUpdates = msg.followedBranchUpdates
 			vm.runningGitFetch = false
 			vm.runningGitHubAPIBranch = 0
 			return vm, vm.runGitHubAPIFetch
@@ -90,6 +123,7 @@ func (vm *GitHubFetchModel) Update(msg tea.Msg) (*GitHubFetchModel, tea.Cmd) {
 		}
 		if msg.mergeCommitPropagationIsDone {
 			vm.runningPropagateMergeCommit = false
+			vm.endedFollowedBranches = vm.findEndedFollowedBranches()
 			return vm, func() tea.Msg { return &GitHubFetchDone{} }
 		}
 	case spinner.TickMsg:
@@ -107,7 +141,7 @@ func (vm *GitHubFetchModel) View() string {
 		sb.WriteString(colors.ProgressStyle.Render(vm.spinner.View() + "Running git fetch..."))
 		showTree = true
 	} else if vm.runningGitHubAPIBranch >= 0 && vm.runningGitHubAPIBranch < len(vm.targetBranches) {
-		sb.WriteString(colors.ProgressStyle.Render(vm.spinner.View() + "Querying GitHub API for " + vm.targetBranches[vm.runningGitHubAPIBranch].Short() + "..."))
+		sb.WriteString(colors.ProgressStyle.Render(vm.spinner.View() + "Querying " + vm.client.Name() + " API for " + vm.targetBranches[vm.runningGitHubAPIBranch].Short() + "..."))
 		showTree = true
 	} else if vm.runningCheckCommitHistory {
 		sb.WriteString(colors.ProgressStyle.Render(vm.spinner.View() + "Checking commit history for merge commits..."))
@@ -117,6 +151,18 @@ func (vm *GitHubFetchModel) View() string {
 		showTree = true
 	} else {
 		sb.WriteString(colors.SuccessStyle.Render("✓ GitHub fetch is done"))
+		for _, fp := range vm.forcePushedBranches {
+			sb.WriteString("\n")
+			sb.WriteString(viewForcePushedBranch(fp))
+		}
+		for _, update := range vm.followedBranchUpdates {
+			sb.WriteString("\n")
+			sb.WriteString(viewFollowedBranchUpdate(update))
+		}
+		for _, br := range vm.endedFollowedBranches {
+			sb.WriteString("\n")
+			sb.WriteString(vm.viewEndedFollowedBranch(br))
+		}
 	}
 
 	if showTree {
@@ -173,11 +219,240 @@ func (vm *GitHubFetchModel) View() string {
 }
 
 func (vm *GitHubFetchModel) runGitFetch() tea.Msg {
+	before := vm.remoteTrackingCommits()
 	remote := vm.repo.GetRemoteName()
 	if _, err := vm.repo.Git("fetch", remote); err != nil {
-		return errors.Errorf("failed to fetch from %s: %v", remote, err)
+		return errors.WrapIff(err, "failed to fetch from %s", remote)
+	}
+	// In the fork-based workflow, the remote tracking branches of the fork are used to
+	// determine the branches to push. The branches can be pushed to different remotes with
+	// branch.<name>.pushRemote.
+	fetched := map[string]bool{remote: true}
+	for _, br := range vm.targetBranches {
+		pushRemote := vm.repo.GetBranchPushRemoteName(br.Short())
+		if fetched[pushRemote] {
+			continue
+		}
+		fetched[pushRemote] = true
+		if _, err := vm.repo.Git("fetch", pushRemote); err != nil {
+			return errors.WrapIff(err, "failed to fetch from %s", pushRemote)
+		}
+	}
+	if err := vm.repo.FetchBranchNotes(); err != nil {
+		logrus.WithError(err).Warning("failed to fetch the branch notes")
+	}
+	forcePushed, err := vm.updateForcePushedBranches(before)
+	if err != nil {
+		return err
+	}
+	followedUpdates, err := vm.updateFollowedBranches()
+	if err != nil {
+		return err
+	}
+	return &GitHubFetchProgress{
+		gitFetchIsDone:        true,
+		forcePushedBranches:   forcePushed,
+		followedBranchUpdates: followedUpdates,
 	}
-	return &GitHubFetchProgress{gitFetchIsDone: true}
+}
+
+// remoteTrackingCommits returns the commits of the remote tracking branches of the target
+// branches.
+func (vm *GitHubFetchModel) remoteTrackingCommits() map[string]string {
+	ret := map[string]string{}
+	for _, br := range vm.targetBranches {
+		rtb := vm.repo.PushRemoteBranchRef(br.Short())
+		if oid, err := vm.repo.RevParse(&git.RevParse{Rev: rtb}); err == nil {
+			ret[br.Short()] = oid
+		}
+	}
+	return ret
+}
+
+// updateForcePushedBranches finds the branches whose remote branches were rewritten by the
+// fetch (a non-fast-forward update from the commits before the fetch), and updates the local
+// branches that don't have changes of their own to the remote branches. The children keep the
+// old commit as their parent head, so the restack rebases only their own commits onto the new
+// history instead of duplicating the commits of the old history.
+func (vm *GitHubFetchModel) updateForcePushedBranches(
+	before map[string]string,
+) ([]forcePushedBranch, error) {
+	after := vm.remoteTrackingCommits()
+	var ret []forcePushedBranch
+	for _, br := range vm.targetBranches {
+		name := br.Short()
+		oldCommit, newCommit := before[name], after[name]
+		if oldCommit == "" || newCommit == "" || oldCommit == newCommit {
+			continue
+		}
+		if avbr, _ := vm.db.ReadTx().Branch(name); avbr.IsFollowed() {
+			// Updated by updateFollowedBranches.
+			continue
+		}
+		if ok, err := vm.repo.IsAncestor(oldCommit, newCommit); err != nil || ok {
+			// A fast-forward update (or the old commit is gone).
+			continue
+		}
+		fp := forcePushedBranch{branch: name, oldCommit: oldCommit, newCommit: newCommit}
+		local, err := vm.repo.RevParse(&git.RevParse{Rev: br.String()})
+		if err != nil {
+			return nil, err
+		}
+		if local == oldCommit {
+			updated, err := vm.resetBranch(br, newCommit)
+			if err != nil {
+				return nil, err
+			}
+			fp.updated = updated
+		}
+		if fp.updated {
+			// The remote history is adopted, so it's no longer a rewrite by someone else.
+			if err := vm.repo.BranchSetConfig(name, "av-pushed-commit", newCommit); err != nil {
+				return nil, err
+			}
+		}
+		logrus.WithFields(logrus.Fields{
+			"branch":  name,
+			"old":     oldCommit,
+			"new":     newCommit,
+			"updated": fp.updated,
+		}).Debug("remote branch was force-pushed")
+		ret = append(ret, fp)
+	}
+	return ret, nil
+}
+
+// updateFollowedBranches updates the followed branches (see meta.Branch.Followed) to their remote
+// branches, whether they were fast-forwarded or rewritten. Like updateForcePushedBranches, the
+// children keep the old commit as their parent head, so only their own commits are rebased onto
+// the new commits of the followed branch.
+func (vm *GitHubFetchModel) updateFollowedBranches() ([]followedBranchUpdate, error) {
+	var ret []followedBranchUpdate
+	for _, br := range vm.targetBranches {
+		avbr, _ := vm.db.ReadTx().Branch(br.Short())
+		if !avbr.IsFollowed() || avbr.MergeCommit != "" {
+			continue
+		}
+		rtb := vm.repo.PushRemoteBranchRef(br.Short())
+		newCommit, err := vm.repo.RevParse(&git.RevParse{Rev: rtb})
+		if err != nil {
+			// The remote branch is deleted (e.g., the pull request is merged or closed).
+			continue
+		}
+		oldCommit, err := vm.repo.RevParse(&git.RevParse{Rev: br.String()})
+		if err != nil {
+			return nil, err
+		}
+		if oldCommit == newCommit {
+			continue
+		}
+		updated, err := vm.resetBranch(br, newCommit)
+		if err != nil {
+			return nil, err
+		}
+		if !updated {
+			logrus.WithField("branch", br.Short()).
+				Warn("the followed branch is checked out in another worktree; not updating it")
+			continue
+		}
+		forcePushed, err := vm.repo.IsAncestor(oldCommit, newCommit)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, followedBranchUpdate{
+			branch:      br.Short(),
+			oldCommit:   oldCommit,
+			newCommit:   newCommit,
+			forcePushed: !forcePushed,
+		})
+	}
+	return ret, nil
+}
+
+func viewFollowedBranchUpdate(update followedBranchUpdate) string {
+	msg := "✓ Followed branch " + update.branch + " is updated to the remote branch (" +
+		update.oldCommit[:7] + " -> " + update.newCommit[:7] + ")"
+	if update.forcePushed {
+		msg += ", which was force-pushed"
+	}
+	return colors.SuccessStyle.Render(msg + ".")
+}
+
+// findEndedFollowedBranches returns the followed branches whose pull requests are merged or
+// closed and that still have children.
+func (vm *GitHubFetchModel) findEndedFollowedBranches() []meta.Branch {
+	tx := vm.db.ReadTx()
+	var ret []meta.Branch
+	for _, br := range vm.targetBranches {
+		avbr, _ := tx.Branch(br.Short())
+		if !avbr.IsFollowed() || (avbr.MergeCommit == "" && !avbr.IsClosedFollowed()) {
+			continue
+		}
+		if len(meta.Children(tx, avbr.Name)) == 0 {
+			continue
+		}
+		ret = append(ret, avbr)
+	}
+	return ret
+}
+
+func (vm *GitHubFetchModel) viewEndedFollowedBranch(br meta.Branch) string {
+	state := "merged"
+	if br.MergeCommit == "" {
+		state = "closed without merging"
+	}
+	trunk, _ := meta.Trunk(vm.db.ReadTx(), br.Name)
+	var children []string
+	for _, child := range meta.Children(vm.db.ReadTx(), br.Name) {
+		children = append(children, child.Name)
+	}
+	return colors.Warning(
+		"! The pull request of followed branch "+br.Name+" was "+state+".",
+	) + colors.Faint(
+		"\n  Its children ("+strings.Join(children, ", ")+") are moved onto "+trunk+".",
+	)
+}
+
+// resetBranch moves the branch to the commit. The branches checked out in other worktrees are
+// not moved, and false is returned for them.
+func (vm *GitHubFetchModel) resetBranch(br plumbing.ReferenceName, commit string) (bool, error) {
+	if br == vm.currentBranch {
+		// Keep the uncommitted changes (git reset fails if they conflict).
+		if _, err := vm.repo.Git("reset", "--keep", commit); err != nil {
+			return false, errors.Errorf("failed to reset %s to %s: %v", br.Short(), commit, err)
+		}
+		return true, nil
+	}
+	if err := vm.repo.CheckNotInOtherWorktree(br.Short()); err != nil {
+		return false, nil
+	}
+	if err := vm.repo.UpdateRef(&git.UpdateRef{Ref: br.String(), New: commit}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func viewForcePushedBranch(fp forcePushedBranch) string {
+	sb := strings.Builder{}
+	sb.WriteString(colors.Warning(
+		"! " + fp.branch + " was force-pushed on the remote (" +
+			fp.oldCommit[:7] + " -> " + fp.newCommit[:7] + ").",
+	))
+	if fp.updated {
+		sb.WriteString(colors.Faint(
+			"\n  The local branch is updated to the remote branch, and its children are" +
+				"\n  rebased onto the new history.",
+		))
+	} else {
+		sb.WriteString(colors.Faint(
+			"\n  The local branch has changes of its own, so it's not pushed. To keep them on top" +
+				"\n  of the new history, run:",
+		))
+		sb.WriteString("\n    " + colors.CliCmd(
+			"git rebase --onto "+fp.newCommit[:7]+" "+fp.oldCommit[:7]+" "+fp.branch,
+		))
+	}
+	return sb.String()
 }
 
 func (vm *GitHubFetchModel) runGitHubAPIFetch() tea.Msg {
@@ -198,9 +473,27 @@ func (vm *GitHubFetchModel) runGitHubAPIFetch() tea.Msg {
 	if err := tx.Commit(); err != nil {
 		return errors.Errorf("failed to commit: %v", err)
 	}
+	if avbr, _ = tx.Branch(br.Short()); avbr.MergeCommit != "" {
+		vm.notifyMerged(br.Short())
+	}
 	return &GitHubFetchProgress{apiFetchIsDone: true}
 }
 
+// notifyMerged sends a merge notification for the newly merged branch. A failure to notify
+// shouldn't fail the fetch, so errors are only logged.
+func (vm *GitHubFetchModel) notifyMerged(branch string) {
+	if !notify.Enabled() {
+		return
+	}
+	event, err := notify.NewStackEvent(vm.db.ReadTx(), notify.EventMerge, branch)
+	if err == nil {
+		err = notify.Send(context.Background(), event)
+	}
+	if err != nil {
+		logrus.WithError(err).Warning("failed to send the merge notification")
+	}
+}
+
 func (vm *GitHubFetchModel) updateMergeCommitsFromCommitMessage() tea.Msg {
 	trunkRefs := map[plumbing.ReferenceName]bool{}
 	for _, br := range vm.targetBranches {
@@ -3,11 +3,14 @@ package ghui
 import (
 	"context"
 	"fmt"
+	"io"
+	"slices"
 	"strings"
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/actions"
 	avconfig "github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/forge"
 	"github.com/aviator-co/av/internal/gh"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
@@ -24,7 +27,7 @@ import (
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -35,16 +38,28 @@ const (
 	reasonNotPushedToRemote = "No remote branch yet."
 	reasonPRIsMerged        = "PR is already merged."
 	reasonPRIsClosed        = "PR is closed."
+	reasonPRIsQueued        = "PR is in the merge queue."
+	reasonFollowed          = "Followed branch is owned by someone else."
+	reasonNoSubmit          = "Branch is excluded from submit."
+	reasonRemoteRewritten   = "Remote branch was force-pushed by someone else."
 	reasonParentNotPushed   = "Parent branch is not pushed to remote."
 	reasonNoPR              = "Some branches in a stack do not have a PR."
+	reasonNotRestacked      = "Not rebased by this sync."
 )
 
 type pushCandidate struct {
-	branch       plumbing.ReferenceName
+	branch plumbing.ReferenceName
+	// The remote that the branch is pushed to (see git.Repo.GetBranchPushRemoteName).
+	remote       string
 	remoteCommit *object.Commit
+	// The commit that the remote branch is expected to be at (see actions.PushLease).
+	leaseCommit  string
 	localCommit  *object.Commit
 	remotePRMeta actions.PRMetadata
 	localPRMeta  actions.PRMetadata
+	// The work based on this branch that the push rewrites the history of (e.g., the open pull
+	// requests of other people's branches).
+	rewriteImpact actions.HistoryRewriteImpact
 }
 
 type noPushBranch struct {
@@ -52,11 +67,13 @@ type noPushBranch struct {
 	reason string
 }
 
+// NewGitHubPushModel creates a model that pushes the target branches. The push policy is one of
+// the config.Push* values (see config.ParsePushPolicy).
 func NewGitHubPushModel(
 	repo *git.Repo,
 	db meta.DB,
-	client *gh.Client,
-	pushFlag string,
+	client forge.Forge,
+	pushPolicy string,
 	targetBranches []plumbing.ReferenceName,
 ) *GitHubPushModel {
 	var makeDraftBeforePush bool
@@ -70,11 +87,11 @@ func NewGitHubPushModel(
 		db:                  db,
 		client:              client,
 		makeDraftBeforePush: makeDraftBeforePush,
-		pushFlag:            pushFlag,
+		pushPolicy:          pushPolicy,
 		targetBranches:      targetBranches,
 		spinner:             spinner.New(spinner.WithSpinner(spinner.Dot)),
 		help:                help.New(),
-		chooseNoPush:        pushFlag == "no",
+		chooseNoPush:        pushPolicy == avconfig.PushNever,
 		pullRequestsCache:   map[string]*gh.PullRequest{},
 	}
 }
@@ -89,12 +106,15 @@ type GitHubPushDone struct{}
 type GitHubPushModel struct {
 	repo                *git.Repo
 	db                  meta.DB
-	client              *gh.Client
+	client              forge.Forge
 	makeDraftBeforePush bool
-	pushFlag            string
+	pushPolicy          string
 	targetBranches      []plumbing.ReferenceName
-	spinner             spinner.Model
-	help                help.Model
+	// The branches rebased by the sync. Only these are pushed with the only-if-restacked
+	// policy.
+	restackedBranches map[string]bool
+	spinner           spinner.Model
+	help              help.Model
 
 	chooseNoPush   bool
 	pushCandidates []pushCandidate
@@ -109,6 +129,15 @@ type GitHubPushModel struct {
 	done                  bool
 }
 
+// SetRestackedBranches sets the branches that were rebased by the sync for the
+// only-if-restacked push policy.
+func (vm *GitHubPushModel) SetRestackedBranches(branches []string) {
+	vm.restackedBranches = map[string]bool{}
+	for _, name := range branches {
+		vm.restackedBranches[name] = true
+	}
+}
+
 func (vm *GitHubPushModel) Init() tea.Cmd {
 	vm.calculatingCandidates = true
 	return tea.Batch(vm.spinner.Tick, vm.calculateChangedBranches)
@@ -123,10 +152,15 @@ func (vm *GitHubPushModel) Update(msg tea.Msg) (*GitHubPushModel, tea.Cmd) {
 				vm.done = true
 				return vm, func() tea.Msg { return &GitHubPushDone{} }
 			}
-			if vm.pushFlag == "yes" {
+			if vm.pushPolicy == avconfig.PushAlways || vm.pushPolicy == avconfig.PushOnlyIfRestacked {
 				vm.runningGitPush = true
 				return vm, vm.runUpdate
 			}
+			if err := uiutils.RequireInteractive(
+				"confirming the push", "use --push=always or --push=never",
+			); err != nil {
+				return vm, func() tea.Msg { return err }
+			}
 			vm.askingForConfirmation = true
 			vm.pushPrompt = uiutils.NewPromptModel("Are you OK with pushing these branches to remote?", []string{continuePush, abortPush})
 			return vm, vm.pushPrompt.Init()
@@ -168,6 +202,12 @@ func (vm *GitHubPushModel) Update(msg tea.Msg) (*GitHubPushModel, tea.Cmd) {
 	return vm, nil
 }
 
+// ChoseNoPush returns true if the branches are not pushed because of --push=never or the user
+// declined the push.
+func (vm *GitHubPushModel) ChoseNoPush() bool {
+	return vm.chooseNoPush
+}
+
 func (vm *GitHubPushModel) View() string {
 	if vm.calculatingCandidates {
 		return colors.ProgressStyle.Render(vm.spinner.View() + "Finding the changed branches...")
@@ -175,16 +215,16 @@ func (vm *GitHubPushModel) View() string {
 
 	sb := strings.Builder{}
 	if len(vm.pushCandidates) == 0 {
-		sb.WriteString(colors.SuccessStyle.Render("✓ Nothing to push to GitHub"))
+		sb.WriteString(colors.SuccessStyle.Render("✓ Nothing to push to " + vm.client.Name()))
 	} else if vm.askingForConfirmation {
-		sb.WriteString("Confirming the push to GitHub")
+		sb.WriteString("Confirming the push to " + vm.client.Name())
 	} else if vm.runningGitPush {
-		sb.WriteString(colors.ProgressStyle.Render(vm.spinner.View() + "Pushing to GitHub..."))
+		sb.WriteString(colors.ProgressStyle.Render(vm.spinner.View() + "Pushing to " + vm.client.Name() + "..."))
 	} else if vm.done {
 		if vm.chooseNoPush {
-			sb.WriteString(colors.SuccessStyle.Render("✓ Not pushing to GitHub"))
+			sb.WriteString(colors.SuccessStyle.Render("✓ Not pushing to " + vm.client.Name()))
 		} else {
-			sb.WriteString(colors.SuccessStyle.Render("✓ Pushed to GitHub"))
+			sb.WriteString(colors.SuccessStyle.Render("✓ Pushed to " + vm.client.Name()))
 		}
 	}
 
@@ -246,6 +286,14 @@ func (vm *GitHubPushModel) viewPushCandidates() string {
 		if avbr.PullRequest != nil && avbr.PullRequest.Permalink != "" {
 			sb.WriteString("  PR:     " + avbr.PullRequest.Permalink + "\n")
 		}
+		if !branch.rewriteImpact.Empty() {
+			sb.WriteString(colors.Warning(
+				"  Warning: this push rewrites the history of a branch that others have based work on:",
+			) + "\n")
+			for _, line := range branch.rewriteImpact.Lines() {
+				sb.WriteString("    " + line + "\n")
+			}
+		}
 	}
 	return sb.String()
 }
@@ -285,19 +333,91 @@ func (vm *GitHubPushModel) runUpdate() (ret tea.Msg) {
 }
 
 func (vm *GitHubPushModel) runGitPush() error {
-	pushArgs := []string{"push", vm.repo.GetRemoteName(), "--atomic"}
+	// The push options (see config.PullRequestCIHints) apply to all the branches in a push, so
+	// the branches with different push options are pushed separately. So are the branches
+	// pushed to different remotes (branch.<name>.pushRemote).
+	type pushGroup struct {
+		remote  string
+		options []string
+	}
+	var groups []pushGroup
+	var candidates [][]pushCandidate
+	tx := vm.db.ReadTx()
 	for _, branch := range vm.pushCandidates {
-		// Do a compare-and-swap to be strict on what we show as a difference.
+		opts := actions.CIHintPushOptions(tx, branch.branch.Short())
+		i := slices.IndexFunc(groups, func(g pushGroup) bool {
+			return g.remote == branch.remote && slices.Equal(g.options, opts)
+		})
+		if i < 0 {
+			groups = append(groups, pushGroup{remote: branch.remote, options: opts})
+			candidates = append(candidates, nil)
+			i = len(groups) - 1
+		}
+		candidates[i] = append(candidates[i], branch)
+	}
+	// Each push is atomic, but the pushes are not. The pushed branches are recorded after each
+	// push so that a failure leaves the state of the branches pushed before it accurate.
+	var pushed []string
+	for i, group := range groups {
+		if err := vm.gitPush(group.remote, group.options, candidates[i]); err != nil {
+			if len(pushed) > 0 {
+				return errors.WrapIff(
+					err, "pushed %s, but failed to push the other branches",
+					strings.Join(pushed, ", "),
+				)
+			}
+			return err
+		}
+		if err := vm.recordPushedBranches(candidates[i]); err != nil {
+			return err
+		}
+		for _, branch := range candidates[i] {
+			pushed = append(pushed, branch.branch.Short())
+		}
+	}
+	if err := vm.repo.PushBranchNotes(); err != nil {
+		logrus.WithError(err).Warning("failed to push the branch notes")
+	}
+	return nil
+}
+
+// recordPushedBranches records the remote and the commit that the branches were pushed to.
+func (vm *GitHubPushModel) recordPushedBranches(candidates []pushCandidate) error {
+	for _, branch := range candidates {
+		if err := vm.repo.BranchSetConfig(branch.branch.Short(), "av-pushed-remote", branch.remote); err != nil {
+			return err
+		}
+		if err := vm.repo.BranchSetConfig(branch.branch.Short(), "av-pushed-ref", branch.branch.String()); err != nil {
+			return err
+		}
+		if err := vm.repo.BranchSetConfig(branch.branch.Short(), "av-pushed-commit", branch.localCommit.Hash.String()); err != nil {
+			return err
+		}
+		if avconfig.Av.UpstreamTracking == avconfig.UpstreamTrackingPush {
+			if err := vm.repo.BranchSetUpstream(branch.branch.Short(), branch.remote); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (vm *GitHubPushModel) gitPush(
+	remoteName string,
+	pushOptions []string,
+	candidates []pushCandidate,
+) error {
+	pushArgs := []string{"push", remoteName, "--atomic"}
+	pushArgs = append(pushArgs, pushOptions...)
+	for _, branch := range candidates {
+		// Do a compare-and-swap against the last-known remote commit so that the commits
+		// pushed by someone else are not overwritten.
 		pushArgs = append(
 			pushArgs,
-			fmt.Sprintf(
-				"--force-with-lease=%s:%s",
-				branch.branch.String(),
-				branch.remoteCommit.Hash.String(),
-			),
+			fmt.Sprintf("--force-with-lease=%s:%s", branch.branch.String(), branch.leaseCommit),
 		)
 	}
-	for _, branch := range vm.pushCandidates {
+	for _, branch := range candidates {
 		// Push the exact commit hash to be strict on what we show as a difference.
 		pushArgs = append(pushArgs,
 			fmt.Sprintf("%s:%s", branch.localCommit.Hash.String(), branch.branch.String()),
@@ -310,19 +430,20 @@ func (vm *GitHubPushModel) runGitPush() error {
 		return errors.WrapIff(err, "failed to push branches to GitHub")
 	}
 	if res.ExitCode != 0 {
-		return errors.Errorf("failed to push branches to GitHub\n%s\n%s", res.Stdout, res.Stderr)
-	}
-
-	for _, branch := range vm.pushCandidates {
-		if err := vm.repo.BranchSetConfig(branch.branch.Short(), "av-pushed-remote", vm.repo.GetRemoteName()); err != nil {
-			return err
-		}
-		if err := vm.repo.BranchSetConfig(branch.branch.Short(), "av-pushed-ref", branch.branch.String()); err != nil {
-			return err
-		}
-		if err := vm.repo.BranchSetConfig(branch.branch.Short(), "av-pushed-commit", branch.localCommit.Hash.String()); err != nil {
-			return err
+		if actions.IsStaleLeaseRejection(res.Stderr) {
+			// The remote branches moved after the fetch. The push is atomic, so none of
+			// these branches is pushed.
+			var names []string
+			for _, branch := range candidates {
+				names = append(names, branch.branch.Short())
+			}
+			return errors.Errorf(
+				"failed to push branches to GitHub: a remote branch moved since the last fetch "+
+					"(one of %s); none of them was pushed, run av sync again to fetch it\n%s",
+				strings.Join(names, ", "), res.Stderr,
+			)
 		}
+		return errors.Errorf("failed to push branches to GitHub\n%s\n%s", res.Stdout, res.Stderr)
 	}
 	return nil
 }
@@ -382,13 +503,25 @@ func (vm *GitHubPushModel) updatePRs(ghPRs map[plumbing.ReferenceName]*gh.PullRe
 			stackToWrite,
 			vm.db.ReadTx(),
 		)
-		if _, err := vm.client.UpdatePullRequest(context.Background(), githubv4.UpdatePullRequestInput{
-			PullRequestID: pr.ID,
-			BaseRefName:   githubv4.NewString(githubv4.String(avbr.Parent.Name)),
-			Body:          githubv4.NewString(githubv4.String(prBody)),
+		if _, err := vm.client.UpdatePullRequest(context.Background(), forge.UpdatePullRequestInput{
+			ID:          pr.ID,
+			BaseRefName: &avbr.Parent.Name,
+			Body:        &prBody,
 		}); err != nil {
 			return err
 		}
+		if err := actions.SyncStackComment(
+			context.Background(), vm.client, vm.db.ReadTx(), avbr.Name, pr.ID, stackToWrite,
+		); err != nil {
+			return err
+		}
+		if client := forge.GitHubClient(vm.client); client != nil {
+			if err := actions.SyncCIHintLabel(
+				context.Background(), client, vm.db.ReadTx(), avbr.Name, pr.ID, io.Discard,
+			); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -406,12 +539,6 @@ func (vm *GitHubPushModel) undraftPRs(ghPRs map[plumbing.ReferenceName]*gh.PullR
 
 func (vm *GitHubPushModel) calculateChangedBranches() tea.Msg {
 	repo := vm.repo.GoGitRepo()
-	remote, err := repo.Remote(vm.repo.GetRemoteName())
-	if err != nil {
-		return errors.Errorf("failed to get remote %s: %v", vm.repo.GetRemoteName(), err)
-	}
-	remoteConfig := remote.Config()
-
 	var noPushBranches []noPushBranch
 	var pushCandidates []pushCandidate
 
@@ -432,7 +559,41 @@ func (vm *GitHubPushModel) calculateChangedBranches() tea.Msg {
 			})
 			continue
 		}
+		if avbr.IsInMergeQueue() {
+			// Force-pushing a queued branch removes it from the queue.
+			noPushBranches = append(noPushBranches, noPushBranch{
+				branch: br,
+				reason: reasonPRIsQueued,
+			})
+			continue
+		}
+		if avbr.IsFollowed() {
+			noPushBranches = append(noPushBranches, noPushBranch{
+				branch: br,
+				reason: reasonFollowed,
+			})
+			continue
+		}
+		if vm.pushPolicy == avconfig.PushOnlyIfRestacked && !vm.restackedBranches[br.Short()] {
+			noPushBranches = append(noPushBranches, noPushBranch{
+				branch: br,
+				reason: reasonNotRestacked,
+			})
+			continue
+		}
+		if meta.NoSubmitAncestor(vm.db.ReadTx(), br.Short()) != "" {
+			noPushBranches = append(noPushBranches, noPushBranch{
+				branch: br,
+				reason: reasonNoSubmit,
+			})
+			continue
+		}
 
+		remoteName := vm.repo.GetBranchPushRemoteName(br.Short())
+		remoteConfig, err := vm.remoteConfig(remoteName)
+		if err != nil {
+			return err
+		}
 		rtb := mapToRemoteTrackingBranch(remoteConfig, br)
 		if rtb == nil {
 			noPushBranches = append(noPushBranches, noPushBranch{
@@ -451,10 +612,12 @@ func (vm *GitHubPushModel) calculateChangedBranches() tea.Msg {
 			continue
 		}
 
-		localRef, err := repo.Reference(br, true)
+		// The local-only commits are excluded from the pushed commit.
+		pushHash, err := actions.PushCommit(vm.repo, vm.db.ReadTx(), br.Short())
 		if err != nil {
 			return err
 		}
+		localHash := plumbing.NewHash(pushHash)
 
 		localPRMeta := vm.createPRMetadata(avbr)
 		remotePRMeta := actions.PRMetadata{}
@@ -466,7 +629,7 @@ func (vm *GitHubPushModel) calculateChangedBranches() tea.Msg {
 			}
 		}
 
-		if localRef.Hash() == remoteRef.Hash() && localPRMeta == remotePRMeta {
+		if localHash == remoteRef.Hash() && localPRMeta == remotePRMeta {
 			noPushBranches = append(noPushBranches, noPushBranch{
 				branch: br,
 				reason: reasonAlreadyUpToDate,
@@ -474,7 +637,15 @@ func (vm *GitHubPushModel) calculateChangedBranches() tea.Msg {
 			continue
 		}
 
-		if !vm.allParentsHaveRemoteTrackingBranch(remoteConfig, br) {
+		if vm.isRemoteRewritten(br, localHash, remoteRef.Hash()) {
+			// Pushing would overwrite the rewritten history (see GitHubFetchModel).
+			noPushBranches = append(noPushBranches, noPushBranch{
+				branch: br,
+				reason: reasonRemoteRewritten,
+			})
+			continue
+		}
+		if !vm.allParentsHaveRemoteTrackingBranch(br) {
 			// If a parent doesn't have a remote tracking branch, the PR cannot be made
 			// with that branch as the base. We cannot push this branch.
 			noPushBranches = append(noPushBranches, noPushBranch{
@@ -497,17 +668,36 @@ func (vm *GitHubPushModel) calculateChangedBranches() tea.Msg {
 		if err != nil {
 			return err
 		}
-		localRefCommit, err := repo.CommitObject(localRef.Hash())
+		localRefCommit, err := repo.CommitObject(localHash)
 		if err != nil {
 			return err
 		}
 
+		// Abort rather than overwrite the commits that someone else pushed to the branch.
+		leaseCommit, err := actions.PushLease(
+			vm.repo, remoteName, br.Short(), remoteRef.Hash().String(), localHash.String(),
+		)
+		if err != nil {
+			return err
+		}
+
+		var rewriteImpact actions.HistoryRewriteImpact
+		if isAncestor, err := remoteRefCommit.IsAncestor(localRefCommit); err == nil && !isAncestor {
+			rewriteImpact = actions.FindHistoryRewriteImpact(
+				context.Background(), vm.repo, vm.client, vm.db.ReadTx(),
+				remoteName, br.Short(), remoteRef.Hash().String(),
+			)
+		}
+
 		pushCandidates = append(pushCandidates, pushCandidate{
-			branch:       br,
-			remoteCommit: remoteRefCommit,
-			localCommit:  localRefCommit,
-			remotePRMeta: remotePRMeta,
-			localPRMeta:  localPRMeta,
+			branch:        br,
+			remote:        remoteName,
+			remoteCommit:  remoteRefCommit,
+			leaseCommit:   leaseCommit,
+			localCommit:   localRefCommit,
+			remotePRMeta:  remotePRMeta,
+			localPRMeta:   localPRMeta,
+			rewriteImpact: rewriteImpact,
 		})
 	}
 	vm.noPushBranches = noPushBranches
@@ -515,14 +705,46 @@ func (vm *GitHubPushModel) calculateChangedBranches() tea.Msg {
 	return &GitHubPushProgress{candidateCalculationDone: true}
 }
 
-func (vm *GitHubPushModel) allParentsHaveRemoteTrackingBranch(
-	remoteConfig *config.RemoteConfig,
+// isRemoteRewritten returns true if the remote branch was rewritten since av pushed it last
+// (e.g., force-pushed by a teammate), and pushing the local branch would overwrite it. A remote
+// commit that was once the local branch (e.g., force-pushed with git push by the user) is not a
+// rewrite.
+func (vm *GitHubPushModel) isRemoteRewritten(
 	br plumbing.ReferenceName,
+	localHash, remoteHash plumbing.Hash,
 ) bool {
+	pushed, err := vm.repo.Git("config", "--get", "branch."+br.Short()+".av-pushed-commit")
+	if err != nil || pushed == "" || pushed == remoteHash.String() {
+		return false
+	}
+	if ok, err := vm.repo.IsAncestor(remoteHash.String(), localHash.String()); err != nil || ok {
+		return false
+	}
+	if ok, err := vm.repo.ReflogContains(br.String(), remoteHash.String()); err != nil || ok {
+		return false
+	}
+	ok, err := vm.repo.IsAncestor(pushed, remoteHash.String())
+	return err == nil && !ok
+}
+
+func (vm *GitHubPushModel) remoteConfig(remoteName string) (*config.RemoteConfig, error) {
+	remote, err := vm.repo.GoGitRepo().Remote(remoteName)
+	if err != nil {
+		return nil, errors.Errorf("failed to get remote %s: %v", remoteName, err)
+	}
+	return remote.Config(), nil
+}
+
+func (vm *GitHubPushModel) allParentsHaveRemoteTrackingBranch(br plumbing.ReferenceName) bool {
 	avbr, _ := vm.db.ReadTx().Branch(br.Short())
 	parent := avbr.Parent
 	for !parent.Trunk {
 		avbr, _ := vm.db.ReadTx().Branch(parent.Name)
+		// Each parent is checked on the remote that it's pushed to.
+		remoteConfig, err := vm.remoteConfig(vm.repo.GetBranchPushRemoteName(parent.Name))
+		if err != nil {
+			return false
+		}
 		rtb := mapToRemoteTrackingBranch(remoteConfig, plumbing.NewBranchReferenceName(parent.Name))
 		if rtb == nil {
 			return false
@@ -535,7 +757,9 @@ func (vm *GitHubPushModel) allParentsHaveRemoteTrackingBranch(
 func (vm *GitHubPushModel) allBranchesOnStackHavePRs(br plumbing.ReferenceName) bool {
 	avbr, _ := vm.db.ReadTx().Branch(br.Short())
 	for {
-		if avbr.PullRequest == nil {
+		// The pull request of a followed branch may not be found (e.g., it's in another
+		// repository), but the branch is on the remote, so it can be the base branch.
+		if avbr.PullRequest == nil && !avbr.IsFollowed() {
 			return false
 		}
 		if avbr.Parent.Trunk {
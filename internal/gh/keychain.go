@@ -0,0 +1,45 @@
+package gh
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// The service name of the GitHub token in the OS keychain. The account (or the attribute) is
+// the GitHub host (e.g., github.com).
+const keychainService = "av"
+
+func keychainName() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "macOS Keychain"
+	case "windows":
+		return "Windows Credential Manager"
+	default:
+		return "Secret Service keyring (libsecret)"
+	}
+}
+
+// keychainToken reads the GitHub token of the host from the OS keychain.
+//
+//   - macOS: the generic password of the service "av" and the account of the host.
+//   - Windows: the generic credential of the target "av:<host>".
+//   - Others: the secret of the attributes service=av and host=<host> via secret-tool.
+func keychainToken(host string) (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return runCredentialHelper(
+			"security", "find-generic-password", "-s", keychainService, "-a", host, "-w",
+		)
+	case "windows":
+		return windowsCredential(keychainService + ":" + host)
+	default:
+		secretTool, err := exec.LookPath("secret-tool")
+		if err != nil {
+			return "", nil
+		}
+		return runCredentialHelper(
+			secretTool, "lookup", "service", keychainService, "host", host,
+		)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package gh
+
+func windowsCredential(string) (string, error) {
+	return "", nil
+}
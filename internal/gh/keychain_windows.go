@@ -0,0 +1,66 @@
+package gh
+
+import (
+	"syscall"
+	"unsafe"
+
+	"emperror.dev/errors"
+)
+
+var (
+	advapi32     = syscall.NewLazyDLL("advapi32.dll")
+	procCredRead = advapi32.NewProc("CredReadW")
+	procCredFree = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric   = 1
+	errorNotFound     = syscall.Errno(1168)
+	credentialBlobMax = 5 * 512
+)
+
+// credential is the CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// windowsCredential reads the password of the generic credential of the target from the
+// Windows Credential Manager (e.g., the one added by `cmdkey /generic:<target>`).
+func windowsCredential(target string) (string, error) {
+	name, err := syscall.UTF16PtrFromString(target)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	ret, _, err := procCredRead.Call(
+		uintptr(unsafe.Pointer(name)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)),
+	)
+	if ret == 0 {
+		if errors.Is(err, errorNotFound) {
+			return "", nil
+		}
+		return "", errors.WrapIff(err, "failed to read the credential %q", target)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred))) //nolint:errcheck
+	if cred.CredentialBlobSize == 0 || cred.CredentialBlobSize > credentialBlobMax {
+		return "", nil
+	}
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	// cmdkey stores the password in UTF-16.
+	if len(blob)%2 == 0 {
+		u16 := unsafe.Slice((*uint16)(unsafe.Pointer(cred.CredentialBlob)), len(blob)/2)
+		return syscall.UTF16ToString(u16), nil
+	}
+	return string(blob), nil
+}
@@ -0,0 +1,115 @@
+package gh
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+type ProjectV2 struct {
+	ID     githubv4.ID `graphql:"id"`
+	Title  string      `graphql:"title"`
+	Fields struct {
+		Nodes []ProjectV2Field
+	} `graphql:"fields(first: 100)"`
+}
+
+// ProjectV2Field is a field of a GitHub project (v2). Only one of the embedded structs is
+// populated depending on Typename.
+type ProjectV2Field struct {
+	Typename string `graphql:"__typename"`
+	Field    struct {
+		ID       githubv4.ID                 `graphql:"id"`
+		Name     string                      `graphql:"name"`
+		DataType githubv4.ProjectV2FieldType `graphql:"dataType"`
+	} `graphql:"... on ProjectV2Field"`
+	SingleSelectField struct {
+		ID      githubv4.ID `graphql:"id"`
+		Name    string      `graphql:"name"`
+		Options []struct {
+			ID   string `graphql:"id"`
+			Name string `graphql:"name"`
+		} `graphql:"options"`
+	} `graphql:"... on ProjectV2SingleSelectField"`
+	IterationField struct {
+		ID            githubv4.ID `graphql:"id"`
+		Name          string      `graphql:"name"`
+		Configuration struct {
+			Iterations []ProjectV2Iteration `graphql:"iterations"`
+		} `graphql:"configuration"`
+	} `graphql:"... on ProjectV2IterationField"`
+}
+
+type ProjectV2Iteration struct {
+	ID    string `graphql:"id"`
+	Title string `graphql:"title"`
+	// The start date of the iteration in the format of YYYY-MM-DD.
+	StartDate string `graphql:"startDate"`
+	// The duration of the iteration in days.
+	Duration int `graphql:"duration"`
+}
+
+// ProjectV2 returns the GitHub project (v2) with the given number owned by the given
+// organization or user.
+func (c *Client) ProjectV2(ctx context.Context, ownerLogin string, number int) (*ProjectV2, error) {
+	var query struct {
+		RepositoryOwner struct {
+			Organization struct {
+				ProjectV2 ProjectV2 `graphql:"projectV2(number: $number)"`
+			} `graphql:"... on Organization"`
+			User struct {
+				ProjectV2 ProjectV2 `graphql:"projectV2(number: $number)"`
+			} `graphql:"... on User"`
+		} `graphql:"repositoryOwner(login: $ownerLogin)"`
+	}
+	if err := c.query(ctx, &query, map[string]any{
+		"ownerLogin": githubv4.String(ownerLogin),
+		"number":     githubv4.Int(number),
+	}); err != nil {
+		return nil, errors.WrapIff(err, "failed to query GitHub project %s/%d", ownerLogin, number)
+	}
+	if query.RepositoryOwner.Organization.ProjectV2.ID != nil {
+		return &query.RepositoryOwner.Organization.ProjectV2, nil
+	}
+	if query.RepositoryOwner.User.ProjectV2.ID != nil {
+		return &query.RepositoryOwner.User.ProjectV2, nil
+	}
+	return nil, errors.Errorf("GitHub project %s/%d not found", ownerLogin, number)
+}
+
+// AddProjectV2Item adds the given pull request or issue to the given project and returns the
+// ID of the project item. If the content is already in the project, the existing item is
+// returned.
+func (c *Client) AddProjectV2Item(
+	ctx context.Context,
+	input githubv4.AddProjectV2ItemByIdInput,
+) (githubv4.ID, error) {
+	var mutation struct {
+		AddProjectV2ItemByID struct {
+			Item struct {
+				ID githubv4.ID `graphql:"id"`
+			} `graphql:"item"`
+		} `graphql:"addProjectV2ItemById(input: $input)"`
+	}
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return nil, errors.Wrap(err, "failed to add item to project: github error")
+	}
+	return mutation.AddProjectV2ItemByID.Item.ID, nil
+}
+
+// UpdateProjectV2ItemFieldValue sets a field value of the given project item.
+func (c *Client) UpdateProjectV2ItemFieldValue(
+	ctx context.Context,
+	input githubv4.UpdateProjectV2ItemFieldValueInput,
+) error {
+	var mutation struct {
+		UpdateProjectV2ItemFieldValue struct {
+			ClientMutationID string `graphql:"clientMutationId"`
+		} `graphql:"updateProjectV2ItemFieldValue(input: $input)"`
+	}
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return errors.Wrap(err, "failed to update project item field: github error")
+	}
+	return nil
+}
@@ -9,11 +9,13 @@ import (
 )
 
 type PullRequest struct {
-	ID                  string
-	Number              int64
-	HeadRefName         string
-	BaseRefName         string
-	IsDraft             bool
+	ID          string
+	Number      int64
+	HeadRefName string
+	BaseRefName string
+	IsDraft     bool
+	// True if the pull request is in the merge queue of the base branch.
+	IsInMergeQueue      bool
 	Permalink           string
 	State               githubv4.PullRequestState
 	Title               string
@@ -80,6 +82,82 @@ func (c *Client) PullRequest(ctx context.Context, id string) (*PullRequest, erro
 	return &query.Node.PullRequest, nil
 }
 
+// PullRequests returns the pull requests with the given IDs in a single query (per 100 IDs).
+// The pull requests that are not found (e.g., deleted) are omitted.
+func (c *Client) PullRequests(ctx context.Context, ids []string) ([]PullRequest, error) {
+	var ret []PullRequest
+	for start := 0; start < len(ids); start += maxNodesPerQuery {
+		end := min(start+maxNodesPerQuery, len(ids))
+		var query struct {
+			Nodes []struct {
+				PullRequest PullRequest `graphql:"... on PullRequest"`
+			} `graphql:"nodes(ids: $ids)"`
+		}
+		var gqlIDs []githubv4.ID
+		for _, id := range ids[start:end] {
+			gqlIDs = append(gqlIDs, githubv4.ID(id))
+		}
+		if err := c.query(ctx, &query, map[string]any{
+			"ids": gqlIDs,
+		}); err != nil {
+			return nil, errors.Wrap(err, "failed to query pull requests")
+		}
+		for _, node := range query.Nodes {
+			if node.PullRequest.ID != "" {
+				ret = append(ret, node.PullRequest)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// PullRequestByNumber returns the pull request with the given number in the repository.
+func (c *Client) PullRequestByNumber(ctx context.Context, opts PullRequestOpts) (*PullRequest, error) {
+	var query struct {
+		Repository struct {
+			PullRequest *PullRequest `graphql:"pullRequest(number: $number)"`
+		} `graphql:"repository(owner: $owner, name: $repo)"`
+	}
+	if err := c.query(ctx, &query, map[string]interface{}{
+		"owner":  githubv4.String(opts.Owner),
+		"repo":   githubv4.String(opts.Repo),
+		"number": githubv4.Int(opts.Number),
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to query pull request")
+	}
+	if query.Repository.PullRequest == nil {
+		return nil, errors.Errorf("pull request #%d not found", opts.Number)
+	}
+	return query.Repository.PullRequest, nil
+}
+
+// PullRequestRefs is the base and head commits of a pull request as seen by GitHub.
+type PullRequestRefs struct {
+	BaseRefName string
+	BaseRefOid  string
+	HeadRefName string
+	HeadRefOid  string
+}
+
+// PullRequestRefs returns the current base and head commits of the pull request. GitHub shows
+// the diff between the merge base of these commits and the head commit.
+func (c *Client) PullRequestRefs(ctx context.Context, id string) (*PullRequestRefs, error) {
+	var query struct {
+		Node struct {
+			PullRequest PullRequestRefs `graphql:"... on PullRequest"`
+		} `graphql:"node(id: $id)"`
+	}
+	if err := c.query(ctx, &query, map[string]interface{}{
+		"id": githubv4.ID(id),
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to query pull request")
+	}
+	if query.Node.PullRequest.HeadRefOid == "" {
+		return nil, errors.Errorf("pull request %q not found", id)
+	}
+	return &query.Node.PullRequest, nil
+}
+
 type GetPullRequestsInput struct {
 	// REQUIRED
 	Owner string
@@ -207,6 +285,35 @@ func (c *Client) MarkPullRequestReadyForReview(
 	return &mutation.MarkPullRequestReadyForReview.PullRequest, nil
 }
 
+// EnablePullRequestAutoMerge enables auto-merge on the pull request. GitHub merges the pull
+// request once its requirements (e.g., the required checks) are met.
+func (c *Client) EnablePullRequestAutoMerge(
+	ctx context.Context,
+	input githubv4.EnablePullRequestAutoMergeInput,
+) error {
+	var mutation struct {
+		EnablePullRequestAutoMerge struct {
+			ClientMutationID string `graphql:"clientMutationId"`
+		} `graphql:"enablePullRequestAutoMerge(input: $input)"`
+	}
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return errors.Wrap(err, "failed to enable auto-merge: github error")
+	}
+	return nil
+}
+
+func (c *Client) ClosePullRequest(ctx context.Context, id string) (*PullRequest, error) {
+	var mutation struct {
+		ClosePullRequest struct {
+			PullRequest PullRequest
+		} `graphql:"closePullRequest(input: $input)"`
+	}
+	if err := c.mutate(ctx, &mutation, githubv4.ClosePullRequestInput{PullRequestID: id}, nil); err != nil {
+		return nil, errors.Wrap(err, "failed to close pull request: github error")
+	}
+	return &mutation.ClosePullRequest.PullRequest, nil
+}
+
 type RepoPullRequestOpts struct {
 	Owner  string
 	Repo   string
@@ -260,3 +367,88 @@ func (c *Client) RepoPullRequests(
 		PullRequests: query.Repository.PullRequests.Nodes,
 	}, nil
 }
+
+// BasedPullRequest is an open pull request that uses a branch as the base branch.
+type BasedPullRequest struct {
+	Number      int64
+	Permalink   string
+	HeadRefName string
+	Author      struct {
+		Login string
+	}
+}
+
+// OpenPullRequestsWithBase returns the open pull requests whose base branch is the given
+// branch.
+func (c *Client) OpenPullRequestsWithBase(
+	ctx context.Context,
+	owner string,
+	repo string,
+	baseRefName string,
+) ([]BasedPullRequest, error) {
+	var query struct {
+		Repository struct {
+			PullRequests struct {
+				Nodes []BasedPullRequest
+			} `graphql:"pullRequests(baseRefName: $baseRefName, states: OPEN, first: 50)"`
+		} `graphql:"repository(owner: $owner, name: $repo)"`
+	}
+	if err := c.query(ctx, &query, map[string]any{
+		"owner":       githubv4.String(owner),
+		"repo":        githubv4.String(repo),
+		"baseRefName": githubv4.String(baseRefName),
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to query pull requests")
+	}
+	return query.Repository.PullRequests.Nodes, nil
+}
+
+// AddComment adds a comment to the given pull request or issue.
+func (c *Client) AddComment(ctx context.Context, input githubv4.AddCommentInput) error {
+	var mutation struct {
+		AddComment struct {
+			ClientMutationID string `graphql:"clientMutationId"`
+		} `graphql:"addComment(input: $input)"`
+	}
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return errors.Wrap(err, "failed to add comment: github error")
+	}
+	return nil
+}
+
+// IssueComment is a comment on a pull request or an issue.
+type IssueComment struct {
+	ID              string
+	Body            string
+	ViewerDidAuthor bool
+}
+
+// PullRequestComments returns the last 100 comments of the given pull request.
+func (c *Client) PullRequestComments(ctx context.Context, id string) ([]IssueComment, error) {
+	var query struct {
+		Node struct {
+			PullRequest struct {
+				Comments struct {
+					Nodes []IssueComment
+				} `graphql:"comments(last: 100)"`
+			} `graphql:"... on PullRequest"`
+		} `graphql:"node(id: $id)"`
+	}
+	if err := c.query(ctx, &query, map[string]any{"id": githubv4.ID(id)}); err != nil {
+		return nil, errors.Wrap(err, "failed to query pull request comments")
+	}
+	return query.Node.PullRequest.Comments.Nodes, nil
+}
+
+// UpdateIssueComment updates the body of the given comment.
+func (c *Client) UpdateIssueComment(ctx context.Context, input githubv4.UpdateIssueCommentInput) error {
+	var mutation struct {
+		UpdateIssueComment struct {
+			ClientMutationID string `graphql:"clientMutationId"`
+		} `graphql:"updateIssueComment(input: $input)"`
+	}
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return errors.Wrap(err, "failed to update comment: github error")
+	}
+	return nil
+}
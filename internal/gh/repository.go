@@ -38,3 +38,22 @@ func (c *Client) GetRepositoryBySlug(ctx context.Context, slug string) (*Reposit
 
 	return &query.Repository, nil
 }
+
+// GetRepositoryByID returns the repository of the node ID. Unlike the owner and the name, the ID
+// doesn't change when the repository is transferred or renamed.
+func (c *Client) GetRepositoryByID(ctx context.Context, id string) (*Repository, error) {
+	var query struct {
+		Node struct {
+			Repository Repository `graphql:"... on Repository"`
+		} `graphql:"node(id: $id)"`
+	}
+	if err := c.query(ctx, &query, map[string]interface{}{
+		"id": githubv4.ID(id),
+	}); err != nil {
+		return nil, errors.Wrap(err, "unable to fetch repository from GitHub")
+	}
+	if query.Node.Repository.ID == "" {
+		return nil, errors.Errorf("repository %q not found", id)
+	}
+	return &query.Node.Repository, nil
+}
@@ -0,0 +1,193 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// The longest wait for a rate limit to reset. If a rate limit resets later than this (e.g.,
+// the primary rate limit that resets hourly), the request fails instead of blocking av.
+const maxRateLimitWait = 5 * time.Minute
+
+// sharedRateLimiter is shared by all the GitHub clients of the process.
+var sharedRateLimiter = &rateLimiter{}
+
+// rateLimiter holds back all the requests while a rate limit is in effect so that the requests
+// sent concurrently (e.g., the branches submitted in parallel) don't keep hitting the limit.
+type rateLimiter struct {
+	mu       sync.Mutex
+	resumeAt time.Time
+}
+
+// wait blocks until the rate limit is lifted.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		d := time.Until(l.resumeAt)
+		l.mu.Unlock()
+		if d <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// pause holds back the requests for the duration.
+func (l *rateLimiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if t := time.Now().Add(d); t.After(l.resumeAt) {
+		l.resumeAt = t
+	}
+}
+
+// retryTransport is an http.RoundTripper that retries the requests rejected by the GitHub API
+// rate limits (both the primary and the secondary rate limits). It waits as instructed by the
+// Retry-After and X-RateLimit-Reset headers, or with an exponential backoff.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	// The first backoff delay when the response doesn't tell how long to wait.
+	backoff time.Duration
+	limiter *rateLimiter
+}
+
+func newRetryTransport(base http.RoundTripper, maxRetries int) *retryTransport {
+	return &retryTransport{
+		base:       base,
+		maxRetries: maxRetries,
+		backoff:    time.Second,
+		limiter:    sharedRateLimiter,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.GetBody == nil {
+		// The body is sent again on retries.
+		body, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+		r := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+		resp, err := t.base.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		delay, limited, err := rateLimitDelay(resp, attempt, t.backoff)
+		if err != nil {
+			return nil, err
+		}
+		if !limited || attempt >= t.maxRetries || delay > maxRateLimitWait {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		logrus.WithFields(logrus.Fields{
+			"url":     req.URL.String(),
+			"attempt": attempt + 1,
+			"delay":   delay,
+		}).Debug("GitHub API rate limit exceeded, retrying...")
+		t.limiter.pause(delay)
+	}
+}
+
+// rateLimitDelay returns how long to wait before retrying the request if the response is a
+// rate limit error. The response body is restored after it's inspected.
+func rateLimitDelay(resp *http.Response, attempt int, backoff time.Duration) (time.Duration, bool, error) {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusForbidden, http.StatusOK:
+	default:
+		return 0, false, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return 0, false, errors.WrapIf(err, "failed to read the GitHub API response")
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if !isRateLimited(resp, body) {
+		return 0, false, nil
+	}
+
+	if s := resp.Header.Get("Retry-After"); s != "" {
+		if secs, err := strconv.Atoi(s); err == nil {
+			return time.Duration(secs) * time.Second, true, nil
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return max(time.Until(time.Unix(reset, 0))+time.Second, 0), true, nil
+		}
+	}
+	// Exponential backoff with jitter, up to a minute.
+	delay := min(backoff<<attempt, time.Minute)
+	delay += time.Duration(rand.Int64N(int64(delay)/4 + 1))
+	return delay, true, nil
+}
+
+func isRateLimited(resp *http.Response, body []byte) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		// GitHub returns 403 for both the rate limits and the permission errors.
+		return resp.Header.Get("Retry-After") != "" ||
+			resp.Header.Get("X-RateLimit-Remaining") == "0" ||
+			strings.Contains(strings.ToLower(string(body)), "rate limit")
+	case http.StatusOK:
+		// The GraphQL API reports the rate limits as the errors in a successful response.
+		if !bytes.Contains(body, []byte("RATE_LIMITED")) {
+			return false
+		}
+		var graphqlResp struct {
+			Errors []struct {
+				Type string `json:"type"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(body, &graphqlResp); err != nil {
+			return false
+		}
+		for _, e := range graphqlResp.Errors {
+			if e.Type == "RATE_LIMITED" {
+				return true
+			}
+		}
+	}
+	return false
+}
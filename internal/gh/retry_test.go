@@ -0,0 +1,115 @@
+package gh
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTransport(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		limited func(w http.ResponseWriter)
+	}{
+		{
+			name: "secondary rate limit",
+			limited: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = io.WriteString(w, `{"message":"You have exceeded a secondary rate limit."}`)
+			},
+		},
+		{
+			name: "too many requests",
+			limited: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+		},
+		{
+			name: "GraphQL rate limit",
+			limited: func(w http.ResponseWriter) {
+				_, _ = io.WriteString(w, `{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`)
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				require.Equal(t, `{"query":"viewer"}`, string(body), "the body should be sent on retries")
+				if calls.Add(1) < 3 {
+					tt.limited(w)
+					return
+				}
+				_, _ = io.WriteString(w, `{"data":{}}`)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: &retryTransport{
+				base:       http.DefaultTransport,
+				maxRetries: 5,
+				backoff:    time.Millisecond,
+				limiter:    &rateLimiter{},
+			}}
+			resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"query":"viewer"}`))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, `{"data":{}}`, string(body))
+			require.EqualValues(t, 3, calls.Load())
+		})
+	}
+}
+
+func TestRetryTransportGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{
+		base:       http.DefaultTransport,
+		maxRetries: 2,
+		backoff:    time.Millisecond,
+		limiter:    &rateLimiter{},
+	}}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	require.EqualValues(t, 3, calls.Load())
+}
+
+func TestRetryTransportIgnoresPermissionErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `{"message":"Resource not accessible by integration"}`)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{
+		base:       http.DefaultTransport,
+		maxRetries: 2,
+		backoff:    time.Millisecond,
+		limiter:    &rateLimiter{},
+	}}
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	require.Contains(t, string(body), "Resource not accessible")
+	require.EqualValues(t, 1, calls.Load())
+}
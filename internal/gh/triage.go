@@ -0,0 +1,169 @@
+package gh
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+// PullRequestTriage is the reviewers, the labels, and the assignees of a pull request.
+type PullRequestTriage struct {
+	// The login of the author of the pull request.
+	Author string
+	// The logins of the users and the "org/slug" names of the teams whose reviews are
+	// requested.
+	RequestedReviewers []string
+	// The logins of the users who already reviewed the pull request.
+	Reviewers []string
+	// The names of the labels.
+	Labels []string
+	// The logins of the assignees.
+	Assignees []string
+}
+
+// PullRequestTriage returns the reviewers, the labels, and the assignees of the given pull
+// request.
+func (c *Client) PullRequestTriage(ctx context.Context, id string) (*PullRequestTriage, error) {
+	var query struct {
+		Node struct {
+			PullRequest struct {
+				ID     string
+				Author struct {
+					Login string
+				}
+				ReviewRequests struct {
+					Nodes []struct {
+						RequestedReviewer struct {
+							User struct {
+								Login string
+							} `graphql:"... on User"`
+							Team struct {
+								Slug         string
+								Organization struct {
+									Login string
+								}
+							} `graphql:"... on Team"`
+						}
+					}
+				} `graphql:"reviewRequests(first: 100)"`
+				LatestReviews struct {
+					Nodes []struct {
+						Author struct {
+							Login string
+						}
+					}
+				} `graphql:"latestReviews(first: 100)"`
+				Labels struct {
+					Nodes []struct {
+						Name string
+					}
+				} `graphql:"labels(first: 100)"`
+				Assignees struct {
+					Nodes []struct {
+						Login string
+					}
+				} `graphql:"assignees(first: 100)"`
+			} `graphql:"... on PullRequest"`
+		} `graphql:"node(id: $id)"`
+	}
+	if err := c.query(ctx, &query, map[string]any{
+		"id": githubv4.ID(id),
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to query pull request reviewers and labels")
+	}
+	pr := query.Node.PullRequest
+	if pr.ID == "" {
+		return nil, errors.Errorf("pull request %q not found", id)
+	}
+	ret := &PullRequestTriage{Author: pr.Author.Login}
+	for _, node := range pr.ReviewRequests.Nodes {
+		if login := node.RequestedReviewer.User.Login; login != "" {
+			ret.RequestedReviewers = append(ret.RequestedReviewers, login)
+		} else if team := node.RequestedReviewer.Team; team.Slug != "" {
+			ret.RequestedReviewers = append(
+				ret.RequestedReviewers, team.Organization.Login+"/"+team.Slug,
+			)
+		}
+	}
+	for _, node := range pr.LatestReviews.Nodes {
+		ret.Reviewers = append(ret.Reviewers, node.Author.Login)
+	}
+	for _, node := range pr.Labels.Nodes {
+		ret.Labels = append(ret.Labels, node.Name)
+	}
+	for _, node := range pr.Assignees.Nodes {
+		ret.Assignees = append(ret.Assignees, node.Login)
+	}
+	return ret, nil
+}
+
+type Label struct {
+	ID   githubv4.ID `graphql:"id"`
+	Name string      `graphql:"name"`
+}
+
+// RepositoryLabel returns the label with the given name in the repository.
+func (c *Client) RepositoryLabel(ctx context.Context, owner, repo, name string) (*Label, error) {
+	var query struct {
+		Repository struct {
+			Label *Label `graphql:"label(name: $name)"`
+		} `graphql:"repository(owner: $owner, name: $repo)"`
+	}
+	if err := c.query(ctx, &query, map[string]any{
+		"owner": githubv4.String(owner),
+		"repo":  githubv4.String(repo),
+		"name":  githubv4.String(name),
+	}); err != nil {
+		return nil, err
+	}
+	if query.Repository.Label == nil {
+		return nil, errors.Errorf("label %q not found in %s/%s", name, owner, repo)
+	}
+	return query.Repository.Label, nil
+}
+
+// AddLabels adds the labels to the pull request (or the issue).
+func (c *Client) AddLabels(ctx context.Context, input githubv4.AddLabelsToLabelableInput) error {
+	var mutation struct {
+		AddLabelsToLabelable struct {
+			ClientMutationID string `graphql:"clientMutationId"`
+		} `graphql:"addLabelsToLabelable(input: $input)"`
+	}
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return errors.Wrap(err, "failed to add labels: github error")
+	}
+	return nil
+}
+
+// AddAssignees adds the assignees to the pull request (or the issue).
+func (c *Client) AddAssignees(
+	ctx context.Context,
+	input githubv4.AddAssigneesToAssignableInput,
+) error {
+	var mutation struct {
+		AddAssigneesToAssignable struct {
+			ClientMutationID string `graphql:"clientMutationId"`
+		} `graphql:"addAssigneesToAssignable(input: $input)"`
+	}
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return errors.Wrap(err, "failed to add assignees: github error")
+	}
+	return nil
+}
+
+// RemoveLabels removes the labels from the pull request (or the issue).
+func (c *Client) RemoveLabels(
+	ctx context.Context,
+	input githubv4.RemoveLabelsFromLabelableInput,
+) error {
+	var mutation struct {
+		RemoveLabelsFromLabelable struct {
+			ClientMutationID string `graphql:"clientMutationId"`
+		} `graphql:"removeLabelsFromLabelable(input: $input)"`
+	}
+	if err := c.mutate(ctx, &mutation, input, nil); err != nil {
+		return errors.Wrap(err, "failed to remove labels: github error")
+	}
+	return nil
+}
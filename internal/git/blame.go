@@ -0,0 +1,49 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+
+	"emperror.dev/errors"
+)
+
+// The header line of a line in the git blame --porcelain output:
+// <commit> <original line> <final line> [<number of lines in the group>]
+var blameHeaderPattern = regexp.MustCompile(`^([0-9a-f]{40,64}) \d+ (\d+)(?: \d+)?$`)
+
+// BlameLines returns the commit that last changed each line of the file at the revision (as
+// reported by git blame). The first element is the commit of the first line.
+func (r *Repo) BlameLines(rev string, path string) ([]string, error) {
+	output, err := r.Run(&RunOpts{
+		Args:      []string{"blame", "--porcelain", rev, "--", path},
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to blame %q", path)
+	}
+	var ret []string
+	sc := bufio.NewScanner(bytes.NewReader(output.Stdout))
+	sc.Buffer(nil, 1<<30)
+	for sc.Scan() {
+		line := sc.Text()
+		if len(line) > 0 && line[0] == '\t' {
+			// The content of the line.
+			continue
+		}
+		m := blameHeaderPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil || n != len(ret)+1 {
+			return nil, errors.Errorf("unexpected git blame output: %q", line)
+		}
+		ret = append(ret, m[1])
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.WrapIf(err, "failed to read the git blame output")
+	}
+	return ret, nil
+}
@@ -11,12 +11,42 @@ func (r *Repo) BranchDelete(names ...string) error {
 	return err
 }
 
+// BranchDeleteRemote deletes the branch on the remote (equivalent to `git push <remote>
+// --delete <branch>`).
+func (r *Repo) BranchDeleteRemote(remote, name string) error {
+	_, err := r.Run(&RunOpts{
+		Args:      []string{"push", remote, "--delete", name},
+		ExitError: true,
+	})
+	return err
+}
+
 // BranchSetConfig sets a config on the given branch (equivalent to `git config
 // branch.<branch>.<key> <value>`).
 func (r *Repo) BranchSetConfig(name, key, value string) error {
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
 	_, err := r.Run(&RunOpts{
 		Args:      []string{"config", fmt.Sprintf("branch.%s.%s", name, key), value},
 		ExitError: true,
 	})
 	return err
 }
+
+// BranchSetPushRemote configures the given branch to be pushed to the remote (equivalent to
+// setting `branch.<branch>.pushRemote`). This takes precedence over remote.pushDefault for both
+// av and plain `git push`.
+func (r *Repo) BranchSetPushRemote(name, remote string) error {
+	return r.BranchSetConfig(name, "pushRemote", remote)
+}
+
+// BranchSetUpstream configures the given branch to track the branch with the same name on the
+// remote (equivalent to setting `branch.<branch>.remote` and `branch.<branch>.merge`). This
+// makes plain `git push` and `git pull` use that branch. The remote branch doesn't have to
+// exist yet.
+func (r *Repo) BranchSetUpstream(name, remote string) error {
+	if err := r.BranchSetConfig(name, "remote", remote); err != nil {
+		return err
+	}
+	return r.BranchSetConfig(name, "merge", "refs/heads/"+name)
+}
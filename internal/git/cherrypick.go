@@ -24,6 +24,16 @@ type CherryPick struct {
 	// (equivalent to the --no-commit flag on `git cherry-pick`).
 	NoCommit bool
 
+	// Signoff specifies whether or not to add a Signed-off-by trailer to the
+	// commits (equivalent to the --signoff flag on `git cherry-pick`). This
+	// can't be used with FastForward.
+	Signoff bool
+
+	// Sign specifies whether or not to sign the commits with the signing key configured in Git
+	// (equivalent to the --gpg-sign flag on `git cherry-pick`). The fast-forwarded commits are
+	// kept as they are.
+	Sign bool
+
 	// FastForward specifies whether or not to fast-forward the current branch
 	// if possible (equivalent to the --ff flag on `git cherry-pick`).
 	// If true, and the parent of the commit is the current HEAD, the HEAD
@@ -60,6 +70,12 @@ func (r *Repo) CherryPick(opts CherryPick) error {
 		if opts.NoCommit {
 			args = append(args, "--no-commit")
 		}
+		if opts.Signoff {
+			args = append(args, "--signoff")
+		}
+		if opts.Sign {
+			args = append(args, "--gpg-sign")
+		}
 		args = append(args, opts.Commits...)
 	}
 
@@ -0,0 +1,152 @@
+package git
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// ConflictSide is a side of a conflict, corresponding to the stage number of the index entry.
+//
+// Note that during a rebase, "ours" is the commit being rebased onto (i.e., the parent branch)
+// and "theirs" is the commit being replayed (i.e., the branch being rebased).
+type ConflictSide int
+
+const (
+	ConflictSideOurs   ConflictSide = 2
+	ConflictSideTheirs ConflictSide = 3
+)
+
+type ConflictedFile struct {
+	Path string
+	// True if any version of the file is binary, in which case the conflict cannot be
+	// resolved by editing the conflict markers.
+	Binary bool
+	// True if the path is a submodule. The conflict is resolved by checking out a commit in the
+	// submodule and staging it.
+	Submodule bool
+	// The blob object IDs of the conflicting versions keyed by the stage number. A missing
+	// stage means that the file is deleted on that side.
+	blobs map[ConflictSide]string
+}
+
+// IsDeletedOnOneSide returns true if the file is deleted on one side of the conflict and
+// modified on the other.
+func (f ConflictedFile) IsDeletedOnOneSide() bool {
+	_, ours := f.blobs[ConflictSideOurs]
+	_, theirs := f.blobs[ConflictSideTheirs]
+	return ours != theirs
+}
+
+// The file mode of the submodules (gitlinks) in the index.
+const gitlinkMode = "160000"
+
+// binaryDetectionSize is the number of bytes checked for a NUL byte to detect binary files.
+// This is the same heuristic as Git's.
+const binaryDetectionSize = 8000
+
+// ConflictedFiles returns the files that have unresolved conflicts in the index.
+func (r *Repo) ConflictedFiles() ([]ConflictedFile, error) {
+	output, err := r.Run(&RunOpts{
+		Args:      []string{"ls-files", "--unmerged", "-z"},
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to list the conflicted files")
+	}
+
+	var files []ConflictedFile
+	index := map[string]int{}
+	for _, entry := range strings.Split(string(output.Stdout), "\x00") {
+		if entry == "" {
+			continue
+		}
+		// Each entry is "<mode> <object> <stage>\t<path>".
+		info, path, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 3 {
+			return nil, errors.Errorf("unexpected git ls-files output: %q", entry)
+		}
+		stage, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, errors.Errorf("unexpected git ls-files output: %q", entry)
+		}
+		i, ok := index[path]
+		if !ok {
+			i = len(files)
+			index[path] = i
+			files = append(files, ConflictedFile{Path: path, blobs: map[ConflictSide]string{}})
+		}
+		files[i].blobs[ConflictSide(stage)] = fields[1]
+		if fields[0] == gitlinkMode {
+			files[i].Submodule = true
+		}
+	}
+
+	for i := range files {
+		if files[i].Submodule {
+			// The submodule commits are not in this repository.
+			continue
+		}
+		binary, err := r.isBinaryConflict(files[i])
+		if err != nil {
+			return nil, err
+		}
+		files[i].Binary = binary
+	}
+	return files, nil
+}
+
+func (r *Repo) isBinaryConflict(file ConflictedFile) (bool, error) {
+	// Respect the gitattributes (e.g., "*.png binary" or "*.bin -diff").
+	attr, err := r.Git("check-attr", "diff", "--", file.Path)
+	if err != nil {
+		return false, err
+	}
+	if strings.HasSuffix(attr, ": diff: unset") {
+		return true, nil
+	}
+
+	var revisions []string
+	for _, blob := range file.blobs {
+		revisions = append(revisions, blob)
+	}
+	items, err := r.GetRefs(&GetRefs{Revisions: revisions})
+	if err != nil {
+		return false, err
+	}
+	for _, item := range items {
+		contents := item.Contents
+		if len(contents) > binaryDetectionSize {
+			contents = contents[:binaryDetectionSize]
+		}
+		if bytes.IndexByte(contents, 0) >= 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ResolveConflict resolves the conflict of the file by taking the version of the given side
+// and staging the result. If the file is deleted on that side, the file is removed.
+func (r *Repo) ResolveConflict(file ConflictedFile, side ConflictSide) error {
+	if _, ok := file.blobs[side]; !ok {
+		if _, err := r.Git("rm", "--quiet", "--", file.Path); err != nil {
+			return errors.WrapIff(err, "failed to resolve the conflict of %q", file.Path)
+		}
+		return nil
+	}
+	flag := "--ours"
+	if side == ConflictSideTheirs {
+		flag = "--theirs"
+	}
+	if _, err := r.Git("checkout", flag, "--", file.Path); err != nil {
+		return errors.WrapIff(err, "failed to resolve the conflict of %q", file.Path)
+	}
+	if _, err := r.Git("add", "--", file.Path); err != nil {
+		return errors.WrapIff(err, "failed to resolve the conflict of %q", file.Path)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package git_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoConflictedFiles(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+
+	commitFiles := func(text, image string) plumbing.Hash {
+		repo.CreateFile(t, "text.txt", text)
+		repo.CreateFile(t, "image.bin", image)
+		repo.Git(t, "add", "text.txt", "image.bin")
+		repo.Git(t, "commit", "-m", "Write files")
+		return repo.GetCommitAtRef(t, plumbing.HEAD)
+	}
+	base := commitFiles("base\n", "base\x00")
+	ours := commitFiles("ours\n", "ours\x00")
+	repo.CheckoutCommit(t, base)
+	theirs := commitFiles("theirs\n", "theirs\x00")
+
+	repo.CheckoutCommit(t, ours)
+	avRepo := repo.AsAvGitRepo()
+	err := avRepo.CherryPick(git.CherryPick{Commits: []string{theirs.String()}})
+	require.Error(t, err)
+
+	files, err := avRepo.ConflictedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "image.bin", files[0].Path)
+	assert.True(t, files[0].Binary)
+	assert.Equal(t, "text.txt", files[1].Path)
+	assert.False(t, files[1].Binary)
+
+	require.NoError(t, avRepo.ResolveConflict(files[0], git.ConflictSideTheirs))
+	contents, err := os.ReadFile(filepath.Join(repo.RepoDir, "image.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, "theirs\x00", string(contents))
+
+	files, err = avRepo.ConflictedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "text.txt", files[0].Path)
+}
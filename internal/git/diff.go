@@ -1,6 +1,9 @@
 package git
 
 import (
+	"strconv"
+	"strings"
+
 	"emperror.dev/errors"
 )
 
@@ -60,3 +63,46 @@ func (r *Repo) Diff(d *DiffOpts) (*Diff, error) {
 	}
 	return &Diff{Empty: true, Contents: string(output.Stdout)}, nil
 }
+
+type DiffStat struct {
+	Path      string
+	Additions int
+	Deletions int
+	// If true, the file is a binary file and Additions and Deletions are zero.
+	Binary bool
+}
+
+// DiffStats returns the per-file statistics of the diff between the two commits (as reported
+// by `git diff --numstat`).
+func (r *Repo) DiffStats(from, to string) ([]DiffStat, error) {
+	output, err := r.Run(&RunOpts{
+		Args:      []string{"diff", "--numstat", "-z", "--no-renames", from, to, "--"},
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to compute the diff stats of %s..%s", from, to)
+	}
+	var ret []DiffStat
+	for _, entry := range strings.Split(string(output.Stdout), "\x00") {
+		if entry == "" {
+			continue
+		}
+		fields := strings.SplitN(entry, "\t", 3)
+		if len(fields) != 3 {
+			return nil, errors.Errorf("unexpected git diff --numstat output: %q", entry)
+		}
+		stat := DiffStat{Path: fields[2]}
+		if fields[0] == "-" && fields[1] == "-" {
+			stat.Binary = true
+		} else {
+			if stat.Additions, err = strconv.Atoi(fields[0]); err != nil {
+				return nil, errors.Errorf("unexpected git diff --numstat output: %q", entry)
+			}
+			if stat.Deletions, err = strconv.Atoi(fields[1]); err != nil {
+				return nil, errors.Errorf("unexpected git diff --numstat output: %q", entry)
+			}
+		}
+		ret = append(ret, stat)
+	}
+	return ret, nil
+}
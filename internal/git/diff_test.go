@@ -27,3 +27,19 @@ func TestRepoDiffAmbiguousPathName(t *testing.T) {
 		"diff between branches with different trees should return non-empty",
 	)
 }
+
+func TestRepoDiffStats(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+
+	repo.CreateRef(t, plumbing.NewBranchReferenceName("foo"))
+	repo.CheckoutBranch(t, plumbing.NewBranchReferenceName("foo"))
+
+	repo.CommitFile(t, "foo", "foo\nbar\n")
+	repo.CommitFile(t, "README.md", "# Hello\n")
+	stats, err := repo.AsAvGitRepo().DiffStats("main", "foo")
+	require.NoError(t, err)
+	require.Equal(t, []git.DiffStat{
+		{Path: "README.md", Additions: 1, Deletions: 1},
+		{Path: "foo", Additions: 2, Deletions: 0},
+	}, stats)
+}
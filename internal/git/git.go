@@ -8,11 +8,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/utils/errutils"
 	"github.com/go-git/go-git/v5"
 	"github.com/sirupsen/logrus"
 	giturls "github.com/whilp/git-urls"
@@ -27,21 +31,43 @@ type Repo struct {
 	gitDir  string
 	gitRepo *git.Repository
 	log     logrus.FieldLogger
+
+	// The per-worktree git directory. This is lazily populated by WorktreeGitDir.
+	worktreeGitDir string
+
+	// Serializes the config writes. Git locks the config file while writing it and fails
+	// instead of waiting for the lock, so the branches submitted concurrently can't set their
+	// configs at the same time.
+	configMu sync.Mutex
+	// The remotes read from the Git config by GetRemoteName and GetPushRemoteName. Guarded by
+	// configMu.
+	remoteName  string
+	pushDefault *string
+
+	// The cached refs (see Refs). Guarded by refsMu.
+	refs   *RefSnapshot
+	refsMu sync.Mutex
+
+	// The environment variables of the non-interactive mode (see nonInteractiveEnv).
+	nonInteractiveEnvOnce sync.Once
+	nonInteractiveEnvVars []string
 }
 
 func OpenRepo(repoDir string, gitDir string) (*Repo, error) {
 	repo, err := git.PlainOpenWithOptions(repoDir, &git.PlainOpenOptions{
-		DetectDotGit:          true,
+		// For a bare repository, the repository directory is the git directory itself. go-git
+		// only opens it as a bare repository if it doesn't look for a .git directory.
+		DetectDotGit:          repoDir != gitDir,
 		EnableDotGitCommonDir: true,
 	})
 	if err != nil {
 		return nil, errors.Errorf("failed to open git repo: %v", err)
 	}
 	r := &Repo{
-		repoDir,
-		gitDir,
-		repo,
-		logrus.WithFields(logrus.Fields{"repo": filepath.Base(repoDir)}),
+		repoDir: repoDir,
+		gitDir:  gitDir,
+		gitRepo: repo,
+		log:     logrus.WithFields(logrus.Fields{"repo": filepath.Base(repoDir)}),
 	}
 	return r, nil
 }
@@ -50,10 +76,36 @@ func (r *Repo) Dir() string {
 	return r.repoDir
 }
 
+// GitDir returns the common git directory of the repository ($GIT_COMMON_DIR).
+//
+// This is shared by all the worktrees of the repository (and is the repository itself for a
+// bare repository). Refs, config, and the av metadata live here.
 func (r *Repo) GitDir() string {
 	return r.gitDir
 }
 
+// IsBare returns true if the repository is a bare repository opened outside of any worktree.
+func (r *Repo) IsBare() bool {
+	return r.repoDir == r.gitDir
+}
+
+// WorktreeGitDir returns the git directory of the current worktree ($GIT_DIR).
+//
+// For the main worktree, this is the same as GitDir. For a linked worktree (created by `git
+// worktree add`), this is $GIT_COMMON_DIR/worktrees/<name>. Per-worktree state such as HEAD,
+// REBASE_HEAD, and CHERRY_PICK_HEAD lives here.
+func (r *Repo) WorktreeGitDir() string {
+	if r.worktreeGitDir == "" {
+		dir, err := r.Git("rev-parse", "--absolute-git-dir")
+		if err != nil {
+			r.log.WithError(err).Debug("failed to determine the worktree git dir")
+			return r.gitDir
+		}
+		r.worktreeGitDir = dir
+	}
+	return r.worktreeGitDir
+}
+
 func (r *Repo) AvDir() string {
 	return filepath.Join(r.GitDir(), "av")
 }
@@ -71,18 +123,22 @@ func (r *Repo) AvTmpDir() string {
 }
 
 func (r *Repo) DefaultBranch() (string, error) {
-	ref, err := r.Git("symbolic-ref", "refs/remotes/origin/HEAD")
+	remote := r.GetRemoteName()
+	headRef := RemoteTrackingRef(remote, "HEAD")
+	ref, err := r.Git("symbolic-ref", headRef)
 	if err != nil {
 		logrus.WithError(err).Debug("failed to determine remote HEAD")
 		// this communicates with the remote, so we probably don't want to run
 		// it by default, but we helpfully suggest it to the user. :shrug:
-		logrus.Warn(
-			"Failed to determine repository default branch. " +
-				"Ensure you have a remote named origin and try running `git remote set-head --auto origin` to fix this.",
+		logrus.Warnf(
+			"Failed to determine repository default branch. "+
+				"Ensure you have a remote named %[1]s (see the remote config) and try running "+
+				"`git remote set-head --auto %[1]s` to fix this.",
+			remote,
 		)
 		return "", errors.New("failed to determine remote HEAD")
 	}
-	return strings.TrimPrefix(ref, "refs/remotes/origin/"), nil
+	return strings.TrimPrefix(ref, RemoteTrackingRef(remote, "")), nil
 }
 
 func (r *Repo) IsTrunkBranch(name string) (bool, error) {
@@ -122,14 +178,158 @@ func (r *Repo) GetRemoteName() string {
 	if config.Av.Remote != "" {
 		return config.Av.Remote
 	}
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+	if r.remoteName == "" {
+		r.remoteName = DEFAULT_REMOTE_NAME
+		if name, err := r.Git("config", "--get", "av.remote"); err == nil && name != "" {
+			r.remoteName = name
+		}
+	}
+	return r.remoteName
+}
 
-	return DEFAULT_REMOTE_NAME
+// SetRemoteName sets the remote that av fetches from and opens the pull requests against for
+// this repository (Git's av.remote, which the remote config overrides).
+func (r *Repo) SetRemoteName(remote string) error {
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+	if _, err := r.Git("config", "av.remote", remote); err != nil {
+		return errors.WrapIf(err, "failed to set av.remote")
+	}
+	r.remoteName = remote
+	return nil
+}
+
+// RemoteTrackingRef returns the remote-tracking ref of the branch on the remote (e.g.,
+// refs/remotes/origin/main). The remote-tracking refs are built only by this, so that no
+// command assumes the remote to be origin.
+func RemoteTrackingRef(remote, branch string) string {
+	return "refs/remotes/" + remote + "/" + branch
+}
+
+// RemoteBranchRef returns the remote-tracking ref of the branch on the remote that av fetches
+// from (see GetRemoteName).
+func (r *Repo) RemoteBranchRef(branch string) string {
+	return RemoteTrackingRef(r.GetRemoteName(), branch)
+}
+
+// PushRemoteBranchRef returns the remote-tracking ref of the branch on the remote that the
+// branch is pushed to (see GetBranchPushRemoteName).
+func (r *Repo) PushRemoteBranchRef(branch string) string {
+	return RemoteTrackingRef(r.GetBranchPushRemoteName(branch), branch)
+}
+
+// GetPushRemoteName returns the name of the remote to push the branches to. This is different
+// from GetRemoteName in the fork-based workflow, where the branches are pushed to a fork and
+// the pull requests are opened against the upstream repository.
+func (r *Repo) GetPushRemoteName() string {
+	if config.Av.PushRemote != "" {
+		return config.Av.PushRemote
+	}
+	if pushDefault := r.getPushDefault(); pushDefault != "" {
+		return pushDefault
+	}
+	return r.GetRemoteName()
+}
+
+// getPushDefault returns Git's remote.pushDefault. It's read once and cached since it's looked
+// up for every branch.
+func (r *Repo) getPushDefault() string {
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+	if r.pushDefault == nil {
+		pushDefault, _ := r.Git("config", "--get", "remote.pushDefault")
+		r.pushDefault = &pushDefault
+	}
+	return *r.pushDefault
+}
+
+// SetPushDefault sets Git's remote.pushDefault, the remote to push the branches to (see
+// GetPushRemoteName).
+func (r *Repo) SetPushDefault(remote string) error {
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+	if _, err := r.Git("config", "remote.pushDefault", remote); err != nil {
+		return errors.WrapIf(err, "failed to set remote.pushDefault")
+	}
+	r.pushDefault = &remote
+	return nil
+}
+
+// GetBranchPushRemoteName returns the name of the remote to push the given branch to. Git's
+// branch.<name>.pushRemote takes precedence over GetPushRemoteName, as it does for `git push`.
+func (r *Repo) GetBranchPushRemoteName(branch string) string {
+	if pushRemote, err := r.Git("config", "--get", "branch."+branch+".pushRemote"); err == nil &&
+		pushRemote != "" {
+		return pushRemote
+	}
+	return r.GetPushRemoteName()
+}
+
+// IsForkWorkflow returns true if the branches are pushed to a different remote than the one
+// that the pull requests are opened against.
+func (r *Repo) IsForkWorkflow() bool {
+	return r.GetPushRemoteName() != r.GetRemoteName()
+}
+
+// command returns the git command with the given arguments. The command runs in the repository
+// directory and inherits the environment, so the repository config (e.g., core.hooksPath,
+// rerere, and the merge drivers) applies as if the user ran it. The extra configs in the av
+// config (gitConfig) are passed with -c.
+func (r *Repo) command(args ...string) *exec.Cmd {
+	cmd := baseCommand(r.repoDir, args...)
+	if config.NonInteractive {
+		r.nonInteractiveEnvOnce.Do(func() {
+			r.nonInteractiveEnvVars = nonInteractiveEnv(r.repoDir)
+		})
+		cmd.Env = append(os.Environ(), r.nonInteractiveEnvVars...)
+	}
+	r.invalidateRefs(args)
+	return cmd
+}
+
+// Command returns the git command with the given arguments that runs in the directory (the
+// current directory if empty), with the same configs and environment as the commands of Repo.
+// Use it for the commands that don't run in an opened repository (e.g., git init).
+func Command(dir string, args ...string) *exec.Cmd {
+	cmd := baseCommand(dir, args...)
+	if config.NonInteractive {
+		cmd.Env = append(os.Environ(), nonInteractiveEnv(dir)...)
+	}
+	return cmd
+}
+
+func baseCommand(dir string, args ...string) *exec.Cmd {
+	var cargs []string
+	if runtime.GOOS == "windows" {
+		// Git for Windows fails on the paths longer than MAX_PATH (e.g., in a deeply nested
+		// worktree) unless core.longpaths is set. The gitConfig config can still override it.
+		cargs = append(cargs, "-c", "core.longpaths=true")
+	}
+	for _, c := range config.Av.GitConfig {
+		cargs = append(cargs, "-c", c)
+	}
+	cmd := exec.Command("git", append(cargs, args...)...)
+	cmd.Dir = dir
+	return cmd
+}
+
+// nonInteractiveEnv returns the environment variables that keep Git from asking for the
+// credentials or opening an editor in the non-interactive mode (see config.NonInteractive). Git
+// fails if it needs the editor, unless the editor is ":" (use the text as it is).
+func nonInteractiveEnv(dir string) []string {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	editor, err := baseCommand(dir, "var", "GIT_EDITOR").Output()
+	if err != nil || strings.TrimSpace(string(editor)) != ":" {
+		env = append(env, "GIT_EDITOR=false")
+	}
+	return env
 }
 
 func (r *Repo) Git(args ...string) (string, error) {
 	startTime := time.Now()
-	cmd := exec.Command("git", args...)
-	cmd.Dir = r.repoDir
+	cmd := r.command(args...)
 	out, err := cmd.Output()
 	log := r.log.WithField("duration", time.Since(startTime))
 	if err != nil {
@@ -139,7 +339,7 @@ func (r *Repo) Git(args ...string) (string, error) {
 			stderr = string(exitError.Stderr)
 		}
 		log.Debugf("git %s failed: %s: %s", args, err, stderr)
-		return strings.TrimSpace(string(out)), errors.Wrapf(err, "git %s", args[0])
+		return strings.TrimSpace(string(out)), classifyError(errors.Wrapf(err, "git %s", args[0]), stderr)
 	}
 
 	// trim trailing newline
@@ -175,13 +375,24 @@ func (o Output) Lines() []string {
 	return strings.Split(s, "\n")
 }
 
+// classifyError marks the error of a git command that needed the credentials that cannot be
+// asked in the non-interactive mode (GIT_TERMINAL_PROMPT=0) as an interaction-required failure,
+// so that the command fails with its exit code instead of a generic one.
+func classifyError(err error, stderr string) error {
+	if config.NonInteractive && strings.Contains(stderr, "terminal prompts disabled") {
+		return errutils.WithFailureClass(err, errutils.FailureInteractionRequired)
+	}
+	return err
+}
+
 func (r *Repo) Run(opts *RunOpts) (*Output, error) {
-	cmd := exec.Command("git", opts.Args...)
-	cmd.Dir = r.repoDir
+	cmd := r.command(opts.Args...)
 	r.log.Debugf("git %s", opts.Args)
 	var stdout, stderr bytes.Buffer
 	if opts.Interactive {
-		cmd.Stdin = os.Stdin
+		if !config.NonInteractive {
+			cmd.Stdin = os.Stdin
+		}
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 	} else {
@@ -191,7 +402,7 @@ func (r *Repo) Run(opts *RunOpts) (*Output, error) {
 	if opts.Stdin != nil {
 		cmd.Stdin = opts.Stdin
 	}
-	cmd.Env = append(os.Environ(), opts.Env...)
+	cmd.Env = append(cmd.Environ(), opts.Env...)
 	err := cmd.Run()
 	var exitError *exec.ExitError
 	if err != nil && !errors.As(err, &exitError) {
@@ -202,7 +413,10 @@ func (r *Repo) Run(opts *RunOpts) (*Output, error) {
 		// a Stderr pipe, which is not the case here. Just populate it ourselves
 		// to make it easier for callers to access.
 		exitError.Stderr = stderr.Bytes()
-		return nil, errors.WrapIff(err, "git %s (%s)", opts.Args, stderr.String())
+		return nil, classifyError(
+			errors.WrapIff(err, "git %s (%s)", opts.Args, stderr.String()),
+			stderr.String(),
+		)
 	}
 	return &Output{
 		ExitCode:  cmd.ProcessState.ExitCode(),
@@ -212,19 +426,33 @@ func (r *Repo) Run(opts *RunOpts) (*Output, error) {
 	}, nil
 }
 
+// ErrDetachedHEAD is returned by CurrentBranchName when HEAD is not on a branch (e.g., during
+// a rebase or a bisect, or after checking out a commit).
+var ErrDetachedHEAD = errors.Sentinel(
+	"failed to determine current branch (are you in detached HEAD or is a rebase in progress?)",
+)
+
 // CurrentBranchName returns the name of the current branch.
 // The name is return in "short" format -- i.e., without the "refs/heads/" prefix.
 // IMPORTANT: This function will return an error if the repository is currently
 // in a detached-head state (e.g., during a rebase conflict).
 func (r *Repo) CurrentBranchName() (string, error) {
-	branch, err := r.Git("symbolic-ref", "--short", "HEAD")
+	out, err := r.Run(&RunOpts{
+		Args: []string{"symbolic-ref", "-q", "--short", "HEAD"},
+	})
 	if err != nil {
-		return "", errors.Wrap(
-			err,
-			"failed to determine current branch (are you in detached HEAD or is a rebase in progress?)",
-		)
+		return "", err
+	}
+	switch out.ExitCode {
+	case 0:
+		return strings.TrimSpace(string(out.Stdout)), nil
+	case 1:
+		return "", errors.WithStack(ErrDetachedHEAD)
 	}
-	return branch, nil
+	return "", errors.Errorf(
+		"failed to determine current branch: git symbolic-ref HEAD failed: %s",
+		strings.TrimSpace(string(out.Stderr)),
+	)
 }
 
 func (r *Repo) DoesBranchExist(branch string) (bool, error) {
@@ -232,7 +460,7 @@ func (r *Repo) DoesBranchExist(branch string) (bool, error) {
 }
 
 func (r *Repo) DoesRemoteBranchExist(branch string) (bool, error) {
-	return r.DoesRefExist(fmt.Sprintf("refs/remotes/origin/%s", branch))
+	return r.DoesRefExist(r.RemoteBranchRef(branch))
 }
 
 func (r *Repo) DoesRefExist(ref string) (bool, error) {
@@ -289,6 +517,11 @@ func (r *Repo) CheckoutBranch(opts *CheckoutBranch) (string, error) {
 			Debug("failed to get current branch name, repo is probably in detached HEAD")
 		previousBranchName = ""
 	}
+	if !opts.NewBranch {
+		if err := r.CheckNotInOtherWorktree(opts.Name); err != nil {
+			return "", err
+		}
+	}
 
 	args := []string{"checkout"}
 	if opts.NewBranch {
@@ -358,6 +591,43 @@ func (r *Repo) MergeBase(committishes ...string) (string, error) {
 	return strings.TrimSpace(str), nil
 }
 
+// IsAncestor returns true if the ancestor commit is reachable from the descendant commit
+// (including when they are the same commit).
+func (r *Repo) IsAncestor(ancestor, descendant string) (bool, error) {
+	out, err := r.Run(&RunOpts{
+		Args: []string{"merge-base", "--is-ancestor", ancestor, descendant},
+	})
+	if err != nil {
+		return false, err
+	}
+	switch out.ExitCode {
+	case 0:
+		return true, nil
+	case 1:
+		return false, nil
+	}
+	return false, errors.Errorf(
+		"git merge-base --is-ancestor %s %s failed: %s",
+		ancestor, descendant, strings.TrimSpace(string(out.Stderr)),
+	)
+}
+
+// ReflogContains returns true if the commit was the tip of the ref at some point in its reflog
+// (e.g., a commit that was on the branch before it was amended or rebased).
+func (r *Repo) ReflogContains(ref, commit string) (bool, error) {
+	out, err := r.Run(&RunOpts{
+		Args: []string{"reflog", "show", "--format=%H", ref, "--"},
+	})
+	if err != nil {
+		return false, err
+	}
+	if out.ExitCode != 0 {
+		// The ref has no reflog.
+		return false, nil
+	}
+	return slices.Contains(out.Lines(), commit), nil
+}
+
 type BranchAndCommit struct {
 	Commit string
 	Branch string
@@ -417,14 +687,70 @@ type Origin struct {
 	// The URL slug that corresponds to repository.
 	// For example, github.com/my-org/my-repo becomes my-org/my-repo.
 	RepoSlug string
+	// The forge that hosts the repository, determined from the host of the URL (see
+	// config.ForgeForHost). Empty if the host is unknown.
+	Forge string
+}
+
+// Owner returns the owner part of the repository slug (e.g., my-org for my-org/my-repo).
+func (o *Origin) Owner() string {
+	if i := strings.LastIndex(o.RepoSlug, "/"); i >= 0 {
+		return o.RepoSlug[:i]
+	}
+	return ""
 }
 
+// Origin returns the URL of the canonical repository that the pull requests are opened
+// against. This is the origin remote unless mirror.canonicalURL is configured.
 func (r *Repo) Origin() (*Origin, error) {
+	if config.Av.Mirror.CanonicalURL != "" {
+		return parseOrigin("mirror.canonicalURL", config.Av.Mirror.CanonicalURL)
+	}
+	return r.RemoteOrigin("origin")
+}
+
+// IsPushMirror returns true if the branch is pushed to a mirror of the canonical repository
+// (see Origin) that syncs it to the canonical repository under the same name. Unless the
+// mirror.push config says otherwise, the push URL is a mirror if it's on a different host
+// than the canonical repository. A push URL on the same host is the canonical repository
+// itself or a fork of it.
+func (r *Repo) IsPushMirror(branch string) (bool, error) {
+	switch config.Av.Mirror.Push {
+	case config.MirrorPushYes:
+		return true, nil
+	case config.MirrorPushNo:
+		return false, nil
+	}
+	pushOrigin, err := r.RemotePushOrigin(r.GetBranchPushRemoteName(branch))
+	if err != nil {
+		return false, err
+	}
+	origin, err := r.Origin()
+	if err != nil {
+		return false, err
+	}
+	return !strings.EqualFold(pushOrigin.URL.Hostname(), origin.URL.Hostname()), nil
+}
+
+// RemoteOrigin returns the URL of the given remote.
+func (r *Repo) RemoteOrigin(remote string) (*Origin, error) {
 	// Note: `git remote get-url` gets the "real" URL of the remote (taking
 	// `insteadOf` from git config into account) whereas `git config --get ...`
 	// does *not*. Not sure if it matters here.
+	return r.remoteOrigin("remote", "get-url", remote)
+}
+
+// RemotePushOrigin returns the URL that the branches are pushed to for the given remote. This
+// is different from RemoteOrigin if the remote has a pushurl or a `pushInsteadOf` rewrite
+// applies (e.g., fetching from the upstream repository and pushing to a fork).
+func (r *Repo) RemotePushOrigin(remote string) (*Origin, error) {
+	return r.remoteOrigin("remote", "get-url", "--push", remote)
+}
+
+func (r *Repo) remoteOrigin(args ...string) (*Origin, error) {
+	remote := args[len(args)-1]
 	output, err := r.Run(&RunOpts{
-		Args: []string{"remote", "get-url", "origin"},
+		Args: args,
 	})
 	if err != nil {
 		return nil, err
@@ -437,12 +763,15 @@ func (r *Repo) Origin() (*Origin, error) {
 	}
 	origin := strings.TrimSpace(string(output.Stdout))
 	if origin == "" {
-		return nil, errors.New("origin URL is empty")
+		return nil, errors.Errorf("%s URL is empty", remote)
 	}
+	return parseOrigin(remote, origin)
+}
 
+func parseOrigin(name string, origin string) (*Origin, error) {
 	u, err := giturls.Parse(origin)
 	if err != nil {
-		return nil, errors.WrapIff(err, "failed to parse origin url %q", origin)
+		return nil, errors.WrapIff(err, "failed to parse %s url %q", name, origin)
 	}
 
 	repoSlug := strings.TrimSuffix(u.Path, ".git")
@@ -450,5 +779,6 @@ func (r *Repo) Origin() (*Origin, error) {
 	return &Origin{
 		URL:      u,
 		RepoSlug: repoSlug,
+		Forge:    config.ForgeForHost(u.Hostname()),
 	}, nil
 }
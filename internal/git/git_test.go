@@ -1,6 +1,9 @@
 package git_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/aviator-co/av/internal/config"
@@ -21,6 +24,25 @@ func TestOrigin(t *testing.T) {
 	origin, err = repo.AsAvGitRepo().Origin()
 	require.NoError(t, err)
 	require.Equal(t, "aviator-co/av", origin.RepoSlug)
+	require.Equal(t, config.ForgeGitHub, origin.Forge)
+}
+
+func TestOriginForge(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	config.Av.GitHub.BaseURL = "https://github.mycompany.com/"
+	defer func() { config.Av.GitHub.BaseURL = "" }()
+
+	for url, forge := range map[string]string{
+		"git@github.mycompany.com:my-org/my-repo.git": config.ForgeGitHub,
+		"https://github.mycompany.com/my-org/my-repo": config.ForgeGitHub,
+		"https://gitlab.com/my-group/my-project.git":  config.ForgeGitLab,
+		"git@git.example.com:my-org/my-repo.git":      "",
+	} {
+		repo.Git(t, "remote", "set-url", "origin", url)
+		origin, err := repo.AsAvGitRepo().Origin()
+		require.NoError(t, err)
+		require.Equal(t, forge, origin.Forge, url)
+	}
 }
 
 func TestTrunkBranches(t *testing.T) {
@@ -45,3 +67,155 @@ func TestGetRemoteName(t *testing.T) {
 	require.Equal(t, repo.AsAvGitRepo().GetRemoteName(), "new-remote")
 
 }
+
+func TestSetRemoteName(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	config.Av.Remote = ""
+	remoteDir := strings.TrimSpace(repo.Git(t, "remote", "get-url", "origin"))
+	repo.Git(t, "remote", "add", "upstream", remoteDir)
+	repo.Git(t, "fetch", "upstream")
+	repo.Git(t, "remote", "set-head", "upstream", "main")
+
+	avRepo := repo.AsAvGitRepo()
+	require.NoError(t, avRepo.SetRemoteName("upstream"))
+	require.Equal(t, "upstream", avRepo.GetRemoteName())
+	require.Equal(t, "upstream", repo.AsAvGitRepo().GetRemoteName())
+	require.Equal(t, "refs/remotes/upstream/main", avRepo.RemoteBranchRef("main"))
+	require.Equal(t, "refs/remotes/upstream/one", git.RemoteTrackingRef("upstream", "one"))
+
+	defaultBranch, err := avRepo.DefaultBranch()
+	require.NoError(t, err)
+	require.Equal(t, "main", defaultBranch)
+	exists, err := avRepo.DoesRemoteBranchExist("main")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// The remote config overrides av.remote.
+	config.Av.Remote = "origin"
+	defer func() { config.Av.Remote = "" }()
+	require.Equal(t, "refs/remotes/origin/main", avRepo.RemoteBranchRef("main"))
+}
+
+func TestGetPushRemoteName(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	config.Av.Remote = ""
+	require.Equal(t, git.DEFAULT_REMOTE_NAME, repo.AsAvGitRepo().GetPushRemoteName())
+	require.False(t, repo.AsAvGitRepo().IsForkWorkflow())
+
+	repo.Git(t, "remote", "add", "fork", "git@github.com:alice/av.git")
+	repo.Git(t, "config", "remote.pushDefault", "fork")
+	require.Equal(t, "fork", repo.AsAvGitRepo().GetPushRemoteName())
+	require.True(t, repo.AsAvGitRepo().IsForkWorkflow())
+
+	fork, err := repo.AsAvGitRepo().RemoteOrigin("fork")
+	require.NoError(t, err)
+	require.Equal(t, "alice", fork.Owner())
+
+	config.Av.PushRemote = "other"
+	defer func() { config.Av.PushRemote = "" }()
+	require.Equal(t, "other", repo.AsAvGitRepo().GetPushRemoteName())
+}
+
+func TestGetBranchPushRemoteName(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	config.Av.Remote = ""
+	repo.Git(t, "remote", "add", "fork", "git@github.com:alice/av.git")
+	require.Equal(t, git.DEFAULT_REMOTE_NAME, repo.AsAvGitRepo().GetBranchPushRemoteName("one"))
+
+	require.NoError(t, repo.AsAvGitRepo().BranchSetPushRemote("one", "fork"))
+	require.Equal(t, "fork", repo.AsAvGitRepo().GetBranchPushRemoteName("one"))
+	require.Equal(t, git.DEFAULT_REMOTE_NAME, repo.AsAvGitRepo().GetBranchPushRemoteName("two"))
+}
+
+func TestRemotePushOrigin(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	repo.Git(t, "remote", "add", "upstream", "git@github.com:aviator-co/av.git")
+	repo.Git(t, "config", "url.git@github.com:alice/.pushInsteadOf", "git@github.com:aviator-co/")
+
+	origin, err := repo.AsAvGitRepo().RemoteOrigin("upstream")
+	require.NoError(t, err)
+	require.Equal(t, "aviator-co/av", origin.RepoSlug)
+	pushOrigin, err := repo.AsAvGitRepo().RemotePushOrigin("upstream")
+	require.NoError(t, err)
+	require.Equal(t, "alice/av", pushOrigin.RepoSlug)
+}
+
+func TestGitConfigOptions(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	config.Av.GitConfig = []string{"user.name=av-override", "rerere.enabled=true"}
+	defer func() { config.Av.GitConfig = nil }()
+
+	name, err := repo.AsAvGitRepo().Git("config", "user.name")
+	require.NoError(t, err)
+	require.Equal(t, "av-override", name)
+
+	out, err := repo.AsAvGitRepo().Run(&git.RunOpts{
+		Args:      []string{"config", "rerere.enabled"},
+		ExitError: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"true"}, out.Lines())
+}
+
+func TestIsPushMirror(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	config.Av.Remote = ""
+	repo.Git(t, "remote", "set-url", "origin", "https://github.com/aviator-co/av.git")
+	avRepo := repo.AsAvGitRepo()
+
+	mirror, err := avRepo.IsPushMirror("one")
+	require.NoError(t, err)
+	require.False(t, mirror)
+
+	// A push URL on the same host is a fork.
+	repo.Git(t, "remote", "set-url", "--push", "origin", "git@github.com:alice/av.git")
+	mirror, err = avRepo.IsPushMirror("one")
+	require.NoError(t, err)
+	require.False(t, mirror)
+
+	// A push URL on a different host is a mirror.
+	repo.Git(t, "remote", "set-url", "--push", "origin", "git@git.example.com:mirrors/av.git")
+	mirror, err = avRepo.IsPushMirror("one")
+	require.NoError(t, err)
+	require.True(t, mirror)
+
+	config.Av.Mirror.Push = config.MirrorPushNo
+	defer func() { config.Av.Mirror.Push = config.MirrorPushAuto }()
+	mirror, err = avRepo.IsPushMirror("one")
+	require.NoError(t, err)
+	require.False(t, mirror)
+}
+
+func TestOriginCanonicalURL(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	config.Av.Remote = ""
+	repo.Git(t, "remote", "set-url", "origin", "https://git.example.com/mirrors/av.git")
+
+	origin, err := repo.AsAvGitRepo().Origin()
+	require.NoError(t, err)
+	require.Equal(t, "mirrors/av", origin.RepoSlug)
+
+	config.Av.Mirror.CanonicalURL = "https://github.com/aviator-co/av"
+	defer func() { config.Av.Mirror.CanonicalURL = "" }()
+	origin, err = repo.AsAvGitRepo().Origin()
+	require.NoError(t, err)
+	require.Equal(t, "aviator-co/av", origin.RepoSlug)
+	require.Equal(t, config.ForgeGitHub, origin.Forge)
+}
+
+func TestCurrentBranchName(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	name, err := repo.AsAvGitRepo().CurrentBranchName()
+	require.NoError(t, err)
+	require.Equal(t, "main", name)
+
+	repo.Git(t, "checkout", "--detach")
+	_, err = repo.AsAvGitRepo().CurrentBranchName()
+	require.ErrorIs(t, err, git.ErrDetachedHEAD)
+
+	// Other failures of git are not reported as a detached HEAD.
+	require.NoError(t, os.WriteFile(filepath.Join(repo.GitDir, "HEAD"), []byte("broken\n"), 0o644))
+	_, err = repo.AsAvGitRepo().CurrentBranchName()
+	require.Error(t, err)
+	require.NotErrorIs(t, err, git.ErrDetachedHEAD)
+}
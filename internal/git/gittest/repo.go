@@ -114,6 +114,16 @@ type GitTestRepo struct {
 	GoGit   *git.Repository
 }
 
+// AppendAvConfig appends the given YAML to the av config of the repository
+// (.git/av/config.yml).
+func (r *GitTestRepo) AppendAvConfig(t *testing.T, yaml string) {
+	f, err := os.OpenFile(filepath.Join(r.GitDir, "av", "config.yml"), os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err, "failed to open .git/av/config.yml")
+	_, err = f.WriteString(yaml)
+	require.NoError(t, err, "failed to write .git/av/config.yml")
+	require.NoError(t, f.Close(), "failed to close .git/av/config.yml")
+}
+
 func (r *GitTestRepo) AsAvGitRepo() *avgit.Repo {
 	repo, _ := avgit.OpenRepo(r.RepoDir, r.GitDir)
 	return repo
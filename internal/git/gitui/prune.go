@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"emperror.dev/errors"
+	avconfig "github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/colors"
@@ -53,7 +54,8 @@ func NewPruneBranchModel(
 		initialBranch:  initialBranch,
 		spinner:        spinner.New(spinner.WithSpinner(spinner.Dot)),
 		help:           help.New(),
-		chooseNoPrune:  pruneFlag == "no",
+		// The merged branches are kept instead of asking in the non-interactive mode.
+		chooseNoPrune: pruneFlag == "no" || (pruneFlag == "ask" && avconfig.NonInteractive),
 	}
 }
 
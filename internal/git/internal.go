@@ -5,9 +5,9 @@ import (
 	"path/filepath"
 )
 
-// readGitFile reads a file from the .git directory.
+// readGitFile reads a file from the .git directory of the current worktree.
 func (r *Repo) readGitFile(name string) (string, error) {
-	data, err := os.ReadFile(filepath.Join(r.GitDir(), name))
+	data, err := os.ReadFile(filepath.Join(r.WorktreeGitDir(), name))
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,101 @@
+package git
+
+import (
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// MergeTreeResult is the result of MergeTree.
+type MergeTreeResult struct {
+	// The hash of the merged tree. If there are conflicts, the tree has the conflict markers.
+	Tree string
+	// The paths of the files that conflict. Empty if the merge is clean.
+	ConflictedFiles []string
+}
+
+// MergeTree applies the changes from base to theirs onto ours (like git cherry-pick) with
+// git merge-tree, and returns the merged tree. The arguments are tree-ish (commits or trees).
+// Neither the working tree, the index, nor any ref is changed.
+//
+// Git 2.38 or later is required.
+func (r *Repo) MergeTree(base, ours, theirs string) (*MergeTreeResult, error) {
+	if !r.gitVersionAtLeast(2, 38) {
+		return nil, errors.New("git merge-tree --write-tree requires Git 2.38 or later")
+	}
+	// git merge-tree --merge-base is not available before Git 2.40, so the trees are wrapped
+	// in commits whose only common ancestor is the base.
+	baseCommit, err := r.commitTree(base)
+	if err != nil {
+		return nil, err
+	}
+	oursCommit, err := r.commitTree(ours, baseCommit)
+	if err != nil {
+		return nil, err
+	}
+	theirsCommit, err := r.commitTree(theirs, baseCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := r.Run(&RunOpts{
+		Args: []string{
+			"merge-tree", "--write-tree", "--name-only", "--no-messages",
+			oursCommit, theirsCommit,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The exit code is 0 for a clean merge and 1 for conflicts. Otherwise, the merge failed.
+	if out.ExitCode != 0 && out.ExitCode != 1 {
+		return nil, errors.Errorf(
+			"git merge-tree failed: %s", strings.TrimSpace(string(out.Stderr)),
+		)
+	}
+	lines := out.Lines()
+	if len(lines) == 0 {
+		return nil, errors.New("git merge-tree returned no tree")
+	}
+	result := &MergeTreeResult{Tree: lines[0]}
+	for _, line := range lines[1:] {
+		if line == "" {
+			break
+		}
+		if len(result.ConflictedFiles) == 0 ||
+			result.ConflictedFiles[len(result.ConflictedFiles)-1] != line {
+			result.ConflictedFiles = append(result.ConflictedFiles, line)
+		}
+	}
+	return result, nil
+}
+
+// commitTree creates a commit of the tree of the tree-ish with the given parents. The commit is
+// not referenced by any ref.
+func (r *Repo) commitTree(treeish string, parents ...string) (string, error) {
+	return r.CommitTree(treeish, "av merge-tree", parents...)
+}
+
+// CommitTree creates a commit of the tree of the tree-ish with the message and the given
+// parents, authored by av. The commit is not referenced by any ref. This is for the temporary
+// commits (e.g., the results of MergeTree).
+func (r *Repo) CommitTree(treeish string, message string, parents ...string) (string, error) {
+	args := []string{"commit-tree", treeish + "^{tree}", "-m", message}
+	for _, parent := range parents {
+		args = append(args, "-p", parent)
+	}
+	out, err := r.Run(&RunOpts{
+		Args: args,
+		// The commits are temporary, so they don't need the user's identity (which may not be
+		// configured).
+		Env: []string{
+			"GIT_AUTHOR_NAME=av", "GIT_AUTHOR_EMAIL=av@localhost",
+			"GIT_COMMITTER_NAME=av", "GIT_COMMITTER_EMAIL=av@localhost",
+		},
+		ExitError: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out.Stdout)), nil
+}
@@ -0,0 +1,34 @@
+package git_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_MergeTree(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+
+	base := repo.CommitFile(t, "file", "a\nb\nc\n")
+	theirs := repo.CommitFile(t, "file", "a\nb\nc\nd\n")
+	repo.CheckoutCommit(t, base)
+	ours := repo.CommitFile(t, "other", "x\n")
+
+	result, err := repo.AsAvGitRepo().MergeTree(base.String(), ours.String(), theirs.String())
+	require.NoError(t, err)
+	require.Empty(t, result.ConflictedFiles)
+	content := repo.Git(t, "cat-file", "-p", result.Tree+":file")
+	require.Equal(t, "a\nb\nc\nd\n", content)
+	require.Equal(t, "x\n", repo.Git(t, "cat-file", "-p", result.Tree+":other"))
+
+	// The working tree and the refs are not changed.
+	require.Equal(t, ours.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "HEAD")))
+	require.Empty(t, repo.Git(t, "status", "--porcelain"))
+
+	conflicting := repo.CommitFile(t, "file", "a\nb\nc\ne\n")
+	result, err = repo.AsAvGitRepo().MergeTree(base.String(), conflicting.String(), theirs.String())
+	require.NoError(t, err)
+	require.Equal(t, []string{"file"}, result.ConflictedFiles)
+}
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// BranchNotesRef is the notes ref that stores the per-branch notes.
+const BranchNotesRef = "refs/notes/av"
+
+// The notes are attached to a blob derived from the branch name instead of the branch's
+// commits so that they survive rebases and amends.
+func branchNoteObjectContents(name string) []byte {
+	return []byte("av-branch " + name + "\n")
+}
+
+func branchNoteObject(name string) string {
+	return plumbing.ComputeHash(plumbing.BlobObject, branchNoteObjectContents(name)).String()
+}
+
+// BranchNotes returns the notes of the given branches. Branches without a note are omitted.
+func (r *Repo) BranchNotes(names []string) (map[string]string, error) {
+	output, err := r.Run(&RunOpts{
+		Args: []string{"notes", "--ref", BranchNotesRef, "list"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if output.ExitCode != 0 {
+		// The notes ref doesn't exist yet.
+		return map[string]string{}, nil
+	}
+	// Each line is "<note blob> <annotated object>".
+	noteBlobs := map[string]string{}
+	for _, line := range strings.Split(string(output.Stdout), "\n") {
+		if noteBlob, object, ok := strings.Cut(line, " "); ok {
+			noteBlobs[object] = noteBlob
+		}
+	}
+
+	var branches, revisions []string
+	for _, name := range names {
+		if noteBlob, ok := noteBlobs[branchNoteObject(name)]; ok {
+			branches = append(branches, name)
+			revisions = append(revisions, noteBlob)
+		}
+	}
+	ret := map[string]string{}
+	if len(revisions) == 0 {
+		return ret, nil
+	}
+	items, err := r.GetRefs(&GetRefs{Revisions: revisions})
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to read the branch notes")
+	}
+	for i, item := range items {
+		ret[branches[i]] = strings.TrimSpace(string(item.Contents))
+	}
+	return ret, nil
+}
+
+// SetBranchNote sets the note of the given branch. If note is empty, the note is removed.
+func (r *Repo) SetBranchNote(name string, note string) error {
+	if strings.TrimSpace(note) == "" {
+		if _, err := r.Git(
+			"notes", "--ref", BranchNotesRef, "remove", "--ignore-missing", branchNoteObject(name),
+		); err != nil {
+			return errors.WrapIff(err, "failed to remove the note of %q", name)
+		}
+		return nil
+	}
+
+	// The annotated object needs to exist in the repository.
+	if _, err := r.Run(&RunOpts{
+		Args:      []string{"hash-object", "-w", "--stdin"},
+		Stdin:     bytes.NewReader(branchNoteObjectContents(name)),
+		ExitError: true,
+	}); err != nil {
+		return errors.WrapIff(err, "failed to set the note of %q", name)
+	}
+	if _, err := r.Run(&RunOpts{
+		Args:      []string{"notes", "--ref", BranchNotesRef, "add", "--force", "--file", "-", branchNoteObject(name)},
+		Stdin:     strings.NewReader(note),
+		ExitError: true,
+	}); err != nil {
+		return errors.WrapIff(err, "failed to set the note of %q", name)
+	}
+	return nil
+}
+
+func (r *Repo) remoteBranchNotesRef() string {
+	return "refs/notes/remotes/" + r.GetRemoteName() + "/av"
+}
+
+// FetchBranchNotes fetches the branch notes from the remote and merges them into the local
+// notes. av sync runs this with the fetch of the branches.
+func (r *Repo) FetchBranchNotes() error {
+	remote := r.GetRemoteName()
+	remoteNotesRef := r.remoteBranchNotesRef()
+
+	remoteRefs, err := r.Git("ls-remote", remote, BranchNotesRef)
+	if err != nil {
+		return errors.WrapIff(err, "failed to list the notes on %q", remote)
+	}
+	if remoteRefs == "" {
+		return nil
+	}
+	if _, err := r.Git("fetch", remote, "+"+BranchNotesRef+":"+remoteNotesRef); err != nil {
+		return errors.WrapIff(err, "failed to fetch the notes from %q", remote)
+	}
+	if _, err := r.RevParse(&RevParse{Rev: BranchNotesRef}); err != nil {
+		if _, err := r.Git("update-ref", BranchNotesRef, remoteNotesRef); err != nil {
+			return err
+		}
+	} else if _, err := r.Git(
+		"notes", "--ref", BranchNotesRef, "merge", "--quiet", "--strategy", "union", remoteNotesRef,
+	); err != nil {
+		return errors.WrapIff(err, "failed to merge the notes from %q", remote)
+	}
+	return nil
+}
+
+// PushBranchNotes publishes the branch notes (see PublishBranchNotes) if they have changed
+// since they were last fetched or pushed. av sync and av pr run this after pushing the
+// branches.
+func (r *Repo) PushBranchNotes() error {
+	local, err := r.RevParse(&RevParse{Rev: BranchNotesRef})
+	if err != nil {
+		// No notes to push.
+		return nil
+	}
+	if remote, err := r.RevParse(&RevParse{Rev: r.remoteBranchNotesRef()}); err == nil && remote == local {
+		return nil
+	}
+	return r.PublishBranchNotes()
+}
+
+// PublishBranchNotes fetches the branch notes from the remote, merges them into the local
+// notes, and pushes the result back to the remote.
+func (r *Repo) PublishBranchNotes() error {
+	if err := r.FetchBranchNotes(); err != nil {
+		return err
+	}
+	if _, err := r.RevParse(&RevParse{Rev: BranchNotesRef}); err != nil {
+		// No notes to push.
+		return nil
+	}
+	remote := r.GetRemoteName()
+	if _, err := r.Git("push", remote, BranchNotesRef+":"+BranchNotesRef); err != nil {
+		return errors.WrapIff(err, "failed to push the notes to %q", remote)
+	}
+	if _, err := r.Git("update-ref", r.remoteBranchNotesRef(), BranchNotesRef); err != nil {
+		return err
+	}
+	return nil
+}
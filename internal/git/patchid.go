@@ -0,0 +1,39 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+)
+
+// PatchIDs returns the stable patch IDs (see git patch-id) of the non-merge commits listed by
+// git log with the given arguments, keyed by the commit. Two commits with the same changes
+// (e.g., a commit and its rebased copy) have the same patch ID. The commits without changes are
+// left out.
+func (r *Repo) PatchIDs(args ...string) (map[string]string, error) {
+	log, err := r.Run(&RunOpts{
+		Args: append([]string{
+			"log", "-p", "--no-merges", "--no-color", "--no-ext-diff", "--format=commit %H",
+		}, args...),
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out, err := r.Run(&RunOpts{
+		Args:      []string{"patch-id", "--stable"},
+		Stdin:     bytes.NewReader(log.Stdout),
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string]string{}
+	for _, line := range out.Lines() {
+		// Each line is "<patch-id> <commit>".
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			ret[fields[1]] = fields[0]
+		}
+	}
+	return ret, nil
+}
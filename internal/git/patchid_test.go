@@ -0,0 +1,31 @@
+package git_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_PatchIDs(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	base := repo.GetCommitAtRef(t, "HEAD")
+	repo.Git(t, "switch", "-c", "feature")
+	one := repo.CommitFile(t, "one", "one")
+	two := repo.CommitFile(t, "two", "two")
+	repo.Git(t, "switch", "main")
+	repo.CommitFile(t, "other", "other")
+	repo.Git(t, "cherry-pick", one.String())
+	picked := repo.GetCommitAtRef(t, "HEAD")
+
+	ids, err := repo.AsAvGitRepo().PatchIDs(base.String() + "..feature")
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+	assert.NotEqual(t, ids[one.String()], ids[two.String()])
+
+	mainIDs, err := repo.AsAvGitRepo().PatchIDs(base.String() + "..main")
+	require.NoError(t, err)
+	require.Len(t, mainIDs, 2)
+	assert.Equal(t, ids[one.String()], mainIDs[picked.String()])
+}
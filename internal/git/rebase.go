@@ -25,15 +25,38 @@ type RebaseOpts struct {
 	// If set, this is the branch that will be rebased; otherwise, the current
 	// branch is rebased.
 	Branch string
+	// Optional
+	// If set, git rerere is enabled (with rerere.autoUpdate) for the rebase, so that the
+	// conflict resolutions are recorded and the recorded resolutions are staged.
+	Rerere bool
+	// Optional
+	// If set, use `git rebase --signoff` to add a Signed-off-by trailer to the rebased
+	// commits. This rebases the commits even if the branch is up to date.
+	Signoff bool
+	// Optional
+	// If set, use `git rebase --gpg-sign` to sign the rebased commits with the signing key
+	// configured in Git (user.signingKey and gpg.format).
+	Sign bool
+	// Optional
+	// If set, use `git rebase --committer-date-is-author-date` so that the committer dates of
+	// the rebased commits are the author dates instead of the current time.
+	CommitterDateIsAuthorDate bool
 }
 
+// rerereArgs are the git options that enable git rerere for a command.
+var rerereArgs = []string{"-c", "rerere.enabled=true", "-c", "rerere.autoUpdate=true"}
+
 func (r *Repo) Rebase(opts RebaseOpts) (*Output, error) {
 	// TODO: probably move the parseRebaseOutput logic in sync to here
 
-	args := []string{"rebase"}
+	var args []string
+	if opts.Rerere {
+		args = append(args, rerereArgs...)
+	}
+	args = append(args, "rebase")
 	if opts.Continue {
 		return r.Run(&RunOpts{
-			Args: []string{"rebase", "--continue"},
+			Args: append(args, "--continue"),
 			// `git rebase --continue` will open an editor to allow the user
 			// to edit the commit message, which we don't want here. Instead, we
 			// specify `true` here (which is a command that does nothing and
@@ -42,13 +65,22 @@ func (r *Repo) Rebase(opts RebaseOpts) (*Output, error) {
 		})
 	} else if opts.Abort {
 		return r.Run(&RunOpts{
-			Args: []string{"rebase", "--abort"},
+			Args: append(args, "--abort"),
 		})
 	} else if opts.Skip {
 		return r.Run(&RunOpts{
-			Args: []string{"rebase", "--skip"},
+			Args: append(args, "--skip"),
 		})
 	}
+	if opts.Signoff {
+		args = append(args, "--signoff")
+	}
+	if opts.Sign {
+		args = append(args, "--gpg-sign")
+	}
+	if opts.CommitterDateIsAuthorDate {
+		args = append(args, "--committer-date-is-author-date")
+	}
 	if opts.Onto != "" {
 		args = append(args, "--onto", opts.Onto)
 	}
@@ -88,6 +120,12 @@ type RebaseResult struct {
 	ErrorHeadline string
 }
 
+// UsedRerere returns true if git rerere resolved some of the conflicts with the recorded
+// resolutions.
+func (r *RebaseResult) UsedRerere() bool {
+	return strings.Contains(r.Hint, "using previous resolution")
+}
+
 var carriageReturnRegex = regexp.MustCompile(`^.+\r`)
 var hintRegex = regexp.MustCompile(`(?m)^hint:.+$\n?`)
 var errorMatchRegex = regexp.MustCompile(`(?m)^error: (.+)$`)
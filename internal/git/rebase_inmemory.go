@@ -0,0 +1,114 @@
+package git
+
+import (
+	"strings"
+)
+
+// RebaseInMemory moves the commits in upstream..branch onto the given commit and updates the
+// branch, like git rebase --onto, but without touching the working tree or the index. Each
+// commit is applied onto the new base with git merge-tree and committed with git commit-tree,
+// so no files are written and no hooks are run for the branches that rebase cleanly.
+//
+// As with git rebase, the authors and the messages of the commits are preserved, the commits
+// that become empty are dropped, and the commits that started empty are kept.
+//
+// It returns false without changing anything if git merge-tree --write-tree is not available
+// (Git 2.38 or later is required), if the branch is checked out in a worktree, if the branch
+// has merge commits, or if any commit conflicts. It also returns false if the repository has a
+// post-rewrite hook, which git rebase would run. The caller should fall back to git rebase in
+// that case.
+func (r *Repo) RebaseInMemory(branch, upstream, onto string) (bool, error) {
+	if !r.gitVersionAtLeast(2, 38) {
+		return false, nil
+	}
+	if hook, err := r.hasHook("post-rewrite"); err != nil || hook {
+		return false, err
+	}
+	ref := "refs/heads/" + branch
+	worktree, err := r.Git("for-each-ref", "--format=%(worktreepath)", ref)
+	if err != nil {
+		return false, err
+	}
+	if worktree != "" {
+		return false, nil
+	}
+	oldHead, err := r.RevParse(&RevParse{Rev: ref})
+	if err != nil {
+		return false, err
+	}
+	base, err := r.RevParse(&RevParse{Rev: onto + "^{commit}"})
+	if err != nil {
+		return false, err
+	}
+	baseTree, err := r.RevParse(&RevParse{Rev: base + "^{tree}"})
+	if err != nil {
+		return false, err
+	}
+
+	// Each line is "<commit> <tree> <parents>...", from the oldest commit.
+	out, err := r.Run(&RunOpts{
+		Args:      []string{"log", "--reverse", "--format=%H %T %P", upstream + ".." + ref, "--"},
+		ExitError: true,
+	})
+	if err != nil {
+		return false, err
+	}
+	trees := map[string]string{}
+	for _, line := range out.Lines() {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			r.log.WithField("commit", line).Debug("cannot rebase merge or root commits in memory")
+			return false, nil
+		}
+		commit, tree, parent := fields[0], fields[1], fields[2]
+		trees[commit] = tree
+
+		parentTree, ok := trees[parent]
+		if !ok {
+			if parentTree, err = r.RevParse(&RevParse{Rev: parent + "^{tree}"}); err != nil {
+				return false, err
+			}
+		}
+		newTree := baseTree
+		if tree != parentTree {
+			merged, err := r.MergeTree(parent, base, commit)
+			if err != nil {
+				return false, err
+			}
+			if len(merged.ConflictedFiles) > 0 {
+				r.log.WithField("commit", commit).Debug("commit conflicts, falling back to git rebase")
+				return false, nil
+			}
+			if merged.Tree == baseTree {
+				// The changes are already in the new base.
+				continue
+			}
+			newTree = merged.Tree
+		}
+
+		obj, err := r.readCommitObject(commit)
+		if err != nil {
+			return false, err
+		}
+		// The committer is the current user as with git rebase.
+		newCommit, err := r.Run(&RunOpts{
+			Args:      []string{"commit-tree", newTree, "-p", base},
+			Env:       obj.signatureEnv("author"),
+			Stdin:     strings.NewReader(obj.message),
+			ExitError: true,
+		})
+		if err != nil {
+			return false, err
+		}
+		base = strings.TrimSpace(string(newCommit.Stdout))
+		baseTree = newTree
+	}
+
+	if base == oldHead {
+		return true, nil
+	}
+	if err := r.UpdateRef(&UpdateRef{Ref: ref, New: base, Old: oldHead}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
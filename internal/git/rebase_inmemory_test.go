@@ -0,0 +1,46 @@
+package git_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_RebaseInMemory(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	avRepo := repo.AsAvGitRepo()
+
+	base := repo.CommitFile(t, "file", "a\nb\nc\n")
+	repo.Git(t, "checkout", "-b", "feature")
+	repo.CommitFile(t, "file", "a\nb\nc\nd\n", gittest.WithMessage("Add d"))
+	repo.CommitFile(t, "other", "x\n", gittest.WithMessage("Add other"))
+	repo.Git(t, "checkout", "-b", "conflicting", base.String())
+	repo.CommitFile(t, "file", "y\na\nb\nc\n", gittest.WithMessage("Add y"))
+	repo.Git(t, "checkout", "main")
+	onto := repo.CommitFile(t, "file", "z\na\nb\nc\n", gittest.WithMessage("Add z"))
+
+	rebased, err := avRepo.RebaseInMemory("feature", base.String(), onto.String())
+	require.NoError(t, err)
+	require.True(t, rebased)
+	require.Equal(t, onto.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "feature~2")))
+	require.Equal(t, "z\na\nb\nc\nd\n", repo.Git(t, "show", "feature:file"))
+	require.Equal(t, "Add other", strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%s", "feature")))
+	// The working tree is not touched.
+	require.Equal(t, "main", strings.TrimSpace(repo.Git(t, "branch", "--show-current")))
+	require.Empty(t, repo.Git(t, "status", "--porcelain"))
+
+	// A conflicting branch is left as is for git rebase.
+	before := repo.Git(t, "rev-parse", "conflicting")
+	rebased, err = avRepo.RebaseInMemory("conflicting", base.String(), onto.String())
+	require.NoError(t, err)
+	require.False(t, rebased)
+	require.Equal(t, before, repo.Git(t, "rev-parse", "conflicting"))
+
+	// The checked-out branch is left for git rebase too.
+	repo.Git(t, "checkout", "feature")
+	rebased, err = avRepo.RebaseInMemory("feature", base.String(), onto.String())
+	require.NoError(t, err)
+	require.False(t, rebased)
+}
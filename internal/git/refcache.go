@@ -0,0 +1,180 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// readOnlyCommands are the git commands that don't update the refs. Running any other command
+// through the Repo drops the cached refs (see Refs).
+var readOnlyCommands = map[string]bool{
+	"cat-file":     true,
+	"diff":         true,
+	"for-each-ref": true,
+	"log":          true,
+	"ls-files":     true,
+	"ls-remote":    true,
+	"merge-base":   true,
+	"merge-tree":   true,
+	"rev-list":     true,
+	"rev-parse":    true,
+	"show":         true,
+	"show-ref":     true,
+	"status":       true,
+	"symbolic-ref": true,
+	"var":          true,
+}
+
+// SnapshotRef is a ref in a RefSnapshot.
+type SnapshotRef struct {
+	// The full name of the ref (e.g., refs/heads/main).
+	Name string
+	// The object ID that the ref points to.
+	OID string
+	// The tree of the commit that the ref points to (empty if it's not a commit).
+	Tree string
+}
+
+// RefSnapshot is the refs of the repository read with a single git for-each-ref. It's used
+// instead of running git for each branch on the paths that read many branches (e.g., av tree),
+// which is slow in the repositories with many refs.
+type RefSnapshot struct {
+	repo *Repo
+	refs map[string]SnapshotRef
+	// The results of BranchesContaining by commit.
+	contains map[string]map[string]bool
+}
+
+// Refs returns the snapshot of the refs of the repository. The snapshot is cached until a git
+// command that can update the refs is run through the Repo.
+func (r *Repo) Refs() (*RefSnapshot, error) {
+	r.refsMu.Lock()
+	defer r.refsMu.Unlock()
+	if r.refs != nil {
+		return r.refs, nil
+	}
+	out, err := r.Git(
+		"for-each-ref", "--format", "%(refname)%00%(objectname)%00%(tree)",
+		"refs/heads", "refs/remotes", "refs/tags",
+	)
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to list the refs")
+	}
+	snapshot := &RefSnapshot{
+		repo:     r,
+		refs:     make(map[string]SnapshotRef),
+		contains: make(map[string]map[string]bool),
+	}
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.Split(line, "\x00")
+		if len(parts) != 3 {
+			continue
+		}
+		snapshot.refs[parts[0]] = SnapshotRef{Name: parts[0], OID: parts[1], Tree: parts[2]}
+	}
+	r.refs = snapshot
+	return snapshot, nil
+}
+
+// invalidateRefs drops the cached refs unless the git command is known not to update refs.
+func (r *Repo) invalidateRefs(args []string) {
+	if len(args) > 0 && readOnlyCommands[args[0]] {
+		return
+	}
+	r.refsMu.Lock()
+	r.refs = nil
+	r.refsMu.Unlock()
+}
+
+// Ref looks up the ref by name like git rev-parse does: the name can be a full ref name, a
+// branch name, a tag name, or a remote-tracking branch name (e.g., origin/main).
+func (s *RefSnapshot) Ref(name string) (SnapshotRef, bool) {
+	for _, prefix := range []string{"", "refs/", "refs/tags/", "refs/heads/", "refs/remotes/"} {
+		if ref, ok := s.refs[prefix+name]; ok {
+			return ref, true
+		}
+	}
+	return SnapshotRef{}, false
+}
+
+// Branch returns the local branch.
+func (s *RefSnapshot) Branch(name string) (SnapshotRef, bool) {
+	ref, ok := s.refs["refs/heads/"+name]
+	return ref, ok
+}
+
+// RemoteBranch returns the remote-tracking branch of the remote.
+func (s *RefSnapshot) RemoteBranch(remote string, name string) (SnapshotRef, bool) {
+	ref, ok := s.refs[RemoteTrackingRef(remote, name)]
+	return ref, ok
+}
+
+// BranchesContaining returns the local branches that contain the commit (i.e., the commit is
+// an ancestor of the branch or the branch itself). All the branches are checked by a single
+// git for-each-ref and the result is cached in the snapshot.
+func (s *RefSnapshot) BranchesContaining(commit string) (map[string]bool, error) {
+	s.repo.refsMu.Lock()
+	branches, ok := s.contains[commit]
+	s.repo.refsMu.Unlock()
+	if ok {
+		return branches, nil
+	}
+	out, err := s.repo.Git(
+		"for-each-ref", "--format", "%(refname:lstrip=2)", "--contains", commit, "refs/heads",
+	)
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to list the branches containing %s", commit)
+	}
+	branches = make(map[string]bool)
+	for _, name := range strings.Split(out, "\n") {
+		if name != "" {
+			branches[name] = true
+		}
+	}
+	s.repo.refsMu.Lock()
+	s.contains[commit] = branches
+	s.repo.refsMu.Unlock()
+	return branches, nil
+}
+
+// ResolveRevisions resolves the revisions to the object IDs with a single
+// git cat-file --batch-check instead of running git rev-parse for each of them. The revisions
+// that don't exist are not included in the returned map.
+func (r *Repo) ResolveRevisions(revs []string) (map[string]string, error) {
+	ret := make(map[string]string, len(revs))
+	if len(revs) == 0 {
+		return ret, nil
+	}
+	var input bytes.Buffer
+	for _, rev := range revs {
+		if strings.ContainsAny(rev, "\n") {
+			return nil, errors.Errorf("invalid revision %q", rev)
+		}
+		input.WriteString(rev)
+		input.WriteString("\n")
+	}
+	out, err := r.Run(&RunOpts{
+		Args:      []string{"cat-file", "--batch-check=%(objectname)"},
+		Stdin:     &input,
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to resolve the revisions")
+	}
+	lines := out.Lines()
+	if len(lines) != len(revs) {
+		return nil, errors.Errorf(
+			"internal error: expected %d lines from cat-file, got %d", len(revs), len(lines),
+		)
+	}
+	for i, line := range lines {
+		// A revision that doesn't resolve is printed as "<rev> missing" (or "ambiguous").
+		if strings.HasSuffix(line, " missing") || strings.HasSuffix(line, " ambiguous") {
+			continue
+		}
+		ret[revs[i]] = line
+	}
+	return ret, nil
+}
@@ -0,0 +1,56 @@
+package git_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_Refs(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	avRepo := repo.AsAvGitRepo()
+	base := repo.GetCommitAtRef(t, "HEAD")
+	repo.Git(t, "checkout", "-b", "one")
+	one := repo.CommitFile(t, "one", "one")
+
+	refs, err := avRepo.Refs()
+	require.NoError(t, err)
+	ref, ok := refs.Ref("one")
+	require.True(t, ok)
+	assert.Equal(t, "refs/heads/one", ref.Name)
+	assert.Equal(t, one.String(), ref.OID)
+	assert.NotEmpty(t, ref.Tree)
+	_, ok = refs.RemoteBranch("origin", "main")
+	assert.True(t, ok)
+	_, ok = refs.Branch("two")
+	assert.False(t, ok)
+
+	containing, err := refs.BranchesContaining(base.String())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"main": true, "one": true}, containing)
+
+	// The snapshot is cached for the read-only commands, and dropped once the refs can change.
+	_, err = avRepo.Git("rev-parse", "HEAD")
+	require.NoError(t, err)
+	cached, err := avRepo.Refs()
+	require.NoError(t, err)
+	assert.Same(t, refs, cached)
+	_, err = avRepo.Git("branch", "two")
+	require.NoError(t, err)
+	refs, err = avRepo.Refs()
+	require.NoError(t, err)
+	assert.NotSame(t, cached, refs)
+	_, ok = refs.Branch("two")
+	assert.True(t, ok)
+}
+
+func TestRepo_ResolveRevisions(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	head := repo.GetCommitAtRef(t, "HEAD")
+
+	revs, err := repo.AsAvGitRepo().ResolveRevisions([]string{"main", "no-such-branch", "HEAD"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"main": head.String(), "HEAD": head.String()}, revs)
+}
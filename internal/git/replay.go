@@ -0,0 +1,235 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// ReplayCommit creates a copy of the given commit on top of the given base commit (like
+// git cherry-pick) without touching the working tree or the index, and returns the hash of the
+// new commit. The author, the committer, and the message of the commit are preserved, so that
+// replaying the same commit onto the same base always produces the same commit.
+//
+// Merge commits cannot be replayed. If the changes of the commit cannot be applied cleanly
+// onto the base, an error is returned.
+func (r *Repo) ReplayCommit(commit string, onto string) (string, error) {
+	obj, err := r.readCommitObject(commit)
+	if err != nil {
+		return "", err
+	}
+	parents, message := obj.parents, obj.message
+	env := append(obj.signatureEnv("author"), obj.signatureEnv("committer")...)
+	if len(parents) != 1 {
+		return "", errors.Errorf("cannot replay commit %q with %d parents", commit, len(parents))
+	}
+
+	// Apply the changes of the commit onto the base in a temporary index.
+	indexDir, err := os.MkdirTemp("", "av-replay-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(indexDir)
+	indexEnv := []string{"GIT_INDEX_FILE=" + filepath.Join(indexDir, "index")}
+	if _, err := r.Run(&RunOpts{
+		Args:      []string{"read-tree", onto},
+		Env:       indexEnv,
+		ExitError: true,
+	}); err != nil {
+		return "", err
+	}
+	patch, err := r.Run(&RunOpts{
+		Args:      []string{"diff-tree", "-p", "--binary", "--full-index", parents[0], commit},
+		ExitError: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(patch.Stdout) > 0 {
+		if _, err := r.Run(&RunOpts{
+			Args:      []string{"apply", "--cached"},
+			Env:       indexEnv,
+			Stdin:     bytes.NewReader(patch.Stdout),
+			ExitError: true,
+		}); err != nil {
+			return "", errors.WrapIff(err, "failed to apply commit %q onto %q", commit, onto)
+		}
+	}
+	tree, err := r.Run(&RunOpts{
+		Args:      []string{"write-tree"},
+		Env:       indexEnv,
+		ExitError: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	newCommit, err := r.Run(&RunOpts{
+		Args:      []string{"commit-tree", strings.TrimSpace(string(tree.Stdout)), "-p", onto},
+		Env:       env,
+		Stdin:     strings.NewReader(message),
+		ExitError: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(newCommit.Stdout)), nil
+}
+
+// commitObject is the parsed header and message of a commit object.
+type commitObject struct {
+	parents []string
+	// The name, the email, and the date ("timestamp timezone") of the author and the committer
+	// keyed by "author" and "committer".
+	signatures map[string][3]string
+	message    string
+}
+
+// signatureEnv returns the environment variables for git commit-tree that set the author or the
+// committer ("author" or "committer") to the one of the commit.
+func (c commitObject) signatureEnv(key string) []string {
+	sig := c.signatures[key]
+	prefix := "GIT_" + strings.ToUpper(key) + "_"
+	return []string{prefix + "NAME=" + sig[0], prefix + "EMAIL=" + sig[1], prefix + "DATE=" + sig[2]}
+}
+
+// readCommitObject reads the parents, the author, the committer, and the message of the commit.
+func (r *Repo) readCommitObject(commit string) (*commitObject, error) {
+	out, err := r.Run(&RunOpts{
+		Args:      []string{"cat-file", "commit", commit},
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	header, message, _ := strings.Cut(string(out.Stdout), "\n\n")
+	obj := &commitObject{signatures: map[string][3]string{}, message: message}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "parent":
+			obj.parents = append(obj.parents, value)
+		case "author", "committer":
+			name, email, date, ok := parseSignature(value)
+			if !ok {
+				return nil, errors.Errorf("failed to parse the %s of commit %q", key, commit)
+			}
+			obj.signatures[key] = [3]string{name, email, date}
+		}
+	}
+	return obj, nil
+}
+
+// parseSignature parses the "Name <email> timestamp timezone" format of the author and the
+// committer in a commit object.
+func parseSignature(s string) (name string, email string, date string, ok bool) {
+	name, rest, ok := strings.Cut(s, " <")
+	if !ok {
+		return "", "", "", false
+	}
+	email, date, ok = strings.Cut(rest, "> ")
+	return name, email, date, ok
+}
+
+// ReplayBranch moves the commits in upstream..branch onto the given commit with git replay
+// and updates the branch. Unlike git rebase, git replay creates the new commits without
+// touching the working tree or the index, so this is much faster for the branches that are not
+// checked out.
+//
+// It returns false without changing anything if git replay is not available (Git 2.44 or
+// later is required), if the branch is checked out in a worktree, or if the commits cannot be
+// replayed cleanly (e.g., conflicts or merge commits). It also returns false if the repository
+// has a post-rewrite hook, which git replay doesn't run. The caller should fall back to
+// git rebase in that case.
+func (r *Repo) ReplayBranch(branch, upstream, onto string) (bool, error) {
+	if !r.gitVersionAtLeast(2, 44) {
+		return false, nil
+	}
+	if hook, err := r.hasHook("post-rewrite"); err != nil || hook {
+		return false, err
+	}
+	ref := "refs/heads/" + branch
+	worktree, err := r.Git("for-each-ref", "--format=%(worktreepath)", ref)
+	if err != nil {
+		return false, err
+	}
+	if worktree != "" {
+		return false, nil
+	}
+	oldHead, err := r.RevParse(&RevParse{Rev: ref})
+	if err != nil {
+		return false, err
+	}
+
+	out, err := r.Run(&RunOpts{
+		Args: []string{"replay", "--onto", onto, upstream + ".." + ref},
+	})
+	if err != nil {
+		return false, err
+	}
+	if out.ExitCode != 0 {
+		r.log.WithField("stderr", string(out.Stderr)).Debug("git replay failed")
+		return false, nil
+	}
+	// The output is a list of "update <ref> <new> <old>" lines for git update-ref --stdin.
+	var newHead string
+	for _, line := range strings.Split(strings.TrimSpace(string(out.Stdout)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == "update" && fields[1] == ref {
+			newHead = fields[2]
+		}
+	}
+	if newHead == "" {
+		return false, nil
+	}
+	if err := r.UpdateRef(&UpdateRef{Ref: ref, New: newHead, Old: oldHead}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// gitVersionAtLeast returns true if the version of the git command is at least the given
+// version.
+func (r *Repo) gitVersionAtLeast(major, minor int) bool {
+	out, err := r.Git("version")
+	if err != nil {
+		return false
+	}
+	// e.g., "git version 2.44.0" or "git version 2.39.3 (Apple Git-146)"
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return false
+	}
+	parts := strings.SplitN(fields[2], ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	gotMajor, err1 := strconv.Atoi(parts[0])
+	gotMinor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return gotMajor > major || (gotMajor == major && gotMinor >= minor)
+}
+
+// hasHook returns true if the repository has the given hook. The hooks directory is
+// determined by Git (e.g., core.hooksPath is honored).
+func (r *Repo) hasHook(name string) (bool, error) {
+	hookPath, err := r.Git("rev-parse", "--git-path", "hooks/"+name)
+	if err != nil {
+		return false, err
+	}
+	if !filepath.IsAbs(hookPath) {
+		hookPath = filepath.Join(r.repoDir, hookPath)
+	}
+	stat, err := os.Stat(hookPath)
+	if err != nil {
+		return false, nil
+	}
+	// Git ignores the hooks that are not executable.
+	return stat.Mode().IsRegular() && stat.Mode().Perm()&0111 != 0, nil
+}
@@ -11,6 +11,9 @@ type RevListOpts struct {
 
 	// If true, display the commits in chronological order.
 	Reverse bool
+
+	// If true, the merge commits are not listed.
+	NoMerges bool
 }
 
 // RevList list commits that are reachable from the given commits (excluding
@@ -20,6 +23,9 @@ func (r *Repo) RevList(opts RevListOpts) ([]string, error) {
 	if opts.Reverse {
 		args = append(args, "--reverse")
 	}
+	if opts.NoMerges {
+		args = append(args, "--no-merges")
+	}
 	args = append(args, opts.Specifiers...)
 	res, err := r.Run(&RunOpts{
 		Args:      args,
@@ -0,0 +1,120 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// ErrSigningKeyUnavailable is returned by CheckSigningKey if the commits can't be signed.
+type ErrSigningKeyUnavailable struct {
+	// The signature format (gpg.format): "openpgp", "ssh", or "x509".
+	Format string
+	Reason string
+}
+
+func (e ErrSigningKeyUnavailable) Error() string {
+	return "cannot sign the commits with the " + e.Format + " signing key: " + e.Reason +
+		" (configure user.signingKey and gpg.format, or disable restack.signCommits)"
+}
+
+// CheckSigningKey checks that the signing key that Git uses for git commit --gpg-sign
+// (user.signingKey in the format of gpg.format) is available, so that a restack doesn't stop
+// in the middle of a rebase because a commit can't be signed.
+//
+// The check is best effort: for example, a passphrase-protected key may still fail to sign.
+func (r *Repo) CheckSigningKey() error {
+	format := r.configValue("gpg.format")
+	if format == "" {
+		format = "openpgp"
+	}
+	key := r.configValue("user.signingKey")
+	unavailable := func(reason string) error {
+		return ErrSigningKeyUnavailable{Format: format, Reason: reason}
+	}
+
+	switch format {
+	case "ssh":
+		program := r.configValue("gpg.ssh.program")
+		if program == "" {
+			program = "ssh-keygen"
+		}
+		if _, err := exec.LookPath(program); err != nil {
+			return unavailable("the signing program " + program + " is not found")
+		}
+		if key == "" {
+			if r.configValue("gpg.ssh.defaultKeyCommand") != "" {
+				return nil
+			}
+			return unavailable("user.signingKey is not set")
+		}
+		if strings.HasPrefix(key, "key::") || strings.HasPrefix(key, "ssh-") {
+			// A literal public key. The private key is expected in the ssh-agent.
+			return nil
+		}
+		keyPath := key
+		if rest, ok := strings.CutPrefix(keyPath, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+			keyPath = filepath.Join(home, rest)
+		}
+		if _, err := os.Stat(keyPath); err != nil {
+			return unavailable("the key file " + key + " is not found")
+		}
+		return nil
+	case "x509":
+		program := r.configValue("gpg.x509.program")
+		if program == "" {
+			program = "gpgsm"
+		}
+		if _, err := exec.LookPath(program); err != nil {
+			return unavailable("the signing program " + program + " is not found")
+		}
+		return nil
+	case "openpgp":
+		program := r.configValue("gpg.openpgp.program")
+		if program == "" {
+			program = r.configValue("gpg.program")
+		}
+		if program == "" {
+			program = "gpg"
+		}
+		if _, err := exec.LookPath(program); err != nil {
+			return unavailable("the signing program " + program + " is not found")
+		}
+		if key == "" {
+			// Git signs with the key of the committer identity.
+			ident, err := r.Git("var", "GIT_COMMITTER_IDENT")
+			if err != nil {
+				return errors.WrapIf(err, "failed to determine the committer identity")
+			}
+			name, email, _, ok := parseSignature(ident)
+			if !ok {
+				return errors.Errorf("unexpected committer identity %q", ident)
+			}
+			key = name + " <" + email + ">"
+		}
+		cmd := exec.Command(program, "--batch", "--list-secret-keys", key)
+		cmd.Dir = r.repoDir
+		if err := cmd.Run(); err != nil {
+			return unavailable("no secret key for " + key + " is found by " + program)
+		}
+		return nil
+	default:
+		return unavailable("unknown gpg.format " + format)
+	}
+}
+
+// configValue returns the value of the Git config, or an empty string if it's not set.
+func (r *Repo) configValue(key string) string {
+	value, err := r.Git("config", "--get", key)
+	if err != nil {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,60 @@
+package git_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_CheckSigningKey(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not available")
+	}
+	repo := gittest.NewTempRepo(t)
+	repo.Git(t, "config", "gpg.format", "ssh")
+
+	err := repo.AsAvGitRepo().CheckSigningKey()
+	_, ok := errutils.As[git.ErrSigningKeyUnavailable](err)
+	require.True(t, ok, "expected ErrSigningKeyUnavailable, got %v", err)
+	assert.Contains(t, err.Error(), "user.signingKey is not set")
+
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	repo.Git(t, "config", "user.signingKey", keyPath)
+	err = repo.AsAvGitRepo().CheckSigningKey()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not found")
+
+	require.NoError(t, exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", keyPath).Run())
+	require.NoError(t, repo.AsAvGitRepo().CheckSigningKey())
+
+	// The rebased commits are signed with the key, and the author dates are kept.
+	base := repo.GetCommitAtRef(t, "HEAD")
+	repo.CommitFile(t, "one", "one")
+	repo.Git(t, "commit", "--amend", "--no-edit", "--date=2020-01-02T03:04:05Z")
+	repo.Git(t, "checkout", "-b", "feature")
+	repo.Git(t, "checkout", base.String())
+	newBase := repo.CommitFile(t, "two", "two")
+	_, err = repo.AsAvGitRepo().Rebase(git.RebaseOpts{
+		Branch:   "feature",
+		Upstream: base.String(),
+		Onto:     newBase.String(),
+		Sign:     true,
+
+		CommitterDateIsAuthorDate: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, newBase.String(), strings.TrimSpace(repo.Git(t, "rev-parse", "feature^")))
+	assert.Contains(t, repo.Git(t, "cat-file", "commit", "feature"), "gpgsig -----BEGIN SSH SIGNATURE-----")
+	assert.Equal(
+		t,
+		"1577934245 1577934245",
+		strings.TrimSpace(repo.Git(t, "log", "-1", "--format=%at %ct", "feature")),
+	)
+}
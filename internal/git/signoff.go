@@ -0,0 +1,116 @@
+package git
+
+import (
+	"net/mail"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// CommitSignoffs is a commit and the sign-offs (the Signed-off-by trailers) in its message.
+type CommitSignoffs struct {
+	Hash        string
+	ShortHash   string
+	Subject     string
+	AuthorEmail string
+	// The emails of the Signed-off-by trailers.
+	SignoffEmails []string
+}
+
+// SignedOffBy returns true if the commit has a Signed-off-by trailer with the given email.
+func (c CommitSignoffs) SignedOffBy(email string) bool {
+	for _, e := range c.SignoffEmails {
+		if strings.EqualFold(e, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// SignedOffByAuthor returns true if the commit is signed off by its author, as required by the
+// Developer Certificate of Origin (DCO).
+func (c CommitSignoffs) SignedOffByAuthor() bool {
+	return c.SignedOffBy(c.AuthorEmail)
+}
+
+// Signoffs returns the sign-offs of the commits in the revision range (e.g., "main..feature").
+func (r *Repo) Signoffs(revisionRange string) ([]CommitSignoffs, error) {
+	out, err := r.Git(
+		"log",
+		"--format=%H%x00%h%x00%s%x00%ae%x00%(trailers:key=Signed-off-by,valueonly,separator=%x01)%x00",
+		revisionRange, "--",
+	)
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to read the sign-offs of %s", revisionRange)
+	}
+	fields := strings.Split(out, "\x00")
+	var ret []CommitSignoffs
+	for i := 0; i+4 < len(fields); i += 5 {
+		c := CommitSignoffs{
+			// The entries are separated by a newline.
+			Hash:        strings.TrimSpace(fields[i]),
+			ShortHash:   fields[i+1],
+			Subject:     fields[i+2],
+			AuthorEmail: fields[i+3],
+		}
+		for _, value := range strings.Split(fields[i+4], "\x01") {
+			if email := signoffEmail(value); email != "" {
+				c.SignoffEmails = append(c.SignoffEmails, email)
+			}
+		}
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+// signoffEmail returns the email of the Signed-off-by trailer value (e.g., "Jane Doe
+// <jane@example.com>").
+func signoffEmail(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	if addr, err := mail.ParseAddress(value); err == nil {
+		return addr.Address
+	}
+	if _, rest, ok := strings.Cut(value, "<"); ok {
+		email, _, _ := strings.Cut(rest, ">")
+		return email
+	}
+	return ""
+}
+
+// CommitterEmail returns the email of the committer identity that Git uses for the new commits
+// (and for the sign-offs added by --signoff).
+func (r *Repo) CommitterEmail() (string, error) {
+	ident, err := r.Git("var", "GIT_COMMITTER_IDENT")
+	if err != nil {
+		return "", errors.WrapIf(err, "failed to determine the committer identity")
+	}
+	_, rest, ok := strings.Cut(ident, "<")
+	if !ok {
+		return "", errors.Errorf("unexpected committer identity %q", ident)
+	}
+	email, _, _ := strings.Cut(rest, ">")
+	return email, nil
+}
+
+// CommitsToSignoff returns the commits in the revision range that are signed off by neither
+// their authors nor the committer identity. These are the commits that --signoff changes.
+func (r *Repo) CommitsToSignoff(revisionRange string) ([]CommitSignoffs, error) {
+	email, err := r.CommitterEmail()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := r.Signoffs(revisionRange)
+	if err != nil {
+		return nil, err
+	}
+	var ret []CommitSignoffs
+	for _, c := range commits {
+		if !c.SignedOffByAuthor() && !c.SignedOffBy(email) {
+			ret = append(ret, c)
+		}
+	}
+	return ret, nil
+}
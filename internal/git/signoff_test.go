@@ -0,0 +1,41 @@
+package git_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_Signoffs(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	base := repo.GetCommitAtRef(t, "HEAD")
+	signed := repo.CommitFile(t, "one", "one", gittest.WithMessage(
+		"signed\n\nSigned-off-by: AV Test <AV-TEST@nonexistent>",
+	))
+	other := repo.CommitFile(t, "two", "two", gittest.WithMessage(
+		"signed by another\n\nSigned-off-by: Jane Doe <jane@example.com>\nReviewed-by: Someone <someone@example.com>",
+	))
+	unsigned := repo.CommitFile(t, "three", "three", gittest.WithMessage("unsigned"))
+
+	commits, err := repo.AsAvGitRepo().Signoffs(base.String() + "..HEAD")
+	require.NoError(t, err)
+	require.Len(t, commits, 3)
+	assert.Equal(t, unsigned.String(), commits[0].Hash)
+	assert.Equal(t, "av-test@nonexistent", commits[0].AuthorEmail)
+	assert.Empty(t, commits[0].SignoffEmails)
+	assert.Equal(t, other.String(), commits[1].Hash)
+	assert.Equal(t, []string{"jane@example.com"}, commits[1].SignoffEmails)
+	assert.False(t, commits[1].SignedOffByAuthor())
+	assert.Equal(t, signed.String(), commits[2].Hash)
+	assert.True(t, commits[2].SignedOffByAuthor())
+
+	toSignoff, err := repo.AsAvGitRepo().CommitsToSignoff(base.String() + "..HEAD")
+	require.NoError(t, err)
+	var hashes []string
+	for _, c := range toSignoff {
+		hashes = append(hashes, c.Hash)
+	}
+	assert.Equal(t, []string{unsigned.String(), other.String()}, hashes)
+}
@@ -0,0 +1,69 @@
+package git
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// Autostash stashes the uncommitted changes of the tracked files (both staged and unstaged) and
+// cleans the working tree, like git rebase --autostash. The untracked files are left as they
+// are. It returns the hash of the stash commit, or an empty string if there is nothing to stash.
+//
+// The stash is also stored in the stash list with the given message, so that the changes can be
+// restored with git stash pop even if av doesn't get a chance to restore them.
+func (r *Repo) Autostash(message string) (string, error) {
+	hash, err := r.Git("stash", "create", message)
+	if err != nil {
+		return "", errors.WrapIf(err, "failed to stash the uncommitted changes")
+	}
+	if hash == "" {
+		return "", nil
+	}
+	if _, err := r.Run(&RunOpts{
+		Args:      []string{"stash", "store", "--message", message, hash},
+		ExitError: true,
+	}); err != nil {
+		return "", errors.WrapIf(err, "failed to store the stash")
+	}
+	if _, err := r.Run(&RunOpts{
+		Args:      []string{"reset", "--hard", "--quiet"},
+		ExitError: true,
+	}); err != nil {
+		return "", errors.WrapIff(
+			err, "failed to clean the working tree (the changes are stashed as %s)", ShortSha(hash),
+		)
+	}
+	return hash, nil
+}
+
+// AutostashPop restores the changes stashed by Autostash onto the working tree (with the
+// staged changes staged again) and removes the stash from the stash list. If the changes can't
+// be applied cleanly, the stash is kept in the stash list and an error is returned.
+func (r *Repo) AutostashPop(hash string) error {
+	if _, err := r.Run(&RunOpts{
+		Args:      []string{"stash", "apply", "--index", "--quiet", hash},
+		ExitError: true,
+	}); err != nil {
+		return errors.WrapIff(
+			err, "failed to restore the stashed changes (they are kept in the stash as %s)",
+			ShortSha(hash),
+		)
+	}
+	stashes, err := r.Git("stash", "list", "--format=%H")
+	if err != nil {
+		return err
+	}
+	idx := slices.Index(strings.Split(stashes, "\n"), hash)
+	if idx < 0 {
+		// Already dropped by the user.
+		return nil
+	}
+	_, err = r.Run(&RunOpts{
+		Args:      []string{"stash", "drop", "--quiet", "stash@{" + strconv.Itoa(idx) + "}"},
+		ExitError: true,
+	})
+	return err
+}
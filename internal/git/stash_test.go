@@ -0,0 +1,37 @@
+package git_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_Autostash(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	repo.CommitFile(t, "staged", "one\n")
+	repo.CommitFile(t, "unstaged", "one\n")
+
+	hash, err := repo.AsAvGitRepo().Autostash("av test")
+	require.NoError(t, err)
+	assert.Empty(t, hash, "nothing to stash in a clean working tree")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "staged"), []byte("two\n"), 0644))
+	repo.Git(t, "add", "staged")
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "unstaged"), []byte("two\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(repo.RepoDir, "untracked"), []byte("new\n"), 0644))
+
+	hash, err = repo.AsAvGitRepo().Autostash("av test")
+	require.NoError(t, err)
+	require.NotEmpty(t, hash)
+	assert.Equal(t, "?? untracked\n", repo.Git(t, "status", "--porcelain"))
+	assert.Contains(t, repo.Git(t, "stash", "list"), "av test")
+
+	repo.CommitFile(t, "other", "one\n")
+	require.NoError(t, repo.AsAvGitRepo().AutostashPop(hash))
+	assert.Equal(t, "M  staged\n M unstaged\n?? untracked\n", repo.Git(t, "status", "--porcelain"))
+	assert.Empty(t, repo.Git(t, "stash", "list"))
+}
@@ -15,6 +15,19 @@ const (
 	StateFileKindSyncV2  StateFileKind = "stack-sync-v2.state.json"
 )
 
+// InProgressStateFile returns the kind of the state file of the av operation that is
+// interrupted (e.g., by a conflict), or false if no operation is in progress.
+func (r *Repo) InProgressStateFile() (StateFileKind, bool) {
+	for _, kind := range []StateFileKind{
+		StateFileKindSyncV2, StateFileKindReorder, StateFileKindRestack,
+	} {
+		if stat, _ := os.Stat(filepath.Join(r.AvDir(), string(kind))); stat != nil {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
 func (r *Repo) ReadStateFile(kind StateFileKind, msg any) error {
 	bs, err := os.ReadFile(filepath.Join(r.AvDir(), string(kind)))
 	if err != nil {
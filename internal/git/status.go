@@ -58,6 +58,10 @@ var (
 )
 
 func (r *Repo) Status() (GitStatus, error) {
+	if r.IsBare() {
+		// A bare repository has no worktree, and hence no current branch or file changes.
+		return GitStatus{}, nil
+	}
 	body, err := r.Git("status", "--porcelain=v2", "--branch", "--untracked-files")
 	if err != nil {
 		return GitStatus{}, err
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// HasSubmodules returns true if the working tree has a .gitmodules file.
+func (r *Repo) HasSubmodules() bool {
+	_, err := os.Stat(filepath.Join(r.repoDir, ".gitmodules"))
+	return err == nil
+}
+
+// StaleSubmodules returns the paths of the submodules whose checked out commits don't match
+// the commits recorded in the index, or that are not initialized, including the nested
+// submodules.
+func (r *Repo) StaleSubmodules() ([]string, error) {
+	if !r.HasSubmodules() {
+		return nil, nil
+	}
+	out, err := r.Run(&RunOpts{
+		Args:      []string{"submodule", "status", "--recursive"},
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to read the submodule status")
+	}
+	var ret []string
+	for _, line := range strings.Split(string(out.Stdout), "\n") {
+		// Each line is "<state><commit> <path> (<describe>)", where the state is a space if
+		// the submodule is up to date, "-" if it's not initialized, "+" if it's at another
+		// commit, and "U" if it has merge conflicts.
+		if len(line) < 2 || line[0] == ' ' {
+			continue
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) >= 2 {
+			ret = append(ret, fields[1])
+		}
+	}
+	return ret, nil
+}
+
+// UpdateSubmodules checks out the commits recorded in the index in the submodules with git
+// submodule update --init --recursive.
+func (r *Repo) UpdateSubmodules() error {
+	out, err := r.Run(&RunOpts{
+		Args: []string{"submodule", "update", "--init", "--recursive"},
+	})
+	if err != nil {
+		return err
+	}
+	if out.ExitCode != 0 {
+		return errors.Errorf(
+			"failed to update the submodules: %s", strings.TrimSpace(string(out.Stderr)),
+		)
+	}
+	return nil
+}
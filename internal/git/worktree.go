@@ -0,0 +1,46 @@
+package git
+
+import (
+	"path/filepath"
+
+	"emperror.dev/errors"
+)
+
+// IsLinkedWorktree returns true if the current worktree is a linked worktree (created by `git
+// worktree add`) rather than the main worktree.
+func (r *Repo) IsLinkedWorktree() bool {
+	return !r.IsBare() && filepath.Clean(r.WorktreeGitDir()) != filepath.Clean(r.GitDir())
+}
+
+// BranchWorktree returns the path of the worktree that the branch is checked out in, or an
+// empty string if the branch is not checked out in any worktree.
+func (r *Repo) BranchWorktree(branch string) (string, error) {
+	return r.Git("for-each-ref", "--format=%(worktreepath)", "refs/heads/"+branch)
+}
+
+// CheckNotInOtherWorktree returns an error if the branch is checked out in a worktree other
+// than the current one. Git refuses to check out or rebase such a branch, since it would change
+// the files under the other worktree.
+func (r *Repo) CheckNotInOtherWorktree(branch string) error {
+	path, err := r.BranchWorktree(branch)
+	if err != nil {
+		return err
+	}
+	if path == "" || sameDir(path, r.repoDir) {
+		return nil
+	}
+	return errors.Errorf(
+		"branch %q is checked out in another worktree at %s (run the command from that worktree, or switch that worktree to another branch first)",
+		branch, path,
+	)
+}
+
+func sameDir(a, b string) bool {
+	if ra, err := filepath.EvalSymlinks(a); err == nil {
+		a = ra
+	}
+	if rb, err := filepath.EvalSymlinks(b); err == nil {
+		b = rb
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
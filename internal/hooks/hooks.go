@@ -0,0 +1,139 @@
+// Package hooks runs the user-defined commands at the lifecycle events of av (e.g., before a
+// branch is created or after a stack is submitted).
+//
+// The hooks of an event are the shell commands configured in the hooks config, followed by
+// the executable .av/hooks/<event> in the repository if it exists. The context of the event
+// is passed to the hooks as environment variables (see Env).
+package hooks
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/sirupsen/logrus"
+)
+
+type Event string
+
+const (
+	// PreBranchCreate runs before a branch is created. A failing hook aborts the creation.
+	PreBranchCreate Event = "pre-branch-create"
+	// PostBranchCreate runs after a branch is created.
+	PostBranchCreate Event = "post-branch-create"
+	// PreSubmit runs for each branch before its pull request is created or updated. A
+	// failing hook aborts the submission.
+	PreSubmit Event = "pre-submit"
+	// PostSubmit runs for each branch after its pull request is created or updated.
+	PostSubmit Event = "post-submit"
+	// PostSync runs after av sync completes.
+	PostSync Event = "post-sync"
+	// PreRestack runs before av restack rebases the branches. A failing hook aborts the
+	// restack.
+	PreRestack Event = "pre-restack"
+)
+
+// Dir is the directory (relative to the repository root) of the hook executables.
+const Dir = ".av/hooks"
+
+// Env is the context of the event that is passed to the hooks as environment variables.
+type Env struct {
+	// The branch of the event ($AV_BRANCH).
+	Branch string
+	// The parent branch of Branch ($AV_PARENT).
+	Parent string
+	// The pull request number of Branch, if any ($AV_PR_NUMBER).
+	PullRequestNumber int64
+	// The pull request URL of Branch, if any ($AV_PR_URL).
+	PullRequestURL string
+}
+
+func (e Env) environ(event Event) []string {
+	env := []string{
+		"AV_HOOK=" + string(event),
+		"AV_BRANCH=" + e.Branch,
+		"AV_PARENT=" + e.Parent,
+	}
+	if e.PullRequestNumber != 0 {
+		env = append(env, "AV_PR_NUMBER="+strconv.FormatInt(e.PullRequestNumber, 10))
+	}
+	if e.PullRequestURL != "" {
+		env = append(env, "AV_PR_URL="+e.PullRequestURL)
+	}
+	return env
+}
+
+// Enabled returns true if the event has any hooks.
+func Enabled(repo *git.Repo, event Event) bool {
+	return len(configured(event)) > 0 || executable(repo, event) != ""
+}
+
+// Run runs the hooks of the event in the repository root. The output of the hooks goes to
+// stderr so that it doesn't mix with the output of av. It stops at the first failing hook
+// and returns its error.
+func Run(repo *git.Repo, event Event, env Env) error {
+	return run(repo, event, env, os.Stderr)
+}
+
+func run(repo *git.Repo, event Event, env Env, out io.Writer) error {
+	var cmds []*exec.Cmd
+	for _, command := range configured(event) {
+		cmds = append(cmds, exec.Command("sh", "-c", command))
+	}
+	if path := executable(repo, event); path != "" {
+		cmds = append(cmds, exec.Command(path))
+	}
+	for _, cmd := range cmds {
+		cmd.Dir = repo.Dir()
+		cmd.Env = append(os.Environ(), env.environ(event)...)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		logrus.WithField("hook", event).WithField("args", cmd.Args).Debug("running hook")
+		if err := cmd.Run(); err != nil {
+			return errors.WrapIff(err, "%s hook %q failed", event, hookName(cmd))
+		}
+	}
+	return nil
+}
+
+func hookName(cmd *exec.Cmd) string {
+	if len(cmd.Args) == 3 && cmd.Args[1] == "-c" {
+		return cmd.Args[2]
+	}
+	return filepath.ToSlash(filepath.Join(Dir, filepath.Base(cmd.Path)))
+}
+
+func configured(event Event) []string {
+	h := config.Av.Hooks
+	switch event {
+	case PreBranchCreate:
+		return h.PreBranchCreate
+	case PostBranchCreate:
+		return h.PostBranchCreate
+	case PreSubmit:
+		return h.PreSubmit
+	case PostSubmit:
+		return h.PostSubmit
+	case PostSync:
+		return h.PostSync
+	case PreRestack:
+		return h.PreRestack
+	}
+	panic(fmt.Sprintf("unknown hook event %q", event))
+}
+
+// executable returns the path of .av/hooks/<event> if it's an executable file.
+func executable(repo *git.Repo, event Event) string {
+	path := filepath.Join(repo.Dir(), Dir, string(event))
+	stat, err := os.Stat(path)
+	if err != nil || stat.IsDir() || stat.Mode()&0o111 == 0 {
+		return ""
+	}
+	return path
+}
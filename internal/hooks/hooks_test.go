@@ -0,0 +1,60 @@
+package hooks_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/hooks"
+	"github.com/stretchr/testify/require"
+)
+
+func setHooks(t *testing.T, h config.Hooks) {
+	orig := config.Av.Hooks
+	config.Av.Hooks = h
+	t.Cleanup(func() { config.Av.Hooks = orig })
+}
+
+func TestRun(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	setHooks(t, config.Hooks{
+		PreSubmit: []string{`echo "$AV_HOOK $AV_BRANCH $AV_PARENT $AV_PR_NUMBER" > configured.txt`},
+	})
+	hookDir := filepath.Join(repo.RepoDir, hooks.Dir)
+	require.NoError(t, os.MkdirAll(hookDir, 0o755))
+	require.NoError(t, os.WriteFile(
+		filepath.Join(hookDir, "pre-submit"),
+		[]byte("#!/bin/sh\necho \"$AV_PR_URL\" > executable.txt\n"),
+		0o755,
+	))
+
+	avRepo := repo.AsAvGitRepo()
+	require.True(t, hooks.Enabled(avRepo, hooks.PreSubmit))
+	require.False(t, hooks.Enabled(avRepo, hooks.PostSubmit))
+	require.NoError(t, hooks.Run(avRepo, hooks.PreSubmit, hooks.Env{
+		Branch:            "feature",
+		Parent:            "main",
+		PullRequestNumber: 42,
+		PullRequestURL:    "https://github.com/aviator-co/av/pull/42",
+	}))
+
+	configured, err := os.ReadFile(filepath.Join(repo.RepoDir, "configured.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "pre-submit feature main 42\n", string(configured))
+	executable, err := os.ReadFile(filepath.Join(repo.RepoDir, "executable.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "https://github.com/aviator-co/av/pull/42\n", string(executable))
+}
+
+func TestRunStopsAtFailure(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	setHooks(t, config.Hooks{
+		PreBranchCreate: []string{"exit 1", "touch ran.txt"},
+	})
+
+	err := hooks.Run(repo.AsAvGitRepo(), hooks.PreBranchCreate, hooks.Env{Branch: "feature"})
+	require.ErrorContains(t, err, `pre-branch-create hook "exit 1" failed`)
+	require.NoFileExists(t, filepath.Join(repo.RepoDir, "ran.txt"))
+}
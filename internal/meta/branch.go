@@ -2,6 +2,7 @@ package meta
 
 import (
 	"encoding/json"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/shurcooL/githubv4"
@@ -23,12 +24,137 @@ type Branch struct {
 
 	// The merge commit onto the trunk branch, if any
 	MergeCommit string `json:"mergeCommit,omitempty"`
+
+	// The commit that the branch is pinned to, if any. A pinned branch is not rebased by
+	// sync/restack, and its children are rebased onto the pinned commit.
+	PinnedCommit string `json:"pinnedCommit,omitempty"`
+
+	// The trunk commit that the stack is frozen to, if any (e.g., a release cut). This is set
+	// only on the stack roots. A stack root with a frozen base is rebased onto this commit by
+	// sync instead of the latest trunk.
+	FrozenBase string `json:"frozenBase,omitempty"`
+
+	// The immutable ref (a tag or a commit SHA, e.g., "v1.2.0") that the stack is built on, if
+	// any. This is set only on the stack roots together with FrozenBase, which is the commit of
+	// the ref, so that the stack is treated as based on a trunk frozen at the ref.
+	BaseRef string `json:"baseRef,omitempty"`
+
+	// True if the branch is followed from the remote (see av follow): it's owned by someone
+	// else (e.g., a dependency update by Renovate or Dependabot), and stacks are built on top of
+	// it. A followed branch is never rebased or pushed by av. av sync updates it to the remote
+	// branch instead.
+	Followed bool `json:"followed,omitempty"`
+
+	// The prefix of the numbered branch names of the stack (e.g., "feat-x" for
+	// "feat-x/01-schema"), if any. This is set on all the branches of a stack renamed by
+	// av renumber, and the stack is renumbered after av reorder.
+	SequencePrefix string `json:"sequencePrefix,omitempty"`
+
+	// The URL of the preview deployment of the branch (e.g., a preview environment deployed
+	// from the pull request), if any. This is shown in av tree and in the pull request body.
+	PreviewURL string `json:"previewURL,omitempty"`
+
+	// The lock of the branch, if any (see av branch lock). A locked branch is being edited by
+	// the lock owner (e.g., in a pair or mob session), and the other users are warned before
+	// restacking or pushing it.
+	Lock *BranchLock `json:"lock,omitempty"`
+
+	// True if the branch is excluded from submit (see av branch --no-submit), e.g., a local
+	// integration or experiment branch. It's restacked as usual, but av pr and av sync don't
+	// push it, and the branches stacked on it are not submitted either.
+	NoSubmit bool `json:"noSubmit,omitempty"`
+
+	// The position in the stack (the branch based on the trunk is at position 1) at which the
+	// draft pull request of the branch becomes ready for review. This is set when the pull
+	// request is submitted as a draft by the draft rules or by av pr --ready-below, and av sync
+	// marks the pull request as ready once its parents are merged. Zero if not set.
+	ReadyAtDepth int `json:"readyAtDepth,omitempty"`
+
+	// The merge method ("merge", "squash", or "rebase") to enable auto-merge with once the pull
+	// request of the branch targets the trunk. This is set when the pull request is submitted
+	// with auto-merge above the bottom of the stack, and av sync enables auto-merge once its
+	// parents are merged. Empty if not set.
+	AutoMergeMethod string `json:"autoMergeMethod,omitempty"`
+
+	// The report of the conflict that stopped the last restack of the branch, if any. This is
+	// kept after the rebase is continued or aborted so that av tree can show the conflicted
+	// branches later, and it's cleared when the branch is restacked successfully.
+	Conflict *BranchConflict `json:"conflict,omitempty"`
+
+	// The merged branch that this branch reverts, if any (see av revert).
+	Reverts *BranchRevert `json:"reverts,omitempty"`
+
+	// The issue that the branch works on (see av branch --issue): an issue key of a tracker
+	// like Jira (e.g., "PROJ-123") or a GitHub issue ("#123" or "owner/repo#123"). It's added
+	// to the commit messages and the pull request title and body of the branch.
+	Issue string `json:"issue,omitempty"`
+}
+
+// BranchRevert is the merged branch (or pull request) that a revert branch reverts.
+type BranchRevert struct {
+	// The name of the reverted branch.
+	Branch string `json:"branch,omitempty"`
+	// The number of the pull request of the reverted branch, if known.
+	PullRequestNumber int64 `json:"pullRequestNumber,omitempty"`
+	// The reverted merge commit on the trunk.
+	Commit string `json:"commit"`
+	// The trunk commit before the reverted commits if the branch was rebase-merged into more
+	// than one commit (Base..Commit is reverted). Empty if only Commit is reverted.
+	Base string `json:"base,omitempty"`
+}
+
+// BranchConflict is the report of a conflict while rebasing a branch onto its parent branch.
+type BranchConflict struct {
+	// When the conflict was detected.
+	DetectedAt time.Time `json:"detectedAt"`
+	// The parent branch that the branch was being rebased onto.
+	Parent string `json:"parent"`
+	// The paths of the conflicted files.
+	Files []string `json:"files"`
+	// The commit of the branch that conflicts ("<short hash> <subject>").
+	Commit string `json:"commit,omitempty"`
+	// The commits of the parent branch that changed the conflicted files ("<short hash>
+	// <subject>"), newest first.
+	ParentCommits []string `json:"parentCommits,omitempty"`
+	// The commands suggested to resolve the conflict.
+	Commands []string `json:"commands,omitempty"`
+}
+
+// BranchLock marks a branch as being edited by a user.
+type BranchLock struct {
+	// The email of the user who locked the branch.
+	Owner string `json:"owner"`
+	// When the branch was locked.
+	LockedAt time.Time `json:"lockedAt"`
 }
 
 func (b *Branch) IsStackRoot() bool {
 	return b.Parent.Trunk
 }
 
+func (b *Branch) IsPinned() bool {
+	return b.PinnedCommit != ""
+}
+
+// IsFollowed returns true if the branch is followed from the remote (see Branch.Followed).
+func (b *Branch) IsFollowed() bool {
+	return b.Followed
+}
+
+// IsClosedFollowed returns true if the branch is followed from the remote and its pull request
+// is closed without being merged. The children of such a branch are moved off of it.
+func (b *Branch) IsClosedFollowed() bool {
+	return b.Followed && b.MergeCommit == "" && b.PullRequest != nil &&
+		b.PullRequest.State == githubv4.PullRequestStateClosed
+}
+
+// IsInMergeQueue returns true if the pull request of the branch is in the merge queue. The
+// queue merges the pushed commits of the branch, so the branch shouldn't be rewritten.
+func (b *Branch) IsInMergeQueue() bool {
+	return b.PullRequest != nil && b.PullRequest.State == githubv4.PullRequestStateOpen &&
+		b.PullRequest.InMergeQueue
+}
+
 func (b *Branch) UnmarshalJSON(bytes []byte) error {
 	// We have to do a bit of backwards-compatible trickery here to support the
 	// fact that "parent" used to be a string field and now it's a struct
@@ -71,7 +197,7 @@ func (b *Branch) UnmarshalJSON(bytes []byte) error {
 var _ json.Unmarshaler = (*Branch)(nil)
 
 type PullRequest struct {
-	// The GitHub (GraphQL) ID of the pull request.
+	// The GitHub (GraphQL) ID of the pull request, or the merge request IID on GitLab.
 	ID string `json:"id"`
 	// The pull request number.
 	Number int64 `json:"number"`
@@ -79,6 +205,37 @@ type PullRequest struct {
 	Permalink string `json:"permalink"`
 	// The state of the pull request (open, closed, or merged).
 	State githubv4.PullRequestState `json:"state"`
+	// True if the pull request was in the merge queue when it was last fetched.
+	InMergeQueue bool `json:"inMergeQueue,omitempty"`
+	// The title of the pull request when it was last fetched or updated (shown in the shell
+	// completion).
+	Title string `json:"title,omitempty"`
+	// When the state of the pull request was last fetched from the forge (e.g., by av sync).
+	// Zero if unknown.
+	FetchedAt time.Time `json:"fetchedAt"`
+	// The review status of the pull request when it was last fetched by av pr status --stack,
+	// if any. This is shown when the forge is not reachable (e.g., with --offline).
+	Review *PullRequestReview `json:"review,omitempty"`
+}
+
+// PullRequestReview is the mergeability, the reviews, and the checks of a pull request cached
+// from the forge.
+type PullRequestReview struct {
+	// When the review status was fetched.
+	FetchedAt      time.Time                          `json:"fetchedAt"`
+	IsDraft        bool                               `json:"isDraft,omitempty"`
+	Mergeable      githubv4.MergeableState            `json:"mergeable,omitempty"`
+	ReviewDecision githubv4.PullRequestReviewDecision `json:"reviewDecision,omitempty"`
+	Approvers      []string                           `json:"approvers,omitempty"`
+	Checks         []PullRequestCheck                 `json:"checks,omitempty"`
+}
+
+// PullRequestCheck is a check of the head commit of a pull request.
+type PullRequestCheck struct {
+	Name string `json:"name"`
+	// One of "pass", "fail", or "pending".
+	Result   string `json:"result"`
+	Required bool   `json:"required,omitempty"`
 }
 
 // GetNumber returns the number of the pull request or zero if the PullRequest is nil.
@@ -177,6 +334,25 @@ func Trunk(tx ReadTx, name string) (string, bool) {
 	return "", false
 }
 
+// NoSubmitAncestor returns the branch that excludes the given branch from submit: the branch
+// itself or its closest ancestor with NoSubmit set. It returns an empty string if the branch
+// can be submitted.
+func NoSubmitAncestor(tx ReadTx, name string) string {
+	for {
+		br, ok := tx.Branch(name)
+		if !ok {
+			return ""
+		}
+		if br.NoSubmit {
+			return name
+		}
+		if br.Parent.Trunk || br.Parent.Name == "" {
+			return ""
+		}
+		name = br.Parent.Name
+	}
+}
+
 // Children returns all the immediate children of the given branch.
 func Children(tx ReadTx, name string) []Branch {
 	branches := tx.AllBranches()
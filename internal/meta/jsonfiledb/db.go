@@ -5,7 +5,9 @@ import (
 	"path/filepath"
 	"sync"
 
+	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/meta"
+	"github.com/sirupsen/logrus"
 )
 
 type DB struct {
@@ -17,13 +19,28 @@ type DB struct {
 
 // OpenPath opens a JSON file database at the given path.
 // If the file does not exist, it is created (as well as all ancestor directories).
+// A corrupted file (e.g., a torn write) is repaired from the backup of the last committed
+// state.
 func OpenPath(fp string) (*DB, bool, error) {
 	_ = os.MkdirAll(filepath.Dir(fp), 0755)
-	state, err := readState(fp)
+	db := &DB{filepath: fp, stateMu: sync.Mutex{}}
+	state, recovered, err := readState(fp)
 	if err != nil {
 		return nil, false, err
 	}
-	db := &DB{filepath: fp, stateMu: sync.Mutex{}, state: state}
+	// The temporary files and the repair are only touched if no other av command is writing
+	// the state file. Opening the database never waits for the other commands.
+	if l, err := lockFile(db.lockPath(), 0); err == nil {
+		removeStaleTempFiles(fp)
+		if recovered {
+			if err := state.write(fp); err != nil {
+				l.unlock()
+				return nil, false, err
+			}
+		}
+		l.unlock()
+	}
+	db.state = state
 	return db, state.RepositoryState.ID != "", nil
 }
 
@@ -37,10 +54,49 @@ func (d *DB) ReadTx() meta.ReadTx {
 
 func (d *DB) WriteTx() meta.WriteTx {
 	// For a write transaction, we acquire the lock until the transaction is
-	// aborted/committed in order to prevent other transactions from modifying
-	// the state.
+	// aborted/committed in order to prevent other transactions in this process from
+	// modifying the state. This lock is not held across the processes.
 	d.stateMu.Lock()
-	return &writeTx{d, readTx{d.state.copy()}}
+	// The database is shared by all the worktrees of the repository, so the av commands
+	// running in the other worktrees may have changed it since it was opened. Start from the
+	// latest state without the file lock, which is safe as the state file is replaced
+	// atomically. The changes made by the other processes until the commit are kept by
+	// commit, which writes only the changes of this transaction on top of them.
+	if state, _, err := readState(d.filepath); err != nil {
+		logrus.WithError(err).Warn("failed to re-read the av state file, using the state read when it was opened")
+	} else {
+		d.state = state
+	}
+	return &writeTx{db: d, readTx: readTx{d.state.copy()}}
+}
+
+func (d *DB) lockPath() string {
+	return d.filepath + ".lock"
+}
+
+// commit writes the changes of a write transaction on top of the latest state file. The lock
+// across the processes is held only while the state file is re-read and written, so that the
+// other av commands (e.g., the ones run by the hooks) are never blocked by a long transaction.
+func (d *DB) commit(tx *writeTx) error {
+	l, err := lockFile(d.lockPath(), lockTimeout)
+	if errors.Is(err, errLockTimeout) {
+		return err
+	}
+	if err != nil {
+		// Some network filesystems don't support locking. The writes are still atomic.
+		logrus.WithError(err).Debug("failed to lock the av state file")
+	}
+	defer l.unlock()
+	latest, _, err := readState(d.filepath)
+	if err != nil {
+		return err
+	}
+	tx.applyTo(latest)
+	if err := latest.write(d.filepath); err != nil {
+		return err
+	}
+	d.state = latest
+	return nil
 }
 
 var (
@@ -1,7 +1,9 @@
 package jsonfiledb_test
 
 import (
+	"os"
 	"testing"
+	"time"
 
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/meta/jsonfiledb"
@@ -50,3 +52,80 @@ func TestJSONFileDB(t *testing.T) {
 	require.True(t, ok, "branch should be found after re-open")
 	require.Equal(t, "foo", foo.Name, "branch name should match")
 }
+
+func TestJSONFileDBRecoversTornWrite(t *testing.T) {
+	tempfile := t.TempDir() + "/db.json"
+
+	db, _, err := jsonfiledb.OpenPath(tempfile)
+	require.NoError(t, err)
+	tx := db.WriteTx()
+	tx.SetRepository(meta.Repository{ID: "foo"})
+	tx.SetBranch(meta.Branch{Name: "foo"})
+	require.NoError(t, tx.Commit())
+
+	// A torn write leaves the file truncated or filled with zeros.
+	require.NoError(t, os.WriteFile(tempfile, make([]byte, 64), 0644))
+	// A crashed writer leaves its temporary file.
+	require.NoError(t, os.WriteFile(tempfile+".tmp123", []byte("{"), 0644))
+
+	db, exists, err := jsonfiledb.OpenPath(tempfile)
+	require.NoError(t, err, "db open should recover from the backup")
+	require.True(t, exists)
+	_, ok := db.ReadTx().Branch("foo")
+	require.True(t, ok, "branch should be recovered from the backup")
+
+	data, err := os.ReadFile(tempfile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"foo"`, "the state file should be repaired")
+	require.NoFileExists(t, tempfile+".tmp123", "stale temporary files should be removed")
+}
+
+func TestJSONFileDBCorruptedWithoutBackup(t *testing.T) {
+	tempfile := t.TempDir() + "/db.json"
+	require.NoError(t, os.WriteFile(tempfile, []byte("{\"branches\": {"), 0644))
+	_, _, err := jsonfiledb.OpenPath(tempfile)
+	require.Error(t, err)
+}
+
+func TestJSONFileDBWriteTxMergesAcrossInstances(t *testing.T) {
+	tempfile := t.TempDir() + "/db.json"
+
+	dbA, _, err := jsonfiledb.OpenPath(tempfile)
+	require.NoError(t, err)
+	dbB, _, err := jsonfiledb.OpenPath(tempfile)
+	require.NoError(t, err)
+
+	tx := dbA.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "stale"})
+	require.NoError(t, tx.Commit())
+
+	txA := dbA.WriteTx()
+	txA.SetBranch(meta.Branch{Name: "a"})
+
+	// A transaction open in another process (e.g., an av command run by a hook) doesn't block
+	// the other transactions.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		txB := dbB.WriteTx()
+		txB.SetBranch(meta.Branch{Name: "b"})
+		txB.DeleteBranch("stale")
+		assert.NoError(t, txB.Commit())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the write transaction should not wait for the other one")
+	}
+	require.NoError(t, txA.Commit())
+
+	// Both transactions are written on top of each other.
+	db, _, err := jsonfiledb.OpenPath(tempfile)
+	require.NoError(t, err)
+	_, ok := db.ReadTx().Branch("a")
+	require.True(t, ok)
+	_, ok = db.ReadTx().Branch("b")
+	require.True(t, ok)
+	_, ok = db.ReadTx().Branch("stale")
+	require.False(t, ok)
+}
@@ -0,0 +1,59 @@
+package jsonfiledb
+
+import (
+	"os"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+// lockTimeout is how long a commit waits for the other av commands to finish writing the state
+// file. The lock is only held while the state file is written, so this is long enough unless the
+// other process is stuck.
+const lockTimeout = 10 * time.Second
+
+// errLockTimeout is returned when the lock is held by another process for longer than the
+// timeout.
+var errLockTimeout = errors.New("timed out waiting for another av command to write the av state file")
+
+// fileLock is an exclusive lock on a file that is held across the processes (flock on Unix,
+// LockFileEx on Windows). This serializes the writes of the state file by the av commands running
+// concurrently (e.g., in the other worktrees of the repository).
+type fileLock struct {
+	f *os.File
+}
+
+// lockFile acquires the lock on the file at the given path, waiting up to the timeout until the
+// other processes release it. A zero timeout tries only once. The file is created if it does not
+// exist.
+func lockFile(path string, timeout time.Duration) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to open the lock file %q", path)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLockFD(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, errors.WrapIff(err, "failed to lock %q", path)
+		}
+		if locked {
+			return &fileLock{f: f}, nil
+		}
+		if !time.Now().Before(deadline) {
+			_ = f.Close()
+			return nil, errLockTimeout
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// unlock releases the lock. Unlocking a nil lock is a no-op.
+func (l *fileLock) unlock() {
+	if l == nil {
+		return
+	}
+	_ = unlockFD(l.f)
+	_ = l.f.Close()
+}
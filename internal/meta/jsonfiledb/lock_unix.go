@@ -0,0 +1,30 @@
+//go:build !windows
+
+package jsonfiledb
+
+import (
+	"os"
+	"syscall"
+)
+
+// tryLockFD tries to lock the file without blocking. It returns false if another process holds
+// the lock.
+func tryLockFD(f *os.File) (bool, error) {
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		switch err {
+		case nil:
+			return true, nil
+		case syscall.EWOULDBLOCK:
+			return false, nil
+		case syscall.EINTR:
+			continue
+		default:
+			return false, err
+		}
+	}
+}
+
+func unlockFD(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
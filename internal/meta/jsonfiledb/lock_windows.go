@@ -0,0 +1,31 @@
+//go:build windows
+
+package jsonfiledb
+
+import (
+	"os"
+
+	"emperror.dev/errors"
+	"golang.org/x/sys/windows"
+)
+
+// tryLockFD tries to lock the file without blocking. It returns false if another process holds
+// the lock.
+func tryLockFD(f *os.File) (bool, error) {
+	err := windows.LockFileEx(
+		windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY,
+		0,
+		1,
+		0,
+		&windows.Overlapped{},
+	)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFD(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &windows.Overlapped{})
+}
@@ -1,29 +1,79 @@
 package jsonfiledb
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
+	"path/filepath"
 
 	"emperror.dev/errors"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/utils/maputils"
+	"github.com/sirupsen/logrus"
 )
 
-func readState(filepath string) (*state, error) {
-	data, err := os.ReadFile(filepath)
+// backupPath returns the path of the backup of the last committed state, which is used to
+// recover from a torn write of the state file.
+func backupPath(fp string) string {
+	return fp + ".bak"
+}
+
+// readState reads the state file. If the state file is corrupted (e.g., a torn write on a
+// network filesystem), the last committed state is recovered from the backup and the returned
+// bool is true.
+func readState(fp string) (*state, bool, error) {
+	data, err := os.ReadFile(fp)
 	if err != nil && !os.IsNotExist(err) {
-		return nil, err
+		return nil, false, err
+	}
+	exists := err == nil
+	st, err := parseState(data, exists)
+	if err == nil {
+		return st, false, nil
+	}
+	if backup, berr := os.ReadFile(backupPath(fp)); berr == nil {
+		if st, berr := parseState(backup, true); berr == nil {
+			logrus.WithError(err).
+				WithField("file", fp).
+				Warn("av state file is corrupted, recovered the last committed state from the backup")
+			return st, true, nil
+		}
 	}
 	if len(data) == 0 {
+		// Nothing to recover from. An empty file is the same as a new database.
+		return &state{}, false, nil
+	}
+	return nil, false, errors.WrapIff(err, "failed to read av state file %q", fp)
+}
+
+func parseState(data []byte, exists bool) (*state, error) {
+	if !exists {
 		data = []byte("{}")
 	}
+	if len(bytes.Trim(data, "\x00 \t\r\n")) == 0 {
+		// A committed state file is never empty, so this is a torn write.
+		return nil, errors.New("av state file is empty")
+	}
 	var state state
 	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, errors.WrapIff(err, "failed to read av state file %q", filepath)
+		return nil, err
 	}
 	return &state, nil
 }
 
+// removeStaleTempFiles removes the temporary files left by the av commands that crashed while
+// writing the state file. This must be called with the file lock held.
+func removeStaleTempFiles(fp string) {
+	matches, _ := filepath.Glob(fp + ".tmp*")
+	for _, m := range matches {
+		_ = os.Remove(m)
+	}
+	matches, _ = filepath.Glob(backupPath(fp) + ".tmp*")
+	for _, m := range matches {
+		_ = os.Remove(m)
+	}
+}
+
 type state struct {
 	BranchState     map[string]meta.Branch `json:"branches"`
 	RepositoryState meta.Repository        `json:"repository"`
@@ -36,16 +86,50 @@ func (d *state) copy() state {
 	}
 }
 
-func (d *state) write(filepath string) error {
-	f, err := os.OpenFile(filepath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+func (d *state) write(fp string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
 	if err != nil {
 		return errors.WrapIff(err, "failed to write av state file")
 	}
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(d); err != nil {
-		_ = f.Close()
+	data = append(data, '\n')
+	if err := writeFileAtomic(fp, data); err != nil {
 		return errors.WrapIff(err, "failed to write av state file")
 	}
-	return f.Close()
+	// Keep a backup of the last committed state to recover from a torn write. This is best
+	// effort as the state file itself is already written.
+	if err := writeFileAtomic(backupPath(fp), data); err != nil {
+		logrus.WithError(err).Debug("failed to write the backup of the av state file")
+	}
+	return nil
+}
+
+// writeFileAtomic writes the data to a temporary file and renames it so that the av commands
+// running concurrently (e.g., in another worktree) never read a partially written file. The
+// data is flushed to the disk before the rename so that a crash doesn't leave a torn file.
+func writeFileAtomic(fp string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(fp), filepath.Base(fp)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// CreateTemp creates the file only readable by the owner.
+	_ = f.Chmod(0644)
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), fp); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return nil
 }
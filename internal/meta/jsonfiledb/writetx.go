@@ -5,10 +5,15 @@ import "github.com/aviator-co/av/internal/meta"
 type writeTx struct {
 	db *DB
 	readTx
+	// The branches set (true) or deleted (false) by the transaction, and whether the
+	// repository was set. Only these are written on top of the latest state on commit.
+	changedBranches map[string]bool
+	repositorySet   bool
 }
 
 func (tx *writeTx) SetRepository(repository meta.Repository) {
 	tx.state.RepositoryState = repository
+	tx.repositorySet = true
 }
 
 func (tx *writeTx) SetBranch(branch meta.Branch) {
@@ -16,10 +21,36 @@ func (tx *writeTx) SetBranch(branch meta.Branch) {
 		panic("cannot set branch with empty name")
 	}
 	tx.state.BranchState[branch.Name] = branch
+	tx.markChanged(branch.Name, true)
 }
 
 func (tx *writeTx) DeleteBranch(name string) {
 	delete(tx.state.BranchState, name)
+	tx.markChanged(name, false)
+}
+
+func (tx *writeTx) markChanged(name string, set bool) {
+	if tx.changedBranches == nil {
+		tx.changedBranches = map[string]bool{}
+	}
+	tx.changedBranches[name] = set
+}
+
+// applyTo applies the changes of the transaction to the given state.
+func (tx *writeTx) applyTo(st *state) {
+	if tx.repositorySet {
+		st.RepositoryState = tx.state.RepositoryState
+	}
+	if len(tx.changedBranches) > 0 && st.BranchState == nil {
+		st.BranchState = map[string]meta.Branch{}
+	}
+	for name, set := range tx.changedBranches {
+		if set {
+			st.BranchState[name] = tx.state.BranchState[name]
+		} else {
+			delete(st.BranchState, name)
+		}
+	}
 }
 
 func (tx *writeTx) Abort() {
@@ -35,15 +66,11 @@ func (tx *writeTx) Commit() error {
 	if tx.db == nil {
 		panic("cannot commit transaction: already finalized")
 	}
-	// Always unlock the database even if there is an error.
-	defer tx.db.stateMu.Unlock()
-	err := tx.state.write(tx.db.filepath)
-	if err != nil {
-		return err
-	}
-	*tx.db.state = tx.state
+	db := tx.db
 	tx.db = nil
-	return nil
+	// Always unlock the database even if there is an error.
+	defer db.stateMu.Unlock()
+	return db.commit(tx)
 }
 
 var _ meta.WriteTx = &writeTx{}
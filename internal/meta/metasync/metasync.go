@@ -0,0 +1,447 @@
+// Package metasync shares the av metadata of the branches through a Git ref so that the
+// stacks follow the user across clones of the repository.
+//
+// The branch metadata is stored as a JSON file in the commits of a ref of the user (see Ref),
+// so that the clones of the teammates that share the remote don't mix their stacks. The ref is
+// fetched from and pushed to the remote, and the diverged metadata is merged per branch with
+// the last shared state as the base (see Merge). Only the branches that are pushed are shared.
+//
+// The refs of the other users are fetched too, but only the branch locks (see meta.BranchLock)
+// are taken from them, so that a lock is seen by the teammates who work on the same branches.
+package metasync
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/cleanup"
+	"github.com/sirupsen/logrus"
+)
+
+// The file name of the branch metadata in the commits of Ref.
+const branchesFileName = "branches.json"
+
+// Ref returns the ref that stores the branch metadata of the user, which is named after the
+// user.email config (refs/av/users/<email>/metadata).
+func Ref(repo *git.Repo) (string, error) {
+	email, err := repo.Git("config", "user.email")
+	if err != nil || email == "" {
+		return "", errors.New("syncMetadata needs the user.email config to name the av metadata ref")
+	}
+	return "refs/av/users/" + refComponent(email) + "/metadata", nil
+}
+
+func remoteRef(remote string, ref string) string {
+	return "refs/av/remotes/" + remote + "/" + strings.TrimPrefix(ref, "refs/av/")
+}
+
+// refComponent replaces the characters that can't be in a ref name component.
+func refComponent(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("@.+-_", r):
+			return r
+		}
+		return '-'
+	}, s)
+	s = strings.ReplaceAll(s, "..", "-")
+	s = strings.ReplaceAll(s, "@{", "-")
+	return strings.Trim(s, ".")
+}
+
+// PullResult is the result of Pull.
+type PullResult struct {
+	// The branches that are added from the remote metadata.
+	Added []string
+	// The branches that are updated or deleted by the remote metadata.
+	Updated []string
+	// The branches that are changed both locally and on the remote differently. The local
+	// metadata is kept for them.
+	Conflicts []string
+}
+
+// The refs of the metadata of all the users.
+const usersRefPattern = "refs/av/users/*/metadata"
+
+// Pull fetches the branch metadata from the remote and merges it into the database. The
+// branches that are new to this clone are created from their remote branches. The branches
+// that exist neither locally nor on the remote are not added. The locks of the branches are
+// taken from the metadata of the other users (see mergeLocks).
+func Pull(repo *git.Repo, db meta.DB) (*PullResult, error) {
+	ref, err := Ref(repo)
+	if err != nil {
+		return nil, err
+	}
+	remote := repo.GetPushRemoteName()
+	if _, err := repo.Git(
+		"fetch", remote, "+"+usersRefPattern+":"+remoteRef(remote, usersRefPattern),
+	); err != nil {
+		return nil, errors.WrapIff(err, "failed to fetch the av metadata from %q", remote)
+	}
+	result, err := pullUserMetadata(repo, db, remote, ref)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := mergeLocks(repo, db, remote, ref)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range locked {
+		if !slices.Contains(result.Updated, name) && !slices.Contains(result.Added, name) {
+			result.Updated = append(result.Updated, name)
+		}
+	}
+	sort.Strings(result.Updated)
+	return result, nil
+}
+
+// pullUserMetadata merges the metadata of the user fetched from the remote into the database.
+func pullUserMetadata(repo *git.Repo, db meta.DB, remote string, ref string) (*PullResult, error) {
+	remoteCommit := localRefCommit(repo, remoteRef(remote, ref))
+	if remoteCommit == "" {
+		return &PullResult{}, nil
+	}
+	localCommit := localRefCommit(repo, ref)
+	if localCommit == remoteCommit {
+		return &PullResult{}, nil
+	}
+
+	base := map[string]meta.Branch{}
+	if localCommit != "" {
+		if mergeBase, err := repo.MergeBase(localCommit, remoteCommit); err == nil {
+			if mergeBase == remoteCommit {
+				// The remote has nothing new.
+				return &PullResult{}, nil
+			}
+			base, err = readBranches(repo, mergeBase)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	remoteBranches, err := readBranches(repo, remoteCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+	local := tx.AllBranches()
+	shared := pushedBranches(repo, local)
+	merged, conflicts := Merge(base, shared, remoteBranches)
+
+	result := &PullResult{Conflicts: conflicts}
+	for name, br := range merged {
+		old, ok := local[name]
+		if !ok {
+			if created, err := ensureLocalBranch(repo, remote, name); err != nil {
+				return nil, err
+			} else if !created {
+				delete(merged, name)
+				continue
+			}
+			result.Added = append(result.Added, name)
+		} else if reflect.DeepEqual(old, br) {
+			continue
+		} else {
+			result.Updated = append(result.Updated, name)
+		}
+		tx.SetBranch(br)
+	}
+	for name := range shared {
+		if _, ok := merged[name]; !ok {
+			tx.DeleteBranch(name)
+			result.Updated = append(result.Updated, name)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Updated)
+
+	// Record the merged state on top of both histories so that the next push is a
+	// fast-forward.
+	parents := []string{remoteCommit}
+	if localCommit != "" {
+		parents = append([]string{localCommit}, parents...)
+	}
+	if err := writeCommit(repo, ref, merged, parents, "Merge av metadata from "+remote); err != nil {
+		return nil, err
+	}
+	cu.Cancel()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Push merges the branch metadata on the remote (see Pull) and pushes the metadata of the
+// pushed branches in the database to the remote. The branches that were never pushed stay
+// private to this clone.
+func Push(repo *git.Repo, db meta.DB) error {
+	ref, err := Ref(repo)
+	if err != nil {
+		return err
+	}
+	result, err := Pull(repo, db)
+	if err != nil {
+		return err
+	}
+	for _, name := range result.Conflicts {
+		logrus.WithField("branch", name).
+			Warn("av metadata of the branch was changed on another clone; keeping the local one")
+	}
+
+	branches := pushedBranches(repo, db.ReadTx().AllBranches())
+	localCommit := localRefCommit(repo, ref)
+	var parents []string
+	if localCommit != "" {
+		current, err := readBranches(repo, localCommit)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(current, branches) {
+			parents = []string{localCommit}
+			localCommit = ""
+		}
+	}
+	if localCommit == "" {
+		if err := writeCommit(repo, ref, branches, parents, "Update av metadata"); err != nil {
+			return err
+		}
+	}
+
+	remote := repo.GetPushRemoteName()
+	if _, err := repo.Git("push", remote, ref+":"+ref); err != nil {
+		return errors.WrapIff(err, "failed to push the av metadata to %q", remote)
+	}
+	if _, err := repo.Git("update-ref", remoteRef(remote, ref), ref); err != nil {
+		return err
+	}
+	return nil
+}
+
+// mergeLocks takes the locks of the branches in the database from the metadata of the other
+// users fetched from the remote. The metadata of a user is authoritative only for the locks
+// owned by the user: a newer lock of the user is taken, and a lock of the user that is no longer
+// in their metadata is released. The names of the changed branches are returned.
+func mergeLocks(repo *git.Repo, db meta.DB, remote string, ownRef string) ([]string, error) {
+	prefix := remoteRef(remote, "refs/av/users/")
+	out, err := repo.Git("for-each-ref", "--format=%(refname)", prefix)
+	if err != nil {
+		return nil, err
+	}
+	tx := db.WriteTx()
+	cu := cleanup.New(func() { tx.Abort() })
+	defer cu.Cleanup()
+	local := tx.AllBranches()
+	changed := map[string]bool{}
+	for _, userRef := range strings.Split(out, "\n") {
+		if userRef == "" || userRef == remoteRef(remote, ownRef) ||
+			!strings.HasSuffix(userRef, "/metadata") {
+			continue
+		}
+		user := strings.TrimSuffix(strings.TrimPrefix(userRef, prefix), "/metadata")
+		ownedByUser := func(lock *meta.BranchLock) bool {
+			return lock != nil && strings.EqualFold(refComponent(lock.Owner), user)
+		}
+		branches, err := readBranches(repo, userRef)
+		if err != nil {
+			logrus.WithError(err).WithField("ref", userRef).Warn("skipping the av metadata of another user")
+			continue
+		}
+		for name, br := range local {
+			other := branches[name].Lock
+			switch {
+			case ownedByUser(other) &&
+				(br.Lock == nil || br.Lock.LockedAt.Before(other.LockedAt)):
+				br.Lock = other
+			case ownedByUser(br.Lock) && !ownedByUser(other):
+				br.Lock = nil
+			default:
+				continue
+			}
+			local[name] = br
+			tx.SetBranch(br)
+			changed[name] = true
+		}
+	}
+	cu.Cancel()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	var ret []string
+	for name := range changed {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// Merge merges the local and the remote branch metadata that are diverged from the base. A
+// branch that is changed (or deleted) only on one side takes that change. A branch that is
+// changed on both sides differently is a conflict, and the local metadata is kept (the
+// remote one is taken if the branch is deleted locally). The names of the conflicted
+// branches are returned in sorted order.
+func Merge(
+	base, local, remote map[string]meta.Branch,
+) (map[string]meta.Branch, []string) {
+	names := map[string]bool{}
+	for _, m := range []map[string]meta.Branch{base, local, remote} {
+		for name := range m {
+			names[name] = true
+		}
+	}
+
+	merged := map[string]meta.Branch{}
+	var conflicts []string
+	for name := range names {
+		b, bok := base[name]
+		l, lok := local[name]
+		r, rok := remote[name]
+		sameLR := lok == rok && reflect.DeepEqual(l, r)
+		localUnchanged := lok == bok && reflect.DeepEqual(l, b)
+		remoteUnchanged := rok == bok && reflect.DeepEqual(r, b)
+		switch {
+		case sameLR || remoteUnchanged:
+			if lok {
+				merged[name] = l
+			}
+		case localUnchanged:
+			if rok {
+				merged[name] = r
+			}
+		default:
+			conflicts = append(conflicts, name)
+			if lok {
+				merged[name] = l
+			} else {
+				merged[name] = r
+			}
+		}
+	}
+	sort.Strings(conflicts)
+	return merged, conflicts
+}
+
+// ensureLocalBranch creates the local branch from its remote branch if it doesn't exist. It
+// returns false if the branch exists neither locally nor on the remote.
+func ensureLocalBranch(repo *git.Repo, remote string, name string) (bool, error) {
+	if exists, err := repo.DoesBranchExist(name); err != nil {
+		return false, err
+	} else if exists {
+		return true, nil
+	}
+	remoteBranch := git.RemoteTrackingRef(remote, name)
+	if exists, _ := repo.DoesRefExist(remoteBranch); !exists {
+		// The branch may have been pushed from another clone after the last fetch.
+		out, err := repo.Run(&git.RunOpts{
+			Args: []string{"fetch", remote, "+refs/heads/" + name + ":" + remoteBranch},
+		})
+		if err != nil {
+			return false, err
+		}
+		if out.ExitCode != 0 {
+			logrus.WithField("branch", name).
+				Debug("skipping av metadata of a branch that doesn't exist locally nor on the remote")
+			return false, nil
+		}
+	}
+	if _, err := repo.Git("branch", "--no-track", name, remoteBranch); err != nil {
+		return false, errors.WrapIff(err, "failed to create branch %q", name)
+	}
+	return true, nil
+}
+
+// pushedBranches returns the branches that have their remote-tracking branches on their push
+// remotes, which are the ones whose metadata is shared.
+func pushedBranches(repo *git.Repo, branches map[string]meta.Branch) map[string]meta.Branch {
+	ret := map[string]meta.Branch{}
+	for name, br := range branches {
+		if exists, _ := repo.DoesRefExist(repo.PushRemoteBranchRef(name)); exists {
+			ret[name] = br
+		}
+	}
+	return ret
+}
+
+// localRefCommit returns the commit of the ref, or an empty string if it doesn't exist.
+func localRefCommit(repo *git.Repo, ref string) string {
+	commit, err := repo.RevParse(&git.RevParse{Rev: ref})
+	if err != nil {
+		return ""
+	}
+	return commit
+}
+
+func readBranches(repo *git.Repo, commit string) (map[string]meta.Branch, error) {
+	out, err := repo.Run(&git.RunOpts{
+		Args:      []string{"cat-file", "blob", commit + ":" + branchesFileName},
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to read the av metadata at %s", commit)
+	}
+	branches := map[string]meta.Branch{}
+	if err := json.Unmarshal(out.Stdout, &branches); err != nil {
+		return nil, errors.WrapIff(err, "failed to read the av metadata at %s", commit)
+	}
+	for name, br := range branches {
+		br.Name = name
+		branches[name] = br
+	}
+	return branches, nil
+}
+
+// writeCommit writes the branch metadata as a new commit with the given parents and updates
+// the ref to the commit.
+func writeCommit(
+	repo *git.Repo,
+	ref string,
+	branches map[string]meta.Branch,
+	parents []string,
+	message string,
+) error {
+	data, err := json.MarshalIndent(branches, "", "  ")
+	if err != nil {
+		return err
+	}
+	blob, err := repo.Run(&git.RunOpts{
+		Args:      []string{"hash-object", "-w", "--stdin"},
+		Stdin:     bytes.NewReader(data),
+		ExitError: true,
+	})
+	if err != nil {
+		return errors.WrapIf(err, "failed to write the av metadata")
+	}
+	tree, err := repo.Run(&git.RunOpts{
+		Args: []string{"mktree"},
+		Stdin: strings.NewReader(
+			"100644 blob " + strings.TrimSpace(string(blob.Stdout)) + "\t" + branchesFileName + "\n",
+		),
+		ExitError: true,
+	})
+	if err != nil {
+		return errors.WrapIf(err, "failed to write the av metadata")
+	}
+	args := []string{"commit-tree", strings.TrimSpace(string(tree.Stdout)), "-m", message}
+	for _, parent := range parents {
+		args = append(args, "-p", parent)
+	}
+	commit, err := repo.Git(args...)
+	if err != nil {
+		return errors.WrapIf(err, "failed to write the av metadata")
+	}
+	if _, err := repo.Git("update-ref", ref, commit); err != nil {
+		return err
+	}
+	return nil
+}
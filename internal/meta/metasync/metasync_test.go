@@ -0,0 +1,225 @@
+package metasync_test
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/meta/jsonfiledb"
+	"github.com/aviator-co/av/internal/meta/metasync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	trunk := meta.BranchState{Name: "main", Trunk: true}
+	branch := func(name string, parent string) meta.Branch {
+		if parent == "main" {
+			return meta.Branch{Name: name, Parent: trunk}
+		}
+		return meta.Branch{Name: name, Parent: meta.BranchState{Name: parent}}
+	}
+
+	base := map[string]meta.Branch{
+		"unchanged":      branch("unchanged", "main"),
+		"local-changed":  branch("local-changed", "main"),
+		"remote-changed": branch("remote-changed", "main"),
+		"both-changed":   branch("both-changed", "main"),
+		"local-deleted":  branch("local-deleted", "main"),
+		"remote-deleted": branch("remote-deleted", "main"),
+	}
+	local := map[string]meta.Branch{
+		"unchanged":      branch("unchanged", "main"),
+		"local-changed":  branch("local-changed", "unchanged"),
+		"remote-changed": branch("remote-changed", "main"),
+		"both-changed":   branch("both-changed", "local-changed"),
+		"remote-deleted": branch("remote-deleted", "main"),
+		"local-added":    branch("local-added", "main"),
+	}
+	remote := map[string]meta.Branch{
+		"unchanged":      branch("unchanged", "main"),
+		"local-changed":  branch("local-changed", "main"),
+		"remote-changed": branch("remote-changed", "unchanged"),
+		"both-changed":   branch("both-changed", "remote-changed"),
+		"local-deleted":  branch("local-deleted", "main"),
+		"remote-added":   branch("remote-added", "main"),
+	}
+
+	merged, conflicts := metasync.Merge(base, local, remote)
+	require.Equal(t, map[string]meta.Branch{
+		"unchanged":      branch("unchanged", "main"),
+		"local-changed":  branch("local-changed", "unchanged"),
+		"remote-changed": branch("remote-changed", "unchanged"),
+		"both-changed":   branch("both-changed", "local-changed"),
+		"local-added":    branch("local-added", "main"),
+		"remote-added":   branch("remote-added", "main"),
+	}, merged)
+	require.Equal(t, []string{"both-changed"}, conflicts)
+}
+
+func TestPushPull(t *testing.T) {
+	repoA := gittest.NewTempRepo(t)
+	repoA.Git(t, "checkout", "-b", "one")
+	repoA.CommitFile(t, "one.txt", "one")
+	repoA.Git(t, "push", "origin", "one")
+	dbA := repoA.OpenDB(t)
+	setBranch(t, dbA, meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	require.NoError(t, metasync.Push(repoA.AsAvGitRepo(), dbA))
+
+	// A fresh clone gets the branch and its metadata.
+	remoteURL := strings.TrimSpace(repoA.Git(t, "remote", "get-url", "origin"))
+	dirB := filepath.Join(t.TempDir(), "clone")
+	require.NoError(t, exec.Command("git", "clone", remoteURL, dirB).Run())
+	for _, c := range [][]string{{"user.name", "av-test"}, {"user.email", "av-test@nonexistent"}} {
+		require.NoError(t, exec.Command("git", "-C", dirB, "config", c[0], c[1]).Run())
+	}
+	repoB, err := git.OpenRepo(dirB, filepath.Join(dirB, ".git"))
+	require.NoError(t, err)
+	dbB, _, err := jsonfiledb.OpenPath(filepath.Join(dirB, ".git", "av", "av.db"))
+	require.NoError(t, err)
+	result, err := metasync.Pull(repoB, dbB)
+	require.NoError(t, err)
+	require.Equal(t, []string{"one"}, result.Added)
+	exists, err := repoB.DoesBranchExist("one")
+	require.NoError(t, err)
+	require.True(t, exists)
+	one, ok := dbB.ReadTx().Branch("one")
+	require.True(t, ok)
+	require.Equal(t, "main", one.Parent.Name)
+
+	// Diverged changes are merged, and the local one wins a conflict.
+	one.PreviewURL = "https://b.example.com"
+	setBranch(t, dbB, one)
+	require.NoError(t, metasync.Push(repoB, dbB))
+
+	oneA, _ := dbA.ReadTx().Branch("one")
+	oneA.PreviewURL = "https://a.example.com"
+	setBranch(t, dbA, oneA)
+	repoA.Git(t, "checkout", "-b", "two")
+	repoA.CommitFile(t, "two.txt", "two")
+	repoA.Git(t, "push", "origin", "two")
+	setBranch(t, dbA, meta.Branch{Name: "two", Parent: meta.BranchState{Name: "one"}})
+	result, err = metasync.Pull(repoA.AsAvGitRepo(), dbA)
+	require.NoError(t, err)
+	require.Equal(t, []string{"one"}, result.Conflicts)
+	require.NoError(t, metasync.Push(repoA.AsAvGitRepo(), dbA))
+
+	result, err = metasync.Pull(repoB, dbB)
+	require.NoError(t, err)
+	require.Equal(t, []string{"two"}, result.Added)
+	require.Empty(t, result.Conflicts)
+	one, _ = dbB.ReadTx().Branch("one")
+	require.Equal(t, "https://a.example.com", one.PreviewURL)
+}
+
+func TestPushPullPerUser(t *testing.T) {
+	repoA := gittest.NewTempRepo(t)
+	repoA.Git(t, "checkout", "-b", "one")
+	repoA.CommitFile(t, "one.txt", "one")
+	repoA.Git(t, "push", "origin", "one")
+	repoA.Git(t, "checkout", "-b", "private")
+	repoA.CommitFile(t, "private.txt", "private")
+	dbA := repoA.OpenDB(t)
+	setBranch(t, dbA, meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	setBranch(t, dbA, meta.Branch{Name: "private", Parent: meta.BranchState{Name: "one"}})
+	require.NoError(t, metasync.Push(repoA.AsAvGitRepo(), dbA))
+
+	// The branch that was never pushed is not shared.
+	remoteRefs := repoA.Git(t, "ls-remote", "origin", "refs/av/*")
+	require.Contains(t, remoteRefs, "refs/av/users/av-test@nonexistent/metadata")
+	require.Len(t, strings.Split(strings.TrimSpace(remoteRefs), "\n"), 1)
+	require.NotContains(
+		t,
+		repoA.Git(t, "cat-file", "blob", "refs/av/users/av-test@nonexistent/metadata:branches.json"),
+		"private",
+	)
+
+	// A teammate's clone neither gets nor publishes the metadata of the user.
+	remoteURL := strings.TrimSpace(repoA.Git(t, "remote", "get-url", "origin"))
+	dirB := filepath.Join(t.TempDir(), "clone")
+	require.NoError(t, exec.Command("git", "clone", remoteURL, dirB).Run())
+	for _, c := range [][]string{{"user.name", "teammate"}, {"user.email", "teammate@nonexistent"}} {
+		require.NoError(t, exec.Command("git", "-C", dirB, "config", c[0], c[1]).Run())
+	}
+	repoB, err := git.OpenRepo(dirB, filepath.Join(dirB, ".git"))
+	require.NoError(t, err)
+	dbB, _, err := jsonfiledb.OpenPath(filepath.Join(dirB, ".git", "av", "av.db"))
+	require.NoError(t, err)
+	result, err := metasync.Pull(repoB, dbB)
+	require.NoError(t, err)
+	require.Empty(t, result.Added)
+	exists, err := repoB.DoesBranchExist("one")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	require.NoError(t, exec.Command("git", "-C", dirB, "checkout", "-b", "two", "origin/main").Run())
+	require.NoError(t, exec.Command("git", "-C", dirB, "push", "origin", "two").Run())
+	setBranch(t, dbB, meta.Branch{Name: "two", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	require.NoError(t, metasync.Push(repoB, dbB))
+	_, ok := dbB.ReadTx().Branch("one")
+	require.False(t, ok)
+
+	result, err = metasync.Pull(repoA.AsAvGitRepo(), dbA)
+	require.NoError(t, err)
+	require.Empty(t, result.Added)
+	require.Empty(t, result.Updated)
+	_, ok = dbA.ReadTx().Branch("two")
+	require.False(t, ok)
+	_, ok = dbA.ReadTx().Branch("private")
+	require.True(t, ok)
+}
+
+func TestPullLocks(t *testing.T) {
+	repoA := gittest.NewTempRepo(t)
+	repoA.Git(t, "checkout", "-b", "one")
+	repoA.CommitFile(t, "one.txt", "one")
+	repoA.Git(t, "push", "origin", "one")
+	dbA := repoA.OpenDB(t)
+	one := meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}}
+	setBranch(t, dbA, one)
+
+	remoteURL := strings.TrimSpace(repoA.Git(t, "remote", "get-url", "origin"))
+	dirB := filepath.Join(t.TempDir(), "clone")
+	require.NoError(t, exec.Command("git", "clone", remoteURL, dirB).Run())
+	for _, c := range [][]string{{"user.name", "teammate"}, {"user.email", "teammate@nonexistent"}} {
+		require.NoError(t, exec.Command("git", "-C", dirB, "config", c[0], c[1]).Run())
+	}
+	repoB, err := git.OpenRepo(dirB, filepath.Join(dirB, ".git"))
+	require.NoError(t, err)
+	dbB, _, err := jsonfiledb.OpenPath(filepath.Join(dirB, ".git", "av", "av.db"))
+	require.NoError(t, err)
+	setBranch(t, dbB, one)
+
+	// The teammate sees the lock of the user.
+	locked := one
+	locked.Lock = &meta.BranchLock{
+		Owner:    "av-test@nonexistent",
+		LockedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	setBranch(t, dbA, locked)
+	require.NoError(t, metasync.Push(repoA.AsAvGitRepo(), dbA))
+	result, err := metasync.Pull(repoB, dbB)
+	require.NoError(t, err)
+	require.Equal(t, []string{"one"}, result.Updated)
+	br, _ := dbB.ReadTx().Branch("one")
+	require.Equal(t, locked.Lock, br.Lock)
+
+	// The lock is released when the user unlocks the branch.
+	setBranch(t, dbA, one)
+	require.NoError(t, metasync.Push(repoA.AsAvGitRepo(), dbA))
+	result, err = metasync.Pull(repoB, dbB)
+	require.NoError(t, err)
+	require.Equal(t, []string{"one"}, result.Updated)
+	br, _ = dbB.ReadTx().Branch("one")
+	require.Nil(t, br.Lock)
+}
+
+func setBranch(t *testing.T, db meta.DB, branch meta.Branch) {
+	tx := db.WriteTx()
+	tx.SetBranch(branch)
+	require.NoError(t, tx.Commit())
+}
@@ -13,7 +13,7 @@ import (
 // ReadRepository reads repository metadata from the git repo.
 // Returns the metadata and a boolean indicating if the metadata was found.
 func ReadRepository(repo *git.Repo) (meta.Repository, error) {
-	metaPath := filepath.Join(repo.Dir(), ".git", "av", "repo-metadata.json")
+	metaPath := filepath.Join(repo.AvDir(), "repo-metadata.json")
 	data, err := os.ReadFile(metaPath)
 	if err != nil {
 		return meta.Repository{}, errors.WrapIf(err, "failed to read the repository metadata")
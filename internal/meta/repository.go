@@ -1,9 +1,11 @@
 package meta
 
 type Repository struct {
-	// The GitHub (GraphQL) ID of the repository (e.g., R_kgDOHMmHmg).
+	// The GitHub (GraphQL) ID of the repository (e.g., R_kgDOHMmHmg), or the numeric project
+	// ID on GitLab.
 	ID string `json:"id"`
-	// The owner of the repository (e.g., aviator-co)
+	// The owner of the repository (e.g., aviator-co). On GitLab, this is the full path of the
+	// namespace (e.g., my-group/my-subgroup).
 	Owner string `json:"owner"`
 	// The name of the repository (e.g., av)
 	Name string `json:"name"`
@@ -0,0 +1,163 @@
+// Package notify posts messages about stacks to a chat webhook (Slack or Microsoft Teams).
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/stackutils"
+)
+
+const (
+	FormatSlack = "slack"
+	FormatTeams = "teams"
+)
+
+type EventKind int
+
+const (
+	// EventSubmit is sent when the pull requests of a stack are created or updated.
+	EventSubmit EventKind = iota
+	// EventMerge is sent when a pull request in a stack is merged.
+	EventMerge
+)
+
+// Event is a notification about a stack.
+type Event struct {
+	Kind EventKind
+	// The branch that triggered the event. For EventMerge, this is the merged branch.
+	Branch     string
+	Repository meta.Repository
+	Stack      []StackEntry
+}
+
+// StackEntry is a branch in the stack, listed in depth-first order from the trunk.
+type StackEntry struct {
+	Name string
+	// The depth of the branch in the stack tree. The trunk branch has depth 0.
+	Depth       int
+	PullRequest *meta.PullRequest
+	Merged      bool
+}
+
+// Enabled returns true if a notification webhook is configured.
+func Enabled() bool {
+	return config.Av.Notification.WebhookURL != ""
+}
+
+// NewStackEvent creates an event for the stack that contains the given branch.
+func NewStackEvent(tx meta.ReadTx, kind EventKind, branch string) (*Event, error) {
+	root, err := stackutils.BuildStackTreeCurrentStack(tx, branch, true)
+	if err != nil {
+		return nil, err
+	}
+	event := &Event{
+		Kind:       kind,
+		Branch:     branch,
+		Repository: tx.Repository(),
+	}
+	var visit func(node *stackutils.StackTreeNode, depth int)
+	visit = func(node *stackutils.StackTreeNode, depth int) {
+		entry := StackEntry{Name: node.Branch.BranchName, Depth: depth}
+		if br, ok := tx.Branch(node.Branch.BranchName); ok {
+			entry.PullRequest = br.PullRequest
+			entry.Merged = br.MergeCommit != ""
+		}
+		event.Stack = append(event.Stack, entry)
+		for _, child := range node.Children {
+			visit(child, depth+1)
+		}
+	}
+	visit(root, 0)
+	return event, nil
+}
+
+// Send posts the event to the configured webhook. It's a no-op if no webhook is configured.
+func Send(ctx context.Context, event *Event) error {
+	if !Enabled() {
+		return nil
+	}
+	text, err := formatEvent(config.Av.Notification.Format, event)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return errors.WrapIf(err, "failed to encode the notification payload")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, config.Av.Notification.WebhookURL, bytes.NewReader(body),
+	)
+	if err != nil {
+		return errors.WrapIf(err, "failed to create the notification request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.WrapIf(err, "failed to send the notification")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("notification webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func formatEvent(format string, event *Event) (string, error) {
+	var link func(pr *meta.PullRequest) string
+	var indent string
+	switch format {
+	case "", FormatSlack:
+		link = func(pr *meta.PullRequest) string {
+			return fmt.Sprintf("<%s|#%d>", pr.Permalink, pr.Number)
+		}
+		indent = "    "
+	case FormatTeams:
+		link = func(pr *meta.PullRequest) string {
+			return fmt.Sprintf("[#%d](%s)", pr.Number, pr.Permalink)
+		}
+		indent = "  "
+	default:
+		return "", errors.Errorf(
+			"unknown notification format %q (expected %q or %q)", format, FormatSlack, FormatTeams,
+		)
+	}
+
+	repoName := event.Repository.Owner + "/" + event.Repository.Name
+	sb := strings.Builder{}
+	switch event.Kind {
+	case EventSubmit:
+		fmt.Fprintf(&sb, "Stack submitted in %s", repoName)
+	case EventMerge:
+		fmt.Fprintf(&sb, "Pull request merged in %s", repoName)
+		for _, entry := range event.Stack {
+			if entry.Name == event.Branch && entry.PullRequest != nil {
+				fmt.Fprintf(&sb, ": %s", link(entry.PullRequest))
+			}
+		}
+	}
+	sb.WriteString("\n")
+	for _, entry := range event.Stack {
+		sb.WriteString(strings.Repeat(indent, entry.Depth))
+		fmt.Fprintf(&sb, "- `%s`", entry.Name)
+		if entry.PullRequest != nil {
+			sb.WriteString(" " + link(entry.PullRequest))
+		}
+		if entry.Merged {
+			sb.WriteString(" (merged)")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
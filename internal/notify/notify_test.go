@@ -0,0 +1,60 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/meta/jsonfiledb"
+	"github.com/aviator-co/av/internal/notify"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	db, _, err := jsonfiledb.OpenPath(t.TempDir() + "/db.json")
+	require.NoError(t, err)
+	tx := db.WriteTx()
+	tx.SetRepository(meta.Repository{Owner: "aviator-co", Name: "av"})
+	tx.SetBranch(meta.Branch{
+		Name:        "one",
+		Parent:      meta.BranchState{Name: "main", Trunk: true},
+		PullRequest: &meta.PullRequest{Number: 1, Permalink: "https://github.com/aviator-co/av/pull/1"},
+		MergeCommit: "abc",
+	})
+	tx.SetBranch(meta.Branch{
+		Name:        "two",
+		Parent:      meta.BranchState{Name: "one"},
+		PullRequest: &meta.PullRequest{Number: 2, Permalink: "https://github.com/aviator-co/av/pull/2"},
+	})
+	require.NoError(t, tx.Commit())
+
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	orig := config.Av.Notification
+	defer func() { config.Av.Notification = orig }()
+	config.Av.Notification.WebhookURL = server.URL
+
+	event, err := notify.NewStackEvent(db.ReadTx(), notify.EventMerge, "one")
+	require.NoError(t, err)
+
+	require.NoError(t, notify.Send(context.Background(), event))
+	require.Equal(t, "Pull request merged in aviator-co/av: <https://github.com/aviator-co/av/pull/1|#1>\n"+
+		"- `main`\n"+
+		"    - `one` <https://github.com/aviator-co/av/pull/1|#1> (merged)\n"+
+		"        - `two` <https://github.com/aviator-co/av/pull/2|#2>", payload["text"])
+
+	config.Av.Notification.Format = notify.FormatTeams
+	require.NoError(t, notify.Send(context.Background(), event))
+	require.Equal(t, "Pull request merged in aviator-co/av: [#1](https://github.com/aviator-co/av/pull/1)\n"+
+		"- `main`\n"+
+		"  - `one` [#1](https://github.com/aviator-co/av/pull/1) (merged)\n"+
+		"    - `two` [#2](https://github.com/aviator-co/av/pull/2)", payload["text"])
+}
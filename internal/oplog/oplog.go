@@ -0,0 +1,269 @@
+// Package oplog records the state of the repository before the av operations that rewrite
+// branches (e.g., av sync and av reorder) so that they can be undone with av undo.
+package oplog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/sirupsen/logrus"
+)
+
+// The maximum number of the operations kept in the log. Older operations are discarded.
+const maxEntries = 20
+
+const fileName = "oplog.json"
+
+// Entry is a recorded operation. It holds the state of the repository before the operation.
+type Entry struct {
+	// The command that started the operation (e.g., "av sync").
+	Command string    `json:"command"`
+	Time    time.Time `json:"time"`
+	// The branch that was checked out. Empty if HEAD was detached.
+	CurrentBranch string `json:"currentBranch,omitempty"`
+	// The commits of the branches tracked by av keyed by the branch name. The trunk branches
+	// and the branches not tracked by av are not recorded.
+	Refs map[string]string `json:"refs"`
+	// The av metadata of the branches keyed by the branch name.
+	Branches map[string]meta.Branch `json:"branches"`
+}
+
+func logPath(repo *git.Repo) string {
+	return filepath.Join(repo.AvDir(), fileName)
+}
+
+// Read returns the recorded operations, oldest first.
+func Read(repo *git.Repo) ([]Entry, error) {
+	bs, err := os.ReadFile(logPath(repo))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(bs, &entries); err != nil {
+		return nil, errors.WrapIff(err, "failed to read the av operation log %q", logPath(repo))
+	}
+	return entries, nil
+}
+
+func write(repo *git.Repo, entries []Entry) error {
+	if len(entries) > maxEntries {
+		entries = entries[len(entries)-maxEntries:]
+	}
+	bs, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WrapIf(
+		os.WriteFile(logPath(repo), bs, 0644),
+		"failed to write the av operation log",
+	)
+}
+
+// Record records the current state of the repository as the state before the given
+// operation.
+func Record(repo *git.Repo, tx meta.ReadTx, command string) error {
+	refs, err := repo.ListRefs(&git.ListRefs{Patterns: []string{"refs/heads/"}})
+	if err != nil {
+		return err
+	}
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil && !errors.Is(err, git.ErrDetachedHEAD) {
+		return err
+	}
+	entry := Entry{
+		Command:       command,
+		Time:          time.Now(),
+		CurrentBranch: currentBranch,
+		Refs:          map[string]string{},
+		Branches:      tx.AllBranches(),
+	}
+	for _, ref := range refs {
+		name := strings.TrimPrefix(ref.Name, "refs/heads/")
+		if _, ok := entry.Branches[name]; ok {
+			entry.Refs[name] = ref.Oid
+		}
+	}
+
+	entries, err := Read(repo)
+	if err != nil {
+		return err
+	}
+	logrus.WithField("command", command).Debug("recording operation to the av operation log")
+	return write(repo, append(entries, entry))
+}
+
+// Undo restores the state of the repository before the last n operations and removes them
+// from the log. The restored operations are returned, newest first.
+//
+// The branches are reset to the recorded commits and the av metadata is replaced with the
+// recorded metadata. The branches created after the recorded state are kept in Git but are no
+// longer tracked by av.
+func Undo(repo *git.Repo, db meta.DB, n int) ([]Entry, error) {
+	entries, err := Read(repo)
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, errors.New("the number of operations to undo must be positive")
+	}
+	if len(entries) < n {
+		return nil, errors.Errorf(
+			"cannot undo %d operation(s): only %d operation(s) are recorded", n, len(entries),
+		)
+	}
+	target := entries[len(entries)-n]
+
+	// Detach HEAD so that the checked out branch can be reset without touching the working
+	// tree. The recorded branch is checked out at the end.
+	if err := repo.Detach(); err != nil {
+		return nil, err
+	}
+	for name, oid := range target.Refs {
+		if err := repo.UpdateRef(&git.UpdateRef{
+			Ref:          "refs/heads/" + name,
+			New:          oid,
+			CreateReflog: true,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	tx := db.WriteTx()
+	defer tx.Abort()
+	for name := range tx.AllBranches() {
+		if _, ok := target.Branches[name]; !ok {
+			tx.DeleteBranch(name)
+		}
+	}
+	for _, branch := range target.Branches {
+		tx.SetBranch(branch)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if target.CurrentBranch != "" {
+		if _, err := repo.CheckoutBranch(&git.CheckoutBranch{Name: target.CurrentBranch}); err != nil {
+			return nil, err
+		}
+	}
+
+	undone := make([]Entry, 0, n)
+	for i := len(entries) - 1; i >= len(entries)-n; i-- {
+		undone = append(undone, entries[i])
+	}
+	if err := write(repo, entries[:len(entries)-n]); err != nil {
+		return nil, err
+	}
+	return undone, nil
+}
+
+// Snapshot is the state of the branches tracked by av at a point in the past.
+type Snapshot struct {
+	// The operation that the state was recorded before. Nil if the state is reconstructed from
+	// the current av metadata.
+	Before *Entry
+	// The branch that was checked out, if known.
+	CurrentBranch string
+	// The commits of the branches keyed by the branch name. A branch is missing if its commit
+	// is unknown (e.g., a deleted branch without a recorded commit).
+	Refs map[string]string
+	// The av metadata of the branches keyed by the branch name.
+	Branches map[string]meta.Branch
+}
+
+// SnapshotBefore returns the state before the n-th most recent operation (1 is the last one, as
+// numbered by av undo --list).
+func SnapshotBefore(repo *git.Repo, n int) (*Snapshot, error) {
+	entries, err := Read(repo)
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > len(entries) {
+		return nil, errors.Errorf(
+			"operation %d is not recorded (%d operation(s) are recorded; see av undo --list)",
+			n, len(entries),
+		)
+	}
+	entry := entries[len(entries)-n]
+	return &Snapshot{
+		Before:        &entry,
+		CurrentBranch: entry.CurrentBranch,
+		Refs:          entry.Refs,
+		Branches:      entry.Branches,
+	}, nil
+}
+
+// SnapshotAt reconstructs the state at the given time. The av metadata is taken from the first
+// operation recorded after the time (or from the current metadata if there is none), and the
+// commits of the branches are looked up in the reflogs of the branches. The branches created
+// after the time (and their children) are excluded.
+func SnapshotAt(repo *git.Repo, tx meta.ReadTx, at time.Time) (*Snapshot, error) {
+	entries, err := Read(repo)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Snapshot{Branches: tx.AllBranches(), Refs: map[string]string{}}
+	recordedRefs := map[string]string{}
+	for i := range entries {
+		if entries[i].Time.After(at) {
+			ret.Before = &entries[i]
+			ret.CurrentBranch = entries[i].CurrentBranch
+			ret.Branches = entries[i].Branches
+			recordedRefs = entries[i].Refs
+			break
+		}
+	}
+
+	branches := map[string]meta.Branch{}
+	for name, branch := range ret.Branches {
+		branches[name] = branch
+		out, err := repo.Run(&git.RunOpts{
+			Args: []string{
+				"rev-parse", "--verify",
+				"refs/heads/" + name + "@{" + at.Format("2006-01-02 15:04:05 -0700") + "}",
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if out.ExitCode != 0 {
+			// The branch has no reflog (e.g., it has been deleted since).
+			if oid, ok := recordedRefs[name]; ok {
+				ret.Refs[name] = oid
+			}
+			continue
+		}
+		if strings.Contains(string(out.Stderr), "only goes back to") {
+			// The reflog starts after the time, so the branch didn't exist yet.
+			delete(branches, name)
+			continue
+		}
+		ret.Refs[name] = strings.TrimSpace(string(out.Stdout))
+	}
+	// Drop the children of the branches that didn't exist.
+	for removed := true; removed; {
+		removed = false
+		for name, branch := range branches {
+			if _, ok := branches[branch.Parent.Name]; !branch.Parent.Trunk && !ok {
+				delete(branches, name)
+				removed = true
+			}
+		}
+	}
+	for name := range ret.Refs {
+		if _, ok := branches[name]; !ok {
+			delete(ret.Refs, name)
+		}
+	}
+	ret.Branches = branches
+	return ret, nil
+}
@@ -0,0 +1,389 @@
+// Package query implements the query language of `av query` that selects branches by their
+// metadata and pull request state.
+//
+// A query is a boolean expression that is evaluated for each branch managed by av:
+//
+//	expr     = or
+//	or       = and { "||" and }
+//	and      = unary { "&&" unary }
+//	unary    = "!" unary | primary
+//	primary  = "(" expr ")" | function "(" word ")" | word [ ("=" | "!=") word ]
+//
+// A bare word is a predicate (e.g., "has-pr"), a word followed by "=" or "!=" compares a
+// field (e.g., "author=me"), and a function selects the branches related to the given branch
+// (e.g., "descendants(feature-x)"). Words can be quoted with double quotes.
+package query
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/meta"
+)
+
+// Env provides the information to evaluate the queries against.
+type Env struct {
+	Tx meta.ReadTx
+	// Additional predicates (e.g., "needs-restack") that need more than the metadata. They are
+	// only called for the branches that the query needs to check.
+	Predicates map[string]func(branch string) (bool, error)
+	// Author returns the author ("Name <email>") of the head commit of the branch.
+	Author func(branch string) (string, error)
+	// The email address of the current user, matched by "author=me".
+	Me string
+}
+
+// Expr is a parsed query.
+type Expr interface {
+	eval(env *Env, branch meta.Branch) (bool, error)
+}
+
+// Select returns the branches that match the query in the stack order (parents first).
+func Select(expr Expr, env *Env) ([]string, error) {
+	var ret []string
+	for _, name := range stackOrder(env.Tx) {
+		branch, _ := env.Tx.Branch(name)
+		ok, err := expr.eval(env, branch)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ret = append(ret, name)
+		}
+	}
+	return ret, nil
+}
+
+// stackOrder returns all the branches in the depth-first order of the stacks.
+func stackOrder(tx meta.ReadTx) []string {
+	trunks := map[string]bool{}
+	for _, branch := range tx.AllBranches() {
+		if branch.Parent.Trunk {
+			trunks[branch.Parent.Name] = true
+		}
+	}
+	var names []string
+	for trunk := range trunks {
+		names = append(names, trunk)
+	}
+	sort.Strings(names)
+	var ret []string
+	for _, trunk := range names {
+		ret = append(ret, meta.SubsequentBranches(tx, trunk)...)
+	}
+	return ret
+}
+
+type notExpr struct{ expr Expr }
+
+func (e notExpr) eval(env *Env, branch meta.Branch) (bool, error) {
+	ok, err := e.expr.eval(env, branch)
+	return !ok, err
+}
+
+type binaryExpr struct {
+	and         bool
+	left, right Expr
+}
+
+func (e binaryExpr) eval(env *Env, branch meta.Branch) (bool, error) {
+	ok, err := e.left.eval(env, branch)
+	if err != nil {
+		return false, err
+	}
+	// Short-circuit so that the expensive predicates are evaluated only when needed.
+	if ok != e.and {
+		return ok, nil
+	}
+	return e.right.eval(env, branch)
+}
+
+type predicateExpr struct{ name string }
+
+func (e predicateExpr) eval(env *Env, branch meta.Branch) (bool, error) {
+	switch e.name {
+	case "has-pr":
+		return branch.PullRequest != nil, nil
+	case "merged":
+		return branch.MergeCommit != "" ||
+			(branch.PullRequest != nil && branch.PullRequest.State == "MERGED"), nil
+	case "pinned":
+		return branch.IsPinned(), nil
+	case "root":
+		return branch.Parent.Trunk, nil
+	case "leaf":
+		return len(meta.ChildrenNames(env.Tx, branch.Name)) == 0, nil
+	}
+	if fn, ok := env.Predicates[e.name]; ok {
+		return fn(branch.Name)
+	}
+	return false, errors.Errorf("unknown predicate %q", e.name)
+}
+
+type fieldExpr struct {
+	field, value string
+	negate       bool
+}
+
+func (e fieldExpr) eval(env *Env, branch meta.Branch) (bool, error) {
+	ok, err := e.match(env, branch)
+	if err != nil {
+		return false, err
+	}
+	return ok != e.negate, nil
+}
+
+func (e fieldExpr) match(env *Env, branch meta.Branch) (bool, error) {
+	switch e.field {
+	case "name":
+		return path.Match(e.value, branch.Name)
+	case "parent":
+		return branch.Parent.Name == e.value, nil
+	case "pr":
+		return fmt.Sprint(branch.PullRequest.GetNumber()) == strings.TrimPrefix(e.value, "#"), nil
+	case "pr-state":
+		if branch.PullRequest == nil {
+			return strings.EqualFold(e.value, "none"), nil
+		}
+		return strings.EqualFold(string(branch.PullRequest.State), e.value), nil
+	case "author":
+		if env.Author == nil {
+			return false, errors.New("author is not available")
+		}
+		author, err := env.Author(branch.Name)
+		if err != nil {
+			return false, err
+		}
+		if e.value == "me" {
+			return env.Me != "" && strings.Contains(author, "<"+env.Me+">"), nil
+		}
+		return strings.Contains(strings.ToLower(author), strings.ToLower(e.value)), nil
+	}
+	return false, errors.Errorf("unknown field %q", e.field)
+}
+
+type functionExpr struct{ name, arg string }
+
+func (e functionExpr) eval(env *Env, branch meta.Branch) (bool, error) {
+	var related []string
+	switch e.name {
+	case "descendants":
+		related = meta.SubsequentBranches(env.Tx, e.arg)
+	case "children":
+		related = meta.ChildrenNames(env.Tx, e.arg)
+	case "ancestors":
+		if _, ok := env.Tx.Branch(e.arg); ok {
+			var err error
+			related, err = meta.PreviousBranches(env.Tx, e.arg)
+			if err != nil {
+				return false, err
+			}
+		}
+	case "stack":
+		if _, ok := env.Tx.Branch(e.arg); ok {
+			var err error
+			related, err = meta.StackBranches(env.Tx, e.arg)
+			if err != nil {
+				return false, err
+			}
+		}
+	default:
+		return false, errors.Errorf("unknown function %q", e.name)
+	}
+	return slices.Contains(related, branch.Name), nil
+}
+
+var (
+	functions = []string{"ancestors", "children", "descendants", "stack"}
+	fields    = []string{"author", "name", "parent", "pr", "pr-state"}
+)
+
+// Parse parses the query.
+func Parse(s string) (Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, errors.Errorf("unexpected %q in query", p.tokens[p.pos].text)
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case strings.HasPrefix(s[i:], "&&"), strings.HasPrefix(s[i:], "||"),
+			strings.HasPrefix(s[i:], "!="):
+			tokens = append(tokens, token{tokenOp, s[i : i+2]})
+			i += 2
+		case strings.ContainsRune("!()=", rune(c)):
+			tokens = append(tokens, token{tokenOp, s[i : i+1]})
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated quote in query")
+			}
+			tokens = append(tokens, token{tokenWord, s[i+1 : i+1+end]})
+			i += end + 2
+		default:
+			start := i
+			for i < len(s) && !unicode.IsSpace(rune(s[i])) && !strings.ContainsRune("!()=&|\"", rune(s[i])) {
+				i++
+			}
+			if start == i {
+				return nil, errors.Errorf("unexpected %q in query", s[i:i+1])
+			}
+			tokens = append(tokens, token{tokenWord, s[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peekOp(op string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenOp && p.tokens[p.pos].text == op
+}
+
+func (p *parser) expectWord() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", errors.New("unexpected end of query")
+	}
+	t := p.tokens[p.pos]
+	if t.kind != tokenWord {
+		return "", errors.Errorf("unexpected %q in query", t.text)
+	}
+	p.pos++
+	return t.text, nil
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.peekOp(op) {
+		if p.pos >= len(p.tokens) {
+			return errors.Errorf("expected %q at the end of query", op)
+		}
+		return errors.Errorf("expected %q but got %q in query", op, p.tokens[p.pos].text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOp("||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOp("&&") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.peekOp("!") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	if p.peekOp("(") {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	word, err := p.expectWord()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.peekOp("("):
+		p.pos++
+		arg, err := p.expectWord()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(functions, word) {
+			return nil, errors.Errorf("unknown function %q (expected one of %s)", word, strings.Join(functions, ", "))
+		}
+		return functionExpr{name: word, arg: arg}, nil
+	case p.peekOp("="), p.peekOp("!="):
+		negate := p.tokens[p.pos].text == "!="
+		p.pos++
+		value, err := p.expectWord()
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(fields, word) {
+			return nil, errors.Errorf("unknown field %q (expected one of %s)", word, strings.Join(fields, ", "))
+		}
+		return fieldExpr{field: word, value: value, negate: negate}, nil
+	}
+	return predicateExpr{name: word}, nil
+}
@@ -0,0 +1,84 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelect(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	// main -> one -> two -> three
+	//             \-> four
+	// main -> five
+	tx := db.WriteTx()
+	tx.SetBranch(meta.Branch{Name: "one", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	tx.SetBranch(meta.Branch{
+		Name:        "two",
+		Parent:      meta.BranchState{Name: "one"},
+		PullRequest: &meta.PullRequest{Number: 2, State: "OPEN"},
+	})
+	tx.SetBranch(meta.Branch{Name: "three", Parent: meta.BranchState{Name: "two"}})
+	tx.SetBranch(meta.Branch{
+		Name:        "four",
+		Parent:      meta.BranchState{Name: "one"},
+		PullRequest: &meta.PullRequest{Number: 4, State: "MERGED"},
+	})
+	tx.SetBranch(meta.Branch{Name: "five", Parent: meta.BranchState{Name: "main", Trunk: true}})
+	require.NoError(t, tx.Commit())
+
+	env := &query.Env{
+		Tx: db.ReadTx(),
+		Predicates: map[string]func(string) (bool, error){
+			"needs-restack": func(branch string) (bool, error) { return branch == "three", nil },
+		},
+		Author: func(branch string) (string, error) {
+			if branch == "five" {
+				return "Bob <bob@example.com>", nil
+			}
+			return "Alice <alice@example.com>", nil
+		},
+		Me: "alice@example.com",
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"root", []string{"five", "one"}},
+		{"descendants(one)", []string{"four", "two", "three"}},
+		{"ancestors(three)", []string{"one", "two"}},
+		{"children(one) && !merged", []string{"two"}},
+		{"stack(three) && leaf", []string{"four", "three"}},
+		{"has-pr || parent=two", []string{"four", "two", "three"}},
+		{"pr-state=merged", []string{"four"}},
+		{"pr=#2", []string{"two"}},
+		{`name="t*"`, []string{"two", "three"}},
+		{"needs-restack && author=me", []string{"three"}},
+		{"author!=me", []string{"five"}},
+		{"!(root || has-pr)", []string{"three"}},
+	} {
+		t.Run(tt.query, func(t *testing.T) {
+			expr, err := query.Parse(tt.query)
+			require.NoError(t, err)
+			got, err := query.Select(expr, env)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, q := range []string{"", "root &&", "(root", "root)", "foo(one)", "bar=1", `name="x`} {
+		_, err := query.Parse(q)
+		assert.Error(t, err, q)
+	}
+	expr, err := query.Parse("unknown-predicate")
+	require.NoError(t, err)
+	_, err = query.Select(expr, env)
+	assert.Error(t, err)
+}
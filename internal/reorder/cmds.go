@@ -35,6 +35,13 @@ type State struct {
 	Head string `json:"head"`
 	// The name of the current branch in the reorder operation.
 	Branch string `json:"branch"`
+	// If true, the picked commits that are not signed off get a Signed-off-by trailer.
+	Signoff bool `json:"signoff,omitempty"`
+	// If true, the picked commits that are rewritten are signed with the Git signing key.
+	Sign bool `json:"sign,omitempty"`
+	// The stash commit of the uncommitted changes that are stashed before the reorder. The
+	// changes are restored when the reorder is done or aborted.
+	Autostash string `json:"autostash,omitempty"`
 	// The sequence of commands to be executed.
 	// NOTE: we handle marshalling/unmarshalling in the MarshalJSON/UnmarshalJSON methods.
 	Commands []Cmd `json:"-"`
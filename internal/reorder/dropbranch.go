@@ -0,0 +1,40 @@
+package reorder
+
+import (
+	"strings"
+)
+
+// DropBranchCmd is a command that drops a branch from the stack together with the commits
+// picked after it (until the next branch).
+//
+//	drop-branch <branch-name>
+//
+// ResolvePlan turns the command into a DeleteBranchCmd at the end of the plan and re-parents
+// the branches that were stacked on the dropped branch onto the previous branch.
+type DropBranchCmd struct {
+	// The name of the branch to drop.
+	Name string
+}
+
+func (d DropBranchCmd) Execute(ctx *Context) error {
+	return DeleteBranchCmd{Name: d.Name, DeleteGitRef: true}.Execute(ctx)
+}
+
+func (d DropBranchCmd) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("drop-branch ")
+	sb.WriteString(d.Name)
+	return sb.String()
+}
+
+var _ Cmd = DropBranchCmd{}
+
+func parseDropBranchCmd(args []string) (Cmd, error) {
+	if len(args) != 1 {
+		return nil, ErrInvalidCmd{
+			"drop-branch",
+			"exactly one argument is required (the name of the branch to drop)",
+		}
+	}
+	return DropBranchCmd{Name: args[0]}, nil
+}
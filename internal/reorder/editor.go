@@ -48,7 +48,7 @@ func EditPlan(repo *git.Repo, plan []Cmd) ([]Cmd, error) {
 		newPlan = append(newPlan, cmd)
 	}
 
-	return newPlan, nil
+	return ResolvePlan(newPlan)
 }
 
 type PlanDiff struct {
@@ -67,8 +67,12 @@ func Diff(old []Cmd, new []Cmd) PlanDiff {
 
 	var newBranches []string
 	for _, cmd := range new {
-		if sb, ok := cmd.(StackBranchCmd); ok {
-			newBranches = append(newBranches, sb.Name)
+		switch cmd := cmd.(type) {
+		case StackBranchCmd:
+			newBranches = append(newBranches, cmd.Name)
+		case DeleteBranchCmd:
+			// Explicitly dropped by the user, so it's not removed by accident.
+			oldBranches = sliceutils.Subtract(oldBranches, []string{cmd.Name})
 		}
 	}
 
@@ -88,6 +92,18 @@ const instructionsText = `
 #         specified, the branch is rooted from the given branch.
 #         trunk-branch-name can be either a branch name or a branch name with a
 #         commit ID in the format "<branch-name>@<commit-id>".
+#         To move a branch onto another branch, change its parent. The parent
+#         must come before the branch in the plan.
+# split <new-branch-name>
+#         Split the current branch at this point. The commits picked after this
+#         command go to the new branch, which is stacked on the current branch
+#         (and the branches stacked on the current branch are stacked on the
+#         new branch instead).
+# drop, drop-branch <branch-name>
+#         Drop the branch and the commits picked after this command (replace
+#         stack-branch with drop-branch). The branches stacked on the dropped
+#         branch are stacked on the previous branch instead. The branch is
+#         deleted at the end of the reorder.
 # p, pick <commit-id>
 #         Pick a commit to be included in the stack. Only valid after a
 #         stack-branch command.
@@ -20,8 +20,12 @@ func ParseCmd(line string) (Cmd, error) {
 	switch cmdName {
 	case "delete-branch", "db":
 		return parseDeleteBranchCmd(args)
+	case "drop-branch", "drop":
+		return parseDropBranchCmd(args)
 	case "pick", "p":
 		return parsePickCmd(args)
+	case "split":
+		return parseSplitBranchCmd(args)
 	case "stack-branch", "sb":
 		return parseStackBranchCmd(args)
 	default:
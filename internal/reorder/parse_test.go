@@ -23,6 +23,10 @@ func TestParseCmd(t *testing.T) {
 		{"delete-branch foo", DeleteBranchCmd{Name: "foo"}, false},
 		{"delete-branch foo bar", DeleteBranchCmd{}, true},
 		{"db foo --delete-git-ref", DeleteBranchCmd{Name: "foo", DeleteGitRef: true}, false},
+		{"split", SplitBranchCmd{}, true},
+		{"split feature-two", SplitBranchCmd{Name: "feature-two"}, false},
+		{"drop-branch", DropBranchCmd{}, true},
+		{"drop feature-one", DropBranchCmd{Name: "feature-one"}, false},
 		{"blarn", nil, true},
 	} {
 		t.Run(tt.Input, func(t *testing.T) {
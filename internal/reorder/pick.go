@@ -17,16 +17,37 @@ type PickCmd struct {
 }
 
 func (p PickCmd) Execute(ctx *Context) error {
+	signoff := false
+	if ctx.State.Signoff {
+		commits, err := ctx.Repo.CommitsToSignoff(p.Commit + "^!")
+		if err != nil {
+			return err
+		}
+		signoff = len(commits) > 0
+	}
 	err := ctx.Repo.CherryPick(git.CherryPick{
 		Commits: []string{p.Commit},
-		// Use FastForward to avoid always amending commits.
-		FastForward: true,
+		// Use FastForward to avoid always amending commits. The commits that are signed off
+		// are rewritten anyway.
+		FastForward: !signoff,
+		Signoff:     signoff,
+		Sign:        ctx.State.Sign,
 	})
 	if conflict, ok := errutils.As[git.ErrCherryPickConflict](err); ok {
 		ctx.Print(
 			colors.Failure("  - ", conflict.Error(), "\n"),
 			colors.Faint(text.Indent(strings.TrimRight(conflict.Output, "\n"), "        "), "\n"),
 		)
+		if files, err := ctx.Repo.ConflictedFiles(); err == nil {
+			for _, file := range files {
+				if file.Submodule {
+					ctx.Print(colors.Failure(
+						"  - submodule ", file.Path, " conflicts: check out the commit to use in the ",
+						"submodule and stage it with git add ", file.Path, "\n",
+					))
+				}
+			}
+		}
 		return ErrInterruptReorder
 	} else if err != nil {
 		return err
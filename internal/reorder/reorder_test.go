@@ -87,3 +87,36 @@ func TestReorderConflict(t *testing.T) {
 	require.NotNil(t, state, "expected state to be returned after conflicts")
 	require.Equal(t, state.Commands[0], reorder.PickCmd{Commit: c2a.String()})
 }
+
+func TestReorderSplitBranch(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	db := repo.OpenDB(t)
+
+	initial := repo.GetCommitAtRef(t, plumbing.HEAD)
+
+	repo.CreateRef(t, plumbing.NewBranchReferenceName("one"))
+	repo.CheckoutBranch(t, plumbing.NewBranchReferenceName("one"))
+	c1a := repo.CommitFile(t, "file", "hello\n")
+	c1b := repo.CommitFile(t, "fichier", "bonjour\n")
+
+	plan, err := reorder.ResolvePlan([]reorder.Cmd{
+		reorder.StackBranchCmd{Name: "one", Trunk: fmt.Sprintf("main@%s", initial)},
+		reorder.PickCmd{Commit: c1a.String()},
+		reorder.SplitBranchCmd{Name: "two"},
+		reorder.PickCmd{Commit: c1b.String()},
+	})
+	require.NoError(t, err)
+	state, err := reorder.Reorder(reorder.Context{
+		Repo:  repo.AsAvGitRepo(),
+		DB:    db,
+		State: &reorder.State{Commands: plan},
+	})
+	require.NoError(t, err, "expected reorder to complete cleanly")
+	require.Nil(t, state, "expected reorder to complete cleanly")
+
+	assert.Equal(t, c1a, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("one")))
+	assert.Equal(t, c1b, repo.GetCommitAtRef(t, plumbing.NewBranchReferenceName("two")))
+	two, _ := db.ReadTx().Branch("two")
+	assert.Equal(t, "one", two.Parent.Name)
+	assert.Equal(t, c1a.String(), two.Parent.Head)
+}
@@ -0,0 +1,130 @@
+package reorder
+
+import (
+	"fmt"
+)
+
+// ResolvePlan validates the plan edited by the user and resolves the commands that affect
+// the other branches in the plan:
+//
+//   - split: the branches that are stacked on the split branch (after the split) are stacked
+//     on the new branch instead.
+//   - drop-branch: the commits picked after the command are dropped, the branches that were
+//     stacked on the dropped branch are stacked on the previous branch in the plan instead, and
+//     the branch is deleted at the end of the reorder.
+//
+// The returned plan consists of only stack-branch, pick, and delete-branch commands.
+func ResolvePlan(plan []Cmd) ([]Cmd, error) {
+	// All the branches that the plan creates, to detect parents that come later in the plan.
+	planned := make(map[string]bool)
+	for _, cmd := range plan {
+		switch cmd := cmd.(type) {
+		case StackBranchCmd:
+			planned[cmd.Name] = true
+		case SplitBranchCmd:
+			planned[cmd.Name] = true
+		case DropBranchCmd:
+			planned[cmd.Name] = true
+		}
+	}
+
+	var (
+		resolved []Cmd
+		drops    []Cmd
+		// The branches that have been started so far.
+		seen = make(map[string]bool)
+		// The branch that the branches stacked on the key branch are stacked on instead (the
+		// branch created by splitting the key branch, or the branch before the dropped key
+		// branch).
+		replaced = make(map[string]string)
+		// The current branch, or an empty string if there is none or it's dropped.
+		current  string
+		dropping bool
+	)
+	addBranch := func(cmdName, name string) error {
+		if seen[name] {
+			return ErrInvalidCmd{cmdName, fmt.Sprintf("branch %q appears more than once", name)}
+		}
+		seen[name] = true
+		return nil
+	}
+	resolveParent := func(name string) string {
+		for {
+			next, ok := replaced[name]
+			if !ok {
+				return name
+			}
+			name = next
+		}
+	}
+
+	for _, cmd := range plan {
+		switch cmd := cmd.(type) {
+		case StackBranchCmd:
+			if err := addBranch("stack-branch", cmd.Name); err != nil {
+				return nil, err
+			}
+			if cmd.Parent != "" {
+				if planned[cmd.Parent] && !seen[cmd.Parent] {
+					return nil, ErrInvalidCmd{"stack-branch", fmt.Sprintf(
+						"the parent %q of %q must come before it in the plan", cmd.Parent, cmd.Name,
+					)}
+				}
+				cmd.Parent = resolveParent(cmd.Parent)
+				if cmd.Parent == "" {
+					return nil, ErrInvalidCmd{"stack-branch", fmt.Sprintf(
+						"%q is stacked on a dropped branch at the bottom of the stack "+
+							"(specify --trunk)", cmd.Name,
+					)}
+				}
+			}
+			resolved = append(resolved, cmd)
+			current = cmd.Name
+			dropping = false
+		case SplitBranchCmd:
+			if current == "" {
+				return nil, ErrInvalidCmd{"split", "must come after a stack-branch command"}
+			}
+			if err := addBranch("split", cmd.Name); err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, StackBranchCmd{
+				Name:    cmd.Name,
+				Parent:  current,
+				Comment: "split from " + current,
+			})
+			replaced[current] = cmd.Name
+			current = cmd.Name
+		case DropBranchCmd:
+			if err := addBranch("drop-branch", cmd.Name); err != nil {
+				return nil, err
+			}
+			replaced[cmd.Name] = lastBranch(resolved)
+			drops = append(drops, DeleteBranchCmd{Name: cmd.Name, DeleteGitRef: true})
+			current = ""
+			dropping = true
+		case PickCmd:
+			if dropping {
+				continue
+			}
+			if current == "" {
+				return nil, ErrInvalidCmd{"pick", "must come after a stack-branch command"}
+			}
+			resolved = append(resolved, cmd)
+		default:
+			resolved = append(resolved, cmd)
+		}
+	}
+	return append(resolved, drops...), nil
+}
+
+// lastBranch returns the name of the last branch started in the plan, or an empty string if
+// there is none.
+func lastBranch(plan []Cmd) string {
+	for i := len(plan) - 1; i >= 0; i-- {
+		if sb, ok := plan[i].(StackBranchCmd); ok {
+			return sb.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package reorder_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/reorder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePlan(t *testing.T) {
+	for _, tt := range []struct {
+		Name  string
+		Plan  []reorder.Cmd
+		Want  []reorder.Cmd
+		Error string
+	}{
+		{
+			Name: "split",
+			Plan: []reorder.Cmd{
+				reorder.StackBranchCmd{Name: "one", Trunk: "main"},
+				reorder.PickCmd{Commit: "1a"},
+				reorder.SplitBranchCmd{Name: "one-b"},
+				reorder.PickCmd{Commit: "1b"},
+				reorder.StackBranchCmd{Name: "two", Parent: "one"},
+				reorder.PickCmd{Commit: "2a"},
+			},
+			Want: []reorder.Cmd{
+				reorder.StackBranchCmd{Name: "one", Trunk: "main"},
+				reorder.PickCmd{Commit: "1a"},
+				reorder.StackBranchCmd{Name: "one-b", Parent: "one", Comment: "split from one"},
+				reorder.PickCmd{Commit: "1b"},
+				reorder.StackBranchCmd{Name: "two", Parent: "one-b"},
+				reorder.PickCmd{Commit: "2a"},
+			},
+		},
+		{
+			Name: "drop",
+			Plan: []reorder.Cmd{
+				reorder.StackBranchCmd{Name: "one", Trunk: "main"},
+				reorder.PickCmd{Commit: "1a"},
+				reorder.DropBranchCmd{Name: "two"},
+				reorder.PickCmd{Commit: "2a"},
+				reorder.StackBranchCmd{Name: "three", Parent: "two"},
+				reorder.PickCmd{Commit: "3a"},
+			},
+			Want: []reorder.Cmd{
+				reorder.StackBranchCmd{Name: "one", Trunk: "main"},
+				reorder.PickCmd{Commit: "1a"},
+				reorder.StackBranchCmd{Name: "three", Parent: "one"},
+				reorder.PickCmd{Commit: "3a"},
+				reorder.DeleteBranchCmd{Name: "two", DeleteGitRef: true},
+			},
+		},
+		{
+			Name: "move onto another branch",
+			Plan: []reorder.Cmd{
+				reorder.StackBranchCmd{Name: "one", Trunk: "main"},
+				reorder.StackBranchCmd{Name: "two", Parent: "one"},
+				reorder.StackBranchCmd{Name: "three", Parent: "one"},
+			},
+			Want: []reorder.Cmd{
+				reorder.StackBranchCmd{Name: "one", Trunk: "main"},
+				reorder.StackBranchCmd{Name: "two", Parent: "one"},
+				reorder.StackBranchCmd{Name: "three", Parent: "one"},
+			},
+		},
+		{
+			Name: "parent after the branch",
+			Plan: []reorder.Cmd{
+				reorder.StackBranchCmd{Name: "one", Trunk: "main"},
+				reorder.StackBranchCmd{Name: "two", Parent: "three"},
+				reorder.StackBranchCmd{Name: "three", Parent: "one"},
+			},
+			Error: `the parent "three" of "two" must come before it in the plan`,
+		},
+		{
+			Name: "stacked on the dropped bottom branch",
+			Plan: []reorder.Cmd{
+				reorder.DropBranchCmd{Name: "one"},
+				reorder.StackBranchCmd{Name: "two", Parent: "one"},
+			},
+			Error: "specify --trunk",
+		},
+		{
+			Name: "duplicate branch",
+			Plan: []reorder.Cmd{
+				reorder.StackBranchCmd{Name: "one", Trunk: "main"},
+				reorder.SplitBranchCmd{Name: "one"},
+			},
+			Error: `branch "one" appears more than once`,
+		},
+		{
+			Name: "pick without branch",
+			Plan: []reorder.Cmd{
+				reorder.PickCmd{Commit: "1a"},
+			},
+			Error: "must come after a stack-branch command",
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			plan, err := reorder.ResolvePlan(tt.Plan)
+			if tt.Error != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.Error)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.Want, plan)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package reorder
+
+import (
+	"emperror.dev/errors"
+)
+
+// CheckSharedBases checks that the edited plan doesn't move the commits of a shared base (a
+// branch that more than one branch is stacked on in the original plan) into a branch that only
+// some of the branches stacked on the base are stacked on. Such a move takes the commits away
+// from the other stacks on the base and splits the base inconsistently. The commits can still
+// be moved to the branches below the base, or into a new branch split from the base that all
+// the stacks are stacked on (see SplitBranchCmd).
+func CheckSharedBases(old []Cmd, new []Cmd) error {
+	oldBranches := planBranches(old)
+	children := map[string][]string{}
+	for _, br := range oldBranches {
+		if br.parent != "" {
+			children[br.parent] = append(children[br.parent], br.name)
+		}
+	}
+	newBranches := planBranches(new)
+	newParents := map[string]string{}
+	commitBranches := map[string]string{}
+	for _, br := range newBranches {
+		newParents[br.name] = br.parent
+		for _, commit := range br.commits {
+			commitBranches[commit] = br.name
+		}
+	}
+	// isStackedOn returns true if the branch is stacked on the base (directly or not) in the
+	// edited plan.
+	isStackedOn := func(branch, base string) bool {
+		seen := map[string]bool{}
+		for p := newParents[branch]; p != "" && !seen[p]; p = newParents[p] {
+			if p == base {
+				return true
+			}
+			seen[p] = true
+		}
+		return false
+	}
+
+	for _, br := range oldBranches {
+		if len(children[br.name]) < 2 {
+			continue
+		}
+		for _, commit := range br.commits {
+			moved, ok := commitBranches[commit]
+			if !ok || moved == br.name || !isStackedOn(moved, br.name) {
+				continue
+			}
+			for _, child := range children[br.name] {
+				if _, ok := newParents[child]; !ok || child == moved || isStackedOn(child, moved) {
+					continue
+				}
+				return errors.Errorf(
+					"commit %s of the shared branch %s cannot be moved into %s "+
+						"(%s, which is also stacked on %s, would lose it)",
+					commit, br.name, moved, child, br.name,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+type planBranch struct {
+	name    string
+	parent  string
+	commits []string
+}
+
+// planBranches returns the branches started by the plan with their parents and picked commits.
+func planBranches(plan []Cmd) []*planBranch {
+	var ret []*planBranch
+	var current *planBranch
+	for _, cmd := range plan {
+		switch cmd := cmd.(type) {
+		case StackBranchCmd:
+			parent := cmd.Parent
+			if parent == "" && cmd.Trunk == "" && current != nil {
+				parent = current.name
+			}
+			current = &planBranch{name: cmd.Name, parent: parent}
+			ret = append(ret, current)
+		case SplitBranchCmd:
+			if current == nil {
+				continue
+			}
+			current = &planBranch{name: cmd.Name, parent: current.name}
+			ret = append(ret, current)
+		case PickCmd:
+			if current != nil {
+				current.commits = append(current.commits, cmd.Commit)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,47 @@
+package reorder_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/reorder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSharedBases(t *testing.T) {
+	old := []reorder.Cmd{
+		reorder.StackBranchCmd{Name: "base", Trunk: "main"},
+		reorder.PickCmd{Commit: "b1"},
+		reorder.PickCmd{Commit: "b2"},
+		reorder.StackBranchCmd{Name: "one", Parent: "base"},
+		reorder.PickCmd{Commit: "o1"},
+		reorder.StackBranchCmd{Name: "two", Parent: "base"},
+		reorder.PickCmd{Commit: "t1"},
+	}
+	require.NoError(t, reorder.CheckSharedBases(old, old))
+
+	// Splitting the shared base keeps the commits below both stacks.
+	require.NoError(t, reorder.CheckSharedBases(old, []reorder.Cmd{
+		reorder.StackBranchCmd{Name: "base", Trunk: "main"},
+		reorder.PickCmd{Commit: "b1"},
+		reorder.StackBranchCmd{Name: "base-2", Parent: "base"},
+		reorder.PickCmd{Commit: "b2"},
+		reorder.StackBranchCmd{Name: "one", Parent: "base-2"},
+		reorder.PickCmd{Commit: "o1"},
+		reorder.StackBranchCmd{Name: "two", Parent: "base-2"},
+		reorder.PickCmd{Commit: "t1"},
+	}))
+
+	// Moving a commit of the shared base into one of the stacks takes it away from the other.
+	err := reorder.CheckSharedBases(old, []reorder.Cmd{
+		reorder.StackBranchCmd{Name: "base", Trunk: "main"},
+		reorder.PickCmd{Commit: "b1"},
+		reorder.StackBranchCmd{Name: "one", Parent: "base"},
+		reorder.PickCmd{Commit: "b2"},
+		reorder.PickCmd{Commit: "o1"},
+		reorder.StackBranchCmd{Name: "two", Parent: "base"},
+		reorder.PickCmd{Commit: "t1"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "commit b2 of the shared branch base cannot be moved into one (two, which is also stacked on base, would lose it)")
+}
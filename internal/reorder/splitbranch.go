@@ -0,0 +1,44 @@
+package reorder
+
+import (
+	"strings"
+)
+
+// SplitBranchCmd is a command that splits the current branch in two at the current commit.
+// The commits picked after this command go to a new branch that is stacked on top of the
+// current branch.
+//
+//	split <new-branch-name>
+//
+// ResolvePlan re-parents the branches that are stacked on the current branch onto the new
+// branch.
+type SplitBranchCmd struct {
+	// The name of the branch to create.
+	Name string
+}
+
+func (s SplitBranchCmd) Execute(ctx *Context) error {
+	if ctx.State.Branch == "" {
+		return ErrInvalidCmd{"split", "must come after a stack-branch command"}
+	}
+	return StackBranchCmd{Name: s.Name, Parent: ctx.State.Branch}.Execute(ctx)
+}
+
+func (s SplitBranchCmd) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("split ")
+	sb.WriteString(s.Name)
+	return sb.String()
+}
+
+var _ Cmd = SplitBranchCmd{}
+
+func parseSplitBranchCmd(args []string) (Cmd, error) {
+	if len(args) != 1 {
+		return nil, ErrInvalidCmd{
+			"split",
+			"exactly one argument is required (the name of the branch to create)",
+		}
+	}
+	return SplitBranchCmd{Name: args[0]}, nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/aviator-co/av/internal/utils/colors"
 
+	"github.com/aviator-co/av/internal/actions"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/spf13/pflag"
@@ -71,12 +72,22 @@ func (b StackBranchCmd) Execute(ctx *Context) error {
 	if headCommit == "" {
 		headCommit = branch.Parent.Name
 	}
+	exists, err := ctx.Repo.DoesBranchExist(b.Name)
+	if err != nil {
+		return err
+	}
 	if _, err := ctx.Repo.Git("switch", "--force-create", b.Name); err != nil {
 		return err
 	}
+	if !exists {
+		if err := actions.ConfigureNewBranch(ctx.Repo, b.Name); err != nil {
+			return err
+		}
+	}
 	if _, err := ctx.Repo.Git("reset", "--hard", headCommit); err != nil {
 		return err
 	}
+	ctx.State.Branch = b.Name
 	ctx.Print(
 		"Starting branch ",
 		colors.UserInput(b.Name),
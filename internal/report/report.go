@@ -0,0 +1,262 @@
+// Package report summarizes the stacks and the pull requests created, merged, and still open
+// in a timeframe (see av report).
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aviator-co/av/internal/meta"
+)
+
+const (
+	StateOpen   = "open"
+	StateMerged = "merged"
+	StateClosed = "closed"
+)
+
+// PullRequest is a pull request of a stack.
+type PullRequest struct {
+	Number    int64      `json:"number"`
+	Title     string     `json:"title"`
+	URL       string     `json:"url"`
+	Branch    string     `json:"branch"`
+	State     string     `json:"state"`
+	CreatedAt time.Time  `json:"createdAt"`
+	MergedAt  *time.Time `json:"mergedAt,omitempty"`
+	ClosedAt  *time.Time `json:"closedAt,omitempty"`
+}
+
+// Stack is a stack that has a pull request created or merged in the timeframe, or that still
+// has an open pull request.
+type Stack struct {
+	// The name of the root branch of the stack.
+	Root string `json:"root"`
+	// The state of the stack: open if any pull request is open, merged if all the pull
+	// requests are merged (or closed), and closed if none of them is merged.
+	State string `json:"state"`
+	// The pull requests of the stack in the stack order.
+	PullRequests []PullRequest `json:"pullRequests"`
+}
+
+// Summary is the numbers of the stacks and the pull requests in the timeframe.
+type Summary struct {
+	StacksCreated       int `json:"stacksCreated"`
+	StacksMerged        int `json:"stacksMerged"`
+	StacksOpen          int `json:"stacksOpen"`
+	PullRequestsCreated int `json:"pullRequestsCreated"`
+	PullRequestsMerged  int `json:"pullRequestsMerged"`
+	PullRequestsOpen    int `json:"pullRequestsOpen"`
+}
+
+// Report is the report of a timeframe.
+type Report struct {
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	Summary Summary   `json:"summary"`
+	Stacks  []Stack   `json:"stacks"`
+	// The number of the recorded av operations (e.g., "av sync") in the timeframe keyed by
+	// the command.
+	Operations map[string]int `json:"operations,omitempty"`
+}
+
+// Build builds the report of the timeframe [since, until).
+//
+// branches is the av metadata of the branches, including the ones that no longer exist (e.g.,
+// deleted after merged) so that their pull requests are counted. pullRequests is the pull
+// requests keyed by the branch name. Branches without a pull request are not reported.
+// operations is the time of the recorded av operations keyed by the command.
+func Build(
+	since, until time.Time,
+	branches map[string]meta.Branch,
+	pullRequests map[string]PullRequest,
+	operations map[string][]time.Time,
+) *Report {
+	inRange := func(t *time.Time) bool {
+		return t != nil && !t.Before(since) && t.Before(until)
+	}
+	r := &Report{Since: since, Until: until}
+
+	stacks := map[string][]string{}
+	for name := range pullRequests {
+		root := stackRoot(branches, name)
+		stacks[root] = append(stacks[root], name)
+	}
+	var roots []string
+	for root := range stacks {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	for _, root := range roots {
+		order := stackOrder(branches, root)
+		names := stacks[root]
+		sort.Slice(names, func(i, j int) bool {
+			if order[names[i]] != order[names[j]] {
+				return order[names[i]] < order[names[j]]
+			}
+			return names[i] < names[j]
+		})
+
+		stack := Stack{Root: root}
+		var created, active, open, merged bool
+		var firstCreated time.Time
+		var lastMerged *time.Time
+		allClosed := true
+		for _, name := range names {
+			pr := pullRequests[name]
+			stack.PullRequests = append(stack.PullRequests, pr)
+			if firstCreated.IsZero() || pr.CreatedAt.Before(firstCreated) {
+				firstCreated = pr.CreatedAt
+			}
+			if inRange(&pr.CreatedAt) {
+				r.Summary.PullRequestsCreated++
+				active = true
+			}
+			if inRange(pr.MergedAt) {
+				r.Summary.PullRequestsMerged++
+				active = true
+			}
+			switch pr.State {
+			case StateOpen:
+				r.Summary.PullRequestsOpen++
+				open = true
+				allClosed = false
+			case StateMerged:
+				merged = true
+				if lastMerged == nil || pr.MergedAt != nil && pr.MergedAt.After(*lastMerged) {
+					lastMerged = pr.MergedAt
+				}
+			}
+		}
+		created = inRange(&firstCreated)
+		switch {
+		case open:
+			stack.State = StateOpen
+			r.Summary.StacksOpen++
+		case merged && allClosed:
+			stack.State = StateMerged
+			if inRange(lastMerged) {
+				r.Summary.StacksMerged++
+			}
+		default:
+			stack.State = StateClosed
+		}
+		if created {
+			r.Summary.StacksCreated++
+		}
+		if active || open {
+			r.Stacks = append(r.Stacks, stack)
+		}
+	}
+
+	for command, times := range operations {
+		for _, t := range times {
+			if inRange(&t) {
+				if r.Operations == nil {
+					r.Operations = map[string]int{}
+				}
+				r.Operations[command]++
+			}
+		}
+	}
+	return r
+}
+
+// stackRoot returns the root branch of the stack of the branch.
+func stackRoot(branches map[string]meta.Branch, name string) string {
+	seen := map[string]bool{}
+	for {
+		br, ok := branches[name]
+		if !ok || br.Parent.Trunk || br.Parent.Name == "" || seen[br.Parent.Name] {
+			return name
+		}
+		seen[name] = true
+		name = br.Parent.Name
+	}
+}
+
+// stackOrder returns the depth-first order of the branches of the stack of the root.
+func stackOrder(branches map[string]meta.Branch, root string) map[string]int {
+	children := map[string][]string{}
+	for name, br := range branches {
+		if !br.Parent.Trunk {
+			children[br.Parent.Name] = append(children[br.Parent.Name], name)
+		}
+	}
+	order := map[string]int{}
+	var visit func(string)
+	visit = func(name string) {
+		if _, ok := order[name]; ok {
+			return
+		}
+		order[name] = len(order)
+		sort.Strings(children[name])
+		for _, child := range children[name] {
+			visit(child)
+		}
+	}
+	visit(root)
+	return order
+}
+
+// WriteMarkdown writes the report in Markdown.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Stack report: %s to %s\n\n", formatDate(r.Since), formatDate(r.Until))
+	fmt.Fprintf(&sb, "- Pull requests: %d created, %d merged, %d open\n",
+		r.Summary.PullRequestsCreated, r.Summary.PullRequestsMerged, r.Summary.PullRequestsOpen)
+	fmt.Fprintf(&sb, "- Stacks: %d created, %d merged, %d open\n",
+		r.Summary.StacksCreated, r.Summary.StacksMerged, r.Summary.StacksOpen)
+	if len(r.Operations) > 0 {
+		var commands []string
+		for command := range r.Operations {
+			commands = append(commands, command)
+		}
+		sort.Strings(commands)
+		var ops []string
+		for _, command := range commands {
+			ops = append(ops, fmt.Sprintf("%d `%s`", r.Operations[command], command))
+		}
+		fmt.Fprintf(&sb, "- Operations: %s\n", strings.Join(ops, ", "))
+	}
+
+	for _, state := range []string{StateOpen, StateMerged, StateClosed} {
+		var stacks []Stack
+		for _, s := range r.Stacks {
+			if s.State == state {
+				stacks = append(stacks, s)
+			}
+		}
+		if len(stacks) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n### %s stacks\n", strings.ToUpper(state[:1])+state[1:])
+		for _, s := range stacks {
+			fmt.Fprintf(&sb, "\n**%s**\n\n", s.Root)
+			for _, pr := range s.PullRequests {
+				fmt.Fprintf(&sb, "- [#%d](%s) %s (%s)\n", pr.Number, pr.URL, pr.Title, pr.describe())
+			}
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (pr PullRequest) describe() string {
+	switch {
+	case pr.State == StateMerged && pr.MergedAt != nil:
+		return "merged " + formatDate(*pr.MergedAt)
+	case pr.State == StateClosed && pr.ClosedAt != nil:
+		return "closed " + formatDate(*pr.ClosedAt)
+	default:
+		return pr.State + ", created " + formatDate(pr.CreatedAt)
+	}
+}
+
+func formatDate(t time.Time) string {
+	return t.Local().Format(time.DateOnly)
+}
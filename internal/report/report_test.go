@@ -0,0 +1,65 @@
+package report_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/report"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	dayPtr := func(d int) *time.Time { t := day(d); return &t }
+	trunk := meta.BranchState{Name: "main", Trunk: true}
+	branches := map[string]meta.Branch{
+		"api":       {Name: "api", Parent: trunk},
+		"api-2":     {Name: "api-2", Parent: meta.BranchState{Name: "api"}},
+		"docs":      {Name: "docs", Parent: trunk},
+		"old":       {Name: "old", Parent: trunk},
+		"abandoned": {Name: "abandoned", Parent: trunk},
+		"no-pr":     {Name: "no-pr", Parent: trunk},
+	}
+	prs := map[string]report.PullRequest{
+		// An open stack created in the timeframe.
+		"api":   {Number: 1, Branch: "api", State: report.StateMerged, CreatedAt: day(8), MergedAt: dayPtr(9)},
+		"api-2": {Number: 2, Branch: "api-2", State: report.StateOpen, CreatedAt: day(9)},
+		// A stack merged in the timeframe.
+		"docs": {Number: 3, Branch: "docs", State: report.StateMerged, CreatedAt: day(1), MergedAt: dayPtr(10)},
+		// Merged before the timeframe.
+		"old": {Number: 4, Branch: "old", State: report.StateMerged, CreatedAt: day(1), MergedAt: dayPtr(2)},
+		// Closed in the timeframe, but neither created nor merged.
+		"abandoned": {Number: 5, Branch: "abandoned", State: report.StateClosed, CreatedAt: day(1), ClosedAt: dayPtr(9)},
+	}
+	ops := map[string][]time.Time{
+		"av sync":    {day(2), day(8), day(9)},
+		"av reorder": {day(1)},
+	}
+
+	r := report.Build(day(8), day(15), branches, prs, ops)
+	require.Equal(t, report.Summary{
+		StacksCreated:       1,
+		StacksMerged:        1,
+		StacksOpen:          1,
+		PullRequestsCreated: 2,
+		PullRequestsMerged:  2,
+		PullRequestsOpen:    1,
+	}, r.Summary)
+	require.Equal(t, map[string]int{"av sync": 2}, r.Operations)
+	require.Len(t, r.Stacks, 2)
+	require.Equal(t, "api", r.Stacks[0].Root)
+	require.Equal(t, report.StateOpen, r.Stacks[0].State)
+	require.Equal(t, int64(1), r.Stacks[0].PullRequests[0].Number)
+	require.Equal(t, int64(2), r.Stacks[0].PullRequests[1].Number)
+	require.Equal(t, "docs", r.Stacks[1].Root)
+	require.Equal(t, report.StateMerged, r.Stacks[1].State)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteMarkdown(&buf))
+	require.Contains(t, buf.String(), "- Pull requests: 2 created, 2 merged, 1 open\n")
+	require.Contains(t, buf.String(), "### Open stacks\n\n**api**\n")
+	require.Contains(t, buf.String(), "### Merged stacks\n\n**docs**\n")
+	require.Contains(t, buf.String(), "- Operations: 2 `av sync`\n")
+}
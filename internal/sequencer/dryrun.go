@@ -0,0 +1,124 @@
+package sequencer
+
+import (
+	"github.com/aviator-co/av/internal/git"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// DryRunStatus is the predicted outcome of a restack operation.
+type DryRunStatus int
+
+const (
+	// The branch is already on top of its new parent, so it won't be rebased.
+	DryRunUpToDate DryRunStatus = iota
+	// The branch will be rebased without conflicts.
+	DryRunRebase
+	// The rebase of the branch will stop at a conflict.
+	DryRunConflict
+	// The branch will be rebased onto a parent that has a conflict, so the outcome depends on
+	// how the conflict is resolved.
+	DryRunBlocked
+)
+
+// DryRunResult is the predicted outcome of a restack operation.
+type DryRunResult struct {
+	Op     RestackOp
+	Status DryRunStatus
+	// The number of commits to be rebased.
+	Commits int
+	// The commit that stops at a conflict and the conflicting files (DryRunConflict).
+	ConflictCommit string
+	ConflictFiles  []string
+	// The branch that has the conflict that this branch waits for (DryRunBlocked).
+	BlockedBy plumbing.ReferenceName
+}
+
+// DryRun predicts the outcome of the remaining operations without changing the branches or the
+// av metadata. The commits of each branch are replayed onto the new parent one by one with
+// git merge-tree like git rebase does, so that the first conflicting commit is found.
+func (seq *Sequencer) DryRun(repo *git.Repo) ([]DryRunResult, error) {
+	var results []DryRunResult
+	// The trees of the branches after the predicted rebases.
+	rebasedTrees := map[plumbing.ReferenceName]string{}
+	// The conflicting branch that each conflicting or blocked branch waits for.
+	blockedBy := map[plumbing.ReferenceName]plumbing.ReferenceName{}
+	started := false
+	for _, op := range seq.Operations {
+		if op.Name == seq.CurrentSyncRef {
+			started = true
+		}
+		if !started {
+			continue
+		}
+		result := DryRunResult{Op: op}
+		if conflict, ok := blockedBy[op.NewParent]; ok {
+			result.Status = DryRunBlocked
+			result.BlockedBy = conflict
+			blockedBy[op.Name] = conflict
+			results = append(results, result)
+			continue
+		}
+
+		previousParentHash, newParentHash, err := seq.parentHashes(repo, op)
+		if err != nil {
+			return nil, err
+		}
+		commits, err := repo.RevList(git.RevListOpts{
+			Specifiers: []string{previousParentHash.String() + ".." + op.Name.String()},
+			Reverse:    true,
+			// git rebase drops the merge commits.
+			NoMerges: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Commits = len(commits)
+
+		signoff := false
+		if seq.Signoff {
+			toSignoff, err := repo.CommitsToSignoff(
+				previousParentHash.String() + ".." + op.Name.String(),
+			)
+			if err != nil {
+				return nil, err
+			}
+			signoff = len(toSignoff) > 0
+		}
+
+		onto, parentRebased := rebasedTrees[op.NewParent]
+		if !parentRebased && !signoff {
+			fastForwarded, err := isParentFastForwarded(
+				repo, op.Name.String(), previousParentHash, newParentHash,
+			)
+			if err != nil {
+				return nil, err
+			}
+			if fastForwarded {
+				results = append(results, result)
+				continue
+			}
+		}
+		if !parentRebased {
+			onto = newParentHash.String()
+		}
+
+		result.Status = DryRunRebase
+		for _, commit := range commits {
+			merged, err := repo.MergeTree(commit+"^", onto, commit)
+			if err != nil {
+				return nil, err
+			}
+			if len(merged.ConflictedFiles) > 0 {
+				result.Status = DryRunConflict
+				result.ConflictCommit = commit
+				result.ConflictFiles = merged.ConflictedFiles
+				blockedBy[op.Name] = op.Name
+				break
+			}
+			onto = merged.Tree
+		}
+		rebasedTrees[op.Name] = onto
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -34,15 +34,15 @@ func PlanForRestack(
 			// Skip rebasing branches that have merge commits.
 			continue
 		}
+		if avbr.IsPinned() {
+			// Skip rebasing pinned branches.
+			continue
+		}
 		if avbr.Parent.Trunk {
 			// Skip rebasing the stack roots.
 			continue
 		}
-		ret = append(ret, sequencer.RestackOp{
-			Name:             br,
-			NewParent:        plumbing.NewBranchReferenceName(avbr.Parent.Name),
-			NewParentIsTrunk: avbr.Parent.Trunk,
-		})
+		ret = append(ret, newRestackOp(tx, br, avbr.Parent))
 	}
 	return ret, nil
 }
@@ -65,7 +65,16 @@ func PlanForSync(
 	if err != nil {
 		return nil, err
 	}
+	return PlanForSyncBranches(tx, targetBranches, restackStackRoots), nil
+}
 
+// PlanForSyncBranches plans syncing the given branches. The branches must be in the dependency
+// order (see GetTargetBranchesFromList).
+func PlanForSyncBranches(
+	tx meta.ReadTx,
+	targetBranches []plumbing.ReferenceName,
+	restackStackRoots bool,
+) []sequencer.RestackOp {
 	var ret []sequencer.RestackOp
 	for _, br := range targetBranches {
 		avbr, _ := tx.Branch(br.Short())
@@ -73,17 +82,33 @@ func PlanForSync(
 			// Skip rebasing branches that have merge commits.
 			continue
 		}
+		if avbr.IsPinned() {
+			// Skip rebasing pinned branches.
+			continue
+		}
+		if avbr.IsInMergeQueue() {
+			// Skip rebasing the branches in the merge queue. The queue merges the pushed
+			// commits, and the branch is pruned only if it still points to them.
+			continue
+		}
+		if avbr.IsFollowed() {
+			// Skip rebasing the followed branches. They are updated to their remote branches
+			// when fetching instead.
+			continue
+		}
 
 		if avbr.Parent.Trunk {
-			if !restackStackRoots {
-				// Skip rebasing the stack roots.
+			if !restackStackRoots && avbr.FrozenBase == "" {
+				// Skip rebasing the stack roots. The stack roots with a frozen base are
+				// always rebased onto the frozen base.
 				continue
 			}
 		} else {
 			// Check if the parent branch is merged.
 			avpbr, _ := tx.Branch(avbr.Parent.Name)
-			if avpbr.MergeCommit != "" {
-				// The parent is merged.
+			if avpbr.MergeCommit != "" || avpbr.IsClosedFollowed() {
+				// The parent is merged, or it's a followed branch whose pull request is
+				// closed. The commits of the parent are dropped either way.
 				trunk, _ := meta.Trunk(tx, br.Short())
 				ret = append(ret, sequencer.RestackOp{
 					Name:             br,
@@ -93,13 +118,43 @@ func PlanForSync(
 				continue
 			}
 		}
-		ret = append(ret, sequencer.RestackOp{
-			Name:             br,
-			NewParent:        plumbing.NewBranchReferenceName(avbr.Parent.Name),
-			NewParentIsTrunk: avbr.Parent.Trunk,
-		})
+		ret = append(ret, newRestackOp(tx, br, avbr.Parent))
 	}
-	return ret, nil
+	return ret
+}
+
+// PlanForBaseBump plans rebasing only the stack root of the given branch onto the latest trunk.
+// The descendants of the root are not rebased, so they need a restack afterwards.
+func PlanForBaseBump(
+	tx meta.ReadTx,
+	branch plumbing.ReferenceName,
+) ([]sequencer.RestackOp, error) {
+	root, ok := meta.Root(tx, branch.Short())
+	if !ok {
+		return nil, errors.Errorf("branch %q is not in a stack", branch.Short())
+	}
+	avbr, _ := tx.Branch(root)
+	if avbr.MergeCommit != "" {
+		return nil, errors.Errorf("stack root %q is already merged", root)
+	}
+	if avbr.IsPinned() {
+		return nil, errors.Errorf("stack root %q is pinned (run av unpin first)", root)
+	}
+	if avbr.FrozenBase != "" {
+		return nil, errors.Errorf(
+			"stack root %q has a frozen base (run av unfreeze-base first)", root,
+		)
+	}
+	if avbr.IsFollowed() {
+		return nil, errors.Errorf(
+			"stack root %q is followed from the remote (run av sync to update it)", root,
+		)
+	}
+	return []sequencer.RestackOp{{
+		Name:             plumbing.NewBranchReferenceName(root),
+		NewParent:        plumbing.NewBranchReferenceName(avbr.Parent.Name),
+		NewParentIsTrunk: true,
+	}}, nil
 }
 
 func PlanForReparent(
@@ -107,40 +162,164 @@ func PlanForReparent(
 	repo *git.Repo,
 	currentBranch, newParentBranch plumbing.ReferenceName,
 ) ([]sequencer.RestackOp, error) {
-	if newParentBranch == currentBranch {
-		return nil, errors.New("cannot re-parent to self")
+	if err := checkReparent(tx, currentBranch, newParentBranch); err != nil {
+		return nil, err
 	}
 	children := meta.SubsequentBranches(tx, currentBranch.Short())
-	for _, child := range children {
-		if child == newParentBranch.Short() {
-			return nil, errors.New("cannot re-parent to a child branch")
-		}
-	}
 	isParentTrunk, err := repo.IsTrunkBranch(newParentBranch.Short())
 	if err != nil {
 		return nil, err
 	}
 	var ret []sequencer.RestackOp
-	ret = append(ret, sequencer.RestackOp{
-		Name:             currentBranch,
-		NewParent:        newParentBranch,
-		NewParentIsTrunk: isParentTrunk,
-	})
+	ret = append(ret, newReparentOp(tx, currentBranch, meta.BranchState{
+		Name:  newParentBranch.Short(),
+		Trunk: isParentTrunk,
+	}))
 	for _, child := range children {
 		avbr, _ := tx.Branch(child)
 		if avbr.MergeCommit != "" {
 			// Skip rebasing branches that have merge commits.
 			continue
 		}
-		ret = append(ret, sequencer.RestackOp{
-			Name:             plumbing.NewBranchReferenceName(child),
-			NewParent:        plumbing.NewBranchReferenceName(avbr.Parent.Name),
-			NewParentIsTrunk: avbr.Parent.Trunk,
-		})
+		if avbr.IsPinned() {
+			// Skip rebasing pinned branches.
+			continue
+		}
+		ret = append(ret, newRestackOp(tx, plumbing.NewBranchReferenceName(child), avbr.Parent))
 	}
 	return ret, nil
 }
 
+// PlanForReparentBranches plans re-parenting all the given branches onto the same new parent.
+// The descendants of the branches that are not in the list are restacked onto their current
+// parents.
+func PlanForReparentBranches(
+	tx meta.ReadTx,
+	repo *git.Repo,
+	branches []plumbing.ReferenceName,
+	newParentBranch plumbing.ReferenceName,
+) ([]sequencer.RestackOp, error) {
+	listed := map[string]bool{}
+	for _, br := range branches {
+		if err := checkReparent(tx, br, newParentBranch); err != nil {
+			return nil, errors.WrapIff(err, "cannot re-parent %q", br.Short())
+		}
+		listed[br.Short()] = true
+	}
+	isParentTrunk, err := repo.IsTrunkBranch(newParentBranch.Short())
+	if err != nil {
+		return nil, err
+	}
+	newParent := meta.BranchState{Name: newParentBranch.Short(), Trunk: isParentTrunk}
+
+	// The branches are expected to be in the dependency order, so a listed branch that is a
+	// descendant of another listed branch is already visited below.
+	var ret []sequencer.RestackOp
+	visited := map[string]bool{}
+	for _, br := range branches {
+		if visited[br.Short()] {
+			continue
+		}
+		visited[br.Short()] = true
+		ret = append(ret, newReparentOp(tx, br, newParent))
+		for _, child := range meta.SubsequentBranches(tx, br.Short()) {
+			visited[child] = true
+			if listed[child] {
+				ret = append(ret, newReparentOp(tx, plumbing.NewBranchReferenceName(child), newParent))
+				continue
+			}
+			avbr, _ := tx.Branch(child)
+			if avbr.MergeCommit != "" || avbr.IsPinned() {
+				// Skip rebasing merged and pinned branches.
+				continue
+			}
+			ret = append(ret, newRestackOp(tx, plumbing.NewBranchReferenceName(child), avbr.Parent))
+		}
+	}
+	return ret, nil
+}
+
+// PlanForReparentOnly plans re-parenting only the given branch onto the new parent. The children
+// of the branch are moved onto the branch's current parent so that they stay in the original
+// stack. Since the children are moved first, the new parent can be one of them.
+func PlanForReparentOnly(
+	tx meta.ReadTx,
+	repo *git.Repo,
+	branch, newParentBranch plumbing.ReferenceName,
+) ([]sequencer.RestackOp, error) {
+	if newParentBranch == branch {
+		return nil, errors.New("cannot re-parent to self")
+	}
+	avbr, _ := tx.Branch(branch.Short())
+	if avbr.IsPinned() {
+		return nil, errors.Errorf(
+			"cannot re-parent a pinned branch (run 'av unpin %s' first)", branch.Short(),
+		)
+	}
+	if avbr.IsFollowed() {
+		return nil, errors.Errorf("cannot re-parent a followed branch %q", branch.Short())
+	}
+	isParentTrunk, err := repo.IsTrunkBranch(newParentBranch.Short())
+	if err != nil {
+		return nil, err
+	}
+	ret := planMoveChildrenToParent(tx, avbr)
+	ret = append(ret, newReparentOp(tx, branch, meta.BranchState{
+		Name:  newParentBranch.Short(),
+		Trunk: isParentTrunk,
+	}))
+	return ret, nil
+}
+
+// PlanForAbandon plans moving the children of the branch onto the branch's parent so that the
+// branch can be deleted without orphaning them.
+func PlanForAbandon(tx meta.ReadTx, branch plumbing.ReferenceName) []sequencer.RestackOp {
+	avbr, _ := tx.Branch(branch.Short())
+	return planMoveChildrenToParent(tx, avbr)
+}
+
+// planMoveChildrenToParent plans re-parenting the children of the branch onto the branch's
+// parent and restacking their descendants.
+func planMoveChildrenToParent(tx meta.ReadTx, avbr meta.Branch) []sequencer.RestackOp {
+	var ret []sequencer.RestackOp
+	for _, child := range meta.Children(tx, avbr.Name) {
+		if child.MergeCommit != "" || child.IsPinned() {
+			// Merged and pinned branches are not rebased, so they stay on the branch.
+			continue
+		}
+		ret = append(ret, newReparentOp(tx, plumbing.NewBranchReferenceName(child.Name), avbr.Parent))
+		for _, desc := range meta.SubsequentBranches(tx, child.Name) {
+			descbr, _ := tx.Branch(desc)
+			if descbr.MergeCommit != "" || descbr.IsPinned() {
+				continue
+			}
+			ret = append(ret, newRestackOp(tx, plumbing.NewBranchReferenceName(desc), descbr.Parent))
+		}
+	}
+	return ret
+}
+
+func checkReparent(tx meta.ReadTx, branch, newParentBranch plumbing.ReferenceName) error {
+	if newParentBranch == branch {
+		return errors.New("cannot re-parent to self")
+	}
+	for _, child := range meta.SubsequentBranches(tx, branch.Short()) {
+		if child == newParentBranch.Short() {
+			return errors.New("cannot re-parent to a child branch")
+		}
+	}
+	avbr, _ := tx.Branch(branch.Short())
+	if avbr.IsPinned() {
+		return errors.Errorf(
+			"cannot re-parent a pinned branch (run 'av unpin %s' first)", branch.Short(),
+		)
+	}
+	if avbr.IsFollowed() {
+		return errors.Errorf("cannot re-parent a followed branch %q", branch.Short())
+	}
+	return nil
+}
+
 func PlanForAmend(
 	tx meta.ReadTx,
 	repo *git.Repo,
@@ -153,11 +332,53 @@ func PlanForAmend(
 			// Skip rebasing branches that have merge commits.
 			continue
 		}
-		ret = append(ret, sequencer.RestackOp{
-			Name:             plumbing.NewBranchReferenceName(child),
-			NewParent:        plumbing.NewBranchReferenceName(avbr.Parent.Name),
-			NewParentIsTrunk: avbr.Parent.Trunk,
-		})
+		if avbr.IsPinned() {
+			// Skip rebasing pinned branches.
+			continue
+		}
+		ret = append(ret, newRestackOp(tx, plumbing.NewBranchReferenceName(child), avbr.Parent))
 	}
 	return ret, nil
 }
+
+// newRestackOp creates an operation that rebases the branch onto the given parent. If the parent
+// is pinned, the branch is rebased onto the pinned commit instead of the parent's head. If the
+// parent is trunk and the branch has a frozen base, the branch is rebased onto the frozen base
+// instead of the latest trunk.
+func newRestackOp(
+	tx meta.ReadTx,
+	branch plumbing.ReferenceName,
+	parent meta.BranchState,
+) sequencer.RestackOp {
+	op := sequencer.RestackOp{
+		Name:             branch,
+		NewParent:        plumbing.NewBranchReferenceName(parent.Name),
+		NewParentIsTrunk: parent.Trunk,
+	}
+	if parent.Trunk {
+		if avbr, _ := tx.Branch(branch.Short()); avbr.FrozenBase != "" {
+			op.NewParentHash = plumbing.NewHash(avbr.FrozenBase)
+		}
+	} else {
+		if avpbr, _ := tx.Branch(parent.Name); avpbr.IsPinned() {
+			op.NewParentHash = plumbing.NewHash(avpbr.PinnedCommit)
+		}
+	}
+	return op
+}
+
+// newReparentOp creates an operation that rebases the branch onto the new parent. A stack built
+// on a tag or a commit (see meta.Branch.BaseRef) is retargeted to the new parent, so the
+// branch is rebased onto the new parent instead of its frozen base.
+func newReparentOp(
+	tx meta.ReadTx,
+	branch plumbing.ReferenceName,
+	newParent meta.BranchState,
+) sequencer.RestackOp {
+	op := newRestackOp(tx, branch, newParent)
+	if avbr, _ := tx.Branch(branch.Short()); avbr.BaseRef != "" && newParent.Trunk {
+		op.NewParentHash = plumbing.ZeroHash
+		op.ClearFrozenBase = true
+	}
+	return op
+}
@@ -1,6 +1,9 @@
 package planner
 
 import (
+	"sort"
+
+	"github.com/aviator-co/av/internal/actions"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/go-git/go-git/v5/plumbing"
@@ -96,3 +99,30 @@ func GetTargetBranches(
 	}
 	return ret, nil
 }
+
+// GetTargetBranchesFromList returns the given branches (e.g., the branches read from the
+// standard input) in the dependency order, so that a parent branch is restacked before its
+// children. Duplicated branches are removed.
+func GetTargetBranchesFromList(tx meta.ReadTx, names []string) ([]plumbing.ReferenceName, error) {
+	depths := map[string]int{}
+	var unique []string
+	for _, n := range names {
+		if _, seen := depths[n]; seen {
+			continue
+		}
+		prevs, err := meta.PreviousBranches(tx, n)
+		if err != nil {
+			return nil, actions.BranchNotAdoptedError{Branch: n}
+		}
+		depths[n] = len(prevs)
+		unique = append(unique, n)
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		return depths[unique[i]] < depths[unique[j]]
+	})
+	var ret []plumbing.ReferenceName
+	for _, n := range unique {
+		ret = append(ret, plumbing.NewBranchReferenceName(n))
+	}
+	return ret, nil
+}
@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"emperror.dev/errors"
+	avconfig "github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/aviator-co/av/internal/utils/progress"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/sirupsen/logrus"
 )
 
 type RestackOp struct {
@@ -25,6 +30,14 @@ type RestackOp struct {
 	// branch hash if the new parent is not trunk. Or if the new parent is trunk, the sequencer
 	// will use the remote tracking branch's hash.
 	NewParentHash plumbing.Hash
+
+	// If true, the frozen base of the branch is cleared after the rebase (e.g., when a stack
+	// built on a tag is retargeted to a branch).
+	ClearFrozenBase bool
+
+	// If set, the branch is a stack root built on a tag or a commit (NewParentHash). The commit
+	// is recorded as the frozen base of the branch together with this ref name after the rebase.
+	NewBaseRef string
 }
 
 type branchSnapshot struct {
@@ -53,6 +66,9 @@ type Sequencer struct {
 	CurrentSyncRef plumbing.ReferenceName
 	// If the rebase is stopped, these fields are set.
 	SequenceInterruptedNewParentHash plumbing.Hash
+	// If true, the commits that are not signed off are rebased with git rebase --signoff. The
+	// branches whose commits are all signed off are rebased as usual.
+	Signoff bool
 
 	Operations []RestackOp
 }
@@ -67,6 +83,7 @@ func NewSequencer(remoteName string, db meta.DB, ops []RestackOp) *Sequencer {
 		OriginalBranchSnapshots: getBranchSnapshots(db),
 		Operations:              ops,
 		CurrentSyncRef:          currentSyncRef,
+		Signoff:                 avconfig.Av.Commit.Signoff,
 	}
 }
 
@@ -115,7 +132,7 @@ func (seq *Sequencer) runFromInterruptedState(
 	}
 	if seqAbort {
 		// Abort the rebase if we need to
-		if stat, _ := os.Stat(filepath.Join(repo.GitDir(), "REBASE_HEAD")); stat != nil {
+		if stat, _ := os.Stat(filepath.Join(repo.WorktreeGitDir(), "REBASE_HEAD")); stat != nil {
 			if _, err := repo.Rebase(git.RebaseOpts{Abort: true}); err != nil {
 				return nil, errors.Errorf("failed to abort in-progress rebase: %v", err)
 			}
@@ -128,70 +145,112 @@ func (seq *Sequencer) runFromInterruptedState(
 		if err := seq.checkNoUnstagedChanges(repo); err != nil {
 			return nil, err
 		}
-		result, err := repo.RebaseParse(git.RebaseOpts{Continue: true})
+		result, err := seq.rebase(repo, git.RebaseOpts{Continue: true})
 		if err != nil {
 			return nil, errors.Errorf("failed to continue in-progress rebase: %v", err)
 		}
 		if result.Status == git.RebaseConflict {
+			addSubmoduleConflictHeadline(repo, result)
 			return result, nil
 		}
 		if err := seq.postRebaseBranchUpdate(db, seq.SequenceInterruptedNewParentHash); err != nil {
 			return nil, err
 		}
-		return result, nil
+		return result, UpdateSubmodules(repo)
 	}
 	if seqSkip {
-		result, err := repo.RebaseParse(git.RebaseOpts{Skip: true})
+		result, err := seq.rebase(repo, git.RebaseOpts{Skip: true})
 		if err != nil {
 			return nil, errors.Errorf("failed to skip in-progress rebase: %v", err)
 		}
 		if result.Status == git.RebaseConflict {
+			addSubmoduleConflictHeadline(repo, result)
 			return result, nil
 		}
 		if err := seq.postRebaseBranchUpdate(db, seq.SequenceInterruptedNewParentHash); err != nil {
 			return nil, err
 		}
-		return result, nil
+		return result, UpdateSubmodules(repo)
 	}
 	panic("unreachable")
 }
 
 func (seq *Sequencer) rebaseBranch(repo *git.Repo, db meta.DB) (*git.RebaseResult, error) {
 	op := seq.getCurrentOp()
-	snapshot, ok := seq.OriginalBranchSnapshots[op.Name]
-	if !ok {
-		panic(fmt.Sprintf("branch %q not found in original branch infos", op.Name))
+	progress.Report(progress.PhaseRestack, op.Name.Short(), seq.currentOpIndex(), len(seq.Operations))
+	previousParentHash, newParentHash, err := seq.parentHashes(repo, op)
+	if err != nil {
+		return nil, err
 	}
 
-	var previousParentHash plumbing.Hash
-	if snapshot.IsParentTrunk {
-		// Use the current remote tracking branch hash as the previous parent hash.
-		var err error
-		previousParentHash, err = seq.getRemoteTrackingBranchCommit(repo, snapshot.ParentBranch)
+	signoff := false
+	if seq.Signoff {
+		commits, err := repo.CommitsToSignoff(previousParentHash.String() + ".." + op.Name.String())
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		previousParentHash = snapshot.PreviouslySyncedParentBranchHash
+		signoff = len(commits) > 0
 	}
 
-	var newParentHash plumbing.Hash
-	if op.NewParentHash.IsZero() {
-		if op.NewParentIsTrunk {
-			var err error
-			newParentHash, err = seq.getRemoteTrackingBranchCommit(repo, op.NewParent)
-			if err != nil {
+	if !signoff {
+		// If the parent has only moved forward to a commit that the branch already contains
+		// (e.g., the parent was fast-forwarded), the branch is already on top of the new
+		// parent. Only the recorded parent head is updated so that the commits are not
+		// rewritten (which would retrigger CI for nothing).
+		fastForwarded, err := isParentFastForwarded(
+			repo, op.Name.String(), previousParentHash, newParentHash,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if fastForwarded {
+			if err := seq.postRebaseBranchUpdate(db, newParentHash); err != nil {
 				return nil, err
 			}
-		} else {
-			var err error
-			newParentHash, err = seq.getBranchCommit(repo, op.NewParent)
+			return &git.RebaseResult{Status: git.RebaseAlreadyUpToDate}, nil
+		}
+	}
+
+	// The branch cannot be rebased if it's checked out in another worktree.
+	if err := repo.CheckNotInOtherWorktree(op.Name.Short()); err != nil {
+		return nil, err
+	}
+
+	sign := avconfig.Av.Restack.SignCommits
+	if sign {
+		// Check the signing key first so that the rebase doesn't stop at the first commit.
+		if err := repo.CheckSigningKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Neither git replay nor the in-memory rebase can add the sign-offs, sign the commits, or
+	// keep the dates.
+	if !avconfig.Av.Restack.NoInMemoryRebase && !signoff && !sign &&
+		!avconfig.Av.Restack.CommitterDateIsAuthorDate {
+		// Move the commits without touching the working tree: with git replay (Git 2.44 or
+		// later) if possible, and with git merge-tree otherwise. This falls back to git rebase
+		// for the checked-out branch and on conflicts.
+		rebased, err := repo.ReplayBranch(
+			op.Name.Short(), previousParentHash.String(), newParentHash.String(),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if !rebased {
+			rebased, err = repo.RebaseInMemory(
+				op.Name.Short(), previousParentHash.String(), newParentHash.String(),
+			)
 			if err != nil {
 				return nil, err
 			}
 		}
-	} else {
-		newParentHash = op.NewParentHash
+		if rebased {
+			if err := seq.postRebaseBranchUpdate(db, newParentHash); err != nil {
+				return nil, err
+			}
+			return &git.RebaseResult{Status: git.RebaseUpdated}, nil
+		}
 	}
 
 	// The commits from `rebaseFrom` to `snapshot.Name` should be rebased onto `rebaseOnto`.
@@ -199,8 +258,12 @@ func (seq *Sequencer) rebaseBranch(repo *git.Repo, db meta.DB) (*git.RebaseResul
 		Branch:   op.Name.Short(),
 		Upstream: previousParentHash.String(),
 		Onto:     newParentHash.String(),
+		Signoff:  signoff,
+		Sign:     sign,
+
+		CommitterDateIsAuthorDate: avconfig.Av.Restack.CommitterDateIsAuthorDate,
 	}
-	result, err := repo.RebaseParse(opts)
+	result, err := seq.rebase(repo, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -211,13 +274,144 @@ func (seq *Sequencer) rebaseBranch(repo *git.Repo, db meta.DB) (*git.RebaseResul
 			op.NewParent,
 			previousParentHash.String()[:7],
 		) + result.ErrorHeadline
+		addSubmoduleConflictHeadline(repo, result)
 		seq.SequenceInterruptedNewParentHash = newParentHash
 		return result, nil
 	}
 	if err := seq.postRebaseBranchUpdate(db, newParentHash); err != nil {
 		return nil, err
 	}
-	return result, nil
+	// git rebase checks out the branch, so its submodules are updated as well.
+	return result, UpdateSubmodules(repo)
+}
+
+// UpdateSubmodules checks out the submodule commits of the checked-out branch if the submodules
+// are out of sync (e.g., after the branch is rebased onto a parent that changed them), unless
+// it's disabled by the restack.noSubmoduleUpdate config.
+func UpdateSubmodules(repo *git.Repo) error {
+	if avconfig.Av.Restack.NoSubmoduleUpdate {
+		return nil
+	}
+	stale, err := repo.StaleSubmodules()
+	if err != nil || len(stale) == 0 {
+		return err
+	}
+	logrus.WithField("submodules", stale).Debug("updating the submodules")
+	return repo.UpdateSubmodules()
+}
+
+// addSubmoduleConflictHeadline explains how to resolve the submodule conflicts of the stopped
+// rebase, since they can't be resolved by editing the conflict markers.
+func addSubmoduleConflictHeadline(repo *git.Repo, result *git.RebaseResult) {
+	files, err := repo.ConflictedFiles()
+	if err != nil {
+		logrus.WithError(err).Debug("failed to list the conflicted files")
+		return
+	}
+	var paths []string
+	for _, file := range files {
+		if file.Submodule {
+			paths = append(paths, file.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	result.ErrorHeadline = strings.TrimRight(result.ErrorHeadline, "\n") + "\n" + fmt.Sprintf(
+		"Submodule conflict in %s needs manual resolution: check out the commit to use in the "+
+			"submodule (git -C <path> checkout <commit>) and stage it with git add <path>.",
+		strings.Join(paths, ", "),
+	)
+}
+
+// parentHashes returns the commit that the branch of the operation is currently based on (the
+// previous parent) and the commit that it should be rebased onto (the new parent).
+func (seq *Sequencer) parentHashes(
+	repo *git.Repo,
+	op RestackOp,
+) (previousParentHash plumbing.Hash, newParentHash plumbing.Hash, err error) {
+	snapshot, ok := seq.OriginalBranchSnapshots[op.Name]
+	if !ok {
+		panic(fmt.Sprintf("branch %q not found in original branch infos", op.Name))
+	}
+
+	if snapshot.IsParentTrunk {
+		// Use the current remote tracking branch hash as the previous parent hash.
+		previousParentHash, err = seq.getRemoteTrackingBranchCommit(repo, snapshot.ParentBranch)
+		if err != nil {
+			return plumbing.ZeroHash, plumbing.ZeroHash, err
+		}
+	} else {
+		previousParentHash = snapshot.PreviouslySyncedParentBranchHash
+	}
+
+	switch {
+	case !op.NewParentHash.IsZero():
+		newParentHash = op.NewParentHash
+	case op.NewParentIsTrunk:
+		newParentHash, err = seq.getRemoteTrackingBranchCommit(repo, op.NewParent)
+	default:
+		newParentHash, err = seq.getBranchCommit(repo, op.NewParent)
+	}
+	if err != nil {
+		return plumbing.ZeroHash, plumbing.ZeroHash, err
+	}
+	return previousParentHash, newParentHash, nil
+}
+
+// isParentFastForwarded returns true if the new parent commit descends from the previous parent
+// commit and the branch already contains the new parent commit.
+func isParentFastForwarded(
+	repo *git.Repo,
+	branch string,
+	previousParentHash, newParentHash plumbing.Hash,
+) (bool, error) {
+	if previousParentHash.IsZero() || newParentHash.IsZero() {
+		return false, nil
+	}
+	if ok, err := repo.IsAncestor(previousParentHash.String(), newParentHash.String()); err != nil || !ok {
+		return false, err
+	}
+	return repo.IsAncestor(newParentHash.String(), branch)
+}
+
+// rebase runs git rebase with git rerere if enabled. If rerere resolves all the conflicts with
+// the recorded resolutions (e.g., the same conflict was resolved in a parent branch), the rebase
+// is continued automatically.
+func (seq *Sequencer) rebase(repo *git.Repo, opts git.RebaseOpts) (*git.RebaseResult, error) {
+	opts.Rerere = avconfig.Av.Restack.Rerere
+	for {
+		result, err := repo.RebaseParse(opts)
+		if err != nil || result.Status != git.RebaseConflict || !result.UsedRerere() {
+			return result, err
+		}
+		files, err := repo.ConflictedFiles()
+		if err != nil {
+			return nil, err
+		}
+		if len(files) > 0 {
+			return result, nil
+		}
+		logrus.Debug("git rerere resolved all the conflicts, continuing the rebase")
+		opts = git.RebaseOpts{Continue: true, Rerere: opts.Rerere}
+	}
+}
+
+// CheckCleanWorktree returns an error if the working tree has uncommitted changes to the tracked
+// files. Such changes make the first rebase fail, so a restack must not be started (or its state
+// saved) with them unless they are stashed first.
+func CheckCleanWorktree(repo *git.Repo, command string) error {
+	status, err := repo.Status()
+	if err != nil {
+		return err
+	}
+	if !status.IsCleanIgnoringUntracked() {
+		return errutils.WithFailureClass(errors.Errorf(
+			"refusing to run %s: the working tree has uncommitted changes (commit or stash them, or use --autostash)",
+			command,
+		), errutils.FailureDirtyWorktree)
+	}
+	return nil
 }
 
 func (seq *Sequencer) checkNoUnstagedChanges(repo *git.Repo) error {
@@ -226,9 +420,9 @@ func (seq *Sequencer) checkNoUnstagedChanges(repo *git.Repo) error {
 		return err
 	}
 	if !diff.Empty {
-		return errors.New(
+		return errutils.WithFailureClass(errors.New(
 			"refusing to sync: there are unstaged changes in the working tree (use `git add` to stage changes)",
-		)
+		), errutils.FailureDirtyWorktree)
 	}
 	return nil
 }
@@ -246,6 +440,16 @@ func (seq *Sequencer) postRebaseBranchUpdate(db meta.DB, newParentHash plumbing.
 	tx := db.WriteTx()
 	br, _ := tx.Branch(op.Name.Short())
 	br.Parent = newParentBranchState
+	if !op.NewParentIsTrunk || op.ClearFrozenBase {
+		// The frozen base only applies to the stack roots.
+		br.FrozenBase = ""
+		br.BaseRef = ""
+	}
+	if op.NewBaseRef != "" && op.NewParentIsTrunk {
+		br.FrozenBase = newParentHash.String()
+		br.BaseRef = op.NewBaseRef
+	}
+	br.Conflict = nil
 	tx.SetBranch(br)
 	if err := tx.Commit(); err != nil {
 		return err
@@ -258,12 +462,23 @@ func (seq *Sequencer) postRebaseBranchUpdate(db meta.DB, newParentHash plumbing.
 			} else {
 				seq.CurrentSyncRef = plumbing.ReferenceName("")
 			}
+			progress.Report(progress.PhaseRestack, op.Name.Short(), i+1, len(seq.Operations))
 			break
 		}
 	}
 	return nil
 }
 
+// currentOpIndex returns the index of the operation that is currently being synced.
+func (seq *Sequencer) currentOpIndex() int {
+	for i, op := range seq.Operations {
+		if op.Name == seq.CurrentSyncRef {
+			return i
+		}
+	}
+	return len(seq.Operations)
+}
+
 func (seq *Sequencer) getCurrentOp() RestackOp {
 	for _, op := range seq.Operations {
 		if op.Name == seq.CurrentSyncRef {
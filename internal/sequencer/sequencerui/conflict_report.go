@@ -0,0 +1,59 @@
+package sequencerui
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// maxConflictReportParentCommits is the maximum number of the parent branch commits recorded
+// in a conflict report.
+const maxConflictReportParentCommits = 10
+
+// NewConflictReport returns the report of the conflict of the interrupted rebase of the branch
+// onto the parent branch. newParentHash is the commit that the branch is rebased onto, and it's
+// used to find the commits of the parent branch that changed the conflicted files. command is
+// the command that continues the rebase (e.g., "av sync").
+func NewConflictReport(
+	repo *git.Repo,
+	parent string,
+	newParentHash plumbing.Hash,
+	command string,
+) (*meta.BranchConflict, error) {
+	files, err := repo.ConflictedFiles()
+	if err != nil {
+		return nil, err
+	}
+	report := &meta.BranchConflict{
+		DetectedAt: time.Now(),
+		Parent:     parent,
+		Files:      []string{},
+	}
+	for _, file := range files {
+		report.Files = append(report.Files, file.Path)
+	}
+	report.Commit, _ = repo.Git("log", "-1", "--format=%h %s", "REBASE_HEAD")
+
+	if !newParentHash.IsZero() && len(report.Files) > 0 {
+		// The commits that are in the new parent but not in the conflicting commit are the
+		// changes of the parent branch that the commit is replayed on.
+		args := []string{
+			"log", "--format=%h %s", "-n", strconv.Itoa(maxConflictReportParentCommits),
+			newParentHash.String(), "^REBASE_HEAD", "--",
+		}
+		args = append(args, report.Files...)
+		if out, err := repo.Git(args...); err == nil && out != "" {
+			report.ParentCommits = strings.Split(out, "\n")
+		}
+	}
+
+	if len(report.Files) > 0 {
+		report.Commands = append(report.Commands, "git add -- "+strings.Join(report.Files, " "))
+	}
+	report.Commands = append(report.Commands, command+" --continue")
+	return report, nil
+}
@@ -3,6 +3,7 @@ package sequencerui
 import (
 	"strings"
 
+	"github.com/aviator-co/av/internal/config"
 	"github.com/aviator-co/av/internal/git"
 	"github.com/aviator-co/av/internal/meta"
 	"github.com/aviator-co/av/internal/sequencer"
@@ -11,6 +12,7 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/sirupsen/logrus"
 )
 
 func NewRestackModel(repo *git.Repo, db meta.DB) *RestackModel {
@@ -19,6 +21,7 @@ func NewRestackModel(repo *git.Repo, db meta.DB) *RestackModel {
 		db:      db,
 		spinner: spinner.New(spinner.WithSpinner(spinner.Dot)),
 		Command: "av restack",
+		Explain: !config.UserState.ExplainedConflict,
 	}
 }
 
@@ -27,6 +30,9 @@ type RestackState struct {
 	RestackingAll   bool
 	RelatedBranches []string
 	Seq             *sequencer.Sequencer
+	// The stash commit of the uncommitted changes that are stashed before the restack (see
+	// git.Repo.Autostash). The changes are restored when the restack is done or aborted.
+	Autostash string
 }
 
 type RestackProgress struct {
@@ -42,9 +48,11 @@ type RestackModel struct {
 	Skip     bool
 	Continue bool
 	Abort    bool
-	DryRun   bool
 	State    *RestackState
 	Command  string
+	// If true, the step-by-step walkthrough of resolving the conflict is shown on a conflict.
+	// This is shown on the first conflict of the user by default.
+	Explain bool
 
 	repo *git.Repo
 	db   meta.DB
@@ -52,7 +60,18 @@ type RestackModel struct {
 	spinner                     spinner.Model
 	rebaseConflictErrorHeadline string
 	rebaseConflictHint          string
+	conflictWalkthrough         string
 	abortedBranch               plumbing.ReferenceName
+
+	// The binary file conflicts that are waiting for the user to choose a version.
+	binaryConflicts []git.ConflictedFile
+	// The messages about the binary file conflicts that are resolved for the current rebase.
+	binaryConflictResolutions []string
+	// True if there are binary file conflicts left for the user to resolve manually.
+	hasUnresolvedBinaryConflicts bool
+	// True if the stashed changes are restored, and the error if they couldn't be restored.
+	autostashRestored bool
+	autostashErr      error
 }
 
 func (vm *RestackModel) Init() tea.Cmd {
@@ -78,8 +97,19 @@ func (vm *RestackModel) Update(msg tea.Msg) (*RestackModel, tea.Cmd) {
 				if _, err := vm.repo.CheckoutBranch(&git.CheckoutBranch{Name: vm.State.InitialBranch}); err != nil {
 					return vm, func() tea.Msg { return err }
 				}
+				if err := sequencer.UpdateSubmodules(vm.repo); err != nil {
+					return vm, func() tea.Msg { return err }
+				}
+			}
+			if vm.State.Autostash != "" {
+				// A failure to restore the changes doesn't fail the restack. The changes
+				// are kept in the stash.
+				vm.autostashErr = vm.repo.AutostashPop(vm.State.Autostash)
+				vm.autostashRestored = vm.autostashErr == nil
+				vm.State.Autostash = ""
 			}
 			if vm.abortedBranch != "" {
+				vm.updateAbortedConflictReport()
 				return vm, func() tea.Msg { return &RestackAbort{} }
 			}
 			return vm, func() tea.Msg { return &RestackDone{} }
@@ -87,8 +117,14 @@ func (vm *RestackModel) Update(msg tea.Msg) (*RestackModel, tea.Cmd) {
 		if msg.result != nil && msg.result.Status == git.RebaseConflict {
 			vm.rebaseConflictErrorHeadline = msg.result.ErrorHeadline
 			vm.rebaseConflictHint = msg.result.Hint
-			return vm, func() tea.Msg { return &RestackConflict{} }
+			vm.prepareConflictWalkthrough()
+			vm.saveConflictReport()
+			return vm, vm.handleBinaryConflicts()
 		}
+		vm.rebaseConflictErrorHeadline = ""
+		vm.rebaseConflictHint = ""
+		vm.conflictWalkthrough = ""
+		vm.binaryConflictResolutions = nil
 		if msg.err != nil {
 			return vm, func() tea.Msg { return msg.err }
 		}
@@ -97,10 +133,194 @@ func (vm *RestackModel) Update(msg tea.Msg) (*RestackModel, tea.Cmd) {
 		var cmd tea.Cmd
 		vm.spinner, cmd = vm.spinner.Update(msg)
 		return vm, cmd
+	case tea.KeyMsg:
+		if !vm.IsPrompting() {
+			return vm, nil
+		}
+		switch msg.String() {
+		case "p":
+			return vm, vm.resolveBinaryConflict(git.ConflictSideOurs)
+		case "b":
+			return vm, vm.resolveBinaryConflict(git.ConflictSideTheirs)
+		case "s":
+			return vm, vm.resolveBinaryConflict(0)
+		case "ctrl+c":
+			// Leave the rest to the user so that the restack state is saved and the
+			// restack can be continued later.
+			vm.binaryConflicts = nil
+			vm.hasUnresolvedBinaryConflicts = true
+			return vm, func() tea.Msg { return &RestackConflict{} }
+		}
 	}
 	return vm, nil
 }
 
+// prepareConflictWalkthrough prepares the walkthrough of resolving the conflict if it should be
+// shown. The walkthrough is shown only once by default, so it's marked as seen.
+func (vm *RestackModel) prepareConflictWalkthrough() {
+	if !vm.Explain {
+		return
+	}
+	walkthrough, err := ConflictWalkthrough(vm.repo, vm.State.Seq.CurrentSyncRef.Short(), vm.Command)
+	if err != nil {
+		logrus.WithError(err).Debug("failed to prepare the conflict walkthrough")
+		return
+	}
+	vm.conflictWalkthrough = walkthrough
+	if !config.UserState.ExplainedConflict {
+		config.UserState.ExplainedConflict = true
+		if err := config.SaveUserState(); err != nil {
+			logrus.WithError(err).Debug("failed to save the user state")
+		}
+	}
+}
+
+// saveConflictReport saves the report of the conflict of the current rebase to the branch
+// metadata so that the conflicted branch can be shown later (e.g., in av tree) even after the
+// rebase is aborted. The report is cleared when the branch is restacked successfully.
+func (vm *RestackModel) saveConflictReport() {
+	var parent string
+	for _, op := range vm.State.Seq.Operations {
+		if op.Name == vm.State.Seq.CurrentSyncRef {
+			parent = op.NewParent.Short()
+		}
+	}
+	report, err := NewConflictReport(
+		vm.repo, parent, vm.State.Seq.SequenceInterruptedNewParentHash, vm.Command,
+	)
+	if err != nil {
+		logrus.WithError(err).Debug("failed to prepare the conflict report")
+		return
+	}
+	tx := vm.db.WriteTx()
+	br, _ := tx.Branch(vm.State.Seq.CurrentSyncRef.Short())
+	br.Conflict = report
+	tx.SetBranch(br)
+	if err := tx.Commit(); err != nil {
+		logrus.WithError(err).Debug("failed to save the conflict report")
+	}
+}
+
+// updateAbortedConflictReport updates the suggested commands of the conflict report of the
+// aborted branch. The rebase is undone, so the conflict is reproduced by running the command
+// again instead of continuing it.
+func (vm *RestackModel) updateAbortedConflictReport() {
+	tx := vm.db.WriteTx()
+	br, _ := tx.Branch(vm.abortedBranch.Short())
+	if br.Conflict == nil {
+		tx.Abort()
+		return
+	}
+	br.Conflict.Commands = []string{vm.Command}
+	tx.SetBranch(br)
+	if err := tx.Commit(); err != nil {
+		logrus.WithError(err).Debug("failed to update the conflict report")
+	}
+}
+
+// IsPrompting returns true if the model is waiting for the user to choose how to resolve a
+// binary file conflict. The key messages should be forwarded to the model while prompting.
+func (vm *RestackModel) IsPrompting() bool {
+	return len(vm.binaryConflicts) > 0
+}
+
+// handleBinaryConflicts resolves the binary file conflicts of the current rebase with the
+// configured default resolutions, and prompts the user for the rest. Binary files can't be
+// resolved by editing the conflict markers, so the user needs to choose one of the versions.
+func (vm *RestackModel) handleBinaryConflicts() tea.Cmd {
+	vm.binaryConflicts = nil
+	vm.hasUnresolvedBinaryConflicts = false
+	files, err := vm.repo.ConflictedFiles()
+	if err != nil {
+		return func() tea.Msg { return err }
+	}
+	resolved := false
+	for _, file := range files {
+		if !file.Binary {
+			continue
+		}
+		side, ok := defaultBinaryConflictSide(file.Path)
+		if !ok && config.NonInteractive {
+			// Left for the user to resolve manually instead of asking.
+			vm.hasUnresolvedBinaryConflicts = true
+			continue
+		}
+		if !ok {
+			vm.binaryConflicts = append(vm.binaryConflicts, file)
+			continue
+		}
+		if err := vm.repo.ResolveConflict(file, side); err != nil {
+			return func() tea.Msg { return err }
+		}
+		vm.addBinaryConflictResolution(file.Path, side, " (restack.binaryConflicts config)")
+		resolved = true
+	}
+	if vm.IsPrompting() {
+		return nil
+	}
+	if !resolved {
+		return func() tea.Msg { return &RestackConflict{} }
+	}
+	return vm.continueIfResolved
+}
+
+// resolveBinaryConflict resolves the first binary file conflict waiting for the user with the
+// given side. If side is zero, the conflict is left for the user to resolve manually.
+func (vm *RestackModel) resolveBinaryConflict(side git.ConflictSide) tea.Cmd {
+	file := vm.binaryConflicts[0]
+	vm.binaryConflicts = vm.binaryConflicts[1:]
+	if side == 0 {
+		vm.hasUnresolvedBinaryConflicts = true
+	} else {
+		if err := vm.repo.ResolveConflict(file, side); err != nil {
+			return func() tea.Msg { return err }
+		}
+		vm.addBinaryConflictResolution(file.Path, side, "")
+	}
+	if vm.IsPrompting() {
+		return nil
+	}
+	return vm.continueIfResolved
+}
+
+func (vm *RestackModel) addBinaryConflictResolution(path string, side git.ConflictSide, suffix string) {
+	version := "the parent branch's version"
+	if side == git.ConflictSideTheirs {
+		version = "the version of " + vm.State.Seq.CurrentSyncRef.Short()
+	}
+	vm.binaryConflictResolutions = append(
+		vm.binaryConflictResolutions,
+		"Resolved "+path+" with "+version+suffix,
+	)
+}
+
+// continueIfResolved continues the rebase if all the conflicts are resolved.
+func (vm *RestackModel) continueIfResolved() tea.Msg {
+	files, err := vm.repo.ConflictedFiles()
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		return &RestackConflict{}
+	}
+	result, err := vm.State.Seq.Run(vm.repo, vm.db, false, true, false)
+	return &RestackProgress{result: result, err: err}
+}
+
+// defaultBinaryConflictSide returns the configured resolution of the binary file conflict.
+func defaultBinaryConflictSide(path string) (git.ConflictSide, bool) {
+	for _, rule := range config.Av.Restack.BinaryConflicts {
+		if !rule.Match(path) {
+			continue
+		}
+		if rule.Resolve == config.BinaryConflictResolveBranch {
+			return git.ConflictSideTheirs, true
+		}
+		return git.ConflictSideOurs, true
+	}
+	return 0, false
+}
+
 func (vm *RestackModel) View() string {
 	sb := strings.Builder{}
 	if vm.State != nil && vm.State.Seq != nil {
@@ -179,6 +399,37 @@ func (vm *RestackModel) View() string {
 			sb.WriteString("\n")
 		}
 	}
+	if vm.autostashRestored {
+		sb.WriteString("\n")
+		sb.WriteString(colors.SuccessStyle.Render("✓ Restored the stashed changes") + "\n")
+	} else if vm.autostashErr != nil {
+		sb.WriteString("\n")
+		sb.WriteString(colors.FailureStyle.Render(vm.autostashErr.Error()) + "\n")
+		sb.WriteString(
+			"Restore them with " + colors.CliCmd("git stash pop --index") +
+				" after resolving the differences.\n",
+		)
+	}
+	if len(vm.binaryConflictResolutions) > 0 {
+		sb.WriteString("\n")
+		for _, resolution := range vm.binaryConflictResolutions {
+			sb.WriteString(colors.SuccessStyle.Render("✓ "+resolution) + "\n")
+		}
+	}
+	if vm.IsPrompting() {
+		branch := vm.State.Seq.CurrentSyncRef.Short()
+		sb.WriteString("\n")
+		sb.WriteString(
+			colors.FailureStyle.Render(
+				"Binary file conflict while rebasing ", branch, ": ", vm.binaryConflicts[0].Path,
+			) + "\n",
+		)
+		sb.WriteString("The file cannot be merged line by line. Which version do you want to keep?\n")
+		sb.WriteString("  " + colors.CliCmd("p") + "  the parent branch's version\n")
+		sb.WriteString("  " + colors.CliCmd("b") + "  the version of " + branch + "\n")
+		sb.WriteString("  " + colors.CliCmd("s") + "  skip and resolve manually\n")
+		return sb.String()
+	}
 	if vm.rebaseConflictErrorHeadline != "" {
 		sb.WriteString("\n")
 		sb.WriteString(
@@ -189,11 +440,23 @@ func (vm *RestackModel) View() string {
 		)
 		sb.WriteString(vm.rebaseConflictErrorHeadline + "\n")
 		sb.WriteString(vm.rebaseConflictHint + "\n")
+		if vm.hasUnresolvedBinaryConflicts {
+			sb.WriteString(
+				"Binary files cannot be edited. Keep the parent branch's version with " +
+					colors.CliCmd("git checkout --ours <path>") + " or the branch's version with " +
+					colors.CliCmd("git checkout --theirs <path>") + ", then run " +
+					colors.CliCmd("git add <path>") + ".\n",
+			)
+		}
 		sb.WriteString("\n")
+		if vm.conflictWalkthrough != "" {
+			sb.WriteString(vm.conflictWalkthrough)
+			return sb.String()
+		}
 		sb.WriteString(
 			"Resolve the conflicts and continue the restack with " + colors.CliCmd(
 				vm.Command+" --continue",
-			),
+			) + " (run " + colors.CliCmd("av sync --explain") + " for a walkthrough)",
 		)
 	}
 	return sb.String()
@@ -0,0 +1,65 @@
+package sequencerui
+
+import (
+	"strings"
+
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/utils/colors"
+)
+
+// ConflictWalkthrough returns the step-by-step instructions to resolve the conflicts of the
+// interrupted rebase of the branch and to continue it with the command (e.g., "av sync"). The
+// branch can be empty if it's unknown.
+func ConflictWalkthrough(repo *git.Repo, branch string, command string) (string, error) {
+	files, err := repo.ConflictedFiles()
+	if err != nil {
+		return "", err
+	}
+	commit, _ := repo.Git("log", "-1", "--format=%h %s", "REBASE_HEAD")
+	if branch == "" {
+		branch = "the branch"
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(colors.Warning("How to resolve the conflict\n"))
+	sb.WriteString("av stopped while rebasing " + branch + " onto its parent branch")
+	if commit != "" {
+		sb.WriteString(" because commit " + colors.UserInput(commit) + "\n")
+		sb.WriteString("changes the same lines as the parent branch")
+	}
+	sb.WriteString(". Your working tree is in the\n")
+	sb.WriteString("middle of the rebase. Nothing is lost, and the rest of the stack is not touched yet.\n\n")
+
+	sb.WriteString("  1. Edit the conflicted files:\n")
+	for _, file := range files {
+		note := ""
+		switch {
+		case file.Binary:
+			note = " (binary: keep one version with " + colors.CliCmd("git checkout --ours|--theirs") + ")"
+		case file.IsDeletedOnOneSide():
+			note = " (deleted on one side: " + colors.CliCmd("git add") + " or " + colors.CliCmd("git rm") + " it)"
+		}
+		sb.WriteString("       - " + colors.UserInput(file.Path) + note + "\n")
+	}
+	if len(files) == 0 {
+		sb.WriteString("       (no conflicted files are left; check " + colors.CliCmd("git status") + ")\n")
+	}
+	sb.WriteString("     In each file, the section between " + colors.UserInput("<<<<<<<") + " and " +
+		colors.UserInput("=======") + " is the parent branch's\n")
+	sb.WriteString("     version, and the section between " + colors.UserInput("=======") + " and " +
+		colors.UserInput(">>>>>>>") + " is the version of " + branch + ".\n")
+	sb.WriteString("     Keep the right code and remove the markers.\n")
+	sb.WriteString("  2. Mark the files as resolved with " + colors.CliCmd("git add <file>") + ".\n")
+	sb.WriteString("     Don't run git commit or git rebase --continue yourself.\n")
+	sb.WriteString("  3. Run " + colors.CliCmd(command+" --continue") + ". av commits the resolution, finishes\n")
+	sb.WriteString("     rebasing " + branch + ", and restacks the rest of the stack. The resolution is\n")
+	sb.WriteString("     recorded, so the same conflict is resolved automatically next time.\n\n")
+
+	sb.WriteString("To stop safely, run " + colors.CliCmd(command+" --abort") + ". The rebase of " + branch +
+		" is undone, and the\n")
+	sb.WriteString("branches that were already restacked are kept (" + colors.CliCmd("av undo") +
+		" restores them after av sync).\n")
+	sb.WriteString("To drop the commit from " + branch + " instead, run " + colors.CliCmd(command+" --skip") + ".\n")
+	sb.WriteString("Run " + colors.CliCmd("av sync --explain") + " to show this again.\n")
+	return sb.String(), nil
+}
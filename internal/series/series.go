@@ -0,0 +1,131 @@
+// Package series reads and writes the series file of a patch queue (as used by Quilt and
+// StGit) that av series exports a stack to.
+//
+// The series file lists the patch files in the order they are applied, one per line. Lines
+// starting with "#" are comments, and anything after the patch name on a line (e.g., Quilt's
+// "-p1") is ignored. av records the stack structure in comments so that other tools still see
+// a plain series file:
+//
+//	# This series applies on GIT commit 0123456789abcdef
+//	# av-trunk: main
+//	# av-branch: feature-one
+//	0001-Add-the-first-feature.patch
+//	# av-branch: feature-two
+//	0002-Add-the-second-feature.patch
+package series
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"emperror.dev/errors"
+)
+
+// FileName is the name of the series file in the patch directory.
+const FileName = "series"
+
+const (
+	baseCommentPrefix   = "# This series applies on GIT commit "
+	trunkCommentPrefix  = "# av-trunk: "
+	branchCommentPrefix = "# av-branch: "
+)
+
+// Series is the content of a series file.
+type Series struct {
+	// The commit that the first patch applies on. Empty if unknown.
+	Base string
+	// The trunk branch that the stack is based on. Empty if unknown.
+	Trunk string
+	// The branches of the stack, parents first. Each branch is stacked on the previous one.
+	// The patches listed before any av-branch comment (e.g., in a series file that is not
+	// written by av) belong to a branch with an empty name.
+	Branches []Branch
+}
+
+// Branch is a branch of the stack and its patches.
+type Branch struct {
+	Name string
+	// The patch file names relative to the patch directory, in the order they are applied.
+	Patches []string
+}
+
+// Patches returns all the patch file names of the series in order.
+func (s *Series) Patches() []string {
+	var ret []string
+	for _, b := range s.Branches {
+		ret = append(ret, b.Patches...)
+	}
+	return ret
+}
+
+// Parse parses a series file.
+func Parse(r io.Reader) (*Series, error) {
+	s := &Series{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, baseCommentPrefix):
+			s.Base = strings.TrimSpace(strings.TrimPrefix(line, baseCommentPrefix))
+		case strings.HasPrefix(line, trunkCommentPrefix):
+			s.Trunk = strings.TrimSpace(strings.TrimPrefix(line, trunkCommentPrefix))
+		case strings.HasPrefix(line, branchCommentPrefix):
+			name := strings.TrimSpace(strings.TrimPrefix(line, branchCommentPrefix))
+			if name == "" {
+				return nil, errors.New("av-branch comment without a branch name")
+			}
+			for _, b := range s.Branches {
+				if b.Name == name {
+					return nil, errors.Errorf("branch %q appears more than once", name)
+				}
+			}
+			s.Branches = append(s.Branches, Branch{Name: name})
+		case strings.HasPrefix(line, "#"):
+		default:
+			if len(s.Branches) == 0 {
+				s.Branches = append(s.Branches, Branch{})
+			}
+			patch := strings.Fields(line)[0]
+			if !isPatchFileName(patch) {
+				return nil, errors.Errorf(
+					"invalid patch name %q (a patch must be a file in the patch directory)", patch,
+				)
+			}
+			last := &s.Branches[len(s.Branches)-1]
+			last.Patches = append(last.Patches, patch)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// isPatchFileName returns true if the patch name is a single file name, so that the patches
+// read from a series file never refer to files outside of the patch directory.
+func isPatchFileName(name string) bool {
+	return name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// Write writes the series file.
+func (s *Series) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if s.Base != "" {
+		fmt.Fprintf(bw, "%s%s\n", baseCommentPrefix, s.Base)
+	}
+	if s.Trunk != "" {
+		fmt.Fprintf(bw, "%s%s\n", trunkCommentPrefix, s.Trunk)
+	}
+	for _, b := range s.Branches {
+		if b.Name != "" {
+			fmt.Fprintf(bw, "%s%s\n", branchCommentPrefix, b.Name)
+		}
+		for _, p := range b.Patches {
+			fmt.Fprintln(bw, p)
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,77 @@
+package series_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/series"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	s, err := series.Parse(strings.NewReader(`# This series applies on GIT commit abc123
+# av-trunk: main
+# av-branch: one
+0001-Add-one.patch
+# a comment from Quilt
+0002-Fix-one.patch -p1
+
+# av-branch: two
+0003-Add-two.patch
+`))
+	require.NoError(t, err)
+	require.Equal(t, &series.Series{
+		Base:  "abc123",
+		Trunk: "main",
+		Branches: []series.Branch{
+			{Name: "one", Patches: []string{"0001-Add-one.patch", "0002-Fix-one.patch"}},
+			{Name: "two", Patches: []string{"0003-Add-two.patch"}},
+		},
+	}, s)
+	require.Equal(t, []string{"0001-Add-one.patch", "0002-Fix-one.patch", "0003-Add-two.patch"}, s.Patches())
+}
+
+func TestParsePlainSeries(t *testing.T) {
+	s, err := series.Parse(strings.NewReader("fix-build.patch\nadd-feature.diff -p0\n"))
+	require.NoError(t, err)
+	require.Equal(t, &series.Series{
+		Branches: []series.Branch{
+			{Patches: []string{"fix-build.patch", "add-feature.diff"}},
+		},
+	}, s)
+}
+
+func TestParseDuplicateBranch(t *testing.T) {
+	_, err := series.Parse(strings.NewReader("# av-branch: one\n# av-branch: one\n"))
+	require.Error(t, err)
+}
+
+func TestParseRejectsPatchOutsideOfDirectory(t *testing.T) {
+	for _, name := range []string{"../../x", "sub/0001.patch", ".."} {
+		_, err := series.Parse(strings.NewReader(name + "\n"))
+		require.Error(t, err, name)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	s := &series.Series{
+		Base:  "abc123",
+		Trunk: "main",
+		Branches: []series.Branch{
+			{Name: "one", Patches: []string{"0001-Add-one.patch"}},
+			{Name: "two"},
+		},
+	}
+	var sb strings.Builder
+	require.NoError(t, s.Write(&sb))
+	require.Equal(t, `# This series applies on GIT commit abc123
+# av-trunk: main
+# av-branch: one
+0001-Add-one.patch
+# av-branch: two
+`, sb.String())
+
+	parsed, err := series.Parse(strings.NewReader(sb.String()))
+	require.NoError(t, err)
+	require.Equal(t, s, parsed)
+}
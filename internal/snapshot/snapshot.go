@@ -0,0 +1,275 @@
+// Package snapshot saves the branches and the av metadata of a stack under a name so that the
+// whole stack can be restored later (e.g., after an experimental reorder or split).
+//
+// A snapshot is a commit referenced by RefPrefix + <name>. The commit has the snapshot as a
+// JSON file in its tree, and the recorded branch commits as its parents so that they are kept
+// by git gc even after the branches are rewritten.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/actions"
+	"github.com/aviator-co/av/internal/git"
+	"github.com/aviator-co/av/internal/meta"
+)
+
+// RefPrefix is the ref namespace of the snapshots.
+const RefPrefix = "refs/av/snapshots/"
+
+// The file name of the snapshot in the tree of the snapshot commit.
+const fileName = "snapshot.json"
+
+// Snapshot is the state of the branches of a stack.
+type Snapshot struct {
+	Name string    `json:"-"`
+	Time time.Time `json:"time"`
+	// The branch that was checked out. Empty if HEAD was detached.
+	CurrentBranch string `json:"currentBranch,omitempty"`
+	// The commits of the branches keyed by the branch name.
+	Refs map[string]string `json:"refs"`
+	// The av metadata of the branches keyed by the branch name.
+	Branches map[string]meta.Branch `json:"branches"`
+}
+
+// BranchNames returns the names of the recorded branches in sorted order.
+func (s *Snapshot) BranchNames() []string {
+	var ret []string
+	for name := range s.Branches {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// ValidateName returns an error if the name cannot be used as a snapshot name.
+func ValidateName(repo *git.Repo, name string) error {
+	out, err := repo.Run(&git.RunOpts{Args: []string{"check-ref-format", RefPrefix + name}})
+	if err != nil {
+		return err
+	}
+	if name == "" || out.ExitCode != 0 {
+		return errors.Errorf("invalid snapshot name %q", name)
+	}
+	return nil
+}
+
+// Save records the current commits and the av metadata of the given branches as a snapshot.
+// An existing snapshot with the same name is replaced only if force is true.
+func Save(
+	repo *git.Repo,
+	tx meta.ReadTx,
+	name string,
+	branches []string,
+	force bool,
+) (*Snapshot, error) {
+	if err := ValidateName(repo, name); err != nil {
+		return nil, err
+	}
+	ref := RefPrefix + name
+	if exists, err := repo.DoesRefExist(ref); err != nil {
+		return nil, err
+	} else if exists && !force {
+		return nil, errors.Errorf("snapshot %q already exists", name)
+	}
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil && !errors.Is(err, git.ErrDetachedHEAD) {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		Name:          name,
+		Time:          time.Now(),
+		CurrentBranch: currentBranch,
+		Refs:          map[string]string{},
+		Branches:      map[string]meta.Branch{},
+	}
+	var parents []string
+	for _, branch := range branches {
+		br, ok := tx.Branch(branch)
+		if !ok {
+			return nil, actions.BranchNotAdoptedError{Branch: branch}
+		}
+		oid, err := repo.RevParse(&git.RevParse{Rev: "refs/heads/" + branch})
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to read branch %q", branch)
+		}
+		snap.Refs[branch] = oid
+		snap.Branches[branch] = br
+		if !slices.Contains(parents, oid) {
+			parents = append(parents, oid)
+		}
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	blob, err := repo.Run(&git.RunOpts{
+		Args:      []string{"hash-object", "-w", "--stdin"},
+		Stdin:     bytes.NewReader(data),
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to write the snapshot")
+	}
+	tree, err := repo.Run(&git.RunOpts{
+		Args: []string{"mktree"},
+		Stdin: strings.NewReader(
+			"100644 blob " + strings.TrimSpace(string(blob.Stdout)) + "\t" + fileName + "\n",
+		),
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to write the snapshot")
+	}
+	commit, err := repo.CommitTree(strings.TrimSpace(string(tree.Stdout)), "av snapshot "+name, parents...)
+	if err != nil {
+		return nil, errors.WrapIf(err, "failed to write the snapshot")
+	}
+	if err := repo.UpdateRef(&git.UpdateRef{Ref: ref, New: commit, CreateReflog: true}); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// Read reads the snapshot of the given name.
+func Read(repo *git.Repo, name string) (*Snapshot, error) {
+	ref := RefPrefix + name
+	if exists, err := repo.DoesRefExist(ref); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, errors.Errorf("snapshot %q does not exist", name)
+	}
+	out, err := repo.Run(&git.RunOpts{
+		Args:      []string{"cat-file", "blob", ref + ":" + fileName},
+		ExitError: true,
+	})
+	if err != nil {
+		return nil, errors.WrapIff(err, "failed to read snapshot %q", name)
+	}
+	snap := &Snapshot{Name: name}
+	if err := json.Unmarshal(out.Stdout, snap); err != nil {
+		return nil, errors.WrapIff(err, "failed to read snapshot %q", name)
+	}
+	for name, br := range snap.Branches {
+		br.Name = name
+		snap.Branches[name] = br
+	}
+	return snap, nil
+}
+
+// List returns the snapshots, newest first.
+func List(repo *git.Repo) ([]*Snapshot, error) {
+	refs, err := repo.ListRefs(&git.ListRefs{Patterns: []string{RefPrefix}})
+	if err != nil {
+		return nil, err
+	}
+	var ret []*Snapshot
+	for _, ref := range refs {
+		snap, err := Read(repo, strings.TrimPrefix(ref.Name, RefPrefix))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, snap)
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Time.After(ret[j].Time) })
+	return ret, nil
+}
+
+// Delete deletes the snapshot of the given name.
+func Delete(repo *git.Repo, name string) error {
+	if _, err := Read(repo, name); err != nil {
+		return err
+	}
+	_, err := repo.Git("update-ref", "-d", RefPrefix+name)
+	return err
+}
+
+// Restore resets the branches of the snapshot to the recorded commits and restores their av
+// metadata. The branches are updated atomically: either all of them are reset or none is. The
+// branches that were deleted since are re-created.
+//
+// The branches that are now in the same stacks as the recorded branches but were not recorded
+// (e.g., created by av split) are kept in Git but are no longer tracked by av. The branches
+// that have been added to the stack are returned in sorted order.
+func Restore(repo *git.Repo, db meta.DB, snap *Snapshot) ([]string, error) {
+	tx := db.WriteTx()
+	defer tx.Abort()
+
+	var untracked []string
+	for name := range snap.Branches {
+		if _, ok := tx.Branch(name); !ok {
+			continue
+		}
+		names, err := meta.StackBranches(tx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if _, ok := snap.Branches[n]; !ok && !slices.Contains(untracked, n) {
+				untracked = append(untracked, n)
+			}
+		}
+	}
+	sort.Strings(untracked)
+
+	for _, name := range snap.BranchNames() {
+		if err := repo.CheckNotInOtherWorktree(name); err != nil {
+			return nil, err
+		}
+	}
+
+	currentBranch, err := repo.CurrentBranchName()
+	if err != nil && !errors.Is(err, git.ErrDetachedHEAD) {
+		return nil, err
+	}
+	// Detach HEAD so that the checked out branch can be reset without touching the working
+	// tree. A branch is checked out again at the end.
+	if err := repo.Detach(); err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	for _, name := range snap.BranchNames() {
+		sb.WriteString("update refs/heads/" + name + " " + snap.Refs[name] + "\n")
+	}
+	if _, err := repo.Run(&git.RunOpts{
+		Args:      []string{"update-ref", "--create-reflog", "--stdin"},
+		Stdin:     strings.NewReader(sb.String()),
+		ExitError: true,
+	}); err != nil {
+		err = errors.WrapIff(err, "failed to restore the branches of snapshot %q", snap.Name)
+		if currentBranch != "" {
+			_, _ = repo.CheckoutBranch(&git.CheckoutBranch{Name: currentBranch})
+		}
+		return nil, err
+	}
+
+	for _, name := range untracked {
+		tx.DeleteBranch(name)
+	}
+	for _, br := range snap.Branches {
+		tx.SetBranch(br)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	// Check out the recorded branch if the current branch is not a branch of the snapshot.
+	checkout := currentBranch
+	if _, ok := snap.Branches[currentBranch]; !ok && snap.CurrentBranch != "" {
+		checkout = snap.CurrentBranch
+	}
+	if checkout != "" {
+		if _, err := repo.CheckoutBranch(&git.CheckoutBranch{Name: checkout}); err != nil {
+			return nil, err
+		}
+	}
+	return untracked, nil
+}
@@ -0,0 +1,128 @@
+// Package splitbranch splits a branch into multiple stacked branches by contiguous groups of
+// its commits (see av split).
+package splitbranch
+
+import (
+	"fmt"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+)
+
+// Group is a contiguous group of the commits of the split branch that becomes a branch.
+type Group struct {
+	// The name of the branch.
+	Name string
+	// The commits of the branch from the oldest.
+	Commits []string
+}
+
+// Head returns the last commit of the group.
+func (g Group) Head() string {
+	return g.Commits[len(g.Commits)-1]
+}
+
+// FormatPlan returns the initial plan to edit: all the commits (from the oldest) in one group
+// of the branch, followed by the instructions.
+func FormatPlan(branch string, commits []*git.CommitInfo) string {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "branch %s\n", branch)
+	for _, commit := range commits {
+		fmt.Fprintf(&sb, "pick %s %s\n", commit.ShortHash, commit.Subject)
+	}
+	fmt.Fprintf(&sb, instructionsText, branch, branch)
+	return sb.String()
+}
+
+const instructionsText = `
+# Split branch %q into stacked branches.
+#
+# Commands:
+# b, branch <branch-name>
+#         Start a new branch with the commits below it, stacked on the previous
+#         branch. The first branch is based on the parent of %q.
+# p, pick <commit-id>
+#         A commit of the branch above it.
+#
+# The commits are listed from the oldest. They must stay in the same order and
+# none can be dropped (use av reorder for that). The split branch must stay in
+# the plan, and its pull request and children follow it. For example,
+#
+#   branch feature-schema
+#   pick 1a2b3c4 Add the schema
+#   branch feature
+#   pick 5d6e7f8 Use the schema
+#
+# Leave the plan unchanged to cancel the split.
+`
+
+// ParsePlan parses the plan edited by the user. The lines starting with "#" are ignored.
+func ParsePlan(text string) ([]Group, error) {
+	var groups []Group
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// The commit subjects can have quotes, so the line is split by spaces only.
+		args := strings.Fields(line)
+		switch args[0] {
+		case "branch", "b":
+			if len(args) != 2 {
+				return nil, errors.Errorf("invalid split command %q (expected branch <branch-name>)", line)
+			}
+			groups = append(groups, Group{Name: args[1]})
+		case "pick", "p":
+			// The rest of the line is the commit subject.
+			if len(args) < 2 {
+				return nil, errors.Errorf("invalid split command %q (expected pick <commit-id>)", line)
+			}
+			if len(groups) == 0 {
+				return nil, errors.Errorf("commit %q must come after a branch command", args[1])
+			}
+			groups[len(groups)-1].Commits = append(groups[len(groups)-1].Commits, args[1])
+		default:
+			return nil, errors.Errorf("unknown split command %q", args[0])
+		}
+	}
+	return groups, nil
+}
+
+// ResolveGroups checks the groups against the commits of the split branch (from the oldest) and
+// returns the groups with the full commit hashes. The groups must contain all the commits in the
+// same order, each group must have a commit, the branch names must be unique, and the split
+// branch must be one of the groups.
+func ResolveGroups(branch string, commits []string, groups []Group) ([]Group, error) {
+	names := map[string]bool{}
+	var ret []Group
+	i := 0
+	for _, group := range groups {
+		if names[group.Name] {
+			return nil, errors.Errorf("branch %q is used more than once", group.Name)
+		}
+		names[group.Name] = true
+		if len(group.Commits) == 0 {
+			return nil, errors.Errorf("branch %q has no commits", group.Name)
+		}
+		resolved := Group{Name: group.Name}
+		for _, commit := range group.Commits {
+			if i >= len(commits) || !strings.HasPrefix(commits[i], commit) {
+				return nil, errors.Errorf(
+					"commit %q is out of order or not in the branch (the commits can't be reordered or duplicated)",
+					commit,
+				)
+			}
+			resolved.Commits = append(resolved.Commits, commits[i])
+			i++
+		}
+		ret = append(ret, resolved)
+	}
+	if i != len(commits) {
+		return nil, errors.Errorf("commit %s is missing (the commits can't be dropped)", git.ShortSha(commits[i]))
+	}
+	if !names[branch] {
+		return nil, errors.Errorf("branch %q must stay in the plan", branch)
+	}
+	return ret, nil
+}
@@ -0,0 +1,76 @@
+package splitbranch_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/splitbranch"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlan(t *testing.T) {
+	groups, err := splitbranch.ParsePlan(`
+# A comment
+branch feature-1
+pick 1111111 Add "quoted" stuff
+b feature
+p 2222222
+p 3333333 Another commit
+`)
+	require.NoError(t, err)
+	require.Equal(t, []splitbranch.Group{
+		{Name: "feature-1", Commits: []string{"1111111"}},
+		{Name: "feature", Commits: []string{"2222222", "3333333"}},
+	}, groups)
+
+	_, err = splitbranch.ParsePlan("pick 1111111\nbranch feature\n")
+	require.Error(t, err)
+	_, err = splitbranch.ParsePlan("branch feature extra\n")
+	require.Error(t, err)
+	_, err = splitbranch.ParsePlan("branch feature\ndrop 1111111\n")
+	require.Error(t, err)
+}
+
+func TestResolveGroups(t *testing.T) {
+	commits := []string{"1111111aaaa", "2222222bbbb", "3333333cccc"}
+	plan := func(groups ...splitbranch.Group) []splitbranch.Group { return groups }
+
+	groups, err := splitbranch.ResolveGroups("feature", commits, plan(
+		splitbranch.Group{Name: "feature-1", Commits: []string{"1111111"}},
+		splitbranch.Group{Name: "feature", Commits: []string{"2222222", "3333333cccc"}},
+	))
+	require.NoError(t, err)
+	require.Equal(t, []splitbranch.Group{
+		{Name: "feature-1", Commits: []string{"1111111aaaa"}},
+		{Name: "feature", Commits: []string{"2222222bbbb", "3333333cccc"}},
+	}, groups)
+	require.Equal(t, "3333333cccc", groups[1].Head())
+
+	for name, groups := range map[string][]splitbranch.Group{
+		"reordered": plan(
+			splitbranch.Group{Name: "feature", Commits: []string{"2222222", "1111111", "3333333"}},
+		),
+		"dropped": plan(
+			splitbranch.Group{Name: "feature", Commits: []string{"1111111", "2222222"}},
+		),
+		"duplicated": plan(
+			splitbranch.Group{Name: "feature", Commits: []string{"1111111", "2222222", "3333333", "3333333"}},
+		),
+		"empty group": plan(
+			splitbranch.Group{Name: "feature-1"},
+			splitbranch.Group{Name: "feature", Commits: []string{"1111111", "2222222", "3333333"}},
+		),
+		"duplicate name": plan(
+			splitbranch.Group{Name: "feature", Commits: []string{"1111111"}},
+			splitbranch.Group{Name: "feature", Commits: []string{"2222222", "3333333"}},
+		),
+		"split branch removed": plan(
+			splitbranch.Group{Name: "feature-1", Commits: []string{"1111111"}},
+			splitbranch.Group{Name: "feature-2", Commits: []string{"2222222", "3333333"}},
+		),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := splitbranch.ResolveGroups("feature", commits, groups)
+			require.Error(t, err)
+		})
+	}
+}
@@ -145,3 +145,43 @@ func getBranchHashes(
 	}
 	return hashToRefMap, refToHashMap, nil
 }
+
+// SetParent changes the parent of the branch piece. The merge base and the included commits are
+// re-calculated against the new parent.
+func SetParent(
+	repo *avgit.Repo,
+	piece *BranchPiece,
+	parent plumbing.ReferenceName,
+	parentIsTrunk bool,
+) error {
+	mb, err := repo.MergeBase(parent.String(), piece.Name.String())
+	if err != nil {
+		return err
+	}
+	mergeBase := plumbing.NewHash(mb)
+	ref, err := repo.GoGitRepo().Reference(piece.Name, true)
+	if err != nil {
+		return err
+	}
+	commit, err := repo.GoGitRepo().CommitObject(ref.Hash())
+	if err != nil {
+		return err
+	}
+	var included []*object.Commit
+	err = object.NewCommitPreorderIter(commit, nil, []plumbing.Hash{mergeBase}).
+		ForEach(func(c *object.Commit) error {
+			included = append(included, c)
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+
+	piece.PossibleParents = nil
+	piece.ContainsMergeCommit = false
+	piece.Parent = parent
+	piece.ParentIsTrunk = parentIsTrunk
+	piece.ParentMergeBase = mergeBase
+	piece.IncludedCommits = included
+	return nil
+}
@@ -0,0 +1,160 @@
+package treedetector
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	avgit "github.com/aviator-co/av/internal/git"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// InferParentsByMergeBase infers the parents of the branch pieces that are based on the trunk
+// (or whose parent is unknown) from the merge bases with the other branches.
+//
+// DetectBranches finds a parent only if the parent's head is in the history of the branch. If
+// the parent branch got new commits after the branch was created from it, the branch looks like
+// it's based on the trunk with the parent's commits included. This finds such a parent: a
+// branch whose merge base with the branch is newer than the trunk's. If multiple branches share
+// the history, the one with the nearest merge base is the parent. A branch that is not adopted
+// yet must have been created before the branch to be its parent (according to the reflog) so
+// that two branches sharing commits don't become each other's parent. If the parent is still
+// ambiguous, the candidates are set as PossibleParents.
+//
+// adoptedBranches are the branches that are already managed by av. They can be the parents too.
+func InferParentsByMergeBase(
+	repo *avgit.Repo,
+	pieces map[plumbing.ReferenceName]*BranchPiece,
+	adoptedBranches []plumbing.ReferenceName,
+) error {
+	candidates := append([]plumbing.ReferenceName{}, adoptedBranches...)
+	for bn := range pieces {
+		candidates = append(candidates, bn)
+	}
+	sortRefNames(candidates)
+	var names []plumbing.ReferenceName
+	for bn := range pieces {
+		names = append(names, bn)
+	}
+	sortRefNames(names)
+
+	creationTimes := map[plumbing.ReferenceName]int64{}
+	creationTime := func(bn plumbing.ReferenceName) int64 {
+		if t, ok := creationTimes[bn]; ok {
+			return t
+		}
+		t := branchCreationTime(repo, bn)
+		creationTimes[bn] = t
+		return t
+	}
+
+	for _, bn := range names {
+		piece := pieces[bn]
+		if piece.ContainsMergeCommit || (piece.Parent != "" && !piece.ParentIsTrunk) {
+			continue
+		}
+		trunkMergeBase := piece.ParentMergeBase
+		if !piece.ParentIsTrunk {
+			mb, err := getNearestTrunkCommit(repo, bn)
+			if err != nil {
+				return err
+			}
+			trunkMergeBase = mb
+		}
+		head, err := repo.RevParse(&avgit.RevParse{Rev: bn.String()})
+		if err != nil {
+			return err
+		}
+		children := GetChildren(pieces, bn)
+
+		type candidate struct {
+			name      plumbing.ReferenceName
+			mergeBase string
+		}
+		var found []candidate
+		for _, cand := range candidates {
+			if cand == bn {
+				continue
+			}
+			if _, ok := children[cand]; ok {
+				continue
+			}
+			if _, unmanaged := pieces[cand]; unmanaged {
+				ct, bt := creationTime(cand), creationTime(bn)
+				if ct == 0 || bt == 0 || ct >= bt {
+					continue
+				}
+			}
+			mb, err := repo.MergeBase(cand.String(), bn.String())
+			if err != nil {
+				// No common history.
+				continue
+			}
+			if mb == trunkMergeBase.String() || mb == head {
+				continue
+			}
+			if !isAncestor(repo, trunkMergeBase.String(), mb) {
+				continue
+			}
+			found = append(found, candidate{name: cand, mergeBase: mb})
+		}
+		if len(found) == 0 {
+			continue
+		}
+
+		// Keep the candidates with the nearest merge base.
+		nearest := found[0].mergeBase
+		for _, c := range found[1:] {
+			if c.mergeBase != nearest && isAncestor(repo, nearest, c.mergeBase) {
+				nearest = c.mergeBase
+			}
+		}
+		var parents []plumbing.ReferenceName
+		for _, c := range found {
+			if c.mergeBase == nearest {
+				parents = append(parents, c.name)
+			}
+		}
+		if len(parents) > 1 {
+			piece.Parent = ""
+			piece.ParentIsTrunk = false
+			piece.ParentMergeBase = plumbing.ZeroHash
+			piece.IncludedCommits = nil
+			piece.PossibleParents = parents
+			continue
+		}
+		if err := SetParent(repo, piece, parents[0], false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isAncestor(repo *avgit.Repo, ancestor string, descendant string) bool {
+	_, err := repo.Git("merge-base", "--is-ancestor", ancestor, descendant)
+	return err == nil
+}
+
+// branchCreationTime returns the time of the oldest reflog entry of the branch in Unix seconds,
+// or 0 if the branch has no reflog.
+func branchCreationTime(repo *avgit.Repo, bn plumbing.ReferenceName) int64 {
+	out, err := repo.Git("reflog", "show", "--date=unix", "--format=%gd", bn.String())
+	if err != nil || out == "" {
+		return 0
+	}
+	lines := strings.Split(out, "\n")
+	oldest := lines[len(lines)-1]
+	start, end := strings.LastIndex(oldest, "@{"), strings.LastIndex(oldest, "}")
+	if start < 0 || end < start {
+		return 0
+	}
+	t, err := strconv.ParseInt(oldest[start+2:end], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return t
+}
+
+func sortRefNames(names []plumbing.ReferenceName) {
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+}
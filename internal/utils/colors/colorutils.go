@@ -29,9 +29,18 @@ var (
 	ProgressStyle = lipgloss.NewStyle().Foreground(Amber600)
 	SuccessStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color('2'))
 	FailureStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color('1'))
+	CommandStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color('5'))
 	QuestionStyle = lipgloss.NewStyle().Bold(true)
 
 	// Aligned with promptkit
 	// https://github.com/erikgeiser/promptkit/blob/main/selection/prompt.go#L62
 	PromptChoice = lipgloss.NewStyle().Foreground(lipgloss.Color("32")).Bold(true)
 )
+
+// The colors of av tree (see Theme).
+var (
+	BranchColor    = Green600
+	CurrentColor   = Cyan600
+	AttentionColor = Amber600
+	PinnedColor    = Purple600
+)
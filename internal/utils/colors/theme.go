@@ -0,0 +1,214 @@
+package colors
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fatih/color"
+	"github.com/muesli/termenv"
+)
+
+// Theme is the set of colors for the roles in the output. The colors are ANSI color numbers
+// (0-255) or hex colors (e.g., "#0072b2"). An empty color leaves the text uncolored.
+//
+// The hex colors are converted to the closest color that the terminal supports (see
+// SetColorProfile).
+type Theme struct {
+	// The successful results (e.g., "✓ Restack is done").
+	Success lipgloss.Color
+	// The warnings.
+	Warning lipgloss.Color
+	// The failures and the errors.
+	Failure lipgloss.Color
+	// The commands to run (e.g., "av restack --continue").
+	Command lipgloss.Color
+	// The user-provided values (e.g., the branch names in the messages).
+	Input lipgloss.Color
+	// The operations in progress. This is also used for the stale branches and the notes in
+	// av tree.
+	Progress lipgloss.Color
+	// The branch names in av tree.
+	Branch lipgloss.Color
+	// The current branch (HEAD) and the preview environments in av tree.
+	Current lipgloss.Color
+	// The pinned branches in av tree.
+	Pinned lipgloss.Color
+	// The selected choice in the prompts.
+	Prompt lipgloss.Color
+}
+
+// The built-in themes.
+var themes = map[string]Theme{
+	"default": {
+		Success:  "2",
+		Warning:  "3",
+		Failure:  "1",
+		Command:  "5",
+		Input:    "6",
+		Progress: Amber600,
+		Branch:   Green600,
+		Current:  Cyan600,
+		Pinned:   Purple600,
+		Prompt:   "32",
+	},
+	// The Okabe-Ito palette, which is distinguishable with the common color vision
+	// deficiencies. The successes and the failures are blue and vermillion instead of green
+	// and red.
+	"colorblind": {
+		Success:  "#56b4e9",
+		Warning:  "#e69f00",
+		Failure:  "#d55e00",
+		Command:  "#cc79a7",
+		Input:    "#009e73",
+		Progress: "#e69f00",
+		Branch:   "#0072b2",
+		Current:  "#009e73",
+		Pinned:   "#cc79a7",
+		Prompt:   "#56b4e9",
+	},
+	// No colors. The bold and the faint text are kept.
+	"monochrome": {},
+}
+
+// ThemeNames returns the names of the built-in themes.
+func ThemeNames() []string {
+	var ret []string
+	for name := range themes {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// LookupTheme returns the built-in theme with the given name.
+func LookupTheme(name string) (Theme, error) {
+	theme, ok := themes[name]
+	if !ok {
+		return Theme{}, errors.Errorf(
+			"unknown theme %q (expected one of %s)", name, strings.Join(ThemeNames(), ", "),
+		)
+	}
+	return theme, nil
+}
+
+// Override sets the color of the given role (the lowercase field name, e.g., "success").
+func (t *Theme) Override(role string, c string) error {
+	if c != "" && !validColor(c) {
+		return errors.Errorf("invalid color %q for %q (expected 0-255 or a hex color)", c, role)
+	}
+	fields := map[string]*lipgloss.Color{
+		"success":  &t.Success,
+		"warning":  &t.Warning,
+		"failure":  &t.Failure,
+		"command":  &t.Command,
+		"input":    &t.Input,
+		"progress": &t.Progress,
+		"branch":   &t.Branch,
+		"current":  &t.Current,
+		"pinned":   &t.Pinned,
+		"prompt":   &t.Prompt,
+	}
+	field, ok := fields[strings.ToLower(role)]
+	if !ok {
+		var names []string
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return errors.Errorf(
+			"unknown color role %q (expected one of %s)", role, strings.Join(names, ", "),
+		)
+	}
+	*field = lipgloss.Color(c)
+	return nil
+}
+
+func validColor(c string) bool {
+	if hex, ok := strings.CutPrefix(c, "#"); ok {
+		if len(hex) != 3 && len(hex) != 6 {
+			return false
+		}
+		_, err := strconv.ParseUint(hex, 16, 32)
+		return err == nil
+	}
+	n, err := strconv.Atoi(c)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// SetColorProfile overrides the color support of the terminal. The profile is one of "auto"
+// (or empty, detected from the terminal and the environment variables such as COLORTERM),
+// "none", "ansi" (16 colors), "ansi256", or "truecolor". The profiles other than "auto" and
+// "none" force the colors even if the output is not a terminal.
+func SetColorProfile(profile string) error {
+	switch profile {
+	case "", "auto":
+		return nil
+	case "none":
+		lipgloss.SetColorProfile(termenv.Ascii)
+		color.NoColor = true
+	case "ansi":
+		lipgloss.SetColorProfile(termenv.ANSI)
+		color.NoColor = false
+	case "ansi256":
+		lipgloss.SetColorProfile(termenv.ANSI256)
+		color.NoColor = false
+	case "truecolor":
+		lipgloss.SetColorProfile(termenv.TrueColor)
+		color.NoColor = false
+	default:
+		return errors.Errorf(
+			"invalid color profile %q (expected auto, none, ansi, ansi256, or truecolor)",
+			profile,
+		)
+	}
+	return nil
+}
+
+// SetTheme applies the theme to the colors and the styles of this package. This should be
+// called before any output is written.
+func SetTheme(theme Theme) {
+	CliCmdC = themeColor(theme.Command)
+	SuccessC = themeColor(theme.Success)
+	WarningC = themeColor(theme.Warning)
+	FailureC = themeColor(theme.Failure)
+	UserInputC = themeColor(theme.Input)
+
+	CliCmd = CliCmdC.Sprint
+	Success = SuccessC.Sprint
+	Warning = WarningC.Sprint
+	Failure = FailureC.Sprint
+	UserInput = UserInputC.Sprint
+
+	ProgressStyle = lipgloss.NewStyle().Foreground(theme.Progress)
+	SuccessStyle = lipgloss.NewStyle().Foreground(theme.Success)
+	FailureStyle = lipgloss.NewStyle().Foreground(theme.Failure)
+	CommandStyle = lipgloss.NewStyle().Foreground(theme.Command)
+	PromptChoice = lipgloss.NewStyle().Foreground(theme.Prompt).Bold(true)
+
+	BranchColor = theme.Branch
+	CurrentColor = theme.Current
+	AttentionColor = theme.Progress
+	PinnedColor = theme.Pinned
+}
+
+// themeColor returns the color for the fatih/color based helpers (e.g., Success), converted to
+// the color profile of the terminal.
+func themeColor(c lipgloss.Color) *color.Color {
+	var attrs []color.Attribute
+	if tc := lipgloss.ColorProfile().Color(string(c)); tc != nil {
+		for _, s := range strings.Split(tc.Sequence(false), ";") {
+			if n, err := strconv.Atoi(s); err == nil {
+				attrs = append(attrs, color.Attribute(n))
+			}
+		}
+	}
+	ret := color.New(attrs...)
+	if len(attrs) == 0 {
+		// Otherwise, the text is wrapped with the empty escape sequences.
+		ret.DisableColor()
+	}
+	return ret
+}
@@ -0,0 +1,47 @@
+package colors_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/utils/colors"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThemeOverride(t *testing.T) {
+	theme, err := colors.LookupTheme("colorblind")
+	require.NoError(t, err)
+	require.NoError(t, theme.Override("Success", "#0072b2"))
+	require.NoError(t, theme.Override("failure", "208"))
+	require.NoError(t, theme.Override("branch", ""))
+	assert.Equal(t, lipgloss.Color("#0072b2"), theme.Success)
+	assert.Equal(t, lipgloss.Color("208"), theme.Failure)
+	assert.Equal(t, lipgloss.Color(""), theme.Branch)
+
+	require.ErrorContains(t, theme.Override("success", "green"), "invalid color")
+	require.ErrorContains(t, theme.Override("success", "256"), "invalid color")
+	require.ErrorContains(t, theme.Override("background", "1"), "unknown color role")
+
+	_, err = colors.LookupTheme("solarized")
+	require.ErrorContains(t, err, "colorblind, default, monochrome")
+}
+
+func TestSetTheme(t *testing.T) {
+	require.NoError(t, colors.SetColorProfile("ansi256"))
+	defer func() { _ = colors.SetColorProfile("none") }()
+
+	theme, err := colors.LookupTheme("default")
+	require.NoError(t, err)
+	require.NoError(t, theme.Override("success", "#0072b2"))
+	colors.SetTheme(theme)
+	// The hex color is converted to the closest color of the 256-color palette.
+	assert.True(t, strings.HasPrefix(colors.Success("ok"), "\x1b[38;5;25mok\x1b["))
+	assert.Equal(t, "\x1b[31mfailed\x1b[0m", colors.Failure("failed"))
+
+	monochrome, err := colors.LookupTheme("monochrome")
+	require.NoError(t, err)
+	colors.SetTheme(monochrome)
+	assert.Equal(t, "ok", colors.Success("ok"))
+}
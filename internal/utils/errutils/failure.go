@@ -0,0 +1,59 @@
+package errutils
+
+// FailureClass is a class of failures that av exits with a stable exit code for, so that the
+// scripts (e.g., CI jobs) can tell them apart. The exit codes are listed in av(1).
+type FailureClass struct {
+	// The name of the class shown in the JSON error output (e.g., "conflict").
+	Name string
+	// The exit code of av for the class.
+	ExitCode int
+}
+
+var (
+	// FailureGeneric is any other failure.
+	FailureGeneric = FailureClass{Name: "error", ExitCode: 1}
+	// FailureConflict is a rebase that stopped at a conflict for the user to resolve.
+	FailureConflict = FailureClass{Name: "conflict", ExitCode: 2}
+	// FailureAuth is a missing or rejected credential of GitHub or GitLab.
+	FailureAuth = FailureClass{Name: "auth", ExitCode: 3}
+	// FailureDirtyWorktree is a command that refuses to run with uncommitted changes.
+	FailureDirtyWorktree = FailureClass{Name: "dirty-worktree", ExitCode: 4}
+	// FailureMetadataMismatch is the av metadata that doesn't match the Git branches (e.g., a
+	// branch that is not adopted, or a stale base).
+	FailureMetadataMismatch = FailureClass{Name: "metadata-mismatch", ExitCode: 5}
+	// FailureInteractionRequired is a command that needs to open an editor or a prompt, which
+	// cannot be done (e.g., with --non-interactive).
+	FailureInteractionRequired = FailureClass{Name: "interaction-required", ExitCode: 6}
+)
+
+// ClassifiedError is implemented by the errors that belong to a failure class.
+type ClassifiedError interface {
+	error
+	FailureClass() FailureClass
+}
+
+type classifiedError struct {
+	err   error
+	class FailureClass
+}
+
+func (e classifiedError) Error() string              { return e.err.Error() }
+func (e classifiedError) Unwrap() error              { return e.err }
+func (e classifiedError) FailureClass() FailureClass { return e.class }
+
+// WithFailureClass marks err as a failure of the given class. It returns nil if err is nil.
+func WithFailureClass(err error, class FailureClass) error {
+	if err == nil {
+		return nil
+	}
+	return classifiedError{err: err, class: class}
+}
+
+// ClassOf returns the failure class of err, which is the class of the outermost
+// ClassifiedError in the chain. It returns false if err doesn't belong to any class.
+func ClassOf(err error) (FailureClass, bool) {
+	if classified, ok := As[ClassifiedError](err); ok {
+		return classified.FailureClass(), true
+	}
+	return FailureClass{}, false
+}
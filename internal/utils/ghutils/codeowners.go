@@ -0,0 +1,157 @@
+package ghutils
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/git"
+)
+
+// codeownersLocations are the locations where GitHub looks for the CODEOWNERS file, in the
+// order of precedence.
+var codeownersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+type CodeownersRule struct {
+	Pattern string
+	// The owners of the matching files. Each owner is either "@user", "@org/team", or an
+	// email address.
+	Owners []string
+	re     *regexp.Regexp
+}
+
+type Codeowners struct {
+	Rules []CodeownersRule
+}
+
+// ReadCodeowners reads the CODEOWNERS file of the repository. It returns nil if the repository
+// doesn't have one.
+func ReadCodeowners(repo *git.Repo) (*Codeowners, error) {
+	for _, location := range codeownersLocations {
+		f, err := os.Open(filepath.Join(repo.Dir(), location))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		co, err := ParseCodeowners(f)
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to parse %s", location)
+		}
+		return co, nil
+	}
+	return nil, nil
+}
+
+// ReadCodeownersAt reads the CODEOWNERS file of the repository at the given revision (e.g., the
+// trunk branch), so that the changes in the working tree or in the branch being submitted don't
+// decide its own reviewers. It returns nil if the revision doesn't have one.
+func ReadCodeownersAt(repo *git.Repo, rev string) (*Codeowners, error) {
+	if _, err := repo.RevParse(&git.RevParse{Rev: rev + "^{commit}"}); err != nil {
+		return nil, errors.WrapIff(err, "failed to resolve %q", rev)
+	}
+	for _, location := range codeownersLocations {
+		out, err := repo.Run(&git.RunOpts{Args: []string{"cat-file", "blob", rev + ":" + location}})
+		if err != nil {
+			return nil, err
+		}
+		if out.ExitCode != 0 {
+			// The file doesn't exist at the revision.
+			continue
+		}
+		co, err := ParseCodeowners(bytes.NewReader(out.Stdout))
+		if err != nil {
+			return nil, errors.WrapIff(err, "failed to parse %s", location)
+		}
+		return co, nil
+	}
+	return nil, nil
+}
+
+// ParseCodeowners parses a CODEOWNERS file.
+func ParseCodeowners(r io.Reader) (*Codeowners, error) {
+	var co Codeowners
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		re, err := codeownersPatternRegexp(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		co.Rules = append(co.Rules, CodeownersRule{
+			Pattern: fields[0],
+			Owners:  fields[1:],
+			re:      re,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &co, nil
+}
+
+// Owners returns the owners of the given file path (relative to the repository root). As
+// with GitHub, the last matching rule takes precedence. A matching rule without owners means
+// that the file has no owners.
+func (co *Codeowners) Owners(path string) []string {
+	for i := len(co.Rules) - 1; i >= 0; i-- {
+		if co.Rules[i].re.MatchString(path) {
+			return co.Rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// codeownersPatternRegexp converts a CODEOWNERS pattern (which mostly follows the gitignore
+// pattern rules) into a regular expression that matches the file paths.
+func codeownersPatternRegexp(pattern string) (*regexp.Regexp, error) {
+	// A pattern with a slash at the beginning or in the middle is relative to the root.
+	// Otherwise, it can match at any level.
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	p := strings.Trim(pattern, "/")
+
+	sb := strings.Builder{}
+	if anchored {
+		sb.WriteString("^")
+	} else {
+		sb.WriteString("^(.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case p[i] == '*':
+			sb.WriteString("[^/]*")
+		case p[i] == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	if strings.HasSuffix(p, "/*") && !strings.HasSuffix(p, "/**/*") {
+		// Unlike gitignore, GitHub matches "docs/*" only with the direct children of the
+		// directory.
+		sb.WriteString("$")
+	} else {
+		// A pattern that matches a directory matches everything under it.
+		sb.WriteString("(/.*)?$")
+	}
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,67 @@
+package ghutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aviator-co/av/internal/git/gittest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeowners(t *testing.T) {
+	co, err := ParseCodeowners(strings.NewReader(`
+# Default owners
+*       @org/everyone
+*.js    @js-owner # JavaScript files
+/build/ @org/build
+docs/*  @org/docs
+apps/   @org/apps
+**/logs @org/logs
+/vendor/
+`))
+	require.NoError(t, err)
+
+	for _, tt := range []struct {
+		path string
+		want []string
+	}{
+		{"README.md", []string{"@org/everyone"}},
+		{"src/index.js", []string{"@js-owner"}},
+		{"build/out/main.go", []string{"@org/build"}},
+		{"src/build/main.go", []string{"@org/everyone"}},
+		{"docs/index.md", []string{"@org/docs"}},
+		{"docs/guide/index.md", []string{"@org/everyone"}},
+		{"src/apps/web/main.go", []string{"@org/apps"}},
+		{"deploy/logs/today.txt", []string{"@org/logs"}},
+		{"vendor/lib/lib.go", []string{}},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, co.Owners(tt.path))
+		})
+	}
+}
+
+func TestReadCodeownersAt(t *testing.T) {
+	repo := gittest.NewTempRepo(t)
+	repo.CommitFile(t, "CODEOWNERS", "* @org/trunk\n")
+	trunk := repo.GetCommitAtRef(t, "refs/heads/main")
+	repo.Git(t, "switch", "-c", "feature")
+	repo.CommitFile(t, "CODEOWNERS", "* @org/feature\n")
+
+	// The file is read from the given revision, not from the working tree.
+	co, err := ReadCodeownersAt(repo.AsAvGitRepo(), trunk.String())
+	require.NoError(t, err)
+	require.Equal(t, []string{"@org/trunk"}, co.Owners("README.md"))
+
+	co, err = ReadCodeownersAt(repo.AsAvGitRepo(), "feature")
+	require.NoError(t, err)
+	require.Equal(t, []string{"@org/feature"}, co.Owners("README.md"))
+
+	co, err = ReadCodeownersAt(repo.AsAvGitRepo(), trunk.String()+"^")
+	require.NoError(t, err)
+	require.Nil(t, co)
+
+	_, err = ReadCodeownersAt(repo.AsAvGitRepo(), "no-such-branch")
+	require.Error(t, err)
+}
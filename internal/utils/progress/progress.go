@@ -0,0 +1,58 @@
+// Package progress emits machine-readable progress events (one JSON object per line) for the
+// wrappers of av (e.g., editor plugins) to render progress bars for long operations.
+package progress
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+const (
+	PhaseFetch   = "fetch"
+	PhaseRestack = "restack"
+	PhasePush    = "push"
+	PhasePrune   = "prune"
+	PhaseSubmit  = "submit"
+)
+
+// Event is a progress event.
+type Event struct {
+	// The phase of the operation (e.g., "restack").
+	Phase string `json:"phase"`
+	// The branch that is being processed, if any.
+	Branch string `json:"branch,omitempty"`
+	// The progress of the phase from 0 to 100.
+	Percent int `json:"percent"`
+}
+
+var (
+	mu     sync.Mutex
+	output io.Writer
+)
+
+// SetOutput sets the writer that the progress events are written to. The events are not
+// emitted if the output is nil (the default).
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	output = w
+}
+
+// Report emits a progress event for the phase with done out of total steps completed.
+func Report(phase string, branch string, done int, total int) {
+	mu.Lock()
+	defer mu.Unlock()
+	if output == nil {
+		return
+	}
+	percent := 100
+	if total > 0 {
+		percent = done * 100 / total
+	}
+	data, err := json.Marshal(Event{Phase: phase, Branch: branch, Percent: percent})
+	if err != nil {
+		return
+	}
+	_, _ = output.Write(append(data, '\n'))
+}
@@ -0,0 +1,25 @@
+package progress_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aviator-co/av/internal/utils/progress"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	var buf bytes.Buffer
+	progress.Report(progress.PhaseRestack, "one", 0, 2)
+	assert.Empty(t, buf.String())
+
+	progress.SetOutput(&buf)
+	t.Cleanup(func() { progress.SetOutput(nil) })
+	progress.Report(progress.PhaseRestack, "one", 1, 3)
+	progress.Report(progress.PhaseFetch, "", 0, 0)
+	assert.Equal(
+		t,
+		`{"phase":"restack","branch":"one","percent":33}`+"\n"+`{"phase":"fetch","percent":100}`+"\n",
+		buf.String(),
+	)
+}
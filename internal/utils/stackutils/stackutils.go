@@ -99,6 +99,16 @@ func BuildStackTreeAllBranches(
 	return buildStackTree(currentBranch, tx.AllBranches(), sortCurrent)
 }
 
+// BuildStackTreeFromBranches builds the trees of the given branches (e.g., the branches recorded
+// in the past) instead of the branches in the database.
+func BuildStackTreeFromBranches(
+	branches map[string]meta.Branch,
+	currentBranch string,
+	sortCurrent bool,
+) []*StackTreeNode {
+	return buildStackTree(currentBranch, branches, sortCurrent)
+}
+
 func BuildStackTreeCurrentStack(
 	tx meta.ReadTx,
 	currentBranch string,
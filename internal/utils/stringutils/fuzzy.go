@@ -0,0 +1,55 @@
+package stringutils
+
+import (
+	"sort"
+	"strings"
+)
+
+// FuzzyMatch reports whether all the characters of the query appear in s in order (ignoring
+// case). The returned score is lower for better matches: a substring match scores by its
+// position, and other matches score by how spread out the matched characters are.
+func FuzzyMatch(query, s string) (score int, ok bool) {
+	query = strings.ToLower(query)
+	lower := strings.ToLower(s)
+	if i := strings.Index(lower, query); i >= 0 {
+		return i, true
+	}
+	queryRunes := []rune(query)
+	qi := 0
+	first, last := -1, -1
+	for i, r := range []rune(lower) {
+		if qi < len(queryRunes) && r == queryRunes[qi] {
+			if first < 0 {
+				first = i
+			}
+			last = i
+			qi++
+		}
+	}
+	if qi < len(queryRunes) {
+		return 0, false
+	}
+	// Substring matches always come first.
+	return len(lower) + last - first, true
+}
+
+// FuzzyFilter returns the items that match the query (see FuzzyMatch), best matches first.
+// Items with the same score keep their original order.
+func FuzzyFilter(query string, items []string) []string {
+	type match struct {
+		item  string
+		score int
+	}
+	var matches []match
+	for _, item := range items {
+		if score, ok := FuzzyMatch(query, item); ok {
+			matches = append(matches, match{item, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+	ret := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ret = append(ret, m.item)
+	}
+	return ret
+}
@@ -0,0 +1,25 @@
+package stringutils_test
+
+import (
+	"testing"
+
+	"github.com/aviator-co/av/internal/utils/stringutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	_, ok := stringutils.FuzzyMatch("fbar", "feature-bar")
+	require.True(t, ok)
+	_, ok = stringutils.FuzzyMatch("FB", "feature-bar")
+	require.True(t, ok)
+	_, ok = stringutils.FuzzyMatch("bf", "feature-bar")
+	require.False(t, ok)
+}
+
+func TestFuzzyFilter(t *testing.T) {
+	items := []string{"feature-foo-bar", "bar", "fix-baz", "feature-bar"}
+	require.Equal(t, []string{"bar", "feature-bar", "feature-foo-bar"}, stringutils.FuzzyFilter("bar", items))
+	require.Equal(t, []string{"feature-bar", "feature-foo-bar"}, stringutils.FuzzyFilter("fbar", items))
+	require.Equal(t, []string{"fix-baz"}, stringutils.FuzzyFilter("fxb", items))
+	require.Empty(t, stringutils.FuzzyFilter("qux", items))
+}
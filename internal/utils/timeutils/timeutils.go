@@ -1,7 +1,11 @@
 package timeutils
 
 import (
+	"strconv"
+	"strings"
 	"time"
+
+	"emperror.dev/errors"
 )
 
 // FormalLocal takes a time and converts it into a readable format in the local timezome (outputLayout).
@@ -10,3 +14,44 @@ func FormatLocal(timestamp time.Time) string {
 	timestamp = timestamp.In(time.Local)
 	return timestamp.Format(outputLayout)
 }
+
+// ParseDuration parses a duration string. In addition to the units supported by
+// time.ParseDuration, it accepts a number of days ("14d") or weeks ("2w").
+func ParseDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.ParseFloat(n, 64)
+			if err != nil || v < 0 {
+				return 0, errors.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(v * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// FormatShortDuration formats a duration in the largest whole unit of the units accepted by
+// ParseDuration (e.g., "45s", "12m", "5h", "3d", or "2w").
+func FormatShortDuration(d time.Duration) string {
+	for _, u := range []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+	} {
+		if d >= u.unit {
+			return strconv.FormatInt(int64(d/u.unit), 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(max(d, 0)/time.Second), 10) + "s"
+}
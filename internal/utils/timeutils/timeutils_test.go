@@ -0,0 +1,54 @@
+package timeutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+		err   bool
+	}{
+		{"14d", 14 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDuration(tt.input)
+			if (err != nil) != tt.err {
+				t.Fatalf("ParseDuration(%q) error = %v, want error %v", tt.input, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatShortDuration(t *testing.T) {
+	tests := []struct {
+		input time.Duration
+		want  string
+	}{
+		{45 * time.Second, "45s"},
+		{90 * time.Second, "1m"},
+		{5*time.Hour + 59*time.Minute, "5h"},
+		{3 * 24 * time.Hour, "3d"},
+		{15 * 24 * time.Hour, "2w"},
+		{-time.Second, "0s"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatShortDuration(tt.input); got != tt.want {
+			t.Errorf("FormatShortDuration(%v) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package uiutils
+
+import (
+	"os"
+
+	"emperror.dev/errors"
+	"github.com/aviator-co/av/internal/config"
+	"github.com/aviator-co/av/internal/utils/errutils"
+	"github.com/mattn/go-isatty"
+)
+
+// IsInteractive returns true if av can prompt the user: the standard input is a terminal and
+// the non-interactive mode is off. The optional prompts are skipped otherwise.
+func IsInteractive() bool {
+	return !config.NonInteractive && isatty.IsTerminal(os.Stdin.Fd())
+}
+
+// RequireInteractive returns an error of the interaction-required class in the non-interactive
+// mode. what describes the input that av would ask for (e.g., "the commit message"), and hint
+// tells how to give it without a prompt (e.g., "use -m"). It returns nil otherwise.
+func RequireInteractive(what string, hint string) error {
+	if !config.NonInteractive {
+		return nil
+	}
+	msg := what + " needs the user's input, which is disabled by --non-interactive"
+	if hint != "" {
+		msg += " (" + hint + ")"
+	}
+	return InteractionRequired(errors.New(msg))
+}
+
+// InteractionRequired marks err as a failure that needs the user's input that cannot be asked.
+func InteractionRequired(err error) error {
+	return errutils.WithFailureClass(err, errutils.FailureInteractionRequired)
+}
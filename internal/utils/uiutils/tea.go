@@ -3,6 +3,7 @@ package uiutils
 import (
 	"os"
 
+	"github.com/aviator-co/av/internal/config"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mattn/go-isatty"
 )
@@ -18,7 +19,7 @@ type BubbleTeaModelWithExitHandling interface {
 
 func RunBubbleTea(model BubbleTeaModelWithExitHandling) error {
 	var opts []tea.ProgramOption
-	if !isatty.IsTerminal(os.Stdout.Fd()) {
+	if !isatty.IsTerminal(os.Stdout.Fd()) || config.NonInteractive {
 		opts = []tea.ProgramOption{
 			tea.WithInput(nil),
 		}